@@ -1,21 +1,32 @@
 package main
 
 import (
+	"context"       // 傳遞 readiness 檢查的 context
 	"database/sql"  // 提供通用 SQL 資料庫操作介面
+	"errors"        // 判斷 HTTP server 是否為正常關閉
 	"log"           // 用於輸出啟動與錯誤日誌
+	"net/http"      // 以 http.Server 啟動 API，才能優雅關閉
 	"os"            // 檔案與路徑相關操作（例如建立資料夾）
 	"path/filepath" // 處理檔案路徑（例如取 DB 目錄）
+	"syscall"       // 監聽 SIGTERM，容器停止時優雅關閉
+	"time"          // 設定 HTTP server 關閉的等待時間
 
 	"github.com/gin-gonic/gin" // Gin HTTP 框架
 
 	"github.com/golang-migrate/migrate/v4"                               // 資料庫 migration 主套件
 	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite" // SQLite 專用的 migrate driver
 	_ "github.com/golang-migrate/migrate/v4/source/file"                 // 檔案系統作為 migration source（使用 file://）
+	"github.com/prometheus/client_golang/prometheus"                     // Prometheus registry
 
+	"sessionservice/internal/challenge"    // signup 防機器人 challenge（CAPTCHA / PoW）
 	"sessionservice/internal/config"       // 讀取服務設定（包含 DBPath / Redis / JWT 等）
 	"sessionservice/internal/db"           // sqlc 產生的 DB 存取層
+	"sessionservice/internal/health"       // readiness 檢查與結果快取
 	httpapi "sessionservice/internal/http" // HTTP router 與 handler
 	"sessionservice/internal/infra"        // Redis / Asynq 等基礎設施
+	"sessionservice/internal/lifecycle"    // 關閉時依反向順序收尾各子系統
+	"sessionservice/internal/metrics"      // 業務指標的 Prometheus 實作
+	"sessionservice/internal/middleware"   // admin key 驗證失敗稽核
 	"sessionservice/internal/session"      // SessionService 登入 / 登出邏輯
 	"sessionservice/internal/token"        // JWT 管理
 
@@ -23,7 +34,14 @@ import (
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	infra.SetErrorLogSampling(cfg.LogSampleInterval)
+
+	// 各子系統啟動後向 lifecycle 註冊關閉函式，收到訊號時反向關閉
+	lc := lifecycle.NewManager(lifecycle.DefaultTimeout)
 
 	// 確保資料夾存在
 	if err := os.MkdirAll(filepath.Dir(cfg.DBPath), 0o755); err != nil {
@@ -35,7 +53,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to open sqlite: %v", err)
 	}
-	defer sqlDB.Close()
+	lc.RegisterCloser("sqlite", sqlDB)
 
 	// 簡單檢查連線
 	if err := sqlDB.Ping(); err != nil {
@@ -52,26 +70,110 @@ func main() {
 
 	// Redis
 	rdb := infra.NewRedisClient(cfg)
-	defer rdb.Close()
+	lc.RegisterCloser("redis", rdb)
+	if cfg.RedisKeepAliveInterval > 0 {
+		// 在 Redis client 關閉前先停止
+		keepAlive := infra.NewRedisKeepAlive(rdb, cfg.RedisKeepAliveInterval)
+		keepAlive.Start()
+		lc.Register("redis keepalive", keepAlive.Stop)
+	}
 
 	// Asynq client（給 SessionService 使用）
 	asynqClient := infra.NewAsynqClient(cfg)
-	defer asynqClient.Close()
+	lc.RegisterCloser("asynq client", asynqClient)
 
-	// Session service
-	sessSvc := session.NewSessionService(q, rdb, cfg, asynqClient)
+	// Session service（業務指標註冊到預設的 Prometheus registry）
+	promMetrics := metrics.NewPrometheus(prometheus.DefaultRegisterer)
+	sessSvc := session.NewSessionService(q, rdb, cfg, asynqClient, promMetrics)
+	// admin 觸發的背景清理（例如 purge 後的 SCAN）登記在此；在 HTTP server 之後、Redis / DB 之前關閉
+	background := lifecycle.NewGroup()
+	sessSvc.WithBackground(background)
+	lc.Register("background jobs", background.Shutdown)
 
 	// JWT manager（預設存活時間使用 cfg.SessionTTL）
 	jwtMgr := token.NewManager(cfg.JWTSecret, cfg.SessionTTL)
+	// scope bitmask 依 TOKEN_EXCHANGE_SCOPES 的順序對照，新增 scope 時只能加在最後；
+	// 對照表一律註冊，關閉 JWT_COMPACT_CLAIMS 後先前簽發的 compact token 仍能還原 scope
+	jwtMgr.WithScopeTable(cfg.TokenExchangeScopes)
+	if cfg.JWTCompactClaims {
+		jwtMgr.WithCompactClaims()
+	}
+	if cfg.JWTSubString {
+		jwtMgr.WithStringSubject()
+	}
+	if cfg.MaxTokenScopes > 0 {
+		// scope 群組依 TOKEN_EXCHANGE_SCOPES 展開，簽發與驗證的 instance 必須使用相同設定
+		jwtMgr.WithScopeLimit(cfg.MaxTokenScopes, cfg.TokenScopesOverflow == "group", cfg.TokenExchangeScopes)
+	}
+	if cfg.MaxTokenTTL > 0 {
+		jwtMgr.WithMaxTTL(cfg.MaxTokenTTL)
+	}
+	if cfg.JWTLeeway > 0 {
+		jwtMgr.WithLeeway(cfg.JWTLeeway)
+	}
+	if cfg.JWTEnforceAudience {
+		jwtMgr.WithAudienceEnforcement()
+	}
+
+	// Signup challenge（未啟用時為 nil）
+	signupChallenge := challenge.NewFromConfig(cfg)
+
+	// Readiness check：DB 與 Redis 都可連線才算 ready
+	readiness := health.NewChecker(cfg.ReadyCacheTTL, map[string]health.Check{
+		"db":    sqlDB.PingContext,
+		"redis": func(ctx context.Context) error { return rdb.Ping(ctx).Err() },
+	})
+
+	if cfg.AdminAPIKeyPrevious != "" {
+		log.Printf("ADMIN_API_KEY_PREVIOUS is set: the previous admin key is still accepted; remove it once all admin tooling uses ADMIN_API_KEY")
+	}
+
+	// Admin key 驗證失敗稽核（指標，以及同一 IP 失敗過多時的通知）
+	adminAudit := middleware.NewAdminAuthAudit(promMetrics, rdb, asynqClient, cfg.AdminAuthFailureThreshold, cfg.AdminAuthFailureWindow)
+
+	// SSE 串流登記處，關閉時在 HTTP server 之前通知串流結束
+	sseStreams := httpapi.NewSSERegistry()
 
 	// 建立 router
-	r := httpapi.NewRouter(q, jwtMgr, sessSvc, cfg.SessionTTL, cfg.AdminAPIKey)
+	r := httpapi.NewRouter(q, rdb, jwtMgr, sessSvc, cfg, signupChallenge, readiness, adminAudit, promMetrics, sseStreams)
+
+	// listener 模式：/metrics 由獨立的內部 listener 提供（admin 模式已由 router 掛在主 port）
+	if cfg.MetricsMode == metrics.ModeListener {
+		metricsCtx, stopMetrics := context.WithCancel(context.Background())
+		metricsDone := make(chan struct{})
+		go func() {
+			defer close(metricsDone)
+			log.Printf("serving metrics on %s", cfg.MetricsAddr)
+			if err := metrics.Serve(metricsCtx, cfg.MetricsAddr, prometheus.DefaultGatherer); err != nil {
+				log.Printf("metrics server stopped: %v", err)
+			}
+		}()
+		lc.Register("metrics server", func(ctx context.Context) error {
+			stopMetrics()
+			select {
+			case <-metricsDone:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}
 
-	// 啟動 HTTP server
+	// 啟動 HTTP server；關閉時先停止接收新請求，等待進行中的請求完成
 	gin.SetMode(gin.ReleaseMode)
-	log.Printf("starting api on %s", cfg.HTTPAddr)
-	if err := r.Run(cfg.HTTPAddr); err != nil {
-		log.Fatalf("server stopped: %v", err)
+	srv := &http.Server{Addr: cfg.HTTPAddr, Handler: r}
+	go func() {
+		log.Printf("starting api on %s", cfg.HTTPAddr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server stopped: %v", err)
+		}
+	}()
+	lc.RegisterWithTimeout("http server", 30*time.Second, srv.Shutdown)
+	// 最後註冊、最先執行：SSE 串流不會自己結束，先送出 event: shutdown 讓 client 重連，srv.Shutdown 才不必等到逾時
+	lc.RegisterWithTimeout("sse streams", cfg.SSEShutdownGrace, sseStreams.Shutdown)
+
+	if err := lc.WaitForSignal(os.Interrupt, syscall.SIGTERM); err != nil {
+		log.Printf("shutdown finished with errors: %v", err)
 	}
 }
 
@@ -3,25 +3,33 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"syscall"
+	"time"
 
 	"github.com/hibiken/asynq"
-	"github.com/redis/go-redis/v9"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"sessionservice/internal/config"
 	"sessionservice/internal/db"
 	"sessionservice/internal/infra"
+	"sessionservice/internal/metrics"
+	"sessionservice/internal/worker"
 
 	_ "modernc.org/sqlite"
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	infra.SetErrorLogSampling(cfg.LogSampleInterval)
 
 	// SQLite
 	if err := os.MkdirAll(filepath.Dir(cfg.DBPath), 0o755); err != nil {
@@ -39,127 +47,129 @@ func main() {
 
 	q := db.New(sqlDB)
 
-	// Redis client（給 worker handler 使用）
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     cfg.RedisAddr,
-		Password: cfg.RedisPassword,
-		DB:       0,
-	})
+	// Redis client（給 worker handler 存取 session 資料使用）
+	rdb := infra.NewRedisClient(cfg)
 	defer rdb.Close()
+	if cfg.RedisKeepAliveInterval > 0 {
+		keepAlive := infra.NewRedisKeepAlive(rdb, cfg.RedisKeepAliveInterval)
+		keepAlive.Start()
+		defer keepAlive.Stop(context.Background())
+	}
 
-	// Asynq server
-	srv := asynq.NewServer(
-		asynq.RedisClientOpt{
-			Addr:     cfg.RedisAddr,
-			Password: cfg.RedisPassword,
-			DB:       0,
-		},
-		asynq.Config{
-			Concurrency: cfg.AsynqConcurrency,
-		},
-	)
+	// Asynq server（佇列可能位於另一台 Redis）
+	srv := asynq.NewServer(infra.AsynqRedisOpt(cfg), infra.AsynqServerConfig(cfg))
 
-	mux := asynq.NewServeMux()
+	// 關機時記錄佇列深度，方便確認 drain 前後還有多少任務
+	inspector := asynq.NewInspector(infra.AsynqRedisOpt(cfg))
+	defer inspector.Close()
 
-	// session:expire handler
-	mux.HandleFunc(infra.TaskTypeSessionExpire, func(ctx context.Context, t *asynq.Task) error {
-		var p infra.SessionExpirePayload
-		if err := json.Unmarshal(t.Payload(), &p); err != nil {
-			log.Printf("session:expire: invalid payload: %v", err)
-			return err
-		}
+	mux := asynq.NewServeMux()
 
-		sessKey := infra.SessKey(p.SessionID)
-		userSessKey := infra.UserSessKey(p.UserID)
+	// 排入任務用的 client：ban 旗標重建，以及 session:expire 遇到已延長的 session 時改排到新的到期時間
+	asynqClient := infra.NewAsynqClient(cfg)
+	defer asynqClient.Close()
+
+	// 註冊 session:expire 與 login:audit handler
+	handlers := worker.NewHandlers(sqlDB, q, rdb).WithAsynqClient(asynqClient)
+
+	// 啟用 login_events 批次寫入時，背景定期 flush，關機時寫完剩餘事件
+	batchCtx, stopBatcher := context.WithCancel(context.Background())
+	batcherDone := make(chan struct{})
+	if cfg.AuditBatchSize > 0 {
+		batcher := worker.NewAuditBatcher(sqlDB, q, cfg.AuditBatchSize, cfg.AuditBatchInterval)
+		handlers.WithAuditBatcher(batcher)
+		go func() {
+			batcher.Run(batchCtx)
+			close(batcherDone)
+		}()
+		log.Printf("login:audit batching enabled: size=%d interval=%s", cfg.AuditBatchSize, cfg.AuditBatchInterval)
+	} else {
+		close(batcherDone)
+	}
 
-		// 檢查 Redis 是否仍有該 session
-		data, err := rdb.HGetAll(ctx, sessKey).Result()
-		if err != nil && err != redis.Nil {
-			log.Printf("session:expire: redis HGetAll error: %v", err)
-			return err
-		}
-		if len(data) == 0 {
-			// 已不存在，可能已手動 logout 或被踢，視為完成
-			return nil
+	// login:audit 輸出目的地；sqlite sink 需在設定 batcher 之後建立才會使用批次寫入
+	if slices.Contains(cfg.AuditSinks, worker.AuditSinkFile) {
+		if err := os.MkdirAll(filepath.Dir(cfg.AuditFilePath), 0o755); err != nil {
+			log.Fatalf("failed to create audit log dir: %v", err)
 		}
+	}
+	auditSinks, err := worker.OpenAuditSinks(cfg.AuditSinks, handlers.SQLiteAuditSink(), worker.AuditSinkOptions{
+		FilePath:  cfg.AuditFilePath,
+		SyslogTag: cfg.AuditSyslogTag,
+	})
+	if err != nil {
+		log.Fatalf("failed to open audit sinks: %v", err)
+	}
+	defer worker.CloseAuditSinks(auditSinks)
+	handlers.WithAuditSinks(auditSinks)
+	log.Printf("login:audit sinks: %v", cfg.AuditSinks)
 
-		pipe := rdb.TxPipeline()
-		pipe.Del(ctx, sessKey)
-		pipe.ZRem(ctx, userSessKey, p.SessionID)
-		if _, err := pipe.Exec(ctx); err != nil {
-			log.Printf("session:expire: redis cleanup error: %v", err)
-			return err
-		}
+	if cfg.ImpossibleTravelMaxKmh > 0 {
+		handlers.WithImpossibleTravel(cfg.ImpossibleTravelMaxKmh, cfg.ImpossibleTravelKick)
+		log.Printf("impossible travel detection enabled: max_kmh=%d kick=%t", cfg.ImpossibleTravelMaxKmh, cfg.ImpossibleTravelKick)
+	}
+	handlers.Register(mux)
+
+	// worker 沒有對外的 HTTP port，/metrics 一律使用獨立的內部 listener
+	metricsCtx, stopMetrics := context.WithCancel(context.Background())
+	defer stopMetrics()
+	if cfg.MetricsMode != metrics.ModeOff && cfg.WorkerMetricsAddr != "" {
+		go func() {
+			log.Printf("serving metrics on %s", cfg.WorkerMetricsAddr)
+			if err := metrics.Serve(metricsCtx, cfg.WorkerMetricsAddr, prometheus.DefaultGatherer); err != nil {
+				log.Printf("metrics server stopped: %v", err)
+			}
+		}()
+	}
 
-		// 更新 DB sessions.revoked_at / revoked_by
-		if err := q.RevokeSession(ctx, db.RevokeSessionParams{
-			ID:        p.SessionID,
-			RevokedBy: sql.NullString{String: "system:expire", Valid: true},
-		}); err != nil {
-			log.Printf("session:expire: db revoke error: %v", err)
-			return err
-		}
+	// 啟動 worker；訊號由下方自行處理，才能在 drain 前後記錄佇列深度
+	if err := srv.Start(mux); err != nil {
+		log.Fatalf("asynq server stopped: %v", err)
+	}
 
-		return nil
-	})
+	log.Printf("asynq worker started with concurrency=%d shutdown_timeout=%s", cfg.AsynqConcurrency, cfg.WorkerShutdownTimeout)
 
-	// login:audit handler
-	mux.HandleFunc(infra.TaskTypeLoginAudit, func(ctx context.Context, t *asynq.Task) error {
-		var p infra.LoginAuditPayload
-		if err := json.Unmarshal(t.Payload(), &p); err != nil {
-			log.Printf("login:audit: invalid payload: %v", err)
-			return err
+	// 啟動時先重建一次 Redis 的 ban 旗標（Redis 被清空後重啟 worker 即可補回），之後依 BAN_RESYNC_INTERVAL_SECONDS 定期執行
+	if err := infra.EnqueueBanResync(context.Background(), asynqClient, time.Minute); err != nil {
+		log.Printf("failed to enqueue %s: %v", infra.TaskTypeBanResync, err)
+	}
+	// 計數器清理依 COUNTERS_SWEEP_INTERVAL_SECONDS 定期執行，與 ban 旗標重建共用同一個 scheduler
+	// SESSION_RECORD_MODE=async 時另依 SESSION_RECONCILE_INTERVAL_SECONDS 補寫遺失的 sessions 紀錄
+	reconcileSessions := cfg.SessionRecordMode == "async" && cfg.SessionReconcileInterval > 0
+	if cfg.BanResyncInterval > 0 || cfg.CountersSweepInterval > 0 || reconcileSessions {
+		scheduler := asynq.NewScheduler(infra.AsynqRedisOpt(cfg), nil)
+		if cfg.BanResyncInterval > 0 {
+			if _, err := scheduler.Register(fmt.Sprintf("@every %s", cfg.BanResyncInterval), infra.NewBanResyncTask(cfg.BanResyncInterval)); err != nil {
+				log.Fatalf("failed to schedule %s: %v", infra.TaskTypeBanResync, err)
+			}
 		}
-
-		var userID sql.NullInt64
-		if p.UserID != nil {
-			userID = sql.NullInt64{Int64: *p.UserID, Valid: true}
+		if cfg.CountersSweepInterval > 0 {
+			if _, err := scheduler.Register(fmt.Sprintf("@every %s", cfg.CountersSweepInterval), infra.NewCountersSweepTask(cfg.CountersSweepInterval)); err != nil {
+				log.Fatalf("failed to schedule %s: %v", infra.TaskTypeCountersSweep, err)
+			}
 		}
-
-		// 直接用 Exec 寫入 login_events，避免再擴充 sqlc schema 太多欄位
-		_, err := sqlDB.ExecContext(ctx, `
-INSERT INTO login_events (
-    user_id,
-    username,
-    success,
-    reason,
-    ip,
-    user_agent,
-    created_at
-) VALUES (
-    ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP
-)
-`, nullableInt64(userID), p.Username, p.Success, p.Reason, p.IP, p.UserAgent)
-		if err != nil {
-			log.Printf("login:audit: insert error: %v", err)
-			return err
+		if reconcileSessions {
+			if _, err := scheduler.Register(fmt.Sprintf("@every %s", cfg.SessionReconcileInterval), infra.NewSessionReconcileTask(cfg.SessionReconcileInterval)); err != nil {
+				log.Fatalf("failed to schedule %s: %v", infra.TaskTypeSessionReconcile, err)
+			}
 		}
-		return nil
-	})
-
-	// 啟動 worker
-	go func() {
-		if err := srv.Run(mux); err != nil {
-			log.Fatalf("asynq server stopped: %v", err)
+		if err := scheduler.Start(); err != nil {
+			log.Fatalf("failed to start scheduler: %v", err)
 		}
-	}()
-
-	log.Printf("asynq worker started with concurrency=%d", cfg.AsynqConcurrency)
+		defer scheduler.Shutdown()
+	}
 
 	// 等待中斷訊號
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	<-sigCh
 
-	log.Println("worker shutting down...")
-	srv.Shutdown()
-}
+	log.Printf("worker shutting down, draining in-flight tasks (timeout %s)...", cfg.WorkerShutdownTimeout)
+	infra.LogQueueDepth(inspector, "draining")
+	srv.Shutdown() // 停止拉新任務，等待進行中的任務完成或逾時
+	infra.LogQueueDepth(inspector, "drained")
 
-func nullableInt64(v sql.NullInt64) interface{} {
-	if v.Valid {
-		return v.Int64
-	}
-	return nil
+	// 所有任務處理完後再 flush 剩餘的 login_events
+	stopBatcher()
+	<-batcherDone
 }
-
-
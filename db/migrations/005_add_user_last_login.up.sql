@@ -0,0 +1,3 @@
+ALTER TABLE users
+ADD COLUMN last_login_at DATETIME;
+
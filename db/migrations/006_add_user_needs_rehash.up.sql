@@ -0,0 +1,3 @@
+ALTER TABLE users
+ADD COLUMN needs_rehash BOOLEAN NOT NULL DEFAULT 0;
+
@@ -0,0 +1,2 @@
+ALTER TABLE users
+ADD COLUMN must_reset_password BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS username_changes (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id      INTEGER NOT NULL,
+    old_username TEXT NOT NULL,
+    new_username TEXT NOT NULL,
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
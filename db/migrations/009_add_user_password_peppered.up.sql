@@ -0,0 +1,2 @@
+ALTER TABLE users
+ADD COLUMN password_peppered BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE login_events
+ADD COLUMN country TEXT;
@@ -0,0 +1,45 @@
+-- 將舊的自由格式 revoked_by 盡量對應到固定的原因，無法對應的改為 unknown
+UPDATE sessions
+SET revoked_by = CASE
+    WHEN revoked_by IN ('logout', 'user:logout') THEN 'user'
+    WHEN revoked_by IN ('admin', 'kick', 'admin:kick_all') THEN 'admin:kick'
+    WHEN revoked_by IN ('ban', 'admin:banned') THEN 'admin:ban'
+    WHEN revoked_by IN ('expire', 'expired', 'system:expired') THEN 'system:expire'
+    WHEN revoked_by IN ('limit', 'system:max_sessions') THEN 'system:limit'
+    WHEN revoked_by IN ('purge', 'system:purge') THEN 'admin:purge'
+    ELSE 'unknown'
+END
+WHERE revoked_by IS NOT NULL
+  AND revoked_by NOT IN (
+    'user', 'user:password_reset',
+    'system:expire', 'system:limit', 'system:redis_error', 'system:username_change', 'system:impossible_travel',
+    'admin:kick', 'admin:kick_device', 'admin:ban', 'admin:force_reset', 'admin:delete', 'admin:purge',
+    'unknown'
+  );
+
+-- SQLite 無法對既有欄位加上 CHECK，改以 trigger 拒絕清單以外的 revoked_by（對應 infra.RevokeReasons）
+CREATE TRIGGER sessions_revoked_by_check_insert
+BEFORE INSERT ON sessions
+WHEN NEW.revoked_by IS NOT NULL
+  AND NEW.revoked_by NOT IN (
+    'user', 'user:password_reset',
+    'system:expire', 'system:limit', 'system:redis_error', 'system:username_change', 'system:impossible_travel',
+    'admin:kick', 'admin:kick_device', 'admin:ban', 'admin:force_reset', 'admin:delete', 'admin:purge',
+    'unknown'
+  )
+BEGIN
+    SELECT RAISE(ABORT, 'invalid revoked_by');
+END;
+
+CREATE TRIGGER sessions_revoked_by_check_update
+BEFORE UPDATE OF revoked_by ON sessions
+WHEN NEW.revoked_by IS NOT NULL
+  AND NEW.revoked_by NOT IN (
+    'user', 'user:password_reset',
+    'system:expire', 'system:limit', 'system:redis_error', 'system:username_change', 'system:impossible_travel',
+    'admin:kick', 'admin:kick_device', 'admin:ban', 'admin:force_reset', 'admin:delete', 'admin:purge',
+    'unknown'
+  )
+BEGIN
+    SELECT RAISE(ABORT, 'invalid revoked_by');
+END;
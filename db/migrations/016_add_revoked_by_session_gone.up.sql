@@ -0,0 +1,31 @@
+-- 新增 system:session_gone：非同步寫入 sessions 紀錄（SESSION_RECORD_MODE=async）時，session 在紀錄寫入前就已從 Redis 消失
+DROP TRIGGER IF EXISTS sessions_revoked_by_check_insert;
+DROP TRIGGER IF EXISTS sessions_revoked_by_check_update;
+
+CREATE TRIGGER sessions_revoked_by_check_insert
+BEFORE INSERT ON sessions
+WHEN NEW.revoked_by IS NOT NULL
+  AND NEW.revoked_by NOT IN (
+    'user', 'user:password_reset',
+    'system:expire', 'system:limit', 'system:redis_error', 'system:username_change', 'system:impossible_travel',
+    'system:session_gone',
+    'admin:kick', 'admin:kick_device', 'admin:ban', 'admin:force_reset', 'admin:delete', 'admin:purge',
+    'unknown'
+  )
+BEGIN
+    SELECT RAISE(ABORT, 'invalid revoked_by');
+END;
+
+CREATE TRIGGER sessions_revoked_by_check_update
+BEFORE UPDATE OF revoked_by ON sessions
+WHEN NEW.revoked_by IS NOT NULL
+  AND NEW.revoked_by NOT IN (
+    'user', 'user:password_reset',
+    'system:expire', 'system:limit', 'system:redis_error', 'system:username_change', 'system:impossible_travel',
+    'system:session_gone',
+    'admin:kick', 'admin:kick_device', 'admin:ban', 'admin:force_reset', 'admin:delete', 'admin:purge',
+    'unknown'
+  )
+BEGIN
+    SELECT RAISE(ABORT, 'invalid revoked_by');
+END;
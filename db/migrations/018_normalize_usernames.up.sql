@@ -0,0 +1,6 @@
+-- 將既有 username 改為 NormalizeUsername 的形式（去除前後空白、轉小寫），讓大小寫混合的舊帳號仍能登入。
+-- 若有兩個帳號正規化後相同，UPDATE 會撞上 users.username 的 UNIQUE 而讓整個 migration 失敗，需先人工合併或改名再重跑。
+-- 注意：SQLite 的 lower() 只處理 ASCII，含非 ASCII 大寫字母的 username 需另行處理。
+UPDATE users
+SET username = lower(trim(username))
+WHERE username != lower(trim(username));
@@ -0,0 +1,5 @@
+-- login:audit 任務重試時以 event_id 去重，避免同一次登入嘗試寫入多筆 login_events；舊資料為 NULL，不受唯一索引限制
+ALTER TABLE login_events
+ADD COLUMN event_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_login_events_event_id ON login_events (event_id);
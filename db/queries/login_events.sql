@@ -15,4 +15,41 @@ INSERT INTO login_events (
     ?6
 );
 
+-- name: ScrubLoginEventsPII :exec
+UPDATE login_events
+SET ip = NULL,
+    user_agent = NULL,
+    country = NULL
+WHERE user_id = ?1;
 
+-- name: ListLoginEventsByUser :many
+SELECT
+    id,
+    user_id,
+    username,
+    success,
+    reason,
+    ip,
+    user_agent,
+    created_at,
+    country
+FROM login_events
+WHERE user_id = ?1
+ORDER BY created_at DESC, id DESC
+LIMIT ?2;
+
+-- name: CountFailedLoginsSince :one
+SELECT COUNT(*)
+FROM login_events
+WHERE username = ?1
+  AND success = 0
+  AND created_at >= datetime(?2);
+
+-- name: ListFailedLoginIPsSince :many
+SELECT DISTINCT ip
+FROM login_events
+WHERE username = ?1
+  AND success = 0
+  AND created_at >= datetime(?2)
+  AND ip IS NOT NULL
+ORDER BY ip;
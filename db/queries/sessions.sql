@@ -15,6 +15,49 @@ INSERT INTO sessions (
     NULL
 );
 
+-- name: RecordSession :exec
+INSERT OR IGNORE INTO sessions (
+    id,
+    user_id,
+    created_at,
+    expires_at,
+    revoked_at,
+    revoked_by
+) VALUES (
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    NULL,
+    NULL
+);
+
+-- name: GetActiveSession :one
+SELECT
+    id,
+    user_id,
+    created_at,
+    expires_at,
+    revoked_at,
+    revoked_by
+FROM sessions
+WHERE id = ?1
+  AND user_id = ?2
+  AND revoked_at IS NULL
+LIMIT 1;
+
+-- name: GetSession :one
+SELECT
+    id,
+    user_id,
+    created_at,
+    expires_at,
+    revoked_at,
+    revoked_by
+FROM sessions
+WHERE id = ?1
+LIMIT 1;
+
 -- name: RevokeSession :exec
 UPDATE sessions
 SET revoked_at = CURRENT_TIMESTAMP,
@@ -23,3 +66,32 @@ WHERE id = ?1
   AND revoked_at IS NULL;
 
 
+
+-- name: UpdateSessionExpiry :exec
+UPDATE sessions
+SET expires_at = ?2
+WHERE id = ?1
+  AND revoked_at IS NULL;
+
+-- name: ListSessionsByUser :many
+SELECT
+    id,
+    user_id,
+    created_at,
+    expires_at,
+    revoked_at,
+    revoked_by
+FROM sessions
+WHERE user_id = ?1
+ORDER BY created_at DESC;
+
+-- name: CountRevocationsByReason :many
+SELECT
+    revoked_by,
+    COUNT(*) AS count
+FROM sessions
+WHERE revoked_by IS NOT NULL
+  AND revoked_at >= datetime(?1)
+  AND revoked_at <= datetime(?2)
+GROUP BY revoked_by
+ORDER BY revoked_by;
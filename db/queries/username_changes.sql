@@ -0,0 +1,21 @@
+-- name: CreateUsernameChange :exec
+INSERT INTO username_changes (
+    user_id,
+    old_username,
+    new_username
+) VALUES (
+    ?1,
+    ?2,
+    ?3
+);
+
+-- name: ListUsernameChangesByUser :many
+SELECT
+    id,
+    user_id,
+    old_username,
+    new_username,
+    created_at
+FROM username_changes
+WHERE user_id = ?1
+ORDER BY created_at ASC, id ASC;
@@ -1,17 +1,32 @@
 -- name: CreateUser :one
 INSERT INTO users (
     username,
-    password_hash
+    password_hash,
+    password_peppered,
+    password_changed_at,
+    email
 ) VALUES (
     ?1,
-    ?2
+    ?2,
+    ?3,
+    CURRENT_TIMESTAMP,
+    ?4
 )
 RETURNING
     id,
     username,
     password_hash,
     created_at,
-    is_banned;
+    is_banned,
+    last_login_at,
+    needs_rehash,
+    must_reset_password,
+    password_peppered,
+    deleted_at,
+    password_changed_at,
+    last_login_ip,
+    roles,
+    email;
 
 -- name: GetUserByUsername :one
 SELECT
@@ -19,9 +34,40 @@ SELECT
     username,
     password_hash,
     created_at,
-    is_banned
+    is_banned,
+    last_login_at,
+    needs_rehash,
+    must_reset_password,
+    password_peppered,
+    deleted_at,
+    password_changed_at,
+    last_login_ip,
+    roles,
+    email
 FROM users
 WHERE username = ?1
+  AND deleted_at IS NULL
+LIMIT 1;
+
+-- name: GetUserByEmail :one
+SELECT
+    id,
+    username,
+    password_hash,
+    created_at,
+    is_banned,
+    last_login_at,
+    needs_rehash,
+    must_reset_password,
+    password_peppered,
+    deleted_at,
+    password_changed_at,
+    last_login_ip,
+    roles,
+    email
+FROM users
+WHERE email = ?1
+  AND deleted_at IS NULL
 LIMIT 1;
 
 -- name: GetUserByID :one
@@ -30,11 +76,53 @@ SELECT
     username,
     password_hash,
     created_at,
-    is_banned
+    is_banned,
+    last_login_at,
+    needs_rehash,
+    must_reset_password,
+    password_peppered,
+    deleted_at,
+    password_changed_at,
+    last_login_ip,
+    roles,
+    email
 FROM users
 WHERE id = ?1
+  AND deleted_at IS NULL
 LIMIT 1;
 
+-- name: GetDeletedUserByID :one
+SELECT
+    id,
+    username,
+    password_hash,
+    created_at,
+    is_banned,
+    last_login_at,
+    needs_rehash,
+    must_reset_password,
+    password_peppered,
+    deleted_at,
+    password_changed_at,
+    last_login_ip,
+    roles,
+    email
+FROM users
+WHERE id = ?1
+  AND deleted_at IS NOT NULL
+LIMIT 1;
+
+-- name: CountUsersByUsername :one
+SELECT COUNT(*)
+FROM users
+WHERE username = ?1;
+
+-- name: ListBannedUsers :many
+SELECT id
+FROM users
+WHERE is_banned = 1
+ORDER BY id;
+
 -- name: BanUser :exec
 UPDATE users
 SET is_banned = 1
@@ -45,6 +133,58 @@ UPDATE users
 SET is_banned = 0
 WHERE id = ?1;
 
+-- name: UpdateLastLogin :exec
+UPDATE users
+SET last_login_at = ?2,
+    last_login_ip = ?3
+WHERE id = ?1;
+
+-- name: UpdatePasswordHash :exec
+UPDATE users
+SET password_hash = ?2,
+    password_peppered = ?3,
+    needs_rehash = 0
+WHERE id = ?1;
+
+-- name: MarkNeedsRehash :exec
+UPDATE users
+SET needs_rehash = 1
+WHERE id = ?1;
+
+-- name: SetMustResetPassword :exec
+UPDATE users
+SET must_reset_password = 1
+WHERE id = ?1;
+
+-- name: ResetPassword :exec
+UPDATE users
+SET password_hash = ?2,
+    password_peppered = ?3,
+    password_changed_at = CURRENT_TIMESTAMP,
+    needs_rehash = 0,
+    must_reset_password = 0
+WHERE id = ?1;
 
+-- name: UpdateUsername :exec
+UPDATE users
+SET username = ?2
+WHERE id = ?1;
 
+-- name: SoftDeleteUser :execrows
+UPDATE users
+SET deleted_at = ?2,
+    last_login_ip = NULL,
+    email = NULL
+WHERE id = ?1
+  AND deleted_at IS NULL;
 
+-- name: RestoreUser :exec
+UPDATE users
+SET deleted_at = NULL
+WHERE id = ?1;
+
+-- name: SetUserRoles :execrows
+UPDATE users
+SET roles = ?2
+WHERE id = ?1
+  AND deleted_at IS NULL;
@@ -0,0 +1,147 @@
+package challenge
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"sessionservice/internal/config"
+	"sessionservice/internal/infra"
+)
+
+// 支援的 signup challenge 模式。
+const (
+	ModeCaptcha = "captcha"
+	ModePoW     = "pow"
+)
+
+var (
+	// ErrChallengeRequired 代表請求沒有附上 challenge 解答。
+	ErrChallengeRequired = errors.New("challenge required")
+	// ErrChallengeFailed 代表 challenge 解答驗證失敗。
+	ErrChallengeFailed = errors.New("challenge failed")
+	// ErrChallengeUnavailable 代表無法完成驗證（例如 CAPTCHA 供應商無法連線或回傳錯誤），與解答是否正確無關。
+	ErrChallengeUnavailable = errors.New("challenge verification unavailable")
+)
+
+// Solution 是 client 在 signup 時附上的 challenge 解答。
+type Solution struct {
+	Username     string // 要註冊的使用者名稱，PoW 會把它綁進雜湊避免重複使用同一個 nonce
+	CaptchaToken string // 前端 CAPTCHA widget 回傳的 token
+	PoWNonce     string // 使 sha256(username:nonce) 達到難度要求的 nonce
+	RemoteIP     string // 請求來源 IP，轉交給 CAPTCHA 供應商做風險判斷
+}
+
+// Verifier 驗證 signup challenge，驗證失敗時回傳 ErrChallengeRequired 或 ErrChallengeFailed；
+// 無法完成驗證時回傳包住 ErrChallengeUnavailable 的錯誤。
+type Verifier interface {
+	Verify(ctx context.Context, sol Solution) error
+}
+
+// NewFromConfig 依設定建立 Verifier；未啟用時回傳 nil，呼叫端應直接略過驗證。
+func NewFromConfig(cfg *config.Config) Verifier {
+	switch strings.ToLower(cfg.SignupChallenge) {
+	case ModeCaptcha:
+		return NewCaptchaVerifier(cfg.CaptchaVerifyURL, cfg.CaptchaSecret, infra.HTTPClient(cfg))
+	case ModePoW:
+		return NewPoWVerifier(cfg.PoWDifficulty)
+	default:
+		return nil
+	}
+}
+
+// CaptchaVerifier 透過 hCaptcha / Turnstile 的 siteverify API 在伺服器端驗證 token。
+type CaptchaVerifier struct {
+	verifyURL string
+	secret    string
+	client    *http.Client
+}
+
+func NewCaptchaVerifier(verifyURL, secret string, client *http.Client) *CaptchaVerifier {
+	return &CaptchaVerifier{
+		verifyURL: verifyURL,
+		secret:    secret,
+		client:    client,
+	}
+}
+
+// Verify 將 token 送到 siteverify，兩家供應商都接受 form 參數並回傳 {"success": bool}。
+// 只有 success=false 才回傳 ErrChallengeFailed；連線失敗、非 2xx 或無法解析的回應都包成 ErrChallengeUnavailable，
+// 避免供應商故障時把合法使用者當成 CAPTCHA 答錯。
+func (v *CaptchaVerifier) Verify(ctx context.Context, sol Solution) error {
+	if sol.CaptchaToken == "" {
+		return ErrChallengeRequired
+	}
+
+	form := url.Values{}
+	form.Set("secret", v.secret)
+	form.Set("response", sol.CaptchaToken)
+	if sol.RemoteIP != "" {
+		form.Set("remoteip", sol.RemoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrChallengeUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: siteverify returned status %d", ErrChallengeUnavailable, resp.StatusCode)
+	}
+
+	var body struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("%w: decode siteverify response: %w", ErrChallengeUnavailable, err)
+	}
+	if !body.Success {
+		return ErrChallengeFailed
+	}
+	return nil
+}
+
+// PoWVerifier 要求 sha256(username + ":" + nonce) 至少有 difficulty 個前導零位元。
+type PoWVerifier struct {
+	difficulty int
+}
+
+func NewPoWVerifier(difficulty int) *PoWVerifier {
+	return &PoWVerifier{difficulty: difficulty}
+}
+
+func (v *PoWVerifier) Verify(_ context.Context, sol Solution) error {
+	if sol.PoWNonce == "" {
+		return ErrChallengeRequired
+	}
+	sum := sha256.Sum256([]byte(sol.Username + ":" + sol.PoWNonce))
+	if leadingZeroBits(sum[:]) < v.difficulty {
+		return ErrChallengeFailed
+	}
+	return nil
+}
+
+// leadingZeroBits 計算雜湊值開頭連續為 0 的位元數。
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, x := range b {
+		if x == 0 {
+			n += 8
+			continue
+		}
+		return n + bits.LeadingZeros8(x)
+	}
+	return n
+}
@@ -0,0 +1,121 @@
+package challenge
+
+import (
+	"context"           // 匯入 context，傳給 Verifier.Verify
+	"crypto/sha256"     // 匯入 sha256，在測試中暴力找出符合難度的 nonce
+	"net/http"          // 匯入 net/http，撰寫假的 siteverify handler
+	"net/http/httptest" // 匯入 httptest，啟動模擬 CAPTCHA 供應商的測試伺服器
+	"strconv"           // 匯入 strconv，將計數器轉成 nonce 字串
+	"testing"           // 匯入 testing 套件，提供單元測試框架
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，用於撰寫斷言
+
+	"sessionservice/internal/config" // 匯入 config 套件，測試 NewFromConfig
+)
+
+// solvePoW 以暴力搜尋找出第一個符合難度的 nonce，僅用於低難度的測試情境。
+func solvePoW(username string, difficulty int) string {
+	for i := 0; ; i++ {
+		nonce := strconv.Itoa(i)                             // 以遞增整數作為候選 nonce
+		sum := sha256.Sum256([]byte(username + ":" + nonce)) // 與正式驗證相同的雜湊組合
+		if leadingZeroBits(sum[:]) >= difficulty {           // 前導零位元數達標即回傳
+			return nonce
+		}
+	}
+}
+
+// newFakeSiteverify 建立模擬 siteverify 的伺服器：只有 token 為 "good-token" 且 secret 正確時回傳 success。
+func newFakeSiteverify(t *testing.T) *httptest.Server {
+	t.Helper() // 標記為測試輔助函式
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())                                                             // 解析 form 參數
+		ok := r.PostForm.Get("secret") == "test-secret" && r.PostForm.Get("response") == "good-token" // 判斷 secret 與 token 是否正確
+		w.Header().Set("Content-Type", "application/json")                                            // 回傳 JSON
+		_, _ = w.Write([]byte(`{"success":` + strconv.FormatBool(ok) + `}`))                          // 依結果回傳 success 欄位
+	}))
+}
+
+// TestCaptchaVerifierSuccess 測試 siteverify 回傳 success=true 時驗證通過。
+func TestCaptchaVerifierSuccess(t *testing.T) {
+	srv := newFakeSiteverify(t) // 啟動模擬的 CAPTCHA 供應商
+	defer srv.Close()           // 測試結束時關閉
+
+	v := NewCaptchaVerifier(srv.URL, "test-secret", srv.Client())               // 指向模擬伺服器
+	err := v.Verify(context.Background(), Solution{CaptchaToken: "good-token"}) // 送出正確 token
+	require.NoError(t, err)                                                     // 應驗證通過
+}
+
+// TestCaptchaVerifierFailure 測試 siteverify 回傳 success=false 或缺少 token 時應回傳對應錯誤。
+func TestCaptchaVerifierFailure(t *testing.T) {
+	srv := newFakeSiteverify(t) // 啟動模擬的 CAPTCHA 供應商
+	defer srv.Close()           // 測試結束時關閉
+
+	v := NewCaptchaVerifier(srv.URL, "test-secret", srv.Client()) // 指向模擬伺服器
+
+	err := v.Verify(context.Background(), Solution{CaptchaToken: "bad-token"}) // 送出錯誤 token
+	require.ErrorIs(t, err, ErrChallengeFailed)                                // 應回傳驗證失敗
+
+	err = v.Verify(context.Background(), Solution{}) // 完全沒帶 token
+	require.ErrorIs(t, err, ErrChallengeRequired)    // 應回傳缺少 challenge
+}
+
+// TestPoWVerifierSuccess 測試符合難度的 nonce 可以通過驗證。
+func TestPoWVerifierSuccess(t *testing.T) {
+	v := NewPoWVerifier(8)        // 使用低難度，讓測試可快速找到解答
+	nonce := solvePoW("alice", 8) // 為 alice 找出符合難度的 nonce
+
+	err := v.Verify(context.Background(), Solution{Username: "alice", PoWNonce: nonce}) // 驗證解答
+	require.NoError(t, err)                                                             // 應驗證通過
+}
+
+// TestPoWVerifierFailure 測試 nonce 不符難度、綁定到其他 username 或缺少 nonce 時皆驗證失敗。
+func TestPoWVerifierFailure(t *testing.T) {
+	v := NewPoWVerifier(8) // 使用低難度
+
+	nonce := solvePoW("alice", 8)                    // 找出 alice 專用的 nonce
+	sum := sha256.Sum256([]byte("mallory:" + nonce)) // 計算換成其他 username 後的雜湊
+	if leadingZeroBits(sum[:]) < 8 {                 // 極少數情況下可能剛好也符合難度
+		err := v.Verify(context.Background(), Solution{Username: "mallory", PoWNonce: nonce}) // 拿別人的 nonce 來用
+		require.ErrorIs(t, err, ErrChallengeFailed)                                           // 應驗證失敗
+	}
+
+	impossible := NewPoWVerifier(256)                                                            // 難度 256 位元，不可能被滿足
+	err := impossible.Verify(context.Background(), Solution{Username: "alice", PoWNonce: nonce}) // 用原本的 nonce 驗證
+	require.ErrorIs(t, err, ErrChallengeFailed)                                                  // 應驗證失敗
+
+	err = v.Verify(context.Background(), Solution{Username: "alice"}) // 沒有帶 nonce
+	require.ErrorIs(t, err, ErrChallengeRequired)                     // 應回傳缺少 challenge
+}
+
+// TestNewFromConfigDisabledByDefault 測試未設定 SignupChallenge 時不建立 Verifier。
+func TestNewFromConfigDisabledByDefault(t *testing.T) {
+	require.Nil(t, NewFromConfig(&config.Config{}))                                          // 預設關閉
+	require.IsType(t, &PoWVerifier{}, NewFromConfig(&config.Config{SignupChallenge: "pow"})) // 指定 pow 時回傳 PoWVerifier
+}
+
+// TestCaptchaVerifierUnavailable 測試供應商回傳 5xx、無法解析的內容或無法連線時回傳 ErrChallengeUnavailable，而不是 ErrChallengeFailed。
+func TestCaptchaVerifierUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/garbage" { // 回傳 200 但內容不是 JSON
+			_, _ = w.Write([]byte("<html>maintenance</html>"))
+			return
+		}
+		http.Error(w, `{"success":false}`, http.StatusInternalServerError) // 供應商故障
+	}))
+	defer srv.Close() // 測試結束時關閉
+
+	v := NewCaptchaVerifier(srv.URL, "test-secret", srv.Client())               // 指向故障的供應商
+	err := v.Verify(context.Background(), Solution{CaptchaToken: "good-token"}) // 送出 token
+	require.ErrorIs(t, err, ErrChallengeUnavailable)                            // 應回傳無法驗證
+	require.NotErrorIs(t, err, ErrChallengeFailed)                              // 不可當成答錯
+
+	v = NewCaptchaVerifier(srv.URL+"/garbage", "test-secret", srv.Client())    // 回傳無法解析的內容
+	err = v.Verify(context.Background(), Solution{CaptchaToken: "good-token"}) // 送出 token
+	require.ErrorIs(t, err, ErrChallengeUnavailable)                           // 應回傳無法驗證
+
+	url := srv.URL                                                             // 記下位址
+	srv.Close()                                                                // 關閉伺服器模擬無法連線
+	v = NewCaptchaVerifier(url, "test-secret", http.DefaultClient)             // 指向已關閉的供應商
+	err = v.Verify(context.Background(), Solution{CaptchaToken: "good-token"}) // 送出 token
+	require.ErrorIs(t, err, ErrChallengeUnavailable)                           // 應回傳無法驗證
+}
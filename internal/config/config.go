@@ -1,35 +1,239 @@
 package config // 宣告本檔案屬於 config 套件，提供整個專案共用的設定結構與載入邏輯
 
 import (
-	"time" // 引入 time 套件，用來處理時間與 Duration 型別
+	"context"       // 引入 context 套件，查詢 SecretProvider 時使用
+	"fmt"           // 引入 fmt 套件，用來組出讀取設定檔失敗的錯誤訊息
+	"path/filepath" // 引入 path/filepath 套件，依副檔名判斷設定檔格式
+	"strconv"       // 引入 strconv 套件，用來解析設定中的數值
+	"strings"       // 引入 strings 套件，用來拆解逗號分隔的設定值
+	"time"          // 引入 time 套件，用來處理時間與 Duration 型別
 
 	"github.com/spf13/viper" // 引入 viper 套件，負責讀取環境變數與 .env 設定檔
 )
 
 // Config 收攏服務會用到的設定。 // 定義 Config 結構體，集中管理所有服務設定欄位
 type Config struct {
+	AppEnv   string // 執行環境："development"（預設）或 "production"；production 會拒絕開發用的預設密鑰
 	HTTPAddr string // 例如 ":8080"；HTTP 服務監聽位址
 	DBPath   string // SQLite 檔案路徑，例如 "./data/app.db"
 
-	JWTSecret string // HMAC secret，用於簽 JWT
+	RequestTimeout time.Duration // 單一 HTTP 請求的處理時限，超過回 503，0 代表不限制
+
+	RequireJSONContentType bool // 有 body 的請求必須帶 JSON Content-Type，否則回 415
+	AllowFormLogin         bool // 啟用 RequireJSONContentType 時，signup / login / 重設密碼仍接受 form-urlencoded
+
+	LogSampleInterval time.Duration // 相同的錯誤 log（例如 Redis 中斷）在此期間內只輸出第一次，之後附上略過的次數，0 代表每次都輸出
+
+	SSEShutdownGrace time.Duration // 關閉服務時等待 SSE 串流送出 event: shutdown 並結束的最長時間，之後才停止 HTTP server
+
+	JWTSecret        string // HMAC secret，用於簽 JWT
+	JWTMaxTokenLen   int    // Authorization header 內 JWT 允許的最大長度（bytes），超過直接回 401
+	JWTCompactClaims bool   // 簽發 token 時改用單字母 claim key、scope bitmask 並省略 typ header，縮小 token 以放進 cookie
+	JWTSubString     bool   // 簽發 token 時 sub 以字串輸出（RFC 7519），解析時數字與字串都接受
+
+	JWTMinSecretBytes int // JWTSecret 最少要有幾個 bytes，太短時啟動失敗
+
+	JWTLeeway time.Duration // 多台機器時鐘誤差的容忍值：驗證 JWT exp / nbf / iat 與 session expires_at 時都放寬這麼多，0 代表不放寬
+
+	JWTEnforceAudience bool // 簽發的 session token 帶 aud（使用者 API），且各路由群組只接受自己 audience 的 token，沒有 aud 的舊 token 一律拒絕
 
 	// Redis
 	RedisAddr     string // Redis 連線位址，例如 "127.0.0.1:6379"
 	RedisPassword string // Redis 密碼，預設空字串代表無密碼
+	RedisDB       int    // Redis DB 編號
+
+	RedisKeepAliveInterval time.Duration // 背景每隔多久 PING 一次 Redis，避免閒置連線被 server 或 NAT 關閉，0 代表關閉
+
+	// 對外 HTTP 呼叫（CAPTCHA 驗證等）共用的 client 設定
+	OutboundTLSMinVersion   string        // 對外 HTTPS 連線允許的最低 TLS 版本（1.2 或 1.3）
+	OutboundHTTPTimeout     time.Duration // 單次對外請求的整體逾時
+	OutboundMaxConnsPerHost int           // 對同一主機的連線數上限
+
+	// Asynq 佇列使用的 Redis（未設定時沿用上面 session 用的 Redis）
+	AsynqRedisAddr     string // Asynq Redis 連線位址
+	AsynqRedisPassword string // Asynq Redis 密碼
+	AsynqRedisDB       int    // Asynq Redis DB 編號
 
 	// Session 設定
 	SessionTTL         time.Duration // Session 與 JWT 的存活時間
 	MaxSessionsPerUser int           // 單一使用者允許同時存在的 Session 上限
+	MaxSessionLifetime time.Duration // 單一 Session 從建立起算的最長存活時間，admin 延長時不可超過，0 代表不限制
+	EvictReasonTTL     time.Duration // Session 因超過上限被踢掉時，踢除原因保留的時間，0 代表不保留
+	SessionDBFallback  bool          // Redis 查無 session 時改查 sessions 表並回填 Redis，讓 DB 成為 session 的真實來源
+	SessionEpoch       int64         // session ID 內嵌的 epoch 下限，調高後舊 epoch 的 session 全部失效
+	SessionIDEncoding  string        // session ID 隨機部分的編碼："uuid"（預設）或較短的 "base62"
+
+	RoleSessionTTL map[string]time.Duration // 依使用者角色覆寫 SessionTTL（例如 admin 較短），同時符合多個角色時取最短，沒有對應的角色沿用 SessionTTL
+
+	SessionRecordMode string // 登入時寫入 sessions 表的方式："sync"（預設，與 Redis 同步寫入）或 "async"（排入 session:record 由 worker 寫入）
+
+	LastSeenInterval time.Duration // session 的 last_seen 最多每隔多久寫入一次 Redis，0 代表不記錄 last_seen
+
+	RequestCountInterval time.Duration // session 的 request_count 在本機累計後最多每隔多久寫入一次 Redis，0 代表不記錄請求數
+
+	SessionMissingExpiryPolicy string // session hash 沒有 expires_at 時的處理："ttl"（預設，以 Redis key 剩餘 TTL 判斷）或 "reject"（一律視為無效）
+
+	ExtendSessionOnRefresh bool // /auth/refresh 成功時將 session 到期時間滑動到 now + SessionTTL（不超過 MaxSessionLifetime），關閉時只換發 access token
+
+	RefreshFamilyMaxAge time.Duration // refresh 家族（即 session，從登入起算）的最長年齡，超過後 /auth/refresh 一律拒絕、必須重新登入；0 代表不限制
+
+	FeatureFlagsRefresh time.Duration // Redis 中的 feature flag 在本機快取多久才重新載入，0 代表每次都查詢 Redis
+
+	TrustedDeviceTTL time.Duration // 「記住此裝置」後該裝置免 MFA 的期間，0 代表停用 trusted device
+
+	TokenExpiryPolicy string // 簽發的 JWT exp 超過 session expires_at 時的處理："clamp"（預設，縮短到 session 到期時間）或 "reject"（拒絕簽發）
+
+	MaxTokenTTL time.Duration // 任何 JWT 從簽發起算的存活上限，呼叫端要求更晚的 exp 時一律縮短並記錄 log，0 代表不限制
+
+	TokenMode string // access token 格式："jwt"（預設）或 "opaque"（隨機 reference token，只能由本服務查 Redis 驗證，登出立即失效）
+
+	MaxSessionsPerDevice   map[string]int // 依裝置類別（mobile / web / other）各自的 session 上限；有設定時各類別分開計算與踢除
+	MaxSessionsPerDeviceID int            // 同一使用者在同一個 client 提供的 device_id 上允許的 session 數，0 代表不限制
+	PinnedLimitPolicy      string         // 達到上限且可踢除的 session 全部已 pin 時的處理："evict_oldest"（預設，照樣踢最舊的）或 "reject"（拒絕登入）
+	EvictionOrder          string         // 達到上限時先踢哪個 session："created"（預設，最早建立的）或 "last_seen"（最久沒有活動的）
+
+	SingleSessionPerBrowser bool // 同一使用者在同一個 client 提供的 browser_id 上只保留一個 session，新登入時踢掉舊的
+
+	SessionWarnThreshold float64 // 使用者的 session 數達到 MaxSessionsPerUser 的此比例時，登入回應附上 session_warning，0 代表關閉
+
+	// 密碼雜湊設定
+	BcryptCost       int           // 新密碼雜湊使用的 bcrypt cost，低於此值的舊雜湊會在登入時升級
+	RehashSyncBudget time.Duration // 登入時同步重新雜湊的預估耗時上限，超過則只標記 needs_rehash
+	PasswordPepper   string        // 密碼在 bcrypt 前先以此密鑰做 HMAC-SHA256，留空則不使用 pepper
+
+	BcryptMaxConcurrency int           // 同時進行的 bcrypt 密碼比對上限，避免登入尖峰吃滿 CPU，0 代表不限制
+	BcryptQueueDepth     int           // 達到上限時最多幾個比對排隊等候，超過直接回 503
+	BcryptQueueTimeout   time.Duration // 排隊等候比對名額的時間上限，逾時回 503
+
+	PasswordMinEntropyBits int           // signup 與重設密碼時要求的最低密碼熵估計（bits），0 代表不檢查
+	PasswordMaxAge         time.Duration // 密碼從上次變更起算的最長使用期限，超過後登入回 ErrPasswordExpired 要求重設，0 代表不限制
+
+	// Readiness check 設定
+	ReadyCacheTTL time.Duration // /ready 檢查結果的快取時間，期間內的 probe 共用同一次檢查
 
 	// Asynq worker 設定
-	AsynqConcurrency int // Asynq worker 併發數量
+	AsynqConcurrency      int           // Asynq worker 併發數量
+	WorkerShutdownTimeout time.Duration // worker 關機時停止拉新任務後，等待進行中任務完成的時間上限，逾時的任務會交回佇列重試
+	BanResyncInterval     time.Duration // worker 依 users.is_banned 重建 Redis ban 旗標的間隔（啟動時一律執行一次），0 代表只在啟動時執行
+	CountersSweepInterval time.Duration // worker 清除沒有 TTL 的 rate limit / 登入失敗計數器的間隔，0 代表不執行
+
+	SessionReconcileInterval time.Duration // worker 為 Redis 中存在但 sessions 表沒有紀錄的 session 補寫紀錄的間隔，0 代表不執行
+
+	// login:audit 批次寫入設定
+	AuditBatchSize     int           // 累積多少筆 login_events 就寫入一次，0 代表關閉批次、每筆直接寫入
+	AuditBatchInterval time.Duration // 批次未滿時最長等待多久就寫入
+
+	// login:audit 輸出目的地
+	AuditSinks     []string // 同時輸出的 sink：sqlite、file、syslog，逗號分隔
+	AuditFilePath  string   // file sink 附加寫入的 JSON lines 檔案路徑
+	AuditSyslogTag string   // syslog sink 使用的 tag
 
 	// Admin API key
-	AdminAPIKey string // Admin 後台 API 使用的簡易驗證密鑰
+	AdminAPIKey         string // Admin 後台 API 使用的簡易驗證密鑰
+	AdminAPIKeyPrevious string // 輪替期間仍接受的舊 admin key，所有 admin 工具換成新 key 後移除，留空代表只接受 AdminAPIKey
+	AdminPurgeConfirm   string // 呼叫 /admin/sessions/purge 時 X-Confirm-Purge header 必須相符的確認碼，留空則停用 purge
+
+	// Admin key 驗證失敗稽核
+	AdminAuthFailureThreshold int           // 同一 IP 在 AdminAuthFailureWindow 內 admin key 驗證失敗達此次數時送出 notify:admin_auth_failure，0 代表不通知
+	AdminAuthFailureWindow    time.Duration // 計算 admin key 驗證失敗次數的視窗長度
+
+	// Prometheus /metrics 設定
+	MetricsMode       string // "off"、"listener"（獨立內部 listener）或 "admin"（主 port 的 /metrics，需 admin key）
+	MetricsAddr       string // listener 模式下 API 的 /metrics 監聽位址，應只綁定內部網段
+	WorkerMetricsAddr string // worker 的 /metrics 監聽位址（worker 沒有主 port，mode 非 off 時一律使用獨立 listener）
+
+	// Signup challenge 設定
+	SignupChallenge  string // signup 防機器人驗證模式：""（關閉）、"captcha" 或 "pow"
+	CaptchaSecret    string // hCaptcha / Turnstile 的伺服器端 secret
+	CaptchaVerifyURL string // CAPTCHA 驗證 API 位址（hCaptcha 或 Turnstile 的 siteverify）
+	PoWDifficulty    int    // proof-of-work 要求的前導零位元數
+
+	SignupCooldown time.Duration // 同一 IP 成功註冊後，需間隔多久才能再註冊，0 代表不限制
+
+	UserRestoreGrace time.Duration // 軟刪除的 user 在多久內可由 admin 還原，0 代表不限期
+
+	// 地理位置與 impossible travel 偵測
+	GeoCountryHeader       string // 前端 proxy / CDN 標注國碼的 header（例如 CF-IPCountry），留空則不記錄國家
+	ImpossibleTravelMaxKmh int    // 同一使用者兩次成功登入間換算的移動速度超過此值（km/h）即告警，0 代表關閉
+	ImpossibleTravelKick   bool   // 偵測到 impossible travel 時一併踢掉該使用者所有 session
+
+	// 依來源國家限制登入（國碼為 ISO 3166-1 alpha-2，統一轉成大寫）
+	LoginCountryAllowlist     []string // 只允許這些國家登入，留空代表不限制
+	LoginCountryDenylist      []string // 拒絕這些國家登入，優先於 allowlist
+	LoginCountryUnknownPolicy string   // 查不出來源國家時的處理：allow 或 deny
+	BlockMultiCountrySessions bool     // 使用者已有來自其他國家的有效 session 時拒絕新的登入
+
+	// Signed login（machine-to-machine）設定
+	SignedLoginSecret  string        // client 簽章用的共用 HMAC 密鑰，留空則不開放 /auth/login/signed
+	SignedLoginMaxSkew time.Duration // 簽章時間戳與伺服器時間允許的最大誤差
+
+	SignedLoginUsers []string // 可以用 signed login 登入的 username（machine 帳號），共用密鑰不能替清單以外的使用者建立 session
+
+	// Magic link（寄到 email 的一次性登入連結）設定
+	MagicLinkEnabled   bool          // 是否開放 /auth/magic-link 與 /auth/magic-login
+	MagicLinkTTL       time.Duration // 連結的有效期間，過期或使用一次後即失效
+	MagicLinkURL       string        // email 內連結的位址，token 以 ?token= 附加在後面
+	MagicLinkRateLimit int           // 每個 IP 每分鐘可要求寄送連結的次數上限，0 代表不限制
+
+	MagicLinkMinResponse time.Duration // POST /auth/magic-link 回應的最短時間，讓 email 存在與否無法從回應時間區分，0 代表不限制
+
+	// 密碼重設設定
+	PasswordResetTokenTTL  time.Duration // 重設 token 的有效期間，使用一次或過期即失效
+	PasswordResetURL       string        // force-reset 時寄給使用者的重設頁面位址，token 以 ?token= 附加；留空則不寄信，只能由 admin 發出 token
+	PasswordResetRateLimit int           // POST /auth/password/reset 每個 IP 每分鐘的次數上限，0 代表不限制
+
+	// Username 可用性查詢設定
+	UsernameCheckRateLimit   int           // 每個 IP 每分鐘可查詢的次數上限，0 代表不限制
+	UsernameCheckMinResponse time.Duration // 查詢回應的最短時間，讓「可用 / 已被使用」的回應時間一致
+
+	SessionStatusRateLimit int // GET /auth/session-status 每個 IP 每分鐘可查詢的次數上限，0 代表不限制
+
+	// Login 設定
+	LoginMinResponse time.Duration // login 回應的最短時間，成功與失敗一致，0 代表不限制
+
+	LogoutBodyToken bool // /auth/logout 沒有 Authorization header 時接受 body 中的 token（給 navigator.sendBeacon 使用）
+
+	LoginRateLimit       int           // 單一使用者在 LoginRateLimitWindow 內最多可成功建立的 session 數，超過回 429，0 代表不限制
+	LoginRateLimitWindow time.Duration // 計算 LoginRateLimit 的固定視窗長度
+
+	// 保留 username 設定
+	ReservedUsernames []string // 不允許註冊或改名使用的 username，比對時會先正規化
+
+	// Token exchange 設定
+	TokenExchangeScopes    []string      // session token 可換出的 scope 全集，請求超出此範圍即視為越權
+	TokenExchangeAudiences []string      // 允許換發的下游服務 audience，留空則不開放 token exchange
+	TokenExchangeTTL       time.Duration // 換出 token 的存活時間上限，不會超過原 session 的到期時間
+
+	MaxTokenScopes      int    // 單一 token 最多帶幾個 scope，避免 token 無限制變大，0 代表不限制
+	TokenScopesOverflow string // scope 超過 MaxTokenScopes 時的處理："reject"（預設，拒絕簽發）或 "group"（先把同一前綴的完整 scope 合併成 "prefix:*"）
+
+	ValidateBatchMaxTokens int // POST /auth/validate-batch 單次最多驗證幾顆 token
+
+	// 登入後跳轉設定
+	OAuthAllowedRedirects []string // redirect_uri / return_to 允許的目標（完全相同，或以 * 結尾做前綴比對），留空則一律拒絕
+
+	// 安全性回應 header，設為 off 則不送出該 header
+	HeaderContentTypeOptions string // X-Content-Type-Options
+	HeaderFrameOptions       string // X-Frame-Options
+	HeaderReferrerPolicy     string // Referrer-Policy
+	HeaderHSTS               string // Strict-Transport-Security，只應在全程 HTTPS 的部署啟用
+	HeaderCSP                string // Content-Security-Policy
+
+	// 反向 proxy 與 HTTPS
+	TrustedProxies []string // 受信任的 proxy（IP 或 CIDR），只有來自這些位址的 X-Forwarded-For / X-Forwarded-Proto 才會採用
+	ForceHTTPS     bool     // APP_ENV=production 時拒絕不是經由 HTTPS 抵達的請求（回 403），/health 與 /ready 除外
+}
+
+// Load 使用 viper 從環境變數、CONFIG_FILE 指定的 YAML / JSON 設定檔與 .env 檔載入設定，並給預設值，
+// 優先順序為：環境變數 > CONFIG_FILE > .env > 預設值。設定檔的 key 與環境變數名稱相同（不分大小寫），
+// 清單可寫成 YAML / JSON 陣列，MAX_SESSIONS_PER_DEVICE 可寫成物件。合併後的結果會經過 Validate 檢查。 // 對外提供載入設定的統一入口
+// APP_JWT_SECRET 與 ADMIN_API_KEY 依 SECRETS_PROVIDER 取得，預設沿用上述來源。
+func Load() (*Config, error) {
+	return LoadWithSecrets(nil) // 由 SECRETS_PROVIDER 決定 provider
 }
 
-// Load 使用 viper 從環境變數與 .env 檔載入設定，並給預設值。 // 對外提供載入設定的統一入口
-func Load() *Config {
+// LoadWithSecrets 與 Load 相同，但 APP_JWT_SECRET 與 ADMIN_API_KEY 改向 provider 查詢；provider 為 nil 時依 SECRETS_PROVIDER 建立。
+func LoadWithSecrets(provider SecretProvider) (*Config, error) {
 	// 初始化 viper：優先讀取環境變數，再從 .env 檔補值 // 說明載入順序：環境變數優先，其次 .env，最後才是預設值
 	v := viper.New() // 建立一個新的 viper 實例，避免污染全域狀態
 
@@ -43,32 +247,471 @@ func Load() *Config {
 	// 若 .env 不存在，不視為錯誤，方便容器 / 雲端只用環境變數配置 // 容忍沒有 .env 的情況，以利在 Kubernetes / Docker 只用環境變數
 	_ = v.ReadInConfig() // 嘗試讀取 .env，若失敗直接忽略錯誤（不會中止程式）
 
+	// 明確指定的設定檔必須存在且格式正確，與 .env 合併時以設定檔為準（環境變數仍然優先）
+	if file := v.GetString("CONFIG_FILE"); file != "" {
+		v.SetConfigFile(file)                                        // 改讀指定的設定檔
+		v.SetConfigType(strings.TrimPrefix(filepath.Ext(file), ".")) // 依副檔名（.yaml / .yml / .json）決定格式，取代上面的 env 格式
+		if err := v.MergeInConfig(); err != nil {
+			return nil, fmt.Errorf("read config file %s: %w", file, err)
+		}
+	}
+
 	// 預設值（僅當環境變數與 .env 都沒有時才會用到） // 提供安全的 fallback，確保本機開發即使沒設 .env 也能啟動
-	v.SetDefault("APP_HTTP_ADDR", ":8080")             // HTTP 監聽位址預設為 :8080
-	v.SetDefault("APP_DB_PATH", "./data/app.db")      // SQLite 檔案預設存放於 ./data/app.db
-	v.SetDefault("APP_JWT_SECRET", "dev-secret-change-me") // 開發預設 JWT 密鑰，正式環境請務必覆蓋
+	v.SetDefault("APP_HTTP_ADDR", ":8080")           // HTTP 監聽位址預設為 :8080
+	v.SetDefault("APP_DB_PATH", "./data/app.db")     // SQLite 檔案預設存放於 ./data/app.db
+	v.SetDefault("APP_JWT_SECRET", DevJWTSecret)     // 開發預設 JWT 密鑰，正式環境請務必覆蓋
+	v.SetDefault("JWT_MAX_TOKEN_BYTES", 8192)        // JWT 最大 8KB，過長的 token 不進入解析
+	v.SetDefault("REQUEST_TIMEOUT_MS", 10000)        // 單一請求預設最多處理 10 秒
+	v.SetDefault("JWT_COMPACT_CLAIMS", false)        // 預設使用一般的 claim key
+	v.SetDefault("JWT_SUB_STRING", false)            // 預設 sub 維持數字，與既有下游相容
+	v.SetDefault("REQUIRE_JSON_CONTENT_TYPE", false) // 預設不檢查 Content-Type
+	v.SetDefault("ALLOW_FORM_LOGIN", true)           // 預設允許 HTML form 直接送出登入
+
+	v.SetDefault("APP_ENV", "development")   // 預設為開發環境
+	v.SetDefault("JWT_MIN_SECRET_BYTES", 32) // HS256 密鑰至少 32 bytes（與雜湊輸出等長）
+
+	v.SetDefault("JWT_LEEWAY_SECONDS", 0) // 預設不容忍時鐘誤差
+
+	v.SetDefault("JWT_ENFORCE_AUDIENCE", false) // 預設仍接受沒有 aud 的 token
+
+	v.SetDefault("LOG_SAMPLE_INTERVAL_SECONDS", 60) // 相同錯誤每 60 秒最多輸出一次
+
+	v.SetDefault("SSE_SHUTDOWN_GRACE_SECONDS", 5) // 關閉時最多等 SSE 串流 5 秒
 
 	v.SetDefault("REDIS_ADDR", "127.0.0.1:6379") // Redis 預設位址
 	v.SetDefault("REDIS_PASSWORD", "")           // Redis 預設無密碼
+	v.SetDefault("REDIS_DB", 0)                  // Redis 預設使用 DB 0
+	v.SetDefault("ASYNQ_REDIS_ADDR", "")         // 預設不另外指定 Asynq Redis，沿用 session Redis
+	v.SetDefault("ASYNQ_REDIS_PASSWORD", "")     // Asynq Redis 預設無密碼
+
+	v.SetDefault("REDIS_KEEPALIVE_INTERVAL_SECONDS", 0) // 預設不定期 PING Redis
+
+	v.SetDefault("OUTBOUND_TLS_MIN_VERSION", "1.2")      // 預設最低 TLS 1.2
+	v.SetDefault("OUTBOUND_HTTP_TIMEOUT_MS", 5000)       // 對外請求預設 5 秒逾時
+	v.SetDefault("OUTBOUND_HTTP_MAX_CONNS_PER_HOST", 10) // 對同一主機預設最多 10 條連線
+
+	v.SetDefault("SESSION_TTL_SECONDS", 3600)           // 1 小時；Session 與 JWT 預設存活秒數
+	v.SetDefault("MAX_SESSIONS_PER_USER", 2)            // 同一使用者預設最多同時 2 個 Session
+	v.SetDefault("MAX_SESSION_LIFETIME_SECONDS", 86400) // 24 小時；Session 延長後的存活上限
+	v.SetDefault("EVICT_REASON_TTL_SECONDS", 3600)      // 踢除原因預設保留 1 小時
+	v.SetDefault("SESSION_DB_FALLBACK", false)          // 預設只以 Redis 判斷 session 是否有效
+	v.SetDefault("EXTEND_SESSION_ON_REFRESH", false)    // 預設 refresh 不延長 session
+	v.SetDefault("REFRESH_FAMILY_MAX_AGE_SECONDS", 0)   // 預設不限制 refresh 家族的年齡
+	v.SetDefault("SESSION_EPOCH", 1)                    // 預設 epoch 為 1，與未帶 epoch 的舊 session ID 相同
+	v.SetDefault("SESSION_ID_ENCODING", "uuid")         // 預設沿用 UUID 格式的 session ID
+	v.SetDefault("BCRYPT_COST", 10)                     // 預設與 bcrypt.DefaultCost 相同
+	v.SetDefault("REHASH_SYNC_BUDGET_MS", 250)          // 同步 rehash 預估超過 250 毫秒時改為標記下次處理
+	v.SetDefault("BCRYPT_MAX_CONCURRENCY", 0)           // 預設不限制同時進行的 bcrypt 比對
+	v.SetDefault("BCRYPT_QUEUE_DEPTH", 64)              // 最多 64 個比對排隊
+	v.SetDefault("BCRYPT_QUEUE_TIMEOUT_MS", 1000)       // 排隊最多等 1 秒
+	v.SetDefault("PASSWORD_PEPPER", "")                 // 預設不使用 pepper
+	v.SetDefault("PASSWORD_MIN_ENTROPY_BITS", 0)        // 預設不檢查密碼強度
+	v.SetDefault("PASSWORD_MAX_AGE_DAYS", 0)            // 預設不強制定期更換密碼
+	v.SetDefault("READY_CACHE_TTL_MS", 2000)            // /ready 結果預設快取 2 秒
+	v.SetDefault("ASYNQ_CONCURRENCY", 10)               // Asynq worker 預設併發數為 10
+	v.SetDefault("WORKER_SHUTDOWN_TIMEOUT_SECONDS", 30) // 關機時最多等待進行中任務 30 秒
+	v.SetDefault("BAN_RESYNC_INTERVAL_SECONDS", 300)    // 每 5 分鐘重建一次 ban 旗標
+	v.SetDefault("ADMIN_API_KEY", "dev-admin")          // 開發預設 admin key，方便本機測試
+	v.SetDefault("ADMIN_API_KEY_PREVIOUS", "")          // 預設沒有輪替中的舊 key
+
+	v.SetDefault("COUNTERS_SWEEP_INTERVAL_SECONDS", 3600) // 每小時清除一次沒有 TTL 的計數器
+
+	v.SetDefault("ROLE_SESSION_TTL", "") // 預設所有角色都沿用 SESSION_TTL_SECONDS
+
+	v.SetDefault("SESSION_RECORD_MODE", "sync")             // 預設同步寫入 sessions 表
+	v.SetDefault("SESSION_RECONCILE_INTERVAL_SECONDS", 300) // 每 5 分鐘補寫一次遺漏的 sessions 紀錄
+
+	v.SetDefault("SECRETS_PROVIDER", SecretsProviderEnv) // 預設從環境變數 / 設定檔讀取密鑰
+	v.SetDefault("SECRETS_TIMEOUT_MS", 5000)             // 查詢 secrets manager 最多等待 5 秒
+
+	v.SetDefault("LOGIN_AUDIT_BATCH_SIZE", 0)          // 預設關閉批次寫入
+	v.SetDefault("LOGIN_AUDIT_BATCH_INTERVAL_MS", 500) // 批次最長等待 500 毫秒
+
+	v.SetDefault("AUDIT_SINK", "sqlite")                  // 預設只寫入 login_events
+	v.SetDefault("AUDIT_FILE_PATH", "./data/audit.jsonl") // file sink 預設與 SQLite 放在同一個資料目錄
+	v.SetDefault("AUDIT_SYSLOG_TAG", "session-service")   // syslog 預設 tag
+
+	v.SetDefault("SIGNUP_CHALLENGE", "")                                                            // 預設關閉 signup challenge
+	v.SetDefault("CAPTCHA_SECRET", "")                                                              // 預設無 CAPTCHA secret
+	v.SetDefault("CAPTCHA_VERIFY_URL", "https://challenges.cloudflare.com/turnstile/v0/siteverify") // 預設使用 Turnstile 的驗證端點
+	v.SetDefault("POW_DIFFICULTY", 20)                                                              // 預設要求 20 個前導零位元（一般瀏覽器約需數百毫秒）
+
+	v.SetDefault("GEO_COUNTRY_HEADER", "")        // 預設不記錄登入國家
+	v.SetDefault("IMPOSSIBLE_TRAVEL_MAX_KMH", 0)  // 預設關閉 impossible travel 偵測
+	v.SetDefault("IMPOSSIBLE_TRAVEL_KICK", false) // 預設只告警不踢人
+
+	v.SetDefault("LOGIN_COUNTRY_ALLOWLIST", "")           // 預設不限制登入國家
+	v.SetDefault("LOGIN_COUNTRY_DENYLIST", "")            // 預設不拒絕任何國家
+	v.SetDefault("LOGIN_COUNTRY_UNKNOWN_POLICY", "allow") // 預設放行查不出國家的登入（例如內網 IP）
+	v.SetDefault("BLOCK_MULTI_COUNTRY_SESSIONS", false)   // 預設允許同時從不同國家登入
+
+	v.SetDefault("SIGNED_LOGIN_SECRET", "")           // 預設關閉 signed login
+	v.SetDefault("SIGNED_LOGIN_MAX_SKEW_SECONDS", 60) // 時間戳前後 60 秒內有效
+	v.SetDefault("SIGNED_LOGIN_USERS", "")            // 預設沒有任何帳號可用 signed login
+
+	v.SetDefault("MAGIC_LINK_ENABLED", false)                                // 預設關閉 magic link 登入
+	v.SetDefault("MAGIC_LINK_TTL_SECONDS", 900)                              // 連結 15 分鐘內有效
+	v.SetDefault("MAGIC_LINK_URL", "http://localhost:8080/auth/magic-login") // 預設指向本機的 /auth/magic-login
+	v.SetDefault("MAGIC_LINK_RATE_LIMIT", 5)                                 // 每個 IP 每分鐘最多要求 5 次
+
+	v.SetDefault("MAGIC_LINK_MIN_RESPONSE_MS", 250) // 回應至少 250 毫秒，涵蓋查詢使用者與排入寄信任務的時間
+
+	v.SetDefault("PASSWORD_RESET_TOKEN_TTL_SECONDS", 3600) // 重設 token 1 小時內有效
+	v.SetDefault("PASSWORD_RESET_URL", "")                 // 預設不寄送重設連結
+	v.SetDefault("PASSWORD_RESET_RATE_LIMIT", 10)          // 每個 IP 每分鐘最多嘗試 10 次
+
+	v.SetDefault("USERNAME_CHECK_RATE_LIMIT", 30)       // 每個 IP 每分鐘最多查詢 30 次
+	v.SetDefault("USERNAME_CHECK_MIN_RESPONSE_MS", 150) // 查詢回應至少 150 毫秒，降低 timing 枚舉價值
+
+	v.SetDefault("SESSION_STATUS_RATE_LIMIT", 20) // 每個 IP 每分鐘最多查詢 20 次 session 狀態
+
+	v.SetDefault("LOGIN_MIN_RESPONSE_MS", 0) // 預設不延遲 login 回應
+	v.SetDefault("LOGOUT_BODY_TOKEN", false) // 預設 logout 只接受 Authorization header
+
+	v.SetDefault("LOGIN_RATE_LIMIT", 60)                // 同一使用者每個視窗最多登入 60 次，一般使用不會碰到
+	v.SetDefault("LOGIN_RATE_LIMIT_WINDOW_SECONDS", 60) // 以 1 分鐘為一個視窗
+
+	v.SetDefault("RESERVED_USERNAMES", "admin,administrator,root,system,support,help,security,moderator,staff,api,www") // 預設保留的 username
+
+	v.SetDefault("TOKEN_EXCHANGE_TTL_SECONDS", 300) // 換出的下游 token 預設 5 分鐘
+
+	v.SetDefault("MAX_TOKEN_SCOPES", 0)             // 預設不限制 token 內的 scope 數
+	v.SetDefault("TOKEN_SCOPES_OVERFLOW", "reject") // 超過上限時預設拒絕簽發
+
+	v.SetDefault("VALIDATE_BATCH_MAX_TOKENS", 100) // 批次驗證單次最多 100 顆 token
+
+	v.SetDefault("OAUTH_ALLOWED_REDIRECTS", "") // 預設不允許任何跳轉目標
+
+	v.SetDefault("SECURITY_CONTENT_TYPE_OPTIONS", "nosniff")                   // 禁止瀏覽器猜測 Content-Type
+	v.SetDefault("SECURITY_FRAME_OPTIONS", "DENY")                             // 禁止被嵌入 iframe
+	v.SetDefault("SECURITY_REFERRER_POLICY", "no-referrer")                    // 不送出 Referer
+	v.SetDefault("SECURITY_HSTS", "off")                                       // 預設不送 HSTS，避免本機 HTTP 開發被鎖在 HTTPS
+	v.SetDefault("SECURITY_CSP", "default-src 'none'; frame-ancestors 'none'") // JSON API 不需要載入任何資源
+
+	v.SetDefault("TRUSTED_PROXIES", "0.0.0.0/0,::/0") // 與 Gin 預設相同，信任所有來源的 forwarded header
+	v.SetDefault("FORCE_HTTPS", false)                // 預設不強制 HTTPS
+
+	v.SetDefault("SIGNUP_COOLDOWN_SECONDS", 0) // 預設不限制同一 IP 的註冊間隔
+
+	v.SetDefault("USER_RESTORE_GRACE_SECONDS", 30*24*60*60) // 軟刪除後 30 天內可還原
+
+	v.SetDefault("MAX_SESSIONS_PER_DEVICE", "")                 // 預設不分裝置類別，沿用 MAX_SESSIONS_PER_USER
+	v.SetDefault("MAX_SESSIONS_PER_DEVICE_ID", 0)               // 預設不限制同一 device_id 的 session 數
+	v.SetDefault("PINNED_SESSION_LIMIT_POLICY", "evict_oldest") // 全部 session 都已 pin 時預設仍踢最舊的
+	v.SetDefault("EVICTION_ORDER", "created")                   // 預設依建立時間踢除
 
-	v.SetDefault("SESSION_TTL_SECONDS", 3600) // 1 小時；Session 與 JWT 預設存活秒數
-	v.SetDefault("MAX_SESSIONS_PER_USER", 2)  // 同一使用者預設最多同時 2 個 Session
-	v.SetDefault("ASYNQ_CONCURRENCY", 10)     // Asynq worker 預設併發數為 10
-	v.SetDefault("ADMIN_API_KEY", "dev-admin") // 開發預設 admin key，方便本機測試
+	v.SetDefault("SINGLE_SESSION_PER_BROWSER", false) // 預設不限制同一 browser_id 的 session 數
+
+	v.SetDefault("SESSION_WARN_THRESHOLD", 0) // 預設不在登入回應附上 session 數警告
+
+	v.SetDefault("SESSION_LAST_SEEN_INTERVAL_SECONDS", 30) // last_seen 每個 session 最多 30 秒寫入一次
+	v.SetDefault("SESSION_MISSING_EXPIRY_POLICY", "ttl")   // 缺少 expires_at 時預設改看 key 的 TTL
+
+	v.SetDefault("SESSION_REQUEST_COUNT_INTERVAL_SECONDS", 0) // 預設不記錄每個 session 的請求數
+
+	v.SetDefault("FEATURE_FLAGS_REFRESH_SECONDS", 10) // feature flag 預設每 10 秒重新載入
+
+	v.SetDefault("TRUSTED_DEVICE_DAYS", 30) // 記住裝置預設 30 天
+
+	v.SetDefault("TOKEN_EXPIRY_POLICY", "clamp") // token 比 session 活得久時預設縮短到 session 到期時間
+
+	v.SetDefault("MAX_TOKEN_TTL_SECONDS", 86400) // token 最多存活 24 小時
+
+	v.SetDefault("TOKEN_MODE", "jwt") // 預設簽發 JWT
+
+	v.SetDefault("ADMIN_AUTH_FAILURE_THRESHOLD", 0)        // 預設只記錄 log 與指標，不送出通知
+	v.SetDefault("ADMIN_AUTH_FAILURE_WINDOW_SECONDS", 300) // 以 5 分鐘為單位計算失敗次數
+
+	v.SetDefault("METRICS_MODE", "listener")              // 預設以獨立 listener 提供 /metrics，不對外公開
+	v.SetDefault("METRICS_ADDR", "127.0.0.1:9090")        // API 的 metrics 只綁定 loopback
+	v.SetDefault("WORKER_METRICS_ADDR", "127.0.0.1:9091") // worker 的 metrics 使用另一個 port，避免同機部署時衝突
 
 	// 組合 Config 結構並回傳給呼叫端 // 將剛才透過 viper 取得的值轉成強型別設定物件
-	return &Config{
+	cfg := &Config{
+		AppEnv:    v.GetString("APP_ENV"),        // 讀取執行環境
 		HTTPAddr:  v.GetString("APP_HTTP_ADDR"),  // 讀取 HTTP 監聽位址字串
 		DBPath:    v.GetString("APP_DB_PATH"),    // 讀取 SQLite 檔案路徑字串
 		JWTSecret: v.GetString("APP_JWT_SECRET"), // 讀取 JWT 簽章密鑰
 
+		JWTMaxTokenLen:   v.GetInt("JWT_MAX_TOKEN_BYTES"),                                  // 讀取 JWT 長度上限
+		JWTCompactClaims: v.GetBool("JWT_COMPACT_CLAIMS"),                                  // 讀取是否使用 compact claims
+		JWTSubString:     v.GetBool("JWT_SUB_STRING"),                                      // 讀取 sub 是否以字串輸出
+		RequestTimeout:   time.Duration(v.GetInt("REQUEST_TIMEOUT_MS")) * time.Millisecond, // 將毫秒轉成 time.Duration
+
+		JWTMinSecretBytes: v.GetInt("JWT_MIN_SECRET_BYTES"), // 讀取 JWT 密鑰最短長度
+
+		JWTLeeway: time.Duration(v.GetInt("JWT_LEEWAY_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		JWTEnforceAudience: v.GetBool("JWT_ENFORCE_AUDIENCE"), // 讀取是否強制檢查 aud
+
+		RequireJSONContentType: v.GetBool("REQUIRE_JSON_CONTENT_TYPE"), // 讀取是否強制 JSON Content-Type
+		AllowFormLogin:         v.GetBool("ALLOW_FORM_LOGIN"),          // 讀取是否放行 form 登入
+
+		LogSampleInterval: time.Duration(v.GetInt("LOG_SAMPLE_INTERVAL_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		SSEShutdownGrace: time.Duration(v.GetInt("SSE_SHUTDOWN_GRACE_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
 		RedisAddr:     v.GetString("REDIS_ADDR"),     // 讀取 Redis 位址
 		RedisPassword: v.GetString("REDIS_PASSWORD"), // 讀取 Redis 密碼
+		RedisDB:       v.GetInt("REDIS_DB"),          // 讀取 Redis DB 編號
+
+		RedisKeepAliveInterval: time.Duration(v.GetInt("REDIS_KEEPALIVE_INTERVAL_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		OutboundTLSMinVersion:   v.GetString("OUTBOUND_TLS_MIN_VERSION"),                                // 讀取對外連線最低 TLS 版本
+		OutboundHTTPTimeout:     time.Duration(v.GetInt("OUTBOUND_HTTP_TIMEOUT_MS")) * time.Millisecond, // 將毫秒數轉成 time.Duration
+		OutboundMaxConnsPerHost: v.GetInt("OUTBOUND_HTTP_MAX_CONNS_PER_HOST"),                           // 讀取對同一主機的連線數上限
+
+		AsynqRedisAddr:     v.GetString("ASYNQ_REDIS_ADDR"),     // 讀取 Asynq Redis 位址
+		AsynqRedisPassword: v.GetString("ASYNQ_REDIS_PASSWORD"), // 讀取 Asynq Redis 密碼
+		AsynqRedisDB:       v.GetInt("ASYNQ_REDIS_DB"),          // 讀取 Asynq Redis DB 編號（未設定時為 0）
+
+		SessionTTL:         time.Duration(v.GetInt("SESSION_TTL_SECONDS")) * time.Second,          // 將秒數轉成 time.Duration
+		MaxSessionsPerUser: v.GetInt("MAX_SESSIONS_PER_USER"),                                     // 讀取單一使用者 Session 上限
+		MaxSessionLifetime: time.Duration(v.GetInt("MAX_SESSION_LIFETIME_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+		EvictReasonTTL:     time.Duration(v.GetInt("EVICT_REASON_TTL_SECONDS")) * time.Second,     // 將秒數轉成 time.Duration
+		SessionDBFallback:  v.GetBool("SESSION_DB_FALLBACK"),                                      // 讀取是否啟用 DB fallback
+		SessionEpoch:       v.GetInt64("SESSION_EPOCH"),                                           // 讀取 session epoch
+		SessionIDEncoding:  v.GetString("SESSION_ID_ENCODING"),                                    // 讀取 session ID 編碼方式
+
+		RoleSessionTTL: secondsMap(getIntMap(v, "ROLE_SESSION_TTL")), // 拆解 "admin:900,user:3600" 格式的角色存活秒數
+
+		SessionRecordMode: v.GetString("SESSION_RECORD_MODE"), // 讀取 sessions 表的寫入方式
+
+		LastSeenInterval: time.Duration(v.GetInt("SESSION_LAST_SEEN_INTERVAL_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
 
-		SessionTTL:         time.Duration(v.GetInt("SESSION_TTL_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
-		MaxSessionsPerUser: v.GetInt("MAX_SESSIONS_PER_USER"),                            // 讀取單一使用者 Session 上限
+		RequestCountInterval: time.Duration(v.GetInt("SESSION_REQUEST_COUNT_INTERVAL_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		SessionMissingExpiryPolicy: v.GetString("SESSION_MISSING_EXPIRY_POLICY"), // 讀取缺少 expires_at 時的處理方式
+
+		ExtendSessionOnRefresh: v.GetBool("EXTEND_SESSION_ON_REFRESH"), // 讀取 refresh 時是否延長 session
+
+		RefreshFamilyMaxAge: time.Duration(v.GetInt("REFRESH_FAMILY_MAX_AGE_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		FeatureFlagsRefresh: time.Duration(v.GetInt("FEATURE_FLAGS_REFRESH_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		TrustedDeviceTTL: time.Duration(v.GetInt("TRUSTED_DEVICE_DAYS")) * 24 * time.Hour, // 將天數轉成 time.Duration
+
+		TokenExpiryPolicy: v.GetString("TOKEN_EXPIRY_POLICY"), // 讀取 token exp 超過 session 時的處理方式
+
+		MaxTokenTTL: time.Duration(v.GetInt("MAX_TOKEN_TTL_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		TokenMode: v.GetString("TOKEN_MODE"), // 讀取 access token 格式
+
+		MaxSessionsPerDevice:   getIntMap(v, "MAX_SESSIONS_PER_DEVICE"),    // 拆解 "mobile=1,web=2" 格式的類別上限
+		MaxSessionsPerDeviceID: v.GetInt("MAX_SESSIONS_PER_DEVICE_ID"),     // 讀取單一 device_id 的 session 上限
+		PinnedLimitPolicy:      v.GetString("PINNED_SESSION_LIMIT_POLICY"), // 讀取全部 session 已 pin 時的處理方式
+		EvictionOrder:          v.GetString("EVICTION_ORDER"),              // 讀取踢除順序
+
+		SingleSessionPerBrowser: v.GetBool("SINGLE_SESSION_PER_BROWSER"), // 讀取是否每個 browser_id 只保留一個 session
+
+		SessionWarnThreshold: v.GetFloat64("SESSION_WARN_THRESHOLD"), // 讀取 session 數警告比例
+
+		BcryptCost:       v.GetInt("BCRYPT_COST"),                                             // 讀取 bcrypt cost
+		RehashSyncBudget: time.Duration(v.GetInt("REHASH_SYNC_BUDGET_MS")) * time.Millisecond, // 將毫秒轉成 time.Duration
+		PasswordPepper:   v.GetString("PASSWORD_PEPPER"),                                      // 讀取密碼 pepper
+
+		BcryptMaxConcurrency: v.GetInt("BCRYPT_MAX_CONCURRENCY"),                                    // 讀取 bcrypt 比對併發上限
+		BcryptQueueDepth:     v.GetInt("BCRYPT_QUEUE_DEPTH"),                                        // 讀取排隊上限
+		BcryptQueueTimeout:   time.Duration(v.GetInt("BCRYPT_QUEUE_TIMEOUT_MS")) * time.Millisecond, // 將毫秒轉成 time.Duration
+
+		PasswordMinEntropyBits: v.GetInt("PASSWORD_MIN_ENTROPY_BITS"),                             // 讀取最低密碼熵
+		PasswordMaxAge:         time.Duration(v.GetInt("PASSWORD_MAX_AGE_DAYS")) * 24 * time.Hour, // 將天數轉成 time.Duration
+
+		ReadyCacheTTL: time.Duration(v.GetInt("READY_CACHE_TTL_MS")) * time.Millisecond, // 將毫秒轉成 time.Duration
 
 		AsynqConcurrency: v.GetInt("ASYNQ_CONCURRENCY"), // 讀取 Asynq worker 併發設定
-		AdminAPIKey:      v.GetString("ADMIN_API_KEY"), // 讀取 Admin API 密鑰
+		AdminAPIKey:      v.GetString("ADMIN_API_KEY"),  // 讀取 Admin API 密鑰
+
+		AdminAPIKeyPrevious: v.GetString("ADMIN_API_KEY_PREVIOUS"), // 讀取輪替期間仍接受的舊 admin key
+
+		WorkerShutdownTimeout: time.Duration(v.GetInt("WORKER_SHUTDOWN_TIMEOUT_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+		BanResyncInterval:     time.Duration(v.GetInt("BAN_RESYNC_INTERVAL_SECONDS")) * time.Second,     // 將秒數轉成 time.Duration
+		CountersSweepInterval: time.Duration(v.GetInt("COUNTERS_SWEEP_INTERVAL_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		SessionReconcileInterval: time.Duration(v.GetInt("SESSION_RECONCILE_INTERVAL_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		AdminPurgeConfirm: v.GetString("ADMIN_PURGE_CONFIRM"), // 讀取 purge 確認碼
+
+		AdminAuthFailureThreshold: v.GetInt("ADMIN_AUTH_FAILURE_THRESHOLD"),                                   // 讀取 admin 驗證失敗通知門檻
+		AdminAuthFailureWindow:    time.Duration(v.GetInt("ADMIN_AUTH_FAILURE_WINDOW_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		MetricsMode:       v.GetString("METRICS_MODE"),        // 讀取 /metrics 提供方式
+		MetricsAddr:       v.GetString("METRICS_ADDR"),        // 讀取 API metrics 監聽位址
+		WorkerMetricsAddr: v.GetString("WORKER_METRICS_ADDR"), // 讀取 worker metrics 監聽位址
+
+		AuditBatchSize:     v.GetInt("LOGIN_AUDIT_BATCH_SIZE"),                                          // 讀取 login_events 批次筆數
+		AuditBatchInterval: time.Duration(v.GetInt("LOGIN_AUDIT_BATCH_INTERVAL_MS")) * time.Millisecond, // 將毫秒轉成 time.Duration
+
+		AuditSinks:     getList(v, "AUDIT_SINK"),        // 拆解逗號分隔的 audit sink
+		AuditFilePath:  v.GetString("AUDIT_FILE_PATH"),  // 讀取 file sink 路徑
+		AuditSyslogTag: v.GetString("AUDIT_SYSLOG_TAG"), // 讀取 syslog tag
+
+		SignupChallenge:  v.GetString("SIGNUP_CHALLENGE"),   // 讀取 signup challenge 模式
+		CaptchaSecret:    v.GetString("CAPTCHA_SECRET"),     // 讀取 CAPTCHA secret
+		CaptchaVerifyURL: v.GetString("CAPTCHA_VERIFY_URL"), // 讀取 CAPTCHA 驗證端點
+		PoWDifficulty:    v.GetInt("POW_DIFFICULTY"),        // 讀取 PoW 難度
+
+		SignupCooldown: time.Duration(v.GetInt("SIGNUP_COOLDOWN_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		UserRestoreGrace: time.Duration(v.GetInt("USER_RESTORE_GRACE_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		GeoCountryHeader:       v.GetString("GEO_COUNTRY_HEADER"),     // 讀取國碼 header 名稱
+		ImpossibleTravelMaxKmh: v.GetInt("IMPOSSIBLE_TRAVEL_MAX_KMH"), // 讀取 impossible travel 速度門檻
+		ImpossibleTravelKick:   v.GetBool("IMPOSSIBLE_TRAVEL_KICK"),   // 讀取是否自動踢人
+
+		LoginCountryAllowlist:     upperList(getList(v, "LOGIN_COUNTRY_ALLOWLIST")), // 拆解逗號分隔的允許國碼
+		LoginCountryDenylist:      upperList(getList(v, "LOGIN_COUNTRY_DENYLIST")),  // 拆解逗號分隔的拒絕國碼
+		LoginCountryUnknownPolicy: v.GetString("LOGIN_COUNTRY_UNKNOWN_POLICY"),      // 讀取未知國家的處理方式
+		BlockMultiCountrySessions: v.GetBool("BLOCK_MULTI_COUNTRY_SESSIONS"),        // 讀取是否拒絕跨國同時登入
+
+		SignedLoginSecret:  v.GetString("SIGNED_LOGIN_SECRET"),                                     // 讀取 signed login 密鑰
+		SignedLoginMaxSkew: time.Duration(v.GetInt("SIGNED_LOGIN_MAX_SKEW_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		SignedLoginUsers: getList(v, "SIGNED_LOGIN_USERS"), // 拆解逗號分隔的 machine 帳號
+
+		MagicLinkEnabled:   v.GetBool("MAGIC_LINK_ENABLED"),                                 // 讀取是否開放 magic link
+		MagicLinkTTL:       time.Duration(v.GetInt("MAGIC_LINK_TTL_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+		MagicLinkURL:       v.GetString("MAGIC_LINK_URL"),                                   // 讀取 email 內的連結位址
+		MagicLinkRateLimit: v.GetInt("MAGIC_LINK_RATE_LIMIT"),                               // 讀取要求寄送連結的 rate limit
+
+		MagicLinkMinResponse: time.Duration(v.GetInt("MAGIC_LINK_MIN_RESPONSE_MS")) * time.Millisecond, // 將毫秒轉成 time.Duration
+
+		PasswordResetTokenTTL:  time.Duration(v.GetInt("PASSWORD_RESET_TOKEN_TTL_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+		PasswordResetURL:       v.GetString("PASSWORD_RESET_URL"),                                         // 讀取重設頁面位址
+		PasswordResetRateLimit: v.GetInt("PASSWORD_RESET_RATE_LIMIT"),                                     // 讀取重設密碼的 rate limit
+
+		UsernameCheckRateLimit:   v.GetInt("USERNAME_CHECK_RATE_LIMIT"),                                        // 讀取 username 查詢的 rate limit
+		UsernameCheckMinResponse: time.Duration(v.GetInt("USERNAME_CHECK_MIN_RESPONSE_MS")) * time.Millisecond, // 將毫秒轉成 time.Duration
+
+		SessionStatusRateLimit: v.GetInt("SESSION_STATUS_RATE_LIMIT"), // 讀取 session 狀態查詢的 rate limit
+
+		LoginMinResponse: time.Duration(v.GetInt("LOGIN_MIN_RESPONSE_MS")) * time.Millisecond, // 將毫秒轉成 time.Duration
+		LogoutBodyToken:  v.GetBool("LOGOUT_BODY_TOKEN"),                                      // 讀取 logout 是否接受 body token
+
+		LoginRateLimit:       v.GetInt("LOGIN_RATE_LIMIT"),                                             // 讀取單一使用者的登入次數上限
+		LoginRateLimitWindow: time.Duration(v.GetInt("LOGIN_RATE_LIMIT_WINDOW_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		ReservedUsernames: getList(v, "RESERVED_USERNAMES"), // 拆解逗號分隔的保留 username
+
+		TokenExchangeScopes:    getList(v, "TOKEN_EXCHANGE_SCOPES"),                                 // 拆解逗號分隔的可換發 scope
+		TokenExchangeAudiences: getList(v, "TOKEN_EXCHANGE_AUDIENCES"),                              // 拆解逗號分隔的下游 audience
+		TokenExchangeTTL:       time.Duration(v.GetInt("TOKEN_EXCHANGE_TTL_SECONDS")) * time.Second, // 將秒數轉成 time.Duration
+
+		MaxTokenScopes:      v.GetInt("MAX_TOKEN_SCOPES"),         // 讀取 token 的 scope 上限
+		TokenScopesOverflow: v.GetString("TOKEN_SCOPES_OVERFLOW"), // 讀取超過上限時的處理方式
+
+		ValidateBatchMaxTokens: v.GetInt("VALIDATE_BATCH_MAX_TOKENS"), // 讀取批次驗證的 token 數上限
+
+		OAuthAllowedRedirects: getList(v, "OAUTH_ALLOWED_REDIRECTS"), // 拆解逗號分隔的跳轉目標
+
+		HeaderContentTypeOptions: v.GetString("SECURITY_CONTENT_TYPE_OPTIONS"), // 讀取 X-Content-Type-Options
+		HeaderFrameOptions:       v.GetString("SECURITY_FRAME_OPTIONS"),        // 讀取 X-Frame-Options
+		HeaderReferrerPolicy:     v.GetString("SECURITY_REFERRER_POLICY"),      // 讀取 Referrer-Policy
+		HeaderHSTS:               v.GetString("SECURITY_HSTS"),                 // 讀取 Strict-Transport-Security
+		HeaderCSP:                v.GetString("SECURITY_CSP"),                  // 讀取 Content-Security-Policy
+
+		TrustedProxies: getList(v, "TRUSTED_PROXIES"), // 拆解逗號分隔的受信任 proxy
+		ForceHTTPS:     v.GetBool("FORCE_HTTPS"),      // 讀取是否強制 HTTPS
+	}
+
+	// Asynq 未指定獨立 Redis 時，完整沿用 session Redis 的連線設定 // 只有在明確設定 ASYNQ_REDIS_DB 時才保留其 DB 編號，方便同一台 Redis 以不同 DB 分流
+	if cfg.AsynqRedisAddr == "" {
+		cfg.AsynqRedisAddr = cfg.RedisAddr         // 沿用 session Redis 位址
+		cfg.AsynqRedisPassword = cfg.RedisPassword // 沿用 session Redis 密碼
+		if !v.IsSet("ASYNQ_REDIS_DB") {
+			cfg.AsynqRedisDB = cfg.RedisDB // 沿用 session Redis DB 編號
+		}
+	}
+
+	// 敏感設定改向 SecretProvider 查詢，secrets manager 無法連線時直接啟動失敗 // provider 沒有的 key 保留上面讀到的值
+	if provider == nil {
+		p, err := newSecretProvider(v)
+		if err != nil {
+			return nil, err
+		}
+		provider = p
+	}
+	if err := applySecrets(context.Background(), cfg, provider); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// getList 讀取清單設定：環境變數與 .env 為逗號分隔字串，設定檔中也可寫成陣列。
+func getList(v *viper.Viper, key string) []string {
+	switch v.Get(key).(type) {
+	case []interface{}, []string:
+		return splitList(strings.Join(v.GetStringSlice(key), ",")) // 陣列同樣去掉空白與空項目
+	default:
+		return splitList(v.GetString(key))
+	}
+}
+
+// getIntMap 讀取 "key=value" 清單設定：設定檔中也可寫成物件，例如 {mobile: 1, web: 2}。
+func getIntMap(v *viper.Viper, key string) map[string]int {
+	m, ok := v.Get(key).(map[string]interface{})
+	if !ok {
+		return parseIntMap(v.GetString(key))
+	}
+	items := make([]string, 0, len(m))
+	for k, val := range m {
+		items = append(items, fmt.Sprintf("%s=%v", k, val)) // 轉回字串格式，沿用同一套解析與正規化
+	}
+	return parseIntMap(strings.Join(items, ","))
+}
+
+// splitList 將逗號分隔的字串拆成 slice，並去掉空白與空項目。
+func splitList(raw string) []string {
+	var out []string                               // 收集拆解後的項目
+	for _, item := range strings.Split(raw, ",") { // 以逗號拆開
+		item = strings.TrimSpace(item) // 去掉前後空白
+		if item != "" {
+			out = append(out, item) // 忽略空項目
+		}
+	}
+	return out
+}
+
+// upperList 將清單項目統一轉成大寫，讓國碼等設定不分大小寫。
+func upperList(items []string) []string {
+	for i, item := range items {
+		items[i] = strings.ToUpper(item) // 轉成大寫
+	}
+	return items
+}
+
+// secondsMap 將以秒為單位的 map 轉成 time.Duration，輸入為 nil 時回傳 nil。
+func secondsMap(m map[string]int) map[string]time.Duration {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]time.Duration, len(m))
+	for k, n := range m {
+		out[k] = time.Duration(n) * time.Second // 將秒數轉成 time.Duration
+	}
+	return out
+}
+
+// parseIntMap 將 "key=value,key=value" 格式的字串拆成 map（分隔也可寫成 "key:value"），忽略格式錯誤或數值無法解析的項目。
+func parseIntMap(raw string) map[string]int {
+	var out map[string]int                // 沒有任何有效項目時維持 nil
+	for _, item := range splitList(raw) { // 先依逗號拆開
+		key, val, ok := strings.Cut(item, "=") // 以等號分出 key 與 value
+		if !ok {
+			key, val, ok = strings.Cut(item, ":") // 沒有等號時改以冒號分隔
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(val))
+		if !ok || err != nil || strings.TrimSpace(key) == "" {
+			continue // 格式錯誤的項目直接略過
+		}
+		if out == nil {
+			out = make(map[string]int) // 第一個有效項目時才建立 map
+		}
+		out[strings.ToLower(strings.TrimSpace(key))] = n // key 統一小寫
 	}
+	return out
 }
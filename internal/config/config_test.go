@@ -0,0 +1,246 @@
+package config
+
+import (
+	"os"            // 匯入 os，寫出測試用設定檔
+	"path/filepath" // 匯入 path/filepath，組出暫存目錄下的檔案路徑
+	"testing"       // 匯入 testing 套件，提供單元測試框架
+	"time"          // 匯入 time，比對轉換後的存活時間
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，用於撰寫斷言
+)
+
+// TestLoadAsynqRedisFallsBackToSessionRedis 測試未設定 ASYNQ_REDIS_ADDR 時，Asynq 沿用 session Redis 的連線設定。
+func TestLoadAsynqRedisFallsBackToSessionRedis(t *testing.T) {
+	t.Setenv("REDIS_ADDR", "redis-sessions:6379") // 設定 session Redis 位址
+	t.Setenv("REDIS_PASSWORD", "s3cret")          // 設定 session Redis 密碼
+	t.Setenv("REDIS_DB", "2")                     // 設定 session Redis DB
+
+	cfg, err := Load()      // 載入設定
+	require.NoError(t, err) // 設定應通過檢查
+
+	require.Equal(t, "redis-sessions:6379", cfg.AsynqRedisAddr) // Asynq 位址應沿用 session Redis
+	require.Equal(t, "s3cret", cfg.AsynqRedisPassword)          // 密碼也應沿用
+	require.Equal(t, 2, cfg.AsynqRedisDB)                       // DB 編號也應沿用
+}
+
+// TestLoadAsynqRedisSeparateInstance 測試設定 ASYNQ_REDIS_ADDR 後，Asynq 使用獨立的 Redis 設定。
+func TestLoadAsynqRedisSeparateInstance(t *testing.T) {
+	t.Setenv("REDIS_ADDR", "redis-sessions:6379")    // 設定 session Redis 位址
+	t.Setenv("REDIS_DB", "2")                        // 設定 session Redis DB
+	t.Setenv("ASYNQ_REDIS_ADDR", "redis-queue:6379") // 設定獨立的 Asynq Redis
+	t.Setenv("ASYNQ_REDIS_DB", "5")                  // 設定 Asynq Redis DB
+
+	cfg, err := Load()      // 載入設定
+	require.NoError(t, err) // 設定應通過檢查
+
+	require.Equal(t, "redis-sessions:6379", cfg.RedisAddr)   // session Redis 不受影響
+	require.Equal(t, 2, cfg.RedisDB)                         // session Redis DB 不受影響
+	require.Equal(t, "redis-queue:6379", cfg.AsynqRedisAddr) // Asynq 使用獨立位址
+	require.Equal(t, 5, cfg.AsynqRedisDB)                    // Asynq 使用獨立 DB
+}
+
+// TestLoadAsynqRedisSameInstanceDifferentDB 測試只設定 ASYNQ_REDIS_DB 時，沿用 session Redis 位址但使用不同 DB。
+func TestLoadAsynqRedisSameInstanceDifferentDB(t *testing.T) {
+	t.Setenv("REDIS_ADDR", "redis-sessions:6379") // 設定 session Redis 位址
+	t.Setenv("ASYNQ_REDIS_DB", "1")               // 只指定 Asynq DB
+
+	cfg, err := Load()      // 載入設定
+	require.NoError(t, err) // 設定應通過檢查
+
+	require.Equal(t, "redis-sessions:6379", cfg.AsynqRedisAddr) // 位址沿用 session Redis
+	require.Equal(t, 1, cfg.AsynqRedisDB)                       // DB 使用明確設定的值
+}
+
+// TestLoadReservedUsernames 測試 RESERVED_USERNAMES 以逗號拆解，並忽略空白與空項目。
+func TestLoadReservedUsernames(t *testing.T) {
+	t.Setenv("RESERVED_USERNAMES", " admin, Root ,,ops") // 含空白與空項目
+
+	cfg, err := Load()      // 載入設定
+	require.NoError(t, err) // 設定應通過檢查
+
+	require.Equal(t, []string{"admin", "Root", "ops"}, cfg.ReservedUsernames) // 正規化留給比對時處理
+}
+
+// TestLoadMaxSessionsPerDevice 測試 MAX_SESSIONS_PER_DEVICE 拆成類別上限，並略過格式錯誤的項目。
+func TestLoadMaxSessionsPerDevice(t *testing.T) {
+	t.Setenv("MAX_SESSIONS_PER_DEVICE", " Mobile=1, web = 2 ,tablet,other=x") // 含大小寫、空白與錯誤項目
+
+	cfg, err := Load()      // 載入設定
+	require.NoError(t, err) // 設定應通過檢查
+
+	require.Equal(t, map[string]int{"mobile": 1, "web": 2}, cfg.MaxSessionsPerDevice) // 只保留有效項目
+}
+
+// TestLoadRoleSessionTTL 測試 ROLE_SESSION_TTL 以 "role:seconds" 格式拆成角色存活時間，非正數時啟動失敗。
+func TestLoadRoleSessionTTL(t *testing.T) {
+	t.Setenv("ROLE_SESSION_TTL", "Admin:900, user:3600") // 含大小寫與空白
+
+	cfg, err := Load()      // 載入設定
+	require.NoError(t, err) // 設定應通過檢查
+
+	require.Equal(t, map[string]time.Duration{"admin": 15 * time.Minute, "user": time.Hour}, cfg.RoleSessionTTL) // 角色轉小寫、秒數轉成 Duration
+
+	t.Setenv("ROLE_SESSION_TTL", "admin:0")           // 存活時間為 0
+	_, err = Load()                                   // 載入設定
+	require.ErrorContains(t, err, "ROLE_SESSION_TTL") // 應指出錯誤的 key
+}
+
+// writeConfigFile 在暫存目錄寫出設定檔並設定 CONFIG_FILE 指向它。
+func writeConfigFile(t *testing.T, name, content string) {
+	t.Helper()                                                     // 標記為測試輔助函式
+	path := filepath.Join(t.TempDir(), name)                       // 暫存目錄下的設定檔路徑
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600)) // 寫出設定檔
+	t.Setenv("CONFIG_FILE", path)                                  // 指向該設定檔
+}
+
+// TestLoadYAMLConfigFile 測試從 YAML 設定檔讀取設定，清單與物件格式都能對應到既有欄位。
+func TestLoadYAMLConfigFile(t *testing.T) {
+	writeConfigFile(t, "config.yaml", `
+APP_HTTP_ADDR: ":9000"
+redis_addr: "redis-yaml:6379"
+MAX_SESSIONS_PER_USER: 5
+SESSION_ID_ENCODING: base62
+RESERVED_USERNAMES: [admin, " ops "]
+OAUTH_ALLOWED_REDIRECTS: "https://app.example.com/*"
+MAX_SESSIONS_PER_DEVICE:
+  Mobile: 1
+  web: 3
+`) // key 大小寫皆可
+
+	cfg, err := Load()      // 載入設定
+	require.NoError(t, err) // 設定應通過檢查
+
+	require.Equal(t, ":9000", cfg.HTTPAddr)                                            // 讀取字串
+	require.Equal(t, "redis-yaml:6379", cfg.RedisAddr)                                 // 小寫 key 同樣對應
+	require.Equal(t, "redis-yaml:6379", cfg.AsynqRedisAddr)                            // 沿用 session Redis 的邏輯不變
+	require.Equal(t, 5, cfg.MaxSessionsPerUser)                                        // 讀取數值
+	require.Equal(t, "base62", cfg.SessionIDEncoding)                                  // 讀取列舉值
+	require.Equal(t, []string{"admin", "ops"}, cfg.ReservedUsernames)                  // 陣列格式的清單
+	require.Equal(t, []string{"https://app.example.com/*"}, cfg.OAuthAllowedRedirects) // 字串格式的清單
+	require.Equal(t, map[string]int{"mobile": 1, "web": 3}, cfg.MaxSessionsPerDevice)  // 物件格式的類別上限
+	require.Equal(t, "evict_oldest", cfg.PinnedLimitPolicy)                            // 未設定的 key 使用預設值
+}
+
+// TestLoadEnvOverridesConfigFile 測試環境變數優先於 JSON 設定檔。
+func TestLoadEnvOverridesConfigFile(t *testing.T) {
+	writeConfigFile(t, "config.json", `{"APP_HTTP_ADDR": ":9000", "REDIS_DB": 3, "TOKEN_EXCHANGE_AUDIENCES": ["billing", "search"]}`) // JSON 設定檔
+	t.Setenv("APP_HTTP_ADDR", ":9100")                                                                                                // 環境變數覆寫其中一個 key
+	t.Setenv("TOKEN_EXCHANGE_AUDIENCES", "reports")                                                                                   // 清單同樣可被覆寫
+
+	cfg, err := Load()      // 載入設定
+	require.NoError(t, err) // 設定應通過檢查
+
+	require.Equal(t, ":9100", cfg.HTTPAddr)                           // 環境變數優先
+	require.Equal(t, 3, cfg.RedisDB)                                  // 未覆寫的 key 沿用設定檔
+	require.Equal(t, []string{"reports"}, cfg.TokenExchangeAudiences) // 清單以環境變數為準
+}
+
+// TestLoadConfigFileErrors 測試設定檔不存在或合併後的設定不合法時回傳錯誤。
+func TestLoadConfigFileErrors(t *testing.T) {
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml")) // 指向不存在的檔案
+	_, err := Load()                                                    // 載入設定
+	require.Error(t, err)                                               // 應回傳錯誤
+
+	writeConfigFile(t, "config.yaml", "METRICS_MODE: public\nBCRYPT_COST: 2\n") // 不合法的值
+	_, err = Load()                                                             // 載入設定
+	require.ErrorContains(t, err, "METRICS_MODE")                               // 應指出錯誤的 key
+	require.ErrorContains(t, err, "BCRYPT_COST")                                // 所有問題一併回報
+}
+
+// TestValidateJWTSecretMinLength 測試 APP_JWT_SECRET 短於 JWT_MIN_SECRET_BYTES 時啟動失敗。
+func TestValidateJWTSecretMinLength(t *testing.T) {
+	t.Setenv("APP_JWT_SECRET", "too-short-secret") // 16 bytes，低於預設的 32 bytes
+
+	_, err := Load()                                   // 載入設定
+	require.ErrorContains(t, err, "at least 32 bytes") // 應指出最短長度
+
+	t.Setenv("JWT_MIN_SECRET_BYTES", "16")      // 調低最短長度
+	cfg, err := Load()                          // 重新載入
+	require.NoError(t, err)                     // 剛好達到門檻即可啟動
+	require.Equal(t, 16, cfg.JWTMinSecretBytes) // 讀到設定的門檻
+}
+
+// TestValidateRejectsDevJWTSecretInProduction 測試 production 環境不允許沿用開發預設密鑰，開發環境則照常啟動。
+func TestValidateRejectsDevJWTSecretInProduction(t *testing.T) {
+	cfg, err := Load()                                                   // 未設定任何密鑰，使用開發預設值
+	require.NoError(t, err)                                              // 開發環境可以啟動
+	require.Equal(t, DevJWTSecret, cfg.JWTSecret)                        // 預設值即開發密鑰
+	require.GreaterOrEqual(t, len(cfg.JWTSecret), cfg.JWTMinSecretBytes) // 預設值本身滿足長度要求
+
+	t.Setenv("APP_ENV", "production")                    // 切換到正式環境
+	_, err = Load()                                      // 重新載入
+	require.ErrorContains(t, err, "development default") // 開發密鑰被拒絕
+
+	t.Setenv("JWT_MIN_SECRET_BYTES", "8")                // 舊的 .env.example 密鑰較短，先排除長度檢查
+	t.Setenv("APP_JWT_SECRET", "dev-secret-change-me")   // 舊版 .env.example 的密鑰
+	_, err = Load()                                      // 重新載入
+	require.ErrorContains(t, err, "development default") // 同樣被拒絕
+
+	t.Setenv("APP_JWT_SECRET", "prod-7fK2pQ9xLm4vR8sT1wY6zB3nC5hJ0dG") // 正式環境自訂的密鑰
+	t.Setenv("ADMIN_API_KEY", "prod-admin-Qm3xV8rT2kLp")               // 正式環境自訂的 admin key
+	cfg, err = Load()                                                  // 重新載入
+	require.NoError(t, err)                                            // 可以啟動
+	require.Equal(t, "production", cfg.AppEnv)                         // 讀到執行環境
+}
+
+// TestValidateRejectsUnknownAppEnv 測試 APP_ENV 只接受 development 與 production。
+func TestValidateRejectsUnknownAppEnv(t *testing.T) {
+	t.Setenv("APP_ENV", "prod")              // 常見的拼寫
+	_, err := Load()                         // 載入設定
+	require.ErrorContains(t, err, "APP_ENV") // 應指出 APP_ENV 不合法
+}
+
+// TestTrustedProxiesAndForceHTTPS 測試受信任 proxy 清單的預設值、覆寫與格式檢查。
+func TestTrustedProxiesAndForceHTTPS(t *testing.T) {
+	cfg, err := Load()                                                  // 使用預設值載入
+	require.NoError(t, err)                                             // 應成功
+	require.Equal(t, []string{"0.0.0.0/0", "::/0"}, cfg.TrustedProxies) // 預設與 Gin 相同，信任所有來源
+	require.False(t, cfg.ForceHTTPS)                                    // 預設不強制 HTTPS
+
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.10")                      // 只信任內網 proxy
+	t.Setenv("FORCE_HTTPS", "true")                                              // 強制 HTTPS
+	cfg, err = Load()                                                            // 重新載入
+	require.NoError(t, err)                                                      // 應成功
+	require.Equal(t, []string{"10.0.0.0/8", "192.168.1.10"}, cfg.TrustedProxies) // 拆解後去掉空白
+	require.True(t, cfg.ForceHTTPS)                                              // 讀到強制 HTTPS
+
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/33")       // 不合法的 CIDR
+	_, err = Load()                                  // 重新載入
+	require.ErrorContains(t, err, "TRUSTED_PROXIES") // 應指出 TRUSTED_PROXIES 不合法
+}
+
+// TestValidateSignedLoginRequiresUsers 測試設定 SIGNED_LOGIN_SECRET 時必須同時列出允許的 machine 帳號。
+func TestValidateSignedLoginRequiresUsers(t *testing.T) {
+	t.Setenv("SIGNED_LOGIN_SECRET", "m2m-secret")       // 開放 signed login
+	_, err := Load()                                    // 載入設定
+	require.ErrorContains(t, err, "SIGNED_LOGIN_USERS") // 未列出帳號應啟動失敗
+
+	t.Setenv("SIGNED_LOGIN_USERS", "robot, ci-bot")                     // 列出 machine 帳號
+	cfg, err := Load()                                                  // 重新載入
+	require.NoError(t, err)                                             // 可以啟動
+	require.Equal(t, []string{"robot", "ci-bot"}, cfg.SignedLoginUsers) // 拆成帳號清單
+}
+
+// TestValidateRejectsDevAdminAPIKeyInProduction 測試正式環境不接受開發預設或空白的 admin key。
+func TestValidateRejectsDevAdminAPIKeyInProduction(t *testing.T) {
+	t.Setenv("APP_ENV", "production")                                  // 正式環境
+	t.Setenv("APP_JWT_SECRET", "prod-7fK2pQ9xLm4vR8sT1wY6zB3nC5hJ0dG") // 排除 JWT 密鑰檢查
+
+	_, err := Load()                                     // 未設定 ADMIN_API_KEY，沿用開發預設值
+	require.ErrorContains(t, err, "ADMIN_API_KEY")       // 開發預設 key 被拒絕
+	require.ErrorContains(t, err, "development default") // 錯誤訊息指出原因
+
+	t.Setenv("ADMIN_API_KEY", "prod-admin-Qm3xV8rT2kLp") // 新的 admin key
+	t.Setenv("ADMIN_API_KEY_PREVIOUS", DevAdminAPIKey)   // 輪替中的舊 key 仍是開發預設值
+	_, err = Load()                                      // 重新載入
+	require.ErrorContains(t, err, "development default") // 舊 key 也被拒絕
+
+	t.Setenv("ADMIN_API_KEY_PREVIOUS", "") // 移除舊 key
+	cfg, err := Load()                     // 重新載入
+	require.NoError(t, err)                // 可以啟動
+
+	cfg.AdminAPIKey = ""                                          // 空白 key（例如 secrets manager 回傳空值）會讓 admin API 不驗證
+	require.ErrorContains(t, cfg.Validate(), "must not be empty") // 正式環境拒絕
+	cfg.AppEnv = "development"                                    // 開發環境
+	require.NoError(t, cfg.Validate())                            // 允許空白 key
+}
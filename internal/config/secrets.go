@@ -0,0 +1,136 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// SECRETS_PROVIDER 可用的值。
+const (
+	SecretsProviderEnv  = "env"
+	SecretsProviderHTTP = "http"
+)
+
+// ErrSecretNotFound 表示 provider 沒有保存這個 key，Load 會改用環境變數 / 設定檔中的值。
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretProvider 提供敏感設定值（APP_JWT_SECRET、ADMIN_API_KEY），key 與環境變數名稱相同。
+type SecretProvider interface {
+	Secret(ctx context.Context, key string) (string, error)
+}
+
+// providedSecrets 是 Load 向 SecretProvider 查詢的 key 與其在 Config 中的欄位。
+func providedSecrets(cfg *Config) map[string]*string {
+	return map[string]*string{
+		"APP_JWT_SECRET":         &cfg.JWTSecret,
+		"ADMIN_API_KEY":          &cfg.AdminAPIKey,
+		"ADMIN_API_KEY_PREVIOUS": &cfg.AdminAPIKeyPrevious,
+	}
+}
+
+// applySecrets 以 provider 的值覆寫敏感設定；ErrSecretNotFound 時保留原本的值，其他錯誤則讓啟動失敗。
+func applySecrets(ctx context.Context, cfg *Config, provider SecretProvider) error {
+	for key, field := range providedSecrets(cfg) {
+		value, err := provider.Secret(ctx, key)
+		if errors.Is(err, ErrSecretNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("load secret %s: %w", key, err)
+		}
+		*field = value
+	}
+	return nil
+}
+
+// envSecretProvider 是預設的 provider，直接讀 viper 合併後的值（環境變數、CONFIG_FILE、.env、預設值）。
+type envSecretProvider struct {
+	v *viper.Viper
+}
+
+func (p envSecretProvider) Secret(_ context.Context, key string) (string, error) {
+	return p.v.GetString(key), nil
+}
+
+// newSecretProvider 依 SECRETS_PROVIDER 建立 provider。
+func newSecretProvider(v *viper.Viper) (SecretProvider, error) {
+	switch name := v.GetString("SECRETS_PROVIDER"); name {
+	case "", SecretsProviderEnv:
+		return envSecretProvider{v: v}, nil
+	case SecretsProviderHTTP:
+		endpoint := v.GetString("SECRETS_URL")
+		if endpoint == "" {
+			return nil, errors.New("SECRETS_URL is required when SECRETS_PROVIDER=http")
+		}
+		timeout := time.Duration(v.GetInt("SECRETS_TIMEOUT_MS")) * time.Millisecond
+		return NewHTTPSecretProvider(endpoint, v.GetString("SECRETS_TOKEN"), &http.Client{Timeout: timeout}), nil
+	default:
+		return nil, fmt.Errorf("SECRETS_PROVIDER must be one of %q, got %q", []string{SecretsProviderEnv, SecretsProviderHTTP}, name)
+	}
+}
+
+// HTTPSecretProvider 在啟動時向 secrets manager 的 HTTP 端點取回密鑰，例如 Vault agent 或 AWS Secrets Manager 的本機 proxy。
+// 以 GET {endpoint}/{key} 查詢並帶上 Bearer token，回應格式為 {"value": "..."}，404 代表沒有這個 key。
+// 取回的值快取在記憶體中，同一個 provider 對同一個 key 只查詢一次。
+type HTTPSecretProvider struct {
+	endpoint string
+	token    string
+	client   *http.Client
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+func NewHTTPSecretProvider(endpoint, token string, client *http.Client) *HTTPSecretProvider {
+	return &HTTPSecretProvider{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		token:    token,
+		client:   client,
+		cache:    make(map[string]string),
+	}
+}
+
+func (p *HTTPSecretProvider) Secret(ctx context.Context, key string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if value, ok := p.cache[key]; ok {
+		return value, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint+"/"+url.PathEscape(key), nil)
+	if err != nil {
+		return "", err
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", ErrSecretNotFound
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("secrets endpoint returned %s", resp.Status)
+	}
+	var body struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode secret: %w", err)
+	}
+	p.cache[key] = body.Value
+	return body.Value, nil
+}
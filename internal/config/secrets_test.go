@@ -0,0 +1,96 @@
+package config
+
+import (
+	"context"           // 匯入 context，實作測試用 provider
+	"errors"            // 匯入 errors，模擬 provider 失敗
+	"net/http"          // 匯入 net/http，撰寫假的 secrets manager
+	"net/http/httptest" // 匯入 httptest，啟動假的 secrets manager
+	"testing"           // 匯入 testing 套件，提供單元測試框架
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，用於撰寫斷言
+)
+
+// mockSecretProvider 是以 map 提供密鑰的測試用 provider，沒有的 key 回傳 ErrSecretNotFound。
+type mockSecretProvider struct {
+	secrets map[string]string
+	err     error
+}
+
+func (m mockSecretProvider) Secret(_ context.Context, key string) (string, error) {
+	if m.err != nil {
+		return "", m.err // 模擬 secrets manager 無法連線
+	}
+	value, ok := m.secrets[key]
+	if !ok {
+		return "", ErrSecretNotFound // provider 沒有保存這個 key
+	}
+	return value, nil
+}
+
+// TestLoadWithSecretsUsesProvider 測試 provider 提供的密鑰覆寫環境變數，provider 沒有的 key 保留原值。
+func TestLoadWithSecretsUsesProvider(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "admin-from-env") // 環境變數中的 admin key
+
+	provider := mockSecretProvider{secrets: map[string]string{
+		"APP_JWT_SECRET": "jwt-secret-from-vault-0123456789abcdef", // 只提供 JWT 密鑰
+	}}
+	cfg, err := LoadWithSecrets(provider) // 以 mock provider 載入
+	require.NoError(t, err)               // 設定應通過檢查
+
+	require.Equal(t, "jwt-secret-from-vault-0123456789abcdef", cfg.JWTSecret) // 使用 provider 的密鑰
+	require.Equal(t, "admin-from-env", cfg.AdminAPIKey)                       // provider 沒有的 key 沿用環境變數
+}
+
+// TestLoadWithSecretsValidatesProvidedSecret 測試 provider 的值同樣經過 Validate，失敗時 Load 回傳錯誤。
+func TestLoadWithSecretsValidatesProvidedSecret(t *testing.T) {
+	_, err := LoadWithSecrets(mockSecretProvider{secrets: map[string]string{"APP_JWT_SECRET": "short"}}) // 太短的密鑰
+	require.ErrorContains(t, err, "APP_JWT_SECRET must be at least")                                     // 長度檢查仍然生效
+
+	_, err = LoadWithSecrets(mockSecretProvider{err: errors.New("connection refused")}) // provider 無法連線
+	require.ErrorContains(t, err, "connection refused")                                 // 啟動失敗而不是退回預設值
+}
+
+// TestLoadHTTPSecretProvider 測試 SECRETS_PROVIDER=http 時向端點查詢密鑰並帶上 token，同一個 key 只查詢一次。
+func TestLoadHTTPSecretProvider(t *testing.T) {
+	hits := map[string]int{} // 各 key 被查詢的次數
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer vault-token" {
+			w.WriteHeader(http.StatusForbidden) // 沒帶 token 拒絕
+			return
+		}
+		hits[r.URL.Path]++ // 記錄查詢次數
+		switch r.URL.Path {
+		case "/v1/session-service/APP_JWT_SECRET":
+			_, _ = w.Write([]byte(`{"value":"jwt-secret-from-http-0123456789abcdef"}`)) // 回傳 JWT 密鑰
+		case "/v1/session-service/ADMIN_API_KEY":
+			_, _ = w.Write([]byte(`{"value":"admin-from-http"}`)) // 回傳 admin key
+		default:
+			w.WriteHeader(http.StatusNotFound) // 其他 key 不存在
+		}
+	}))
+	defer srv.Close() // 測試結束時關閉
+
+	t.Setenv("SECRETS_PROVIDER", "http")                    // 改用 HTTP provider
+	t.Setenv("SECRETS_URL", srv.URL+"/v1/session-service/") // 結尾的斜線會被去掉
+	t.Setenv("SECRETS_TOKEN", "vault-token")                // secrets manager 的 token
+
+	cfg, err := Load()                                                       // 載入設定
+	require.NoError(t, err)                                                  // 設定應通過檢查
+	require.Equal(t, "jwt-secret-from-http-0123456789abcdef", cfg.JWTSecret) // 使用端點回傳的密鑰
+	require.Equal(t, "admin-from-http", cfg.AdminAPIKey)                     // admin key 也由端點提供
+
+	provider := NewHTTPSecretProvider(srv.URL+"/v1/session-service", "vault-token", srv.Client()) // 直接建立 provider
+	for i := 0; i < 3; i++ {
+		value, err := provider.Secret(context.Background(), "APP_JWT_SECRET") // 重複查詢
+		require.NoError(t, err)                                               // 應成功
+		require.Equal(t, "jwt-secret-from-http-0123456789abcdef", value)      // 值不變
+	}
+	require.Equal(t, 2, hits["/v1/session-service/APP_JWT_SECRET"]) // Load 一次加上 provider 一次，之後都用快取
+
+	_, err = provider.Secret(context.Background(), "UNKNOWN") // 不存在的 key
+	require.ErrorIs(t, err, ErrSecretNotFound)                // 回傳 ErrSecretNotFound
+
+	t.Setenv("SECRETS_TOKEN", "wrong-token") // 錯誤的 token
+	_, err = Load()                          // 重新載入
+	require.ErrorContains(t, err, "403")     // 啟動失敗
+}
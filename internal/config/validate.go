@@ -0,0 +1,153 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// bcrypt 允許的 cost 範圍（與 golang.org/x/crypto/bcrypt 的 MinCost / MaxCost 相同）。
+const (
+	minBcryptCost = 4
+	maxBcryptCost = 31
+)
+
+// DevJWTSecret 是 APP_JWT_SECRET 的開發預設值，APP_ENV=production 時不允許使用。
+const DevJWTSecret = "dev-secret-change-me-before-production"
+
+// knownDevJWTSecrets 是曾出現在預設值或 .env.example 中的密鑰，任何人都查得到，正式環境一律拒絕。
+var knownDevJWTSecrets = []string{DevJWTSecret, "dev-secret-change-me"}
+
+// DevAdminAPIKey 是 ADMIN_API_KEY 的開發預設值，APP_ENV=production 時不允許使用。
+const DevAdminAPIKey = "dev-admin"
+
+// Validate 檢查設定值是否合理，回傳所有問題合併後的錯誤；設定檔或環境變數打錯字時在啟動階段就失敗，而不是執行到一半才出錯。
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	oneOf := func(key, val string, allowed ...string) {
+		for _, a := range allowed {
+			if val == a {
+				return
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s must be one of %q, got %q", key, allowed, val))
+	}
+
+	check(c.HTTPAddr != "", "APP_HTTP_ADDR must not be empty")
+	check(c.DBPath != "", "APP_DB_PATH must not be empty")
+	oneOf("APP_ENV", c.AppEnv, "development", "production")
+	check(c.JWTSecret != "", "APP_JWT_SECRET must not be empty")
+	check(c.JWTMinSecretBytes > 0, "JWT_MIN_SECRET_BYTES must be positive, got %d", c.JWTMinSecretBytes)
+	check(c.JWTSecret == "" || len(c.JWTSecret) >= c.JWTMinSecretBytes,
+		"APP_JWT_SECRET must be at least %d bytes, got %d", c.JWTMinSecretBytes, len(c.JWTSecret))
+	if c.AppEnv == "production" {
+		for _, dev := range knownDevJWTSecrets {
+			check(c.JWTSecret != dev, "APP_JWT_SECRET must not be the development default when APP_ENV=production")
+		}
+		// admin key 為空時 admin API 完全不驗證，只適合本機開發
+		check(c.AdminAPIKey != "", "ADMIN_API_KEY must not be empty when APP_ENV=production")
+		check(c.AdminAPIKey != DevAdminAPIKey && c.AdminAPIKeyPrevious != DevAdminAPIKey,
+			"ADMIN_API_KEY and ADMIN_API_KEY_PREVIOUS must not be the development default when APP_ENV=production")
+	}
+	check(c.AdminAPIKeyPrevious == "" || c.AdminAPIKey != "", "ADMIN_API_KEY_PREVIOUS requires ADMIN_API_KEY to be set")
+	check(c.AdminAPIKeyPrevious == "" || c.AdminAPIKeyPrevious != c.AdminAPIKey, "ADMIN_API_KEY_PREVIOUS must differ from ADMIN_API_KEY")
+	check(c.JWTMaxTokenLen > 0, "JWT_MAX_TOKEN_BYTES must be positive, got %d", c.JWTMaxTokenLen)
+	check(c.JWTLeeway >= 0 && c.JWTLeeway <= 5*time.Minute, "JWT_LEEWAY_SECONDS must be between 0 and 300")
+	check(c.RequestTimeout >= 0, "REQUEST_TIMEOUT_MS must not be negative")
+	check(c.LogSampleInterval >= 0, "LOG_SAMPLE_INTERVAL_SECONDS must not be negative")
+	check(c.SSEShutdownGrace > 0, "SSE_SHUTDOWN_GRACE_SECONDS must be positive")
+	check(c.RedisKeepAliveInterval >= 0, "REDIS_KEEPALIVE_INTERVAL_SECONDS must not be negative")
+	oneOf("OUTBOUND_TLS_MIN_VERSION", c.OutboundTLSMinVersion, "1.2", "1.3")
+	check(c.OutboundHTTPTimeout > 0, "OUTBOUND_HTTP_TIMEOUT_MS must be positive")
+	check(c.OutboundMaxConnsPerHost > 0, "OUTBOUND_HTTP_MAX_CONNS_PER_HOST must be positive, got %d", c.OutboundMaxConnsPerHost)
+
+	check(c.SessionTTL > 0, "SESSION_TTL_SECONDS must be positive")
+	for role, ttl := range c.RoleSessionTTL {
+		check(ttl > 0, "ROLE_SESSION_TTL for role %q must be positive", role)
+	}
+	check(c.MaxSessionsPerUser >= 0, "MAX_SESSIONS_PER_USER must not be negative, got %d", c.MaxSessionsPerUser)
+	check(c.MaxSessionsPerDeviceID >= 0, "MAX_SESSIONS_PER_DEVICE_ID must not be negative, got %d", c.MaxSessionsPerDeviceID)
+	check(c.MaxSessionLifetime >= 0, "MAX_SESSION_LIFETIME_SECONDS must not be negative")
+	check(c.RefreshFamilyMaxAge >= 0, "REFRESH_FAMILY_MAX_AGE_SECONDS must not be negative")
+	check(c.TrustedDeviceTTL >= 0, "TRUSTED_DEVICE_DAYS must not be negative")
+	check(c.RequestCountInterval >= 0, "SESSION_REQUEST_COUNT_INTERVAL_SECONDS must not be negative")
+	check(c.FeatureFlagsRefresh >= 0, "FEATURE_FLAGS_REFRESH_SECONDS must not be negative")
+	oneOf("SESSION_ID_ENCODING", c.SessionIDEncoding, "uuid", "base62")
+	oneOf("SESSION_RECORD_MODE", c.SessionRecordMode, "sync", "async")
+	oneOf("SESSION_MISSING_EXPIRY_POLICY", c.SessionMissingExpiryPolicy, "ttl", "reject")
+	oneOf("TOKEN_EXPIRY_POLICY", c.TokenExpiryPolicy, "clamp", "reject")
+	oneOf("TOKEN_MODE", c.TokenMode, "jwt", "opaque")
+	check(c.MaxTokenTTL >= 0, "MAX_TOKEN_TTL_SECONDS must not be negative")
+	check(c.MaxTokenScopes >= 0, "MAX_TOKEN_SCOPES must not be negative, got %d", c.MaxTokenScopes)
+	oneOf("TOKEN_SCOPES_OVERFLOW", c.TokenScopesOverflow, "reject", "group")
+	check(c.ValidateBatchMaxTokens > 0, "VALIDATE_BATCH_MAX_TOKENS must be positive, got %d", c.ValidateBatchMaxTokens)
+	oneOf("PINNED_SESSION_LIMIT_POLICY", c.PinnedLimitPolicy, "evict_oldest", "reject")
+	oneOf("EVICTION_ORDER", c.EvictionOrder, "created", "last_seen")
+	check(c.EvictionOrder != "last_seen" || c.LastSeenInterval > 0, "EVICTION_ORDER=last_seen requires SESSION_LAST_SEEN_INTERVAL_SECONDS to be positive")
+	check(c.SessionWarnThreshold >= 0 && c.SessionWarnThreshold <= 1, "SESSION_WARN_THRESHOLD must be between 0 and 1, got %v", c.SessionWarnThreshold)
+
+	check(c.BcryptCost >= minBcryptCost && c.BcryptCost <= maxBcryptCost,
+		"BCRYPT_COST must be between %d and %d, got %d", minBcryptCost, maxBcryptCost, c.BcryptCost)
+	check(c.BcryptMaxConcurrency >= 0, "BCRYPT_MAX_CONCURRENCY must not be negative, got %d", c.BcryptMaxConcurrency)
+	check(c.BcryptMaxConcurrency == 0 || c.BcryptQueueDepth >= 0, "BCRYPT_QUEUE_DEPTH must not be negative, got %d", c.BcryptQueueDepth)
+	check(c.BcryptMaxConcurrency == 0 || c.BcryptQueueTimeout > 0, "BCRYPT_QUEUE_TIMEOUT_MS must be positive when BCRYPT_MAX_CONCURRENCY is set")
+	check(c.LoginRateLimit >= 0, "LOGIN_RATE_LIMIT must not be negative, got %d", c.LoginRateLimit)
+	check(c.LoginRateLimit == 0 || c.LoginRateLimitWindow > 0, "LOGIN_RATE_LIMIT_WINDOW_SECONDS must be positive when LOGIN_RATE_LIMIT is set")
+	check(c.SignedLoginSecret == "" || len(c.SignedLoginUsers) > 0, "SIGNED_LOGIN_USERS must list the machine accounts allowed to use SIGNED_LOGIN_SECRET")
+	check(!c.MagicLinkEnabled || c.MagicLinkTTL > 0, "MAGIC_LINK_TTL_SECONDS must be positive when MAGIC_LINK_ENABLED is set")
+	check(!c.MagicLinkEnabled || c.MagicLinkURL != "", "MAGIC_LINK_URL must be set when MAGIC_LINK_ENABLED is set")
+	check(c.MagicLinkRateLimit >= 0, "MAGIC_LINK_RATE_LIMIT must not be negative, got %d", c.MagicLinkRateLimit)
+	check(c.MagicLinkMinResponse >= 0, "MAGIC_LINK_MIN_RESPONSE_MS must not be negative, got %s", c.MagicLinkMinResponse)
+	check(c.PasswordResetTokenTTL > 0, "PASSWORD_RESET_TOKEN_TTL_SECONDS must be positive")
+	check(c.PasswordResetRateLimit >= 0, "PASSWORD_RESET_RATE_LIMIT must not be negative, got %d", c.PasswordResetRateLimit)
+	check(c.SessionStatusRateLimit >= 0, "SESSION_STATUS_RATE_LIMIT must not be negative, got %d", c.SessionStatusRateLimit)
+	check(c.PasswordMinEntropyBits >= 0, "PASSWORD_MIN_ENTROPY_BITS must not be negative, got %d", c.PasswordMinEntropyBits)
+	check(c.PasswordMaxAge >= 0, "PASSWORD_MAX_AGE_DAYS must not be negative")
+
+	check(c.AsynqConcurrency > 0, "ASYNQ_CONCURRENCY must be positive, got %d", c.AsynqConcurrency)
+	check(c.WorkerShutdownTimeout > 0, "WORKER_SHUTDOWN_TIMEOUT_SECONDS must be positive")
+	check(c.BanResyncInterval >= 0, "BAN_RESYNC_INTERVAL_SECONDS must not be negative")
+	check(c.CountersSweepInterval >= 0, "COUNTERS_SWEEP_INTERVAL_SECONDS must not be negative")
+	check(c.SessionReconcileInterval >= 0, "SESSION_RECONCILE_INTERVAL_SECONDS must not be negative")
+	check(c.AuditBatchSize >= 0, "LOGIN_AUDIT_BATCH_SIZE must not be negative, got %d", c.AuditBatchSize)
+	check(c.AuditBatchSize == 0 || c.AuditBatchInterval > 0, "LOGIN_AUDIT_BATCH_INTERVAL_MS must be positive when batching is enabled")
+	check(len(c.AuditSinks) > 0, "AUDIT_SINK must list at least one sink")
+	for _, sink := range c.AuditSinks {
+		oneOf("AUDIT_SINK", sink, "sqlite", "file", "syslog")
+		check(sink != "file" || c.AuditFilePath != "", "AUDIT_FILE_PATH is required when AUDIT_SINK includes file")
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		check(validProxy(proxy), "TRUSTED_PROXIES entries must be an IP or CIDR, got %q", proxy)
+	}
+
+	for _, country := range append(slices.Clone(c.LoginCountryAllowlist), c.LoginCountryDenylist...) {
+		check(len(country) == 2, "LOGIN_COUNTRY_ALLOWLIST / LOGIN_COUNTRY_DENYLIST entries must be ISO 3166-1 alpha-2 codes, got %q", country)
+	}
+	oneOf("LOGIN_COUNTRY_UNKNOWN_POLICY", c.LoginCountryUnknownPolicy, "allow", "deny")
+
+	oneOf("METRICS_MODE", c.MetricsMode, "off", "listener", "admin")
+	oneOf("SIGNUP_CHALLENGE", c.SignupChallenge, "", "captcha", "pow")
+	check(c.SignupChallenge != "captcha" || c.CaptchaSecret != "", "CAPTCHA_SECRET is required when SIGNUP_CHALLENGE=captcha")
+	check(c.SignupChallenge != "pow" || (c.PoWDifficulty > 0 && c.PoWDifficulty <= 64),
+		"POW_DIFFICULTY must be between 1 and 64, got %d", c.PoWDifficulty)
+
+	return errors.Join(errs...)
+}
+
+// validProxy 回傳 TRUSTED_PROXIES 的項目是否為合法的 IP 或 CIDR。
+func validProxy(entry string) bool {
+	if strings.Contains(entry, "/") {
+		_, _, err := net.ParseCIDR(entry)
+		return err == nil
+	}
+	return net.ParseIP(entry) != nil
+}
@@ -10,6 +10,26 @@ import (
 	"database/sql"
 )
 
+const countFailedLoginsSince = `-- name: CountFailedLoginsSince :one
+SELECT COUNT(*)
+FROM login_events
+WHERE username = ?1
+  AND success = 0
+  AND created_at >= datetime(?2)
+`
+
+type CountFailedLoginsSinceParams struct {
+	Username sql.NullString `json:"username"`
+	Since    interface{}    `json:"since"`
+}
+
+func (q *Queries) CountFailedLoginsSince(ctx context.Context, arg CountFailedLoginsSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countFailedLoginsSince, arg.Username, arg.Since)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const insertLoginEvent = `-- name: InsertLoginEvent :exec
 INSERT INTO login_events (
     user_id,
@@ -48,3 +68,109 @@ func (q *Queries) InsertLoginEvent(ctx context.Context, arg InsertLoginEventPara
 	)
 	return err
 }
+
+const listFailedLoginIPsSince = `-- name: ListFailedLoginIPsSince :many
+SELECT DISTINCT ip
+FROM login_events
+WHERE username = ?1
+  AND success = 0
+  AND created_at >= datetime(?2)
+  AND ip IS NOT NULL
+ORDER BY ip
+`
+
+type ListFailedLoginIPsSinceParams struct {
+	Username sql.NullString `json:"username"`
+	Since    interface{}    `json:"since"`
+}
+
+func (q *Queries) ListFailedLoginIPsSince(ctx context.Context, arg ListFailedLoginIPsSinceParams) ([]sql.NullString, error) {
+	rows, err := q.db.QueryContext(ctx, listFailedLoginIPsSince, arg.Username, arg.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []sql.NullString
+	for rows.Next() {
+		var ip sql.NullString
+		if err := rows.Scan(&ip); err != nil {
+			return nil, err
+		}
+		items = append(items, ip)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLoginEventsByUser = `-- name: ListLoginEventsByUser :many
+SELECT
+    id,
+    user_id,
+    username,
+    success,
+    reason,
+    ip,
+    user_agent,
+    created_at,
+    country
+FROM login_events
+WHERE user_id = ?1
+ORDER BY created_at DESC, id DESC
+LIMIT ?2
+`
+
+type ListLoginEventsByUserParams struct {
+	UserID interface{} `json:"user_id"`
+	Limit  int64       `json:"limit"`
+}
+
+func (q *Queries) ListLoginEventsByUser(ctx context.Context, arg ListLoginEventsByUserParams) ([]LoginEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listLoginEventsByUser, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LoginEvent
+	for rows.Next() {
+		var i LoginEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Username,
+			&i.Success,
+			&i.Reason,
+			&i.Ip,
+			&i.UserAgent,
+			&i.CreatedAt,
+			&i.Country,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const scrubLoginEventsPII = `-- name: ScrubLoginEventsPII :exec
+UPDATE login_events
+SET ip = NULL,
+    user_agent = NULL,
+    country = NULL
+WHERE user_id = ?1
+`
+
+func (q *Queries) ScrubLoginEventsPII(ctx context.Context, userID interface{}) error {
+	_, err := q.db.ExecContext(ctx, scrubLoginEventsPII, userID)
+	return err
+}
@@ -18,6 +18,7 @@ type LoginEvent struct {
 	Ip        sql.NullString `json:"ip"`
 	UserAgent sql.NullString `json:"user_agent"`
 	CreatedAt time.Time      `json:"created_at"`
+	Country   sql.NullString `json:"country"`
 }
 
 type Session struct {
@@ -30,9 +31,26 @@ type Session struct {
 }
 
 type User struct {
-	ID           int64     `json:"id"`
-	Username     string    `json:"username"`
-	PasswordHash string    `json:"password_hash"`
-	CreatedAt    time.Time `json:"created_at"`
-	IsBanned     bool      `json:"is_banned"`
+	ID                int64          `json:"id"`
+	Username          string         `json:"username"`
+	PasswordHash      string         `json:"password_hash"`
+	CreatedAt         time.Time      `json:"created_at"`
+	IsBanned          bool           `json:"is_banned"`
+	LastLoginAt       sql.NullTime   `json:"last_login_at"`
+	NeedsRehash       bool           `json:"needs_rehash"`
+	MustResetPassword bool           `json:"must_reset_password"`
+	PasswordPeppered  bool           `json:"password_peppered"`
+	DeletedAt         sql.NullTime   `json:"deleted_at"`
+	PasswordChangedAt sql.NullTime   `json:"password_changed_at"`
+	LastLoginIp       sql.NullString `json:"last_login_ip"`
+	Roles             string         `json:"roles"`
+	Email             sql.NullString `json:"email"`
+}
+
+type UsernameChange struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	OldUsername string    `json:"old_username"`
+	NewUsername string    `json:"new_username"`
+	CreatedAt   time.Time `json:"created_at"`
 }
@@ -46,6 +46,102 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) er
 	return err
 }
 
+const getActiveSession = `-- name: GetActiveSession :one
+SELECT
+    id,
+    user_id,
+    created_at,
+    expires_at,
+    revoked_at,
+    revoked_by
+FROM sessions
+WHERE id = ?1
+  AND user_id = ?2
+  AND revoked_at IS NULL
+LIMIT 1
+`
+
+type GetActiveSessionParams struct {
+	ID     string `json:"id"`
+	UserID int64  `json:"user_id"`
+}
+
+func (q *Queries) GetActiveSession(ctx context.Context, arg GetActiveSessionParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, getActiveSession, arg.ID, arg.UserID)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.RevokedBy,
+	)
+	return i, err
+}
+
+const getSession = `-- name: GetSession :one
+SELECT
+    id,
+    user_id,
+    created_at,
+    expires_at,
+    revoked_at,
+    revoked_by
+FROM sessions
+WHERE id = ?1
+LIMIT 1
+`
+
+func (q *Queries) GetSession(ctx context.Context, id string) (Session, error) {
+	row := q.db.QueryRowContext(ctx, getSession, id)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.RevokedBy,
+	)
+	return i, err
+}
+
+const recordSession = `-- name: RecordSession :exec
+INSERT OR IGNORE INTO sessions (
+    id,
+    user_id,
+    created_at,
+    expires_at,
+    revoked_at,
+    revoked_by
+) VALUES (
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    NULL,
+    NULL
+)
+`
+
+type RecordSessionParams struct {
+	ID        string    `json:"id"`
+	UserID    int64     `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) RecordSession(ctx context.Context, arg RecordSessionParams) error {
+	_, err := q.db.ExecContext(ctx, recordSession,
+		arg.ID,
+		arg.UserID,
+		arg.CreatedAt,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
 const revokeSession = `-- name: RevokeSession :exec
 UPDATE sessions
 SET revoked_at = CURRENT_TIMESTAMP,
@@ -63,3 +159,108 @@ func (q *Queries) RevokeSession(ctx context.Context, arg RevokeSessionParams) er
 	_, err := q.db.ExecContext(ctx, revokeSession, arg.ID, arg.RevokedBy)
 	return err
 }
+
+const updateSessionExpiry = `-- name: UpdateSessionExpiry :exec
+UPDATE sessions
+SET expires_at = ?2
+WHERE id = ?1
+  AND revoked_at IS NULL
+`
+
+type UpdateSessionExpiryParams struct {
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) UpdateSessionExpiry(ctx context.Context, arg UpdateSessionExpiryParams) error {
+	_, err := q.db.ExecContext(ctx, updateSessionExpiry, arg.ID, arg.ExpiresAt)
+	return err
+}
+
+const listSessionsByUser = `-- name: ListSessionsByUser :many
+SELECT
+    id,
+    user_id,
+    created_at,
+    expires_at,
+    revoked_at,
+    revoked_by
+FROM sessions
+WHERE user_id = ?1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListSessionsByUser(ctx context.Context, userID int64) ([]Session, error) {
+	rows, err := q.db.QueryContext(ctx, listSessionsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Session
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.RevokedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countRevocationsByReason = `-- name: CountRevocationsByReason :many
+SELECT
+    revoked_by,
+    COUNT(*) AS count
+FROM sessions
+WHERE revoked_by IS NOT NULL
+  AND revoked_at >= datetime(?1)
+  AND revoked_at <= datetime(?2)
+GROUP BY revoked_by
+ORDER BY revoked_by
+`
+
+type CountRevocationsByReasonParams struct {
+	Since interface{} `json:"since"`
+	Until interface{} `json:"until"`
+}
+
+type CountRevocationsByReasonRow struct {
+	RevokedBy sql.NullString `json:"revoked_by"`
+	Count     int64          `json:"count"`
+}
+
+func (q *Queries) CountRevocationsByReason(ctx context.Context, arg CountRevocationsByReasonParams) ([]CountRevocationsByReasonRow, error) {
+	rows, err := q.db.QueryContext(ctx, countRevocationsByReason, arg.Since, arg.Until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountRevocationsByReasonRow
+	for rows.Next() {
+		var i CountRevocationsByReasonRow
+		if err := rows.Scan(&i.RevokedBy, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// ExecTx 在同一個 transaction 中執行 fn，fn 回傳錯誤時 rollback，否則 commit。
+// Queries 已經綁定在 transaction 上（由 WithTx 建立）時直接以目前的 Queries 執行，由外層決定 commit 與否。
+func (q *Queries) ExecTx(ctx context.Context, fn func(*Queries) error) error {
+	sqlDB, ok := q.db.(*sql.DB)
+	if !ok {
+		return fn(q)
+	}
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(q.WithTx(tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -7,6 +7,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 )
 
 const banUser = `-- name: BanUser :exec
@@ -20,29 +21,152 @@ func (q *Queries) BanUser(ctx context.Context, id int64) error {
 	return err
 }
 
+const countUsersByUsername = `-- name: CountUsersByUsername :one
+SELECT COUNT(*)
+FROM users
+WHERE username = ?1
+`
+
+func (q *Queries) CountUsersByUsername(ctx context.Context, username string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsersByUsername, username)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
     username,
-    password_hash
+    password_hash,
+    password_peppered,
+    password_changed_at,
+    email
 ) VALUES (
     ?1,
-    ?2
+    ?2,
+    ?3,
+    CURRENT_TIMESTAMP,
+    ?4
 )
 RETURNING
     id,
     username,
     password_hash,
     created_at,
-    is_banned
+    is_banned,
+    last_login_at,
+    needs_rehash,
+    must_reset_password,
+    password_peppered,
+    deleted_at,
+    password_changed_at,
+    last_login_ip,
+    roles,
+    email
 `
 
 type CreateUserParams struct {
-	Username     string `json:"username"`
-	PasswordHash string `json:"password_hash"`
+	Username         string         `json:"username"`
+	PasswordHash     string         `json:"password_hash"`
+	PasswordPeppered bool           `json:"password_peppered"`
+	Email            sql.NullString `json:"email"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Username, arg.PasswordHash)
+	row := q.db.QueryRowContext(ctx, createUser,
+		arg.Username,
+		arg.PasswordHash,
+		arg.PasswordPeppered,
+		arg.Email,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.CreatedAt,
+		&i.IsBanned,
+		&i.LastLoginAt,
+		&i.NeedsRehash,
+		&i.MustResetPassword,
+		&i.PasswordPeppered,
+		&i.DeletedAt,
+		&i.PasswordChangedAt,
+		&i.LastLoginIp,
+		&i.Roles,
+		&i.Email,
+	)
+	return i, err
+}
+
+const getDeletedUserByID = `-- name: GetDeletedUserByID :one
+SELECT
+    id,
+    username,
+    password_hash,
+    created_at,
+    is_banned,
+    last_login_at,
+    needs_rehash,
+    must_reset_password,
+    password_peppered,
+    deleted_at,
+    password_changed_at,
+    last_login_ip,
+    roles,
+    email
+FROM users
+WHERE id = ?1
+  AND deleted_at IS NOT NULL
+LIMIT 1
+`
+
+func (q *Queries) GetDeletedUserByID(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.CreatedAt,
+		&i.IsBanned,
+		&i.LastLoginAt,
+		&i.NeedsRehash,
+		&i.MustResetPassword,
+		&i.PasswordPeppered,
+		&i.DeletedAt,
+		&i.PasswordChangedAt,
+		&i.LastLoginIp,
+		&i.Roles,
+		&i.Email,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT
+    id,
+    username,
+    password_hash,
+    created_at,
+    is_banned,
+    last_login_at,
+    needs_rehash,
+    must_reset_password,
+    password_peppered,
+    deleted_at,
+    password_changed_at,
+    last_login_ip,
+    roles,
+    email
+FROM users
+WHERE email = ?1
+  AND deleted_at IS NULL
+LIMIT 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email sql.NullString) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -50,6 +174,15 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.PasswordHash,
 		&i.CreatedAt,
 		&i.IsBanned,
+		&i.LastLoginAt,
+		&i.NeedsRehash,
+		&i.MustResetPassword,
+		&i.PasswordPeppered,
+		&i.DeletedAt,
+		&i.PasswordChangedAt,
+		&i.LastLoginIp,
+		&i.Roles,
+		&i.Email,
 	)
 	return i, err
 }
@@ -60,9 +193,19 @@ SELECT
     username,
     password_hash,
     created_at,
-    is_banned
+    is_banned,
+    last_login_at,
+    needs_rehash,
+    must_reset_password,
+    password_peppered,
+    deleted_at,
+    password_changed_at,
+    last_login_ip,
+    roles,
+    email
 FROM users
 WHERE id = ?1
+  AND deleted_at IS NULL
 LIMIT 1
 `
 
@@ -75,6 +218,15 @@ func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
 		&i.PasswordHash,
 		&i.CreatedAt,
 		&i.IsBanned,
+		&i.LastLoginAt,
+		&i.NeedsRehash,
+		&i.MustResetPassword,
+		&i.PasswordPeppered,
+		&i.DeletedAt,
+		&i.PasswordChangedAt,
+		&i.LastLoginIp,
+		&i.Roles,
+		&i.Email,
 	)
 	return i, err
 }
@@ -85,9 +237,19 @@ SELECT
     username,
     password_hash,
     created_at,
-    is_banned
+    is_banned,
+    last_login_at,
+    needs_rehash,
+    must_reset_password,
+    password_peppered,
+    deleted_at,
+    password_changed_at,
+    last_login_ip,
+    roles,
+    email
 FROM users
 WHERE username = ?1
+  AND deleted_at IS NULL
 LIMIT 1
 `
 
@@ -100,10 +262,145 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.PasswordHash,
 		&i.CreatedAt,
 		&i.IsBanned,
+		&i.LastLoginAt,
+		&i.NeedsRehash,
+		&i.MustResetPassword,
+		&i.PasswordPeppered,
+		&i.DeletedAt,
+		&i.PasswordChangedAt,
+		&i.LastLoginIp,
+		&i.Roles,
+		&i.Email,
 	)
 	return i, err
 }
 
+const listBannedUsers = `-- name: ListBannedUsers :many
+SELECT id
+FROM users
+WHERE is_banned = 1
+ORDER BY id
+`
+
+func (q *Queries) ListBannedUsers(ctx context.Context) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, listBannedUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markNeedsRehash = `-- name: MarkNeedsRehash :exec
+UPDATE users
+SET needs_rehash = 1
+WHERE id = ?1
+`
+
+func (q *Queries) MarkNeedsRehash(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, markNeedsRehash, id)
+	return err
+}
+
+const resetPassword = `-- name: ResetPassword :exec
+UPDATE users
+SET password_hash = ?2,
+    password_peppered = ?3,
+    password_changed_at = CURRENT_TIMESTAMP,
+    needs_rehash = 0,
+    must_reset_password = 0
+WHERE id = ?1
+`
+
+type ResetPasswordParams struct {
+	ID               int64  `json:"id"`
+	PasswordHash     string `json:"password_hash"`
+	PasswordPeppered bool   `json:"password_peppered"`
+}
+
+func (q *Queries) ResetPassword(ctx context.Context, arg ResetPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, resetPassword, arg.ID, arg.PasswordHash, arg.PasswordPeppered)
+	return err
+}
+
+const restoreUser = `-- name: RestoreUser :exec
+UPDATE users
+SET deleted_at = NULL
+WHERE id = ?1
+`
+
+func (q *Queries) RestoreUser(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, restoreUser, id)
+	return err
+}
+
+const setMustResetPassword = `-- name: SetMustResetPassword :exec
+UPDATE users
+SET must_reset_password = 1
+WHERE id = ?1
+`
+
+func (q *Queries) SetMustResetPassword(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, setMustResetPassword, id)
+	return err
+}
+
+const setUserRoles = `-- name: SetUserRoles :execrows
+UPDATE users
+SET roles = ?2
+WHERE id = ?1
+  AND deleted_at IS NULL
+`
+
+type SetUserRolesParams struct {
+	ID    int64  `json:"id"`
+	Roles string `json:"roles"`
+}
+
+func (q *Queries) SetUserRoles(ctx context.Context, arg SetUserRolesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserRoles, arg.ID, arg.Roles)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :execrows
+UPDATE users
+SET deleted_at = ?2,
+    last_login_ip = NULL,
+    email = NULL
+WHERE id = ?1
+  AND deleted_at IS NULL
+`
+
+type SoftDeleteUserParams struct {
+	ID        int64        `json:"id"`
+	DeletedAt sql.NullTime `json:"deleted_at"`
+}
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, arg SoftDeleteUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteUser, arg.ID, arg.DeletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const unbanUser = `-- name: UnbanUser :exec
 UPDATE users
 SET is_banned = 0
@@ -114,3 +411,56 @@ func (q *Queries) UnbanUser(ctx context.Context, id int64) error {
 	_, err := q.db.ExecContext(ctx, unbanUser, id)
 	return err
 }
+
+const updateLastLogin = `-- name: UpdateLastLogin :exec
+UPDATE users
+SET last_login_at = ?2,
+    last_login_ip = ?3
+WHERE id = ?1
+`
+
+type UpdateLastLoginParams struct {
+	ID          int64          `json:"id"`
+	LastLoginAt sql.NullTime   `json:"last_login_at"`
+	LastLoginIp sql.NullString `json:"last_login_ip"`
+}
+
+func (q *Queries) UpdateLastLogin(ctx context.Context, arg UpdateLastLoginParams) error {
+	_, err := q.db.ExecContext(ctx, updateLastLogin, arg.ID, arg.LastLoginAt, arg.LastLoginIp)
+	return err
+}
+
+const updatePasswordHash = `-- name: UpdatePasswordHash :exec
+UPDATE users
+SET password_hash = ?2,
+    password_peppered = ?3,
+    needs_rehash = 0
+WHERE id = ?1
+`
+
+type UpdatePasswordHashParams struct {
+	ID               int64  `json:"id"`
+	PasswordHash     string `json:"password_hash"`
+	PasswordPeppered bool   `json:"password_peppered"`
+}
+
+func (q *Queries) UpdatePasswordHash(ctx context.Context, arg UpdatePasswordHashParams) error {
+	_, err := q.db.ExecContext(ctx, updatePasswordHash, arg.ID, arg.PasswordHash, arg.PasswordPeppered)
+	return err
+}
+
+const updateUsername = `-- name: UpdateUsername :exec
+UPDATE users
+SET username = ?2
+WHERE id = ?1
+`
+
+type UpdateUsernameParams struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+func (q *Queries) UpdateUsername(ctx context.Context, arg UpdateUsernameParams) error {
+	_, err := q.db.ExecContext(ctx, updateUsername, arg.ID, arg.Username)
+	return err
+}
@@ -0,0 +1,126 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+// ErrUnknownFlag 表示 flag 名稱不在 FeatureFlags 註冊的清單中。
+var ErrUnknownFlag = errors.New("unknown feature flag")
+
+// FeatureFlags 是執行期可切換的 feature flag，值保存在 Redis 的 feature:{name}（"1" / "0"），不必重新部署就能開關行為。
+// 讀取時使用記憶體快取，超過 refresh 才重新從 Redis 載入；Redis 沒有設定的 flag 使用呼叫端傳入的設定檔預設值。
+// 多個 replica 之間的切換最慢在 refresh 之後生效，在本 FeatureFlags 上呼叫 Set 則立即生效。
+//
+// 每個已驗證的請求都會讀取 flag，因此 Redis 的 MGET 不在鎖內進行：快取過期時只有一個呼叫端負責重新載入，
+// 其他呼叫端直接使用目前的快取，不會排在同一次 Redis 往返之後。values 只整份替換、不原地修改。
+type FeatureFlags struct {
+	rdb     *redis.Client
+	refresh time.Duration
+	names   []string
+
+	mu       sync.Mutex
+	loadedAt time.Time
+	loading  bool
+	values   map[string]bool
+}
+
+// New 建立 FeatureFlags；names 是允許切換的 flag，refresh 為 0 時每次讀取都查詢 Redis（同時有載入進行中時沿用快取）。
+func New(rdb *redis.Client, refresh time.Duration, names ...string) *FeatureFlags {
+	return &FeatureFlags{
+		rdb:     rdb,
+		refresh: refresh,
+		names:   names,
+		values:  make(map[string]bool),
+	}
+}
+
+// Names 回傳允許切換的 flag 名稱。
+func (f *FeatureFlags) Names() []string {
+	return slices.Clone(f.names)
+}
+
+// Enabled 回傳 flag 目前的值：Redis 有設定時以 Redis 為準，否則回傳 fallback（設定檔中的值）。
+// 重新載入失敗時沿用上一次的快取，Redis 暫時無法連線不會讓行為在設定值與 Redis 值之間來回切換。
+func (f *FeatureFlags) Enabled(ctx context.Context, name string, fallback bool) bool {
+	f.mu.Lock()
+	values := f.values
+	reload := !f.loading && (f.loadedAt.IsZero() || time.Since(f.loadedAt) >= f.refresh)
+	if reload {
+		f.loading = true
+	}
+	f.mu.Unlock()
+
+	if reload {
+		values = f.load(ctx)
+	}
+	if v, ok := values[name]; ok {
+		return v
+	}
+	return fallback
+}
+
+// load 在鎖外以 MGET 一次讀回所有 flag，再替換快取並回傳目前的值；呼叫端需先把 loading 設為 true。
+func (f *FeatureFlags) load(ctx context.Context) map[string]bool {
+	values, err := f.fetch(ctx)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loading = false
+	f.loadedAt = time.Now()
+	if err == nil {
+		f.values = values
+	}
+	return f.values
+}
+
+// fetch 以 MGET 讀回所有 flag；Redis 沒有設定的 flag 不會出現在結果中。
+func (f *FeatureFlags) fetch(ctx context.Context) (map[string]bool, error) {
+	values := make(map[string]bool, len(f.names))
+	if len(f.names) == 0 {
+		return values, nil
+	}
+	keys := make([]string, len(f.names))
+	for i, name := range f.names {
+		keys[i] = infra.FeatureFlagKey(name)
+	}
+	raw, err := f.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range raw {
+		if str, ok := v.(string); ok {
+			values[f.names[i]] = str == "1"
+		}
+	}
+	return values, nil
+}
+
+// Set 將 flag 寫入 Redis 並更新本地快取；name 不在清單中時回傳 ErrUnknownFlag。
+func (f *FeatureFlags) Set(ctx context.Context, name string, enabled bool) error {
+	if !slices.Contains(f.names, name) {
+		return ErrUnknownFlag
+	}
+	val := "0"
+	if enabled {
+		val = "1"
+	}
+	if err := f.rdb.Set(ctx, infra.FeatureFlagKey(name), val, 0).Err(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	values := maps.Clone(f.values)
+	values[name] = enabled
+	f.values = values
+	return nil
+}
@@ -0,0 +1,104 @@
+package flags
+
+import (
+	"context" // 匯入 context，傳給 FeatureFlags 的方法
+	"sync"    // 匯入 sync，等待並行讀取結束
+	"testing" // 匯入 testing 套件，提供單元測試框架
+	"time"    // 匯入 time，設定快取的重新載入間隔
+
+	"github.com/alicebob/miniredis/v2"    // 匯入 miniredis，建立記憶體內 Redis
+	"github.com/redis/go-redis/v9"        // 匯入 go-redis，連線到 miniredis
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra 套件，組出 feature flag key
+)
+
+// newTestFlags 建立連線到 miniredis 的 FeatureFlags。
+func newTestFlags(t *testing.T, refresh time.Duration) (*FeatureFlags, *miniredis.Miniredis, *redis.Client) {
+	t.Helper()                                              // 標記為測試輔助函式
+	mr := miniredis.RunT(t)                                 // 啟動記憶體內 Redis，測試結束時自動關閉
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()}) // 連線到 miniredis
+	t.Cleanup(func() { _ = rdb.Close() })                   // 測試結束時關閉連線
+	return New(rdb, refresh, "sliding", "fail_open"), mr, rdb
+}
+
+// TestFeatureFlagsPrecedence 測試 Redis 有設定時優先於設定檔的值，沒有設定時回傳設定檔的值。
+func TestFeatureFlagsPrecedence(t *testing.T) {
+	ctx := context.Background()    // 測試用 context
+	f, mr, _ := newTestFlags(t, 0) // 每次讀取都查詢 Redis
+
+	require.True(t, f.Enabled(ctx, "sliding", true))   // Redis 沒有設定時沿用設定檔的 true
+	require.False(t, f.Enabled(ctx, "sliding", false)) // Redis 沒有設定時沿用設定檔的 false
+
+	require.NoError(t, mr.Set(infra.FeatureFlagKey("sliding"), "0")) // Redis 關閉 flag
+	require.False(t, f.Enabled(ctx, "sliding", true))                // Redis 優先於設定檔
+	require.NoError(t, mr.Set(infra.FeatureFlagKey("sliding"), "1")) // Redis 開啟 flag
+	require.True(t, f.Enabled(ctx, "sliding", false))                // Redis 優先於設定檔
+	require.False(t, f.Enabled(ctx, "fail_open", false))             // 其他 flag 不受影響
+
+	require.ErrorIs(t, f.Set(ctx, "unknown", true), ErrUnknownFlag) // 未註冊的 flag 不可切換
+	require.False(t, mr.Exists(infra.FeatureFlagKey("unknown")))    // 不會寫入 Redis
+}
+
+// TestFeatureFlagsRuntimeFlip 測試 Set 在本 instance 立即生效，其他 instance 在快取過期後生效。
+func TestFeatureFlagsRuntimeFlip(t *testing.T) {
+	ctx := context.Background()                                     // 測試用 context
+	local, mr, rdb := newTestFlags(t, time.Minute)                  // 快取一分鐘
+	remote := New(rdb, 20*time.Millisecond, "sliding", "fail_open") // 另一個 instance，快取 20 毫秒
+
+	require.False(t, local.Enabled(ctx, "fail_open", false))  // 一開始沿用設定檔
+	require.False(t, remote.Enabled(ctx, "fail_open", false)) // 另一個 instance 也一樣
+
+	require.NoError(t, local.Set(ctx, "fail_open", true))                    // 執行期開啟 flag
+	require.Equal(t, "1", mustGet(t, mr, infra.FeatureFlagKey("fail_open"))) // 寫入 Redis
+	require.True(t, local.Enabled(ctx, "fail_open", false))                  // 本 instance 立即生效
+	require.False(t, remote.Enabled(ctx, "fail_open", false))                // 另一個 instance 仍使用快取
+
+	time.Sleep(30 * time.Millisecond)                        // 等待另一個 instance 的快取過期
+	require.True(t, remote.Enabled(ctx, "fail_open", false)) // 重新載入後生效
+
+	mr.SetError("redis down")                                // 模擬 Redis 故障
+	time.Sleep(30 * time.Millisecond)                        // 等待快取過期
+	require.True(t, remote.Enabled(ctx, "fail_open", false)) // 載入失敗時沿用上一次的值
+}
+
+// mustGet 讀取 miniredis 中的字串值。
+func mustGet(t *testing.T, mr *miniredis.Miniredis, key string) string {
+	t.Helper()              // 標記為測試輔助函式
+	val, err := mr.Get(key) // 讀取 key
+	require.NoError(t, err) // key 應存在
+	return val
+}
+
+// TestFeatureFlagsConcurrentReload 測試載入進行中時其他呼叫端直接使用快取，不會等待同一次 Redis 往返。
+func TestFeatureFlagsConcurrentReload(t *testing.T) {
+	ctx := context.Background()    // 測試用 context
+	f, mr, _ := newTestFlags(t, 0) // 每次讀取都查詢 Redis
+
+	require.NoError(t, mr.Set(infra.FeatureFlagKey("sliding"), "1")) // Redis 開啟 flag
+	require.True(t, f.Enabled(ctx, "sliding", false))                // 載入後快取為 true
+
+	require.NoError(t, mr.Set(infra.FeatureFlagKey("sliding"), "0")) // Redis 改為關閉
+	f.mu.Lock()                                                      // 模擬另一個呼叫端正在載入
+	f.loading = true                                                 // 標記載入進行中
+	f.mu.Unlock()                                                    // 釋放鎖，與 MGET 在鎖外進行時相同
+	require.True(t, f.Enabled(ctx, "sliding", false))                // 不重複載入，沿用快取
+
+	require.False(t, f.load(ctx)["sliding"])          // 進行中的載入完成後替換快取
+	require.False(t, f.Enabled(ctx, "sliding", true)) // 之後的讀取看到新值
+
+	var wg sync.WaitGroup     // 等待所有並行讀取結束
+	for i := 0; i < 20; i++ { // 同時讀取與切換，搭配 -race 檢查
+		wg.Add(1) // 登記一個 goroutine
+		go func(i int) {
+			defer wg.Done() // 結束時通知
+			if i%5 == 0 {   // 部分 goroutine 切換 flag
+				_ = f.Set(ctx, "fail_open", i%10 == 0) // 執行期切換
+				return
+			}
+			_ = f.Enabled(ctx, "sliding", false) // 其他 goroutine 讀取 flag
+		}(i)
+	}
+	wg.Wait()                   // 等待全部完成
+	require.False(t, f.loading) // 載入狀態已清除
+}
@@ -0,0 +1,71 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Check 檢查單一相依服務（DB、Redis…）是否可用。
+type Check func(ctx context.Context) error
+
+// DependencyStatus 是單一相依服務的檢查結果。
+type DependencyStatus struct {
+	OK        bool    `json:"ok"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report 是一次 readiness 檢查的完整結果，key 為相依服務名稱。
+type Report struct {
+	OK           bool
+	Dependencies map[string]DependencyStatus
+}
+
+// Checker 執行 readiness 檢查，並在 ttl 內快取結果，
+// 讓大量 probe 共用同一次檢查，避免每個 replica 的探測都打到 DB 與 Redis。
+type Checker struct {
+	ttl    time.Duration
+	checks map[string]Check
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	last      Report
+}
+
+func NewChecker(ttl time.Duration, checks map[string]Check) *Checker {
+	return &Checker{
+		ttl:    ttl,
+		checks: checks,
+	}
+}
+
+// Check 回傳最近一次檢查的結果；快取過期時才真正執行所有 Check。
+// 檢查期間持有鎖，同時進來的 probe 會等待並共用這次的結果。
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.ttl {
+		return c.last
+	}
+
+	report := Report{OK: true, Dependencies: make(map[string]DependencyStatus, len(c.checks))}
+	for name, check := range c.checks {
+		start := time.Now()
+		err := check(ctx)
+		status := DependencyStatus{
+			OK:        err == nil,
+			LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if err != nil {
+			status.Error = err.Error()
+			report.OK = false
+		}
+		report.Dependencies[name] = status
+	}
+
+	c.last = report
+	c.checkedAt = time.Now()
+	return report
+}
@@ -0,0 +1,58 @@
+package health
+
+import (
+	"context"     // 匯入 context，傳給 Checker.Check
+	"errors"      // 匯入 errors，模擬相依服務故障
+	"sync"        // 匯入 sync，同時發出多個 probe
+	"sync/atomic" // 匯入 sync/atomic，安全地計算檢查次數
+	"testing"     // 匯入 testing 套件，提供單元測試框架
+	"time"        // 匯入 time，設定快取 TTL
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，用於撰寫斷言
+)
+
+// TestCheckerCachesResult 測試 TTL 內的大量呼叫（含併發）只會執行一次底層檢查。
+func TestCheckerCachesResult(t *testing.T) {
+	var calls atomic.Int64 // 記錄底層檢查被呼叫的次數
+	c := NewChecker(time.Minute, map[string]Check{"db": func(context.Context) error {
+		calls.Add(1) // 每次真正檢查時加一
+		return nil
+	}})
+
+	var wg sync.WaitGroup     // 等待所有 goroutine 結束
+	for i := 0; i < 50; i++ { // 模擬 50 個同時進來的 probe
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.True(t, c.Check(context.Background()).OK) // 每個 probe 都應回報健康
+		}()
+	}
+	wg.Wait() // 等待全部完成
+
+	require.EqualValues(t, 1, calls.Load()) // 只應執行一次底層檢查
+}
+
+// TestCheckerReflectsOutageAfterTTL 測試快取過期後會重新檢查並反映故障。
+func TestCheckerReflectsOutageAfterTTL(t *testing.T) {
+	var down atomic.Bool // 控制相依服務是否故障
+	c := NewChecker(20*time.Millisecond, map[string]Check{
+		"db": func(context.Context) error { return nil }, // DB 一直正常
+		"redis": func(context.Context) error {
+			if down.Load() {
+				return errors.New("redis down") // 模擬故障
+			}
+			return nil
+		},
+	})
+
+	require.True(t, c.Check(context.Background()).OK) // 一開始健康
+	down.Store(true)                                  // 相依服務故障
+	require.True(t, c.Check(context.Background()).OK) // TTL 內仍回傳快取結果
+
+	time.Sleep(30 * time.Millisecond)                                  // 等待快取過期
+	report := c.Check(context.Background())                            // 重新檢查
+	require.False(t, report.OK)                                        // 應反映故障
+	require.True(t, report.Dependencies["db"].OK)                      // DB 仍正常
+	require.False(t, report.Dependencies["redis"].OK)                  // Redis 標記為失敗
+	require.Equal(t, "redis down", report.Dependencies["redis"].Error) // 附上錯誤訊息
+}
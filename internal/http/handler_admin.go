@@ -1,24 +1,75 @@
 package http
 
 import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"sessionservice/internal/config"
+	"sessionservice/internal/db"
+	"sessionservice/internal/flags"
+	"sessionservice/internal/infra"
 	"sessionservice/internal/session"
 )
 
-// AdminHandler 負責管理端 API（列出 sessions、踢人、ban/unban）。
+// AdminHandler 負責管理端 API（查詢 user、列出 sessions、踢人、ban/unban、軟刪除與還原）。
 type AdminHandler struct {
+	q       *db.Queries
 	sessSvc *session.SessionService
+	cfg     *config.Config
 }
 
-func NewAdminHandler(sessSvc *session.SessionService) *AdminHandler {
-	return &AdminHandler{sessSvc: sessSvc}
+func NewAdminHandler(q *db.Queries, sessSvc *session.SessionService, cfg *config.Config) *AdminHandler {
+	return &AdminHandler{
+		q:       q,
+		sessSvc: sessSvc,
+		cfg:     cfg,
+	}
+}
+
+// GetUser 回傳某 user 的基本資料（不含密碼雜湊）。
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	userID, err := parseUserIDParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	user, err := h.q.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query user"})
+		return
+	}
+
+	shadowBanned, err := h.sessSvc.IsShadowBanned(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":            user.ID,
+		"username":      user.Username,
+		"created_at":    user.CreatedAt,
+		"is_banned":     user.IsBanned,
+		"shadow_banned": shadowBanned,
+		"last_login_at": nullTimePtr(user.LastLoginAt),
+		"roles":         nonNilRoles(session.UserRoles(user)),
+	})
 }
 
 // ListUserSessions 回傳某 user 的活躍 sessions（從 Redis 讀取）。
+// 支援 query：sort=created_at|last_seen、order=asc|desc、ip、device（比對 user agent）。
 func (h *AdminHandler) ListUserSessions(c *gin.Context) {
 	userID, err := parseUserIDParam(c)
 	if err != nil {
@@ -27,8 +78,17 @@ func (h *AdminHandler) ListUserSessions(c *gin.Context) {
 	}
 
 	ctx := c.Request.Context()
-	sessions, err := h.sessSvc.ListActiveSessions(ctx, userID)
+	sessions, err := h.sessSvc.ListActiveSessions(ctx, userID, session.ListSessionsOptions{
+		Sort:   c.Query("sort"),
+		Order:  c.Query("order"),
+		IP:     c.Query("ip"),
+		Device: c.Query("device"),
+	})
 	if err != nil {
+		if errors.Is(err, session.ErrInvalidListOptions) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sort or order"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions"})
 		return
 	}
@@ -36,6 +96,284 @@ func (h *AdminHandler) ListUserSessions(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
 }
 
+// ListSessions 以 cursor 分頁列出所有使用者的活躍 sessions（GET /admin/sessions），供排查異常使用。
+// 支援 query：cursor（上一頁的 next_cursor）、limit、ip、device（比對 user agent）、min_age_seconds、max_age_seconds。
+func (h *AdminHandler) ListSessions(c *gin.Context) {
+	opts := session.GlobalSessionsOptions{
+		Cursor: c.Query("cursor"),
+		IP:     c.Query("ip"),
+		Device: c.Query("device"),
+	}
+	var minAge, maxAge int
+	var ok bool
+	if opts.Limit, ok = nonNegativeQuery(c, "limit"); !ok {
+		return
+	}
+	if minAge, ok = nonNegativeQuery(c, "min_age_seconds"); !ok {
+		return
+	}
+	if maxAge, ok = nonNegativeQuery(c, "max_age_seconds"); !ok {
+		return
+	}
+	opts.MinAge = time.Duration(minAge) * time.Second
+	opts.MaxAge = time.Duration(maxAge) * time.Second
+
+	page, err := h.sessSvc.ListAllActiveSessions(c.Request.Context(), opts)
+	if err != nil {
+		if errors.Is(err, session.ErrInvalidListOptions) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// nonNegativeQuery 讀取非負整數的 query 參數，未帶時為 0；格式不符時回 400 並回傳 false。
+func nonNegativeQuery(c *gin.Context, name string) (int, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return n, true
+}
+
+// InspectSession 回傳 sess:{sid} 在 Redis 中的完整 hash、TTL 與擁有者是否被 ban（GET /admin/sessions/:sid）。
+func (h *AdminHandler) InspectSession(c *gin.Context) {
+	info, err := h.sessSvc.InspectSession(c.Request.Context(), c.Param("sid"))
+	if err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to inspect session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// BumpSessionEpoch 將 session epoch 加一，讓目前所有 session 立即失效（POST /admin/sessions/epoch）。
+func (h *AdminHandler) BumpSessionEpoch(c *gin.Context) {
+	epoch, err := h.sessSvc.BumpSessionEpoch(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to bump session epoch"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"epoch": epoch})
+}
+
+type setFeatureFlagRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// SetFeatureFlag 在執行期切換 feature flag（POST /admin/flags/:name），不必重新部署；
+// 值寫入 Redis 並優先於設定檔，其他 instance 在 FEATURE_FLAGS_REFRESH_SECONDS 內生效。
+func (h *AdminHandler) SetFeatureFlag(c *gin.Context) {
+	var req setFeatureFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	name := c.Param("name")
+	if err := h.sessSvc.SetFeatureFlag(c.Request.Context(), name, *req.Enabled); err != nil {
+		if errors.Is(err, flags.ErrUnknownFlag) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown feature flag"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set feature flag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"name": name, "enabled": *req.Enabled})
+}
+
+// PurgeSessions 是緊急登出所有人的 panic button（POST /admin/sessions/purge）。
+// 除了 admin key，還必須以 X-Confirm-Purge header 帶入設定的確認碼，避免誤觸。
+// 前進 session epoch 讓所有 token 立即失效後回 202，Redis 與 sessions 表的清理在背景進行。
+func (h *AdminHandler) PurgeSessions(c *gin.Context) {
+	if h.cfg.AdminPurgeConfirm == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "purge_disabled"})
+		return
+	}
+	confirm := c.GetHeader("X-Confirm-Purge")
+	if subtle.ConstantTimeCompare([]byte(confirm), []byte(h.cfg.AdminPurgeConfirm)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "confirmation_required"})
+		return
+	}
+
+	epoch, err := h.sessSvc.PurgeAllSessions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to purge sessions"})
+		return
+	}
+
+	h.sessSvc.StartCleanupSessionsBefore(epoch)
+
+	c.JSON(http.StatusAccepted, gin.H{"epoch": epoch})
+}
+
+// ExportUser 回傳使用者資料的完整匯出（POST /admin/users/:id/export），供處理資料主體存取請求（GDPR）。
+// 內容包含基本資料、活躍 session、session 歷史、最近的登入紀錄與 username 變更，不含密碼雜湊等內部欄位。
+func (h *AdminHandler) ExportUser(c *gin.Context) {
+	userID, err := parseUserIDParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	export, err := h.sessSvc.ExportUserData(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, session.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export user"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-export.json"`, userID))
+	c.JSON(http.StatusOK, export)
+}
+
+// defaultFailedLoginsWindow 是 FailedLogins 未帶 since 時往回統計的時間。
+const defaultFailedLoginsWindow = time.Hour
+
+// FailedLogins 回傳使用者在 since 之後的登入失敗次數（GET /admin/users/:id/failed-logins），供安全儀表板與濫用調查使用。
+// since 為 RFC 3339 時間，未帶時統計最近一小時；include_ips=true 時一併回傳失敗來源的不重複 IP。
+// login_events 以 username 比對，使用者改名前的失敗紀錄不會計入。
+func (h *AdminHandler) FailedLogins(c *gin.Context) {
+	userID, err := parseUserIDParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	since := time.Now().Add(-defaultFailedLoginsWindow)
+	if v := c.Query("since"); v != "" {
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since"})
+			return
+		}
+	}
+	includeIPs := c.Query("include_ips") == "true"
+
+	ctx := c.Request.Context()
+	user, err := h.q.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query user"})
+		return
+	}
+
+	// created_at 由 SQLite 以 UTC 的 "YYYY-MM-DD HH:MM:SS" 寫入，since 轉成相同格式才能正確比較
+	username := sql.NullString{String: user.Username, Valid: true}
+	sinceUTC := since.UTC().Format(time.DateTime)
+	count, err := h.q.CountFailedLoginsSince(ctx, db.CountFailedLoginsSinceParams{Username: username, Since: sinceUTC})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count failed logins"})
+		return
+	}
+
+	resp := gin.H{
+		"user_id":       user.ID,
+		"username":      user.Username,
+		"since":         since.UTC(),
+		"failed_logins": count,
+	}
+	if includeIPs {
+		rows, err := h.q.ListFailedLoginIPsSince(ctx, db.ListFailedLoginIPsSinceParams{Username: username, Since: sinceUTC})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count failed logins"})
+			return
+		}
+		ips := make([]string, 0, len(rows))
+		for _, ip := range rows {
+			ips = append(ips, ip.String)
+		}
+		resp["ips"] = ips
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// defaultRevocationStatsWindow 是 RevocationStats 未帶 since 時往回統計的時間。
+const defaultRevocationStatsWindow = 24 * time.Hour
+
+// RevocationStats 依 revoked_by 統計 since 到 until（含）之間被撤銷的 session 數（GET /admin/stats/revocations）。
+// since / until 為 RFC 3339 時間，預設為最近 24 小時；by_reason 一律列出所有 infra.RevokeReasons，沒有紀錄的原因為 0。
+func (h *AdminHandler) RevocationStats(c *gin.Context) {
+	var err error
+	until := time.Now()
+	if v := c.Query("until"); v != "" {
+		if until, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid until"})
+			return
+		}
+	}
+	since := until.Add(-defaultRevocationStatsWindow)
+	if v := c.Query("since"); v != "" {
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since"})
+			return
+		}
+	}
+	if !since.Before(until) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since must be before until"})
+		return
+	}
+
+	// revoked_at 由 SQLite 以 UTC 的 "YYYY-MM-DD HH:MM:SS" 寫入，since / until 轉成相同格式才能正確比較
+	rows, err := h.q.CountRevocationsByReason(c.Request.Context(), db.CountRevocationsByReasonParams{
+		Since: since.UTC().Format(time.DateTime),
+		Until: until.UTC().Format(time.DateTime),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count revocations"})
+		return
+	}
+
+	byReason := make(map[string]int64, len(infra.RevokeReasons))
+	for _, r := range infra.RevokeReasons {
+		byReason[string(r)] = 0
+	}
+	var total int64
+	for _, row := range rows {
+		byReason[row.RevokedBy.String] = row.Count
+		total += row.Count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"since":     since.UTC(),
+		"until":     until.UTC(),
+		"total":     total,
+		"by_reason": byReason,
+	})
+}
+
+// KickDevice 撤銷綁定在指定 device_id 上的所有 session（POST /admin/devices/:device_id/kick）。
+func (h *AdminHandler) KickDevice(c *gin.Context) {
+	kicked, err := h.sessSvc.KickByDevice(c.Request.Context(), c.Param("device_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to kick device sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"kicked": kicked})
+}
+
 type kickUserRequest struct {
 	SessionID string `json:"session_id,omitempty"`
 	All       bool   `json:"all,omitempty"`
@@ -78,6 +416,127 @@ func (h *AdminHandler) KickUserSessions(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+type extendSessionRequest struct {
+	DurationSeconds int64 `json:"duration_seconds" binding:"required,gt=0"`
+}
+
+// ExtendSession 延長指定 user 的某個 session，延長後不可超過 MaxSessionLifetime。
+func (h *AdminHandler) ExtendSession(c *gin.Context) {
+	userID, err := parseUserIDParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req extendSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	sessionID := c.Param("sid")
+	expiresAt, err := h.sessSvc.ExtendSession(c.Request.Context(), userID, sessionID, time.Duration(req.DurationSeconds)*time.Second)
+	if err != nil {
+		switch {
+		case errors.Is(err, session.ErrSessionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		case errors.Is(err, session.ErrLifetimeExceeded):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "extension exceeds max session lifetime"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to extend session"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"expires_at": expiresAt,
+	})
+}
+
+type forceResetRequest struct {
+	UserIDs   []int64  `json:"user_ids"`
+	Usernames []string `json:"usernames"`
+}
+
+// ForceResetPasswords 批次標記使用者必須重設密碼並踢掉其所有 session（例如比對到外洩密碼清單時）。
+func (h *AdminHandler) ForceResetPasswords(c *gin.Context) {
+	var req forceResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.UserIDs)+len(req.Usernames) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_ids or usernames required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	userIDs := append([]int64(nil), req.UserIDs...)
+	notFound := []string{}
+	for _, name := range req.Usernames {
+		user, err := h.q.GetUserByUsername(ctx, session.NormalizeUsername(name))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				notFound = append(notFound, name)
+				continue
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query user"})
+			return
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+
+	affected := []int64{}
+	for _, id := range userIDs {
+		if _, err := h.q.GetUserByID(ctx, id); err != nil {
+			if err == sql.ErrNoRows {
+				notFound = append(notFound, strconv.FormatInt(id, 10))
+				continue
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query user"})
+			return
+		}
+		if err := h.sessSvc.ForceResetPassword(ctx, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to force reset"})
+			return
+		}
+		affected = append(affected, id)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"affected":  affected,
+		"not_found": notFound,
+	})
+}
+
+// IssuePasswordResetToken 為使用者發出一次性的密碼重設 token（POST /admin/users/:id/password-reset-token），
+// 由 admin 以帳號以外的管道交給使用者，用於沒有 email 的 force-reset 帳號。
+func (h *AdminHandler) IssuePasswordResetToken(c *gin.Context) {
+	userID, err := parseUserIDParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.q.GetUserByID(ctx, userID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query user"})
+		return
+	}
+
+	resetToken, err := h.sessSvc.IssuePasswordResetToken(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue reset token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reset_token": resetToken,
+		"expires_in":  int64(h.cfg.PasswordResetTokenTTL.Seconds()),
+	})
+}
+
 // BanUser 封鎖使用者並踢掉所有 session。
 func (h *AdminHandler) BanUser(c *gin.Context) {
 	userID, err := parseUserIDParam(c)
@@ -110,9 +569,125 @@ func (h *AdminHandler) UnbanUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+// ShadowBanUser 將使用者 shadow ban（POST /admin/users/:id/shadow-ban），不踢掉 session 也不阻擋登入。
+func (h *AdminHandler) ShadowBanUser(c *gin.Context) {
+	userID, err := parseUserIDParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.sessSvc.ShadowBanUser(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to shadow ban user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// UnshadowBanUser 解除使用者的 shadow ban（POST /admin/users/:id/unshadow-ban）。
+func (h *AdminHandler) UnshadowBanUser(c *gin.Context) {
+	userID, err := parseUserIDParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.sessSvc.UnshadowBanUser(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unshadow ban user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+type setRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+// SetUserRoles 以 body 的 roles 取代使用者的角色（PUT /admin/users/:id/roles），空陣列代表清除所有角色。
+// 角色決定登入時套用的 ROLE_SESSION_TTL，既有 session 不受影響。
+func (h *AdminHandler) SetUserRoles(c *gin.Context) {
+	userID, err := parseUserIDParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req setRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Roles == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	roles, err := h.sessSvc.SetUserRoles(c.Request.Context(), userID, req.Roles)
+	if err != nil {
+		switch {
+		case errors.Is(err, session.ErrInvalidRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_role"})
+		case errors.Is(err, session.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set roles"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// nonNilRoles 讓沒有角色的使用者回傳 [] 而不是 null。
+func nonNilRoles(roles []string) []string {
+	if roles == nil {
+		return []string{}
+	}
+	return roles
+}
+
+// DeleteUser 軟刪除使用者並踢掉所有 session（DELETE /admin/users/:id）。
+func (h *AdminHandler) DeleteUser(c *gin.Context) {
+	userID, err := parseUserIDParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.sessSvc.DeleteUser(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, session.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// RestoreUser 還原軟刪除的使用者（POST /admin/users/:id/restore），超過還原期限回 410。
+func (h *AdminHandler) RestoreUser(c *gin.Context) {
+	userID, err := parseUserIDParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.sessSvc.RestoreUser(c.Request.Context(), userID); err != nil {
+		switch {
+		case errors.Is(err, session.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "deleted user not found"})
+		case errors.Is(err, session.ErrRestoreWindowExpired):
+			c.JSON(http.StatusGone, gin.H{"error": "restore window expired"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to restore user"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
 func parseUserIDParam(c *gin.Context) (int64, error) {
 	idStr := c.Param("id")
 	return strconv.ParseInt(idStr, 10, 64)
 }
-
-
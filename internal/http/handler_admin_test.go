@@ -0,0 +1,746 @@
+package http
+
+import (
+	"bytes"             // 匯入 bytes，建立請求 body
+	"context"           // 匯入 context，呼叫 service 與 Redis
+	"encoding/json"     // 匯入 encoding/json，解析回應
+	"net/http"          // 匯入 net/http，使用 method 與狀態碼常數
+	"net/http/httptest" // 匯入 httptest，模擬 HTTP 請求
+	"strconv"           // 匯入 strconv，組出 user id 路徑
+	"strings"           // 匯入 strings，找出 session hash 的 key
+	"testing"           // 匯入 testing，提供單元測試框架
+	"time"              // 匯入 time，設定 session 上限與檢查到期時間
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra"   // 匯入 infra，讀取 Redis session key
+	"sessionservice/internal/session" // 匯入 session，直接建立登入 session
+)
+
+// doAdmin 帶著 admin token 送出 JSON 請求。
+func doAdmin(r http.Handler, env *testEnv, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body)) // 建立請求
+	req.Header.Set("Content-Type", "application/json")                    // 標記為 JSON body
+	req.Header.Set("X-Admin-Token", env.cfg.AdminAPIKey)                  // 帶上 admin token
+	w := httptest.NewRecorder()                                           // 建立 ResponseRecorder
+	r.ServeHTTP(w, req)                                                   // 執行請求
+	return w
+}
+
+// loginForAdminTest 註冊並登入一個使用者，回傳 user id 與 session id。
+func loginForAdminTest(t *testing.T, env *testEnv, r http.Handler) (int64, string) {
+	t.Helper()                                                                                       // 標記為測試輔助函式
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+
+	u, sid, _, err := env.sessSvc.Login(context.Background(), "alice", "password123", session.LoginMeta{}) // 直接透過 service 登入
+	require.NoError(t, err)                                                                                // 應登入成功
+	return u.ID, sid
+}
+
+// TestAdminExtendSession 測試延長 session 會同步更新 Redis 與 DB 的到期時間。
+func TestAdminExtendSession(t *testing.T) {
+	env := newTestEnv(t)                        // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin"          // 設定 admin token
+	env.cfg.MaxSessionLifetime = 2 * time.Hour  // session 最長存活 2 小時
+	r := newTestRouter(env)                     // 建立完整 router
+	userID, sid := loginForAdminTest(t, env, r) // 建立一個 1 小時的 session
+
+	path := "/admin/users/" + strconv.FormatInt(userID, 10) + "/sessions/" + sid + "/extend" // extend 路徑
+	w := doAdmin(r, env, http.MethodPost, path, `{"duration_seconds":1800}`)                 // 延長 30 分鐘
+	require.Equal(t, http.StatusOK, w.Code)                                                  // 應成功
+
+	var resp struct {
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))                                // 解析回應
+	require.WithinDuration(t, time.Now().Add(90*time.Minute), resp.ExpiresAt, 5*time.Second) // 應約為現在 + 1.5 小時
+
+	stored, err := env.rdb.HGet(context.Background(), infra.SessKey(sid), "expires_at").Int64() // Redis 內記錄的到期時間
+	require.NoError(t, err)                                                                     // 應讀取成功
+	require.Equal(t, resp.ExpiresAt.Unix(), stored)                                             // 應與回應一致
+
+	ttl := env.mr.TTL(infra.SessKey(sid))   // miniredis 中的 TTL
+	require.Greater(t, ttl, 80*time.Minute) // TTL 應已延長超過原本的 1 小時
+
+	var dbExpires time.Time                                                                                                     // DB 內的到期時間
+	err = env.sqlDB.QueryRowContext(context.Background(), "SELECT expires_at FROM sessions WHERE id = ?", sid).Scan(&dbExpires) // 查詢 sessions 表
+	require.NoError(t, err)                                                                                                     // 查詢應成功
+	require.Equal(t, resp.ExpiresAt.Unix(), dbExpires.Unix())                                                                   // DB 也應更新
+}
+
+// TestAdminExtendSessionCap 測試延長超過 MaxSessionLifetime 或 session 不存在時會被拒絕。
+func TestAdminExtendSessionCap(t *testing.T) {
+	env := newTestEnv(t)                        // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin"          // 設定 admin token
+	env.cfg.MaxSessionLifetime = 2 * time.Hour  // session 最長存活 2 小時
+	r := newTestRouter(env)                     // 建立完整 router
+	userID, sid := loginForAdminTest(t, env, r) // 建立一個 1 小時的 session
+
+	base := "/admin/users/" + strconv.FormatInt(userID, 10) + "/sessions/"                 // extend 路徑前綴
+	w := doAdmin(r, env, http.MethodPost, base+sid+"/extend", `{"duration_seconds":7200}`) // 延長 2 小時，超過上限
+	require.Equal(t, http.StatusBadRequest, w.Code)                                        // 應被拒絕
+
+	ttl := env.mr.TTL(infra.SessKey(sid))  // 檢查 TTL 沒被改動
+	require.LessOrEqual(t, ttl, time.Hour) // 仍為原本的 1 小時以內
+
+	w = doAdmin(r, env, http.MethodPost, base+"no-such-sid/extend", `{"duration_seconds":60}`) // 不存在的 session
+	require.Equal(t, http.StatusNotFound, w.Code)                                              // 應回 404
+}
+
+// TestAdminForceResetBlocksLogin 測試 admin 批次 force-reset 後登入回傳 403，重設密碼後恢復正常。
+func TestAdminForceResetBlocksLogin(t *testing.T) {
+	env := newTestEnv(t)                        // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin"          // 設定 admin token
+	r := newTestRouter(env)                     // 建立完整 router
+	userID, sid := loginForAdminTest(t, env, r) // 建立 alice 並登入
+
+	body := `{"usernames":["Alice","nobody"]}`                                                                    // 以 username 指定，含一個不存在的帳號
+	w := doAdmin(r, env, http.MethodPost, "/admin/users/force-reset", body)                                       // 呼叫 force-reset
+	require.Equal(t, http.StatusOK, w.Code)                                                                       // 應成功
+	require.JSONEq(t, `{"affected":[`+strconv.FormatInt(userID, 10)+`],"not_found":["nobody"]}`, w.Body.String()) // 回報處理結果
+
+	ok, err := env.sessSvc.IsSessionValid(context.Background(), userID, sid) // 既有 session
+	require.NoError(t, err)                                                  // 檢查不應失敗
+	require.False(t, ok)                                                     // 應已被踢掉
+
+	w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123"}`) // 嘗試登入
+	require.Equal(t, http.StatusForbidden, w.Code)                                                 // 應回 403
+	require.Contains(t, w.Body.String(), "password_reset_required")                                // 並提示需重設
+
+	w = doJSON(r, http.MethodPost, "/auth/password/reset", `{"token":"password123","new_password":"new-password"}`) // 舊密碼不能充當重設 token
+	require.Equal(t, http.StatusUnauthorized, w.Code)                                                               // 應回 401
+
+	w = doAdmin(r, env, http.MethodPost, "/admin/users/"+strconv.FormatInt(userID, 10)+"/password-reset-token", "") // admin 簽發重設 token
+	require.Equal(t, http.StatusOK, w.Code)                                                                         // 應成功
+	var issued struct {
+		ResetToken string `json:"reset_token"` // 一次性重設 token
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued)) // 解析回應
+	require.NotEmpty(t, issued.ResetToken)                      // 應帶 token
+
+	body = `{"token":"` + issued.ResetToken + `","new_password":"new-password"}` // 以 token 重設
+	w = doJSON(r, http.MethodPost, "/auth/password/reset", body)                 // 重設密碼
+	require.Equal(t, http.StatusOK, w.Code)                                      // 應成功
+	w = doJSON(r, http.MethodPost, "/auth/password/reset", body)                 // 重複使用同一 token
+	require.Equal(t, http.StatusUnauthorized, w.Code)                            // token 已失效
+
+	w = doAdmin(r, env, http.MethodPost, "/admin/users/999999/password-reset-token", "") // 不存在的使用者
+	require.Equal(t, http.StatusNotFound, w.Code)                                        // 應回 404
+
+	w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"alice","password":"new-password"}`) // 用新密碼登入
+	require.Equal(t, http.StatusOK, w.Code)                                                         // 應成功
+}
+
+// TestAdminInspectSession 測試可取得 sess:{sid} 的完整 hash、TTL 與 ban 狀態，不存在的 session 回 404。
+func TestAdminInspectSession(t *testing.T) {
+	env := newTestEnv(t)               // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin" // 設定 admin token
+	r := newTestRouter(env)            // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	meta := session.LoginMeta{IP: "10.0.0.1", UserAgent: "inspect-agent"}                            // 登入來源
+	u, sid, expiresAt, err := env.sessSvc.Login(context.Background(), "alice", "password123", meta)  // 建立 session
+	require.NoError(t, err)                                                                          // 應登入成功
+
+	ctx := context.Background()                                                                                          // 背景 context
+	require.NoError(t, env.rdb.HSet(ctx, infra.SessKey(sid), "last_seen", "1700000000", "device_label", "laptop").Err()) // 其他功能寫入的欄位
+
+	w = doAdmin(r, env, http.MethodGet, "/admin/sessions/"+sid, "") // 查詢 session
+	require.Equal(t, http.StatusOK, w.Code)                         // 應成功
+
+	var resp session.SessionInspection                        // 解析回應
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp)) // 應為合法 JSON
+	require.Equal(t, sid, resp.SessionID)                     // session id 正確
+	require.Equal(t, map[string]string{                       // hash 內所有欄位原樣回傳
+		"user_id":      strconv.FormatInt(u.ID, 10),
+		"created_at":   resp.Fields["created_at"],
+		"expires_at":   strconv.FormatInt(expiresAt.Unix(), 10),
+		"ip":           "10.0.0.1",
+		"user_agent":   "inspect-agent",
+		"last_seen":    "1700000000",
+		"device_label": "laptop",
+	}, resp.Fields)
+	require.NotEmpty(t, resp.Fields["created_at"])                                   // created_at 應存在
+	require.InDelta(t, env.mr.TTL(infra.SessKey(sid)).Seconds(), resp.TTLSeconds, 1) // TTL 與 Redis 一致
+	require.Greater(t, resp.TTLSeconds, int64(0))                                    // 應有過期時間
+	require.False(t, resp.UserBanned)                                                // 尚未被 ban
+	require.NoError(t, env.rdb.Set(ctx, infra.BannedUserKey(u.ID), "1", 0).Err())    // 設定 Redis ban flag
+	w = doAdmin(r, env, http.MethodGet, "/admin/sessions/"+sid, "")                  // 再查一次
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))                        // 解析回應
+	require.True(t, resp.UserBanned)                                                 // 應反映 ban 狀態
+	w = doAdmin(r, env, http.MethodGet, "/admin/sessions/missing-sid", "")           // 不存在的 session
+	require.Equal(t, http.StatusNotFound, w.Code)                                    // 應回 404
+	w = doJSON(r, http.MethodGet, "/admin/sessions/"+sid, "")                        // 未帶 admin token
+	require.Equal(t, http.StatusForbidden, w.Code)                                   // 應被拒絕
+}
+
+// TestAdminBumpSessionEpoch 測試 admin 前進 session epoch 後，既有 token 立即失效。
+func TestAdminBumpSessionEpoch(t *testing.T) {
+	env := newTestEnv(t)               // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin" // 設定 admin token
+	r := newTestRouter(env)            // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	token := loginToken(t, r, "alice", "password123")                                                // 登入取得 token
+
+	w = doAdmin(r, env, http.MethodPost, "/admin/sessions/epoch", "") // 前進 epoch
+	require.Equal(t, http.StatusOK, w.Code)                           // 應成功
+	require.JSONEq(t, `{"epoch":1}`, w.Body.String())                 // 測試設定的 epoch 為 0，前進後為 1
+
+	w = doAuthed(r, token, http.MethodGet, "/me", "") // 舊 token
+	require.Equal(t, http.StatusUnauthorized, w.Code) // 應失效
+
+	token = loginToken(t, r, "alice", "password123")  // 重新登入
+	w = doAuthed(r, token, http.MethodGet, "/me", "") // 新 token
+	require.Equal(t, http.StatusOK, w.Code)           // 應有效
+}
+
+// TestAdminListSessionsSortAndFilter 測試 admin 列出 sessions 時可依 last_seen 排序並以 IP、device 過濾。
+func TestAdminListSessionsSortAndFilter(t *testing.T) {
+	env := newTestEnv(t)               // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin" // 設定 admin token
+	env.cfg.MaxSessionsPerUser = 5     // 允許三個 session 同時存在
+	r := newTestRouter(env)            // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+
+	ctx := context.Background()   // 背景 context
+	metas := []session.LoginMeta{ // 三個不同來源的登入
+		{IP: "10.0.0.1", UserAgent: "Mozilla/5.0 (iPhone)"},
+		{IP: "10.0.0.2", UserAgent: "Mozilla/5.0 (Windows NT 10.0)"},
+		{IP: "10.0.0.2", UserAgent: "curl/8.0"},
+	}
+	lastSeen := []string{"1700000200", "1700000300", "1700000100"} // 各 session 的最後活動時間
+	var userID int64
+	sids := make([]string, len(metas))
+	for i, meta := range metas {
+		u, sid, _, err := env.sessSvc.Login(ctx, "alice", "password123", meta)                    // 建立 session
+		require.NoError(t, err)                                                                   // 應登入成功
+		require.NoError(t, env.rdb.HSet(ctx, infra.SessKey(sid), "last_seen", lastSeen[i]).Err()) // 寫入最後活動時間
+		userID, sids[i] = u.ID, sid
+	}
+	base := "/admin/users/" + strconv.FormatInt(userID, 10) + "/sessions" // 列表路徑
+
+	list := func(query string) []string {
+		w := doAdmin(r, env, http.MethodGet, base+query, "") // 呼叫列表
+		require.Equal(t, http.StatusOK, w.Code)              // 應成功
+		var resp struct {
+			Sessions []session.ActiveSessionInfo `json:"sessions"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp)) // 解析回應
+		ids := make([]string, 0, len(resp.Sessions))
+		for _, s := range resp.Sessions {
+			ids = append(ids, s.SessionID)
+		}
+		return ids
+	}
+
+	require.Equal(t, sids, list(""))                                                          // 預設依建立時間由舊到新
+	require.Equal(t, []string{sids[1], sids[0], sids[2]}, list("?sort=last_seen&order=desc")) // 依最後活動時間由新到舊
+	require.Equal(t, []string{sids[2], sids[0], sids[1]}, list("?sort=last_seen"))            // 預設由舊到新
+	require.Equal(t, []string{sids[1], sids[2]}, list("?ip=10.0.0.2"))                        // 依 IP 過濾
+	require.Equal(t, []string{sids[1]}, list("?ip=10.0.0.2&device=windows"))                  // IP 與 device 同時過濾
+
+	w = doAdmin(r, env, http.MethodGet, base+"?sort=username", "") // 不支援的排序欄位
+	require.Equal(t, http.StatusBadRequest, w.Code)                // 應回 400
+}
+
+// TestAdminKickDevice 測試登入時帶入的 device_id 會綁定到 session，admin 可依 device_id 撤銷。
+func TestAdminKickDevice(t *testing.T) {
+	env := newTestEnv(t)               // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin" // 設定 admin token
+	r := newTestRouter(env)            // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+
+	w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123","device_id":"iphone-1"}`) // 帶 device_id 登入
+	require.Equal(t, http.StatusOK, w.Code)                                                                               // 應登入成功
+	var resp loginResponse                                                                                                // 解析回應
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))                                                             // 應為合法 JSON
+
+	w = doAdmin(r, env, http.MethodPost, "/admin/devices/iphone-1/kick", "") // 依 device_id 撤銷
+	require.Equal(t, http.StatusOK, w.Code)                                  // 應成功
+	require.JSONEq(t, `{"kicked":1}`, w.Body.String())                       // 撤銷一個 session
+
+	w = doAuthed(r, resp.AccessToken, http.MethodGet, "/me", "") // 被撤銷的 token
+	require.Equal(t, http.StatusUnauthorized, w.Code)            // 應失效
+}
+
+// TestAdminPurgeSessions 測試 purge 需要確認碼，成功後所有既有 token 立即失效且背景清理會刪除 Redis 資料。
+func TestAdminPurgeSessions(t *testing.T) {
+	env := newTestEnv(t)                        // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin"          // 設定 admin token
+	env.cfg.AdminPurgeConfirm = "yes-purge-all" // 設定確認碼
+	r := newTestRouter(env)                     // 建立完整 router
+
+	tokens := make([]string, 0, 2)
+	for _, name := range []string{"alice", "bob"} {
+		w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"`+name+`","password":"password123"}`) // 註冊
+		require.Equal(t, http.StatusOK, w.Code)                                                             // 應註冊成功
+		tokens = append(tokens, loginToken(t, r, name, "password123"))                                      // 登入取得 token
+	}
+
+	w := doAdmin(r, env, http.MethodPost, "/admin/sessions/purge", "") // 未帶確認碼
+	require.Equal(t, http.StatusBadRequest, w.Code)                    // 應被拒絕
+	w = doAuthed(r, tokens[0], http.MethodGet, "/me", "")              // token 仍有效
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/sessions/purge", nil) // 帶確認碼的請求
+	req.Header.Set("X-Admin-Token", env.cfg.AdminAPIKey)                      // admin token
+	req.Header.Set("X-Confirm-Purge", "yes-purge-all")                        // 確認碼
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)                           // 執行 purge
+	require.Equal(t, http.StatusAccepted, w.Code) // 應回 202
+
+	for _, tok := range tokens {
+		w = doAuthed(r, tok, http.MethodGet, "/me", "")   // 既有 token
+		require.Equal(t, http.StatusUnauthorized, w.Code) // 應立即失效
+	}
+	require.Eventually(t, func() bool { // 背景清理完成後 Redis 不再有 session
+		return len(env.mr.Keys()) == 1 && env.mr.Exists("session_epoch")
+	}, 2*time.Second, 10*time.Millisecond)
+
+	tok := loginToken(t, r, "alice", "password123") // purge 後重新登入
+	w = doAuthed(r, tok, http.MethodGet, "/me", "") // 新 token
+	require.Equal(t, http.StatusOK, w.Code)         // 應有效
+}
+
+// TestAdminDeleteAndRestoreUser 測試軟刪除後 token 與登入都失效、admin 查不到該 user，還原後可再登入，超過期限回 410。
+func TestAdminDeleteAndRestoreUser(t *testing.T) {
+	env := newTestEnv(t)                      // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin"        // 設定 admin token
+	env.cfg.UserRestoreGrace = 24 * time.Hour // 一天內可還原
+	r := newTestRouter(env)                   // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	tok := loginToken(t, r, "alice", "password123")                                                  // 登入取得 token
+	user, err := env.q.GetUserByUsername(context.Background(), "alice")                              // 取得 user ID
+	require.NoError(t, err)
+	path := "/admin/users/" + strconv.FormatInt(user.ID, 10)
+
+	w = doAdmin(r, env, http.MethodDelete, path, "") // 軟刪除
+	require.Equal(t, http.StatusOK, w.Code)          // 應成功
+	w = doAdmin(r, env, http.MethodDelete, path, "") // 重複刪除
+	require.Equal(t, http.StatusNotFound, w.Code)    // 已刪除視為找不到
+
+	w = doAuthed(r, tok, http.MethodGet, "/me", "")                                                // 既有 token
+	require.Equal(t, http.StatusUnauthorized, w.Code)                                              // 應失效
+	w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123"}`) // 再次登入
+	require.Equal(t, http.StatusUnauthorized, w.Code)                                              // 應被拒絕
+	w = doAdmin(r, env, http.MethodGet, path, "")                                                  // admin 查詢
+	require.Equal(t, http.StatusNotFound, w.Code)                                                  // 查不到軟刪除的 user
+
+	w = doAdmin(r, env, http.MethodPost, path+"/restore", "") // 期限內還原
+	require.Equal(t, http.StatusOK, w.Code)                   // 應成功
+	loginToken(t, r, "alice", "password123")                  // 還原後可再登入
+
+	w = doAdmin(r, env, http.MethodDelete, path, "") // 再次刪除
+	require.Equal(t, http.StatusOK, w.Code)
+	_, err = env.sqlDB.ExecContext(context.Background(), "UPDATE users SET deleted_at = ? WHERE id = ?", time.Now().Add(-48*time.Hour), user.ID)
+	require.NoError(t, err)                                   // 將刪除時間推到期限之外
+	w = doAdmin(r, env, http.MethodPost, path+"/restore", "") // 超過期限還原
+	require.Equal(t, http.StatusGone, w.Code)                 // 應回 410
+}
+
+// TestAdminExportUser 測試匯出包含各區段的資料，且不含密碼雜湊。
+func TestAdminExportUser(t *testing.T) {
+	env := newTestEnv(t)                           // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin"             // 設定 admin token
+	r := newTestRouter(env)                        // 建立完整 router
+	userID, kicked := loginForAdminTest(t, env, r) // 建立第一個 session
+	ctx := context.Background()                    // 共用 context
+
+	require.NoError(t, env.sessSvc.KickSession(ctx, userID, kicked))                                      // admin 踢掉第一個 session，留下撤銷紀錄
+	_, active, _, err := env.sessSvc.Login(ctx, "alice", "password123", session.LoginMeta{IP: "1.2.3.4"}) // 第二個 session 保持活躍
+	require.NoError(t, err)                                                                               // 應登入成功
+	_, err = env.sqlDB.ExecContext(ctx,
+		"INSERT INTO login_events (user_id, username, success, reason, ip, country) VALUES (?, 'alice', 1, 'ok', '1.2.3.4', 'TW')", userID) // 模擬 worker 寫入的登入紀錄
+	require.NoError(t, err)                                                                                // 應寫入成功
+	_, err = env.sqlDB.ExecContext(ctx, "UPDATE users SET last_login_ip = '1.2.3.4' WHERE id = ?", userID) // 模擬 worker 更新上次登入 IP
+	require.NoError(t, err)                                                                                // 應更新成功
+
+	path := "/admin/users/" + strconv.FormatInt(userID, 10) + "/export"      // export 路徑
+	w := doAdmin(r, env, http.MethodPost, path, "")                          // 匯出
+	require.Equal(t, http.StatusOK, w.Code)                                  // 應成功
+	require.Contains(t, w.Header().Get("Content-Disposition"), "attachment") // 以附件下載
+	require.NotContains(t, w.Body.String(), "password_hash")                 // 不含密碼雜湊欄位
+	require.NotContains(t, w.Body.String(), "$2a$")                          // 不含 bcrypt 雜湊
+
+	var export session.UserExport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &export))        // 解析回應
+	require.Equal(t, "alice", export.Profile.Username)                 // 基本資料
+	require.Equal(t, "1.2.3.4", export.Profile.LastLoginIP)            // 帶有上次登入 IP
+	require.Len(t, export.ActiveSessions, 1)                           // 活躍 session
+	require.Equal(t, active, export.ActiveSessions[0].SessionID)       // 為第二個 session
+	require.Len(t, export.SessionHistory, 2)                           // 兩筆 session 歷史
+	require.Equal(t, "admin:kick", export.SessionHistory[1].RevokedBy) // 第一個 session 記錄被 admin 踢除
+	require.Len(t, export.LoginEvents, 1)                              // 登入紀錄
+	require.Equal(t, "TW", export.LoginEvents[0].Country)              // 帶有國家
+	require.NotNil(t, export.UsernameChanges)                          // 沒有變更時仍輸出空陣列
+
+	w = doAdmin(r, env, http.MethodPost, "/admin/users/9999/export", "") // 不存在的使用者
+	require.Equal(t, http.StatusNotFound, w.Code)                        // 應回 404
+}
+
+// TestAdminListAllSessionsPaginatesAndFilters 測試跨使用者的 session 列表以 cursor 分頁走完所有 session，並支援 IP / device / 建立時間過濾。
+func TestAdminListAllSessionsPaginatesAndFilters(t *testing.T) {
+	env := newTestEnv(t)               // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin" // 設定 admin token
+	env.cfg.MaxSessionsPerUser = 5     // 每個使用者保留兩個 session
+	r := newTestRouter(env)            // 建立完整 router
+
+	ctx := context.Background()   // 背景 context
+	owners := map[string]int64{}  // session ID 對應的擁有者
+	metas := []session.LoginMeta{ // 每個使用者的兩個登入來源
+		{IP: "10.0.0.1", UserAgent: "Mozilla/5.0 (iPhone)"},
+		{IP: "10.0.0.2", UserAgent: "curl/8.0"},
+	}
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"`+name+`","password":"password123"}`) // 註冊
+		require.Equal(t, http.StatusOK, w.Code)                                                             // 應註冊成功
+		for _, meta := range metas {
+			u, sid, _, err := env.sessSvc.Login(ctx, name, "password123", meta) // 建立 session
+			require.NoError(t, err)                                             // 應登入成功
+			owners[sid] = u.ID                                                  // 記錄擁有者
+		}
+	}
+
+	type page struct {
+		Sessions   []session.GlobalSessionInfo `json:"sessions"`
+		NextCursor string                      `json:"next_cursor"`
+	}
+	listAll := func(query string) []session.GlobalSessionInfo {
+		var all []session.GlobalSessionInfo
+		cursor := ""
+		for i := 0; i < 20; i++ { // 防止 cursor 出錯時無限迴圈
+			w := doAdmin(r, env, http.MethodGet, "/admin/sessions?limit=3&cursor="+cursor+query, "") // 取一頁
+			require.Equal(t, http.StatusOK, w.Code)                                                  // 應成功
+			var p page
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p)) // 解析回應
+			require.LessOrEqual(t, len(p.Sessions), 3)             // 每頁不超過 limit
+			all = append(all, p.Sessions...)                       // 累積結果
+			if p.NextCursor == "" {
+				return all // 沒有下一頁
+			}
+			cursor = p.NextCursor // 換下一頁
+		}
+		t.Fatal("pagination did not finish")
+		return nil
+	}
+
+	all := listAll("")        // 不過濾，走完所有頁
+	require.Len(t, all, 8)    // 四個使用者各兩個 session
+	seen := map[string]bool{} // 檢查沒有重複
+	for _, s := range all {
+		require.False(t, seen[s.SessionID])             // 每個 session 只出現一次
+		seen[s.SessionID] = true                        // 標記已出現
+		require.Equal(t, owners[s.SessionID], s.UserID) // 擁有者正確
+	}
+
+	byIP := listAll("&ip=10.0.0.1") // 依 IP 過濾
+	require.Len(t, byIP, 4)         // 每個使用者一個
+	for _, s := range byIP {
+		require.Equal(t, "10.0.0.1", s.IP) // 只有指定 IP
+	}
+	require.Len(t, listAll("&device=CURL"), 4)               // 依 user agent 過濾（不分大小寫）
+	require.Len(t, listAll("&ip=10.0.0.2&device=iphone"), 0) // 條件都要符合
+
+	old := all[0].SessionID                                                                   // 把其中一個 session 改成兩小時前建立
+	oldCreated := strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10)                  // 兩小時前
+	require.NoError(t, env.rdb.HSet(ctx, infra.SessKey(old), "created_at", oldCreated).Err()) // 改寫建立時間
+	aged := listAll("&min_age_seconds=3600")                                                  // 建立超過一小時
+	require.Len(t, aged, 1)                                                                   // 只有被改寫的那個
+	require.Equal(t, old, aged[0].SessionID)                                                  // 正是該 session
+	require.Len(t, listAll("&max_age_seconds=3600"), 7)                                       // 其餘都在一小時內
+
+	w := doAdmin(r, env, http.MethodGet, "/admin/sessions?cursor=not-a-cursor", "") // 不合法的 cursor
+	require.Equal(t, http.StatusBadRequest, w.Code)                                 // 應回 400
+	w = doAdmin(r, env, http.MethodGet, "/admin/sessions?limit=-1", "")             // 不合法的 limit
+	require.Equal(t, http.StatusBadRequest, w.Code)                                 // 應回 400
+}
+
+// TestSessionRequestCountGrows 測試通過 JWT 驗證的請求會累加 session 的 request_count，並出現在 admin 的 session 列表與 inspect。
+func TestSessionRequestCountGrows(t *testing.T) {
+	env := newTestEnv(t)                           // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin"             // 設定 admin token
+	env.cfg.RequestCountInterval = time.Nanosecond // 間隔極短，每個請求都寫入 Redis
+	r := newTestRouter(env)                        // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	accessToken := loginToken(t, r, "alice", "password123")                                          // 登入
+
+	count := func() (int64, string) {
+		w := doAdmin(r, env, http.MethodGet, "/admin/sessions", "") // 列出所有 session
+		require.Equal(t, http.StatusOK, w.Code)                     // 應成功
+		var page session.GlobalSessionsPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page)) // 解析回應
+		require.Len(t, page.Sessions, 1)                          // 只有一個 session
+		return page.Sessions[0].RequestCount, page.Sessions[0].SessionID
+	}
+
+	n, _ := count()    // 登入後還沒有經過驗證的請求
+	require.Zero(t, n) // 尚未累計
+
+	for i := 0; i < 3; i++ {
+		w = doAuthed(r, accessToken, http.MethodGet, "/me", "") // 帶 token 的請求
+		require.Equal(t, http.StatusOK, w.Code)                 // 應成功
+	}
+	n, sid := count()            // 再次列出
+	require.EqualValues(t, 3, n) // 三個請求都被計入
+
+	w = doAuthed(r, accessToken, http.MethodGet, "/me", "") // 再一個請求
+	require.Equal(t, http.StatusOK, w.Code)                 // 應成功
+
+	w = doAdmin(r, env, http.MethodGet, "/admin/sessions/"+sid, "") // inspect 同一個 session
+	require.Equal(t, http.StatusOK, w.Code)                         // 應成功
+	var info session.SessionInspection
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info)) // 解析回應
+	require.EqualValues(t, 4, info.RequestCount)              // 計數持續成長
+}
+
+// TestAdminSetFeatureFlag 測試 POST /admin/flags/:name 在執行期開啟 extend_session_on_refresh 後，refresh 立即延長 session。
+func TestAdminSetFeatureFlag(t *testing.T) {
+	env := newTestEnv(t)                   // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin"     // 設定 admin token
+	env.cfg.ExtendSessionOnRefresh = false // 設定檔關閉 refresh 延長 session
+	r := newTestRouter(env)                // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	tok := loginToken(t, r, "alice", "password123")                                                  // 登入
+
+	var sessKey string
+	for _, k := range env.mr.Keys() {
+		if strings.HasPrefix(k, "sess:") {
+			sessKey = k // 唯一的 session
+		}
+	}
+	require.NotEmpty(t, sessKey) // 應存在 session
+
+	// refresh 的效果以 TTL 判斷：先讓 session 看起來已使用 10 分鐘
+	shorten := func() time.Duration {
+		expires := time.Now().Add(env.cfg.SessionTTL - 10*time.Minute)            // 提前 10 分鐘到期
+		env.mr.HSet(sessKey, "expires_at", strconv.FormatInt(expires.Unix(), 10)) // 到期時間提前
+		env.mr.SetTTL(sessKey, time.Until(expires))                               // TTL 同步
+		return env.mr.TTL(sessKey)
+	}
+
+	before := shorten()                                        // refresh 前的 TTL
+	w = doAuthed(r, tok, http.MethodPost, "/auth/refresh", "") // flag 未設定時 refresh
+	require.Equal(t, http.StatusOK, w.Code)                    // 應成功
+	require.Equal(t, before, env.mr.TTL(sessKey))              // 沿用設定檔，session TTL 不變
+
+	w = doAdmin(r, env, http.MethodPost, "/admin/flags/extend_session_on_refresh", `{"enabled":true}`) // 執行期開啟
+	require.Equal(t, http.StatusOK, w.Code)                                                            // 應成功
+	require.JSONEq(t, `{"name":"extend_session_on_refresh","enabled":true}`, w.Body.String())          // 回傳切換後的值
+	stored, err := env.mr.Get(infra.FeatureFlagKey("extend_session_on_refresh"))                       // 讀取 Redis 中的 flag
+	require.NoError(t, err)                                                                            // 應已寫入
+	require.Equal(t, "1", stored)                                                                      // 以 "1" 表示開啟
+
+	before = shorten()                                         // refresh 前的 TTL
+	w = doAuthed(r, tok, http.MethodPost, "/auth/refresh", "") // flag 開啟後 refresh
+	require.Equal(t, http.StatusOK, w.Code)                    // 應成功
+	require.Greater(t, env.mr.TTL(sessKey), before)            // Redis 的 flag 優先於設定檔，session TTL 已延長
+
+	w = doAdmin(r, env, http.MethodPost, "/admin/flags/unknown", `{"enabled":true}`)     // 未註冊的 flag
+	require.Equal(t, http.StatusNotFound, w.Code)                                        // 應回 404
+	w = doAdmin(r, env, http.MethodPost, "/admin/flags/extend_session_on_refresh", `{}`) // 缺少 enabled
+	require.Equal(t, http.StatusBadRequest, w.Code)                                      // 應回 400
+}
+
+// TestAdminFailedLogins 測試登入失敗次數只計算該使用者在時間範圍內的失敗紀錄，並可附上不重複的來源 IP。
+func TestAdminFailedLogins(t *testing.T) {
+	env := newTestEnv(t)                      // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin"        // 設定 admin token
+	r := newTestRouter(env)                   // 建立完整 router
+	userID, _ := loginForAdminTest(t, env, r) // 建立使用者 alice
+	ctx := context.Background()               // 共用 context
+	now := time.Now().UTC()                   // 以 UTC 寫入 created_at，與 CURRENT_TIMESTAMP 一致
+	ago := func(d time.Duration) string {
+		return now.Add(-d).Format(time.DateTime) // 轉成 SQLite DATETIME 格式
+	}
+	for _, e := range []struct {
+		username string
+		success  bool
+		ip       string
+		at       string
+	}{
+		{"alice", false, "203.0.113.1", ago(10 * time.Minute)},  // 一小時內的失敗
+		{"alice", false, "203.0.113.1", ago(20 * time.Minute)},  // 同一 IP 再失敗一次
+		{"alice", false, "198.51.100.7", ago(30 * time.Minute)}, // 另一個 IP 的失敗
+		{"alice", false, "192.0.2.9", ago(2 * time.Hour)},       // 超過一小時的失敗
+		{"alice", true, "192.0.2.10", ago(5 * time.Minute)},     // 成功登入不計入
+		{"mallory", false, "192.0.2.11", ago(5 * time.Minute)},  // 其他使用者不計入
+	} {
+		_, err := env.sqlDB.ExecContext(ctx,
+			"INSERT INTO login_events (username, success, reason, ip, created_at) VALUES (?, ?, 'test', ?, ?)", e.username, e.success, e.ip, e.at) // 寫入登入紀錄
+		require.NoError(t, err) // 應寫入成功
+	}
+
+	path := "/admin/users/" + strconv.FormatInt(userID, 10) + "/failed-logins" // 查詢路徑
+	var resp struct {
+		FailedLogins int64    `json:"failed_logins"`
+		IPs          []string `json:"ips"`
+	}
+
+	w := doAdmin(r, env, http.MethodGet, path+"?include_ips=true", "")  // 預設統計最近一小時
+	require.Equal(t, http.StatusOK, w.Code)                             // 應成功
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))           // 解析回應
+	require.EqualValues(t, 3, resp.FailedLogins)                        // 一小時內 alice 失敗 3 次
+	require.Equal(t, []string{"198.51.100.7", "203.0.113.1"}, resp.IPs) // 不重複的來源 IP
+
+	resp.IPs = nil                                                                                     // 重設回應
+	w = doAdmin(r, env, http.MethodGet, path+"?since="+now.Add(-3*time.Hour).Format(time.RFC3339), "") // 往回三小時
+	require.Equal(t, http.StatusOK, w.Code)                                                            // 應成功
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))                                          // 解析回應
+	require.EqualValues(t, 4, resp.FailedLogins)                                                       // 包含兩小時前的失敗
+	require.Nil(t, resp.IPs)                                                                           // 未要求時不回傳 IP
+
+	w = doAdmin(r, env, http.MethodGet, path+"?since="+now.Add(-15*time.Minute).Format(time.RFC3339), "") // 只看最近 15 分鐘
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))                                             // 解析回應
+	require.EqualValues(t, 1, resp.FailedLogins)                                                          // 只有 10 分鐘前那一次
+
+	w = doAdmin(r, env, http.MethodGet, path+"?since=yesterday", "") // since 格式錯誤
+	require.Equal(t, http.StatusBadRequest, w.Code)                  // 應回 400
+
+	w = doAdmin(r, env, http.MethodGet, "/admin/users/9999/failed-logins", "") // 不存在的使用者
+	require.Equal(t, http.StatusNotFound, w.Code)                              // 應回 404
+}
+
+// TestAdminShadowBanUser 測試 shadow ban 不影響既有 token 與登入，並出現在 admin 使用者詳情中。
+func TestAdminShadowBanUser(t *testing.T) {
+	env := newTestEnv(t)               // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin" // 設定 admin token
+	r := newTestRouter(env)            // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	tok := loginToken(t, r, "alice", "password123")                                                  // 登入取得 token
+	user, err := env.q.GetUserByUsername(context.Background(), "alice")                              // 取得 user ID
+	require.NoError(t, err)                                                                          // 應查詢成功
+	path := "/admin/users/" + strconv.FormatInt(user.ID, 10)                                         // 使用者路徑
+	var detail struct {
+		IsBanned     bool `json:"is_banned"`
+		ShadowBanned bool `json:"shadow_banned"`
+	}
+
+	w = doAdmin(r, env, http.MethodPost, path+"/shadow-ban", "") // shadow ban
+	require.Equal(t, http.StatusOK, w.Code)                      // 應成功
+	w = doAdmin(r, env, http.MethodGet, path, "")                // 查詢詳情
+	require.Equal(t, http.StatusOK, w.Code)                      // 應成功
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))  // 解析回應
+	require.True(t, detail.ShadowBanned)                         // 應標示 shadow ban
+	require.False(t, detail.IsBanned)                            // 不是一般 ban
+
+	w = doAuthed(r, tok, http.MethodGet, "/me", "") // 既有 token
+	require.Equal(t, http.StatusOK, w.Code)         // 仍然有效
+	loginToken(t, r, "alice", "password123")        // 仍可登入
+
+	w = doAdmin(r, env, http.MethodPost, path+"/unshadow-ban", "") // 解除 shadow ban
+	require.Equal(t, http.StatusOK, w.Code)                        // 應成功
+	w = doAdmin(r, env, http.MethodGet, path, "")                  // 再次查詢
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))    // 解析回應
+	require.False(t, detail.ShadowBanned)                          // flag 應已移除
+}
+
+// TestAdminRevocationStats 測試依撤銷原因統計時間區間內的 session 數，沒有紀錄的原因為 0。
+func TestAdminRevocationStats(t *testing.T) {
+	env := newTestEnv(t)                          // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin"            // 設定 admin token
+	env.cfg.MaxSessionsPerUser = 10               // 避免觸發同時登入上限
+	r := newTestRouter(env)                       // 建立完整 router
+	userID, first := loginForAdminTest(t, env, r) // 建立第一個 session
+	ctx := context.Background()                   // 共用 context
+	login := func() string {
+		_, sid, _, err := env.sessSvc.Login(ctx, "alice", "password123", session.LoginMeta{}) // 再建立一個 session
+		require.NoError(t, err)                                                               // 應登入成功
+		return sid
+	}
+	second, third, old := login(), login(), login() // 另外三個 session
+
+	require.NoError(t, env.sessSvc.Logout(ctx, userID, first))      // 使用者登出
+	require.NoError(t, env.sessSvc.Logout(ctx, userID, second))     // 使用者登出
+	require.NoError(t, env.sessSvc.KickSession(ctx, userID, third)) // admin 踢除
+	require.NoError(t, env.sessSvc.KickSession(ctx, userID, old))   // admin 踢除
+	_, err := env.sqlDB.ExecContext(ctx, "UPDATE sessions SET revoked_at = ? WHERE id = ?",
+		time.Now().UTC().Add(-48*time.Hour).Format(time.DateTime), old)
+	require.NoError(t, err) // 把最後一筆的撤銷時間推到兩天前
+
+	var resp struct {
+		Total    int64            `json:"total"`
+		ByReason map[string]int64 `json:"by_reason"`
+	}
+	w := doAdmin(r, env, http.MethodGet, "/admin/stats/revocations", "") // 預設統計最近 24 小時
+	require.Equal(t, http.StatusOK, w.Code)                              // 應成功
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))            // 解析回應
+	require.EqualValues(t, 3, resp.Total)                                // 兩天前的撤銷不計入
+	require.EqualValues(t, 2, resp.ByReason["user"])                     // 兩次登出
+	require.EqualValues(t, 1, resp.ByReason["admin:kick"])               // 一次踢除
+	require.Contains(t, resp.ByReason, "admin:ban")                      // 沒有紀錄的原因也列出
+	require.Zero(t, resp.ByReason["admin:ban"])                          // 數量為 0
+
+	since := time.Now().Add(-72 * time.Hour).Format(time.RFC3339)                    // 往回三天
+	w = doAdmin(r, env, http.MethodGet, "/admin/stats/revocations?since="+since, "") // 指定起始時間
+	require.Equal(t, http.StatusOK, w.Code)                                          // 應成功
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))                        // 解析回應
+	require.EqualValues(t, 4, resp.Total)                                            // 包含兩天前的撤銷
+	require.EqualValues(t, 2, resp.ByReason["admin:kick"])                           // 兩次踢除
+
+	w = doAdmin(r, env, http.MethodGet, "/admin/stats/revocations?since=yesterday", "") // since 格式錯誤
+	require.Equal(t, http.StatusBadRequest, w.Code)                                     // 應回 400
+	until := time.Now().Add(-96 * time.Hour).Format(time.RFC3339)                       // 比 since 更早的 until
+	w = doAdmin(r, env, http.MethodGet, "/admin/stats/revocations?since="+since+"&until="+until, "")
+	require.Equal(t, http.StatusBadRequest, w.Code) // 區間不合法應回 400
+}
+
+// TestAdminSetUserRolesSessionTTL 測試 admin 設定角色後，該使用者登入時 session 與 token 都採用 ROLE_SESSION_TTL，一般使用者沿用 SessionTTL。
+func TestAdminSetUserRolesSessionTTL(t *testing.T) {
+	env := newTestEnv(t)                                                         // 建立測試環境（SessionTTL = 1 小時）
+	env.cfg.AdminAPIKey = "test-admin"                                           // 設定 admin token
+	env.cfg.RoleSessionTTL = map[string]time.Duration{"admin": 15 * time.Minute} // admin 只有 15 分鐘
+	r := newTestRouter(env)                                                      // 建立完整 router
+
+	for _, name := range []string{"alice", "bob"} {
+		w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"`+name+`","password":"password123"}`) // 註冊
+		require.Equal(t, http.StatusOK, w.Code)                                                             // 應註冊成功
+	}
+	alice, err := env.q.GetUserByUsername(context.Background(), "alice") // 取得 alice 的 user id
+	require.NoError(t, err)                                              // 應查詢成功
+
+	path := "/admin/users/" + strconv.FormatInt(alice.ID, 10) + "/roles"                     // 設定角色的路徑
+	w := doAdmin(r, env, http.MethodPut, path, `{"roles":["Admin"]}`)                        // 設為 admin
+	require.Equal(t, http.StatusOK, w.Code)                                                  // 應成功
+	require.JSONEq(t, `{"roles":["admin"]}`, w.Body.String())                                // 角色轉成小寫
+	w = doAdmin(r, env, http.MethodPut, path, `{"roles":["not valid"]}`)                     // 含空白的角色名稱
+	require.Equal(t, http.StatusBadRequest, w.Code)                                          // 應回 400
+	w = doAdmin(r, env, http.MethodPut, "/admin/users/999/roles", `{"roles":[]}`)            // 不存在的使用者
+	require.Equal(t, http.StatusNotFound, w.Code)                                            // 應回 404
+	w = doAdmin(r, env, http.MethodGet, "/admin/users/"+strconv.FormatInt(alice.ID, 10), "") // 查詢使用者
+	require.Contains(t, w.Body.String(), `"roles":["admin"]`)                                // 回應帶有角色
+
+	for _, tc := range []struct {
+		username string        // 登入的使用者
+		ttl      time.Duration // 預期的存活時間
+	}{
+		{"alice", 15 * time.Minute}, // admin 取得較短的 TTL
+		{"bob", time.Hour},          // 一般使用者沿用 SessionTTL
+	} {
+		w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"`+tc.username+`","password":"password123"}`) // 登入
+		require.Equal(t, http.StatusOK, w.Code)                                                                  // 應登入成功
+		var resp loginResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))                                      // 解析回應
+		require.InDelta(t, tc.ttl.Seconds(), float64(resp.ExpiresIn), 2)                               // expires_in 依角色而定
+		parsed, err := env.jwtMgr.Parse(resp.AccessToken)                                              // 解析 token
+		require.NoError(t, err)                                                                        // 應為合法 token
+		require.WithinDuration(t, time.Now().Add(tc.ttl), parsed.Claims.ExpiresAt.Time, 2*time.Second) // token exp 依角色而定
+		ttl := env.mr.TTL(infra.SessKey(parsed.Claims.SessionID))                                      // Redis session 的 TTL
+		require.InDelta(t, tc.ttl.Seconds(), ttl.Seconds(), 2)                                         // 與 token 一致
+	}
+}
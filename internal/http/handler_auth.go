@@ -1,13 +1,18 @@
 package http
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 
+	"sessionservice/internal/challenge"
+	"sessionservice/internal/config"
 	"sessionservice/internal/db"
 	"sessionservice/internal/middleware"
 	"sessionservice/internal/session"
@@ -16,47 +21,125 @@ import (
 
 // AuthHandler 負責處理與帳號/登入相關的 HTTP 請求。
 type AuthHandler struct {
-	q         *db.Queries
-	jwtMgr    *token.Manager
-	sessSvc   *session.SessionService
-	tokenTTL  time.Duration
+	q       *db.Queries
+	jwtMgr  *token.Manager
+	sessSvc *session.SessionService
+	cfg     *config.Config
+
+	// signupChallenge 為 nil 時代表未啟用 signup challenge。
+	signupChallenge challenge.Verifier
+
+	// streams 追蹤 SSE 串流，服務關閉時通知它們結束。
+	streams *SSERegistry
 }
 
 // NewAuthHandler 建立 AuthHandler。
-func NewAuthHandler(q *db.Queries, jwtMgr *token.Manager, sessSvc *session.SessionService, tokenTTL time.Duration) *AuthHandler {
+func NewAuthHandler(q *db.Queries, jwtMgr *token.Manager, sessSvc *session.SessionService, cfg *config.Config, signupChallenge challenge.Verifier) *AuthHandler {
 	return &AuthHandler{
-		q:        q,
-		jwtMgr:   jwtMgr,
-		sessSvc:  sessSvc,
-		tokenTTL: tokenTTL,
+		q:               q,
+		jwtMgr:          jwtMgr,
+		sessSvc:         sessSvc,
+		cfg:             cfg,
+		signupChallenge: signupChallenge,
+		streams:         NewSSERegistry(),
 	}
 }
 
+// WithStreams 改用外部建立的 SSERegistry，讓 lifecycle 關閉服務時能通知這個 handler 的串流。
+func (h *AuthHandler) WithStreams(streams *SSERegistry) *AuthHandler {
+	h.streams = streams
+	return h
+}
+
+// signupRequest / loginRequest 同時支援 JSON（文件預設）與 application/x-www-form-urlencoded。
 type signupRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username string `json:"username" form:"username" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required"`
+	Email    string `json:"email,omitempty" form:"email"` // 選填，magic link 登入時以此寄送連結
+
+	// 啟用 signup challenge 時，依模式擇一帶入
+	CaptchaToken string `json:"captcha_token,omitempty" form:"captcha_token"`
+	PoWNonce     string `json:"pow_nonce,omitempty" form:"pow_nonce"`
 }
 
 // Signup 處理使用者註冊。
 func (h *AuthHandler) Signup(c *gin.Context) {
 	var req signupRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	req.Username = session.NormalizeUsername(req.Username)
+	if session.ValidateUsername(req.Username) != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 		return
 	}
+	if h.sessSvc.IsUsernameReserved(req.Username) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username_reserved"})
+		return
+	}
+	req.Email = session.NormalizeEmail(req.Email)
+	if req.Email != "" && session.ValidateEmail(req.Email) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_email"})
+		return
+	}
+	if err := h.sessSvc.CheckPasswordStrength(req.Password, req.Username); err != nil {
+		respondWeakPassword(c, err)
+		return
+	}
+
+	if h.signupChallenge != nil {
+		err := h.signupChallenge.Verify(ctx, challenge.Solution{
+			Username:     req.Username,
+			CaptchaToken: req.CaptchaToken,
+			PoWNonce:     req.PoWNonce,
+			RemoteIP:     c.ClientIP(),
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, challenge.ErrChallengeRequired):
+				c.JSON(http.StatusBadRequest, gin.H{"error": "challenge_required"})
+			case errors.Is(err, challenge.ErrChallengeFailed):
+				c.JSON(http.StatusBadRequest, gin.H{"error": "challenge_failed"})
+			default:
+				// 供應商故障不是使用者的錯，回 503 讓 client 稍後重試
+				log.Printf("signup challenge: %v", err)
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "challenge_unavailable"})
+			}
+			return
+		}
+	}
+
+	// 同一 IP 的註冊冷卻：先佔用名額，註冊沒有成功就歸還；Redis 故障時不阻擋註冊
+	ip := c.ClientIP()
+	if retryAfter, err := h.sessSvc.ClaimSignupSlot(ctx, ip); errors.Is(err, session.ErrSignupCooldown) {
+		middleware.AbortWithRetryAfter(c, http.StatusTooManyRequests, "signup_cooldown", retryAfter)
+		return
+	}
 
-	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashed, peppered, err := h.sessSvc.HashPassword(req.Password)
 	if err != nil {
+		h.sessSvc.ReleaseSignupSlot(ctx, ip)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
 		return
 	}
 
-	ctx := c.Request.Context()
-	user, err := h.q.CreateUser(ctx, db.CreateUserParams{
-		Username:     req.Username,
-		PasswordHash: string(hashed),
+	// 寫入 users 與 SignupHook 在同一個 transaction，外部系統建立失敗時使用者不會留下
+	user, err := h.sessSvc.CreateUser(ctx, db.CreateUserParams{
+		Username:         req.Username,
+		PasswordHash:     hashed,
+		PasswordPeppered: peppered,
+		Email:            sql.NullString{String: req.Email, Valid: req.Email != ""},
 	})
 	if err != nil {
+		h.sessSvc.ReleaseSignupSlot(ctx, ip)
+		if errors.Is(err, session.ErrSignupHookFailed) {
+			log.Printf("signup: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "signup_provisioning_failed"})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to create user"})
 		return
 	}
@@ -67,29 +150,168 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 	})
 }
 
+// respondWeakPassword 回傳 400 password_too_weak，並附上最主要的弱點代碼讓前端顯示對應提示。
+func respondWeakPassword(c *gin.Context, err error) {
+	body := gin.H{"error": "password_too_weak"}
+	var weak *session.WeakPasswordError
+	if errors.As(err, &weak) {
+		body["weakness"] = weak.Weakness
+	}
+	c.JSON(http.StatusBadRequest, body)
+}
+
+// UsernameAvailable 回傳 username 是否尚未被註冊（GET /auth/username-available?u=）。
+// 回應至少會花 UsernameCheckMinResponse 的時間，讓「可用」與「已被使用」無法從回應時間區分。
+func (h *AuthHandler) UsernameAvailable(c *gin.Context) {
+	start := time.Now()
+	defer waitAtLeast(c.Request.Context(), start, h.cfg.UsernameCheckMinResponse)
+
+	username := session.NormalizeUsername(c.Query("u"))
+	if session.ValidateUsername(username) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	// 保留的 username 一律回報不可用
+	if h.sessSvc.IsUsernameReserved(username) {
+		c.JSON(http.StatusOK, gin.H{"available": false})
+		return
+	}
+
+	count, err := h.q.CountUsersByUsername(c.Request.Context(), username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"available": count == 0})
+}
+
+// waitAtLeast 睡到距離 start 至少經過 min；request context 結束時提早返回，不佔住 goroutine。
+func waitAtLeast(ctx context.Context, start time.Time, min time.Duration) {
+	remaining := min - time.Since(start)
+	if remaining <= 0 {
+		return
+	}
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// clientCountry 讀取前端 proxy / CDN 標注的國碼（例如 Cloudflare 的 CF-IPCountry），統一轉成大寫；未設定 header 時回傳空字串。
+// 這個 header 必須由可信任的 proxy 覆寫，否則 client 可以自行偽造。
+func clientCountry(c *gin.Context, header string) string {
+	if header == "" {
+		return ""
+	}
+	return strings.ToUpper(strings.TrimSpace(c.GetHeader(header)))
+}
+
 type loginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username string `json:"username" form:"username" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required"`
+
+	// client 產生的穩定裝置 ID（選填），用來辨識「這台 iPhone」與限制單一裝置的 session 數
+	DeviceID string `json:"device_id,omitempty" form:"device_id" binding:"max=128"`
+
+	// client 產生並存在瀏覽器內的 ID（選填），同一台裝置上的不同瀏覽器各自不同；SINGLE_SESSION_PER_BROWSER 依此只保留一個 session
+	BrowserID string `json:"browser_id,omitempty" form:"browser_id" binding:"max=128"`
+
+	// 登入後要跳轉的位置（選填），必須符合 OAuthAllowedRedirects
+	RedirectURI string `json:"redirect_uri,omitempty" form:"redirect_uri"`
+	ReturnTo    string `json:"return_to,omitempty" form:"return_to"`
+
+	// 為 true 時在回應附上 account_summary（也可用 query ?include_account_summary=true）
+	IncludeAccountSummary bool `json:"include_account_summary,omitempty" form:"include_account_summary"`
 }
 
 type loginResponse struct {
 	AccessToken string `json:"access_token"`
-	ExpiresIn   int64  `json:"expires_in"` // seconds
+	ExpiresIn   int64  `json:"expires_in"`            // seconds
+	RedirectTo  string `json:"redirect_to,omitempty"` // 已通過 allow-list 檢查的跳轉目標
+
+	// session 數達到 SESSION_WARN_THRESHOLD 時才會出現，僅供提示
+	SessionWarning bool `json:"session_warning,omitempty"`
+	ActiveSessions int  `json:"active_sessions,omitempty"`
+	MaxSessions    int  `json:"max_sessions,omitempty"`
+
+	// 登入時帶 include_account_summary=true 才會出現
+	AccountSummary *accountSummaryResponse `json:"account_summary,omitempty"`
+}
+
+// accountSummaryResponse 是 loginResponse.AccountSummary 的內容；從未登入過時 last_login_at / last_login_ip 為 null。
+type accountSummaryResponse struct {
+	ActiveSessions int        `json:"active_sessions"`
+	LastLoginAt    *time.Time `json:"last_login_at"`
+	LastLoginIP    *string    `json:"last_login_ip"`
+}
+
+// addAccountSummary 在登入回應附上 account_summary；查詢失敗只記錄 log，不影響已成功的登入。
+func addAccountSummary(ctx context.Context, sessSvc *session.SessionService, resp *loginResponse, user db.User) {
+	summary, err := sessSvc.AccountSummary(ctx, user)
+	if err != nil {
+		log.Printf("account summary for user %d: %v", user.ID, err)
+		return
+	}
+	resp.AccountSummary = &accountSummaryResponse{
+		ActiveSessions: summary.ActiveSessions,
+		LastLoginAt:    summary.LastLoginAt,
+	}
+	if summary.LastLoginIP != "" {
+		resp.AccountSummary.LastLoginIP = &summary.LastLoginIP
+	}
+}
+
+// addSessionWarning 在使用者的 session 數達到 SESSION_WARN_THRESHOLD 時於登入回應附上 session_warning；
+// 查詢失敗只記錄 log，不影響已成功的登入。
+func addSessionWarning(ctx context.Context, sessSvc *session.SessionService, resp *loginResponse, userID int64) {
+	usage, err := sessSvc.UserSessionUsage(ctx, userID)
+	if err != nil {
+		log.Printf("session usage for user %d: %v", userID, err)
+		return
+	}
+	if usage.Warning {
+		resp.SessionWarning = true
+		resp.ActiveSessions = usage.Active
+		resp.MaxSessions = usage.Max
+	}
 }
 
 // Login 處理登入並回傳 JWT。
+// 設定 LoginMinResponse 時，不論成功或失敗回應都不會早於該時間送出。
 func (h *AuthHandler) Login(c *gin.Context) {
+	start := time.Now()
+	defer waitAtLeast(c.Request.Context(), start, h.cfg.LoginMinResponse)
+
 	var req loginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBind(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 		return
 	}
 
+	// body 內的跳轉目標同樣要通過 allow-list（query 參數已由 ValidateRedirectParams 檢查）
+	redirectTo := req.RedirectURI
+	if redirectTo == "" {
+		redirectTo = req.ReturnTo
+	}
+	for _, target := range []string{req.RedirectURI, req.ReturnTo} {
+		if target != "" && !middleware.RedirectAllowed(target, h.cfg.OAuthAllowedRedirects) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_redirect"})
+			return
+		}
+	}
+
 	ctx := c.Request.Context()
 
 	meta := session.LoginMeta{
 		IP:        c.ClientIP(),
 		UserAgent: c.GetHeader("User-Agent"),
+		DeviceID:  req.DeviceID,
+		BrowserID: req.BrowserID,
+		Country:   clientCountry(c, h.cfg.GeoCountryHeader),
 	}
 
 	user, sessionID, expiresAt, err := h.sessSvc.Login(ctx, req.Username, req.Password, meta)
@@ -98,20 +320,110 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 			return
 		}
+		if err == session.ErrPasswordResetRequired {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":     "password_reset_required",
+				"reset_url": "/auth/password/reset",
+			})
+			return
+		}
+		var expiredErr *session.PasswordExpiredError
+		if errors.As(err, &expiredErr) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":       "password_expired",
+				"reset_url":   "/auth/password/reset",
+				"reset_token": expiredErr.ResetToken,
+			})
+			return
+		}
+		if err == session.ErrSessionLimitReached {
+			c.JSON(http.StatusConflict, gin.H{"error": "session_limit_reached"})
+			return
+		}
+		if err == session.ErrCountryBlocked {
+			c.JSON(http.StatusForbidden, gin.H{"error": "country_blocked"})
+			return
+		}
+		if err == session.ErrMultiCountrySession {
+			c.JSON(http.StatusForbidden, gin.H{"error": "multi_country_session"})
+			return
+		}
+		var rateErr *session.LoginRateLimitError
+		if errors.As(err, &rateErr) {
+			middleware.AbortWithRetryAfter(c, http.StatusTooManyRequests, "login_rate_limited", rateErr.RetryAfter)
+			return
+		}
+		if errors.Is(err, session.ErrPasswordCheckBusy) {
+			// bcrypt 比對名額已滿，請 client 稍後重試，避免登入尖峰拖慢其他請求
+			middleware.AbortWithRetryAfter(c, http.StatusServiceUnavailable, "login_busy", h.cfg.BcryptQueueTimeout)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
 		return
 	}
 
-	tokenStr, err := h.jwtMgr.GenerateWithSession(user.ID, sessionID, expiresAt)
+	tokenStr, tokenExp, err := signSessionToken(ctx, h.sessSvc, h.jwtMgr, user.ID, sessionID, expiresAt, token.AMRPassword)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, loginResponse{
+	expiresIn := h.sessSvc.SessionTTLFor(user)
+	if !tokenExp.Equal(expiresAt) {
+		// exp 被縮短到 session 的到期時間
+		expiresIn = time.Until(tokenExp)
+	}
+	resp := loginResponse{
 		AccessToken: tokenStr,
-		ExpiresIn:   int64(h.tokenTTL.Seconds()),
-	})
+		ExpiresIn:   int64(expiresIn.Seconds()),
+		RedirectTo:  redirectTo,
+	}
+	addSessionWarning(ctx, h.sessSvc, &resp, user.ID)
+	if req.IncludeAccountSummary || c.Query("include_account_summary") == "true" {
+		addAccountSummary(ctx, h.sessSvc, &resp, user)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token" form:"token" binding:"required"`
+	NewPassword string `json:"new_password" form:"new_password" binding:"required"`
+}
+
+// ResetPassword 以一次性的重設 token 設定新密碼（包含被 admin force-reset 或密碼過期而無法登入的使用者）。
+// token 由 admin、force-reset 的通知信或密碼過期的登入回應發出；不接受目前的密碼，避免外洩的密碼被拿來接管帳號。
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	meta := session.LoginMeta{
+		IP:        c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+		Country:   clientCountry(c, h.cfg.GeoCountryHeader),
+	}
+	err := h.sessSvc.ResetPassword(c.Request.Context(), req.Token, req.NewPassword, meta)
+	if err != nil {
+		switch {
+		case errors.Is(err, session.ErrResetTokenInvalid):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "reset_token_invalid"})
+		case errors.Is(err, session.ErrUserBanned):
+			c.JSON(http.StatusForbidden, gin.H{"error": "user is banned"})
+		case errors.Is(err, session.ErrPasswordReused):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "new password must differ from the current one"})
+		case errors.Is(err, session.ErrPasswordTooWeak):
+			respondWeakPassword(c, err)
+		case errors.Is(err, session.ErrPasswordCheckBusy):
+			middleware.AbortWithRetryAfter(c, http.StatusServiceUnavailable, "login_busy", h.cfg.BcryptQueueTimeout)
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
 // Me 回傳目前登入使用者的簡單資訊。
@@ -139,10 +451,66 @@ func (h *AuthHandler) Me(c *gin.Context) {
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{
+		"id":            user.ID,
+		"username":      user.Username,
+		"created":       user.CreatedAt,
+		"last_login_at": nullTimePtr(user.LastLoginAt),
+	})
+}
+
+type changeUsernameRequest struct {
+	NewUsername string `json:"new_username" form:"new_username" binding:"required"`
+}
+
+// ChangeUsername 變更目前登入使用者的 username，並撤銷目前 session 以外的其他 session。
+func (h *AuthHandler) ChangeUsername(c *gin.Context) {
+	var req changeUsernameRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	userIDVal, ok := c.Get(middleware.ContextKeyUserID)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing user in context"})
+		return
+	}
+	sessionIDVal, ok := c.Get(middleware.ContextKeySessionID)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing session in context"})
+		return
+	}
+
+	userID, ok := userIDVal.(int64)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id type"})
+		return
+	}
+	sessionID, ok := sessionIDVal.(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid session id type"})
+		return
+	}
+
+	user, err := h.sessSvc.ChangeUsername(c.Request.Context(), userID, sessionID, req.NewUsername)
+	if err != nil {
+		switch {
+		case errors.Is(err, session.ErrInvalidUsername):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid username"})
+		case errors.Is(err, session.ErrUsernameReserved):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "username_reserved"})
+		case errors.Is(err, session.ErrUsernameTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "username already taken"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change username"})
+		}
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"id":       user.ID,
 		"username": user.Username,
-		"created":  user.CreatedAt,
 	})
 }
 
@@ -178,4 +546,149 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+// Refresh 以目前仍有效的 access token 換發同一個 session 的新 token，amr 沿用原本的登入方式。
+// 設定 ExtendSessionOnRefresh 時一併延長 session；新 token 的到期時間一律等於 session 的到期時間。
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	userID := c.GetInt64(middleware.ContextKeyUserID)
+	sessionID := c.GetString(middleware.ContextKeySessionID)
+	if userID == 0 || sessionID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing session in context"})
+		return
+	}
+
+	expiresAt, err := h.sessSvc.RefreshSession(c.Request.Context(), userID, sessionID)
+	if err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session_invalid"})
+			return
+		}
+		if errors.Is(err, session.ErrRefreshFamilyExpired) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh_family_expired"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "refresh failed"})
+		return
+	}
+
+	amr := c.GetStringSlice(middleware.ContextKeyAMR)
+	tokenStr, expiresAt, err := signSessionToken(c.Request.Context(), h.sessSvc, h.jwtMgr, userID, sessionID, expiresAt, amr...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse{
+		AccessToken: tokenStr,
+		ExpiresIn:   int64(time.Until(expiresAt).Seconds()),
+	})
+}
+
+// tokenInfoResponse 是 GET /auth/token-info 的回應，時間皆為 RFC 3339。
+type tokenInfoResponse struct {
+	IssuedAt         *time.Time `json:"issued_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	SecondsRemaining int64      `json:"seconds_remaining"`
+	SessionExpiresAt time.Time  `json:"session_expires_at"`
+}
+
+// TokenInfo 回傳目前 token 的簽發與到期時間，以及 session 的到期時間，讓 SPA 在到期前主動 refresh。
+// 只讀 token claims 與 Redis session hash，不查 DB。
+func (h *AuthHandler) TokenInfo(c *gin.Context) {
+	userID := c.GetInt64(middleware.ContextKeyUserID)
+	sessionID := c.GetString(middleware.ContextKeySessionID)
+	expiresAt := c.GetTime(middleware.ContextKeyTokenExpiresAt)
+	if userID == 0 || sessionID == "" || expiresAt.IsZero() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing session in context"})
+		return
+	}
+
+	sessionExpiresAt, err := h.sessSvc.SessionExpiresAt(c.Request.Context(), userID, sessionID)
+	if err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session_invalid"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read session"})
+		return
+	}
+
+	resp := tokenInfoResponse{
+		ExpiresAt:        expiresAt.UTC(),
+		SessionExpiresAt: sessionExpiresAt.UTC(),
+	}
+	if iat := c.GetTime(middleware.ContextKeyTokenIssuedAt); !iat.IsZero() {
+		iat = iat.UTC()
+		resp.IssuedAt = &iat
+	}
+	if remaining := int64(time.Until(expiresAt).Seconds()); remaining > 0 {
+		resp.SecondsRemaining = remaining
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// PinSession pin 住目前使用者的某個 session，登入數超過上限時優先保留。
+func (h *AuthHandler) PinSession(c *gin.Context) {
+	h.setSessionPinned(c, true)
+}
+
+// UnpinSession 取消目前使用者某個 session 的 pin。
+func (h *AuthHandler) UnpinSession(c *gin.Context) {
+	h.setSessionPinned(c, false)
+}
+
+func (h *AuthHandler) setSessionPinned(c *gin.Context, pinned bool) {
+	userIDVal, ok := c.Get(middleware.ContextKeyUserID)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing user in context"})
+		return
+	}
+	userID, ok := userIDVal.(int64)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id type"})
+		return
+	}
+
+	sessionID := c.Param("sid")
+	if err := h.sessSvc.PinSession(c.Request.Context(), userID, sessionID, pinned); err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"pinned":     pinned,
+	})
+}
+
+// nullTimePtr 將 sql.NullTime 轉成 *time.Time，讓 JSON 在沒有值時輸出 null。
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
 
+// signSessionToken 為 session 簽發 access token（TOKEN_MODE=opaque 時為 reference token，否則為 JWT），
+// exp 先經 SessionTokenExpiry 確認不會超過 session 的到期時間，回傳實際寫入的 exp。
+// 呼叫端傳入的 expiresAt 有誤時依 TokenExpiryPolicy 縮短或拒絕簽發，超過 MAX_TOKEN_TTL_SECONDS 時再縮短。
+func signSessionToken(ctx context.Context, sessSvc *session.SessionService, jwtMgr *token.Manager, userID int64, sessionID string, expiresAt time.Time, amr ...string) (string, time.Time, error) {
+	exp, err := sessSvc.SessionTokenExpiry(ctx, userID, sessionID, expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	exp = jwtMgr.CapExpiry(exp)
+	var tokenStr string
+	if sessSvc.OpaqueTokensEnabled() {
+		tokenStr, err = sessSvc.IssueOpaqueToken(ctx, userID, sessionID, exp, amr...)
+	} else {
+		tokenStr, err = jwtMgr.GenerateWithSession(userID, sessionID, exp, amr...)
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenStr, exp, nil
+}
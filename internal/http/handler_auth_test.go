@@ -0,0 +1,973 @@
+package http
+
+import (
+	"bytes"             // 匯入 bytes，組出 HTTP 請求 body
+	"context"           // 匯入 context，實作假的 challenge.Verifier
+	"database/sql"      // 匯入 database/sql，建立測試用 SQLite 連線
+	"encoding/json"     // 匯入 encoding/json，解析登入回應
+	"net/http"          // 匯入 net/http，提供 HTTP 方法與狀態碼常數
+	"net/http/httptest" // 匯入 httptest，建立 HTTP 測試請求與 ResponseRecorder
+	"net/url"           // 匯入 net/url，組出 form-encoded body
+	"os"                // 匯入 os，用於讀取 migration 檔案內容
+	"strconv"           // 匯入 strconv，改寫 session 的到期時間
+	"strings"           // 匯入 strings，將 form body 包成 io.Reader
+	"testing"           // 匯入 testing 套件，提供單元測試框架
+	"time"              // 匯入 time，設定測試用 TTL
+
+	"github.com/alicebob/miniredis/v2"    // 匯入 miniredis，提供記憶體內 Redis 測試實例
+	"github.com/gin-gonic/gin"            // 匯入 gin，建立測試用路由
+	"github.com/redis/go-redis/v9"        // 匯入 go-redis，用於連線到 miniredis
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/challenge" // 匯入 challenge 套件，提供 Verifier 介面與錯誤值
+	"sessionservice/internal/config"    // 匯入 config 套件，建立測試用設定
+	"sessionservice/internal/db"        // 匯入 db 套件，建立 sqlc Queries
+	"sessionservice/internal/infra"     // 匯入 infra 套件，讀取 user_sess key
+	"sessionservice/internal/session"   // 匯入 session 套件，建立 SessionService
+	"sessionservice/internal/token"     // 匯入 token 套件，建立 JWT Manager
+
+	_ "modernc.org/sqlite" // 匯入 modernc sqlite driver
+)
+
+// testEnv 封裝 handler 測試所需的周邊資源。
+type testEnv struct {
+	sqlDB   *sql.DB                 // SQLite 連線
+	q       *db.Queries             // sqlc Queries
+	rdb     *redis.Client           // 連線到 miniredis 的 Redis client
+	mr      *miniredis.Miniredis    // miniredis 實例
+	cfg     *config.Config          // 測試用設定
+	sessSvc *session.SessionService // SessionService
+	jwtMgr  *token.Manager          // JWT Manager
+}
+
+// newTestEnv 建立 SQLite（套用 migrations）、miniredis、SessionService 與 JWT Manager。
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper() // 標記為測試輔助函式
+
+	sqlDB, err := sql.Open("sqlite", ":memory:") // 建立記憶體內 SQLite DB
+	require.NoError(t, err)                      // 確保開啟成功
+	sqlDB.SetMaxOpenConns(1)                     // :memory: 每條連線各自一份 DB，限制單一連線確保看到同一份 schema
+
+	applyMigrations(t, sqlDB) // 套用所有 migration
+
+	mr, err := miniredis.Run() // 啟動記憶體內 Redis
+	require.NoError(t, err)    // 確保啟動成功
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()}) // 連線到 miniredis
+
+	cfg := &config.Config{ // 建立測試用設定
+		SessionTTL:         time.Hour, // session TTL 1 小時
+		MaxSessionsPerUser: 2,         // 每個使用者最多 2 個 session
+	}
+
+	q := db.New(sqlDB) // 建立 sqlc Queries
+
+	t.Cleanup(func() { // 測試結束時釋放資源
+		_ = sqlDB.Close() // 關閉 SQLite
+		rdb.Close()       // 關閉 Redis client
+		mr.Close()        // 關閉 miniredis
+	})
+
+	return &testEnv{
+		sqlDB:   sqlDB,
+		q:       q,
+		rdb:     rdb,
+		mr:      mr,
+		cfg:     cfg,
+		sessSvc: session.NewSessionService(q, rdb, cfg, nil, nil),
+		jwtMgr:  token.NewManager("test-secret", time.Hour),
+	}
+}
+
+// applyMigrations 將 db/migrations 目錄下的所有 *.up.sql 依序套用到指定 DB。
+func applyMigrations(t *testing.T, sqlDB *sql.DB) {
+	t.Helper()                  // 標記為測試輔助函式
+	migrationFiles := []string{ // 與正式環境相同順序的 migration 檔案
+		"../../db/migrations/001_init.up.sql",
+		"../../db/migrations/002_add_sessions.up.sql",
+		"../../db/migrations/003_add_login_events.up.sql",
+		"../../db/migrations/004_add_user_ban.up.sql",
+		"../../db/migrations/005_add_user_last_login.up.sql",
+		"../../db/migrations/006_add_user_needs_rehash.up.sql",
+		"../../db/migrations/007_add_user_must_reset_password.up.sql",
+		"../../db/migrations/008_add_username_changes.up.sql",
+		"../../db/migrations/009_add_user_password_peppered.up.sql",
+		"../../db/migrations/010_add_user_deleted_at.up.sql",
+		"../../db/migrations/011_add_login_events_country.up.sql",
+		"../../db/migrations/012_add_user_password_changed_at.up.sql",
+		"../../db/migrations/013_add_sessions_revoked_by_check.up.sql",
+		"../../db/migrations/014_add_user_email.up.sql",
+		"../../db/migrations/015_add_user_last_login_ip.up.sql",
+		"../../db/migrations/016_add_revoked_by_session_gone.up.sql",
+		"../../db/migrations/017_add_user_roles.up.sql",
+		"../../db/migrations/018_normalize_usernames.up.sql",
+		"../../db/migrations/019_add_login_events_event_id.up.sql",
+	}
+
+	for _, path := range migrationFiles { // 逐一套用
+		data, err := os.ReadFile(path)                                 // 讀取 SQL 檔案內容
+		require.NoErrorf(t, err, "failed to read migration %s", path)  // 讀取失敗則中止
+		_, err = sqlDB.Exec(string(data))                              // 執行 SQL
+		require.NoErrorf(t, err, "failed to apply migration %s", path) // 確保套用成功
+	}
+}
+
+// doJSON 對 router 送出 JSON 請求並回傳 ResponseRecorder。
+func doJSON(r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body)) // 建立請求
+	req.Header.Set("Content-Type", "application/json")                    // 標記為 JSON body
+	w := httptest.NewRecorder()                                           // 建立 ResponseRecorder
+	r.ServeHTTP(w, req)                                                   // 執行請求
+	return w
+}
+
+// fakeVerifier 是假的 challenge.Verifier，只接受 CaptchaToken 為 "ok" 的解答。
+type fakeVerifier struct {
+	calls int // 被呼叫次數
+}
+
+func (f *fakeVerifier) Verify(_ context.Context, sol challenge.Solution) error {
+	f.calls++                   // 記錄呼叫次數
+	if sol.CaptchaToken == "" { // 沒帶 token
+		return challenge.ErrChallengeRequired
+	}
+	if sol.CaptchaToken != "ok" { // token 錯誤
+		return challenge.ErrChallengeFailed
+	}
+	return nil
+}
+
+// newSignupRouter 建立只掛 signup 路由、並使用指定 Verifier 的測試 router。
+func newSignupRouter(env *testEnv, v challenge.Verifier) *gin.Engine {
+	gin.SetMode(gin.TestMode)                                       // 設為測試模式
+	r := gin.New()                                                  // 建立 Gin Engine
+	h := NewAuthHandler(env.q, env.jwtMgr, env.sessSvc, env.cfg, v) // 建立 AuthHandler
+	r.POST("/auth/signup", h.Signup)                                // 註冊 signup 路由
+	return r
+}
+
+// TestSignupChallengeRejected 測試啟用 challenge 時，缺少或錯誤的 challenge 都應回傳 400。
+func TestSignupChallengeRejected(t *testing.T) {
+	env := newTestEnv(t)         // 建立測試環境
+	v := &fakeVerifier{}         // 使用假的 CAPTCHA 驗證器
+	r := newSignupRouter(env, v) // 建立測試 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 沒帶 challenge
+	require.Equal(t, http.StatusBadRequest, w.Code)                                                  // 應回傳 400
+	require.Contains(t, w.Body.String(), "challenge_required")                                       // 錯誤碼為 challenge_required
+
+	w = doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123","captcha_token":"bad"}`) // 錯誤 token
+	require.Equal(t, http.StatusBadRequest, w.Code)                                                                       // 應回傳 400
+	require.Contains(t, w.Body.String(), "challenge_failed")                                                              // 錯誤碼為 challenge_failed
+
+	_, err := env.q.GetUserByUsername(context.Background(), "alice") // 確認使用者沒有被建立
+	require.ErrorIs(t, err, sql.ErrNoRows)                           // 應查無此人
+}
+
+// TestSignupChallengePassed 測試 challenge 驗證通過時可以正常註冊。
+func TestSignupChallengePassed(t *testing.T) {
+	env := newTestEnv(t)         // 建立測試環境
+	v := &fakeVerifier{}         // 使用假的 CAPTCHA 驗證器
+	r := newSignupRouter(env, v) // 建立測試 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123","captcha_token":"ok"}`) // 帶入正確 token
+	require.Equal(t, http.StatusOK, w.Code)                                                                               // 應註冊成功
+	require.Equal(t, 1, v.calls)                                                                                          // 驗證器應被呼叫一次
+}
+
+// TestSignupChallengeDisabled 測試未啟用 challenge（Verifier 為 nil）時維持原本行為。
+func TestSignupChallengeDisabled(t *testing.T) {
+	env := newTestEnv(t)           // 建立測試環境
+	r := newSignupRouter(env, nil) // 不帶 Verifier
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 一般註冊請求
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+}
+
+// newTestRouter 以完整的 NewRouter 建立測試 router（不啟用 signup challenge）。
+func newTestRouter(env *testEnv) *gin.Engine {
+	gin.SetMode(gin.TestMode)                                                                   // 設為測試模式
+	return NewRouter(env.q, env.rdb, env.jwtMgr, env.sessSvc, env.cfg, nil, nil, nil, nil, nil) // 使用測試環境的依賴建立 router
+}
+
+// TestUsernameAvailable 測試尚未註冊的 username 回傳 available=true，已註冊（含大小寫不同）回傳 false。
+func TestUsernameAvailable(t *testing.T) {
+	env := newTestEnv(t)    // 建立測試環境
+	r := newTestRouter(env) // 建立完整 router
+
+	w := doJSON(r, http.MethodGet, "/auth/username-available?u=alice", "") // 查詢尚未註冊的 username
+	require.Equal(t, http.StatusOK, w.Code)                                // 應回傳 200
+	require.JSONEq(t, `{"available":true}`, w.Body.String())               // 應為可用
+
+	w = doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊 alice
+	require.Equal(t, http.StatusOK, w.Code)                                                         // 應註冊成功
+
+	w = doJSON(r, http.MethodGet, "/auth/username-available?u=alice", "") // 再查一次 alice
+	require.JSONEq(t, `{"available":false}`, w.Body.String())             // 已被使用
+
+	w = doJSON(r, http.MethodGet, "/auth/username-available?u=%20Alice%20", "") // 大小寫與空白不同，正規化後仍為 alice
+	require.JSONEq(t, `{"available":false}`, w.Body.String())                   // 同樣視為已被使用
+}
+
+// TestUsernameAvailableMinResponse 測試設定最短回應時間時，回應不會早於該時間送出。
+func TestUsernameAvailableMinResponse(t *testing.T) {
+	env := newTestEnv(t)                                     // 建立測試環境
+	env.cfg.UsernameCheckMinResponse = 50 * time.Millisecond // 設定最短回應時間
+	r := newTestRouter(env)                                  // 建立完整 router
+
+	start := time.Now()                                                    // 記錄開始時間
+	w := doJSON(r, http.MethodGet, "/auth/username-available?u=alice", "") // 查詢 username
+	require.Equal(t, http.StatusOK, w.Code)                                // 應回傳 200
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)      // 花費時間應不少於設定值
+}
+
+// TestUsernameAvailableRateLimited 測試同一 IP 超過查詢次數上限時回傳 429。
+func TestUsernameAvailableRateLimited(t *testing.T) {
+	env := newTestEnv(t)               // 建立測試環境
+	env.cfg.UsernameCheckRateLimit = 2 // 每分鐘最多 2 次
+	r := newTestRouter(env)            // 建立完整 router
+
+	for i := 0; i < 2; i++ { // 前兩次應正常回應
+		w := doJSON(r, http.MethodGet, "/auth/username-available?u=alice", "")
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w := doJSON(r, http.MethodGet, "/auth/username-available?u=alice", "") // 第三次查詢
+	require.Equal(t, http.StatusTooManyRequests, w.Code)                   // 應被 rate limit
+	require.Equal(t, "60", w.Header().Get("Retry-After"))                  // 告知 client 視窗剩餘秒數
+}
+
+// TestLoginMinResponse 測試設定最短回應時間時，登入成功與失敗的回應都不會早於該時間送出。
+func TestLoginMinResponse(t *testing.T) {
+	env := newTestEnv(t)                              // 建立測試環境
+	env.cfg.LoginMinResponse = 200 * time.Millisecond // 設定最短回應時間
+	r := newTestRouter(env)                           // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+
+	for _, tc := range []struct {
+		body   string // 請求內容
+		status int    // 預期狀態碼
+	}{
+		{`{"username":"alice","password":"password123"}`, http.StatusOK},     // 登入成功
+		{`{"username":"alice","password":"wrong"}`, http.StatusUnauthorized}, // 密碼錯誤
+		{`{"username":"nobody","password":"x"}`, http.StatusUnauthorized},    // 帳號不存在
+	} {
+		start := time.Now()                                                // 記錄開始時間
+		w := doJSON(r, http.MethodPost, "/auth/login", tc.body)            // 登入
+		require.Equal(t, tc.status, w.Code)                                // 狀態碼正確
+		require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond) // 花費時間不少於設定值
+	}
+}
+
+// TestLoginMinResponseRespectsContext 測試 request context 結束時不會繼續等待最短回應時間。
+func TestLoginMinResponseRespectsContext(t *testing.T) {
+	env := newTestEnv(t)                       // 建立測試環境
+	env.cfg.LoginMinResponse = 5 * time.Second // 設定很長的最短回應時間
+	r := newTestRouter(env)                    // 建立完整 router
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)                                                         // 請求只剩 100ms
+	defer cancel()                                                                                                                         // 結束時釋放資源
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"username":"nobody","password":"x"}`)).WithContext(ctx) // 建立帶 deadline 的請求
+	req.Header.Set("Content-Type", "application/json")                                                                                     // 標記為 JSON body
+
+	start := time.Now()                             // 記錄開始時間
+	r.ServeHTTP(httptest.NewRecorder(), req)        // 執行請求
+	require.Less(t, time.Since(start), time.Second) // 應在 deadline 附近返回，而非等滿 5 秒
+}
+
+// doForm 對 router 送出 application/x-www-form-urlencoded 請求並回傳 ResponseRecorder。
+func doForm(r http.Handler, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode())) // 建立 form body 請求
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")                 // 標記為 form body
+	w := httptest.NewRecorder()                                                         // 建立 ResponseRecorder
+	r.ServeHTTP(w, req)                                                                 // 執行請求
+	return w
+}
+
+// TestLoginAcceptsJSONAndForm 測試 login 同時接受 JSON 與 form-encoded body。
+func TestLoginAcceptsJSONAndForm(t *testing.T) {
+	env := newTestEnv(t)    // 建立測試環境
+	r := newTestRouter(env) // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 以 JSON 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+
+	w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123"}`) // 以 JSON 登入
+	require.Equal(t, http.StatusOK, w.Code)                                                        // 應登入成功
+	require.Contains(t, w.Body.String(), "access_token")                                           // 應回傳 access_token
+
+	w = doForm(r, "/auth/login", url.Values{"username": {"alice"}, "password": {"password123"}}) // 以 form 登入
+	require.Equal(t, http.StatusOK, w.Code)                                                      // 應登入成功
+	require.Contains(t, w.Body.String(), "access_token")                                         // 應回傳 access_token
+}
+
+// TestSignupAcceptsForm 測試 signup 接受 form-encoded body，缺少欄位時仍回傳 400。
+func TestSignupAcceptsForm(t *testing.T) {
+	env := newTestEnv(t)    // 建立測試環境
+	r := newTestRouter(env) // 建立完整 router
+
+	w := doForm(r, "/auth/signup", url.Values{"username": {"bob"}, "password": {"password123"}}) // 以 form 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                      // 應註冊成功
+
+	w = doForm(r, "/auth/signup", url.Values{"username": {"carol"}}) // 缺少 password
+	require.Equal(t, http.StatusBadRequest, w.Code)                  // 應回傳 400
+}
+
+// TestSignupWithPepper 測試設定 pepper 時，signup 寫入 peppered 雜湊且可正常登入。
+func TestSignupWithPepper(t *testing.T) {
+	env := newTestEnv(t)                     // 建立測試環境
+	env.cfg.PasswordPepper = "server-pepper" // 啟用 pepper
+	r := newTestRouter(env)                  // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+
+	var peppered bool                                                                                         // password_peppered 欄位
+	err := env.sqlDB.QueryRow("SELECT password_peppered FROM users WHERE username = 'alice'").Scan(&peppered) // 查詢標記
+	require.NoError(t, err)                                                                                   // 查詢應成功
+	require.True(t, peppered)                                                                                 // 應標記為 peppered
+
+	loginToken(t, r, "alice", "password123") // 以明文密碼登入應成功
+}
+
+// loginToken 以帳密登入並回傳 access token。
+func loginToken(t *testing.T, r http.Handler, username, password string) string {
+	t.Helper()                                                                                              // 標記為測試輔助函式
+	w := doJSON(r, http.MethodPost, "/auth/login", `{"username":"`+username+`","password":"`+password+`"}`) // 登入
+	require.Equal(t, http.StatusOK, w.Code)                                                                 // 應登入成功
+	var resp loginResponse                                                                                  // 解析回應
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))                                               // 應為合法 JSON
+	return resp.AccessToken
+}
+
+// doAuthed 帶著 Bearer token 送出 JSON 請求。
+func doAuthed(r http.Handler, token, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body)) // 建立請求
+	req.Header.Set("Content-Type", "application/json")                    // 標記為 JSON body
+	req.Header.Set("Authorization", "Bearer "+token)                      // 帶上 access token
+	w := httptest.NewRecorder()                                           // 建立 ResponseRecorder
+	r.ServeHTTP(w, req)                                                   // 執行請求
+	return w
+}
+
+// TestChangeUsername 測試變更 username 成功、寫入稽核紀錄，並撤銷其他 session 但保留目前 session。
+func TestChangeUsername(t *testing.T) {
+	env := newTestEnv(t)    // 建立測試環境
+	r := newTestRouter(env) // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	current := loginToken(t, r, "alice", "password123")                                              // 目前使用中的 session
+	other := loginToken(t, r, "alice", "password123")                                                // 另一台裝置的 session
+
+	w = doAuthed(r, current, http.MethodPatch, "/auth/username", `{"new_username":"  Alicia "}`) // 變更 username（會被正規化）
+	require.Equal(t, http.StatusOK, w.Code)                                                      // 應成功
+	require.Contains(t, w.Body.String(), `"username":"alicia"`)                                  // 回傳正規化後的新名稱
+
+	w = doAuthed(r, current, http.MethodGet, "/me", "") // 目前 session 仍有效
+	require.Equal(t, http.StatusOK, w.Code)             // 應成功
+	w = doAuthed(r, other, http.MethodGet, "/me", "")   // 其他 session 應被撤銷
+	require.Equal(t, http.StatusUnauthorized, w.Code)   // 應回 401
+
+	var oldName, newName string                                                                                   // 稽核紀錄欄位
+	err := env.sqlDB.QueryRow("SELECT old_username, new_username FROM username_changes").Scan(&oldName, &newName) // 查詢稽核紀錄
+	require.NoError(t, err)                                                                                       // 應有一筆紀錄
+	require.Equal(t, []string{"alice", "alicia"}, []string{oldName, newName})                                     // 記錄新舊名稱
+	w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"alicia","password":"password123"}`)               // 以新名稱登入
+	require.Equal(t, http.StatusOK, w.Code)                                                                       // 應成功
+}
+
+// TestChangeUsernameConflict 測試新 username 已被使用時回傳 409。
+func TestChangeUsernameConflict(t *testing.T) {
+	env := newTestEnv(t)    // 建立測試環境
+	r := newTestRouter(env) // 建立完整 router
+
+	for _, name := range []string{"alice", "bob"} { // 建立兩個使用者
+		w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"`+name+`","password":"password123"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	token := loginToken(t, r, "alice", "password123") // alice 登入
+
+	w := doAuthed(r, token, http.MethodPatch, "/auth/username", `{"new_username":"BOB"}`) // 改成 bob（大小寫不同仍視為相同）
+	require.Equal(t, http.StatusConflict, w.Code)                                         // 應回 409
+
+	w = doAuthed(r, token, http.MethodPatch, "/auth/username", `{"new_username":"has space"}`) // 含空白
+	require.Equal(t, http.StatusBadRequest, w.Code)                                            // 應回 400
+}
+
+// TestReservedUsernames 測試保留的 username（不分大小寫）在 signup、改名與可用性查詢都會被擋下，相似但不同的名稱則可使用。
+func TestReservedUsernames(t *testing.T) {
+	env := newTestEnv(t)                                      // 建立測試環境
+	env.cfg.ReservedUsernames = []string{"admin", " Support"} // 設定保留名單（含大小寫與空白）
+	r := newTestRouter(env)                                   // 建立完整 router
+
+	for _, name := range []string{"admin", "ADMIN", " Admin ", "support"} { // 各種大小寫與空白變化
+		w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"`+name+`","password":"password123"}`)
+		require.Equal(t, http.StatusBadRequest, w.Code)                     // 應回 400
+		require.JSONEq(t, `{"error":"username_reserved"}`, w.Body.String()) // 錯誤碼為 username_reserved
+	}
+
+	for _, name := range []string{"admin2", "the-admin", "supporter"} { // 相似但不在名單內
+		w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"`+name+`","password":"password123"}`)
+		require.Equal(t, http.StatusOK, w.Code) // 應註冊成功
+	}
+
+	w := doJSON(r, http.MethodGet, "/auth/username-available?u=Admin", "") // 查詢保留名稱
+	require.JSONEq(t, `{"available":false}`, w.Body.String())              // 應回報不可用
+
+	token := loginToken(t, r, "admin2", "password123")                                     // admin2 登入
+	w = doAuthed(r, token, http.MethodPatch, "/auth/username", `{"new_username":"Admin"}`) // 改成保留名稱
+	require.Equal(t, http.StatusBadRequest, w.Code)                                        // 應回 400
+	require.JSONEq(t, `{"error":"username_reserved"}`, w.Body.String())                    // 錯誤碼為 username_reserved
+
+	w = doAuthed(r, token, http.MethodPatch, "/auth/username", `{"new_username":"administrator"}`) // 不在名單內
+	require.Equal(t, http.StatusOK, w.Code)                                                        // 應成功
+}
+
+// TestEvictedSessionGetsSpecificError 測試因超過同時登入上限被踢掉的 session，會收到 EVICTED_MAX_SESSIONS。
+func TestEvictedSessionGetsSpecificError(t *testing.T) {
+	env := newTestEnv(t)                 // 建立測試環境（每人最多 2 個 session）
+	env.cfg.EvictReasonTTL = time.Minute // 保留踢除原因
+	r := newTestRouter(env)              // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	oldest := loginToken(t, r, "alice", "password123")                                               // 第一個 session
+	second := loginToken(t, r, "alice", "password123")                                               // 第二個 session
+	_ = loginToken(t, r, "alice", "password123")                                                     // 第三次登入會踢掉最舊的 session
+
+	w = doAuthed(r, oldest, http.MethodGet, "/me", "")                              // 被踢掉的裝置
+	require.Equal(t, http.StatusUnauthorized, w.Code)                               // 應回 401
+	require.JSONEq(t, `{"error":{"code":"EVICTED_MAX_SESSIONS"}}`, w.Body.String()) // 並說明原因
+
+	w = doAuthed(r, second, http.MethodPost, "/auth/logout", "") // 使用者自行登出的 session
+	require.Equal(t, http.StatusOK, w.Code)                      // 應成功
+	w = doAuthed(r, second, http.MethodGet, "/me", "")           // 再次使用
+	require.Equal(t, http.StatusUnauthorized, w.Code)            // 應回 401
+	require.Contains(t, w.Body.String(), "session_invalid")      // 沒有踢除原因，維持一般錯誤
+}
+
+// TestSignupCooldown 測試同一 IP 在冷卻期間內第二次註冊回 429，失敗的註冊不會啟動冷卻，冷卻過後可再註冊。
+func TestSignupCooldown(t *testing.T) {
+	env := newTestEnv(t)                 // 建立測試環境
+	env.cfg.SignupCooldown = time.Minute // 同一 IP 每分鐘只能註冊一次
+	r := newTestRouter(env)              // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 第一次註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應成功
+
+	w = doJSON(r, http.MethodPost, "/auth/signup", `{"username":"bob","password":"password123"}`) // 冷卻期間內再註冊
+	require.Equal(t, http.StatusTooManyRequests, w.Code)                                          // 應回 429
+	require.Equal(t, "60", w.Header().Get("Retry-After"))                                         // 告知剩餘冷卻時間
+	require.JSONEq(t, `{"error":"signup_cooldown","retry_after_seconds":60}`, w.Body.String())    // body 與 header 一致
+
+	env.mr.FastForward(61 * time.Second) // 冷卻時間過去
+
+	w = doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 重複的 username，註冊失敗
+	require.Equal(t, http.StatusBadRequest, w.Code)                                                 // 應回 400
+	w = doJSON(r, http.MethodPost, "/auth/signup", `{"username":"bob","password":"password123"}`)   // 失敗不應佔用名額
+	require.Equal(t, http.StatusOK, w.Code)                                                         // 應成功
+}
+
+// TestLoginStampsAMR 測試密碼登入的 token 帶有 amr=["pwd"]，換出的下游 token 也沿用相同的 amr。
+func TestLoginStampsAMR(t *testing.T) {
+	env := newTestEnv(t)                                   // 建立測試環境
+	env.cfg.TokenExchangeScopes = []string{"profile:read"} // 允許換發的 scope
+	env.cfg.TokenExchangeAudiences = []string{"billing"}   // 允許的下游服務
+	env.cfg.TokenExchangeTTL = time.Minute                 // 換出 token 存活時間
+	r := newTestRouter(env)                                // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	tok := loginToken(t, r, "alice", "password123")                                                  // 密碼登入
+
+	parsed, err := env.jwtMgr.Parse(tok)                             // 解析 token
+	require.NoError(t, err)                                          // 應可解析
+	require.Equal(t, []string{token.AMRPassword}, parsed.Claims.AMR) // 應標記為密碼登入
+
+	w = doAuthed(r, tok, http.MethodPost, "/auth/token/exchange", `{"audience":"billing","scopes":["profile:read"]}`) // 換出下游 token
+	require.Equal(t, http.StatusOK, w.Code)                                                                           // 應成功
+	var resp tokenExchangeResponse                                                                                    // 解析回應
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))                                                         // 應為合法 JSON
+	exchanged, err := env.jwtMgr.Parse(resp.AccessToken)                                                              // 解析下游 token
+	require.NoError(t, err)                                                                                           // 應可解析
+	require.Equal(t, []string{token.AMRPassword}, exchanged.Claims.AMR)                                               // 沿用原本的 amr
+}
+
+// TestPinSessionEndpoint 測試 pin 住的 session 在超過上限時保留，且只能 pin 自己的 session。
+func TestPinSessionEndpoint(t *testing.T) {
+	env := newTestEnv(t)    // 建立測試環境（每人最多 2 個 session）
+	r := newTestRouter(env) // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	desktop := loginToken(t, r, "alice", "password123")                                              // 主要裝置
+	phone := loginToken(t, r, "alice", "password123")                                                // 第二台裝置
+	parsed, err := env.jwtMgr.Parse(desktop)                                                         // 取得 desktop 的 session ID
+	require.NoError(t, err)                                                                          // 應解析成功
+
+	w = doAuthed(r, phone, http.MethodPost, "/auth/sessions/"+parsed.Claims.SessionID+"/pin", "") // 從另一台裝置 pin 住 desktop
+	require.Equal(t, http.StatusOK, w.Code)                                                       // 應成功
+	require.Contains(t, w.Body.String(), `"pinned":true`)                                         // 回傳 pin 狀態
+
+	w = doAuthed(r, phone, http.MethodPost, "/auth/sessions/missing/pin", "") // 不存在的 session
+	require.Equal(t, http.StatusNotFound, w.Code)                             // 應回 404
+
+	_ = loginToken(t, r, "alice", "password123")        // 第三次登入
+	w = doAuthed(r, desktop, http.MethodGet, "/me", "") // 已 pin 的 desktop
+	require.Equal(t, http.StatusOK, w.Code)             // 仍有效
+	w = doAuthed(r, phone, http.MethodGet, "/me", "")   // 未 pin 的 phone
+	require.Equal(t, http.StatusUnauthorized, w.Code)   // 被踢掉
+}
+
+// TestSignupRejectsWeakPassword 測試設定最低密碼熵後，signup 以 400 拒絕弱密碼並回傳弱點代碼。
+func TestSignupRejectsWeakPassword(t *testing.T) {
+	env := newTestEnv(t)                // 建立測試環境
+	env.cfg.PasswordMinEntropyBits = 40 // 設定最低密碼熵
+	r := newTestRouter(env)             // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"Password1"}`)   // 常見密碼
+	require.Equal(t, http.StatusBadRequest, w.Code)                                                  // 應回 400
+	require.JSONEq(t, `{"error":"password_too_weak","weakness":"common_password"}`, w.Body.String()) // 並說明弱點
+
+	w = doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"x7#Qm9!vLp2@"}`) // 強密碼
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+}
+
+// TestLoginValidatesRedirect 測試登入時 body 或 query 帶的跳轉目標必須在 allow-list 內，通過時原樣回傳 redirect_to。
+func TestLoginValidatesRedirect(t *testing.T) {
+	env := newTestEnv(t)                                                          // 建立測試環境
+	env.cfg.OAuthAllowedRedirects = []string{"https://app.example.com/account/*"} // 設定 allow-list
+	r := newTestRouter(env)                                                       // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+
+	w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123","redirect_uri":"https://evil.com/"}`) // body 帶不允許的目標
+	require.Equal(t, http.StatusBadRequest, w.Code)                                                                                   // 應回 400
+	require.JSONEq(t, `{"error":"invalid_redirect"}`, w.Body.String())                                                                // 錯誤代碼
+
+	w = doJSON(r, http.MethodPost, "/auth/login?return_to=%2F%2Fevil.com", `{"username":"alice","password":"password123"}`) // query 帶不允許的目標
+	require.Equal(t, http.StatusBadRequest, w.Code)                                                                         // 應回 400
+
+	w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123","return_to":"https://app.example.com/account/settings"}`) // 允許的目標
+	require.Equal(t, http.StatusOK, w.Code)                                                                                                               // 應登入成功
+	var resp struct {
+		RedirectTo string `json:"redirect_to"` // 回傳的跳轉目標
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))                     // 解析回應
+	require.Equal(t, "https://app.example.com/account/settings", resp.RedirectTo) // 應原樣回傳
+}
+
+// TestLoginRateLimitReturns429 測試同一使用者登入次數超過上限時回 429 與 Retry-After，其他使用者仍可登入。
+func TestLoginRateLimitReturns429(t *testing.T) {
+	env := newTestEnv(t)                       // 建立測試環境
+	env.cfg.LoginRateLimit = 2                 // 每個視窗最多登入 2 次
+	env.cfg.LoginRateLimitWindow = time.Minute // 視窗長度 1 分鐘
+	r := newTestRouter(env)                    // 建立完整 router
+
+	for _, name := range []string{"alice", "bob"} {
+		w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"`+name+`","password":"password123"}`) // 註冊
+		require.Equal(t, http.StatusOK, w.Code)                                                             // 應註冊成功
+	}
+
+	for i := 0; i < 2; i++ {
+		w := doJSON(r, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123"}`) // 上限內的登入
+		require.Equal(t, http.StatusOK, w.Code)                                                         // 應登入成功
+	}
+
+	w := doJSON(r, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123"}`) // 超過上限
+	require.Equal(t, http.StatusTooManyRequests, w.Code)                                            // 應回 429
+	require.Contains(t, w.Body.String(), `"error":"login_rate_limited"`)                            // 錯誤代碼
+	require.NotEmpty(t, w.Header().Get("Retry-After"))                                              // 應帶 Retry-After
+
+	w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"bob","password":"password123"}`) // 其他使用者
+	require.Equal(t, http.StatusOK, w.Code)                                                      // 不受影響
+}
+
+// doBeacon 模擬 navigator.sendBeacon 以 text/plain 送出字串 body。
+func doBeacon(r http.Handler, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)) // 建立請求
+	req.Header.Set("Content-Type", "text/plain;charset=UTF-8")                 // sendBeacon 送出字串時的 Content-Type
+	w := httptest.NewRecorder()                                                // 建立 ResponseRecorder
+	r.ServeHTTP(w, req)                                                        // 執行請求
+	return w
+}
+
+// TestLogoutWithBodyToken 測試開啟 LogoutBodyToken 後，body 帶 token 即可登出該 session，header 的方式仍可使用。
+func TestLogoutWithBodyToken(t *testing.T) {
+	env := newTestEnv(t)           // 建立測試環境
+	env.cfg.LogoutBodyToken = true // 開啟 body token 登出
+	r := newTestRouter(env)        // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	tok1 := loginToken(t, r, "alice", "password123")                                                 // 第一個 session
+	tok2 := loginToken(t, r, "alice", "password123")                                                 // 第二個 session
+
+	w = doBeacon(r, "/auth/logout", `{"token":"`+tok1+`"}`) // 以 sendBeacon 的格式登出
+	require.Equal(t, http.StatusOK, w.Code)                 // 應登出成功
+	w = doAuthed(r, tok1, http.MethodGet, "/me", "")        // 已登出的 token
+	require.Equal(t, http.StatusUnauthorized, w.Code)       // 不可再使用
+	w = doAuthed(r, tok2, http.MethodGet, "/me", "")        // 其他 session
+	require.Equal(t, http.StatusOK, w.Code)                 // 不受影響
+
+	w = doForm(r, "/auth/logout", url.Values{"token": {tok2}}) // 以 form 登出
+	require.Equal(t, http.StatusOK, w.Code)                    // 應登出成功
+
+	tok3 := loginToken(t, r, "alice", "password123")           // 新的 session
+	w = doAuthed(r, tok3, http.MethodPost, "/auth/logout", "") // header 的方式
+	require.Equal(t, http.StatusOK, w.Code)                    // 仍可登出
+}
+
+// TestLogoutWithBodyTokenRejectsInvalid 測試 body token 同樣會驗證簽章與 session，無效時回 401。
+func TestLogoutWithBodyTokenRejectsInvalid(t *testing.T) {
+	env := newTestEnv(t)           // 建立測試環境
+	env.cfg.LogoutBodyToken = true // 開啟 body token 登出
+	r := newTestRouter(env)        // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	tok := loginToken(t, r, "alice", "password123")                                                  // 登入
+
+	tampered := tok[:len(tok)-2] + "xx"                             // 竄改簽章
+	w = doBeacon(r, "/auth/logout", `{"token":"`+tampered+`"}`)     // 以竄改過的 token 登出
+	require.Equal(t, http.StatusUnauthorized, w.Code)               // 應回 401
+	require.JSONEq(t, `{"error":"invalid token"}`, w.Body.String()) // 簽章驗證失敗
+
+	w = doBeacon(r, "/auth/logout", `{}`)                           // 沒有帶 token
+	require.Equal(t, http.StatusUnauthorized, w.Code)               // 應回 401
+	require.JSONEq(t, `{"error":"missing token"}`, w.Body.String()) // 說明缺少 token
+
+	w = doBeacon(r, "/auth/logout", `{"token":"`+tok+`"}`)            // 正常登出
+	require.Equal(t, http.StatusOK, w.Code)                           // 應登出成功
+	w = doBeacon(r, "/auth/logout", `{"token":"`+tok+`"}`)            // 重送已登出的 token
+	require.Equal(t, http.StatusUnauthorized, w.Code)                 // session 已不存在
+	require.JSONEq(t, `{"error":"session_invalid"}`, w.Body.String()) // 應回 session_invalid
+}
+
+// TestRefreshIssuesTokenForSameSession 測試 /auth/refresh 換發同一個 session 的新 token，開啟 ExtendSessionOnRefresh 時一併延長 Redis TTL。
+func TestRefreshIssuesTokenForSameSession(t *testing.T) {
+	for _, extend := range []bool{false, true} {
+		env := newTestEnv(t)                    // 建立測試環境
+		env.cfg.ExtendSessionOnRefresh = extend // 是否延長 session
+		r := newTestRouter(env)                 // 建立完整 router
+
+		w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+		require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+		tok := loginToken(t, r, "alice", "password123")                                                  // 登入
+
+		keys := env.mr.Keys() // 找出 session hash
+		var sessKey string
+		for _, k := range keys {
+			if strings.HasPrefix(k, "sess:") {
+				sessKey = k // 唯一的 session
+			}
+		}
+		require.NotEmpty(t, sessKey) // 應存在 session
+
+		// 模擬已使用 10 分鐘：到期時間與 TTL 都提前 10 分鐘
+		expires, err := strconv.ParseInt(env.mr.HGet(sessKey, "expires_at"), 10, 64) // 原本的到期時間
+		require.NoError(t, err)                                                      // 應為 unix 秒數
+		env.mr.HSet(sessKey, "expires_at", strconv.FormatInt(expires-600, 10))       // 到期時間提前
+		env.mr.SetTTL(sessKey, time.Until(time.Unix(expires-600, 0)))                // TTL 同步
+		before := env.mr.TTL(sessKey)                                                // refresh 前的 TTL
+
+		w = doAuthed(r, tok, http.MethodPost, "/auth/refresh", "") // refresh
+		require.Equal(t, http.StatusOK, w.Code)                    // 應成功
+		var resp loginResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp)) // 解析回應
+		require.NotEmpty(t, resp.AccessToken)                     // 應回傳新 token
+
+		w = doAuthed(r, resp.AccessToken, http.MethodGet, "/me", "") // 以新 token 呼叫 API
+		require.Equal(t, http.StatusOK, w.Code)                      // 同一個 session 仍有效
+
+		if extend {
+			require.Greater(t, env.mr.TTL(sessKey), before) // session TTL 已延長
+		} else {
+			require.Equal(t, before, env.mr.TTL(sessKey)) // session TTL 不變
+		}
+	}
+}
+
+// TestTokenInfo 測試 /auth/token-info 回傳的時間與 token claims、session hash 一致。
+func TestTokenInfo(t *testing.T) {
+	env := newTestEnv(t)    // 建立測試環境
+	r := newTestRouter(env) // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	tok := loginToken(t, r, "alice", "password123")                                                  // 登入
+
+	parsed, err := env.jwtMgr.Parse(tok) // 解析 token 取得 claims
+	require.NoError(t, err)              // 應解析成功
+	claims := parsed.Claims
+
+	w = doAuthed(r, tok, http.MethodGet, "/auth/token-info", "") // 查詢 token 資訊
+	require.Equal(t, http.StatusOK, w.Code)                      // 應成功
+	var info struct {
+		IssuedAt         time.Time `json:"issued_at"`          // 簽發時間
+		ExpiresAt        time.Time `json:"expires_at"`         // token 到期時間
+		SecondsRemaining int64     `json:"seconds_remaining"`  // 剩餘秒數
+		SessionExpiresAt time.Time `json:"session_expires_at"` // session 到期時間
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info)) // 解析回應
+
+	require.True(t, claims.IssuedAt.Time.Equal(info.IssuedAt))                                    // 與 iat 相同
+	require.True(t, claims.ExpiresAt.Time.Equal(info.ExpiresAt))                                  // 與 exp 相同
+	require.InDelta(t, time.Until(claims.ExpiresAt.Time).Seconds(), info.SecondsRemaining, 2)     // 剩餘秒數
+	expires, err := strconv.ParseInt(env.mr.HGet("sess:"+claims.SessionID, "expires_at"), 10, 64) // session hash 的到期時間
+	require.NoError(t, err)                                                                       // 應為 unix 秒數
+	require.Equal(t, expires, info.SessionExpiresAt.Unix())                                       // 與 session hash 相同
+
+	env.mr.Del("sess:" + claims.SessionID)                       // session 已不存在
+	w = doAuthed(r, tok, http.MethodGet, "/auth/token-info", "") // 再次查詢
+	require.Equal(t, http.StatusUnauthorized, w.Code)            // 應回 401
+}
+
+// TestSignupHookFailureRollsBack 測試 SignupHook 失敗時回 502、使用者沒有建立，hook 恢復後同一個 username 可正常註冊。
+func TestSignupHookFailureRollsBack(t *testing.T) {
+	env := newTestEnv(t)    // 建立測試環境
+	r := newTestRouter(env) // 建立完整 router
+
+	env.sessSvc.WithSignupHook(func(context.Context, db.User) error {
+		return context.DeadlineExceeded // 模擬外部系統逾時
+	})
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusBadGateway, w.Code)                                                  // 應回 502
+	require.JSONEq(t, `{"error":"signup_provisioning_failed"}`, w.Body.String())                     // 錯誤碼
+
+	env.sessSvc.WithSignupHook(nil)                                                                 // 改回預設的 no-op hook
+	w = doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 再次註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                         // 先前已 rollback，應成功
+}
+
+// TestLoginCountryBlocked 測試來源國家在 LOGIN_COUNTRY_DENYLIST 內時登入回 403 country_blocked，其他國家照常登入。
+func TestLoginCountryBlocked(t *testing.T) {
+	env := newTestEnv(t)                          // 建立測試環境
+	env.cfg.GeoCountryHeader = "CF-IPCountry"     // 由 header 取得國碼
+	env.cfg.LoginCountryDenylist = []string{"KP"} // 拒絕 KP
+	r := newTestRouter(env)                       // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+
+	for country, want := range map[string]int{"kp": http.StatusForbidden, "TW": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"username":"alice","password":"password123"}`)) // 建立登入請求
+		req.Header.Set("Content-Type", "application/json")                                                                             // 標記為 JSON body
+		req.Header.Set("CF-IPCountry", country)                                                                                        // 標注來源國家
+		w := httptest.NewRecorder()                                                                                                    // 建立 ResponseRecorder
+		r.ServeHTTP(w, req)                                                                                                            // 執行請求
+		require.Equal(t, want, w.Code, country)                                                                                        // 依國家放行或拒絕
+		if want == http.StatusForbidden {
+			require.Contains(t, w.Body.String(), "country_blocked") // 錯誤碼為 country_blocked
+		}
+	}
+}
+
+// TestLoginSessionWarning 測試 session 數未達 SESSION_WARN_THRESHOLD 時登入回應沒有警告，達到後附上 session_warning 與目前數量。
+func TestLoginSessionWarning(t *testing.T) {
+	env := newTestEnv(t)               // 建立測試環境
+	env.cfg.MaxSessionsPerUser = 5     // 上限 5 個 session
+	env.cfg.SessionWarnThreshold = 0.8 // 達到 4 個時警告
+	r := newTestRouter(env)            // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+
+	for i := 1; i <= 4; i++ {
+		w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123"}`) // 登入
+		require.Equal(t, http.StatusOK, w.Code)                                                        // 應登入成功
+		var resp loginResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp)) // 應為合法 JSON
+		if i < 4 {
+			require.False(t, resp.SessionWarning)                      // 未達門檻，沒有警告
+			require.NotContains(t, w.Body.String(), "active_sessions") // 也不附上數量
+			continue
+		}
+		require.True(t, resp.SessionWarning)     // 達到門檻，附上警告
+		require.Equal(t, 4, resp.ActiveSessions) // 目前 4 個 session
+		require.Equal(t, 5, resp.MaxSessions)    // 上限 5 個
+	}
+}
+
+// TestOpaqueTokenFlow 測試 TOKEN_MODE=opaque 時登入取得 reference token、以它呼叫需要驗證的 API，登出後同一顆 token 立即失效。
+func TestOpaqueTokenFlow(t *testing.T) {
+	env := newTestEnv(t)                        // 建立測試環境
+	env.cfg.TokenMode = session.TokenModeOpaque // 改用 reference token
+	r := newTestRouter(env)                     // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	tok := loginToken(t, r, "alice", "password123")                                                  // 登入
+	require.NotEmpty(t, tok)                                                                         // 應取得 token
+	_, err := env.jwtMgr.Parse(tok)                                                                  // 不是 JWT
+	require.Error(t, err)                                                                            // 無法以 JWT 解析
+
+	w = doAuthed(r, tok, http.MethodGet, "/me", "")            // 以 reference token 呼叫 /me
+	require.Equal(t, http.StatusOK, w.Code)                    // 應通過驗證
+	require.Contains(t, w.Body.String(), `"username":"alice"`) // 回傳自己的資料
+
+	w = doAuthed(r, tok, http.MethodPost, "/auth/logout", "") // 登出
+	require.Equal(t, http.StatusOK, w.Code)                   // 應成功
+
+	w = doAuthed(r, tok, http.MethodGet, "/me", "")                                  // 登出後再用同一顆 token
+	require.Equal(t, http.StatusUnauthorized, w.Code)                                // 應立即失效
+	require.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`) // 標示 token 無效
+}
+
+// TestLoginPasswordExpired 測試密碼過期時登入回 403 password_expired 並附上重設路徑。
+func TestLoginPasswordExpired(t *testing.T) {
+	env := newTestEnv(t)                         // 建立測試環境
+	env.cfg.PasswordMaxAge = 30 * 24 * time.Hour // 密碼最多使用 30 天
+	r := newTestRouter(env)                      // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	loginToken(t, r, "alice", "password123")                                                         // 新密碼可登入
+
+	aged := time.Now().UTC().Add(-31 * 24 * time.Hour).Format(time.DateTime)                                                         // 31 天前
+	_, err := env.sqlDB.ExecContext(context.Background(), "UPDATE users SET password_changed_at = ? WHERE username = 'alice'", aged) // 讓密碼過期
+	require.NoError(t, err)                                                                                                          // 應更新成功
+
+	w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123"}`) // 再次登入
+	require.Equal(t, http.StatusForbidden, w.Code)                                                 // 應回 403
+	var resp struct {
+		Error      string `json:"error"`       // 錯誤代碼
+		ResetURL   string `json:"reset_url"`   // 重設密碼的路徑
+		ResetToken string `json:"reset_token"` // 一次性重設 token
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp)) // 解析回應
+	require.Equal(t, "password_expired", resp.Error)          // 提示密碼過期
+	require.Equal(t, "/auth/password/reset", resp.ResetURL)   // 指向重設路徑
+	require.NotEmpty(t, resp.ResetToken)                      // 附上重設 token
+
+	body := `{"token":"` + resp.ResetToken + `","new_password":"new-password"}` // 以 token 重設
+	w = doJSON(r, http.MethodPost, "/auth/password/reset", body)                // 重設密碼
+	require.Equal(t, http.StatusOK, w.Code)                                     // 應成功
+	loginToken(t, r, "alice", "new-password")                                   // 新密碼可登入
+}
+
+// TestLoginAccountSummary 測試只有帶 include_account_summary=true 才會附上 account_summary，且內容與 Redis、user row 一致。
+func TestLoginAccountSummary(t *testing.T) {
+	env := newTestEnv(t)        // 建立測試環境
+	r := newTestRouter(env)     // 建立完整 router
+	ctx := context.Background() // 建立背景 context
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊 alice
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	user, err := env.q.GetUserByUsername(ctx, "alice")                                               // 取得 user ID
+	require.NoError(t, err)                                                                          // 應查詢成功
+
+	w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123"}`) // 預設登入
+	require.Equal(t, http.StatusOK, w.Code)                                                        // 應登入成功
+	require.NotContains(t, w.Body.String(), "account_summary")                                     // 預設回應不含摘要
+
+	w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123","include_account_summary":true}`) // 以 body 要求摘要
+	require.Equal(t, http.StatusOK, w.Code)                                                                                       // 應登入成功
+	var resp loginResponse                                                                                                        // 解析回應
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))                                                                     // 應為合法 JSON
+	require.NotNil(t, resp.AccountSummary)                                                                                        // 應附上摘要
+	require.Equal(t, 2, resp.AccountSummary.ActiveSessions)                                                                       // 兩次登入各一個 session
+	require.Nil(t, resp.AccountSummary.LastLoginAt)                                                                               // worker 尚未記錄任何登入
+	require.Nil(t, resp.AccountSummary.LastLoginIP)                                                                               // 也沒有 IP
+	require.Contains(t, w.Body.String(), `"last_login_at":null`)                                                                  // 欄位仍會出現，值為 null
+
+	lastLogin := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Second) // worker 記錄的上次登入時間
+	require.NoError(t, env.q.UpdateLastLogin(ctx, db.UpdateLastLoginParams{
+		ID:          user.ID,
+		LastLoginAt: sql.NullTime{Time: lastLogin, Valid: true},
+		LastLoginIp: sql.NullString{String: "198.51.100.4", Valid: true},
+	})) // 模擬 worker 更新 user row
+
+	w = doJSON(r, http.MethodPost, "/auth/login?include_account_summary=true", `{"username":"alice","password":"password123"}`) // 以 query 要求摘要
+	require.Equal(t, http.StatusOK, w.Code)                                                                                     // 應登入成功
+	resp = loginResponse{}                                                                                                      // 重設回應
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))                                                                   // 應為合法 JSON
+	require.NotNil(t, resp.AccountSummary)                                                                                      // 應附上摘要
+	active, err := env.rdb.ZCard(ctx, infra.UserSessKey(user.ID)).Result()                                                      // Redis 中的 session 數
+	require.NoError(t, err)                                                                                                     // 應查詢成功
+	require.Equal(t, int(active), resp.AccountSummary.ActiveSessions)                                                           // 與 Redis 一致
+	require.NotNil(t, resp.AccountSummary.LastLoginAt)                                                                          // 應帶上次登入時間
+	require.True(t, resp.AccountSummary.LastLoginAt.Equal(lastLogin))                                                           // 與 user row 一致
+	require.NotNil(t, resp.AccountSummary.LastLoginIP)                                                                          // 應帶上次登入 IP
+	require.Equal(t, "198.51.100.4", *resp.AccountSummary.LastLoginIP)                                                          // 與 user row 一致
+}
+
+// TestResetPasswordRateLimited 測試同一 IP 重設密碼次數超過上限時回傳 429，避免暴力猜測重設 token。
+func TestResetPasswordRateLimited(t *testing.T) {
+	env := newTestEnv(t)               // 建立測試環境
+	env.cfg.PasswordResetRateLimit = 2 // 每分鐘最多 2 次
+	r := newTestRouter(env)            // 建立完整 router
+
+	for i := 0; i < 2; i++ { // 前兩次以錯誤 token 嘗試
+		w := doJSON(r, http.MethodPost, "/auth/password/reset", `{"token":"guess","new_password":"new-password"}`)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	w := doJSON(r, http.MethodPost, "/auth/password/reset", `{"token":"guess","new_password":"new-password"}`) // 第三次嘗試
+	require.Equal(t, http.StatusTooManyRequests, w.Code)                                                       // 應被 rate limit
+}
+
+// TestChangeUsernameRollsBackWithoutAudit 測試 username_changes 寫入失敗時改名會一併 rollback，舊名稱仍可登入。
+func TestChangeUsernameRollsBackWithoutAudit(t *testing.T) {
+	env := newTestEnv(t)    // 建立測試環境
+	r := newTestRouter(env) // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	token := loginToken(t, r, "alice", "password123")                                                // 登入
+
+	_, err := env.sqlDB.Exec(`CREATE TRIGGER fail_username_changes BEFORE INSERT ON username_changes
+BEGIN SELECT RAISE(ABORT, 'audit unavailable'); END`) // 讓稽核紀錄寫入失敗
+	require.NoError(t, err) // 應成功建立 trigger
+
+	w = doAuthed(r, token, http.MethodPatch, "/auth/username", `{"new_username":"alicia"}`) // 變更 username
+	require.Equal(t, http.StatusInternalServerError, w.Code)                                // 應回 500
+
+	w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123"}`)  // 舊名稱仍可登入
+	require.Equal(t, http.StatusOK, w.Code)                                                         // 改名已 rollback
+	w = doJSON(r, http.MethodPost, "/auth/login", `{"username":"alicia","password":"password123"}`) // 新名稱不存在
+	require.Equal(t, http.StatusUnauthorized, w.Code)                                               // 應回 401
+}
+
+// TestLogoutBeaconWithJSONContentTypeRequired 測試開啟 REQUIRE_JSON_CONTENT_TYPE 時，sendBeacon 以 text/plain 送出的登出仍會放行，其他路由照常回 415。
+func TestLogoutBeaconWithJSONContentTypeRequired(t *testing.T) {
+	env := newTestEnv(t)                  // 建立測試環境
+	env.cfg.LogoutBodyToken = true        // 開啟 body token 登出
+	env.cfg.RequireJSONContentType = true // 強制 JSON Content-Type
+	r := newTestRouter(env)               // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	tok := loginToken(t, r, "alice", "password123")                                                  // 登入
+
+	w = doBeacon(r, "/auth/login", `{"username":"alice","password":"password123"}`) // 其他路由送 text/plain
+	require.Equal(t, http.StatusUnsupportedMediaType, w.Code)                       // 應回 415
+
+	w = doBeacon(r, "/auth/logout", `{"token":"`+tok+`"}`) // 以 sendBeacon 的格式登出
+	require.Equal(t, http.StatusOK, w.Code)                // 應登出成功
+	w = doAuthed(r, tok, http.MethodGet, "/me", "")        // 已登出的 token
+	require.Equal(t, http.StatusUnauthorized, w.Code)      // 不可再使用
+}
+
+// TestSignupChallengeUnavailable 測試 CAPTCHA 供應商故障（回傳 500）時回 503 challenge_unavailable，而不是 400 challenge_failed。
+func TestSignupChallengeUnavailable(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError) // 供應商故障
+	}))
+	defer provider.Close() // 測試結束時關閉
+
+	v := challenge.NewCaptchaVerifier(provider.URL, "secret", provider.Client()) // 指向故障的供應商
+	r := newSignupRouter(env, v)                                                 // 建立測試 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123","captcha_token":"ok"}`) // 帶入 token
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)                                                               // 應回 503
+	require.JSONEq(t, `{"error":"challenge_unavailable"}`, w.Body.String())                                               // 錯誤碼為 challenge_unavailable
+
+	_, err := env.q.GetUserByUsername(context.Background(), "alice") // 確認使用者沒有被建立
+	require.ErrorIs(t, err, sql.ErrNoRows)                           // 應查無此人
+}
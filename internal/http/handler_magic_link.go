@@ -0,0 +1,132 @@
+package http
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"sessionservice/internal/config"
+	"sessionservice/internal/infra"
+	"sessionservice/internal/middleware"
+	"sessionservice/internal/session"
+	"sessionservice/internal/token"
+)
+
+// MagicLinkHandler 處理免密碼的 magic link 登入：先以 email 要求寄送一次性連結，再以連結中的 token 建立 session。
+type MagicLinkHandler struct {
+	jwtMgr  *token.Manager
+	sessSvc *session.SessionService
+	cfg     *config.Config
+}
+
+func NewMagicLinkHandler(jwtMgr *token.Manager, sessSvc *session.SessionService, cfg *config.Config) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		jwtMgr:  jwtMgr,
+		sessSvc: sessSvc,
+		cfg:     cfg,
+	}
+}
+
+type magicLinkRequest struct {
+	Email string `json:"email" form:"email" binding:"required"`
+}
+
+type magicLoginRequest struct {
+	Token string `json:"token" form:"token" binding:"required"`
+}
+
+// magicLinkConfirmPage 是 GET /auth/magic-login 回傳的確認頁，使用者按下按鈕後才以 POST 送出 token。
+var magicLinkConfirmPage = template.Must(template.New("magic-login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="referrer" content="no-referrer"><title>Sign in</title></head>
+<body>
+<form method="post" action="/auth/magic-login">
+<input type="hidden" name="token" value="{{.}}">
+<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+// Request 處理 POST /auth/magic-link。不論 email 是否存在一律回 200 {"ok":true}；
+// 寄送失敗只記錄 log，避免從錯誤回應判斷出 email 已註冊。
+// 回應至少會花 MagicLinkMinResponse 的時間，已註冊的 email 多出的產生 token 與排入寄信任務的耗時不會反映在回應時間上。
+func (h *MagicLinkHandler) Request(c *gin.Context) {
+	start := time.Now()
+	defer waitAtLeast(c.Request.Context(), start, h.cfg.MagicLinkMinResponse)
+
+	var req magicLinkRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if err := h.sessSvc.RequestMagicLink(c.Request.Context(), req.Email); err != nil {
+		infra.LogError("magic link: request error: %v", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// Confirm 處理 GET /auth/magic-login?token=：只回傳確認頁，不消耗 token。
+// 郵件閘道與連結預覽會自動 GET 信中的連結，若 GET 就登入，token 會在使用者點開前被用掉（或替掃描器建立 session）。
+func (h *MagicLinkHandler) Confirm(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := magicLinkConfirmPage.Execute(c.Writer, c.Query("token")); err != nil {
+		infra.LogError("magic link: render confirm page: %v", err)
+	}
+}
+
+// Login 處理 POST /auth/magic-login（JSON 或 form 的 token）：消耗 token 並與密碼登入相同地回傳 access token。
+func (h *MagicLinkHandler) Login(c *gin.Context) {
+	var req magicLoginRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	meta := session.LoginMeta{
+		IP:        c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+		Country:   clientCountry(c, h.cfg.GeoCountryHeader),
+	}
+	user, sessionID, expiresAt, err := h.sessSvc.ConsumeMagicLink(ctx, req.Token, meta)
+	if err != nil {
+		var rateErr *session.LoginRateLimitError
+		switch {
+		case errors.As(err, &rateErr):
+			middleware.AbortWithRetryAfter(c, http.StatusTooManyRequests, "login_rate_limited", rateErr.RetryAfter)
+		case errors.Is(err, session.ErrMagicLinkInvalid):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "magic_link_invalid"})
+		case errors.Is(err, session.ErrUserBanned):
+			c.JSON(http.StatusForbidden, gin.H{"error": "user is banned"})
+		case errors.Is(err, session.ErrSessionLimitReached):
+			c.JSON(http.StatusConflict, gin.H{"error": "session_limit_reached"})
+		case errors.Is(err, session.ErrCountryBlocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": "country_blocked"})
+		case errors.Is(err, session.ErrMultiCountrySession):
+			c.JSON(http.StatusForbidden, gin.H{"error": "multi_country_session"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		}
+		return
+	}
+
+	tokenStr, expiresAt, err := signSessionToken(ctx, h.sessSvc, h.jwtMgr, user.ID, sessionID, expiresAt, token.AMRMagicLink)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	resp := loginResponse{
+		AccessToken: tokenStr,
+		ExpiresIn:   int64(time.Until(expiresAt).Seconds()),
+	}
+	addSessionWarning(ctx, h.sessSvc, &resp, user.ID)
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"encoding/json" // 匯入 encoding/json，解析回應與任務 payload
+	"net/http"      // 匯入 net/http，使用 method 與狀態碼常數
+	"net/url"       // 匯入 net/url，從連結取出 token
+	"strings"       // 匯入 strings，找出信件內的連結
+	"testing"       // 匯入 testing，提供單元測試框架
+	"time"          // 匯入 time，設定連結效期
+
+	"github.com/hibiken/asynq"            // 匯入 asynq，檢查 email:send 任務
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra"   // 匯入 infra，取得任務型別
+	"sessionservice/internal/session" // 匯入 session，建立會排任務的 SessionService
+	"sessionservice/internal/token"   // 匯入 token，使用 amr 常數
+)
+
+// TestMagicLinkLogin 測試 magic link 的完整流程：不論 email 是否存在回應都相同，連結可登入一次，之後即失效。
+func TestMagicLinkLogin(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	opt := asynq.RedisClientOpt{Addr: env.mr.Addr()}                              // asynq 與 session 共用 miniredis
+	client := asynq.NewClient(opt)                                                // 建立 asynq client
+	defer client.Close()                                                          // 測試結束時關閉
+	inspector := asynq.NewInspector(opt)                                          // 建立 inspector 以檢查排入的任務
+	defer inspector.Close()                                                       // 測試結束時關閉
+	env.cfg.MagicLinkEnabled = true                                               // 開放 magic link
+	env.cfg.MagicLinkTTL = 15 * time.Minute                                       // 連結 15 分鐘內有效
+	env.cfg.MagicLinkURL = "https://example.com/auth/magic-login"                 // 信件內的連結位址
+	env.cfg.MagicLinkMinResponse = 50 * time.Millisecond                          // 回應至少 50 毫秒
+	env.sessSvc = session.NewSessionService(env.q, env.rdb, env.cfg, client, nil) // 改用會排任務的 SessionService
+	r := newTestRouter(env)                                                       // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123","email":"Alice@Example.com"}`) // 註冊時帶 email
+	require.Equal(t, http.StatusOK, w.Code)                                                                                      // 應註冊成功
+
+	begin := time.Now()                                                                         // 記錄開始時間
+	unknown := doJSON(r, http.MethodPost, "/auth/magic-link", `{"email":"nobody@example.com"}`) // 未註冊的 email
+	require.GreaterOrEqual(t, time.Since(begin), 50*time.Millisecond)                           // 未註冊的 email 同樣等到最短回應時間
+	known := doJSON(r, http.MethodPost, "/auth/magic-link", `{"email":"alice@example.com"}`)    // 已註冊的 email
+	require.Equal(t, http.StatusOK, unknown.Code)                                               // 應回 200
+	require.Equal(t, unknown.Code, known.Code)                                                  // 狀態碼相同
+	require.Equal(t, unknown.Body.String(), known.Body.String())                                // 回應內容相同
+
+	tasks, err := inspector.ListPendingTasks("default") // 列出待處理的任務
+	require.NoError(t, err)                             // 查詢應成功
+	var emails []infra.EmailSendPayload
+	for _, task := range tasks {
+		if task.Type != infra.TaskTypeEmailSend {
+			continue // 只看 email:send
+		}
+		var p infra.EmailSendPayload
+		require.NoError(t, json.Unmarshal(task.Payload, &p)) // payload 應為合法 JSON
+		emails = append(emails, p)
+	}
+	require.Len(t, emails, 1)                                         // 只寄給已註冊的 email
+	require.Equal(t, "alice@example.com", emails[0].To)               // 收件者為正規化後的 email
+	start := strings.Index(emails[0].Body, env.cfg.MagicLinkURL)      // 找出信件內的連結
+	require.GreaterOrEqual(t, start, 0)                               // 應帶有連結
+	link, err := url.Parse(strings.Fields(emails[0].Body[start:])[0]) // 解析連結
+	require.NoError(t, err)                                           // 連結應可解析
+	loginPath := "/auth/magic-login?" + link.RawQuery                 // 以同樣的 query 呼叫 router
+	form := url.Values{"token": {link.Query().Get("token")}}          // 確認頁送出的 form
+
+	for i := 0; i < 2; i++ { // 郵件掃描器預覽連結，GET 多次都不會消耗 token
+		w = doJSON(r, http.MethodGet, loginPath, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		require.Contains(t, w.Body.String(), `method="post"`)
+		require.Contains(t, w.Body.String(), form.Get("token"))
+	}
+
+	w = doForm(r, "/auth/magic-login", form)                                                       // 在確認頁按下登入
+	require.Equal(t, http.StatusOK, w.Code)                                                        // 應登入成功
+	var resp loginResponse                                                                         // 解析回應
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))                                      // 應為合法 JSON
+	require.NotEmpty(t, resp.AccessToken)                                                          // 應回傳 access token
+	parsed, err := env.jwtMgr.Parse(resp.AccessToken)                                              // 解析 token
+	require.NoError(t, err)                                                                        // 應為合法 token
+	require.Equal(t, []string{token.AMRMagicLink}, parsed.Claims.AMR)                              // amr 標記為 magic link
+	require.Equal(t, http.StatusOK, doAuthed(r, resp.AccessToken, http.MethodGet, "/me", "").Code) // 可存取需登入的路由
+
+	w = doForm(r, "/auth/magic-login", form)                   // 再用同一個連結
+	require.Equal(t, http.StatusUnauthorized, w.Code)          // 應被拒絕
+	require.Contains(t, w.Body.String(), "magic_link_invalid") // 原因為連結已失效
+}
+
+// TestMagicLinkDisabledAndInvalidEmail 測試未開啟 MAGIC_LINK_ENABLED 時不提供路由，且註冊時的 email 必須格式正確。
+func TestMagicLinkDisabledAndInvalidEmail(t *testing.T) {
+	env := newTestEnv(t)    // 建立測試環境（預設關閉 magic link）
+	r := newTestRouter(env) // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/magic-link", `{"email":"alice@example.com"}`) // 要求寄送連結
+	require.Equal(t, http.StatusNotFound, w.Code)                                        // 未開放應回 404
+	w = doJSON(r, http.MethodGet, "/auth/magic-login?token=x", "")                       // 開啟連結
+	require.Equal(t, http.StatusNotFound, w.Code)                                        // 未開放應回 404
+	w = doJSON(r, http.MethodPost, "/auth/magic-login", `{"token":"x"}`)                 // 以連結登入
+	require.Equal(t, http.StatusNotFound, w.Code)                                        // 未開放應回 404
+
+	w = doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123","email":"not-an-email"}`) // email 格式錯誤
+	require.Equal(t, http.StatusBadRequest, w.Code)                                                                        // 應回 400
+	require.Contains(t, w.Body.String(), "invalid_email")                                                                  // 原因為 email 格式錯誤
+}
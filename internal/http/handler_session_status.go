@@ -0,0 +1,73 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"sessionservice/internal/middleware"
+	"sessionservice/internal/session"
+)
+
+// SessionStatus 說明 session 是否仍有效、失效時的原因（GET /auth/session-status?sid=），讓被登出的使用者知道發生了什麼事。
+// 兩種呼叫方式：
+//   - 帶 Authorization: Bearer <token>：token 已過期也接受（簽章仍須正確），預設查詢 token 本身的 session；
+//     帶 sid 時只能查詢同一個使用者的 session，其他人的 session 一律回 404
+//   - 不帶 token、只帶 sid：session ID 本身即為持有證明，只回傳狀態與原因
+//
+// 回應只包含 session 的狀態、原因與結束時間，不含 IP、裝置或帳號資料。
+func (h *AuthHandler) SessionStatus(c *gin.Context) {
+	sid := strings.TrimSpace(c.Query("sid"))
+	var ownerID int64
+	if c.GetHeader("Authorization") != "" {
+		maxTokenLen := h.cfg.JWTMaxTokenLen
+		if maxTokenLen <= 0 {
+			maxTokenLen = middleware.DefaultMaxTokenLength
+		}
+		raw, ok := bearerTokenLenient(c)
+		if !ok || len(raw) > maxTokenLen {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		parsed, err := h.jwtMgr.ParseAllowExpired(raw)
+		if err != nil || parsed.Claims.SessionID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		ownerID = parsed.Claims.UserID
+		if sid == "" {
+			sid = parsed.Claims.SessionID
+		}
+	}
+	if sid == "" || len(sid) > maxSessionIDLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	status, userID, err := h.sessSvc.SessionStatus(c.Request.Context(), sid)
+	if errors.Is(err, session.ErrSessionNotFound) || (err == nil && ownerID != 0 && userID != ownerID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query session status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// maxSessionIDLen 是 session ID 的長度上限，明顯不合法的 sid 不必查詢 DB 與 Redis。
+const maxSessionIDLen = 128
+
+// bearerTokenLenient 從 Authorization: Bearer <token> 取出 token，格式錯誤時回傳 false，由呼叫端決定回應。
+func bearerTokenLenient(c *gin.Context) (string, bool) {
+	scheme, raw, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	raw = strings.TrimSpace(raw)
+	if !ok || !strings.EqualFold(scheme, "Bearer") || raw == "" {
+		return "", false
+	}
+	return raw, true
+}
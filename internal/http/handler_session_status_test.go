@@ -0,0 +1,84 @@
+package http
+
+import (
+	"encoding/json"     // 匯入 encoding/json，解析回應
+	"net/http"          // 匯入 net/http，提供 HTTP 方法與狀態碼常數
+	"net/http/httptest" // 匯入 httptest，建立不帶 token 的請求
+	"testing"           // 匯入 testing 套件，提供單元測試框架
+	"time"              // 匯入 time，產生已過期的 token
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/session" // 匯入 session，取得 Reason 常數
+)
+
+// TestSessionStatusEndpoint 測試登出後以原 token（含已過期的 token）查詢原因、只帶 sid 查詢，以及其他使用者與錯誤 token 的處理。
+func TestSessionStatusEndpoint(t *testing.T) {
+	env := newTestEnv(t)    // 建立測試環境
+	r := newTestRouter(env) // 建立完整 router
+
+	for _, name := range []string{"alice", "bob"} {
+		w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"`+name+`","password":"password123"}`) // 註冊
+		require.Equal(t, http.StatusOK, w.Code)                                                             // 應註冊成功
+	}
+	aliceTok := loginToken(t, r, "alice", "password123") // alice 登入
+	bobTok := loginToken(t, r, "bob", "password123")     // bob 登入
+	parsed, err := env.jwtMgr.Parse(aliceTok)            // 取出 alice 的 session
+	require.NoError(t, err)                              // 應解析成功
+	sid := parsed.Claims.SessionID                       // alice 的 session ID
+
+	statusOf := func(w *httptest.ResponseRecorder) session.SessionStatus {
+		require.Equal(t, http.StatusOK, w.Code)                 // 應回 200
+		var st session.SessionStatus                            // 解析回應
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &st)) // 應為合法 JSON
+		require.Equal(t, sid, st.SessionID)                     // 應為 alice 的 session
+		return st
+	}
+
+	st := statusOf(doAuthed(r, aliceTok, http.MethodGet, "/auth/session-status", "")) // 登入中查詢
+	require.True(t, st.Active)                                                        // 應仍有效
+	require.Empty(t, st.Reason)                                                       // 有效時沒有原因
+
+	w := doAuthed(r, aliceTok, http.MethodPost, "/auth/logout", "") // alice 登出
+	require.Equal(t, http.StatusOK, w.Code)                         // 應登出成功
+
+	st = statusOf(doAuthed(r, aliceTok, http.MethodGet, "/auth/session-status", "")) // 以已撤銷的 token 查詢
+	require.False(t, st.Active)                                                      // 應已失效
+	require.Equal(t, session.SessionReasonLoggedOut, st.Reason)                      // 原因為自行登出
+	require.NotEmpty(t, st.Message)                                                  // 應附上說明
+	require.NotNil(t, st.EndedAt)                                                    // 應附上結束時間
+
+	expired, err := env.jwtMgr.GenerateWithSession(parsed.Claims.UserID, sid, time.Now().Add(-time.Hour)) // 已過期的 token
+	require.NoError(t, err)                                                                               // 應產生成功
+	st = statusOf(doAuthed(r, expired, http.MethodGet, "/auth/session-status", ""))                       // 過期 token 仍可查詢
+	require.Equal(t, session.SessionReasonLoggedOut, st.Reason)                                           // 原因不變
+
+	w = doAuthed(r, bobTok, http.MethodGet, "/auth/session-status?sid="+sid, "") // bob 查詢 alice 的 session
+	require.Equal(t, http.StatusNotFound, w.Code)                                // 不可查詢他人的 session
+
+	st = statusOf(doJSON(r, http.MethodGet, "/auth/session-status?sid="+sid, "")) // 不帶 token、只帶 sid
+	require.Equal(t, session.SessionReasonLoggedOut, st.Reason)                   // 同樣回傳原因
+
+	w = doAuthed(r, aliceTok+"x", http.MethodGet, "/auth/session-status", "") // 簽章錯誤的 token
+	require.Equal(t, http.StatusUnauthorized, w.Code)                         // 應回 401
+
+	w = doJSON(r, http.MethodGet, "/auth/session-status", "") // 沒有 token 也沒有 sid
+	require.Equal(t, http.StatusBadRequest, w.Code)           // 應回 400
+
+	w = doJSON(r, http.MethodGet, "/auth/session-status?sid=no-such-session", "") // 不存在的 session
+	require.Equal(t, http.StatusNotFound, w.Code)                                 // 應回 404
+}
+
+// TestSessionStatusRateLimited 測試同一 IP 超過查詢次數上限時回傳 429。
+func TestSessionStatusRateLimited(t *testing.T) {
+	env := newTestEnv(t)               // 建立測試環境
+	env.cfg.SessionStatusRateLimit = 2 // 每分鐘最多 2 次
+	r := newTestRouter(env)            // 建立完整 router
+
+	for i := 0; i < 2; i++ {
+		w := doJSON(r, http.MethodGet, "/auth/session-status?sid=abc", "") // 上限內的查詢
+		require.Equal(t, http.StatusNotFound, w.Code)                      // 不存在的 session 回 404
+	}
+	w := doJSON(r, http.MethodGet, "/auth/session-status?sid=abc", "") // 第三次查詢
+	require.Equal(t, http.StatusTooManyRequests, w.Code)               // 應被 rate limit
+}
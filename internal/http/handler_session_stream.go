@@ -0,0 +1,94 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"sessionservice/internal/middleware"
+	"sessionservice/internal/session"
+)
+
+// sessionStreamHeartbeat 是沒有變動時送出 SSE 註解的間隔，避免 proxy 把閒置的連線切掉。
+const sessionStreamHeartbeat = 25 * time.Second
+
+// StreamSessions 以 Server-Sent Events 推送目前使用者的活躍 session 清單（GET /auth/sessions/stream）。
+// 連上後先送一次完整清單，之後每次登入、登出、被踢或過期都重新送出（event: sessions）。
+// 目前這個 session 不在清單中時送出 event: revoked 並結束串流；client 斷線時取消訂閱。
+// 服務關閉時送出 event: shutdown 後結束串流，client 應立即重連（會連到其他 instance）。
+// client 需帶 Accept: text/event-stream，Timeout middleware 才不會緩衝並切斷這個請求。
+func (h *AuthHandler) StreamSessions(c *gin.Context) {
+	userID := c.GetInt64(middleware.ContextKeyUserID)
+	sessionID := c.GetString(middleware.ContextKeySessionID)
+	if userID == 0 || sessionID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing session in context"})
+		return
+	}
+
+	stream, ok := h.streams.Open()
+	if !ok {
+		middleware.AbortWithRetryAfter(c, http.StatusServiceUnavailable, "shutting_down", time.Second)
+		return
+	}
+	defer stream.Close()
+
+	ctx := c.Request.Context()
+	changes, err := h.sessSvc.WatchSessions(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to watch sessions"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(sessionStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	// push 送出最新清單，回傳 false 代表應結束串流
+	push := func() bool {
+		sessions, err := h.sessSvc.ListActiveSessions(ctx, userID, session.ListSessionsOptions{})
+		if err != nil {
+			return ctx.Err() == nil
+		}
+		current := false
+		for _, s := range sessions {
+			current = current || s.SessionID == sessionID
+		}
+		if !current {
+			c.SSEvent("revoked", gin.H{"session_id": sessionID})
+			c.Writer.Flush()
+			return false
+		}
+		if sessions == nil {
+			sessions = []session.ActiveSessionInfo{}
+		}
+		c.SSEvent("sessions", gin.H{"sessions": sessions})
+		c.Writer.Flush()
+		return true
+	}
+
+	if !push() {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stream.ShuttingDown():
+			c.SSEvent("shutdown", gin.H{"reconnect": true})
+			c.Writer.Flush()
+			return
+		case _, ok := <-changes:
+			if !ok || !push() {
+				return
+			}
+		case <-heartbeat.C:
+			_, _ = c.Writer.WriteString(": keep-alive\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package http
+
+import (
+	"bufio"             // 匯入 bufio，逐行讀取 SSE
+	"context"           // 匯入 context，斷線時取消請求
+	"encoding/json"     // 匯入 encoding/json，解析事件內容
+	"net/http"          // 匯入 net/http，建立串流請求
+	"net/http/httptest" // 匯入 httptest，啟動真正的 HTTP server 以支援串流
+	"strings"           // 匯入 strings，解析 SSE 欄位
+	"testing"           // 匯入 testing，提供單元測試框架
+	"time"              // 匯入 time，設定等待時間
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra"     // 匯入 infra，取得 pub/sub channel 名稱
+	"sessionservice/internal/lifecycle" // 匯入 lifecycle，模擬關閉服務的流程
+	"sessionservice/internal/session"   // 匯入 session，解析 session 清單
+)
+
+// sseEvent 是一個 SSE 事件的名稱與 data。
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readSSEEvent 從串流讀出下一個事件，略過 keep-alive 註解。
+func readSSEEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper() // 標記為測試輔助函式
+
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')      // 讀一行
+		require.NoError(t, err)              // 串流不應中斷
+		line = strings.TrimRight(line, "\n") // 去掉換行
+		switch {
+		case line == "" && ev.name != "":
+			return ev // 空行代表事件結束
+		case strings.HasPrefix(line, "event:"):
+			ev.name = strings.TrimPrefix(line, "event:") // 事件名稱
+		case strings.HasPrefix(line, "data:"):
+			ev.data = strings.TrimPrefix(line, "data:") // 事件內容
+		}
+	}
+}
+
+// TestStreamSessionsPushesOnLogin 測試第二次登入會把更新後的 session 清單推給第一個連線，斷線後取消訂閱。
+func TestStreamSessionsPushesOnLogin(t *testing.T) {
+	env := newTestEnv(t)                          // 建立測試環境
+	srv := httptest.NewServer(newTestRouter(env)) // 串流需要真正的 HTTP server
+	defer srv.Close()                             // 測試結束時關閉
+
+	w := doJSON(srv.Config.Handler, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                                           // 應註冊成功
+	tok := loginToken(t, srv.Config.Handler, "alice", "password123")                                                  // 第一個裝置登入
+
+	ctx, cancel := context.WithCancel(context.Background())                                           // 用來模擬斷線
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/auth/sessions/stream", nil) // 建立串流請求
+	require.NoError(t, err)                                                                           // 應成功
+	req.Header.Set("Authorization", "Bearer "+tok)                                                    // 帶上第一個裝置的 token
+	req.Header.Set("Accept", "text/event-stream")                                                     // 告知為 SSE 請求
+	resp, err := srv.Client().Do(req)                                                                 // 送出請求
+	require.NoError(t, err)                                                                           // 應成功
+	defer resp.Body.Close()                                                                           // 測試結束時關閉
+	require.Equal(t, http.StatusOK, resp.StatusCode)                                                  // 應回 200
+	require.Contains(t, resp.Header.Get("Content-Type"), "text/event-stream")                         // 回應為 SSE
+
+	stream := bufio.NewReader(resp.Body) // 逐行讀取串流
+	sessionsOf := func(ev sseEvent) []session.ActiveSessionInfo {
+		require.Equal(t, "sessions", ev.name) // 應為 session 清單事件
+		var body struct {
+			Sessions []session.ActiveSessionInfo `json:"sessions"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(ev.data), &body)) // data 應為合法 JSON
+		return body.Sessions
+	}
+
+	require.Len(t, sessionsOf(readSSEEvent(t, stream)), 1) // 連上後先收到目前的清單
+
+	loginToken(t, srv.Config.Handler, "alice", "password123") // 第二個裝置登入
+	require.Len(t, sessionsOf(readSSEEvent(t, stream)), 2)    // 第一個連線收到更新後的清單
+
+	cancel()                                                         // 模擬 client 斷線
+	u, err := env.q.GetUserByUsername(context.Background(), "alice") // 查出 user id
+	require.NoError(t, err)                                          // 應成功
+	channel := infra.UserSessEventsChannel(u.ID)                     // 該使用者的 channel
+	require.Eventually(t, func() bool {
+		subs, err := env.rdb.PubSubNumSub(context.Background(), channel).Result() // 查詢訂閱數
+		return err == nil && subs[channel] == 0
+	}, 2*time.Second, 20*time.Millisecond) // 斷線後取消訂閱
+}
+
+// TestStreamSessionsEndsWhenRevoked 測試目前的 session 被踢掉時送出 revoked 並結束串流。
+func TestStreamSessionsEndsWhenRevoked(t *testing.T) {
+	env := newTestEnv(t)                          // 建立測試環境
+	env.cfg.MaxSessionsPerUser = 1                // 只允許一個 session，第二次登入會踢掉第一個
+	srv := httptest.NewServer(newTestRouter(env)) // 串流需要真正的 HTTP server
+	defer srv.Close()                             // 測試結束時關閉
+
+	w := doJSON(srv.Config.Handler, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                                           // 應註冊成功
+	tok := loginToken(t, srv.Config.Handler, "alice", "password123")                                                  // 第一個裝置登入
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/auth/sessions/stream", nil) // 建立串流請求
+	require.NoError(t, err)                                                           // 應成功
+	req.Header.Set("Authorization", "Bearer "+tok)                                    // 帶上第一個裝置的 token
+	req.Header.Set("Accept", "text/event-stream")                                     // 告知為 SSE 請求
+	resp, err := srv.Client().Do(req)                                                 // 送出請求
+	require.NoError(t, err)                                                           // 應成功
+	defer resp.Body.Close()                                                           // 測試結束時關閉
+
+	stream := bufio.NewReader(resp.Body)                       // 逐行讀取串流
+	require.Equal(t, "sessions", readSSEEvent(t, stream).name) // 先收到目前的清單
+
+	loginToken(t, srv.Config.Handler, "alice", "password123") // 第二次登入踢掉第一個 session
+	require.Equal(t, "revoked", readSSEEvent(t, stream).name) // 收到 revoked
+
+	_, err = stream.ReadString('\n') // 伺服器結束串流
+	require.Error(t, err)            // 應讀到 EOF
+}
+
+// TestStreamSessionsShutdownFrame 測試 lifecycle 關閉服務時，連線中的 SSE client 收到 event: shutdown 後串流結束，之後的新串流回 503。
+func TestStreamSessionsShutdownFrame(t *testing.T) {
+	env := newTestEnv(t)                                                                               // 建立測試環境
+	streams := NewSSERegistry()                                                                        // 串流登記處
+	router := NewRouter(env.q, env.rdb, env.jwtMgr, env.sessSvc, env.cfg, nil, nil, nil, nil, streams) // 掛上登記處
+	srv := httptest.NewServer(router)                                                                  // 串流需要真正的 HTTP server
+	defer srv.Close()                                                                                  // 測試結束時關閉
+
+	w := doJSON(router, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                               // 應註冊成功
+	tok := loginToken(t, router, "alice", "password123")                                                  // 登入
+
+	openStream := func() *http.Response {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/auth/sessions/stream", nil) // 建立串流請求
+		require.NoError(t, err)                                                           // 應成功
+		req.Header.Set("Authorization", "Bearer "+tok)                                    // 帶上 token
+		req.Header.Set("Accept", "text/event-stream")                                     // 告知為 SSE 請求
+		resp, err := srv.Client().Do(req)                                                 // 送出請求
+		require.NoError(t, err)                                                           // 應成功
+		return resp
+	}
+
+	resp := openStream()                                       // 連上串流
+	defer resp.Body.Close()                                    // 測試結束時關閉
+	stream := bufio.NewReader(resp.Body)                       // 逐行讀取串流
+	require.Equal(t, "sessions", readSSEEvent(t, stream).name) // 先收到目前的清單
+	require.Equal(t, 1, streams.Active())                      // 已登記一個串流
+
+	lc := lifecycle.NewManager(time.Second)      // 模擬 cmd/api 的 lifecycle
+	lc.Register("sse streams", streams.Shutdown) // 註冊串流關閉
+	shutdownErr := make(chan error, 1)           // 接收 Shutdown 結果
+	go func() { shutdownErr <- lc.Shutdown(context.Background()) }()
+
+	ev := readSSEEvent(t, stream)                    // 讀下一個事件
+	require.Equal(t, "shutdown", ev.name)            // 應收到 shutdown
+	require.JSONEq(t, `{"reconnect":true}`, ev.data) // 提示 client 重連
+	_, err := stream.ReadString('\n')                // 伺服器結束串流
+	require.Error(t, err)                            // 應讀到 EOF
+	require.NoError(t, <-shutdownErr)                // 所有串流都在期限內結束
+	require.Zero(t, streams.Active())                // 已全部取消登記
+
+	late := openStream()                                             // 關閉期間的新串流
+	defer late.Body.Close()                                          // 測試結束時關閉
+	require.Equal(t, http.StatusServiceUnavailable, late.StatusCode) // 應回 503
+	require.NotEmpty(t, late.Header.Get("Retry-After"))              // 告知稍後重試
+}
@@ -0,0 +1,138 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/config"
+	"sessionservice/internal/infra"
+	"sessionservice/internal/middleware"
+	"sessionservice/internal/session"
+	"sessionservice/internal/token"
+)
+
+// SignedLoginHandler 處理 machine-to-machine 的 signed login：
+// client 以共用密鑰對 username、timestamp、nonce 做 HMAC，伺服器驗證後直接發 session。
+// 只有 SignedLoginUsers 列出的 machine 帳號可以用這個方式登入，共用密鑰外洩也無法冒用一般使用者。
+type SignedLoginHandler struct {
+	rdb     *redis.Client
+	jwtMgr  *token.Manager
+	sessSvc *session.SessionService
+	cfg     *config.Config
+}
+
+func NewSignedLoginHandler(rdb *redis.Client, jwtMgr *token.Manager, sessSvc *session.SessionService, cfg *config.Config) *SignedLoginHandler {
+	return &SignedLoginHandler{
+		rdb:     rdb,
+		jwtMgr:  jwtMgr,
+		sessSvc: sessSvc,
+		cfg:     cfg,
+	}
+}
+
+type signedLoginRequest struct {
+	Username  string `json:"username" binding:"required"`
+	Timestamp int64  `json:"timestamp" binding:"required"` // unix 秒
+	Nonce     string `json:"nonce" binding:"required"`
+	Signature string `json:"signature" binding:"required"` // hex(HMAC-SHA256(secret, username\ntimestamp\nnonce))
+}
+
+// SignedLoginSignature 計算 signed login 的簽章，client 與伺服器使用相同算法。
+func SignedLoginSignature(secret, username string, timestamp int64, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(username + "\n" + strconv.FormatInt(timestamp, 10) + "\n" + nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Login 依序檢查時間戳、簽章與 nonce；簽章通過後才消耗 nonce，避免他人以偽造請求佔用 nonce。
+func (h *SignedLoginHandler) Login(c *gin.Context) {
+	var req signedLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	skew := h.cfg.SignedLoginMaxSkew
+	signedAt := time.Unix(req.Timestamp, 0)
+	if d := time.Since(signedAt); d > skew || d < -skew {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "stale_timestamp"})
+		return
+	}
+
+	expected := SignedLoginSignature(h.cfg.SignedLoginSecret, req.Username, req.Timestamp, req.Nonce)
+	if !hmac.Equal([]byte(expected), []byte(req.Signature)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_signature"})
+		return
+	}
+
+	username := session.NormalizeUsername(req.Username)
+	if !slices.ContainsFunc(h.cfg.SignedLoginUsers, func(allowed string) bool { return session.NormalizeUsername(allowed) == username }) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "signed_login_not_allowed"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	// nonce 的有效期間需涵蓋時間戳可被接受的整個區間（前後各 skew）
+	fresh, err := infra.ConsumeNonce(ctx, h.rdb, username+":"+req.Nonce, 2*skew)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		return
+	}
+	if !fresh {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "nonce_reused"})
+		return
+	}
+
+	meta := session.LoginMeta{
+		IP:        c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+		Country:   clientCountry(c, h.cfg.GeoCountryHeader),
+	}
+	user, sessionID, expiresAt, err := h.sessSvc.LoginTrusted(ctx, req.Username, meta)
+	if err != nil {
+		var rateErr *session.LoginRateLimitError
+		switch {
+		case errors.As(err, &rateErr):
+			middleware.AbortWithRetryAfter(c, http.StatusTooManyRequests, "login_rate_limited", rateErr.RetryAfter)
+		case errors.Is(err, session.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		case errors.Is(err, session.ErrUserBanned):
+			c.JSON(http.StatusForbidden, gin.H{"error": "user is banned"})
+		case errors.Is(err, session.ErrPasswordResetRequired):
+			c.JSON(http.StatusForbidden, gin.H{"error": "password_reset_required"})
+		case errors.Is(err, session.ErrPasswordExpired):
+			c.JSON(http.StatusForbidden, gin.H{"error": "password_expired"})
+		case errors.Is(err, session.ErrSessionLimitReached):
+			c.JSON(http.StatusConflict, gin.H{"error": "session_limit_reached"})
+		case errors.Is(err, session.ErrCountryBlocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": "country_blocked"})
+		case errors.Is(err, session.ErrMultiCountrySession):
+			c.JSON(http.StatusForbidden, gin.H{"error": "multi_country_session"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		}
+		return
+	}
+
+	tokenStr, expiresAt, err := signSessionToken(c.Request.Context(), h.sessSvc, h.jwtMgr, user.ID, sessionID, expiresAt, token.AMRKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	resp := loginResponse{
+		AccessToken: tokenStr,
+		ExpiresIn:   int64(time.Until(expiresAt).Seconds()),
+	}
+	addSessionWarning(c.Request.Context(), h.sessSvc, &resp, user.ID)
+	c.JSON(http.StatusOK, resp)
+}
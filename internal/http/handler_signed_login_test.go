@@ -0,0 +1,134 @@
+package http
+
+import (
+	"context"       // 匯入 context，直接操作 DB
+	"encoding/json" // 匯入 encoding/json，解析登入回應
+	"fmt"           // 匯入 fmt，組出 JSON 請求 body
+	"net/http"      // 匯入 net/http，使用 method 與狀態碼常數
+	"testing"       // 匯入 testing，提供單元測試框架
+	"time"          // 匯入 time，產生簽章時間戳
+
+	"github.com/gin-gonic/gin"            // 匯入 gin，型別標註 router
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/token" // 匯入 token，使用 amr 常數
+)
+
+// newSignedLoginEnv 啟用 signed login（只允許 robot）並建立 robot 與一般使用者 alice。
+func newSignedLoginEnv(t *testing.T) (*testEnv, *gin.Engine) {
+	t.Helper()                                   // 標記為測試輔助函式
+	env := newTestEnv(t)                         // 建立測試環境
+	env.cfg.SignedLoginSecret = "m2m-secret"     // 設定共用密鑰，開放 signed login
+	env.cfg.SignedLoginMaxSkew = time.Minute     // 時間戳允許前後 1 分鐘
+	env.cfg.SignedLoginUsers = []string{"Robot"} // 只有 machine 帳號 robot 可用 signed login
+	r := newTestRouter(env)                      // 建立完整 router
+
+	for _, name := range []string{"robot", "alice"} { // 建立 machine 帳號與一般使用者
+		w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"`+name+`","password":"password123"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	return env, r
+}
+
+// newSignedLoginRouter 與 newSignedLoginEnv 相同，只回傳 router。
+func newSignedLoginRouter(t *testing.T) *gin.Engine {
+	t.Helper()                   // 標記為測試輔助函式
+	_, r := newSignedLoginEnv(t) // 建立啟用 signed login 的環境
+	return r
+}
+
+// signedLoginBody 以指定的時間戳與 nonce 產生 robot 正確簽章的請求 body。
+func signedLoginBody(ts int64, nonce string) string {
+	return signedLoginBodyFor("robot", ts, nonce)
+}
+
+// signedLoginBodyFor 以指定的 username、時間戳與 nonce 產生正確簽章的請求 body。
+func signedLoginBodyFor(username string, ts int64, nonce string) string {
+	sig := SignedLoginSignature("m2m-secret", username, ts, nonce) // 與 client 相同的簽章算法
+	return fmt.Sprintf(`{"username":%q,"timestamp":%d,"nonce":%q,"signature":%q}`, username, ts, nonce, sig)
+}
+
+// TestSignedLoginFirstUseAndReplay 測試 nonce 第一次使用成功，重放同一個請求會被拒絕。
+func TestSignedLoginFirstUseAndReplay(t *testing.T) {
+	r := newSignedLoginRouter(t)                      // 建立啟用 signed login 的 router
+	body := signedLoginBody(time.Now().Unix(), "n-1") // 產生簽章請求
+
+	w := doJSON(r, http.MethodPost, "/auth/login/signed", body) // 第一次送出
+	require.Equal(t, http.StatusOK, w.Code)                     // 應登入成功
+	require.Contains(t, w.Body.String(), "access_token")        // 應回傳 access_token
+
+	w = doJSON(r, http.MethodPost, "/auth/login/signed", body) // 原封不動重放
+	require.Equal(t, http.StatusUnauthorized, w.Code)          // 應被拒絕
+	require.Contains(t, w.Body.String(), "nonce_reused")       // 原因為 nonce 已使用
+}
+
+// TestSignedLoginRejectsStaleOrForged 測試過期時間戳與錯誤簽章都會被拒絕，且不會消耗 nonce。
+func TestSignedLoginRejectsStaleOrForged(t *testing.T) {
+	r := newSignedLoginRouter(t) // 建立啟用 signed login 的 router
+
+	stale := signedLoginBody(time.Now().Add(-2*time.Minute).Unix(), "n-2") // 超過允許誤差的時間戳
+	w := doJSON(r, http.MethodPost, "/auth/login/signed", stale)           // 送出
+	require.Equal(t, http.StatusUnauthorized, w.Code)                      // 應被拒絕
+	require.Contains(t, w.Body.String(), "stale_timestamp")                // 原因為時間戳過期
+
+	forged := fmt.Sprintf(`{"username":"robot","timestamp":%d,"nonce":"n-3","signature":"deadbeef"}`, time.Now().Unix()) // 錯誤簽章
+	w = doJSON(r, http.MethodPost, "/auth/login/signed", forged)                                                         // 送出
+	require.Equal(t, http.StatusUnauthorized, w.Code)                                                                    // 應被拒絕
+	require.Contains(t, w.Body.String(), "invalid_signature")                                                            // 原因為簽章錯誤
+
+	w = doJSON(r, http.MethodPost, "/auth/login/signed", signedLoginBody(time.Now().Unix(), "n-3")) // 以同一個 nonce 正確簽章
+	require.Equal(t, http.StatusOK, w.Code)                                                         // 偽造請求沒有消耗 nonce，應成功
+}
+
+// TestSignedLoginDisabledWithoutSecret 測試未設定共用密鑰時不開放 signed login。
+func TestSignedLoginDisabledWithoutSecret(t *testing.T) {
+	env := newTestEnv(t)    // 建立測試環境（未設定密鑰）
+	r := newTestRouter(env) // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/login/signed", signedLoginBody(time.Now().Unix(), "n-4")) // 送出請求
+	require.Equal(t, http.StatusNotFound, w.Code)                                                    // 路由不存在
+}
+
+// TestSignedLoginStampsAMR 測試 signed login 發出的 token 以 amr=["swk"] 標記為密鑰簽章登入。
+func TestSignedLoginStampsAMR(t *testing.T) {
+	env, r := newSignedLoginEnv(t) // 建立啟用 signed login 的環境
+
+	w := doJSON(r, http.MethodPost, "/auth/login/signed", signedLoginBody(time.Now().Unix(), "n-amr")) // signed login
+	require.Equal(t, http.StatusOK, w.Code)                                                            // 應登入成功
+	var resp loginResponse                                                                             // 解析回應
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))                                          // 應為合法 JSON
+
+	parsed, err := env.jwtMgr.Parse(resp.AccessToken)           // 解析 token
+	require.NoError(t, err)                                     // 應可解析
+	require.Equal(t, []string{token.AMRKey}, parsed.Claims.AMR) // 應標記為密鑰簽章登入
+}
+
+// TestSignedLoginOnlyAllowedUsers 測試共用密鑰只能替 SIGNED_LOGIN_USERS 列出的帳號登入，簽章正確也無法冒用一般使用者。
+func TestSignedLoginOnlyAllowedUsers(t *testing.T) {
+	r := newSignedLoginRouter(t) // 建立啟用 signed login 的 router
+
+	w := doJSON(r, http.MethodPost, "/auth/login/signed", signedLoginBodyFor("alice", time.Now().Unix(), "n-5")) // 替一般使用者簽章
+	require.Equal(t, http.StatusForbidden, w.Code)                                                               // 應被拒絕
+	require.Contains(t, w.Body.String(), "signed_login_not_allowed")                                             // 原因為帳號不在清單內
+}
+
+// TestSignedLoginRespectsPasswordGates 測試 signed login 與密碼登入相同地擋下 force-reset 與密碼過期的帳號。
+func TestSignedLoginRespectsPasswordGates(t *testing.T) {
+	env, r := newSignedLoginEnv(t) // 建立啟用 signed login 的環境
+	ctx := context.Background()    // 背景 context
+
+	_, err := env.sqlDB.ExecContext(ctx, "UPDATE users SET must_reset_password = 1 WHERE username = 'robot'") // 標記需重設密碼
+	require.NoError(t, err)                                                                                   // 應更新成功
+	w := doJSON(r, http.MethodPost, "/auth/login/signed", signedLoginBody(time.Now().Unix(), "n-6"))          // signed login
+	require.Equal(t, http.StatusForbidden, w.Code)                                                            // 應被拒絕
+	require.Contains(t, w.Body.String(), "password_reset_required")                                           // 原因為需重設密碼
+
+	_, err = env.sqlDB.ExecContext(ctx, "UPDATE users SET must_reset_password = 0, password_changed_at = ? WHERE username = 'robot'",
+		time.Now().UTC().Add(-31*24*time.Hour).Format(time.DateTime)) // 改為密碼已使用 31 天
+	require.NoError(t, err)                                                                         // 應更新成功
+	env.cfg.PasswordMaxAge = 30 * 24 * time.Hour                                                    // 密碼最多使用 30 天
+	w = doJSON(r, http.MethodPost, "/auth/login/signed", signedLoginBody(time.Now().Unix(), "n-7")) // signed login
+	require.Equal(t, http.StatusForbidden, w.Code)                                                  // 應被拒絕
+	require.Contains(t, w.Body.String(), "password_expired")                                        // 原因為密碼過期
+	require.NotContains(t, w.Body.String(), "reset_token")                                          // 不替 machine caller 發重設 token
+}
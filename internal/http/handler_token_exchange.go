@@ -0,0 +1,90 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"sessionservice/internal/middleware"
+	"sessionservice/internal/token"
+)
+
+type tokenExchangeRequest struct {
+	Audience string   `json:"audience" binding:"required"`
+	Scopes   []string `json:"scopes" binding:"required,min=1"`
+}
+
+type tokenExchangeResponse struct {
+	AccessToken string   `json:"access_token"`
+	Audience    string   `json:"audience"`
+	Scopes      []string `json:"scopes"`
+	ExpiresIn   int64    `json:"expires_in"` // seconds
+}
+
+// ExchangeToken 以目前的 session token 換出給下游服務使用、範圍更窄的短效 token（RFC 8693 的簡化版）。
+// 換出的 token 綁定同一個 session（登出或被踢後一併失效），scopes 必須是 TokenExchangeScopes 的子集，
+// 且到期時間不會晚於原 token。
+func (h *AuthHandler) ExchangeToken(c *gin.Context) {
+	var req tokenExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	userID := c.GetInt64(middleware.ContextKeyUserID)
+	sessionID := c.GetString(middleware.ContextKeySessionID)
+	if userID == 0 || sessionID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing session in context"})
+		return
+	}
+
+	if !slices.Contains(h.cfg.TokenExchangeAudiences, req.Audience) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_target"})
+		return
+	}
+
+	var scopes []string
+	for _, scope := range req.Scopes {
+		if !slices.Contains(h.cfg.TokenExchangeScopes, scope) {
+			// 要求呼叫端本身沒有的 scope 視為越權
+			c.JSON(http.StatusForbidden, gin.H{"error": "invalid_scope", "scope": scope})
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(h.cfg.TokenExchangeTTL)
+	if callerExp, ok := c.Get(middleware.ContextKeyTokenExpiresAt); ok {
+		if t, ok := callerExp.(time.Time); ok && t.Before(expiresAt) {
+			expiresAt = t
+		}
+	}
+
+	expiresAt = h.jwtMgr.CapExpiry(expiresAt)
+
+	amr, _ := c.Get(middleware.ContextKeyAMR)
+	callerAMR, _ := amr.([]string)
+
+	tokenStr, err := h.jwtMgr.GenerateExchanged(userID, sessionID, req.Audience, scopes, callerAMR, expiresAt)
+	if errors.Is(err, token.ErrTooManyScopes) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too_many_scopes", "max_scopes": h.cfg.MaxTokenScopes})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenExchangeResponse{
+		AccessToken: tokenStr,
+		Audience:    req.Audience,
+		Scopes:      scopes,
+		ExpiresIn:   int64(expiresAt.Sub(now).Seconds()),
+	})
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"encoding/json" // 匯入 encoding/json，解析 exchange 回應
+	"net/http"      // 匯入 net/http，使用 method 與狀態碼常數
+	"testing"       // 匯入 testing，提供單元測試框架
+	"time"          // 匯入 time，設定換出 token 的存活時間
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// newExchangeTestEnv 建立允許換發 billing audience、兩個 scope 的測試環境，並註冊登入 alice。
+func newExchangeTestEnv(t *testing.T) (*testEnv, http.Handler, string) {
+	t.Helper()                                                              // 標記為測試輔助函式
+	env := newTestEnv(t)                                                    // 建立測試環境
+	env.cfg.TokenExchangeScopes = []string{"invoices:read", "profile:read"} // session token 可換出的 scope
+	env.cfg.TokenExchangeAudiences = []string{"billing"}                    // 允許的下游服務
+	env.cfg.TokenExchangeTTL = 5 * time.Minute                              // 換出 token 存活 5 分鐘
+	r := newTestRouter(env)                                                 // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+	return env, r, loginToken(t, r, "alice", "password123")
+}
+
+// TestTokenExchangeNarrowing 測試以 session token 換出限定 audience 與 scope 的短效 token，且綁定同一 session。
+func TestTokenExchangeNarrowing(t *testing.T) {
+	env, r, tok := newExchangeTestEnv(t) // 建立測試環境並登入
+
+	w := doAuthed(r, tok, http.MethodPost, "/auth/token/exchange", `{"audience":"billing","scopes":["invoices:read"]}`) // 換出只讀發票的 token
+	require.Equal(t, http.StatusOK, w.Code)                                                                             // 應成功
+
+	var resp tokenExchangeResponse                                     // 解析回應
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))          // 應為合法 JSON
+	require.Equal(t, []string{"invoices:read"}, resp.Scopes)           // 只包含要求的 scope
+	require.InDelta(t, (5 * time.Minute).Seconds(), resp.ExpiresIn, 2) // 存活時間為設定的 TTL
+
+	parsed, err := env.jwtMgr.Parse(tok)                                       // 原 session token
+	require.NoError(t, err)                                                    // 應可解析
+	exchanged, err := env.jwtMgr.Parse(resp.AccessToken)                       // 換出的 token
+	require.NoError(t, err)                                                    // 應可解析
+	require.Equal(t, parsed.Claims.UserID, exchanged.Claims.UserID)            // 同一個使用者
+	require.Equal(t, parsed.Claims.SessionID, exchanged.Claims.SessionID)      // 綁定同一個 session
+	require.Equal(t, "invoices:read", exchanged.Claims.Scope)                  // scope claim 正確
+	require.Equal(t, []string{"billing"}, []string(exchanged.Claims.Audience)) // audience claim 正確
+
+	w = doAuthed(r, resp.AccessToken, http.MethodGet, "/me", "") // 拿下游 token 呼叫本服務
+	require.Equal(t, http.StatusUnauthorized, w.Code)            // 應被拒絕
+}
+
+// TestTokenExchangeRejectsEscalation 測試要求呼叫端沒有的 scope 或未允許的 audience 時會被拒絕。
+func TestTokenExchangeRejectsEscalation(t *testing.T) {
+	_, r, tok := newExchangeTestEnv(t) // 建立測試環境並登入
+
+	w := doAuthed(r, tok, http.MethodPost, "/auth/token/exchange", `{"audience":"billing","scopes":["invoices:read","invoices:write"]}`) // 混入沒有的 scope
+	require.Equal(t, http.StatusForbidden, w.Code)                                                                                       // 應回 403
+	require.JSONEq(t, `{"error":"invalid_scope","scope":"invoices:write"}`, w.Body.String())                                             // 指出越權的 scope
+
+	w = doAuthed(r, tok, http.MethodPost, "/auth/token/exchange", `{"audience":"payroll","scopes":["invoices:read"]}`) // 未允許的 audience
+	require.Equal(t, http.StatusBadRequest, w.Code)                                                                    // 應回 400
+	require.JSONEq(t, `{"error":"invalid_target"}`, w.Body.String())                                                   // 指出 audience 無效
+
+	w = doAuthed(r, tok, http.MethodPost, "/auth/token/exchange", `{"audience":"billing","scopes":[]}`) // 沒有指定 scope
+	require.Equal(t, http.StatusBadRequest, w.Code)                                                     // 應回 400
+
+	w = doJSON(r, http.MethodPost, "/auth/token/exchange", `{"audience":"billing","scopes":["invoices:read"]}`) // 未帶 token
+	require.Equal(t, http.StatusUnauthorized, w.Code)                                                           // 應回 401
+}
+
+// TestTokenExchangeScopeLimit 測試 scope 數超過 MaxTokenScopes 時拒絕換發，未超過時照常換發。
+func TestTokenExchangeScopeLimit(t *testing.T) {
+	env, r, tok := newExchangeTestEnv(t)                             // 建立測試環境並登入
+	env.cfg.MaxTokenScopes = 1                                       // 每個 token 最多 1 個 scope
+	env.jwtMgr.WithScopeLimit(1, false, env.cfg.TokenExchangeScopes) // 與 main 相同的設定方式
+
+	w := doAuthed(r, tok, http.MethodPost, "/auth/token/exchange", `{"audience":"billing","scopes":["invoices:read"]}`) // 1 個 scope
+	require.Equal(t, http.StatusOK, w.Code)                                                                             // 未超過上限照常換發
+
+	w = doAuthed(r, tok, http.MethodPost, "/auth/token/exchange", `{"audience":"billing","scopes":["invoices:read","profile:read"]}`) // 2 個 scope
+	require.Equal(t, http.StatusBadRequest, w.Code)                                                                                   // 應回 400
+	require.JSONEq(t, `{"error":"too_many_scopes","max_scopes":1}`, w.Body.String())                                                  // 指出上限
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"sessionservice/internal/middleware"
+	"sessionservice/internal/session"
+	"sessionservice/internal/token"
+)
+
+type validateBatchRequest struct {
+	Tokens []string `json:"tokens" binding:"required,min=1"`
+}
+
+// validateBatchResult 是單一 token 的驗證結果；簽章或格式不合法的 token 沒有 user_id / session_id。
+type validateBatchResult struct {
+	Active    bool   `json:"active"`
+	UserID    int64  `json:"user_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// ValidateBatch 一次驗證多顆 access token（POST /auth/validate-batch，需要 admin key），給 gateway 對帳連線池使用。
+// 每顆 token 的判斷與 JWT middleware 相同：簽章、到期時間、只接受一般使用者 API 的 audience（不含 token exchange 換出的 token），以及 session 仍有效
+// （TOKEN_MODE=opaque 時改查 reference token）；
+// session 的檢查以一個 Redis pipeline 完成。results 與 tokens 依序一一對應，超過 VALIDATE_BATCH_MAX_TOKENS 時回 400。
+func (h *AuthHandler) ValidateBatch(c *gin.Context) {
+	var req validateBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	if len(req.Tokens) > h.cfg.ValidateBatchMaxTokens {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too_many_tokens", "max_tokens": h.cfg.ValidateBatchMaxTokens})
+		return
+	}
+
+	maxTokenLen := h.cfg.JWTMaxTokenLen
+	if maxTokenLen <= 0 {
+		maxTokenLen = middleware.DefaultMaxTokenLength
+	}
+
+	ctx := c.Request.Context()
+	results := make([]validateBatchResult, len(req.Tokens))
+	var refs []session.SessionRef
+	var refIdx []int
+	for i, raw := range req.Tokens {
+		if len(raw) > maxTokenLen {
+			continue
+		}
+		var ref session.SessionRef
+		var otherAudience bool
+		if h.sessSvc.OpaqueTokensEnabled() {
+			tok, err := h.sessSvc.ResolveOpaqueToken(ctx, raw)
+			if err != nil {
+				continue
+			}
+			ref = session.SessionRef{UserID: tok.UserID, SessionID: tok.SessionID}
+		} else {
+			parsed, err := h.jwtMgr.Parse(raw)
+			if err != nil {
+				continue
+			}
+			claims := parsed.Claims
+			ref = session.SessionRef{UserID: claims.UserID, SessionID: claims.SessionID}
+			otherAudience = !h.jwtMgr.AcceptsAudience(claims, token.AudienceUser)
+		}
+		results[i].UserID = ref.UserID
+		results[i].SessionID = ref.SessionID
+		if otherAudience || ref.SessionID == "" {
+			continue
+		}
+		refs = append(refs, ref)
+		refIdx = append(refIdx, i)
+	}
+
+	valid, err := h.sessSvc.ValidateSessions(ctx, refs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "session_check_failed"})
+		return
+	}
+	for j, i := range refIdx {
+		results[i].Active = valid[j]
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"encoding/json" // 匯入 encoding/json，組出 request body 與解析回應
+	"net/http"      // 匯入 net/http，提供 HTTP 方法與狀態碼常數
+	"testing"       // 匯入 testing 套件，提供單元測試框架
+	"time"          // 匯入 time，產生已過期的 token
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// TestValidateBatch 測試有效、已登出、簽章錯誤、過期與沒有 session 的 token 混在同一批時，各自回傳正確結果。
+func TestValidateBatch(t *testing.T) {
+	env := newTestEnv(t)                // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin"  // 設定 admin token
+	env.cfg.ValidateBatchMaxTokens = 10 // 單次最多 10 顆 token
+	r := newTestRouter(env)             // 建立完整 router
+
+	for _, name := range []string{"alice", "bob"} {
+		w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"`+name+`","password":"password123"}`) // 註冊
+		require.Equal(t, http.StatusOK, w.Code)                                                             // 應註冊成功
+	}
+	aliceTok := loginToken(t, r, "alice", "password123") // alice 登入
+	bobTok := loginToken(t, r, "bob", "password123")     // bob 登入
+	alice, err := env.jwtMgr.Parse(aliceTok)             // 取出 alice 的 claims
+	require.NoError(t, err)                              // 應解析成功
+	bob, err := env.jwtMgr.Parse(bobTok)                 // 取出 bob 的 claims
+	require.NoError(t, err)                              // 應解析成功
+
+	w := doAuthed(r, bobTok, http.MethodPost, "/auth/logout", "") // bob 登出，token 的 session 已撤銷
+	require.Equal(t, http.StatusOK, w.Code)                       // 應登出成功
+
+	expired, err := env.jwtMgr.GenerateWithSession(alice.Claims.UserID, alice.Claims.SessionID, time.Now().Add(-time.Minute)) // 已過期的 token
+	require.NoError(t, err)                                                                                                   // 應產生成功
+	noSession, err := env.jwtMgr.Generate(alice.Claims.UserID)                                                                // 沒有 session 的 token
+	require.NoError(t, err)                                                                                                   // 應產生成功
+
+	body, err := json.Marshal(map[string][]string{"tokens": {aliceTok, bobTok, "not-a-jwt", aliceTok + "x", expired, noSession}}) // 混合的 batch
+	require.NoError(t, err)                                                                                                       // 應序列化成功
+	w = doAdmin(r, env, http.MethodPost, "/auth/validate-batch", string(body))                                                    // 批次驗證
+	require.Equal(t, http.StatusOK, w.Code)                                                                                       // 應回 200
+
+	var resp struct {
+		Results []validateBatchResult `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp)) // 應為合法 JSON
+	require.Equal(t, []validateBatchResult{
+		{Active: true, UserID: alice.Claims.UserID, SessionID: alice.Claims.SessionID}, // 有效的 token
+		{Active: false, UserID: bob.Claims.UserID, SessionID: bob.Claims.SessionID},    // 已登出，仍回傳 token 內的 ID
+		{},                            // 格式錯誤
+		{},                            // 簽章錯誤
+		{},                            // 已過期
+		{UserID: alice.Claims.UserID}, // 沒有 session 的 token 一律無效
+	}, resp.Results) // 結果依序對應
+}
+
+// TestValidateBatchLimits 測試沒有 admin key 時回 403、空的 batch 與超過上限時回 400。
+func TestValidateBatchLimits(t *testing.T) {
+	env := newTestEnv(t)               // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin" // 設定 admin token
+	env.cfg.ValidateBatchMaxTokens = 2 // 單次最多 2 顆 token
+	r := newTestRouter(env)            // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/validate-batch", `{"tokens":["a"]}`) // 沒帶 admin key
+	require.Equal(t, http.StatusForbidden, w.Code)                              // 應回 403
+
+	w = doAdmin(r, env, http.MethodPost, "/auth/validate-batch", `{"tokens":[]}`) // 空的 batch
+	require.Equal(t, http.StatusBadRequest, w.Code)                               // 應回 400
+
+	w = doAdmin(r, env, http.MethodPost, "/auth/validate-batch", `{"tokens":["a","b","c"]}`) // 超過上限
+	require.Equal(t, http.StatusBadRequest, w.Code)                                          // 應回 400
+	require.Contains(t, w.Body.String(), "too_many_tokens")                                  // 錯誤碼為 too_many_tokens
+}
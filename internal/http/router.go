@@ -1,61 +1,229 @@
 package http
 
 import (
+	"log"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
 
+	"sessionservice/internal/challenge"
+	"sessionservice/internal/config"
 	"sessionservice/internal/db"
+	"sessionservice/internal/health"
+	"sessionservice/internal/metrics"
 	"sessionservice/internal/middleware"
 	"sessionservice/internal/session"
 	"sessionservice/internal/token"
 )
 
 // NewRouter 建立並回傳一個已註冊好路由的 *gin.Engine。
-// 處理 /health, /auth/*, /me, 以及 /admin/* 管理端 API。
+// 處理 /health, /ready, /auth/*, /me, 以及 /admin/* 管理端 API。
+// adminAudit 可為 nil；非 nil 時 admin key 驗證失敗會交給它記錄指標與通知。
+// httpMetrics 可為 nil；非 nil 且 METRICS_MODE 不是 off 時，每個請求依路由樣板記錄請求數與延遲。
+// streams 可為 nil；非 nil 時 SSE 串流登記在其中，由呼叫端在關閉服務時呼叫 streams.Shutdown。
 func NewRouter(
 	q *db.Queries,
+	rdb *redis.Client,
 	jwtMgr *token.Manager,
 	sessSvc *session.SessionService,
-	tokenTTL time.Duration,
-	adminAPIKey string,
+	cfg *config.Config,
+	signupChallenge challenge.Verifier,
+	readiness *health.Checker,
+	adminAudit middleware.AdminAuthFailureReporter,
+	httpMetrics middleware.HTTPMetrics,
+	streams *SSERegistry,
 ) *gin.Engine {
 	r := gin.Default()
+	// ClientIP 與 RequireHTTPS 共用同一份受信任 proxy 清單，來自其他位址的 forwarded header 一律忽略
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	// 最先掛上，被後面的 middleware 拒絕的請求（HTTPS、Content-Type、逾時）也會計入
+	if httpMetrics != nil && cfg.MetricsMode != metrics.ModeOff {
+		r.Use(middleware.HTTPRequestMetrics(httpMetrics))
+	}
+	if cfg.AppEnv == "production" && cfg.ForceHTTPS {
+		trusted, err := middleware.ParseTrustedProxies(cfg.TrustedProxies)
+		if err != nil {
+			log.Printf("invalid TRUSTED_PROXIES: %v", err)
+		}
+		r.Use(middleware.RequireHTTPS(trusted, "/health", "/ready"))
+	}
+	// request ID 先放進 request context，Timeout 衍生的 context 與排入的任務都會沿用
+	r.Use(middleware.RequestID())
+	// SSE 串流以路由排除，不依賴 client 是否帶 Accept: text/event-stream
+	r.Use(middleware.Timeout(cfg.RequestTimeout, "/auth/sessions/stream"))
+	r.Use(middleware.SecurityHeaders(map[string]string{
+		"X-Content-Type-Options":    cfg.HeaderContentTypeOptions,
+		"X-Frame-Options":           cfg.HeaderFrameOptions,
+		"Referrer-Policy":           cfg.HeaderReferrerPolicy,
+		"Strict-Transport-Security": cfg.HeaderHSTS,
+		"Content-Security-Policy":   cfg.HeaderCSP,
+	}))
+	if cfg.RequireJSONContentType {
+		var formPaths, beaconPaths []string
+		if cfg.AllowFormLogin {
+			// 這幾個路由的 handler 以 ShouldBind 同時支援 JSON 與 form
+			formPaths = []string{"/auth/signup", "/auth/login", "/auth/password/reset"}
+		}
+		if cfg.LogoutBodyToken {
+			// sendBeacon 送出字串時為 text/plain、送出 URLSearchParams 時為 form，兩者都要放行
+			formPaths = append(formPaths, "/auth/logout")
+			beaconPaths = append(beaconPaths, "/auth/logout")
+		}
+		if cfg.MagicLinkEnabled {
+			// magic link 確認頁以 HTML form 送出 token
+			formPaths = append(formPaths, "/auth/magic-login")
+		}
+		r.Use(middleware.RequireJSONContentTypeWithBeacon(formPaths, beaconPaths))
+	}
+	// query 帶有 redirect_uri / return_to 的請求一律先檢查 allow-list，避免 open redirect
+	r.Use(middleware.ValidateRedirectParams(cfg.OAuthAllowedRedirects))
+
+	// 未知路由與不支援的 method 一律回 JSON，避免 client 收到 Gin 預設的 HTML
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "NOT_FOUND"}})
+	})
+	r.NoMethod(func(c *gin.Context) {
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": gin.H{"code": "METHOD_NOT_ALLOWED"}})
+	})
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
-	authHandler := NewAuthHandler(q, jwtMgr, sessSvc, tokenTTL)
-	adminHandler := NewAdminHandler(sessSvc)
+	// Readiness check（DB 與 Redis，結果會短暫快取，並附上各相依服務的延遲）
+	if readiness != nil {
+		r.GET("/ready", func(c *gin.Context) {
+			report := readiness.Check(c.Request.Context())
+			body := gin.H{"status": "ready"}
+			for name, dep := range report.Dependencies {
+				body[name] = dep
+			}
+			if !report.OK {
+				body["status"] = "unavailable"
+				c.JSON(http.StatusServiceUnavailable, body)
+				return
+			}
+			c.JSON(http.StatusOK, body)
+		})
+	}
+
+	authHandler := NewAuthHandler(q, jwtMgr, sessSvc, cfg, signupChallenge)
+	if streams != nil {
+		authHandler.WithStreams(streams)
+	}
+	adminHandler := NewAdminHandler(q, sessSvc, cfg)
 
 	// 不需驗證的 auth 路由
 	auth := r.Group("/auth")
 	{
 		auth.POST("/signup", authHandler.Signup)
 		auth.POST("/login", authHandler.Login)
+		auth.POST("/password/reset",
+			middleware.NewRateLimitMiddleware(rdb, "password_reset", cfg.PasswordResetRateLimit, time.Minute),
+			authHandler.ResetPassword,
+		)
+		auth.GET("/username-available",
+			middleware.NewRateLimitMiddleware(rdb, "username_check", cfg.UsernameCheckRateLimit, time.Minute),
+			authHandler.UsernameAvailable,
+		)
+		// token 已過期或 session 已失效時仍可查詢，因此不經過 JWT middleware
+		auth.GET("/session-status",
+			middleware.NewRateLimitMiddleware(rdb, "session_status", cfg.SessionStatusRateLimit, time.Minute),
+			authHandler.SessionStatus,
+		)
 	}
 
-	// 需要 JWT 的路由
+	// Machine-to-machine signed login（未設定共用密鑰時不開放）
+	if cfg.SignedLoginSecret != "" {
+		signedLoginHandler := NewSignedLoginHandler(rdb, jwtMgr, sessSvc, cfg)
+		r.POST("/auth/login/signed", signedLoginHandler.Login)
+	}
+
+	// Magic link 登入（MAGIC_LINK_ENABLED 未開啟時不開放）
+	if cfg.MagicLinkEnabled {
+		magicLinkHandler := NewMagicLinkHandler(jwtMgr, sessSvc, cfg)
+		r.POST("/auth/magic-link",
+			middleware.NewRateLimitMiddleware(rdb, "magic_link", cfg.MagicLinkRateLimit, time.Minute),
+			magicLinkHandler.Request,
+		)
+		r.GET("/auth/magic-login", magicLinkHandler.Confirm)
+		r.POST("/auth/magic-login", magicLinkHandler.Login)
+	}
+
+	// 需要 JWT 的路由：只接受一般使用者 audience 的 token，admin audience 或 token exchange 換出的 token 會被拒絕
 	authRequired := r.Group("/")
-	authRequired.Use(middleware.NewAuthJWTMiddleware(jwtMgr, sessSvc))
+	authRequired.Use(middleware.NewAudienceAuthMiddleware(jwtMgr, sessSvc, cfg.JWTMaxTokenLen, token.AudienceUser))
 	{
 		authRequired.GET("/me", authHandler.Me)
-		authRequired.POST("/auth/logout", authHandler.Logout)
+		if !cfg.LogoutBodyToken {
+			authRequired.POST("/auth/logout", authHandler.Logout)
+		}
+		authRequired.POST("/auth/refresh", authHandler.Refresh)
+		authRequired.GET("/auth/token-info", authHandler.TokenInfo)
+		authRequired.PATCH("/auth/username", authHandler.ChangeUsername)
+		authRequired.POST("/auth/token/exchange", authHandler.ExchangeToken)
+		authRequired.GET("/auth/sessions/stream", authHandler.StreamSessions)
+		authRequired.POST("/auth/sessions/:sid/pin", authHandler.PinSession)
+		authRequired.DELETE("/auth/sessions/:sid/pin", authHandler.UnpinSession)
+	}
+
+	// 接受 body token 的 logout：沒有 Authorization header 時改驗證 body 的 token，簽章與 session 檢查不變
+	if cfg.LogoutBodyToken {
+		r.POST("/auth/logout", middleware.NewBodyTokenAuthMiddleware(jwtMgr, sessSvc, cfg.JWTMaxTokenLen), authHandler.Logout)
+	}
+
+	// gateway 批次驗證 token（以 admin key 保護，不經過 JWT middleware）
+	r.POST("/auth/validate-batch",
+		middleware.NewAdminAPIKeysMiddleware(cfg.AdminAPIKey, cfg.AdminAPIKeyPrevious, adminAudit),
+		authHandler.ValidateBatch,
+	)
+
+	// Prometheus /metrics：admin 模式才掛在主 port，且必須設定 admin key，否則不開放
+	if cfg.MetricsMode == metrics.ModeAdmin {
+		if cfg.AdminAPIKey == "" {
+			log.Printf("METRICS_MODE=admin requires ADMIN_API_KEY; /metrics is disabled")
+		} else {
+			r.GET("/metrics",
+				middleware.NewAdminAPIKeysMiddleware(cfg.AdminAPIKey, cfg.AdminAPIKeyPrevious, adminAudit),
+				gin.WrapH(metrics.Handler(prometheus.DefaultGatherer)),
+			)
+		}
 	}
 
 	// Admin routes（用簡單的 API key middleware 保護）
 	adminGroup := r.Group("/admin")
-	adminGroup.Use(middleware.NewAdminAPIKeyMiddleware(adminAPIKey))
+	adminGroup.Use(middleware.NewAdminAPIKeysMiddleware(cfg.AdminAPIKey, cfg.AdminAPIKeyPrevious, adminAudit))
 	{
+		adminGroup.POST("/users/force-reset", adminHandler.ForceResetPasswords)
+		adminGroup.POST("/users/:id/password-reset-token", adminHandler.IssuePasswordResetToken)
+		adminGroup.GET("/users/:id", adminHandler.GetUser)
 		adminGroup.GET("/users/:id/sessions", adminHandler.ListUserSessions)
+		adminGroup.POST("/users/:id/sessions/:sid/extend", adminHandler.ExtendSession)
 		adminGroup.POST("/users/:id/kick", adminHandler.KickUserSessions)
 		adminGroup.POST("/users/:id/ban", adminHandler.BanUser)
 		adminGroup.POST("/users/:id/unban", adminHandler.UnbanUser)
+		adminGroup.POST("/users/:id/shadow-ban", adminHandler.ShadowBanUser)
+		adminGroup.POST("/users/:id/unshadow-ban", adminHandler.UnshadowBanUser)
+		adminGroup.PUT("/users/:id/roles", adminHandler.SetUserRoles)
+		adminGroup.DELETE("/users/:id", adminHandler.DeleteUser)
+		adminGroup.POST("/users/:id/restore", adminHandler.RestoreUser)
+		adminGroup.POST("/users/:id/export", adminHandler.ExportUser)
+		adminGroup.GET("/users/:id/failed-logins", adminHandler.FailedLogins)
+		adminGroup.GET("/sessions", adminHandler.ListSessions)
+		adminGroup.GET("/stats/revocations", adminHandler.RevocationStats)
+		adminGroup.GET("/sessions/:sid", adminHandler.InspectSession)
+		adminGroup.POST("/sessions/epoch", adminHandler.BumpSessionEpoch)
+		adminGroup.POST("/sessions/purge", adminHandler.PurgeSessions)
+		adminGroup.POST("/flags/:name", adminHandler.SetFeatureFlag)
+		adminGroup.POST("/devices/:device_id/kick", adminHandler.KickDevice)
 	}
 
 	return r
 }
-
-
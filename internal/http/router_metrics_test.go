@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http" // 匯入 net/http，使用 HTTP 方法常數
+	"testing"  // 匯入 testing，提供單元測試框架
+
+	"github.com/prometheus/client_golang/prometheus" // 匯入 prometheus，建立獨立 registry
+	"github.com/stretchr/testify/require"            // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/metrics" // 匯入 metrics，建立 Prometheus 指標
+)
+
+// TestHTTPMetricsUseRouteTemplate 測試 HTTP 指標以路由樣板作為 route label：對不同 user id 的 ban 請求共用同一個 series，
+// 不存在的路徑一律記為 unmatched。
+func TestHTTPMetricsUseRouteTemplate(t *testing.T) {
+	env := newTestEnv(t)                                                                       // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin"                                                         // 設定 admin token
+	reg := prometheus.NewRegistry()                                                            // 使用獨立 registry，避免影響全域
+	prom := metrics.NewPrometheus(reg)                                                         // 建立並註冊指標
+	r := NewRouter(env.q, env.rdb, env.jwtMgr, env.sessSvc, env.cfg, nil, nil, nil, prom, nil) // 掛上 HTTP 指標
+
+	for _, name := range []string{"alice", "bob"} {
+		w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"`+name+`","password":"password123"}`) // 註冊
+		require.Equal(t, http.StatusOK, w.Code)                                                             // 應註冊成功
+	}
+	w := doAdmin(r, env, http.MethodPost, "/admin/users/1/ban", "") // ban 第一個使用者
+	require.Equal(t, http.StatusOK, w.Code)                         // 應成功
+	w = doAdmin(r, env, http.MethodPost, "/admin/users/2/ban", "")  // ban 第二個使用者
+	require.Equal(t, http.StatusOK, w.Code)                         // 應成功
+	doJSON(r, http.MethodGet, "/no/such/path/123", "")              // 不存在的路徑
+	doJSON(r, http.MethodGet, "/no/such/path/456", "")              // 另一個不存在的路徑
+
+	families, err := reg.Gather()  // 收集所有指標
+	require.NoError(t, err)        // 應收集成功
+	series := map[string]float64{} // route label -> 請求數
+	count := map[string]int{}      // route label -> series 數
+	for _, mf := range families {
+		if mf.GetName() != "http_requests_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "route" {
+					series[l.GetValue()] += m.GetCounter().GetValue() // 依 route 累加
+					count[l.GetValue()]++                             // 計算 series 數
+				}
+			}
+		}
+	}
+	require.Equal(t, 1, count["/admin/users/:id/ban"])    // 兩個 ban 請求共用同一個 series
+	require.Equal(t, 2.0, series["/admin/users/:id/ban"]) // 該 series 計數為 2
+	require.Equal(t, 2.0, series["unmatched"])            // 不存在的路徑合併成 unmatched
+	require.NotContains(t, series, "/admin/users/1/ban")  // 不會以實際路徑作為 label
+	require.NotContains(t, series, "/no/such/path/123")   // 不存在的路徑也不會成為 label
+}
@@ -0,0 +1,167 @@
+package http
+
+import (
+	"context"           // 匯入 context，撰寫假的相依檢查
+	"encoding/json"     // 匯入 encoding/json，解析錯誤回應
+	"errors"            // 匯入 errors，模擬相依服務故障
+	"net/http"          // 匯入 net/http，使用狀態碼常數
+	"net/http/httptest" // 匯入 httptest，建立帶 header 的測試請求
+	"strings"           // 匯入 strings，將 JSON body 包成 io.Reader
+	"testing"           // 匯入 testing，提供單元測試框架
+	"time"              // 匯入 time，設定 readiness 快取時間
+
+	"github.com/hibiken/asynq"            // 匯入 asynq，建立 client 與 inspector 檢查排入的任務
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/health"     // 匯入 health，建立 readiness checker
+	"sessionservice/internal/infra"      // 匯入 infra，解析 login:audit payload
+	"sessionservice/internal/middleware" // 匯入 middleware，取得 request ID header 名稱
+	"sessionservice/internal/session"    // 匯入 session，建立帶 asynq client 的 SessionService
+)
+
+// errorCode 從 {"error":{"code":...}} 格式的回應中取出 code。
+func errorCode(t *testing.T, body []byte) string {
+	t.Helper() // 標記為測試輔助函式
+	var resp struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(body, &resp)) // 回應必須是合法 JSON
+	return resp.Error.Code
+}
+
+// TestUnknownRouteReturnsJSON404 測試未定義的路徑回傳 JSON 404。
+func TestUnknownRouteReturnsJSON404(t *testing.T) {
+	env := newTestEnv(t)    // 建立測試環境
+	r := newTestRouter(env) // 建立完整 router
+
+	w := doJSON(r, http.MethodGet, "/no-such-path", "")                     // 打一個不存在的路徑
+	require.Equal(t, http.StatusNotFound, w.Code)                           // 應回 404
+	require.Contains(t, w.Header().Get("Content-Type"), "application/json") // 應為 JSON
+	require.Equal(t, "NOT_FOUND", errorCode(t, w.Body.Bytes()))             // code 應為 NOT_FOUND
+}
+
+// TestWrongMethodReturnsJSON405 測試既有路徑使用錯誤 method 時回傳 JSON 405。
+func TestWrongMethodReturnsJSON405(t *testing.T) {
+	env := newTestEnv(t)    // 建立測試環境
+	r := newTestRouter(env) // 建立完整 router
+
+	w := doJSON(r, http.MethodGet, "/auth/login", "")                       // /auth/login 只接受 POST
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)                   // 應回 405
+	require.Contains(t, w.Header().Get("Content-Type"), "application/json") // 應為 JSON
+	require.Equal(t, "METHOD_NOT_ALLOWED", errorCode(t, w.Body.Bytes()))    // code 應為 METHOD_NOT_ALLOWED
+}
+
+// TestReadyReportsPerDependency 測試 /ready 回報每個相依服務的狀態與延遲，任一失敗時回 503。
+func TestReadyReportsPerDependency(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+	readiness := health.NewChecker(time.Minute, map[string]health.Check{
+		"db":    func(context.Context) error { return nil },                              // DB 正常
+		"redis": func(context.Context) error { return errors.New("connection refused") }, // Redis 故障
+	})
+	r := NewRouter(env.q, env.rdb, env.jwtMgr, env.sessSvc, env.cfg, nil, readiness, nil, nil, nil) // 掛上 readiness checker
+
+	w := doJSON(r, http.MethodGet, "/ready", "")            // 呼叫 /ready
+	require.Equal(t, http.StatusServiceUnavailable, w.Code) // 任一相依失敗應回 503
+
+	var body struct {
+		Status string         `json:"status"`
+		DB     map[string]any `json:"db"`
+		Redis  map[string]any `json:"redis"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))   // 解析回應
+	require.Equal(t, "unavailable", body.Status)                // 整體狀態
+	require.Equal(t, true, body.DB["ok"])                       // DB 回報 ok:true
+	require.Contains(t, body.DB, "latency_ms")                  // 附上延遲
+	require.Equal(t, false, body.Redis["ok"])                   // Redis 回報 ok:false
+	require.Contains(t, body.Redis, "latency_ms")               // 失敗時也附上延遲
+	require.Equal(t, "connection refused", body.Redis["error"]) // 附上錯誤訊息
+}
+
+// TestMetricsAdminMode 測試 admin 模式下 /metrics 掛在主 port 且需要 admin key，listener 模式則不出現在主 port。
+func TestMetricsAdminMode(t *testing.T) {
+	env := newTestEnv(t)               // 建立測試環境
+	env.cfg.AdminAPIKey = "test-admin" // 設定 admin token
+	env.cfg.MetricsMode = "admin"      // 主 port 的 /metrics，需 admin key
+	r := newTestRouter(env)            // 建立完整 router
+
+	w := doJSON(r, http.MethodGet, "/metrics", "") // 未帶 admin key
+	require.Equal(t, http.StatusForbidden, w.Code) // 應回 403
+
+	w = doAdmin(r, env, http.MethodGet, "/metrics", "")   // 帶 admin key
+	require.Equal(t, http.StatusOK, w.Code)               // 應回 200
+	require.Contains(t, w.Body.String(), "go_goroutines") // 應為 Prometheus 文字格式
+
+	env.cfg.MetricsMode = "listener"                    // 改用獨立 listener
+	r = newTestRouter(env)                              // 重建 router
+	w = doAdmin(r, env, http.MethodGet, "/metrics", "") // 主 port 不提供 /metrics
+	require.Equal(t, http.StatusNotFound, w.Code)       // 應回 404
+}
+
+// TestRequestIDReachesLoginAuditTask 測試 middleware 設定的 request ID 會隨登入排入的 login:audit 任務交給 worker。
+func TestRequestIDReachesLoginAuditTask(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	opt := asynq.RedisClientOpt{Addr: env.mr.Addr()}                              // asynq 與 session 共用 miniredis
+	client := asynq.NewClient(opt)                                                // 建立 asynq client
+	defer client.Close()                                                          // 測試結束時關閉
+	inspector := asynq.NewInspector(opt)                                          // 建立 inspector 以檢查排入的任務
+	defer inspector.Close()                                                       // 測試結束時關閉
+	env.sessSvc = session.NewSessionService(env.q, env.rdb, env.cfg, client, nil) // 改用會排任務的 SessionService
+	r := newTestRouter(env)                                                       // 建立完整 router
+
+	w := doJSON(r, http.MethodPost, "/auth/signup", `{"username":"alice","password":"password123"}`) // 註冊 alice
+	require.Equal(t, http.StatusOK, w.Code)                                                          // 應註冊成功
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"username":"alice","password":"password123"}`)) // 建立登入請求
+	req.Header.Set("Content-Type", "application/json")                                                                             // 標記為 JSON body
+	req.Header.Set(middleware.RequestIDHeader, "trace-login-1")                                                                    // 上游帶入的 request ID
+	w = httptest.NewRecorder()                                                                                                     // 建立 ResponseRecorder
+	r.ServeHTTP(w, req)                                                                                                            // 執行請求
+	require.Equal(t, http.StatusOK, w.Code)                                                                                        // 應登入成功
+	require.Equal(t, "trace-login-1", w.Header().Get(middleware.RequestIDHeader))                                                  // 回應帶回同一個 ID
+
+	tasks, err := inspector.ListPendingTasks("default") // 列出待處理的任務
+	require.NoError(t, err)                             // 查詢應成功
+
+	found := false
+	for _, task := range tasks {
+		if task.Type != infra.TaskTypeLoginAudit {
+			continue // 只檢查 login:audit
+		}
+		var p infra.LoginAuditPayload
+		require.NoError(t, json.Unmarshal(task.Payload, &p)) // payload 應為合法 JSON
+		if p.Success {
+			require.Equal(t, "trace-login-1", p.RequestID) // 登入成功的稽核任務帶著 request ID
+			found = true
+		}
+	}
+	require.True(t, found) // 應排入登入成功的 login:audit
+}
+
+// TestSecurityHeaders 測試一般回應帶有設定的安全性 header，設為 off 的 header 不送出。
+func TestSecurityHeaders(t *testing.T) {
+	env := newTestEnv(t)                                             // 建立測試環境
+	env.cfg.HeaderContentTypeOptions = "nosniff"                     // 啟用 X-Content-Type-Options
+	env.cfg.HeaderFrameOptions = "DENY"                              // 啟用 X-Frame-Options
+	env.cfg.HeaderReferrerPolicy = "no-referrer"                     // 啟用 Referrer-Policy
+	env.cfg.HeaderHSTS = "off"                                       // 停用 HSTS
+	env.cfg.HeaderCSP = "default-src 'none'; frame-ancestors 'none'" // 啟用 CSP
+	r := newTestRouter(env)                                          // 建立完整 router
+
+	for _, path := range []string{"/health", "/no-such-route"} {
+		w := doJSON(r, http.MethodGet, path, "")                                                                  // 一般回應與 404 都要帶上
+		require.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))                                     // nosniff
+		require.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))                                               // 禁止 iframe
+		require.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))                                        // 不送 Referer
+		require.Equal(t, "default-src 'none'; frame-ancestors 'none'", w.Header().Get("Content-Security-Policy")) // CSP
+		require.Empty(t, w.Header().Values("Strict-Transport-Security"))                                          // off 的 header 不送出
+	}
+
+	env.cfg.HeaderHSTS = "max-age=31536000; includeSubDomains"                                           // 改為啟用 HSTS
+	env.cfg.HeaderFrameOptions = "SAMEORIGIN"                                                            // 覆寫 X-Frame-Options
+	w := doJSON(newTestRouter(env), http.MethodGet, "/health", "")                                       // 重建 router 後請求
+	require.Equal(t, "max-age=31536000; includeSubDomains", w.Header().Get("Strict-Transport-Security")) // HSTS 已送出
+	require.Equal(t, "SAMEORIGIN", w.Header().Get("X-Frame-Options"))                                    // 使用覆寫後的值
+}
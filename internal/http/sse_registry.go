@@ -0,0 +1,88 @@
+package http
+
+import (
+	"context"
+	"sync"
+)
+
+// SSERegistry 追蹤目前連線中的 SSE 串流。服務關閉時 Shutdown 通知每個串流送出 event: shutdown 後結束，
+// 讓 client 立即重連到其他 instance，而不是等 http.Server.Shutdown 逾時後被直接切斷。
+type SSERegistry struct {
+	mu      sync.Mutex
+	streams map[*SSEStream]struct{}
+	closing bool
+	wg      sync.WaitGroup
+}
+
+// SSEStream 是登記在 SSERegistry 的一個串流；handler 結束時必須呼叫 Close。
+type SSEStream struct {
+	registry *SSERegistry
+	shutdown chan struct{}
+	once     sync.Once
+}
+
+// NewSSERegistry 建立空的 SSERegistry。
+func NewSSERegistry() *SSERegistry {
+	return &SSERegistry{streams: make(map[*SSEStream]struct{})}
+}
+
+// Open 登記一個新串流；服務已開始關閉時回傳 false，handler 應直接回 503 讓 client 改連其他 instance。
+func (r *SSERegistry) Open() (*SSEStream, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closing {
+		return nil, false
+	}
+	s := &SSEStream{registry: r, shutdown: make(chan struct{})}
+	r.streams[s] = struct{}{}
+	r.wg.Add(1)
+	return s, true
+}
+
+// Active 回傳目前登記中的串流數。
+func (r *SSERegistry) Active() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.streams)
+}
+
+// Shutdown 拒絕新的串流，通知所有既有串流結束，並等待它們的 handler 返回。
+// ctx 逾時（SSE_SHUTDOWN_GRACE_SECONDS）時不再等待，剩下的連線交給 http.Server.Shutdown 處理。
+// 寫入 shutdown frame 由各 handler 自己完成，避免與 handler 同時寫同一個 ResponseWriter。
+func (r *SSERegistry) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closing {
+		r.closing = true
+		for s := range r.streams {
+			close(s.shutdown)
+		}
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ShuttingDown 在服務開始關閉時關閉，handler 收到後應送出 event: shutdown 並返回。
+func (s *SSEStream) ShuttingDown() <-chan struct{} {
+	return s.shutdown
+}
+
+// Close 取消登記；重複呼叫不會有作用。
+func (s *SSEStream) Close() {
+	s.once.Do(func() {
+		s.registry.mu.Lock()
+		delete(s.registry.streams, s)
+		s.registry.mu.Unlock()
+		s.registry.wg.Done()
+	})
+}
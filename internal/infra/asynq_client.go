@@ -3,23 +3,47 @@ package infra
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
 
 	"sessionservice/internal/config"
 )
 
 // 任務類型常數
 const (
-	TaskTypeSessionExpire = "session:expire"
-	TaskTypeLoginAudit    = "login:audit"
+	TaskTypeSessionExpire    = "session:expire"
+	TaskTypeLoginAudit       = "login:audit"
+	TaskTypeSessionRecord    = "session:record"
+	TaskTypeSessionReconcile = "session:reconcile"
+
+	TaskTypeAdminAuthFailureNotify = "notify:admin_auth_failure"
+
+	TaskTypeBanResync = "ban:resync"
+
+	TaskTypeCountersSweep = "counters:sweep"
+
+	TaskTypeEmailSend = "email:send"
 )
 
 // SessionExpirePayload 用於 session:expire 任務。
 type SessionExpirePayload struct {
 	SessionID string `json:"session_id"`
 	UserID    int64  `json:"user_id"`
+	RequestID string `json:"request_id,omitempty"` // 排入任務的 HTTP request ID，供 worker log 串接
+}
+
+// SessionRecordPayload 用於 session:record 任務：SESSION_RECORD_MODE=async 時由 worker 寫入 sessions 表。
+type SessionRecordPayload struct {
+	SessionID string    `json:"session_id"`
+	UserID    int64     `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RequestID string    `json:"request_id,omitempty"` // 排入任務的 HTTP request ID，供 worker log 串接
 }
 
 // LoginAuditPayload 用於 login:audit 任務。
@@ -30,15 +54,51 @@ type LoginAuditPayload struct {
 	Reason    string `json:"reason"`
 	IP        string `json:"ip"`
 	UserAgent string `json:"user_agent"`
+	Country   string `json:"country,omitempty"` // 由 GEO_COUNTRY_HEADER 取得的 ISO 3166-1 alpha-2 國碼，可為空
+
+	RequestID string `json:"request_id,omitempty"` // 排入任務的 HTTP request ID，供 worker log 串接
+
+	// EventID 在排入任務時產生，寫入 login_events.event_id；任務重試時靠唯一索引避免重複寫入
+	EventID string `json:"event_id,omitempty"`
+
+	// CreatedAt 為登入嘗試發生的時間，worker 以此更新 users.last_login_at
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AdminAuthFailurePayload 用於 notify:admin_auth_failure 任務。
+// 只帶來源與次數，不包含 client 送出的 admin key。
+type AdminAuthFailurePayload struct {
+	IP       string        `json:"ip"`
+	Route    string        `json:"route"`
+	Failures int64         `json:"failures"`
+	Window   time.Duration `json:"window"`
+
+	RequestID string `json:"request_id,omitempty"` // 排入任務的 HTTP request ID，供 worker log 串接
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EmailSendPayload 用於 email:send 任務。Body 可能含有登入連結，worker 不應把內容寫進 log。
+type EmailSendPayload struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+
+	RequestID string `json:"request_id,omitempty"` // 排入任務的 HTTP request ID，供 worker log 串接
+}
+
+// AsynqRedisOpt 回傳 Asynq 佇列使用的 Redis 連線設定（可與 session Redis 分開）。
+func AsynqRedisOpt(cfg *config.Config) asynq.RedisClientOpt {
+	return asynq.RedisClientOpt{
+		Addr:     cfg.AsynqRedisAddr,
+		Password: cfg.AsynqRedisPassword,
+		DB:       cfg.AsynqRedisDB,
+	}
 }
 
 // NewAsynqClient 根據 config 建立 Asynq client。
 func NewAsynqClient(cfg *config.Config) *asynq.Client {
-	return asynq.NewClient(asynq.RedisClientOpt{
-		Addr:     cfg.RedisAddr,
-		Password: cfg.RedisPassword,
-		DB:       0,
-	})
+	return asynq.NewClient(AsynqRedisOpt(cfg))
 }
 
 // EnqueueSessionExpire 在指定時間執行 session:expire 任務。
@@ -55,17 +115,69 @@ func EnqueueSessionExpire(
 	payload := SessionExpirePayload{
 		SessionID: sessionID,
 		UserID:    userID,
+		RequestID: RequestIDFromContext(ctx),
 	}
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
 	task := asynq.NewTask(TaskTypeSessionExpire, data)
-	_, err = client.EnqueueContext(ctx, task, asynq.ProcessAt(processAt))
+	_, err = client.EnqueueContext(ctx, task,
+		asynq.ProcessAt(processAt),
+		asynq.TaskID(SessionExpireTaskID(sessionID, processAt)),
+	)
+	if errors.Is(err, asynq.ErrTaskIDConflict) || errors.Is(err, asynq.ErrDuplicateTask) {
+		// 同一個 session 在同一個到期時間已經排過任務，重複排入視為成功
+		return nil
+	}
 	return err
 }
 
-// EnqueueLoginAudit 立即送出 login:audit 任務。
+// SessionExpireTaskID 回傳 session:expire 任務的 asynq TaskID。
+// 以 session ID 加上到期時間組成：同一個 session 重複排入會被 asynq 擋下，
+// 但 admin 延長 session 後仍可為新的到期時間排一個任務。
+func SessionExpireTaskID(sessionID string, processAt time.Time) string {
+	return fmt.Sprintf("%s:%s:%d", TaskTypeSessionExpire, sessionID, processAt.Unix())
+}
+
+// SessExpireTaskAtField 是 sess:{sessionID} 中記錄已排定 session:expire 任務時間（Unix 秒）的欄位。
+// session 延長時若已有不晚於新到期時間的任務，就不再另外排入，由該任務觸發時改排到新的到期時間。
+const SessExpireTaskAtField = "expire_task_at"
+
+// recordSessionExpireTaskScript 只在 session hash 仍存在時寫入欄位，避免 session 剛被刪除時重建一個沒有 TTL 的 hash。
+var recordSessionExpireTaskScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+  redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+end
+return 0
+`)
+
+// RecordSessionExpireTask 在 sess:{sessionID} 記錄已排定的 session:expire 任務時間；session 已不存在時不做任何事。
+func RecordSessionExpireTask(ctx context.Context, rdb *redis.Client, sessionID string, at time.Time) error {
+	return recordSessionExpireTaskScript.Run(ctx, rdb, []string{SessKey(sessionID)}, SessExpireTaskAtField, at.Unix()).Err()
+}
+
+// EnqueueSessionRecord 立即送出 session:record 任務；以 session ID 作為 TaskID，同一個 session 重複排入視為成功。
+func EnqueueSessionRecord(ctx context.Context, client *asynq.Client, payload SessionRecordPayload) error {
+	if client == nil {
+		return nil
+	}
+	if payload.RequestID == "" {
+		payload.RequestID = RequestIDFromContext(ctx)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	task := asynq.NewTask(TaskTypeSessionRecord, data)
+	_, err = client.EnqueueContext(ctx, task, asynq.TaskID(TaskTypeSessionRecord+":"+payload.SessionID))
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
+	return err
+}
+
+// EnqueueLoginAudit 立即送出 login:audit 任務；payload 沒有 RequestID 時取自 ctx，沒有 EventID 時產生一個新的。
 func EnqueueLoginAudit(
 	ctx context.Context,
 	client *asynq.Client,
@@ -74,6 +186,12 @@ func EnqueueLoginAudit(
 	if client == nil {
 		return nil
 	}
+	if payload.RequestID == "" {
+		payload.RequestID = RequestIDFromContext(ctx)
+	}
+	if payload.EventID == "" {
+		payload.EventID = uuid.NewString()
+	}
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return err
@@ -83,4 +201,72 @@ func EnqueueLoginAudit(
 	return err
 }
 
+// EnqueueAdminAuthFailureNotify 立即送出 notify:admin_auth_failure 任務；payload 沒有 RequestID 時取自 ctx。
+func EnqueueAdminAuthFailureNotify(
+	ctx context.Context,
+	client *asynq.Client,
+	payload AdminAuthFailurePayload,
+) error {
+	if client == nil {
+		return nil
+	}
+	if payload.RequestID == "" {
+		payload.RequestID = RequestIDFromContext(ctx)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	task := asynq.NewTask(TaskTypeAdminAuthFailureNotify, data)
+	_, err = client.EnqueueContext(ctx, task)
+	return err
+}
+
+// EnqueueEmailSend 立即送出 email:send 任務；payload 沒有 RequestID 時取自 ctx。
+func EnqueueEmailSend(
+	ctx context.Context,
+	client *asynq.Client,
+	payload EmailSendPayload,
+) error {
+	if client == nil {
+		return nil
+	}
+	if payload.RequestID == "" {
+		payload.RequestID = RequestIDFromContext(ctx)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	task := asynq.NewTask(TaskTypeEmailSend, data)
+	_, err = client.EnqueueContext(ctx, task)
+	return err
+}
 
+// NewBanResyncTask 建立 ban:resync 任務（沒有 payload），供啟動時排入與 asynq.Scheduler 定期排入共用。
+// 以 asynq.Unique 避免多個 worker 同時啟動時重複排入。
+func NewBanResyncTask(unique time.Duration) *asynq.Task {
+	return asynq.NewTask(TaskTypeBanResync, nil, asynq.Unique(unique))
+}
+
+// EnqueueBanResync 立即送出 ban:resync 任務；unique 期間內已排過時視為成功。
+func EnqueueBanResync(ctx context.Context, client *asynq.Client, unique time.Duration) error {
+	if client == nil {
+		return nil
+	}
+	_, err := client.EnqueueContext(ctx, NewBanResyncTask(unique))
+	if errors.Is(err, asynq.ErrDuplicateTask) {
+		return nil
+	}
+	return err
+}
+
+// NewCountersSweepTask 建立 counters:sweep 任務（沒有 payload），由 asynq.Scheduler 定期排入。
+func NewCountersSweepTask(unique time.Duration) *asynq.Task {
+	return asynq.NewTask(TaskTypeCountersSweep, nil, asynq.Unique(unique))
+}
+
+// NewSessionReconcileTask 建立 session:reconcile 任務（沒有 payload），由 asynq.Scheduler 定期排入。
+func NewSessionReconcileTask(unique time.Duration) *asynq.Task {
+	return asynq.NewTask(TaskTypeSessionReconcile, nil, asynq.Unique(unique))
+}
@@ -0,0 +1,34 @@
+package infra
+
+import (
+	"context" // 匯入 context，傳給 EnqueueSessionExpire
+	"testing" // 匯入 testing 套件，提供單元測試支援
+	"time"    // 匯入 time，設定任務執行時間
+
+	"github.com/alicebob/miniredis/v2"    // 匯入 miniredis，提供記憶體內 Redis 給 asynq 使用
+	"github.com/hibiken/asynq"            // 匯入 asynq，建立 client 與 inspector
+	"github.com/stretchr/testify/require" // 匯入 testify/require，用於簡潔撰寫斷言
+)
+
+// TestEnqueueSessionExpireDeduplicates 測試同一個 session 重複排入 session:expire 時只會留下一個排程任務。
+func TestEnqueueSessionExpireDeduplicates(t *testing.T) {
+	mr, err := miniredis.Run() // 啟動記憶體內 Redis
+	require.NoError(t, err)    // 確保啟動成功
+	defer mr.Close()           // 測試結束時關閉
+
+	opt := asynq.RedisClientOpt{Addr: mr.Addr()} // asynq 連線設定
+	client := asynq.NewClient(opt)               // 建立 asynq client
+	defer client.Close()                         // 測試結束時關閉
+	inspector := asynq.NewInspector(opt)         // 建立 inspector 以檢查排程中的任務
+	defer inspector.Close()                      // 測試結束時關閉
+
+	ctx := context.Background()                                           // 背景 context
+	at := time.Now().Add(time.Hour)                                       // 一小時後到期
+	require.NoError(t, EnqueueSessionExpire(ctx, client, "sid-1", 1, at)) // 第一次排入
+	require.NoError(t, EnqueueSessionExpire(ctx, client, "sid-1", 1, at)) // 重複排入不應回傳錯誤
+
+	tasks, err := inspector.ListScheduledTasks("default")           // 列出排程中的任務
+	require.NoError(t, err)                                         // 查詢應成功
+	require.Len(t, tasks, 1)                                        // 只應有一個任務
+	require.Equal(t, SessionExpireTaskID("sid-1", at), tasks[0].ID) // TaskID 應由 session ID 組成
+}
@@ -0,0 +1,75 @@
+package infra
+
+import (
+	"log"
+
+	"github.com/hibiken/asynq"
+
+	"sessionservice/internal/config"
+)
+
+// AsynqServerConfig 回傳 worker 的 asynq.Config。
+// 收到關機訊號後 asynq 立即停止拉新任務，並最多等待 WorkerShutdownTimeout 讓進行中的任務完成；
+// 逾時仍未完成的任務會交回佇列，由下一個 worker 重試。
+func AsynqServerConfig(cfg *config.Config) asynq.Config {
+	return asynq.Config{
+		Concurrency:     cfg.AsynqConcurrency,
+		ShutdownTimeout: cfg.WorkerShutdownTimeout,
+	}
+}
+
+// QueueDepth 是單一佇列在某個時間點的任務數量。
+type QueueDepth struct {
+	Queue     string
+	Active    int // 正在被 worker 處理（同一個佇列的所有 worker 合計）
+	Pending   int // 等待被拉取
+	Scheduled int // 排程中，時間到才會進入 pending
+	Retry     int // 等待重試
+}
+
+// QueueInspector 是 InspectQueueDepth 需要的 *asynq.Inspector 方法。
+type QueueInspector interface {
+	Queues() ([]string, error)
+	GetQueueInfo(queue string) (*asynq.QueueInfo, error)
+}
+
+// InspectQueueDepth 以 inspector 讀出所有佇列目前的任務數量。
+func InspectQueueDepth(inspector QueueInspector) ([]QueueDepth, error) {
+	queues, err := inspector.Queues()
+	if err != nil {
+		return nil, err
+	}
+	depths := make([]QueueDepth, 0, len(queues))
+	for _, q := range queues {
+		info, err := inspector.GetQueueInfo(q)
+		if err != nil {
+			return nil, err
+		}
+		depths = append(depths, QueueDepth{
+			Queue:     q,
+			Active:    info.Active,
+			Pending:   info.Pending,
+			Scheduled: info.Scheduled,
+			Retry:     info.Retry,
+		})
+	}
+	return depths, nil
+}
+
+// LogQueueDepth 將各佇列的任務數量寫入 log，stage 說明記錄的時機（例如 "draining"、"drained"）。
+// 讀取失敗只記錄錯誤，不影響關機流程。
+func LogQueueDepth(inspector QueueInspector, stage string) {
+	depths, err := InspectQueueDepth(inspector)
+	if err != nil {
+		log.Printf("worker %s: failed to inspect queues: %v", stage, err)
+		return
+	}
+	if len(depths) == 0 {
+		log.Printf("worker %s: no queues", stage)
+		return
+	}
+	for _, d := range depths {
+		log.Printf("worker %s: queue=%s active=%d pending=%d scheduled=%d retry=%d",
+			stage, d.Queue, d.Active, d.Pending, d.Scheduled, d.Retry)
+	}
+}
@@ -0,0 +1,80 @@
+package infra
+
+import (
+	"bytes"   // 匯入 bytes，攔截 log 輸出
+	"errors"  // 匯入 errors，模擬 inspector 查詢失敗
+	"log"     // 匯入 log，將輸出導向 buffer
+	"os"      // 匯入 os，測試結束後還原 log 輸出
+	"testing" // 匯入 testing 套件，提供單元測試支援
+	"time"    // 匯入 time，設定關機等待時間
+
+	"github.com/hibiken/asynq"            // 匯入 asynq，建立 QueueInfo
+	"github.com/stretchr/testify/require" // 匯入 testify/require，用於簡潔撰寫斷言
+
+	"sessionservice/internal/config" // 匯入 config 套件，建立測試用設定
+)
+
+// fakeQueueInspector 回傳固定的佇列資訊（miniredis 不支援 asynq 查詢佇列資訊時用到的 MEMORY USAGE）。
+type fakeQueueInspector struct {
+	infos map[string]*asynq.QueueInfo // 各佇列的資訊
+	err   error                       // 不為 nil 時 Queues 回傳此錯誤
+}
+
+func (f *fakeQueueInspector) Queues() ([]string, error) {
+	if f.err != nil {
+		return nil, f.err // 模擬查詢失敗
+	}
+	var queues []string
+	for q := range f.infos {
+		queues = append(queues, q) // 收集佇列名稱
+	}
+	return queues, nil
+}
+
+func (f *fakeQueueInspector) GetQueueInfo(queue string) (*asynq.QueueInfo, error) {
+	return f.infos[queue], nil // 回傳固定資訊
+}
+
+// captureLog 將 log 輸出導向 buffer，測試結束後還原。
+func captureLog(t *testing.T) *bytes.Buffer {
+	var logs bytes.Buffer                          // 攔截 log 輸出
+	log.SetOutput(&logs)                           // 將 log 導向 buffer
+	t.Cleanup(func() { log.SetOutput(os.Stderr) }) // 測試結束後還原
+	return &logs
+}
+
+// TestAsynqServerConfig 測試 worker 的併發數與關機等待時間來自設定。
+func TestAsynqServerConfig(t *testing.T) {
+	cfg := &config.Config{AsynqConcurrency: 4, WorkerShutdownTimeout: 45 * time.Second} // 測試用設定
+
+	asynqCfg := AsynqServerConfig(cfg) // 轉成 asynq 設定
+
+	require.Equal(t, 4, asynqCfg.Concurrency)                  // 併發數
+	require.Equal(t, 45*time.Second, asynqCfg.ShutdownTimeout) // 關機時等待進行中任務的上限
+}
+
+// TestLogQueueDepth 測試關機時會記錄各佇列進行中與尚未處理的任務數量。
+func TestLogQueueDepth(t *testing.T) {
+	logs := captureLog(t) // 攔截 log 輸出
+	inspector := &fakeQueueInspector{infos: map[string]*asynq.QueueInfo{
+		"default": {Queue: "default", Active: 3, Pending: 7, Scheduled: 2, Retry: 1}, // 模擬佇列狀態
+	}}
+
+	depths, err := InspectQueueDepth(inspector)                                                               // 讀取佇列深度
+	require.NoError(t, err)                                                                                   // 查詢應成功
+	require.Equal(t, []QueueDepth{{Queue: "default", Active: 3, Pending: 7, Scheduled: 2, Retry: 1}}, depths) // 各狀態的數量
+
+	LogQueueDepth(inspector, "draining")                                                                        // 記錄佇列深度
+	require.Contains(t, logs.String(), "worker draining: queue=default active=3 pending=7 scheduled=2 retry=1") // 應記錄各狀態的數量
+}
+
+// TestLogQueueDepthEmptyAndError 測試沒有佇列或查詢失敗時只記錄 log，不中斷關機流程。
+func TestLogQueueDepthEmptyAndError(t *testing.T) {
+	logs := captureLog(t) // 攔截 log 輸出
+
+	LogQueueDepth(&fakeQueueInspector{}, "draining")                 // 沒有任何佇列
+	require.Contains(t, logs.String(), "worker draining: no queues") // 應記錄沒有佇列
+
+	LogQueueDepth(&fakeQueueInspector{err: errors.New("redis down")}, "drained")               // 查詢失敗
+	require.Contains(t, logs.String(), "worker drained: failed to inspect queues: redis down") // 應記錄錯誤
+}
@@ -0,0 +1,32 @@
+package infra
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"sessionservice/internal/config"
+)
+
+// tlsVersions 對應 OUTBOUND_TLS_MIN_VERSION 可用的值。
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// HTTPClient 建立對外呼叫（CAPTCHA 驗證等）共用的 http.Client：最低 TLS 版本依 OUTBOUND_TLS_MIN_VERSION（預設 1.2），
+// 整體逾時依 OUTBOUND_HTTP_TIMEOUT_MS，對同一主機的連線數以 OUTBOUND_HTTP_MAX_CONNS_PER_HOST 為上限，避免供應商變慢時連線無限堆積。
+// 其餘 transport 設定（proxy、dial 與 TLS handshake 逾時）沿用 http.DefaultTransport。
+func HTTPClient(cfg *config.Config) *http.Client {
+	minVersion, ok := tlsVersions[cfg.OutboundTLSMinVersion]
+	if !ok {
+		minVersion = tls.VersionTLS12
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{MinVersion: minVersion}
+	transport.MaxConnsPerHost = cfg.OutboundMaxConnsPerHost
+	transport.MaxIdleConnsPerHost = cfg.OutboundMaxConnsPerHost
+	transport.ResponseHeaderTimeout = cfg.OutboundHTTPTimeout
+
+	return &http.Client{Transport: transport, Timeout: cfg.OutboundHTTPTimeout}
+}
@@ -0,0 +1,77 @@
+package infra
+
+import (
+	"crypto/tls"        // 匯入 crypto/tls，指定測試 server 的 TLS 版本
+	"net/http"          // 匯入 net/http，撰寫測試 handler
+	"net/http/httptest" // 匯入 httptest，啟動測試用 HTTPS server
+	"testing"           // 匯入 testing 套件，提供單元測試支援
+	"time"              // 匯入 time，設定逾時
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，用於簡潔撰寫斷言
+
+	"sessionservice/internal/config" // 匯入 config 套件，建立測試用設定
+)
+
+// newTLSTestServer 啟動只支援 TLS 1.2 的 HTTPS server，handler 先等待 delay 再回應。
+func newTLSTestServer(t *testing.T, delay time.Duration) *httptest.Server {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)            // 模擬供應商回應變慢
+		w.WriteHeader(http.StatusOK) // 回應 200
+	}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12} // 最高只支援 TLS 1.2
+	srv.StartTLS()                                      // 啟動 HTTPS server
+	t.Cleanup(srv.Close)                                // 測試結束後關閉
+	return srv
+}
+
+// trustServer 讓 client 信任測試 server 的自簽憑證，其餘 TLS 設定不變。
+func trustServer(client *http.Client, srv *httptest.Server) {
+	transport := client.Transport.(*http.Transport)                                                      // 取出 HTTPClient 建立的 transport
+	transport.TLSClientConfig.RootCAs = srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs // 沿用 httptest 的憑證池
+}
+
+// TestHTTPClientConfig 測試 HTTPClient 依設定套用最低 TLS 版本、逾時與連線數上限。
+func TestHTTPClientConfig(t *testing.T) {
+	cfg := &config.Config{OutboundTLSMinVersion: "1.3", OutboundHTTPTimeout: 2 * time.Second, OutboundMaxConnsPerHost: 4} // 測試用設定
+	client := HTTPClient(cfg)                                                                                             // 建立 client
+
+	transport, ok := client.Transport.(*http.Transport)                              // 取出 transport
+	require.True(t, ok)                                                              // 應為 *http.Transport
+	require.Equal(t, uint16(tls.VersionTLS13), transport.TLSClientConfig.MinVersion) // 最低 TLS 1.3
+	require.Equal(t, 4, transport.MaxConnsPerHost)                                   // 連線數上限為 4
+	require.Equal(t, 2*time.Second, client.Timeout)                                  // 整體逾時為 2 秒
+
+	cfg.OutboundTLSMinVersion = ""                                                   // 未設定版本
+	transport = HTTPClient(cfg).Transport.(*http.Transport)                          // 重新建立
+	require.Equal(t, uint16(tls.VersionTLS12), transport.TLSClientConfig.MinVersion) // 預設最低 TLS 1.2
+}
+
+// TestHTTPClientRejectsOldTLS 測試 server 只支援低於最低版本的 TLS 時 handshake 失敗，版本足夠時則正常連線。
+func TestHTTPClientRejectsOldTLS(t *testing.T) {
+	srv := newTLSTestServer(t, 0) // 只支援 TLS 1.2 的 server
+
+	strict := HTTPClient(&config.Config{OutboundTLSMinVersion: "1.3", OutboundHTTPTimeout: 2 * time.Second, OutboundMaxConnsPerHost: 4}) // 要求 TLS 1.3
+	trustServer(strict, srv)                                                                                                             // 信任測試憑證
+	_, err := strict.Get(srv.URL)                                                                                                        // 發出請求
+	require.Error(t, err)                                                                                                                // handshake 應失敗
+
+	lenient := HTTPClient(&config.Config{OutboundTLSMinVersion: "1.2", OutboundHTTPTimeout: 2 * time.Second, OutboundMaxConnsPerHost: 4}) // 接受 TLS 1.2
+	trustServer(lenient, srv)                                                                                                             // 信任測試憑證
+	resp, err := lenient.Get(srv.URL)                                                                                                     // 發出請求
+	require.NoError(t, err)                                                                                                               // 應連線成功
+	defer resp.Body.Close()                                                                                                               // 關閉回應
+	require.Equal(t, http.StatusOK, resp.StatusCode)                                                                                      // 應回 200
+}
+
+// TestHTTPClientTimeout 測試對方回應超過 OUTBOUND_HTTP_TIMEOUT_MS 時請求以逾時失敗。
+func TestHTTPClientTimeout(t *testing.T) {
+	srv := newTLSTestServer(t, 300*time.Millisecond) // 回應前等待 300ms
+
+	client := HTTPClient(&config.Config{OutboundTLSMinVersion: "1.2", OutboundHTTPTimeout: 50 * time.Millisecond, OutboundMaxConnsPerHost: 4}) // 逾時 50ms
+	trustServer(client, srv)                                                                                                                   // 信任測試憑證
+
+	start := time.Now()                                      // 記錄開始時間
+	_, err := client.Get(srv.URL)                            // 發出請求
+	require.Error(t, err)                                    // 應逾時失敗
+	require.Less(t, time.Since(start), 300*time.Millisecond) // 應在 server 回應前就放棄
+}
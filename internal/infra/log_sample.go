@@ -0,0 +1,97 @@
+package infra
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// maxLogSampleKeys 是 LogSampler 同時追蹤的不同訊息數上限，避免訊息內含 IP 等變動內容時 map 無限成長。
+const maxLogSampleKeys = 1024
+
+// LogSampler 對內容完全相同的錯誤 log 取樣：同一訊息第一次出現時照常輸出，之後 interval 內重複的只計數不輸出，
+// 超過 interval 後再出現時輸出一次並附上期間略過的次數。Redis 中斷時每個請求都會產生相同錯誤，取樣避免灌爆 log 收集端。
+// interval <= 0 代表不取樣，每次都輸出。
+type LogSampler struct {
+	mu       sync.Mutex
+	interval time.Duration
+	seen     map[string]*logSample
+	logf     func(format string, args ...any)
+}
+
+type logSample struct {
+	last       time.Time // 最近一次實際輸出的時間
+	suppressed int       // 上次輸出後略過的次數
+}
+
+// NewLogSampler 建立 LogSampler，輸出到標準 log。
+func NewLogSampler(interval time.Duration) *LogSampler {
+	return &LogSampler{interval: interval, seen: make(map[string]*logSample), logf: log.Printf}
+}
+
+// SetInterval 調整取樣間隔，啟動時依設定呼叫。
+func (l *LogSampler) SetInterval(interval time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.interval = interval
+}
+
+// Printf 依格式組出訊息，依取樣規則決定是否輸出。
+func (l *LogSampler) Printf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	now := time.Now()
+
+	l.mu.Lock()
+	if l.interval <= 0 {
+		l.mu.Unlock()
+		l.logf("%s", msg)
+		return
+	}
+	suppressed := 0
+	if s, ok := l.seen[msg]; ok {
+		if now.Sub(s.last) < l.interval {
+			s.suppressed++
+			l.mu.Unlock()
+			return
+		}
+		suppressed = s.suppressed
+		s.last, s.suppressed = now, 0
+	} else {
+		if len(l.seen) >= maxLogSampleKeys {
+			l.pruneLocked(now)
+		}
+		if len(l.seen) < maxLogSampleKeys {
+			l.seen[msg] = &logSample{last: now}
+		}
+	}
+	l.mu.Unlock()
+
+	if suppressed > 0 {
+		l.logf("%s (%d identical messages suppressed)", msg, suppressed)
+		return
+	}
+	l.logf("%s", msg)
+}
+
+// pruneLocked 移除已超過 interval 的訊息；呼叫端需持有 l.mu。
+func (l *LogSampler) pruneLocked(now time.Time) {
+	for msg, s := range l.seen {
+		if now.Sub(s.last) >= l.interval {
+			delete(l.seen, msg)
+		}
+	}
+}
+
+// errorLog 是 SessionService 與 middleware 共用的錯誤 log，啟動時以 SetErrorLogSampling 設定取樣間隔。
+var errorLog = NewLogSampler(0)
+
+// SetErrorLogSampling 設定 LogError 的取樣間隔（LOG_SAMPLE_INTERVAL_SECONDS），0 代表每次都輸出。
+func SetErrorLogSampling(interval time.Duration) {
+	errorLog.SetInterval(interval)
+}
+
+// LogError 以取樣方式記錄錯誤，用在每個請求都可能重複發生的錯誤（Redis 中斷、背景任務排入失敗）。
+func LogError(format string, args ...any) {
+	errorLog.Printf(format, args...)
+}
@@ -0,0 +1,39 @@
+package infra
+
+import (
+	"fmt"     // 匯入 fmt，組出實際輸出的 log
+	"testing" // 匯入 testing 套件，提供單元測試支援
+	"time"    // 匯入 time，設定取樣間隔
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，用於簡潔撰寫斷言
+)
+
+// TestLogSampler 測試 interval 內重複相同的錯誤只輸出一次，不同訊息各自輸出；
+// 超過 interval 後再出現時輸出一行並附上略過的次數，interval 為 0 時每次都輸出。
+func TestLogSampler(t *testing.T) {
+	var lines []string                        // 收集實際輸出的 log
+	l := NewLogSampler(50 * time.Millisecond) // 取樣間隔 50ms
+	l.logf = func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...)) // 記錄輸出
+	}
+
+	for i := 0; i < 1000; i++ {
+		l.Printf("redis error: %v", "connection refused") // Redis 中斷時每個請求都失敗
+	}
+	l.Printf("redis error: %v", "i/o timeout") // 不同的錯誤
+	require.Equal(t, []string{
+		"redis error: connection refused", // 第一次照常輸出
+		"redis error: i/o timeout",        // 不同訊息各自輸出
+	}, lines) // 1001 次錯誤只輸出兩行
+
+	time.Sleep(60 * time.Millisecond)                                                                 // 超過取樣間隔
+	l.Printf("redis error: %v", "connection refused")                                                 // 同一錯誤再次出現
+	require.Len(t, lines, 3)                                                                          // 只多一行
+	require.Equal(t, "redis error: connection refused (999 identical messages suppressed)", lines[2]) // 附上略過的次數
+
+	l.SetInterval(0) // 關閉取樣
+	for i := 0; i < 3; i++ {
+		l.Printf("redis error: %v", "connection refused") // 重複錯誤
+	}
+	require.Len(t, lines, 6) // 每次都輸出
+}
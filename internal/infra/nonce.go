@@ -0,0 +1,14 @@
+package infra
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ConsumeNonce 以 SETNX 標記 nonce 已使用，ttl 內同一個 nonce 只能成功一次。
+// 回傳 true 代表第一次使用；false 代表重放。ttl 應涵蓋簽章時間戳允許的整個有效期間。
+func ConsumeNonce(ctx context.Context, rdb *redis.Client, nonce string, ttl time.Duration) (bool, error) {
+	return rdb.SetNX(ctx, NonceKey(nonce), "1", ttl).Result()
+}
@@ -0,0 +1,35 @@
+package infra
+
+import (
+	"context" // 匯入 context，傳給 Redis 操作
+	"testing" // 匯入 testing 套件，提供單元測試支援
+	"time"    // 匯入 time，設定 nonce 有效期間
+
+	"github.com/alicebob/miniredis/v2"    // 匯入 miniredis，提供記憶體內 Redis
+	"github.com/redis/go-redis/v9"        // 匯入 go-redis，連線到 miniredis
+	"github.com/stretchr/testify/require" // 匯入 testify/require，用於簡潔撰寫斷言
+)
+
+// TestConsumeNonce 測試 nonce 第一次可用、重放被拒，過期後可再次使用。
+func TestConsumeNonce(t *testing.T) {
+	mr, err := miniredis.Run() // 啟動記憶體內 Redis
+	require.NoError(t, err)    // 確保啟動成功
+	defer mr.Close()           // 測試結束時關閉
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()}) // 連線到 miniredis
+	defer rdb.Close()                                       // 測試結束時關閉
+	ctx := context.Background()                             // 背景 context
+
+	ok, err := ConsumeNonce(ctx, rdb, "n-1", time.Minute) // 第一次使用
+	require.NoError(t, err)                               // 不應失敗
+	require.True(t, ok)                                   // 應成功
+
+	ok, err = ConsumeNonce(ctx, rdb, "n-1", time.Minute) // 重放同一個 nonce
+	require.NoError(t, err)                              // 不應失敗
+	require.False(t, ok)                                 // 應被拒絕
+
+	mr.FastForward(time.Minute + time.Second)            // 讓 nonce 過期
+	ok, err = ConsumeNonce(ctx, rdb, "n-1", time.Minute) // 過期後再次使用
+	require.NoError(t, err)                              // 不應失敗
+	require.True(t, ok)                                  // 標記已過期，應可再次使用
+}
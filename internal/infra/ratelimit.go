@@ -0,0 +1,38 @@
+package infra
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// allowRateScript 在同一個指令內 INCR 並於視窗第一次請求時設定 TTL，回傳 {count, pttl}。
+// INCR 與 EXPIRE 分開送出時，中間若連線中斷或程序結束，計數器會永遠沒有 TTL，該 key 的請求從此全被擋下。
+var allowRateScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {count, redis.call('PTTL', KEYS[1])}
+`)
+
+// AllowRate 以 Redis 固定視窗計數實作簡易 rate limit。
+// 回傳此次請求是否仍在 limit 之內，以及目前視窗剩餘的時間（供 Retry-After 使用）。
+func AllowRate(ctx context.Context, rdb *redis.Client, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	res, err := allowRateScript.Run(ctx, rdb, []string{key}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	count, pttl := res[0], res[1]
+
+	if count <= int64(limit) {
+		return true, 0, nil
+	}
+
+	ttl := time.Duration(pttl) * time.Millisecond
+	if ttl < 0 {
+		ttl = window
+	}
+	return false, ttl, nil
+}
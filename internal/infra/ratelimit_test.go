@@ -0,0 +1,38 @@
+package infra
+
+import (
+	"context" // 匯入 context，傳給 Redis 操作
+	"testing" // 匯入 testing 套件，提供單元測試支援
+	"time"    // 匯入 time，設定視窗長度
+
+	"github.com/alicebob/miniredis/v2"    // 匯入 miniredis，提供記憶體內 Redis
+	"github.com/redis/go-redis/v9"        // 匯入 go-redis，連線到 miniredis
+	"github.com/stretchr/testify/require" // 匯入 testify/require，用於簡潔撰寫斷言
+)
+
+// TestAllowRate 測試計數器在第一次請求時就帶有 TTL，超過上限回傳剩餘時間，視窗過後重置。
+func TestAllowRate(t *testing.T) {
+	mr, err := miniredis.Run() // 啟動記憶體內 Redis
+	require.NoError(t, err)    // 確保啟動成功
+	defer mr.Close()           // 測試結束時關閉
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()}) // 連線到 miniredis
+	defer rdb.Close()                                       // 測試結束時關閉
+	ctx := context.Background()                             // 背景 context
+	key := RateLimitKey("test", "127.0.0.1")                // 測試用 key
+
+	ok, _, err := AllowRate(ctx, rdb, key, 1, time.Minute) // 第一次請求
+	require.NoError(t, err)                                // 不應失敗
+	require.True(t, ok)                                    // 應放行
+	require.Equal(t, time.Minute, mr.TTL(key))             // 計數器建立時即帶 TTL
+
+	ok, retryAfter, err := AllowRate(ctx, rdb, key, 1, time.Minute) // 第二次請求
+	require.NoError(t, err)                                         // 不應失敗
+	require.False(t, ok)                                            // 超過上限
+	require.Equal(t, time.Minute, retryAfter)                       // 回傳視窗剩餘時間
+
+	mr.FastForward(time.Minute + time.Second)             // 視窗結束
+	ok, _, err = AllowRate(ctx, rdb, key, 1, time.Minute) // 視窗過後再次請求
+	require.NoError(t, err)                               // 不應失敗
+	require.True(t, ok)                                   // 計數已重置
+}
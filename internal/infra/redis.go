@@ -2,6 +2,8 @@ package infra
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/redis/go-redis/v9"
 
@@ -13,26 +15,149 @@ func NewRedisClient(cfg *config.Config) *redis.Client {
 	return redis.NewClient(&redis.Options{
 		Addr:     cfg.RedisAddr,
 		Password: cfg.RedisPassword,
-		DB:       0,
+		DB:       cfg.RedisDB,
 	})
 }
 
 // Redis key 命名規則：
-// sess:{sessionID}   -> Hash: user_id, created_at, expires_at, ip, user_agent
+// sess:{sessionID}   -> Hash: user_id, created_at, expires_at, ip, user_agent, expire_task_at（已排程的 session:expire 執行時間）
 // user_sess:{userID} -> Sorted Set: member=sessionID, score=created_at unix
 // banned_user:{userID} -> String flag，存在即代表被 ban
+// shadow_banned:{userID} -> String flag，存在即代表被 shadow ban（仍可登入）
+// ratelimit:{scope}:{id} -> String counter，固定視窗計數，TTL 即視窗長度
+// nonce:{nonce} -> String flag，signed login 使用過的 nonce，TTL 即有效期間
+// evict_reason:{sessionID} -> String，session 被系統踢掉的原因（例如 max_sessions），短暫保留供 client 查詢
+// session_epoch -> String integer，目前的 session epoch，ID 內嵌 epoch 較舊的 session 一律無效
+// device_sess:{deviceID} -> Sorted Set: member=sessionID, score=created_at unix nano，client 提供的 device_id 上的 session
+// browser_sess:{userID}:{browserID} -> String sessionID，該使用者在 client 提供的 browser_id 上最新的 session，與 session 同時到期
+// signup_cooldown:{ip} -> String flag，該 IP 最近成功註冊過，TTL 即冷卻時間
+// admin_auth_fail:{ip} -> String counter，該 IP admin key 驗證失敗次數，TTL 即計算視窗
+// last_login_geo:{userID} -> Hash: country, at，使用者上一次成功登入的國家與時間，供 impossible travel 比對
+// trusted_device:{userID}:{deviceHash} -> String flag，記住的裝置（deviceHash 為 trusted-device token 的 SHA-256），TTL 即免 MFA 期間
+// opaque_tok:{tokenHash} -> Hash: user_id, session_id, exp, amr，TOKEN_MODE=opaque 時 reference token 對應的 session（tokenHash 為 token 的 SHA-256），TTL 即 token 效期
+// sess_opaque:{sessionID} -> Set: tokenHash，該 session 簽發過的 reference token，登出時一併刪除
+// magic_link:{tokenHash} -> String userID，magic link 登入 token（tokenHash 為 token 的 SHA-256），TTL 即連結效期，使用一次後刪除
+// pwd_reset:{tokenHash} -> String userID，密碼重設 token（tokenHash 為 token 的 SHA-256），TTL 即 token 效期，使用一次後刪除
+// revoked_sess:{sessionID} -> String revokedBy，sessions 表寫入撤銷失敗時留下的 tombstone，TTL 為 session 最長存活時間，避免 DB fallback 回填已撤銷的 session
+// user_sess_events:{userID} -> Pub/Sub channel，user_sess:{userID} 有變動時發布，供 session 清單即時更新
+// feature:{name} -> String "1" / "0"，執行期切換的 feature flag，不存在時沿用設定檔的值
 
 func SessKey(sessionID string) string {
 	return fmt.Sprintf("sess:%s", sessionID)
 }
 
+// SessKeyPattern 是 SCAN 所有 sess:{sessionID} 使用的 pattern。
+func SessKeyPattern() string {
+	return "sess:*"
+}
+
+// SessIDFromKey 從 sess:{sessionID} 取出 sessionID，格式不符時回傳 false。
+func SessIDFromKey(key string) (string, bool) {
+	sid, ok := strings.CutPrefix(key, "sess:")
+	return sid, ok && sid != ""
+}
+
 func UserSessKey(userID int64) string {
 	return fmt.Sprintf("user_sess:%d", userID)
 }
 
+// UserSessKeyPattern 是 SCAN 所有 user_sess:{userID} 使用的 pattern。
+func UserSessKeyPattern() string {
+	return "user_sess:*"
+}
+
+// ParseUserSessKey 從 user_sess:{userID} 取出 userID，格式不符時回傳 false。
+func ParseUserSessKey(key string) (int64, bool) {
+	rest, ok := strings.CutPrefix(key, "user_sess:")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(rest, 10, 64)
+	return id, err == nil
+}
+
 func BannedUserKey(userID int64) string {
 	return fmt.Sprintf("banned_user:%d", userID)
 }
 
+// ShadowBannedKey 是 shadow ban flag；存在即代表該使用者被 shadow ban（仍可登入，由下游 handler 決定如何處理其操作）。
+func ShadowBannedKey(userID int64) string {
+	return fmt.Sprintf("shadow_banned:%d", userID)
+}
+
+func RateLimitKey(scope, id string) string {
+	return fmt.Sprintf("ratelimit:%s:%s", scope, id)
+}
+
+func NonceKey(nonce string) string {
+	return fmt.Sprintf("nonce:%s", nonce)
+}
+
+func EvictReasonKey(sessionID string) string {
+	return fmt.Sprintf("evict_reason:%s", sessionID)
+}
+
+func SessionEpochKey() string {
+	return "session_epoch"
+}
 
+func SignupCooldownKey(ip string) string {
+	return fmt.Sprintf("signup_cooldown:%s", ip)
+}
+
+func DeviceSessKey(deviceID string) string {
+	return fmt.Sprintf("device_sess:%s", deviceID)
+}
+
+func BrowserSessKey(userID int64, browserID string) string {
+	return fmt.Sprintf("browser_sess:%d:%s", userID, browserID)
+}
 
+func AdminAuthFailKey(ip string) string {
+	return fmt.Sprintf("admin_auth_fail:%s", ip)
+}
+
+// CounterKeyPatterns 回傳 counters:sweep 要掃描的計數器 key pattern。這些計數器一律以 TTL 作為視窗，
+// 沒有 TTL 的 key 只會來自 INCR 成功但 EXPIRE 失敗的視窗，不會再自行消失。
+func CounterKeyPatterns() []string {
+	return []string{"ratelimit:*", "admin_auth_fail:*"}
+}
+
+func LastLoginGeoKey(userID int64) string {
+	return fmt.Sprintf("last_login_geo:%d", userID)
+}
+
+func TrustedDeviceKey(userID int64, deviceHash string) string {
+	return fmt.Sprintf("trusted_device:%d:%s", userID, deviceHash)
+}
+
+func OpaqueTokenKey(tokenHash string) string {
+	return fmt.Sprintf("opaque_tok:%s", tokenHash)
+}
+
+// MagicLinkKey 存放 magic link token（SHA-256）對應的 user ID，TTL 即連結效期，使用一次後刪除。
+func MagicLinkKey(tokenHash string) string {
+	return fmt.Sprintf("magic_link:%s", tokenHash)
+}
+
+// PasswordResetKey 存放密碼重設 token（SHA-256）對應的 user ID，TTL 即 token 效期，使用一次後刪除。
+func PasswordResetKey(tokenHash string) string {
+	return fmt.Sprintf("pwd_reset:%s", tokenHash)
+}
+
+// RevokedSessKey 標記已撤銷但 sessions 表尚未記錄的 session，rehydrateSession 看到此 key 時不回填。
+func RevokedSessKey(sessionID string) string {
+	return fmt.Sprintf("revoked_sess:%s", sessionID)
+}
+
+func SessOpaqueTokensKey(sessionID string) string {
+	return fmt.Sprintf("sess_opaque:%s", sessionID)
+}
+
+func UserSessEventsChannel(userID int64) string {
+	return fmt.Sprintf("user_sess_events:%d", userID)
+}
+
+func FeatureFlagKey(name string) string {
+	return fmt.Sprintf("feature:%s", name)
+}
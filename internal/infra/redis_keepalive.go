@@ -0,0 +1,78 @@
+package infra
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisKeepAlive 定期對 Redis 送出 PING，讓流量很低時連線池內的連線不會閒置太久而被 Redis server 的 timeout 或 NAT 關閉，
+// 下一個請求也就不必付出重新連線的延遲。PING 失敗只記錄 log（經 LogError 取樣），不影響服務。
+type RedisKeepAlive struct {
+	rdb      *redis.Client
+	interval time.Duration
+	logf     func(format string, args ...any)
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRedisKeepAlive 建立 RedisKeepAlive，需呼叫 Start 才會開始 PING。
+func NewRedisKeepAlive(rdb *redis.Client, interval time.Duration) *RedisKeepAlive {
+	return &RedisKeepAlive{rdb: rdb, interval: interval, logf: LogError}
+}
+
+// Start 在背景 goroutine 每隔 interval PING 一次；interval <= 0 或已啟動時不做任何事。
+func (k *RedisKeepAlive) Start() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.interval <= 0 || k.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	k.cancel = cancel
+	k.done = make(chan struct{})
+	go k.run(ctx, k.done)
+}
+
+func (k *RedisKeepAlive) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(k.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, k.interval)
+			if err := k.rdb.Ping(pingCtx).Err(); err != nil && ctx.Err() == nil {
+				k.logf("redis keepalive: ping failed: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Stop 停止背景 PING 並等待 goroutine 結束，ctx 逾時時回傳 ctx.Err()；未啟動時直接回傳 nil。
+// 簽名與 lifecycle.Manager.Register 相容，可直接註冊為關閉函式。
+func (k *RedisKeepAlive) Stop(ctx context.Context) error {
+	k.mu.Lock()
+	cancel, done := k.cancel, k.done
+	k.cancel, k.done = nil, nil
+	k.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
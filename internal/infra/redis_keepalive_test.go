@@ -0,0 +1,51 @@
+package infra
+
+import (
+	"context" // 匯入 context，呼叫 Stop
+	"sync"    // 匯入 sync，保護跨 goroutine 的 log 紀錄
+	"testing" // 匯入 testing 套件，提供單元測試支援
+	"time"    // 匯入 time，設定 PING 間隔
+
+	"github.com/alicebob/miniredis/v2"    // 匯入 miniredis，提供記憶體內 Redis
+	"github.com/redis/go-redis/v9"        // 匯入 go-redis，連線到 miniredis
+	"github.com/stretchr/testify/require" // 匯入 testify/require，用於簡潔撰寫斷言
+)
+
+// TestRedisKeepAlive 測試 Start 後會定期 PING、Stop 後停止且可重複呼叫，Redis 無法連線時只記錄 log 不中斷。
+func TestRedisKeepAlive(t *testing.T) {
+	mr, err := miniredis.Run() // 啟動記憶體內 Redis
+	require.NoError(t, err)    // 確保啟動成功
+	defer mr.Close()           // 測試結束時關閉
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()}) // 連線到 miniredis
+	defer rdb.Close()                                       // 測試結束時關閉
+
+	var mu sync.Mutex                                // log 在背景 goroutine 寫入
+	var logs []string                                // 記錄 PING 失敗的 log
+	k := NewRedisKeepAlive(rdb, 10*time.Millisecond) // 每 10ms PING 一次
+	k.logf = func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		logs = append(logs, format) // 記錄失敗
+	}
+
+	require.NoError(t, k.Stop(context.Background())) // 未啟動時 Stop 直接回傳
+	before := mr.CommandCount()                      // 啟動前的指令數
+	k.Start()                                        // 開始背景 PING
+	k.Start()                                        // 重複 Start 不會多開 goroutine
+	require.Eventually(t, func() bool {
+		return mr.CommandCount() >= before+3 // 應持續送出 PING
+	}, time.Second, 5*time.Millisecond)
+
+	mr.Close() // 模擬 Redis 斷線
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(logs) > 0 // PING 失敗只記錄 log
+	}, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second) // 最多等 1 秒
+	defer cancel()
+	require.NoError(t, k.Stop(ctx))                  // 停止並等待 goroutine 結束
+	require.NoError(t, k.Stop(context.Background())) // 重複 Stop 不會出錯
+}
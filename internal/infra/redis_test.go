@@ -27,4 +27,67 @@ func TestBannedUserKey(t *testing.T) {
 	require.Equal(t, "banned_user:7", key)     // 斷言 key 與預期值一致
 }
 
+// TestRateLimitKey 測試 RateLimitKey 是否依照預期組出 ratelimit key。
+func TestRateLimitKey(t *testing.T) {
+	key := RateLimitKey("username_check", "127.0.0.1")           // 以 scope + client IP 產生 key
+	require.Equal(t, "ratelimit:username_check:127.0.0.1", key) // 斷言 key 與預期值一致
+}
+
+// TestNonceKey 測試 NonceKey 是否依照預期組出 nonce key。
+func TestNonceKey(t *testing.T) {
+	key := NonceKey("n-123")                   // 產生 nonce key
+	require.Equal(t, "nonce:n-123", key)       // 斷言 key 與預期值一致
+}
+
+// TestEvictReasonKey 測試 EvictReasonKey 是否依照預期組出 evict_reason key。
+func TestEvictReasonKey(t *testing.T) {
+	key := EvictReasonKey("abc123")                   // 產生 evict_reason key
+	require.Equal(t, "evict_reason:abc123", key)      // 斷言 key 與預期值一致
+}
 
+// TestSessionEpochKey 測試 SessionEpochKey 回傳固定的 session_epoch key。
+func TestSessionEpochKey(t *testing.T) {
+	require.Equal(t, "session_epoch", SessionEpochKey())     // 斷言 key 與預期值一致
+}
+
+// TestSignupCooldownKey 測試 SignupCooldownKey 是否依照預期組出 signup_cooldown key。
+func TestSignupCooldownKey(t *testing.T) {
+	key := SignupCooldownKey("10.0.0.1")              // 產生 signup_cooldown key
+	require.Equal(t, "signup_cooldown:10.0.0.1", key) // 斷言 key 與預期值一致
+}
+
+// TestDeviceSessKey 測試 DeviceSessKey 是否依照預期組出 device_sess key。
+func TestDeviceSessKey(t *testing.T) {
+	key := DeviceSessKey("ios-1234")              // 產生 device_sess key
+	require.Equal(t, "device_sess:ios-1234", key) // 斷言 key 與預期值一致
+}
+
+// TestAdminAuthFailKey 測試 AdminAuthFailKey 是否依照預期組出 admin_auth_fail key。
+func TestAdminAuthFailKey(t *testing.T) {
+	key := AdminAuthFailKey("10.0.0.1")              // 產生 admin_auth_fail key
+	require.Equal(t, "admin_auth_fail:10.0.0.1", key) // 斷言 key 與預期值一致
+}
+
+// TestLastLoginGeoKey 測試 LastLoginGeoKey 是否依照預期組出 last_login_geo key。
+func TestLastLoginGeoKey(t *testing.T) {
+	key := LastLoginGeoKey(42)                 // 產生 last_login_geo key
+	require.Equal(t, "last_login_geo:42", key) // 斷言 key 與預期值一致
+}
+
+// TestRevokedSessKey 測試 RevokedSessKey 是否依照預期組出撤銷 tombstone key。
+func TestRevokedSessKey(t *testing.T) {
+	key := RevokedSessKey("abc123")                // 產生 tombstone key
+	require.Equal(t, "revoked_sess:abc123", key) // 斷言 key 與預期值一致
+}
+
+// TestSessIDFromKey 測試 SessIDFromKey 能從 sess key 取出 session ID，並拒絕格式不符的 key。
+func TestSessIDFromKey(t *testing.T) {
+	sid, ok := SessIDFromKey(SessKey("abc123")) // 由 SessKey 組出的 key
+	require.True(t, ok)                         // 應可解析
+	require.Equal(t, "abc123", sid)             // 取回原本的 session ID
+
+	_, ok = SessIDFromKey("user_sess:42") // 其他前綴的 key
+	require.False(t, ok)                  // 不應解析
+	_, ok = SessIDFromKey("sess:")        // 缺少 session ID
+	require.False(t, ok)                  // 不應解析
+}
@@ -0,0 +1,16 @@
+package infra
+
+import "context"
+
+type requestIDKey struct{}
+
+// WithRequestID 將 request ID 放進 context，讓 Enqueue* 排入的任務帶著同一個 ID 交給 worker。
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext 回傳 context 中的 request ID，沒有時回傳空字串。
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,50 @@
+package infra
+
+import "database/sql"
+
+// RevokeReason 是 sessions.revoked_by 的值，格式為「發起者:原因」（使用者自行登出只寫 user）。
+// 撤銷 session 時一律使用下列常數；migration 013 / 016 以 trigger 拒絕清單以外的值，新增原因時必須一併新增 migration 更新 trigger。
+type RevokeReason string
+
+const (
+	RevokedByUser             RevokeReason = "user"
+	RevokedByPasswordReset    RevokeReason = "user:password_reset"
+	RevokedByExpire           RevokeReason = "system:expire"
+	RevokedBySessionLimit     RevokeReason = "system:limit"
+	RevokedByRedisError       RevokeReason = "system:redis_error"
+	RevokedByUsernameChange   RevokeReason = "system:username_change"
+	RevokedByImpossibleTravel RevokeReason = "system:impossible_travel"
+	RevokedBySessionGone      RevokeReason = "system:session_gone" // 非同步寫入 sessions 紀錄時 session 已不在 Redis，原本的撤銷原因已無從得知
+	RevokedByAdminKick        RevokeReason = "admin:kick"
+	RevokedByAdminKickDevice  RevokeReason = "admin:kick_device"
+	RevokedByAdminBan         RevokeReason = "admin:ban"
+	RevokedByAdminForceReset  RevokeReason = "admin:force_reset"
+	RevokedByAdminDelete      RevokeReason = "admin:delete"
+	RevokedByAdminPurge       RevokeReason = "admin:purge"
+	// RevokedByUnknown 只用於 migration 無法對應的舊資料，程式不應寫入。
+	RevokedByUnknown RevokeReason = "unknown"
+)
+
+// RevokeReasons 是所有合法的 RevokeReason，順序與上方常數相同。
+var RevokeReasons = []RevokeReason{
+	RevokedByUser,
+	RevokedByPasswordReset,
+	RevokedByExpire,
+	RevokedBySessionLimit,
+	RevokedByRedisError,
+	RevokedByUsernameChange,
+	RevokedByImpossibleTravel,
+	RevokedBySessionGone,
+	RevokedByAdminKick,
+	RevokedByAdminKickDevice,
+	RevokedByAdminBan,
+	RevokedByAdminForceReset,
+	RevokedByAdminDelete,
+	RevokedByAdminPurge,
+	RevokedByUnknown,
+}
+
+// NullString 轉成寫入 sessions.revoked_by 用的 sql.NullString。
+func (r RevokeReason) NullString() sql.NullString {
+	return sql.NullString{String: string(r), Valid: true}
+}
@@ -0,0 +1,13 @@
+package infra
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PublishSessionsChanged 在 user_sess:{userID} 有新增或移除 session 後發布通知。
+// 訊息本身不帶內容，訂閱端收到後自行重新讀取 session 清單；沒有訂閱者時 PUBLISH 只是空操作。
+func PublishSessionsChanged(ctx context.Context, rdb *redis.Client, userID int64) error {
+	return rdb.Publish(ctx, UserSessEventsChannel(userID), "changed").Err()
+}
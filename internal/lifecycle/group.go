@@ -0,0 +1,47 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+)
+
+// Group 追蹤服務執行期間臨時啟動的背景工作（例如 admin 觸發的清理），讓關閉時可以等它們收尾，
+// 而不是在 SCAN 或寫入途中隨程序結束被直接中斷。以 Manager.Register 註冊 Group.Shutdown 即可。
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewGroup() *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{ctx: ctx, cancel: cancel}
+}
+
+// Go 在背景執行 fn；fn 收到的 ctx 會在 Shutdown 等待逾時時取消，fn 應以它中止長時間的工作。
+func (g *Group) Go(fn func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		fn(g.ctx)
+	}()
+}
+
+// Shutdown 等待所有背景工作結束；ctx 先結束時取消工作的 ctx，再等它們返回並回傳 ctx.Err()。
+func (g *Group) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		g.cancel()
+		return nil
+	case <-ctx.Done():
+		g.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,41 @@
+package lifecycle
+
+import (
+	"context" // 匯入 context，控制 Shutdown 的等待時間
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，模擬執行中的背景工作
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// TestGroupShutdownWaitsForJobs 測試 Shutdown 會等背景工作完成，工作的 ctx 在完成前不會被取消。
+func TestGroupShutdownWaitsForJobs(t *testing.T) {
+	g := NewGroup() // 建立 Group
+
+	finished := make(chan error, 1) // 記錄工作結束時的 ctx 狀態
+	g.Go(func(ctx context.Context) {
+		time.Sleep(30 * time.Millisecond) // 模擬正在進行的清理
+		finished <- ctx.Err()             // 完成時 ctx 仍有效
+	})
+
+	require.NoError(t, g.Shutdown(context.Background())) // 等到工作完成
+	require.Len(t, finished, 1)                          // Shutdown 返回時工作已結束
+	require.NoError(t, <-finished)                       // 沒有被取消
+}
+
+// TestGroupShutdownCancelsOnTimeout 測試 Shutdown 逾時時會取消工作的 ctx，並等工作返回後才回傳。
+func TestGroupShutdownCancelsOnTimeout(t *testing.T) {
+	g := NewGroup() // 建立 Group
+
+	stopped := make(chan error, 1) // 記錄工作收到的取消原因
+	g.Go(func(ctx context.Context) {
+		<-ctx.Done()         // 只在被取消時結束
+		stopped <- ctx.Err() // 回報取消
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond) // 關閉逾時
+	defer cancel()
+	require.ErrorIs(t, g.Shutdown(ctx), context.DeadlineExceeded) // 回報逾時
+	require.Len(t, stopped, 1)                                    // 返回前工作已結束
+	require.ErrorIs(t, <-stopped, context.Canceled)               // 工作的 ctx 被取消
+}
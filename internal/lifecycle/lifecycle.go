@@ -0,0 +1,114 @@
+// Package lifecycle 協調服務關閉時各子系統的收尾順序。
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout 是未指定時，單一元件關閉可使用的最長時間。
+const DefaultTimeout = 10 * time.Second
+
+type component struct {
+	name    string
+	timeout time.Duration
+	stop    func(ctx context.Context) error
+}
+
+// Manager 依註冊順序記錄各子系統的關閉函式，關閉時反向執行：
+// 先啟動的元件（DB、Redis）最後關，後啟動、依賴它們的元件（HTTP server、背景工作）先關。
+type Manager struct {
+	mu         sync.Mutex
+	components []component
+	timeout    time.Duration
+	logf       func(format string, args ...any)
+	done       bool
+}
+
+// NewManager 建立 Manager；timeout <= 0 時使用 DefaultTimeout。
+func NewManager(timeout time.Duration) *Manager {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Manager{timeout: timeout, logf: log.Printf}
+}
+
+// Register 註冊一個關閉函式，使用 Manager 的預設逾時。
+func (m *Manager) Register(name string, stop func(ctx context.Context) error) {
+	m.RegisterWithTimeout(name, 0, stop)
+}
+
+// RegisterWithTimeout 註冊一個關閉函式並指定它的逾時；timeout <= 0 時使用 Manager 的預設逾時。
+func (m *Manager) RegisterWithTimeout(name string, timeout time.Duration, stop func(ctx context.Context) error) {
+	if timeout <= 0 {
+		timeout = m.timeout
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.components = append(m.components, component{name: name, timeout: timeout, stop: stop})
+}
+
+// RegisterCloser 註冊一個 io.Closer（例如 *sql.DB、*redis.Client）。
+func (m *Manager) RegisterCloser(name string, c io.Closer) {
+	m.Register(name, func(context.Context) error { return c.Close() })
+}
+
+// Shutdown 反向執行所有關閉函式。每個元件有各自的逾時，逾時或失敗都只記錄並繼續關閉下一個，
+// 最後回傳所有錯誤。重複呼叫時第二次起不做任何事。
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if m.done {
+		m.mu.Unlock()
+		return nil
+	}
+	m.done = true
+	components := m.components
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		comp := components[i]
+		start := time.Now()
+		err := runWithTimeout(ctx, comp)
+		if err != nil {
+			m.logf("shutdown: %s failed after %s: %v", comp.name, time.Since(start), err)
+			errs = append(errs, fmt.Errorf("%s: %w", comp.name, err))
+			continue
+		}
+		m.logf("shutdown: %s stopped in %s", comp.name, time.Since(start))
+	}
+	return errors.Join(errs...)
+}
+
+// runWithTimeout 執行單一元件的關閉函式；函式不理會 ctx 而卡住時，逾時後直接放棄等待。
+func runWithTimeout(parent context.Context, comp component) error {
+	ctx, cancel := context.WithTimeout(parent, comp.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- comp.stop(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WaitForSignal 阻塞直到收到任一指定訊號，接著執行 Shutdown。
+func (m *Manager) WaitForSignal(signals ...os.Signal) error {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, signals...)
+	defer signal.Stop(sigCh)
+
+	sig := <-sigCh
+	m.logf("received %s, shutting down", sig)
+	return m.Shutdown(context.Background())
+}
@@ -0,0 +1,59 @@
+package lifecycle
+
+import (
+	"context" // 匯入 context，撰寫關閉函式
+	"errors"  // 匯入 errors，模擬元件關閉失敗
+	"sync"    // 匯入 sync，保護跨 goroutine 的關閉紀錄
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，設定逾時
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// closerFunc 讓函式滿足 io.Closer，測試 RegisterCloser。
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// TestShutdownReverseOrder 測試元件依註冊的反向順序關閉，失敗或逾時不會中斷後續元件。
+func TestShutdownReverseOrder(t *testing.T) {
+	m := NewManager(time.Second)     // 預設逾時 1 秒
+	m.logf = func(string, ...any) {} // 測試中不輸出 log
+
+	var mu sync.Mutex  // 關閉函式在各自的 goroutine 執行
+	var order []string // 記錄關閉順序
+	add := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name) // 記錄被關閉的元件
+	}
+	snapshot := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), order...)
+	}
+	record := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			add(name)
+			return err
+		}
+	}
+	release := make(chan struct{}) // 測試結束時釋放卡住的元件
+	t.Cleanup(func() { close(release) })
+
+	m.RegisterCloser("db", closerFunc(func() error { add("db"); return nil }))        // 最先啟動
+	m.Register("redis", record("redis", errors.New("boom")))                          // 關閉時失敗
+	m.RegisterWithTimeout("stuck", 20*time.Millisecond, func(context.Context) error { // 不理會 ctx 的元件
+		add("stuck")
+		<-release // 直到測試結束都不回傳
+		return nil
+	})
+	m.Register("http", record("http", nil)) // 最後啟動
+
+	err := m.Shutdown(context.Background())                                // 執行關閉
+	require.Equal(t, []string{"http", "stuck", "redis", "db"}, snapshot()) // 反向順序，且失敗後仍繼續
+	require.ErrorContains(t, err, "redis: boom")                           // 回報失敗的元件
+	require.ErrorIs(t, err, context.DeadlineExceeded)                      // 回報逾時的元件
+	require.NoError(t, m.Shutdown(context.Background()))                   // 第二次呼叫不做任何事
+	require.Equal(t, []string{"http", "stuck", "redis", "db"}, snapshot()) // 沒有重複關閉
+}
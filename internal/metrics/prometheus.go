@@ -0,0 +1,113 @@
+// Package metrics 提供 session.Metrics 的實作。
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sessionservice/internal/middleware"
+	"sessionservice/internal/session"
+)
+
+var (
+	_ session.Metrics             = (*Prometheus)(nil)
+	_ middleware.AdminAuthMetrics = (*Prometheus)(nil)
+	_ middleware.HTTPMetrics      = (*Prometheus)(nil)
+)
+
+// Prometheus 將 SessionService 的業務指標轉成 Prometheus counter / histogram。
+type Prometheus struct {
+	logins         *prometheus.CounterVec
+	logouts        prometheus.Counter
+	sessionCreated prometheus.Counter
+	sessionRevoked *prometheus.CounterVec
+	loginLatency   prometheus.Histogram
+	malformed      *prometheus.CounterVec
+
+	adminAuthFailures *prometheus.CounterVec
+
+	httpRequests *prometheus.CounterVec
+	httpLatency  *prometheus.HistogramVec
+}
+
+// NewPrometheus 建立指標並註冊到 reg。
+func NewPrometheus(reg prometheus.Registerer) *Prometheus {
+	p := &Prometheus{
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "session_logins_total",
+			Help: "Login attempts by outcome.",
+		}, []string{"outcome"}),
+		logouts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "session_logouts_total",
+			Help: "User-initiated logouts.",
+		}),
+		sessionCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "session_created_total",
+			Help: "Sessions created.",
+		}),
+		sessionRevoked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "session_revoked_total",
+			Help: "Sessions revoked by reason.",
+		}, []string{"reason"}),
+		loginLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "session_login_duration_seconds",
+			Help:    "Login latency, including password verification.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		malformed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "session_malformed_total",
+			Help: "Session hashes missing or with an unparsable field, by field.",
+		}, []string{"field"}),
+		adminAuthFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "admin_auth_failure_total",
+			Help: "Rejected admin API key authentications by route.",
+		}, []string{"route"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by route template, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by route template and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+	}
+	reg.MustRegister(p.logins, p.logouts, p.sessionCreated, p.sessionRevoked, p.loginLatency, p.malformed, p.adminAuthFailures,
+		p.httpRequests, p.httpLatency)
+	return p
+}
+
+func (p *Prometheus) IncrLogin(outcome string) {
+	p.logins.WithLabelValues(outcome).Inc()
+}
+
+func (p *Prometheus) IncrLogout() {
+	p.logouts.Inc()
+}
+
+func (p *Prometheus) IncrSessionCreated() {
+	p.sessionCreated.Inc()
+}
+
+func (p *Prometheus) IncrSessionRevoked(reason string) {
+	p.sessionRevoked.WithLabelValues(reason).Inc()
+}
+
+func (p *Prometheus) ObserveLoginLatency(d time.Duration) {
+	p.loginLatency.Observe(d.Seconds())
+}
+
+func (p *Prometheus) IncrMalformedSession(field string) {
+	p.malformed.WithLabelValues(field).Inc()
+}
+
+func (p *Prometheus) IncrAdminAuthFailure(route string) {
+	p.adminAuthFailures.WithLabelValues(route).Inc()
+}
+
+func (p *Prometheus) ObserveHTTPRequest(route, method string, status int, d time.Duration) {
+	p.httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	p.httpLatency.WithLabelValues(route, method).Observe(d.Seconds())
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，模擬登入耗時
+
+	"github.com/prometheus/client_golang/prometheus"          // 匯入 prometheus，建立獨立 registry
+	"github.com/prometheus/client_golang/prometheus/testutil" // 匯入 testutil，讀取指標數值
+	"github.com/stretchr/testify/require"                     // 匯入 testify/require，簡化斷言撰寫
+)
+
+// TestPrometheusRecordsMetrics 測試各方法會更新對應的 Prometheus 指標。
+func TestPrometheusRecordsMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry() // 使用獨立 registry，避免影響全域
+	p := NewPrometheus(reg)         // 建立並註冊指標
+
+	p.IncrLogin("success")                        // 成功登入兩次
+	p.IncrLogin("success")                        // 第二次成功
+	p.IncrLogin("invalid_credentials")            // 失敗一次
+	p.IncrLogout()                                // 登出一次
+	p.IncrSessionCreated()                        // 建立 session 一次
+	p.IncrSessionRevoked("admin:kick")            // 被踢一次
+	p.ObserveLoginLatency(120 * time.Millisecond) // 記錄一次耗時
+	p.IncrAdminAuthFailure("/admin/users/:id")    // admin key 驗證失敗一次
+
+	require.Equal(t, 2.0, testutil.ToFloat64(p.logins.WithLabelValues("success")))             // 成功次數
+	require.Equal(t, 1.0, testutil.ToFloat64(p.logins.WithLabelValues("invalid_credentials"))) // 失敗次數
+	require.Equal(t, 1.0, testutil.ToFloat64(p.logouts))                                       // 登出次數
+	require.Equal(t, 1.0, testutil.ToFloat64(p.sessionCreated))                                // 建立次數
+	require.Equal(t, 1.0, testutil.ToFloat64(p.sessionRevoked.WithLabelValues("admin:kick")))  // 撤銷次數
+	require.Equal(t, 1, testutil.CollectAndCount(p.loginLatency))                              // histogram 已註冊並可收集
+
+	require.Equal(t, 1.0, testutil.ToFloat64(p.adminAuthFailures.WithLabelValues("/admin/users/:id"))) // admin 驗證失敗次數
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// /metrics 的對外方式，對應 METRICS_MODE。
+const (
+	ModeOff      = "off"      // 不提供 /metrics
+	ModeListener = "listener" // 在獨立的內部 listener（METRICS_ADDR）提供，不經過主要 API port
+	ModeAdmin    = "admin"    // 掛在主要 API port 的 /metrics，需帶 admin key
+)
+
+// Handler 回傳輸出 g 中所有指標的 HTTP handler。
+func Handler(g prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+}
+
+// Serve 在 addr 啟動只提供 /metrics 的獨立 HTTP server，ctx 結束時關閉並回傳 nil。
+// API 與 worker 共用此函式，addr 應只綁定內部網段（例如 127.0.0.1:9090）。
+func Serve(ctx context.Context, addr string, g prometheus.Gatherer) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(g))
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
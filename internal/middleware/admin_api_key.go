@@ -1,13 +1,32 @@
 package middleware
 
 import (
+	"context"
+	"crypto/subtle"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+
+	"sessionservice/internal/infra"
 )
 
+// AdminAuthFailureReporter 接收 admin key 驗證失敗事件。
+// route 為 Gin 的路由樣板（例如 /admin/users/:id），ip 為 client IP；不會帶入 client 送出的 key。
+type AdminAuthFailureReporter interface {
+	AdminAuthFailure(ctx context.Context, route, ip string)
+}
+
 // NewAdminAPIKeyMiddleware 檢查 X-Admin-Token 是否與設定值相符。
-func NewAdminAPIKeyMiddleware(adminKey string) gin.HandlerFunc {
+// 驗證失敗時會記錄 log，並通知 reporters（例如 AdminAuthAudit）。
+func NewAdminAPIKeyMiddleware(adminKey string, reporters ...AdminAuthFailureReporter) gin.HandlerFunc {
+	return NewAdminAPIKeysMiddleware(adminKey, "", reporters...)
+}
+
+// NewAdminAPIKeysMiddleware 與 NewAdminAPIKeyMiddleware 相同，但輪替期間同時接受 previousKey（ADMIN_API_KEY_PREVIOUS），
+// 讓仍在使用舊 key 的 admin 工具不會在切換當下失敗。兩把 key 都以 constant-time 比對；previousKey 為空時只接受 adminKey。
+// 以舊 key 通過時記錄取樣的 log，方便確認何時可以移除舊 key。
+func NewAdminAPIKeysMiddleware(adminKey, previousKey string, reporters ...AdminAuthFailureReporter) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if adminKey == "" {
 			// 若沒設定 admin key，仍允許請求通過，但建議只在本地開發時使用。
@@ -16,15 +35,34 @@ func NewAdminAPIKeyMiddleware(adminKey string) gin.HandlerFunc {
 		}
 
 		token := c.GetHeader("X-Admin-Token")
-		if token == "" || token != adminKey {
+		current := subtle.ConstantTimeCompare([]byte(token), []byte(adminKey)) == 1
+		previous := previousKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(previousKey)) == 1
+		if token == "" || (!current && !previous) {
+			reason := "mismatch"
+			if token == "" {
+				reason = "missing"
+			}
+			route := c.FullPath()
+			if route == "" {
+				route = "unmatched"
+			}
+			// 只記錄來源與原因，絕不記錄 client 送出的 key
+			log.Printf("admin auth failure: route=%s method=%s ip=%s reason=%s", route, c.Request.Method, c.ClientIP(), reason)
+			for _, r := range reporters {
+				if r != nil {
+					r.AdminAuthFailure(c.Request.Context(), route, c.ClientIP())
+				}
+			}
+
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
 				"error": "forbidden",
 			})
 			return
 		}
+		if previous {
+			infra.LogError("admin auth: request authenticated with ADMIN_API_KEY_PREVIOUS: route=%s", c.FullPath())
+		}
 
 		c.Next()
 	}
 }
-
-
@@ -59,3 +59,31 @@ func TestAdminAPIKeyMiddleware_Success(t *testing.T) {
 }
 
 
+
+// TestAdminAPIKeysMiddleware_Rotation 測試輪替期間新舊 key 都能通過，錯誤的 key 仍回 403；
+// 移除舊 key（previousKey 為空）後舊 key 改回 403，新 key 不受影響。
+func TestAdminAPIKeysMiddleware_Rotation(t *testing.T) {
+	gin.SetMode(gin.TestMode) // 設為測試模式
+	do := func(h gin.HandlerFunc, token string) int {
+		r := gin.New()                              // 建立 Gin Engine
+		r.Use(h)                                    // 掛上待測 middleware
+		r.GET("/admin/ping", func(c *gin.Context) { // 註冊測試路由
+			c.JSON(http.StatusOK, gin.H{"ok": true}) // 通過時回 200
+		})
+		req := httptest.NewRequest(http.MethodGet, "/admin/ping", nil) // 建立請求
+		req.Header.Set("X-Admin-Token", token)                         // 帶上 admin token
+		w := httptest.NewRecorder()                                    // 建立 ResponseRecorder
+		r.ServeHTTP(w, req)                                            // 執行請求
+		return w.Code
+	}
+
+	rotating := NewAdminAPIKeysMiddleware("new-key", "old-key")       // 輪替期間
+	require.Equal(t, http.StatusOK, do(rotating, "new-key"))          // 新 key 通過
+	require.Equal(t, http.StatusOK, do(rotating, "old-key"))          // 舊 key 仍通過
+	require.Equal(t, http.StatusForbidden, do(rotating, "other-key")) // 錯誤的 key 拒絕
+
+	done := NewAdminAPIKeysMiddleware("new-key", "")            // 移除舊 key 後
+	require.Equal(t, http.StatusOK, do(done, "new-key"))        // 新 key 通過
+	require.Equal(t, http.StatusForbidden, do(done, "old-key")) // 舊 key 拒絕
+	require.Equal(t, http.StatusForbidden, do(done, ""))        // 空的 key 不會因為舊 key 為空而通過
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+// AdminAuthMetrics 記錄 admin key 驗證失敗次數，由 metrics.Prometheus 實作。
+type AdminAuthMetrics interface {
+	IncrAdminAuthFailure(route string)
+}
+
+// AdminAuthAudit 稽核 admin key 驗證失敗：每次失敗都更新指標；
+// 同一 IP 在 window 內失敗達 threshold 次時送出一次 notify:admin_auth_failure。
+type AdminAuthAudit struct {
+	metrics     AdminAuthMetrics
+	rdb         *redis.Client
+	asynqClient *asynq.Client
+	threshold   int
+	window      time.Duration
+}
+
+var _ AdminAuthFailureReporter = (*AdminAuthAudit)(nil)
+
+// NewAdminAuthAudit 建立 AdminAuthAudit。threshold <= 0 或 rdb 為 nil 時只記錄指標，不送出通知。
+func NewAdminAuthAudit(metrics AdminAuthMetrics, rdb *redis.Client, asynqClient *asynq.Client, threshold int, window time.Duration) *AdminAuthAudit {
+	return &AdminAuthAudit{
+		metrics:     metrics,
+		rdb:         rdb,
+		asynqClient: asynqClient,
+		threshold:   threshold,
+		window:      window,
+	}
+}
+
+// AdminAuthFailure 實作 AdminAuthFailureReporter。
+// Redis 或 Asynq 發生錯誤時只記錄 log，不影響 403 回應。
+func (a *AdminAuthAudit) AdminAuthFailure(ctx context.Context, route, ip string) {
+	if a.metrics != nil {
+		a.metrics.IncrAdminAuthFailure(route)
+	}
+	if a.threshold <= 0 || a.rdb == nil {
+		return
+	}
+
+	key := infra.AdminAuthFailKey(ip)
+	pipe := a.rdb.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, a.window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		infra.LogError("admin auth audit: redis error: %v", err)
+		return
+	}
+
+	// 只在剛好達到門檻時通知一次，避免持續嘗試時灌爆通知佇列
+	if incr.Val() != int64(a.threshold) {
+		return
+	}
+	err := infra.EnqueueAdminAuthFailureNotify(ctx, a.asynqClient, infra.AdminAuthFailurePayload{
+		IP:        ip,
+		Route:     route,
+		Failures:  incr.Val(),
+		Window:    a.window,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		log.Printf("admin auth audit: enqueue notify error: %v", err)
+	}
+}
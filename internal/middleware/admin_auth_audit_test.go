@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"bytes"             // 匯入 bytes，攔截 log 輸出
+	"context"           // 匯入 context，讀取 Redis 計數
+	"log"               // 匯入 log，替換 log 輸出目的地
+	"net/http"          // 匯入 net/http，提供 HTTP 狀態碼常數
+	"net/http/httptest" // 匯入 httptest，建立測試請求
+	"os"                // 匯入 os，還原 log 輸出
+	"sync"              // 匯入 sync，保護計數 map
+	"testing"           // 匯入 testing，提供單元測試框架
+	"time"              // 匯入 time，設定計數視窗
+
+	"github.com/alicebob/miniredis/v2"    // 匯入 miniredis，提供記憶體內 Redis
+	"github.com/gin-gonic/gin"            // 匯入 gin，建立測試路由
+	"github.com/redis/go-redis/v9"        // 匯入 go-redis，連線到 miniredis
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra，取得計數 key
+)
+
+// fakeAdminAuthMetrics 以 map 記錄各 route 的失敗次數。
+type fakeAdminAuthMetrics struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (f *fakeAdminAuthMetrics) IncrAdminAuthFailure(route string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counts[route]++
+}
+
+// TestAdminAPIKeyMiddleware_AuditsFailures 測試驗證失敗時指標依 route 累加、IP 計數寫入 Redis，且 log 不含送出的 key。
+func TestAdminAPIKeyMiddleware_AuditsFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode) // 設為測試模式
+
+	mr, err := miniredis.Run() // 啟動記憶體內 Redis
+	require.NoError(t, err)    // 確保啟動成功
+	t.Cleanup(mr.Close)        // 測試結束後關閉
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	var logs bytes.Buffer                          // 攔截 log 輸出
+	log.SetOutput(&logs)                           // 將 log 導向 buffer
+	t.Cleanup(func() { log.SetOutput(os.Stderr) }) // 測試結束後還原
+
+	m := &fakeAdminAuthMetrics{counts: map[string]int{}}    // 假的指標實作
+	audit := NewAdminAuthAudit(m, rdb, nil, 3, time.Minute) // 門檻 3 次，未設定 asynq client
+	r := gin.New()                                          // 建立 Gin Engine
+	r.Use(NewAdminAPIKeyMiddleware("secret-key", audit))    // 掛上帶稽核的 middleware
+	r.GET("/admin/users/:id", func(c *gin.Context) {        // 註冊帶參數的路由
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	for _, key := range []string{"wrong-key-1", "wrong-key-2", ""} { // 兩次錯誤 key、一次未帶 key
+		req := httptest.NewRequest(http.MethodGet, "/admin/users/42", nil)
+		if key != "" {
+			req.Header.Set("X-Admin-Token", key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)                            // 執行請求
+		require.Equal(t, http.StatusForbidden, w.Code) // 應被拒絕
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/users/42", nil) // 正確 key
+	req.Header.Set("X-Admin-Token", "secret-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)                     // 執行請求
+	require.Equal(t, http.StatusOK, w.Code) // 應通過且不計入失敗
+
+	require.Equal(t, map[string]int{"/admin/users/:id": 3}, m.counts) // 指標以路由樣板為 label
+
+	n, err := rdb.Get(context.Background(), infra.AdminAuthFailKey("192.0.2.1")).Int() // httptest 預設的 client IP
+	require.NoError(t, err)                                                            // 計數 key 應存在
+	require.Equal(t, 3, n)                                                             // 累計三次失敗
+	require.Greater(t, mr.TTL(infra.AdminAuthFailKey("192.0.2.1")), time.Duration(0))  // 計數有視窗 TTL
+
+	require.Contains(t, logs.String(), "admin auth failure: route=/admin/users/:id") // 有記錄失敗
+	require.Contains(t, logs.String(), "reason=missing")                             // 區分未帶 key
+	require.NotContains(t, logs.String(), "wrong-key")                               // 不記錄送出的 key
+	require.NotContains(t, logs.String(), "secret-key")                              // 也不記錄設定的 key
+}
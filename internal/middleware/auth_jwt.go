@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 
+	"sessionservice/internal/infra"
 	"sessionservice/internal/session"
 	"sessionservice/internal/token"
 )
@@ -14,62 +19,270 @@ const (
 	// ContextKeyUserID 是 Gin context 裡存放 user ID 的 key。
 	ContextKeyUserID    = "userID"
 	ContextKeySessionID = "sessionID"
+	// ContextKeyTokenExpiresAt 存放呼叫端 token 的到期時間（time.Time）。
+	ContextKeyTokenExpiresAt = "tokenExpiresAt"
+	// ContextKeyTokenIssuedAt 存放呼叫端 token 的簽發時間（time.Time），token 沒有 iat 時不設定。
+	ContextKeyTokenIssuedAt = "tokenIssuedAt"
+	// ContextKeyAMR 存放 token 的 amr claim（[]string），即使用者登入時使用的驗證方式。
+	ContextKeyAMR = "amr"
+	// ContextKeyShadowBanned 存放使用者是否被 shadow ban（bool）；請求照常通過，由下游 handler 決定如何處理。
+	ContextKeyShadowBanned = "shadowBanned"
+
+	// DefaultMaxTokenLength 是未設定上限時允許的 JWT 最大長度（bytes）。
+	DefaultMaxTokenLength = 8 * 1024
 )
 
 // NewAuthJWTMiddleware 建立一個 Gin middleware：
 // - 從 Authorization: Bearer <token> 抽出 JWT
+// - 先做長度與 header.payload.signature 三段格式的便宜檢查，明顯不合法的 token 直接回 401
 // - 使用 token.Manager 驗證簽章與過期時間
+//   （TOKEN_MODE=opaque 時改以 Redis 查詢 reference token，不解析 JWT）
 // - 解析出 userID 與 sessionID
 // - 呼叫 SessionService.IsSessionValid 進一步確認 Redis session 是否仍存在
-// - 將 userID / sessionID 塞進 Gin context
-func NewAuthJWTMiddleware(jwtMgr *token.Manager, sessSvc *session.SessionService) gin.HandlerFunc {
+// - 將 userID / sessionID / amr 塞進 Gin context
+// - 任一步驟失敗時回 401，並依 RFC 6750 以 WWW-Authenticate 標示原因（例如 token 過期、簽章錯誤、session 已失效）
+// 只接受一般使用者 API 的 token（token.AudienceUser），其他路由群組請用 NewAudienceAuthMiddleware 宣告自己的 audience。
+func NewAuthJWTMiddleware(jwtMgr *token.Manager, sessSvc *session.SessionService, maxTokenLen int) gin.HandlerFunc {
+	return NewAudienceAuthMiddleware(jwtMgr, sessSvc, maxTokenLen, token.AudienceUser)
+}
+
+// NewAudienceAuthMiddleware 與 NewAuthJWTMiddleware 相同，但只接受 aud 為 audience 的 JWT（見 token.Manager.AcceptsAudience），
+// 讓 admin 用的 token 不能呼叫一般 API，反之亦然。reference token 沒有 audience，一律視為一般使用者的 token。
+func NewAudienceAuthMiddleware(jwtMgr *token.Manager, sessSvc *session.SessionService, maxTokenLen int, audience string) gin.HandlerFunc {
+	if maxTokenLen <= 0 {
+		maxTokenLen = DefaultMaxTokenLength
+	}
+
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing Authorization header"})
+		raw, ok := bearerToken(c)
+		if !ok {
 			return
 		}
+		authenticateToken(c, jwtMgr, sessSvc, maxTokenLen, audience, raw)
+	}
+}
 
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid Authorization header"})
-			return
-		}
+// bodyToken 是 NewBodyTokenAuthMiddleware 接受的 body 格式，JSON 或 form 皆可。
+type bodyToken struct {
+	Token string `json:"token" form:"token"`
+}
 
-		raw := strings.TrimSpace(parts[1])
-		if raw == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "empty token"})
-			return
-		}
+// NewBodyTokenAuthMiddleware 與 NewAuthJWTMiddleware 相同，但沒有 Authorization header 時改從 body 的 token 欄位取得 JWT，
+// 給只能送 body 的 client 使用（例如關閉分頁時以 navigator.sendBeacon 登出）。
+// sendBeacon 送出字串時 Content-Type 為 text/plain，此時 body 同樣以 JSON 解析；簽章與 session 的檢查與 header 完全相同。
+func NewBodyTokenAuthMiddleware(jwtMgr *token.Manager, sessSvc *session.SessionService, maxTokenLen int) gin.HandlerFunc {
+	if maxTokenLen <= 0 {
+		maxTokenLen = DefaultMaxTokenLength
+	}
 
-		parsed, err := jwtMgr.Parse(raw)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			raw, ok := bearerToken(c)
+			if !ok {
+				return
+			}
+			authenticateToken(c, jwtMgr, sessSvc, maxTokenLen, token.AudienceUser, raw)
 			return
 		}
 
-		claims := parsed.Claims
-		userID := claims.UserID
-		sessionID := claims.SessionID
-		if sessionID == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid_token_no_session"})
-			return
+		var body bodyToken
+		var err error
+		switch c.ContentType() {
+		case "application/x-www-form-urlencoded", "multipart/form-data":
+			err = c.ShouldBind(&body)
+		default:
+			err = c.ShouldBindJSON(&body)
 		}
-
-		ok, err := sessSvc.IsSessionValid(c.Request.Context(), userID, sessionID)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session_check_failed"})
+		raw := strings.TrimSpace(body.Token)
+		if err != nil || raw == "" {
+			abortUnauthorized(c, "", "", gin.H{"error": "missing token"})
 			return
 		}
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session_invalid"})
+		authenticateToken(c, jwtMgr, sessSvc, maxTokenLen, token.AudienceUser, raw)
+	}
+}
+
+// bearerToken 從 Authorization: Bearer <token> 取出 JWT，格式錯誤時回 401 並回傳 false。
+func bearerToken(c *gin.Context) (string, bool) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		abortUnauthorized(c, "", "", gin.H{"error": "missing Authorization header"})
+		return "", false
+	}
+
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		abortUnauthorized(c, bearerInvalidRequest, "Authorization header must use the Bearer scheme", gin.H{"error": "invalid Authorization header"})
+		return "", false
+	}
+
+	raw := strings.TrimSpace(parts[1])
+	if raw == "" {
+		abortUnauthorized(c, bearerInvalidRequest, "The access token is empty", gin.H{"error": "empty token"})
+		return "", false
+	}
+	return raw, true
+}
+
+// tokenSubject 是從 access token（JWT 或 reference token）取出、要寫進 context 的資料。
+type tokenSubject struct {
+	UserID    int64
+	SessionID string
+	ExpiresAt time.Time
+	IssuedAt  time.Time // reference token 沒有 iat，為零值
+	AMR       []string
+}
+
+// authenticateToken 驗證 access token 與對應的 session，通過時將 userID / sessionID / amr 塞進 context 並繼續，否則回 401。
+// TOKEN_MODE=opaque 時 token 為 reference token，改查 Redis 而不解析 JWT；JWT 的 aud 必須被 audience 接受，
+// reference token 只由使用者登入簽發，因此只能用於 token.AudienceUser 的路由群組。
+func authenticateToken(c *gin.Context, jwtMgr *token.Manager, sessSvc *session.SessionService, maxTokenLen int, audience, raw string) {
+	var subject tokenSubject
+	var ok bool
+	if sessSvc.OpaqueTokensEnabled() {
+		subject, ok = resolveOpaqueToken(c, sessSvc, maxTokenLen, audience, raw)
+	} else {
+		subject, ok = parseJWT(c, jwtMgr, maxTokenLen, audience, raw)
+	}
+	if !ok {
+		return
+	}
+
+	userID := subject.UserID
+	sessionID := subject.SessionID
+	if sessionID == "" {
+		abortUnauthorized(c, bearerInvalidToken, "The access token is not bound to a session", gin.H{"error": "invalid_token_no_session"})
+		return
+	}
+
+	ok, err := sessSvc.IsSessionValid(c.Request.Context(), userID, sessionID)
+	if err != nil {
+		infra.LogError("auth: session check failed: %v", err)
+		abortUnauthorized(c, bearerInvalidToken, "The session could not be verified", gin.H{"error": "session_check_failed"})
+		return
+	}
+	if !ok {
+		if reason, _ := sessSvc.EvictReason(c.Request.Context(), sessionID); reason == session.EvictReasonMaxSessions {
+			abortUnauthorized(c, bearerInvalidToken, "The session was ended by a newer login", gin.H{"error": gin.H{"code": "EVICTED_MAX_SESSIONS"}})
 			return
 		}
+		abortUnauthorized(c, bearerInvalidToken, "The session is no longer valid", gin.H{"error": "session_invalid"})
+		return
+	}
+
+	c.Set(ContextKeyUserID, userID)
+	c.Set(ContextKeySessionID, sessionID)
+	if !subject.ExpiresAt.IsZero() {
+		c.Set(ContextKeyTokenExpiresAt, subject.ExpiresAt)
+	}
+	if !subject.IssuedAt.IsZero() {
+		c.Set(ContextKeyTokenIssuedAt, subject.IssuedAt)
+	}
+	c.Set(ContextKeyAMR, subject.AMR)
+
+	// 查詢失敗時視為未 shadow ban，不因這個 flag 擋下請求
+	shadowBanned, err := sessSvc.IsShadowBanned(c.Request.Context(), userID)
+	if err != nil {
+		infra.LogError("auth: shadow ban check failed: %v", err)
+	}
+	c.Set(ContextKeyShadowBanned, shadowBanned)
+	c.Next()
+}
+
+// parseJWT 驗證 JWT 的格式、簽章、到期時間與 audience，不接受 token exchange 換出或其他路由群組的 token；失敗時回 401 並回傳 false。
+func parseJWT(c *gin.Context, jwtMgr *token.Manager, maxTokenLen int, audience, raw string) (tokenSubject, bool) {
+	if len(raw) > maxTokenLen || !hasJWTShape(raw) {
+		abortUnauthorized(c, bearerInvalidToken, "The access token is malformed", gin.H{"error": "invalid token"})
+		return tokenSubject{}, false
+	}
+
+	parsed, err := jwtMgr.Parse(raw)
+	if err != nil {
+		abortUnauthorized(c, bearerInvalidToken, parseErrorDescription(err), gin.H{"error": "invalid token"})
+		return tokenSubject{}, false
+	}
+
+	claims := parsed.Claims
+	if !jwtMgr.AcceptsAudience(claims, audience) {
+		// token exchange 換出的 token 只給下游服務使用；admin 與一般使用者的 token 也不能互相呼叫對方的路由
+		abortUnauthorized(c, bearerInvalidToken, "The access token is not accepted by this service", gin.H{"error": "invalid token"})
+		return tokenSubject{}, false
+	}
+
+	subject := tokenSubject{UserID: claims.UserID, SessionID: claims.SessionID, AMR: claims.AMR}
+	if claims.ExpiresAt != nil {
+		subject.ExpiresAt = claims.ExpiresAt.Time
+	}
+	if claims.IssuedAt != nil {
+		subject.IssuedAt = claims.IssuedAt.Time
+	}
+	return subject, true
+}
 
-		c.Set(ContextKeyUserID, userID)
-		c.Set(ContextKeySessionID, sessionID)
-		c.Next()
+// resolveOpaqueToken 以 Redis 查出 reference token 對應的 session；token 不存在（已登出或過期）時回 401 並回傳 false。
+// reference token 不帶 aud，一律視為 token.AudienceUser，其他 audience 的路由群組（例如 admin）直接拒絕。
+func resolveOpaqueToken(c *gin.Context, sessSvc *session.SessionService, maxTokenLen int, audience, raw string) (tokenSubject, bool) {
+	if len(raw) > maxTokenLen {
+		abortUnauthorized(c, bearerInvalidToken, "The access token is malformed", gin.H{"error": "invalid token"})
+		return tokenSubject{}, false
+	}
+	if audience != token.AudienceUser {
+		abortUnauthorized(c, bearerInvalidToken, "The access token is not accepted by this service", gin.H{"error": "invalid token"})
+		return tokenSubject{}, false
+	}
+
+	tok, err := sessSvc.ResolveOpaqueToken(c.Request.Context(), raw)
+	if errors.Is(err, session.ErrOpaqueTokenInvalid) {
+		abortUnauthorized(c, bearerInvalidToken, "The access token is invalid or expired", gin.H{"error": "invalid token"})
+		return tokenSubject{}, false
+	}
+	if err != nil {
+		infra.LogError("auth: opaque token lookup failed: %v", err)
+		abortUnauthorized(c, bearerInvalidToken, "The session could not be verified", gin.H{"error": "session_check_failed"})
+		return tokenSubject{}, false
 	}
+	return tokenSubject{UserID: tok.UserID, SessionID: tok.SessionID, ExpiresAt: tok.ExpiresAt, AMR: tok.AMR}, true
 }
 
+// RFC 6750 §3.1 定義的 WWW-Authenticate error code。
+const (
+	bearerInvalidRequest = "invalid_request"
+	bearerInvalidToken   = "invalid_token"
+)
 
+// abortUnauthorized 回 401 並依 RFC 6750 設定 WWW-Authenticate，JSON body 維持原本的格式。
+// bearerErr 為空代表請求完全沒有帶 token，依 RFC 6750 此時只回 Bearer、不帶 error。
+func abortUnauthorized(c *gin.Context, bearerErr, description string, body gin.H) {
+	challenge := "Bearer"
+	if bearerErr != "" {
+		challenge = fmt.Sprintf("Bearer error=%q, error_description=%q", bearerErr, description)
+	}
+	c.Header("WWW-Authenticate", challenge)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, body)
+}
+
+// parseErrorDescription 將 token.Manager.Parse 的錯誤轉成 WWW-Authenticate 的 error_description，
+// 讓 client 分辨 token 過期（應 refresh）與簽章錯誤（應重新登入）。
+func parseErrorDescription(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "The access token expired"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return "The access token signature is invalid"
+	default:
+		return "The access token is invalid"
+	}
+}
+
+// hasJWTShape 檢查 token 是否為三段非空、以 "." 分隔的 compact JWS 格式。
+func hasJWTShape(raw string) bool {
+	if strings.Count(raw, ".") != 2 {
+		return false
+	}
+	for _, seg := range strings.Split(raw, ".") {
+		if seg == "" {
+			return false
+		}
+	}
+	return true
+}
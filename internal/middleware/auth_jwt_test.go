@@ -4,6 +4,7 @@ import (
 	"context"              // 匯入 context，用於 Redis 與 SessionService 呼叫
 	"net/http"             // 匯入 net/http，提供 HTTP 方法與狀態碼常數
 	"net/http/httptest"    // 匯入 httptest，建立 HTTP 測試伺服器與請求
+	"strings"              // 匯入 strings，產生超長與格式錯誤的 token
 	"testing"              // 匯入 testing 套件，提供單元測試框架
 	"time"                 // 匯入 time，用於設定與檢查 JWT 過期時間
 
@@ -36,7 +37,7 @@ func newTestSessionService(t *testing.T) (*session.SessionService, *token.Manage
 		MaxSessionsPerUser: 10,        // 測試中不需觸發 session 上限
 	}
 
-	sessSvc := session.NewSessionService(nil, rdb, cfg, nil) // 建立 SessionService，資料庫與 Asynq 參數傳入 nil 即可
+	sessSvc := session.NewSessionService(nil, rdb, cfg, nil, nil) // 建立 SessionService，資料庫與 Asynq 參數傳入 nil 即可
 	jwtMgr := token.NewManager("test-secret", time.Hour)     // 建立 JWT Manager，測試用密鑰與 TTL
 
 	return sessSvc, jwtMgr, mr, rdb // 回傳 SessionService、JWT Manager、miniredis handler 與 Redis client，以便測試使用與關閉
@@ -46,7 +47,7 @@ func newTestSessionService(t *testing.T) (*session.SessionService, *token.Manage
 func setupAuthRoute(jwtMgr *token.Manager, sessSvc *session.SessionService) *gin.Engine {
 	gin.SetMode(gin.TestMode)                                   // 設定 Gin 為測試模式
 	r := gin.New()                                              // 建立新的 Gin Engine
-	r.Use(NewAuthJWTMiddleware(jwtMgr, sessSvc))                // 在全域掛上 JWT 驗證 middleware
+	r.Use(NewAuthJWTMiddleware(jwtMgr, sessSvc, 0))             // 在全域掛上 JWT 驗證 middleware（使用預設長度上限）
 	r.GET("/me", func(c *gin.Context) {                         // 建立測試用的 /me 路由
 		userID, _ := c.Get(ContextKeyUserID)                // 從 context 取出 userID
 		sessionID, _ := c.Get(ContextKeySessionID)          // 從 context 取出 sessionID
@@ -173,4 +174,208 @@ func TestAuthJWTMiddleware_SessionInvalid(t *testing.T) {
 	require.Equal(t, http.StatusUnauthorized, w.Code)             // 因為 Redis 中沒有對應 session，應回傳 401
 }
 
+// TestAuthJWTMiddleware_OversizedToken 測試超過長度上限的 token 不經解析直接回傳 401。
+func TestAuthJWTMiddleware_OversizedToken(t *testing.T) {
+	sessSvc, jwtMgr, mr, rdb := newTestSessionService(t) // 建立測試用 SessionService 與 JWT Manager
+	defer mr.Close()                                     // 測試結束關閉 miniredis
+	defer rdb.Close()                                    // 測試結束關閉 Redis client
+
+	huge := strings.Repeat("a", DefaultMaxTokenLength) + ".b.c"   // 三段格式正確但長度超過上限
+
+	r := setupAuthRoute(jwtMgr, sessSvc)                          // 建立測試 router
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)        // 建立請求
+	req.Header.Set("Authorization", "Bearer "+huge)               // 帶入超長 token
+	w := httptest.NewRecorder()                                   // 建立 ResponseRecorder
+
+	r.ServeHTTP(w, req)                                           // 執行請求
+	require.Equal(t, http.StatusUnauthorized, w.Code)             // 斷言為 401 Unauthorized
+	require.Contains(t, w.Body.String(), "invalid token")         // 應回傳 invalid token
+}
+
+// TestAuthJWTMiddleware_WrongSegmentCount 測試 "." 分隔段數不是三段的 token 應回傳 401。
+func TestAuthJWTMiddleware_WrongSegmentCount(t *testing.T) {
+	sessSvc, jwtMgr, mr, rdb := newTestSessionService(t) // 建立測試用 SessionService 與 JWT Manager
+	defer mr.Close()                                     // 測試結束關閉 miniredis
+	defer rdb.Close()                                    // 測試結束關閉 Redis client
 
+	r := setupAuthRoute(jwtMgr, sessSvc)                          // 建立測試 router
+	for _, raw := range []string{"abc", "a.b", "a.b.c.d", "a..c"} { // 段數錯誤或含空段的 token
+		req := httptest.NewRequest(http.MethodGet, "/me", nil) // 建立請求
+		req.Header.Set("Authorization", "Bearer "+raw)         // 帶入格式錯誤的 token
+		w := httptest.NewRecorder()                            // 建立 ResponseRecorder
+
+		r.ServeHTTP(w, req)                                    // 執行請求
+		require.Equal(t, http.StatusUnauthorized, w.Code, raw) // 斷言為 401 Unauthorized
+	}
+}
+
+// TestAuthJWTMiddleware_WWWAuthenticate 測試各種驗證失敗都回 401 並帶上對應的 WWW-Authenticate，JSON body 仍保留。
+func TestAuthJWTMiddleware_WWWAuthenticate(t *testing.T) {
+	sessSvc, jwtMgr, mr, rdb := newTestSessionService(t) // 建立測試用 SessionService / JWT Manager
+	defer mr.Close()                                     // 測試結束時關閉 miniredis
+	defer rdb.Close()                                    // 測試結束時關閉 Redis client
+
+	expired, err := jwtMgr.GenerateWithSession(1, "sid-expired", time.Now().Add(-time.Minute))                          // 已過期的 token
+	require.NoError(t, err)                                                                                             // 產生 token 不應失敗
+	forged, err := token.NewManager("other-secret", time.Hour).GenerateWithSession(1, "sid", time.Now().Add(time.Hour)) // 以其他密鑰簽章
+	require.NoError(t, err)                                                                                             // 產生 token 不應失敗
+	revoked, err := jwtMgr.GenerateWithSession(1, "sid-gone", time.Now().Add(time.Hour))                                // Redis 中沒有對應 session
+	require.NoError(t, err)                                                                                             // 產生 token 不應失敗
+	noSession, err := jwtMgr.Generate(1)                                                                                // 沒有 session ID 的 token
+	require.NoError(t, err)                                                                                             // 產生 token 不應失敗
+
+	for _, tc := range []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"missing token", "", `Bearer`}, // 沒帶 token 時不帶 error
+		{"wrong scheme", "Basic abc", `Bearer error="invalid_request", error_description="Authorization header must use the Bearer scheme"`},
+		{"expired", "Bearer " + expired, `Bearer error="invalid_token", error_description="The access token expired"`},
+		{"invalid signature", "Bearer " + forged, `Bearer error="invalid_token", error_description="The access token signature is invalid"`},
+		{"malformed", "Bearer not-a-jwt", `Bearer error="invalid_token", error_description="The access token is malformed"`},
+		{"no session", "Bearer " + noSession, `Bearer error="invalid_token", error_description="The access token is not bound to a session"`},
+		{"session invalid", "Bearer " + revoked, `Bearer error="invalid_token", error_description="The session is no longer valid"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupAuthRoute(jwtMgr, sessSvc)                   // 建立掛好 middleware 的 router
+			req := httptest.NewRequest(http.MethodGet, "/me", nil) // 準備呼叫 /me 的請求
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header) // 帶入 Authorization header
+			}
+			w := httptest.NewRecorder() // 建立 ResponseRecorder
+
+			r.ServeHTTP(w, req)                                           // 執行請求
+			require.Equal(t, http.StatusUnauthorized, w.Code)             // 應回 401
+			require.Equal(t, tc.want, w.Header().Get("WWW-Authenticate")) // 依失敗原因帶上 WWW-Authenticate
+			require.Contains(t, w.Body.String(), `"error"`)               // JSON body 仍保留
+		})
+	}
+}
+
+// TestAuthJWTMiddleware_ShadowBanned 測試 shadow ban 的使用者仍可通過驗證，且 context 帶有 ContextKeyShadowBanned。
+func TestAuthJWTMiddleware_ShadowBanned(t *testing.T) {
+	sessSvc, jwtMgr, mr, rdb := newTestSessionService(t) // 建立測試用 SessionService / JWT Manager / miniredis / Redis client
+	defer mr.Close()                                     // 測試結束時關閉 miniredis
+	defer rdb.Close()                                    // 測試結束時關閉 Redis client
+
+	ctx := context.Background() // 建立背景 context，用於 Redis 操作
+	userID := int64(100)        // 測試用 user ID
+	sessionID := "sid-shadow"   // 測試用 session ID
+	require.NoError(t, rdb.HSet(ctx, infra.SessKey(sessionID), map[string]interface{}{
+		"user_id":    userID,                           // 存入 user_id 欄位
+		"created_at": time.Now().Unix(),                // 存入建立時間
+		"expires_at": time.Now().Add(time.Hour).Unix(), // 存入過期時間
+	}).Err()) // 預先寫入 session
+	tokenStr, err := jwtMgr.GenerateWithSession(userID, sessionID, time.Now().Add(time.Hour)) // 產生 token
+	require.NoError(t, err)                                                                   // 不應失敗
+
+	gin.SetMode(gin.TestMode)                       // 設定 Gin 為測試模式
+	r := gin.New()                                  // 建立新的 Gin Engine
+	r.Use(NewAuthJWTMiddleware(jwtMgr, sessSvc, 0)) // 掛上 JWT 驗證 middleware
+	r.GET("/me", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"shadow_banned": c.GetBool(ContextKeyShadowBanned)}) // 回傳 context 中的 flag
+	})
+	call := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil) // 準備請求
+		req.Header.Set("Authorization", "Bearer "+tokenStr)    // 帶入 token
+		w := httptest.NewRecorder()                            // 捕捉回應
+		r.ServeHTTP(w, req)                                    // 執行請求
+		return w
+	}
+
+	w := call()                                                   // 尚未 shadow ban
+	require.Equal(t, http.StatusOK, w.Code)                       // 應通過
+	require.JSONEq(t, `{"shadow_banned":false}`, w.Body.String()) // flag 為 false
+
+	require.NoError(t, sessSvc.ShadowBanUser(ctx, userID))       // shadow ban
+	w = call()                                                   // 再次呼叫
+	require.Equal(t, http.StatusOK, w.Code)                      // 仍應通過
+	require.JSONEq(t, `{"shadow_banned":true}`, w.Body.String()) // flag 為 true
+}
+
+// TestAuthJWTMiddleware_Audience 測試一般路由拒絕 admin audience 的 token，admin 路由拒絕一般使用者的 token。
+func TestAuthJWTMiddleware_Audience(t *testing.T) {
+	sessSvc, jwtMgr, mr, rdb := newTestSessionService(t) // 建立測試用 SessionService / JWT Manager / miniredis / Redis client
+	defer mr.Close()                                     // 測試結束時關閉 miniredis
+	defer rdb.Close()                                    // 測試結束時關閉 Redis client
+	jwtMgr.WithAudienceEnforcement()                     // 強制檢查 aud
+
+	ctx := context.Background() // 建立背景 context，用於 Redis 操作
+	userID := int64(100)        // 測試用 user ID
+	sessionID := "sid-aud"      // 測試用 session ID
+	require.NoError(t, rdb.HSet(ctx, infra.SessKey(sessionID), map[string]interface{}{
+		"user_id":    userID,                           // 存入 user_id 欄位
+		"created_at": time.Now().Unix(),                // 存入建立時間
+		"expires_at": time.Now().Add(time.Hour).Unix(), // 存入過期時間
+	}).Err()) // 預先寫入 session
+
+	exp := time.Now().Add(time.Hour)                                                                        // 共用的到期時間
+	userTok, err := jwtMgr.GenerateWithSession(userID, sessionID, exp)                                      // 一般使用者的 token
+	require.NoError(t, err)                                                                                 // 不應失敗
+	adminTok, err := jwtMgr.GenerateForAudience(userID, sessionID, token.AudienceAdmin, exp)                // admin audience 的 token
+	require.NoError(t, err)                                                                                 // 不應失敗
+	untagged, err := token.NewManager("test-secret", time.Hour).GenerateWithSession(userID, sessionID, exp) // 沒有 aud 的舊 token
+	require.NoError(t, err)                                                                                 // 不應失敗
+
+	gin.SetMode(gin.TestMode)                                                                  // 設定 Gin 為測試模式
+	r := gin.New()                                                                             // 建立新的 Gin Engine
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }                                     // 通過驗證時回 200
+	r.GET("/me", NewAuthJWTMiddleware(jwtMgr, sessSvc, 0), ok)                                 // 一般使用者路由
+	r.GET("/admin/me", NewAudienceAuthMiddleware(jwtMgr, sessSvc, 0, token.AudienceAdmin), ok) // admin 路由
+	call := func(path, tok string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil) // 準備請求
+		req.Header.Set("Authorization", "Bearer "+tok)        // 帶入 token
+		w := httptest.NewRecorder()                           // 捕捉回應
+		r.ServeHTTP(w, req)                                   // 執行請求
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, call("/me", userTok))                 // 一般 token 可呼叫一般路由
+	require.Equal(t, http.StatusUnauthorized, call("/me", adminTok))      // admin token 不能呼叫一般路由
+	require.Equal(t, http.StatusUnauthorized, call("/me", untagged))      // 沒有 aud 的 token 被拒絕
+	require.Equal(t, http.StatusOK, call("/admin/me", adminTok))          // admin token 可呼叫 admin 路由
+	require.Equal(t, http.StatusUnauthorized, call("/admin/me", userTok)) // 一般 token 不能呼叫 admin 路由
+}
+
+// TestAuthJWTMiddleware_OpaqueAudience 測試 TOKEN_MODE=opaque 時 reference token 只能呼叫一般使用者路由，admin audience 的路由一律拒絕。
+func TestAuthJWTMiddleware_OpaqueAudience(t *testing.T) {
+	mr, err := miniredis.Run() // 啟動記憶體內 Redis
+	require.NoError(t, err)    // 確保啟動成功
+	defer mr.Close()           // 測試結束時關閉 miniredis
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()}) // 連線到 miniredis
+	defer rdb.Close()                                       // 測試結束時關閉 Redis client
+
+	cfg := &config.Config{SessionTTL: time.Hour, MaxSessionsPerUser: 10, TokenMode: session.TokenModeOpaque} // 改用 reference token
+	sessSvc := session.NewSessionService(nil, rdb, cfg, nil, nil)                                            // 建立 SessionService
+	jwtMgr := token.NewManager("test-secret", time.Hour)                                                     // 建立 JWT Manager
+
+	ctx := context.Background() // 建立背景 context，用於 Redis 操作
+	userID := int64(100)        // 測試用 user ID
+	sessionID := "sid-opaque"   // 測試用 session ID
+	require.NoError(t, rdb.HSet(ctx, infra.SessKey(sessionID), map[string]interface{}{
+		"user_id":    userID,                           // 存入 user_id 欄位
+		"created_at": time.Now().Unix(),                // 存入建立時間
+		"expires_at": time.Now().Add(time.Hour).Unix(), // 存入過期時間
+	}).Err()) // 預先寫入 session
+
+	tok, err := sessSvc.IssueOpaqueToken(ctx, userID, sessionID, time.Now().Add(time.Hour)) // 簽發 reference token
+	require.NoError(t, err)                                                                 // 不應失敗
+
+	gin.SetMode(gin.TestMode)                                                                     // 設定 Gin 為測試模式
+	r := gin.New()                                                                                // 建立新的 Gin Engine
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }                                        // 通過驗證時回 200
+	r.GET("/me", NewAuthJWTMiddleware(jwtMgr, sessSvc, 1024), ok)                                 // 一般使用者路由
+	r.GET("/admin/me", NewAudienceAuthMiddleware(jwtMgr, sessSvc, 1024, token.AudienceAdmin), ok) // admin 路由
+	call := func(path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil) // 準備請求
+		req.Header.Set("Authorization", "Bearer "+tok)        // 帶入 reference token
+		w := httptest.NewRecorder()                           // 捕捉回應
+		r.ServeHTTP(w, req)                                   // 執行請求
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, call("/me"))                 // 可呼叫一般路由
+	require.Equal(t, http.StatusUnauthorized, call("/admin/me")) // 不能呼叫 admin 路由
+}
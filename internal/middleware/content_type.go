@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireJSONContentType 拒絕 body 不是 JSON 的請求並回 415，避免 Gin 的 binder 依 Content-Type 猜錯格式、默默解析成空值：
+// - GET / HEAD / OPTIONS 與沒有 body 的請求不檢查
+// - Content-Type 必須是 application/json 或 +json 結尾的型別
+// - formPaths 內的路由（以 Gin 的 FullPath 比對）另外接受 application/x-www-form-urlencoded
+func RequireJSONContentType(formPaths ...string) gin.HandlerFunc {
+	return RequireJSONContentTypeWithBeacon(formPaths, nil)
+}
+
+// RequireJSONContentTypeWithBeacon 與 RequireJSONContentType 相同，但 beaconPaths 內的路由另外接受 text/plain：
+// navigator.sendBeacon 送出字串 body 時 Content-Type 固定為 text/plain，無法改成 JSON，這類 body 由 handler 以 JSON 解析。
+func RequireJSONContentTypeWithBeacon(formPaths, beaconPaths []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength == 0 && len(c.Request.TransferEncoding) == 0 {
+			c.Next()
+			return
+		}
+
+		mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if err == nil {
+			if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+				c.Next()
+				return
+			}
+			if mediaType == "application/x-www-form-urlencoded" && slices.Contains(formPaths, c.FullPath()) {
+				c.Next()
+				return
+			}
+			if mediaType == "text/plain" && slices.Contains(beaconPaths, c.FullPath()) {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported_media_type"})
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"          // 匯入 net/http，提供 HTTP 方法與狀態碼常數
+	"net/http/httptest" // 匯入 httptest，建立 HTTP 測試請求
+	"strings"           // 匯入 strings，建立請求 body
+	"testing"           // 匯入 testing 套件，提供單元測試框架
+
+	"github.com/gin-gonic/gin"            // 匯入 gin，建立測試用路由
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// newContentTypeRouter 建立掛上 RequireJSONContentType 的路由，/form 額外接受 form-urlencoded。
+func newContentTypeRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)                    // 設定 Gin 為測試模式
+	r := gin.New()                               // 建立新的 Gin Engine
+	r.Use(RequireJSONContentType("/form"))       // 只有 /form 接受 form
+	ok := func(c *gin.Context) { c.Status(200) } // 通過時回 200
+	r.POST("/json", ok)                          // 只接受 JSON 的路由
+	r.POST("/form", ok)                          // 同時接受 form 的路由
+	r.GET("/json", ok)                           // GET 不檢查
+	return r
+}
+
+// doContentType 送出帶指定 Content-Type 與 body 的請求。
+func doContentType(r *gin.Engine, method, path, contentType, body string) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body)) // 建立請求
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType) // 設定 Content-Type
+	}
+	w := httptest.NewRecorder() // 建立 ResponseRecorder
+	r.ServeHTTP(w, req)         // 執行請求
+	return w.Code
+}
+
+// TestRequireJSONContentTypeRejects 測試缺少或錯誤的 Content-Type 回 415。
+func TestRequireJSONContentTypeRejects(t *testing.T) {
+	r := newContentTypeRouter() // 建立路由
+
+	require.Equal(t, http.StatusUnsupportedMediaType, doContentType(r, http.MethodPost, "/json", "", `{"a":1}`))                              // 沒有 Content-Type
+	require.Equal(t, http.StatusUnsupportedMediaType, doContentType(r, http.MethodPost, "/json", "text/plain", `{"a":1}`))                    // 錯誤的 Content-Type
+	require.Equal(t, http.StatusUnsupportedMediaType, doContentType(r, http.MethodPost, "/json", "application/x-www-form-urlencoded", "a=1")) // 非 form 路由不接受 form
+	require.Equal(t, http.StatusUnsupportedMediaType, doContentType(r, http.MethodPost, "/json", "application/json;;", `{"a":1}`))            // 無法解析的 Content-Type
+}
+
+// TestRequireJSONContentTypeAllows 測試 JSON、form 路由的 form、GET 與沒有 body 的請求都會放行。
+func TestRequireJSONContentTypeAllows(t *testing.T) {
+	r := newContentTypeRouter() // 建立路由
+
+	require.Equal(t, http.StatusOK, doContentType(r, http.MethodPost, "/json", "application/json; charset=utf-8", `{"a":1}`)) // 帶 charset 的 JSON
+	require.Equal(t, http.StatusOK, doContentType(r, http.MethodPost, "/json", "application/merge-patch+json", `{"a":1}`))    // +json 型別
+	require.Equal(t, http.StatusOK, doContentType(r, http.MethodPost, "/form", "application/x-www-form-urlencoded", "a=1"))   // form 路由接受 form
+	require.Equal(t, http.StatusOK, doContentType(r, http.MethodGet, "/json", "", ""))                                        // GET 不檢查
+	require.Equal(t, http.StatusOK, doContentType(r, http.MethodPost, "/json", "", ""))                                       // 沒有 body 的 POST 不檢查
+}
+
+// TestRequireJSONContentTypeBeaconPaths 測試 beaconPaths 內的路由接受 sendBeacon 的 text/plain，其他路由仍回 415。
+func TestRequireJSONContentTypeBeaconPaths(t *testing.T) {
+	gin.SetMode(gin.TestMode)                                                         // 設定 Gin 為測試模式
+	r := gin.New()                                                                    // 建立新的 Gin Engine
+	r.Use(RequireJSONContentTypeWithBeacon([]string{"/beacon"}, []string{"/beacon"})) // /beacon 接受 form 與 text/plain
+	ok := func(c *gin.Context) { c.Status(200) }                                      // 通過時回 200
+	r.POST("/json", ok)                                                               // 只接受 JSON 的路由
+	r.POST("/beacon", ok)                                                             // sendBeacon 使用的路由
+
+	require.Equal(t, http.StatusOK, doContentType(r, http.MethodPost, "/beacon", "text/plain;charset=UTF-8", `{"token":"x"}`))    // sendBeacon 字串 body
+	require.Equal(t, http.StatusOK, doContentType(r, http.MethodPost, "/beacon", "application/x-www-form-urlencoded", "token=x")) // sendBeacon URLSearchParams
+	require.Equal(t, http.StatusUnsupportedMediaType, doContentType(r, http.MethodPost, "/json", "text/plain", `{"a":1}`))        // 其他路由仍拒絕
+	require.Equal(t, http.StatusUnsupportedMediaType, doContentType(r, http.MethodPost, "/beacon", "text/html", "<p>"))           // 其他型別仍拒絕
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPMetrics 記錄每個 HTTP 請求的結果與耗時，由 metrics.Prometheus 實作。
+type HTTPMetrics interface {
+	ObserveHTTPRequest(route, method string, status int, d time.Duration)
+}
+
+// UnmatchedRoute 是沒有對應路由（404、405）的請求使用的 route label。
+const UnmatchedRoute = "unmatched"
+
+// metricMethods 是會原樣作為 method label 的 HTTP method，其他值一律記為 OTHER。
+var metricMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// HTTPRequestMetrics 在請求結束後記錄指標，route label 使用 Gin 的路由樣板（例如 /admin/users/:id/ban）而不是實際路徑，
+// 路徑參數不會讓 series 無限增加。沒有對應路由的請求一律記為 unmatched、非標準的 method 記為 OTHER，
+// label 值因此只會落在已註冊的路由集合內，掃描大量隨機路徑也不會撐爆 Prometheus。
+func HTTPRequestMetrics(m HTTPMetrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = UnmatchedRoute
+		}
+		method := c.Request.Method
+		if !metricMethods[method] {
+			method = "OTHER"
+		}
+		m.ObserveHTTPRequest(route, method, c.Writer.Status(), time.Since(start))
+	}
+}
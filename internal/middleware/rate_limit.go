@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+// NewRateLimitMiddleware 以 client IP 為單位，在 window 內最多放行 limit 次請求。
+// limit <= 0 代表不限制；Redis 發生錯誤時放行請求，避免 rate limiter 本身變成單點故障。
+func NewRateLimitMiddleware(rdb *redis.Client, scope string, limit int, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		key := infra.RateLimitKey(scope, c.ClientIP())
+		ok, retryAfter, err := infra.AllowRate(c.Request.Context(), rdb, key, limit, window)
+		if err != nil {
+			infra.LogError("rate limit %s: redis error: %v", scope, err)
+			c.Next()
+			return
+		}
+		if !ok {
+			AbortWithRetryAfter(c, http.StatusTooManyRequests, "rate_limited", retryAfter)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"          // 匯入 net/http，提供 HTTP 方法與狀態碼常數
+	"net/http/httptest" // 匯入 httptest，建立 HTTP 測試請求
+	"testing"           // 匯入 testing 套件，提供單元測試框架
+
+	"github.com/gin-gonic/gin"            // 匯入 gin，建立測試用路由
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// testRedirectAllowList 是測試用的跳轉 allow-list。
+var testRedirectAllowList = []string{
+	"https://app.example.com/oauth/callback", // 完全相同才放行
+	"https://app.example.com/account/*",      // 前綴比對
+	"/dashboard/*",                           // 同站相對路徑
+}
+
+// TestRedirectAllowed 測試完全相同、前綴比對與各種 open redirect 手法。
+func TestRedirectAllowed(t *testing.T) {
+	cases := []struct {
+		target string // 跳轉目標
+		want   bool   // 是否應放行
+	}{
+		{"https://app.example.com/oauth/callback", true},      // 完全相同
+		{"https://app.example.com/oauth/callback?x=1", false}, // 完全相同的項目不接受額外 query
+		{"https://app.example.com/account/settings", true},    // 前綴之下
+		{"https://APP.example.com/account/profile", true},     // host 大小寫不影響
+		{"https://app.example.com/accounting", false},         // 不在同一層目錄之下
+		{"http://app.example.com/account/settings", false},    // scheme 不同
+		{"https://app.example.com.evil.com/account/x", false}, // host 後綴攻擊
+		{"https://app.example.com@evil.com/account/x", false}, // 帶帳密的 host 混淆
+		{"/dashboard/home", true},                             // 同站相對路徑
+		{"//evil.com/dashboard/home", false},                  // protocol-relative URL
+		{"/\\evil.com", false},                                // 反斜線
+		{"javascript:alert(1)", false},                        // 非 http 的 scheme
+		{"dashboard/home", false},                             // 不以 / 開頭的相對路徑
+		{"https://app.example.com/account/\nx", false},        // 控制字元
+		{"", false}, // 空字串
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.want, RedirectAllowed(tc.target, testRedirectAllowList), tc.target) // 逐一比對
+	}
+
+	require.False(t, RedirectAllowed("/dashboard/home", nil)) // allow-list 為空時全部拒絕
+}
+
+// TestValidateRedirectParams 測試 query 帶有不允許的跳轉目標時回 400，其餘請求照常通過。
+func TestValidateRedirectParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)                                            // 設定 Gin 為測試模式
+	r := gin.New()                                                       // 建立新的 Gin Engine
+	r.Use(ValidateRedirectParams(testRedirectAllowList))                 // 掛上跳轉檢查
+	r.GET("/callback", func(c *gin.Context) { c.Status(http.StatusOK) }) // 通過時回 200
+
+	do := func(path string) int {
+		w := httptest.NewRecorder()                                    // 建立 ResponseRecorder
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil)) // 執行請求
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, do("/callback"))                                                             // 沒有帶跳轉參數
+	require.Equal(t, http.StatusOK, do("/callback?return_to=%2Fdashboard%2Fhome"))                               // 允許的目標
+	require.Equal(t, http.StatusBadRequest, do("/callback?redirect_uri=https%3A%2F%2Fevil.com%2F"))              // 不允許的目標
+	require.Equal(t, http.StatusBadRequest, do("/callback?return_to=%2Fdashboard%2Fa&return_to=%2F%2Fevil.com")) // 任一值不允許即拒絕
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"sessionservice/internal/infra"
+)
+
+const (
+	// RequestIDHeader 是 client 或上游 proxy 帶入、以及回應中回傳 request ID 的 header。
+	RequestIDHeader = "X-Request-ID"
+	// ContextKeyRequestID 存放目前請求的 request ID（string）。
+	ContextKeyRequestID = "requestID"
+
+	// maxRequestIDLength 是接受外部帶入 request ID 的最大長度，超過或含有其他字元時改為自行產生。
+	maxRequestIDLength = 128
+)
+
+// RequestID 為每個請求決定一個 request ID：沿用合法的 X-Request-ID，否則產生 UUID。
+// ID 會寫回回應 header、Gin context 與 request context，之後排入的 asynq 任務會帶著它交給 worker。
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set(ContextKeyRequestID, id)
+		c.Request = c.Request.WithContext(infra.WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// validRequestID 只接受英數字與 - _ . : 組成的 ID，避免把任意內容寫進 log 與任務 payload。
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
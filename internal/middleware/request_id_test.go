@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"          // 匯入 net/http，提供 HTTP 方法與狀態碼常數
+	"net/http/httptest" // 匯入 httptest，建立 HTTP 測試請求
+	"strings"           // 匯入 strings，組出過長的 request ID
+	"testing"           // 匯入 testing 套件，提供單元測試框架
+
+	"github.com/gin-gonic/gin"            // 匯入 gin，建立測試用路由
+	"github.com/google/uuid"              // 匯入 uuid，確認自行產生的 ID 格式
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra 套件，讀取 request context 中的 ID
+)
+
+// TestRequestID 測試合法的 X-Request-ID 原樣沿用，缺少或不合法時改為產生 UUID，並寫入回應與 request context。
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode) // 設定 Gin 為測試模式
+	r := gin.New()            // 建立新的 Gin Engine
+	r.Use(RequestID())        // 掛上 request ID middleware
+
+	var fromCtx, fromGin string // 記錄 handler 看到的 request ID
+	r.GET("/ping", func(c *gin.Context) {
+		fromCtx = infra.RequestIDFromContext(c.Request.Context()) // 從 request context 讀取
+		fromGin = c.GetString(ContextKeyRequestID)                // 從 Gin context 讀取
+		c.Status(http.StatusNoContent)
+	})
+
+	do := func(header string) string {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil) // 建立請求
+		if header != "" {
+			req.Header.Set(RequestIDHeader, header) // 帶入 request ID
+		}
+		w := httptest.NewRecorder()                                // 建立 ResponseRecorder
+		r.ServeHTTP(w, req)                                        // 執行請求
+		require.Equal(t, fromCtx, fromGin)                         // 兩處的 ID 應相同
+		require.Equal(t, fromCtx, w.Header().Get(RequestIDHeader)) // 回應 header 帶回同一個 ID
+		return fromCtx
+	}
+
+	require.Equal(t, "req-123_abc.1:2", do("req-123_abc.1:2")) // 合法 ID 原樣沿用
+
+	for _, bad := range []string{"", "has space", "bad\"quote", strings.Repeat("a", 129)} {
+		id := do(bad)                       // 缺少或不合法的 ID
+		_, err := uuid.Parse(id)            // 應改為自行產生
+		require.NoErrorf(t, err, "%q", bad) // 產生的 ID 為 UUID
+	}
+}
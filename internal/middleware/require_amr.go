@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAMR 建立一個 Gin middleware，要求 token 的 amr claim 包含所有指定的驗證方式（例如 "otp"），
+// 否則回 403，讓 client 知道需要以更強的方式重新登入。必須掛在 NewAuthJWTMiddleware 之後。
+func RequireAMR(methods ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		val, _ := c.Get(ContextKeyAMR)
+		amr, _ := val.([]string)
+		for _, m := range methods {
+			if !slices.Contains(amr, m) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error":        "insufficient_authentication",
+					"required_amr": methods,
+				})
+				return
+			}
+		}
+		c.Next()
+	}
+}
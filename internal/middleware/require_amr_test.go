@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"           // 匯入 context，寫入測試用 Redis session
+	"net/http"          // 匯入 net/http，提供 HTTP 方法與狀態碼常數
+	"net/http/httptest" // 匯入 httptest，建立 HTTP 測試請求
+	"testing"           // 匯入 testing 套件，提供單元測試框架
+	"time"              // 匯入 time，設定 session 與 JWT 過期時間
+
+	"github.com/gin-gonic/gin"            // 匯入 gin，建立測試用路由
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra 套件，產生 Redis key
+	"sessionservice/internal/token" // 匯入 token 套件，使用 amr 常數
+)
+
+// TestRequireAMR 測試 RequireAMR 只放行 amr claim 含有指定驗證方式的 token。
+func TestRequireAMR(t *testing.T) {
+	sessSvc, jwtMgr, mr, rdb := newTestSessionService(t) // 建立測試用 SessionService 與 JWT Manager
+	defer mr.Close()                                     // 測試結束時關閉 miniredis
+	defer rdb.Close()                                    // 測試結束時關閉 Redis client
+
+	gin.SetMode(gin.TestMode)                                            // 設定 Gin 為測試模式
+	r := gin.New()                                                       // 建立新的 Gin Engine
+	r.Use(NewAuthJWTMiddleware(jwtMgr, sessSvc, 0))                      // 先驗證 JWT
+	r.GET("/sensitive", RequireAMR(token.AMROTP), func(c *gin.Context) { // 需要 MFA 的路由
+		amr, _ := c.Get(ContextKeyAMR)           // 從 context 取出 amr
+		c.JSON(http.StatusOK, gin.H{"amr": amr}) // 回傳給測試檢查
+	})
+
+	ctx := context.Background() // 背景 context
+	call := func(sid string, amr ...string) *httptest.ResponseRecorder {
+		expiresAt := time.Now().Add(time.Hour)                                                                    // session 與 token 的到期時間
+		require.NoError(t, rdb.HSet(ctx, infra.SessKey(sid), "user_id", 1, "expires_at", expiresAt.Unix()).Err()) // 寫入 session
+		tok, err := jwtMgr.GenerateWithSession(1, sid, expiresAt, amr...)                                         // 產生帶 amr 的 token
+		require.NoError(t, err)                                                                                   // 不應失敗
+		req := httptest.NewRequest(http.MethodGet, "/sensitive", nil)                                             // 呼叫受保護路由
+		req.Header.Set("Authorization", "Bearer "+tok)                                                            // 帶上 token
+		w := httptest.NewRecorder()                                                                               // 建立 ResponseRecorder
+		r.ServeHTTP(w, req)                                                                                       // 執行請求
+		return w
+	}
+
+	w := call("sid-mfa", token.AMRPassword, token.AMROTP)       // 密碼 + OTP 登入
+	require.Equal(t, http.StatusOK, w.Code)                     // 應放行
+	require.JSONEq(t, `{"amr":["pwd","otp"]}`, w.Body.String()) // context 內有完整 amr
+
+	w = call("sid-pwd", token.AMRPassword)                                                               // 只有密碼登入
+	require.Equal(t, http.StatusForbidden, w.Code)                                                       // 應回 403
+	require.JSONEq(t, `{"error":"insufficient_authentication","required_amr":["otp"]}`, w.Body.String()) // 指出缺少的驗證方式
+
+	w = call("sid-none")                           // 沒有 amr claim 的舊 token
+	require.Equal(t, http.StatusForbidden, w.Code) // 同樣應回 403
+}
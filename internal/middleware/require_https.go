@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParseTrustedProxies 解析 TRUSTED_PROXIES 的項目（單一 IP 或 CIDR），格式與 gin.Engine.SetTrustedProxies 相同。
+func ParseTrustedProxies(entries []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: entry}
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, cidr)
+	}
+	return nets, nil
+}
+
+// RequireHTTPS 拒絕不是經由 HTTPS 抵達的請求並回 403，避免 TLS 終止在 proxy 時因設定錯誤讓 token 以明文傳輸：
+// - 直接以 TLS 連線的請求一律通過
+// - 對端位址在 trusted 之內時，以 X-Forwarded-Proto 的第一個值判斷；不受信任的來源送來的 header 一律忽略
+// - exemptPaths 內的路由（以 Gin 的 FullPath 比對，例如 /health、/ready）不檢查，讓探針可直接以 HTTP 連線
+//
+// 不改以 redirect 處理：請求抵達時 token 已經以明文送出，轉址只會掩蓋設定錯誤。
+func RequireHTTPS(trusted []*net.IPNet, exemptPaths ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.TLS != nil || slices.Contains(exemptPaths, c.FullPath()) {
+			c.Next()
+			return
+		}
+		if forwardedHTTPS(c, trusted) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "https_required"})
+	}
+}
+
+// forwardedHTTPS 回傳請求是否來自受信任的 proxy，且 proxy 標示原始請求為 HTTPS。
+func forwardedHTTPS(c *gin.Context, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(c.RemoteIP())
+	if ip == nil || !slices.ContainsFunc(trusted, func(n *net.IPNet) bool { return n.Contains(ip) }) {
+		return false
+	}
+	proto, _, _ := strings.Cut(c.GetHeader("X-Forwarded-Proto"), ",")
+	return strings.EqualFold(strings.TrimSpace(proto), "https")
+}
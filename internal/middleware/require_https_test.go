@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"crypto/tls"        // 匯入 crypto/tls，模擬直接以 TLS 抵達的請求
+	"net/http"          // 匯入 net/http，提供 HTTP 方法與狀態碼常數
+	"net/http/httptest" // 匯入 httptest，建立 HTTP 測試請求
+	"testing"           // 匯入 testing 套件，提供單元測試框架
+
+	"github.com/gin-gonic/gin"            // 匯入 gin，建立測試用路由
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// TestRequireHTTPS 測試受信任 proxy 標示 http 時回 403、https 時通過，以及不受信任來源的 header 被忽略。
+func TestRequireHTTPS(t *testing.T) {
+	gin.SetMode(gin.TestMode)                                          // 設定 Gin 為測試模式
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8"})        // 只信任內網的 proxy
+	require.NoError(t, err)                                            // 解析應成功
+	r := gin.New()                                                     // 建立新的 Gin Engine
+	r.Use(RequireHTTPS(trusted, "/health"))                            // 掛上 HTTPS 檢查，/health 例外
+	r.GET("/me", func(c *gin.Context) { c.Status(http.StatusOK) })     // 一般 API 路由
+	r.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) }) // 探針路由
+
+	do := func(path, remote, proto string, viaTLS bool) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil) // 建立請求
+		req.RemoteAddr = remote                               // 設定對端位址
+		if proto != "" {
+			req.Header.Set("X-Forwarded-Proto", proto) // proxy 標示的原始協定
+		}
+		if viaTLS {
+			req.TLS = &tls.ConnectionState{} // 直接以 TLS 連線
+		}
+		w := httptest.NewRecorder() // 建立 ResponseRecorder
+		r.ServeHTTP(w, req)         // 執行請求
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusForbidden, do("/me", "10.0.0.5:1234", "http", false))     // 受信任 proxy 標示 http 時拒絕
+	require.Equal(t, http.StatusOK, do("/me", "10.0.0.5:1234", "https", false))           // 受信任 proxy 標示 https 時通過
+	require.Equal(t, http.StatusOK, do("/me", "10.0.0.5:1234", "HTTPS, http", false))     // 多層 proxy 時看第一個值
+	require.Equal(t, http.StatusForbidden, do("/me", "10.0.0.5:1234", "", false))         // 沒有 header 視為 HTTP
+	require.Equal(t, http.StatusForbidden, do("/me", "203.0.113.9:1234", "https", false)) // 不受信任來源偽造的 header 被忽略
+	require.Equal(t, http.StatusOK, do("/me", "203.0.113.9:1234", "", true))              // 直接以 TLS 連線時通過
+	require.Equal(t, http.StatusOK, do("/health", "203.0.113.9:1234", "", false))         // 探針路由不檢查
+}
+
+// TestParseTrustedProxies 測試單一 IP、CIDR 與格式錯誤的項目。
+func TestParseTrustedProxies(t *testing.T) {
+	nets, err := ParseTrustedProxies([]string{"192.168.1.10", "10.0.0.0/8", "::1"}) // IP、CIDR 與 IPv6 混用
+	require.NoError(t, err)                                                         // 應成功
+	require.Len(t, nets, 3)                                                         // 每個項目一個網段
+	require.Equal(t, "192.168.1.10/32", nets[0].String())                           // 單一 IPv4 視為 /32
+	require.Equal(t, "::1/128", nets[2].String())                                   // 單一 IPv6 視為 /128
+
+	_, err = ParseTrustedProxies([]string{"not-an-ip"}) // 格式錯誤
+	require.Error(t, err)                               // 應回傳錯誤
+}
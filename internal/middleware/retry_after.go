@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AbortWithRetryAfter 是所有限流 / 卸載回應（429、503）的共用出口：
+// 同時設定 Retry-After header 與 body 的 retry_after_seconds，讓 client 用同一套邏輯退避。
+func AbortWithRetryAfter(c *gin.Context, status int, code string, d time.Duration) {
+	secs := writeRetryAfter(c, d)
+	c.AbortWithStatusJSON(status, gin.H{
+		"error":               code,
+		"retry_after_seconds": secs,
+	})
+}
+
+// writeRetryAfter 設定 Retry-After header 並回傳實際寫入的秒數。
+// d 無條件進位成秒且最少 1 秒，Retry-After: 0 會讓 client 立即重試。
+func writeRetryAfter(c *gin.Context, d time.Duration) int {
+	secs := int(math.Ceil(d.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(secs))
+	return secs
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"encoding/json"     // 匯入 encoding/json，解析回應 body
+	"net/http"          // 匯入 net/http，提供 HTTP 方法與狀態碼常數
+	"net/http/httptest" // 匯入 httptest，建立 HTTP 測試請求
+	"strconv"           // 匯入 strconv，將秒數轉成 header 字串
+	"testing"           // 匯入 testing 套件，提供單元測試框架
+	"time"              // 匯入 time，設定視窗與 timeout
+
+	"github.com/alicebob/miniredis/v2"    // 匯入 miniredis，提供記憶體內 Redis 測試實例
+	"github.com/gin-gonic/gin"            // 匯入 gin，建立測試用路由
+	"github.com/redis/go-redis/v9"        // 匯入 go-redis，用於連線到 miniredis
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// TestThrottledResponsesSetRetryAfter 針對每一種限流 / 卸載回應，確認 Retry-After header 與 retry_after_seconds 一致。
+func TestThrottledResponsesSetRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode) // 設定 Gin 為測試模式
+
+	mr, err := miniredis.Run() // 啟動記憶體內 Redis
+	require.NoError(t, err)    // 確保啟動成功
+	t.Cleanup(mr.Close)        // 測試結束時關閉
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()}) // 連線到 miniredis
+	t.Cleanup(func() { _ = rdb.Close() })                   // 測試結束時關閉
+
+	cases := []struct {
+		name       string          // 限流類型
+		middleware gin.HandlerFunc // 被測試的 middleware
+		warmup     int             // 觸發限流前先送出的請求數
+		status     int             // 預期狀態碼
+		code       string          // 預期錯誤碼
+		retryAfter int             // 預期 Retry-After 秒數
+	}{
+		{
+			name:       "rate_limit",
+			middleware: NewRateLimitMiddleware(rdb, "test", 1, 30*time.Second),
+			warmup:     1,
+			status:     http.StatusTooManyRequests,
+			code:       "rate_limited",
+			retryAfter: 30,
+		},
+		{
+			name:       "request_timeout",
+			middleware: Timeout(1500 * time.Millisecond),
+			status:     http.StatusServiceUnavailable,
+			code:       "request_timeout",
+			retryAfter: 2,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := gin.New()       // 每種類型各自一個 Engine
+			r.Use(tc.middleware) // 掛上被測試的 middleware
+			r.GET("/x", func(c *gin.Context) {
+				if tc.code == "request_timeout" {
+					<-c.Request.Context().Done() // 模擬卡住直到超時的下游呼叫
+				}
+				c.JSON(http.StatusOK, gin.H{"ok": true})
+			})
+
+			var w *httptest.ResponseRecorder
+			for i := 0; i <= tc.warmup; i++ { // 最後一次請求應被限流
+				w = httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/x", nil))
+			}
+
+			require.Equal(t, tc.status, w.Code) // 狀態碼正確
+
+			var body struct {
+				Error             string `json:"error"`
+				RetryAfterSeconds int    `json:"retry_after_seconds"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))                    // body 應為合法 JSON
+			require.Equal(t, tc.code, body.Error)                                        // 錯誤碼正確
+			require.Equal(t, tc.retryAfter, body.RetryAfterSeconds)                      // body 秒數正確
+			require.Equal(t, strconv.Itoa(tc.retryAfter), w.Header().Get("Retry-After")) // header 與 body 一致
+		})
+	}
+}
+
+// TestWriteRetryAfterRoundsUp 測試秒數會無條件進位，且最少為 1 秒。
+func TestWriteRetryAfterRoundsUp(t *testing.T) {
+	gin.SetMode(gin.TestMode) // 設定 Gin 為測試模式
+
+	for d, want := range map[time.Duration]int{
+		0:                       1,  // 0 不應讓 client 立即重試
+		200 * time.Millisecond:  1,  // 不足 1 秒進位為 1
+		time.Second:             1,  // 整秒不變
+		1001 * time.Millisecond: 2,  // 超過一點點也進位
+		time.Minute:             60, // 長時間
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())                      // 建立測試用 Context
+		require.Equal(t, want, writeRetryAfter(c, d))                              // 回傳值正確
+		require.Equal(t, strconv.Itoa(want), c.Writer.Header().Get("Retry-After")) // header 正確
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderOff 作為 header 的設定值時代表不送出該 header（環境變數無法以空字串覆寫預設值）。
+const HeaderOff = "off"
+
+// SecurityHeaders 在每個回應加上指定的安全性 header（例如 X-Content-Type-Options、Strict-Transport-Security），
+// 值為空字串或 off 的 header 不送出。header 在 handler 執行前寫入，錯誤回應與 404 同樣會帶上。
+func SecurityHeaders(headers map[string]string) gin.HandlerFunc {
+	enabled := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if value == "" || strings.EqualFold(value, HeaderOff) {
+			continue
+		}
+		enabled[name] = value
+	}
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		for name, value := range enabled {
+			h.Set(name, value)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout 為每個請求套上 d 的 deadline：
+// - 以帶 deadline 的 child context 取代 request context，Redis / DB 等下游呼叫會一併被取消
+// - handler 的輸出先寫進 buffer，超時後改回 503 request_timeout，避免送出寫到一半的回應
+// d <= 0 代表不限制；exemptPaths 內的路由（以 Gin 的 FullPath 比對，例如 SSE 串流）與帶 Accept: text/event-stream 的請求不套用，
+// 串流路由不能只靠 Accept 判斷：沒帶這個 header 的 client（curl、fetch）仍會連上串流，輸出被 buffer 住後直到超時才回 503。
+//
+// 限制：503 在 handler 返回後才寫出。handler 不理會 ctx（例如不帶 ctx 的阻塞呼叫）時，連線會被占用到 handler 自己結束，
+// 只是最後的回應換成 503；不另開 goroutine 與 handler 競速，因為 gin.Context 在 handler 結束前不能交還給其他 goroutine 使用。
+func Timeout(d time.Duration, exemptPaths ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d <= 0 || slices.Contains(exemptPaths, c.FullPath()) || isStreamingRequest(c.Request) {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		w := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// 超時多半代表下游過載，請 client 至少等一個 timeout 週期再重試
+			AbortWithRetryAfter(c, http.StatusServiceUnavailable, "request_timeout", d)
+			return
+		}
+		w.flush()
+	}
+}
+
+func isStreamingRequest(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// bufferedWriter 暫存 handler 的狀態碼與 body，等確定沒有超時再寫到真正的 ResponseWriter。
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body    bytes.Buffer
+	status  int
+	written bool
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if !w.written {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
+
+// flush 將暫存的回應寫到真正的 ResponseWriter。
+func (w *bufferedWriter) flush() {
+	if !w.written {
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"           // 匯入 context，檢查 handler 的 context 是否被取消
+	"net/http"          // 匯入 net/http，提供 HTTP 方法與狀態碼常數
+	"net/http/httptest" // 匯入 httptest，建立 HTTP 測試請求
+	"testing"           // 匯入 testing 套件，提供單元測試框架
+	"time"              // 匯入 time，設定 timeout 與模擬慢速 handler
+
+	"github.com/gin-gonic/gin"            // 匯入 gin，建立測試用路由
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// TestTimeoutSlowHandler 測試 handler 超過時限時回傳 503 request_timeout，且 handler 的 context 已被取消。
+func TestTimeoutSlowHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)             // 設定 Gin 為測試模式
+	r := gin.New()                        // 建立新的 Gin Engine
+	r.Use(Timeout(20 * time.Millisecond)) // 掛上 20ms 的 timeout
+
+	var handlerErr error // 記錄 handler 看到的 context 錯誤
+	r.GET("/slow", func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done(): // 模擬會尊重 context 的下游呼叫（Redis / DB）
+			handlerErr = c.Request.Context().Err()
+		case <-time.After(time.Second): // 若沒被取消就會拖很久
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "downstream failed"}) // 下游失敗後 handler 照常寫回應
+	})
+
+	w := httptest.NewRecorder()                                       // 建立 ResponseRecorder
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil)) // 執行請求
+
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)                                   // 應回 503
+	require.JSONEq(t, `{"error":"request_timeout","retry_after_seconds":1}`, w.Body.String()) // handler 的回應應被丟棄
+	require.ErrorIs(t, handlerErr, context.DeadlineExceeded)                                  // handler 的 context 應已因 deadline 取消
+}
+
+// TestTimeoutFastHandler 測試在時限內完成的 handler 回應不受影響。
+func TestTimeoutFastHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)   // 設定 Gin 為測試模式
+	r := gin.New()              // 建立新的 Gin Engine
+	r.Use(Timeout(time.Second)) // 掛上 1 秒的 timeout
+	r.GET("/fast", func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"ok": true}) // 立即回應
+	})
+
+	w := httptest.NewRecorder()                                       // 建立 ResponseRecorder
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil)) // 執行請求
+
+	require.Equal(t, http.StatusCreated, w.Code)      // 狀態碼應原樣保留
+	require.JSONEq(t, `{"ok":true}`, w.Body.String()) // body 應原樣保留
+}
+
+// TestTimeoutExemptPath 測試 exemptPaths 內的串流路由即使沒帶 Accept: text/event-stream 也不套用 timeout，輸出直接寫出。
+func TestTimeoutExemptPath(t *testing.T) {
+	gin.SetMode(gin.TestMode)                      // 設定 Gin 為測試模式
+	r := gin.New()                                 // 建立新的 Gin Engine
+	r.Use(Timeout(20*time.Millisecond, "/stream")) // 掛上 20ms 的 timeout，排除 /stream
+	var ctxErr error                               // 記錄 handler 結束時的 context 錯誤
+	r.GET("/stream", func(c *gin.Context) {
+		c.String(http.StatusOK, "data: first\n\n") // 第一個事件
+		c.Writer.Flush()                           // 立即送出
+		time.Sleep(50 * time.Millisecond)          // 超過 timeout 仍在串流
+		ctxErr = c.Request.Context().Err()         // context 不應被 deadline 取消
+	})
+
+	w := httptest.NewRecorder()                                         // 建立 ResponseRecorder
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil)) // 不帶 Accept header（例如 curl）
+
+	require.Equal(t, http.StatusOK, w.Code)              // 不應被改成 503
+	require.Equal(t, "data: first\n\n", w.Body.String()) // 輸出未被丟棄
+	require.True(t, w.Flushed)                           // Flush 直接送到真正的 ResponseWriter
+	require.NoError(t, ctxErr)                           // 沒有套上 deadline
+}
+
+// TestTimeoutHandlerIgnoringContext 測試 handler 不理會 ctx 時，503 要等 handler 返回後才寫出（文件所述的限制）。
+func TestTimeoutHandlerIgnoringContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)             // 設定 Gin 為測試模式
+	r := gin.New()                        // 建立新的 Gin Engine
+	r.Use(Timeout(20 * time.Millisecond)) // 掛上 20ms 的 timeout
+	r.GET("/blocking", func(c *gin.Context) {
+		time.Sleep(100 * time.Millisecond)       // 不帶 ctx 的阻塞呼叫
+		c.JSON(http.StatusOK, gin.H{"ok": true}) // 結束後照常寫回應
+	})
+
+	start := time.Now()                                                   // 記錄開始時間
+	w := httptest.NewRecorder()                                           // 建立 ResponseRecorder
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/blocking", nil)) // 執行請求
+
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond) // 連線占用到 handler 結束
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)            // 最後的回應仍換成 503
+	require.Contains(t, w.Body.String(), "request_timeout")            // handler 的回應被丟棄
+}
@@ -0,0 +1,34 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"sessionservice/internal/db"
+	"sessionservice/internal/infra"
+)
+
+// AccountSummary 是登入回應可選擇附上的帳號摘要（例如 app 登入後顯示「N 台裝置，上次登入 X」）。
+type AccountSummary struct {
+	ActiveSessions int
+	LastLoginAt    *time.Time // 從未登入過時為 nil
+	LastLoginIP    string     // 從未登入過或沒有記錄 IP 時為空
+}
+
+// AccountSummary 以 user_sess:{uid} 計算目前的 session 數（含剛建立的 session），上次登入時間與 IP 取自 user row。
+// u 應為 Login 回傳、建立本次 session 前讀出的 user：last_login_* 由 worker 處理 login:audit 後才更新，因此代表上一次登入。
+func (s *SessionService) AccountSummary(ctx context.Context, u db.User) (AccountSummary, error) {
+	active, err := s.rdb.ZCard(ctx, infra.UserSessKey(u.ID)).Result()
+	if err != nil {
+		return AccountSummary{}, err
+	}
+	summary := AccountSummary{ActiveSessions: int(active)}
+	if u.LastLoginAt.Valid {
+		at := u.LastLoginAt.Time
+		summary.LastLoginAt = &at
+	}
+	if u.LastLoginIp.Valid {
+		summary.LastLoginIP = u.LastLoginIp.String
+	}
+	return summary, nil
+}
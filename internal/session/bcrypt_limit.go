@@ -0,0 +1,65 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrPasswordCheckBusy 表示同時進行的 bcrypt 比對已達 BcryptMaxConcurrency，且排隊人數過多或等候逾時；handler 回 503。
+var ErrPasswordCheckBusy = errors.New("too many concurrent password checks")
+
+// bcryptLimiter 限制同時進行的 bcrypt 比對數量，避免大量登入吃滿所有 CPU、拖慢其他請求。
+// 超過上限的請求短暫排隊，排隊人數超過 maxQueue 時立即拒絕，等候超過 maxWait 時同樣拒絕。
+// nil 代表不限制。
+type bcryptLimiter struct {
+	slots    chan struct{}
+	waiting  atomic.Int64
+	maxQueue int64
+	maxWait  time.Duration
+}
+
+func newBcryptLimiter(maxConcurrency, maxQueue int, maxWait time.Duration) *bcryptLimiter {
+	if maxConcurrency <= 0 {
+		return nil
+	}
+	return &bcryptLimiter{
+		slots:    make(chan struct{}, maxConcurrency),
+		maxQueue: int64(maxQueue),
+		maxWait:  maxWait,
+	}
+}
+
+// acquire 取得一個比對名額，成功時回傳的 release 必須在比對結束後呼叫。
+func (l *bcryptLimiter) acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	default:
+	}
+
+	if l.waiting.Add(1) > l.maxQueue {
+		l.waiting.Add(-1)
+		return nil, ErrPasswordCheckBusy
+	}
+	defer l.waiting.Add(-1)
+
+	timer := time.NewTimer(l.maxWait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	case <-timer.C:
+		return nil, ErrPasswordCheckBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *bcryptLimiter) release() {
+	<-l.slots
+}
@@ -0,0 +1,76 @@
+package session
+
+import (
+	"context"     // 匯入 context，取得比對名額
+	"sync"        // 匯入 sync，同時發出多個比對
+	"sync/atomic" // 匯入 sync/atomic，安全地記錄同時進行的數量
+	"testing"     // 匯入 testing，提供單元測試框架
+	"time"        // 匯入 time，模擬比對耗時與設定等候上限
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+	"golang.org/x/crypto/bcrypt"          // 匯入 bcrypt，產生低 cost 的雜湊
+)
+
+// TestBcryptLimiterBoundsConcurrency 測試同時取得名額的數量不超過上限，排隊的請求最後都能完成。
+func TestBcryptLimiterBoundsConcurrency(t *testing.T) {
+	l := newBcryptLimiter(2, 16, time.Second) // 最多 2 個同時比對，16 個排隊
+
+	var running, peak atomic.Int64 // 目前與最高的同時進行數量
+	var wg sync.WaitGroup          // 等待所有 goroutine 結束
+	for i := 0; i < 10; i++ {      // 10 個同時進來的登入
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.acquire(context.Background()) // 取得名額
+			require.NoError(t, err)                         // 排隊後都應取得
+			n := running.Add(1)                             // 開始比對
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break // 更新最高值
+				}
+			}
+			time.Sleep(10 * time.Millisecond) // 模擬 bcrypt 耗時
+			running.Add(-1)                   // 比對結束
+			release()                         // 歸還名額
+		}()
+	}
+	wg.Wait() // 等待全部完成
+
+	require.LessOrEqual(t, peak.Load(), int64(2))        // 同時進行的比對不超過上限
+	require.Nil(t, newBcryptLimiter(0, 16, time.Second)) // 上限為 0 時不限制
+}
+
+// TestLoginBusyWhenBcryptSaturated 測試比對名額已滿且無法排隊時登入回傳 ErrPasswordCheckBusy，名額釋出後恢復正常。
+func TestLoginBusyWhenBcryptSaturated(t *testing.T) {
+	env := newTestEnv(t)                                        // 建立測試環境
+	env.cfg.BcryptMaxConcurrency = 1                            // 同時只允許 1 個比對
+	env.cfg.BcryptQueueDepth = 0                                // 不允許排隊
+	env.cfg.BcryptQueueTimeout = 50 * time.Millisecond          // 等候上限
+	svc := NewSessionService(env.q, env.rdb, env.cfg, nil, nil) // 以新設定建立 SessionService
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost) // 低 cost 雜湊，讓測試快速
+	require.NoError(t, err)                                                           // 確保成功
+	createTestUser(t, env, "alice", string(hashed))                                   // 建立使用者
+
+	release, err := svc.bcrypt.acquire(env.ctx) // 佔住唯一的比對名額
+	require.NoError(t, err)                     // 應取得
+
+	_, _, _, err = svc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 名額已滿且不可排隊
+	require.ErrorIs(t, err, ErrPasswordCheckBusy)                          // 應回傳忙碌而非密碼錯誤
+	require.Equal(t, LoginOutcomeBusy, loginOutcome(err))                  // metrics outcome 獨立計算
+
+	env.cfg.BcryptQueueDepth = 1                                           // 允許 1 個排隊
+	svc = NewSessionService(env.q, env.rdb, env.cfg, nil, nil)             // 重新建立
+	release()                                                              // 歸還舊 limiter 的名額
+	release, err = svc.bcrypt.acquire(env.ctx)                             // 佔住新 limiter 的名額
+	require.NoError(t, err)                                                // 應取得
+	start := time.Now()                                                    // 記錄開始時間
+	_, _, _, err = svc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 排隊等候後逾時
+	require.ErrorIs(t, err, ErrPasswordCheckBusy)                          // 應回傳忙碌
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)      // 至少等候了設定的時間
+
+	release()                                                              // 釋出名額
+	_, _, _, err = svc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 再次登入
+	require.NoError(t, err)                                                // 應登入成功
+}
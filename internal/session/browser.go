@@ -0,0 +1,31 @@
+package session
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+// enforceBrowserSession 在 SingleSessionPerBrowser 開啟時踢掉該使用者在同一個 browser_id 上既有的 session，
+// 讓每個瀏覽器只有最新一次登入有效。browser_id 依使用者分開索引，其他帳號送出相同的 browser_id 不受影響；
+// 與 device_id 不同，同一台裝置上的不同瀏覽器彼此不會互踢。
+func (s *SessionService) enforceBrowserSession(ctx context.Context, userID int64, browserID string) error {
+	oldSID, err := s.rdb.Get(ctx, infra.BrowserSessKey(userID, browserID)).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	exists, err := s.rdb.Exists(ctx, infra.SessKey(oldSID)).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 1 {
+		s.evictForLimit(ctx, userID, oldSID)
+	}
+	return nil
+}
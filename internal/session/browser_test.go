@@ -0,0 +1,50 @@
+package session
+
+import (
+	"testing" // 匯入 testing，提供單元測試框架
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// TestSingleSessionPerBrowser 測試開啟 SingleSessionPerBrowser 時，同一個 browser_id 再次登入會踢掉舊的 session，
+// 同一台裝置上的其他瀏覽器、其他使用者相同的 browser_id 與未帶 browser_id 的 session 都不受影響。
+func TestSingleSessionPerBrowser(t *testing.T) {
+	env := newTestEnv(t)                             // 建立測試環境
+	env.cfg.MaxSessionsPerUser = 10                  // 避免同時登入上限影響結果
+	env.cfg.SingleSessionPerBrowser = true           // 每個 browser_id 只保留一個 session
+	hashed, err := bcryptGenerate("password123")     // 產生密碼雜湊
+	require.NoError(t, err)                          // 應產生成功
+	alice := createTestUser(t, env, "alice", hashed) // 建立 alice
+	bob := createTestUser(t, env, "bob", hashed)     // 建立 bob
+
+	login := func(username string, meta LoginMeta) string {
+		_, sid, _, err := env.sessSvc.Login(env.ctx, username, "password123", meta) // 登入
+		require.NoError(t, err)                                                     // 應登入成功
+		return sid
+	}
+	valid := func(userID int64, sid string) bool {
+		ok, err := env.sessSvc.IsSessionValid(env.ctx, userID, sid) // 檢查 session
+		require.NoError(t, err)                                     // 查詢不應失敗
+		return ok
+	}
+
+	chrome := LoginMeta{DeviceID: "laptop", BrowserID: "chrome"}   // 筆電上的 Chrome
+	firefox := LoginMeta{DeviceID: "laptop", BrowserID: "firefox"} // 同一台筆電上的 Firefox
+
+	first := login("alice", chrome)      // Chrome 第一次登入
+	other := login("alice", firefox)     // Firefox 登入
+	plain := login("alice", LoginMeta{}) // 未帶 browser_id
+	bobs := login("bob", chrome)         // bob 使用相同的 browser_id
+	second := login("alice", chrome)     // Chrome 再次登入
+
+	require.False(t, valid(alice.ID, first)) // 同一瀏覽器的舊 session 被踢掉
+	require.True(t, valid(alice.ID, second)) // 新 session 有效
+	require.True(t, valid(alice.ID, other))  // 同一裝置的其他瀏覽器不受影響
+	require.True(t, valid(alice.ID, plain))  // 未帶 browser_id 的 session 不受影響
+	require.True(t, valid(bob.ID, bobs))     // 其他使用者不受影響
+
+	env.cfg.SingleSessionPerBrowser = false  // 關閉限制
+	third := login("alice", chrome)          // Chrome 再登入
+	require.True(t, valid(alice.ID, second)) // 關閉時不踢舊的
+	require.True(t, valid(alice.ID, third))  // 新 session 有效
+}
@@ -0,0 +1,55 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"sessionservice/internal/db"
+	"sessionservice/internal/infra"
+)
+
+var (
+	ErrUserNotFound         = errors.New("user not found")
+	ErrRestoreWindowExpired = errors.New("restore window expired")
+)
+
+// DeleteUser 軟刪除 user：設定 deleted_at 但保留 users 這一列作為 tombstone（legal hold 用），
+// 清除 users.last_login_ip、users.email 與 login_events 中的 IP 與 user agent，並踢掉所有 session。
+// 軟刪除後 GetUserByUsername / GetUserByID 都查不到該 user，因此無法再登入；
+// username 仍被佔用，才能在 UserRestoreGrace 內原樣還原；email 則立即釋出，還原後需重新設定。
+func (s *SessionService) DeleteUser(ctx context.Context, userID int64) error {
+	n, err := s.q.SoftDeleteUser(ctx, db.SoftDeleteUserParams{
+		ID:        userID,
+		DeletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+
+	if err := s.q.ScrubLoginEventsPII(ctx, userID); err != nil {
+		return err
+	}
+	return s.revokeAllSessions(ctx, userID, infra.RevokedByAdminDelete)
+}
+
+// RestoreUser 還原軟刪除的 user；超過 UserRestoreGrace 回傳 ErrRestoreWindowExpired。
+// 已清除的 login_events 資料不會回復，被踢掉的 session 也需要重新登入。
+func (s *SessionService) RestoreUser(ctx context.Context, userID int64) error {
+	u, err := s.q.GetDeletedUserByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if s.cfg.UserRestoreGrace > 0 && time.Since(u.DeletedAt.Time) > s.cfg.UserRestoreGrace {
+		return ErrRestoreWindowExpired
+	}
+
+	return s.q.RestoreUser(ctx, userID)
+}
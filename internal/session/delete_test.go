@@ -0,0 +1,90 @@
+package session
+
+import (
+	"database/sql" // 匯入 database/sql，讀取可為 NULL 的欄位
+	"errors"       // 匯入 errors，比對 sentinel error
+	"testing"      // 匯入 testing，提供單元測試框架
+	"time"         // 匯入 time，設定還原期限
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/db"    // 匯入 db，建立帶 email 的使用者
+	"sessionservice/internal/infra" // 匯入 infra，讀取 Redis session key
+)
+
+// TestDeleteUserBlocksLoginAndRestores 測試軟刪除後無法登入、既有 session 被踢、login_events 的 PII 被清除，且期限內可還原。
+func TestDeleteUserBlocksLoginAndRestores(t *testing.T) {
+	env := newTestEnv(t)                      // 建立測試環境
+	env.cfg.UserRestoreGrace = 24 * time.Hour // 一天內可還原
+
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{IP: "10.0.0.1", UserAgent: "curl/8"}) // 刪除前登入
+	require.NoError(t, err)                                                                                              // 應登入成功
+	_, err = env.sqlDB.ExecContext(env.ctx, "INSERT INTO login_events (user_id, username, success, ip, user_agent) VALUES (?, 'alice', 1, '10.0.0.1', 'curl/8')", user.ID)
+	require.NoError(t, err) // 模擬 worker 寫入的登入紀錄
+	_, err = env.sqlDB.ExecContext(env.ctx, "UPDATE users SET last_login_ip = '10.0.0.1' WHERE id = ?", user.ID)
+	require.NoError(t, err) // 模擬 worker 更新上次登入 IP
+
+	require.NoError(t, env.sessSvc.DeleteUser(env.ctx, user.ID))                          // 軟刪除
+	require.True(t, errors.Is(env.sessSvc.DeleteUser(env.ctx, user.ID), ErrUserNotFound)) // 重複刪除視為找不到
+
+	require.False(t, env.mr.Exists(infra.SessKey(sid))) // 既有 session 已被踢掉
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{})
+	require.ErrorIs(t, err, ErrInvalidCredentials) // 軟刪除的 user 無法登入
+
+	var deleted int
+	err = env.sqlDB.QueryRowContext(env.ctx, "SELECT COUNT(*) FROM users WHERE id = ? AND deleted_at IS NOT NULL", user.ID).Scan(&deleted)
+	require.NoError(t, err)      // 查詢應成功
+	require.Equal(t, 1, deleted) // 資料列仍保留作為 tombstone
+	var withPII int
+	err = env.sqlDB.QueryRowContext(env.ctx, "SELECT COUNT(*) FROM login_events WHERE user_id = ? AND (ip IS NOT NULL OR user_agent IS NOT NULL)", user.ID).Scan(&withPII)
+	require.NoError(t, err)      // 查詢應成功
+	require.Equal(t, 0, withPII) // IP 與 user agent 已清除
+	var lastLoginIP sql.NullString
+	err = env.sqlDB.QueryRowContext(env.ctx, "SELECT last_login_ip FROM users WHERE id = ?", user.ID).Scan(&lastLoginIP)
+	require.NoError(t, err)             // 查詢應成功
+	require.False(t, lastLoginIP.Valid) // 上次登入 IP 已清除
+
+	require.NoError(t, env.sessSvc.RestoreUser(env.ctx, user.ID))                  // 期限內還原
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 還原後登入
+	require.NoError(t, err)                                                        // 應登入成功
+	require.ErrorIs(t, env.sessSvc.RestoreUser(env.ctx, user.ID), ErrUserNotFound) // 未刪除的 user 無法還原
+}
+
+// TestRestoreUserAfterGrace 測試超過還原期限後 RestoreUser 回傳 ErrRestoreWindowExpired。
+func TestRestoreUserAfterGrace(t *testing.T) {
+	env := newTestEnv(t)                 // 建立測試環境
+	env.cfg.UserRestoreGrace = time.Hour // 一小時內可還原
+
+	user := createTestUser(t, env, "bob", "x")                   // 建立使用者
+	require.NoError(t, env.sessSvc.DeleteUser(env.ctx, user.ID)) // 軟刪除
+	_, err := env.sqlDB.ExecContext(env.ctx, "UPDATE users SET deleted_at = ? WHERE id = ?", time.Now().Add(-2*time.Hour), user.ID)
+	require.NoError(t, err) // 將刪除時間往前推到期限之外
+
+	require.ErrorIs(t, env.sessSvc.RestoreUser(env.ctx, user.ID), ErrRestoreWindowExpired) // 已超過期限
+}
+
+// TestDeleteUserReleasesEmail 測試軟刪除會清除 users.email，同一個 email 可以再註冊，且刪除後的匯出不再帶 email。
+func TestDeleteUserReleasesEmail(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	email := sql.NullString{String: "alice@example.com", Valid: true}                                               // 共用的 email
+	user, err := env.q.CreateUser(env.ctx, db.CreateUserParams{Username: "alice", PasswordHash: "x", Email: email}) // 建立帶 email 的使用者
+	require.NoError(t, err)                                                                                         // 應建立成功
+
+	export, err := env.sessSvc.ExportUserData(env.ctx, user.ID) // 刪除前匯出
+	require.NoError(t, err)                                     // 應成功
+	require.Equal(t, "alice@example.com", export.Profile.Email) // 匯出帶有 email
+
+	require.NoError(t, env.sessSvc.DeleteUser(env.ctx, user.ID)) // 軟刪除
+
+	export, err = env.sessSvc.ExportUserData(env.ctx, user.ID) // 刪除後匯出
+	require.NoError(t, err)                                    // 軟刪除的使用者仍可匯出
+	require.Empty(t, export.Profile.Email)                     // email 已清除
+
+	_, err = env.q.CreateUser(env.ctx, db.CreateUserParams{Username: "alice2", PasswordHash: "x", Email: email}) // 以同一個 email 註冊
+	require.NoError(t, err)                                                                                      // email 已釋出
+}
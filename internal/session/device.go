@@ -0,0 +1,176 @@
+package session
+
+import (
+	"context"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+// 裝置類別，作為 MaxSessionsPerDevice 的 key。
+const (
+	DeviceMobile = "mobile"
+	DeviceWeb    = "web"
+	DeviceOther  = "other"
+)
+
+// mobileUAMarkers 是判定為行動裝置的 user agent 片段（比對前已轉小寫）。
+var mobileUAMarkers = []string{"mobile", "android", "iphone", "ipad", "ipod"}
+
+// DeviceCategory 由 user agent 推斷裝置類別：行動裝置為 mobile，其他瀏覽器為 web，其餘（CLI、SDK 等）為 other。
+func DeviceCategory(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	for _, m := range mobileUAMarkers {
+		if strings.Contains(ua, m) {
+			return DeviceMobile
+		}
+	}
+	if strings.HasPrefix(ua, "mozilla/") {
+		return DeviceWeb
+	}
+	return DeviceOther
+}
+
+// deviceSessionLimit 回傳某裝置類別的 session 上限，未個別設定時沿用 MaxSessionsPerUser。
+func (s *SessionService) deviceSessionLimit(category string) int {
+	if n, ok := s.cfg.MaxSessionsPerDevice[category]; ok {
+		return n
+	}
+	return s.cfg.MaxSessionsPerUser
+}
+
+// enforceDeviceSessionLimit 在建立新 session 前，只在同一裝置類別內由舊到新踢除 session（順序依 EvictionOrder），
+// 直到該類別騰出一個位置；其他類別的 session 不受影響。已 pin 的 session 排在最後才踢。
+func (s *SessionService) enforceDeviceSessionLimit(ctx context.Context, userID int64, category string) error {
+	limit := s.deviceSessionLimit(category)
+	if limit <= 0 {
+		return nil
+	}
+
+	// zset 依建立時間排序，由舊到新取出後以 pipeline 一次讀回各 session 的 user agent
+	sids, err := s.rdb.ZRange(ctx, infra.UserSessKey(userID), 0, -1).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if len(sids) == 0 {
+		return nil
+	}
+	if sids, err = s.sortByActivity(ctx, sids); err != nil {
+		return err
+	}
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(sids))
+	for i, sid := range sids {
+		cmds[i] = pipe.HMGet(ctx, infra.SessKey(sid), "user_id", "user_agent", "pinned")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return err
+	}
+
+	var sameCategory []string
+	var pinned []bool
+	for i, sid := range sids {
+		vals := cmds[i].Val()
+		if len(vals) < 3 || vals[0] == nil {
+			// hash 已過期但 zset 還留著，不計入上限
+			continue
+		}
+		ua, _ := vals[1].(string)
+		if DeviceCategory(ua) == category {
+			sameCategory = append(sameCategory, sid)
+			pinned = append(pinned, vals[2] == "1")
+		}
+	}
+	if len(sameCategory) < limit {
+		return nil
+	}
+
+	ordered, err := s.evictionOrder(sameCategory, pinned, len(sameCategory)-limit+1)
+	if err != nil {
+		return err
+	}
+	for i := 0; len(ordered)-i >= limit; i++ {
+		s.evictForLimit(ctx, userID, ordered[i])
+	}
+	return nil
+}
+
+// liveDeviceSessions 讀出 device_sess:{deviceID} 內仍存在的 session（由舊到新）與其擁有者，
+// 並順手移除 hash 已過期或已被撤銷的成員。
+func (s *SessionService) liveDeviceSessions(ctx context.Context, deviceID string) ([]string, []int64, error) {
+	deviceKey := infra.DeviceSessKey(deviceID)
+	sids, err := s.rdb.ZRange(ctx, deviceKey, 0, -1).Result()
+	if err != nil && err != redis.Nil {
+		return nil, nil, err
+	}
+	if len(sids) == 0 {
+		return nil, nil, nil
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.StringCmd, len(sids))
+	for i, sid := range sids {
+		cmds[i] = pipe.HGet(ctx, infra.SessKey(sid), "user_id")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, nil, err
+	}
+
+	var live []string
+	var owners []int64
+	var stale []interface{}
+	for i, sid := range sids {
+		uid, err := cmds[i].Int64()
+		if err != nil {
+			stale = append(stale, sid)
+			continue
+		}
+		live = append(live, sid)
+		owners = append(owners, uid)
+	}
+	if len(stale) > 0 {
+		_ = s.rdb.ZRem(ctx, deviceKey, stale...).Err()
+	}
+	return live, owners, nil
+}
+
+// enforceDeviceIDLimit 在同一個 device_id 上由舊到新踢除該使用者的 session，直到騰出一個位置。
+// device_id 由 client 提供，因此只會踢掉同一使用者的 session，其他帳號即使送出相同的 device_id 也不受影響。
+func (s *SessionService) enforceDeviceIDLimit(ctx context.Context, userID int64, deviceID string) error {
+	sids, owners, err := s.liveDeviceSessions(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+
+	var mine []string
+	for i, sid := range sids {
+		if owners[i] == userID {
+			mine = append(mine, sid)
+		}
+	}
+	for i := 0; len(mine)-i >= s.cfg.MaxSessionsPerDeviceID; i++ {
+		s.evictForLimit(ctx, userID, mine[i])
+		_ = s.rdb.ZRem(ctx, infra.DeviceSessKey(deviceID), mine[i]).Err()
+	}
+	return nil
+}
+
+// KickByDevice 撤銷綁定在指定 device_id 上的所有 session（不分使用者），回傳被撤銷的數量。
+func (s *SessionService) KickByDevice(ctx context.Context, deviceID string) (int, error) {
+	sids, owners, err := s.liveDeviceSessions(ctx, deviceID)
+	if err != nil {
+		return 0, err
+	}
+
+	kicked := 0
+	for i, sid := range sids {
+		if err := s.revokeSession(ctx, owners[i], sid, infra.RevokedByAdminKickDevice); err != nil {
+			return kicked, err
+		}
+		kicked++
+	}
+	_ = s.rdb.Del(ctx, infra.DeviceSessKey(deviceID)).Err()
+	return kicked, nil
+}
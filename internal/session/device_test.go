@@ -0,0 +1,124 @@
+package session
+
+import (
+	"testing" // 匯入 testing，提供單元測試框架
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// TestDeviceCategory 測試由 user agent 推斷裝置類別。
+func TestDeviceCategory(t *testing.T) {
+	cases := map[string]string{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148": DeviceMobile, // iPhone Safari
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8)":                             DeviceMobile, // Android
+		"MyApp/2.1 (iPad; iOS 17)":                                             DeviceMobile, // 原生 App
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0":               DeviceWeb,    // 桌面瀏覽器
+		"curl/8.0": DeviceOther, // CLI
+		"":         DeviceOther, // 未帶 user agent
+	}
+	for ua, want := range cases {
+		require.Equal(t, want, DeviceCategory(ua), ua) // 類別應符合預期
+	}
+}
+
+// TestDeviceSessionLimitEvictsWithinCategory 測試設定裝置類別上限後，超過上限只會踢掉同類別最舊的 session。
+func TestDeviceSessionLimitEvictsWithinCategory(t *testing.T) {
+	env := newTestEnv(t)                                                         // 建立測試環境
+	env.cfg.MaxSessionsPerUser = 1                                               // 未個別設定的類別沿用此上限
+	env.cfg.MaxSessionsPerDevice = map[string]int{DeviceWeb: 2, DeviceMobile: 1} // web 可兩個、mobile 一個
+
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	web := LoginMeta{UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64)"}   // 桌面瀏覽器
+	mobile := LoginMeta{UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0)"} // 行動裝置
+	cli := LoginMeta{UserAgent: "curl/8.0"}                                    // 其他類別
+	login := func(meta LoginMeta) string {
+		_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", meta) // 以指定裝置登入
+		require.NoError(t, err)                                                    // 應登入成功
+		return sid
+	}
+	valid := func(sid string) bool {
+		ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid) // 檢查 session
+		require.NoError(t, err)                                      // 檢查不應失敗
+		return ok
+	}
+
+	web1, web2, mobile1 := login(web), login(web), login(mobile) // 三個 session，皆未超過各自上限
+	require.True(t, valid(web1))                                 // 全部有效
+	require.True(t, valid(web2))
+	require.True(t, valid(mobile1))
+
+	mobile2 := login(mobile)         // mobile 超過上限
+	require.False(t, valid(mobile1)) // 只踢掉舊的 mobile session
+	require.True(t, valid(mobile2))
+	require.True(t, valid(web1)) // web session 不受影響
+	require.True(t, valid(web2))
+
+	web3 := login(web)            // web 超過上限
+	require.False(t, valid(web1)) // 只踢掉最舊的 web session
+	require.True(t, valid(web2))
+	require.True(t, valid(web3))
+	require.True(t, valid(mobile2)) // mobile session 不受影響
+
+	cli1, cli2 := login(cli), login(cli) // other 類別沿用 MaxSessionsPerUser=1
+	require.False(t, valid(cli1))        // 舊的 CLI session 被踢掉
+	require.True(t, valid(cli2))
+	require.True(t, valid(web2)) // 其他類別不受影響
+	require.True(t, valid(web3))
+	require.True(t, valid(mobile2))
+}
+
+// TestDeviceIDEvictionAndKick 測試 MaxSessionsPerDeviceID=1 時同一裝置的新登入會踢掉該使用者的舊 session，
+// 其他裝置與其他使用者不受影響，且 KickByDevice 會撤銷該裝置上的所有 session。
+func TestDeviceIDEvictionAndKick(t *testing.T) {
+	env := newTestEnv(t)               // 建立測試環境
+	env.cfg.MaxSessionsPerUser = 10    // 不讓使用者總上限介入
+	env.cfg.MaxSessionsPerDeviceID = 1 // 一台裝置一個 session
+
+	hashed, err := bcryptGenerate("password123")     // 產生雜湊
+	require.NoError(t, err)                          // 確保成功
+	alice := createTestUser(t, env, "alice", hashed) // 建立 alice
+	bob := createTestUser(t, env, "bob", hashed)     // 建立 bob
+
+	login := func(username, deviceID string) string {
+		_, sid, _, err := env.sessSvc.Login(env.ctx, username, "password123", LoginMeta{DeviceID: deviceID}) // 以指定裝置登入
+		require.NoError(t, err)                                                                              // 應登入成功
+		return sid
+	}
+	valid := func(userID int64, sid string) bool {
+		ok, err := env.sessSvc.IsSessionValid(env.ctx, userID, sid) // 檢查 session
+		require.NoError(t, err)                                     // 檢查不應失敗
+		return ok
+	}
+
+	phone1 := login("alice", "iphone-1")      // alice 在 iPhone 登入
+	phone2 := login("alice", "iphone-1")      // 同一台 iPhone 再登入
+	laptop := login("alice", "laptop-1")      // 另一台裝置
+	noID := login("alice", "")                // 沒有提供 device_id
+	require.False(t, valid(alice.ID, phone1)) // 同裝置的舊 session 被踢掉
+	require.True(t, valid(alice.ID, phone2))
+	require.True(t, valid(alice.ID, laptop)) // 其他裝置不受影響
+	require.True(t, valid(alice.ID, noID))   // 沒有 device_id 的 session 不受影響
+
+	bobPhone := login("bob", "iphone-1")     // 其他使用者送出相同的 device_id
+	require.True(t, valid(alice.ID, phone2)) // 不會踢掉 alice 的 session
+	require.True(t, valid(bob.ID, bobPhone))
+
+	sessions, err := env.sessSvc.ListActiveSessions(env.ctx, alice.ID, ListSessionsOptions{}) // 列出 alice 的 session
+	require.NoError(t, err)                                                                   // 不應失敗
+	require.Equal(t, "iphone-1", sessions[0].DeviceID)                                        // 最舊的仍有效 session 是 iPhone 上的
+
+	kicked, err := env.sessSvc.KickByDevice(env.ctx, "iphone-1") // 撤銷該裝置上的所有 session
+	require.NoError(t, err)                                      // 不應失敗
+	require.Equal(t, 2, kicked)                                  // alice 與 bob 各一個
+	require.False(t, valid(alice.ID, phone2))
+	require.False(t, valid(bob.ID, bobPhone))
+	require.True(t, valid(alice.ID, laptop))                // 其他裝置不受影響
+	require.False(t, env.mr.Exists("device_sess:iphone-1")) // 索引一併刪除
+
+	kicked, err = env.sessSvc.KickByDevice(env.ctx, "iphone-1") // 再踢一次
+	require.NoError(t, err)                                     // 不應失敗
+	require.Zero(t, kicked)                                     // 已沒有 session
+}
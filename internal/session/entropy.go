@@ -0,0 +1,310 @@
+package session
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// 密碼弱點代碼，作為 PasswordStrength.Weakness 與 API 錯誤回應中的 weakness。
+const (
+	WeaknessCommonPassword   = "common_password"
+	WeaknessContainsUsername = "contains_username"
+	WeaknessRepeated         = "repeated_characters"
+	WeaknessSequence         = "sequence"
+	WeaknessKeyboardPattern  = "keyboard_pattern"
+	WeaknessTooShort         = "too_short"
+)
+
+// ErrPasswordTooWeak 表示密碼的熵估計低於 PasswordMinEntropyBits；實際回傳的是 *WeakPasswordError。
+var ErrPasswordTooWeak = errors.New("password too weak")
+
+// WeakPasswordError 帶有熵估計與最主要的弱點，errors.Is(err, ErrPasswordTooWeak) 成立。
+type WeakPasswordError struct {
+	Bits     float64
+	Weakness string
+}
+
+func (e *WeakPasswordError) Error() string {
+	return "password too weak: " + e.Weakness
+}
+
+func (e *WeakPasswordError) Is(target error) bool {
+	return target == ErrPasswordTooWeak
+}
+
+// CheckPasswordStrength 在設定 PasswordMinEntropyBits 時估計密碼的熵，低於門檻回傳 *WeakPasswordError。
+func (s *SessionService) CheckPasswordStrength(password, username string) error {
+	if s.cfg.PasswordMinEntropyBits <= 0 {
+		return nil
+	}
+	strength := EstimatePasswordEntropy(password, username)
+	if strength.Bits >= float64(s.cfg.PasswordMinEntropyBits) {
+		return nil
+	}
+	weakness := strength.Weakness
+	if weakness == "" {
+		weakness = WeaknessTooShort
+	}
+	return &WeakPasswordError{Bits: strength.Bits, Weakness: weakness}
+}
+
+// PasswordStrength 是 EstimatePasswordEntropy 的結果。
+type PasswordStrength struct {
+	Bits     float64
+	Weakness string // 扣分最多的弱點代碼，沒有明顯弱點時為空
+}
+
+// minPatternLen 是常見密碼、鍵盤排列等樣式最短的比對長度，太短的片段當成一般字元計算。
+const minPatternLen = 4
+
+// EstimatePasswordEntropy 以類似 zxcvbn 的方式估計密碼的熵（bits）：
+// 由左至右切出常見密碼 / username、重複字元、連續字元與鍵盤排列等片段，各片段只計猜中該樣式所需的 bits，
+// 其餘字元依密碼使用到的字元集大小計算。估計偏保守，只用於擋下明顯容易被猜中的密碼。
+func EstimatePasswordEntropy(password, username string) PasswordStrength {
+	runes := []rune(password)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	plain := []rune(unleet(string(lower)))
+	username = unleet(strings.ToLower(username))
+	charBits := math.Log2(float64(charsetSize(password)))
+
+	var result PasswordStrength
+	penalty := 0.0
+	note := func(weakness string, lost float64) {
+		if lost > penalty {
+			penalty = lost
+			result.Weakness = weakness
+		}
+	}
+
+	for i := 0; i < len(runes); {
+		// 鍵盤排列比字典片段長時（例如 zxcvbnm,./ 對上字典裡的 zxcv）以鍵盤排列計算
+		if j, rank := dictionaryMatch(plain, i, username); j > i && j >= keyboardRun(lower, i) {
+			bits := math.Log2(float64(rank+1)) + capitalizationBits(runes[i:j]) + leetBits(runes[i:j], plain[i:j])
+			if rank == 0 {
+				note(WeaknessContainsUsername, float64(j-i)*charBits-bits)
+			} else {
+				note(WeaknessCommonPassword, float64(j-i)*charBits-bits)
+			}
+			result.Bits += bits
+			i = j
+			continue
+		}
+		if j := repeatRun(lower, i); j-i >= 3 {
+			bits := charBits + math.Log2(float64(j-i))
+			note(WeaknessRepeated, float64(j-i)*charBits-bits)
+			result.Bits += bits
+			i = j
+			continue
+		}
+		if j := sequenceRun(lower, i); j-i >= 3 {
+			bits := charBits + math.Log2(float64(j-i)) + 1 // 遞增或遞減
+			note(WeaknessSequence, float64(j-i)*charBits-bits)
+			result.Bits += bits
+			i = j
+			continue
+		}
+		if j := keyboardRun(lower, i); j-i >= minPatternLen {
+			bits := math.Log2(float64(keyboardStarts)) + math.Log2(float64(j-i)) + 1
+			note(WeaknessKeyboardPattern, float64(j-i)*charBits-bits)
+			result.Bits += bits
+			i = j
+			continue
+		}
+		result.Bits += charBits
+		i++
+	}
+
+	if result.Weakness == "" && len(runes) < 8 {
+		result.Weakness = WeaknessTooShort
+	}
+	return result
+}
+
+// charsetSize 回傳密碼使用到的字元類別總大小（小寫、大寫、數字、符號、其他）。
+func charsetSize(password string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 100
+	}
+	if size == 0 {
+		size = 1
+	}
+	return size
+}
+
+// leetTable 是常見的 leet 替換，比對字典前先還原。
+var leetTable = strings.NewReplacer("@", "a", "4", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t")
+
+// unleet 把小寫化的密碼還原 leet 替換；每個替換都是一個字元換一個字元，位置不變。
+func unleet(s string) string {
+	return leetTable.Replace(s)
+}
+
+// capitalizationBits 估計字典片段的大小寫變化：全小寫 0、首字或全大寫 1，其餘依大寫字元數計。
+func capitalizationBits(seg []rune) float64 {
+	upper := 0
+	for _, r := range seg {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 0
+	case upper == len(seg), upper == 1 && unicode.IsUpper(seg[0]):
+		return 1
+	default:
+		return float64(upper)
+	}
+}
+
+// leetBits 每個 leet 替換算 1 bit。
+func leetBits(orig, plain []rune) float64 {
+	n := 0
+	for i := range orig {
+		if unicode.ToLower(orig[i]) != plain[i] {
+			n++
+		}
+	}
+	return float64(n)
+}
+
+// dictionaryMatch 從 i 開始找最長的常見密碼或 username 片段，回傳結束位置與排名（username 排名為 0）；沒有時回傳 i。
+func dictionaryMatch(plain []rune, i int, username string) (int, int) {
+	for j := len(plain); j-i >= minPatternLen; j-- {
+		word := string(plain[i:j])
+		if len(username) >= 3 && word == username {
+			return j, 0
+		}
+		if rank, ok := commonPasswordRank[word]; ok {
+			return j, rank
+		}
+	}
+	return i, 0
+}
+
+// repeatRun 回傳從 i 開始相同字元連續出現的結束位置。
+func repeatRun(lower []rune, i int) int {
+	j := i + 1
+	for j < len(lower) && lower[j] == lower[i] {
+		j++
+	}
+	return j
+}
+
+// sequenceRun 回傳從 i 開始字元碼依序 +1 或 -1 的結束位置（例如 abcd、4321）。
+func sequenceRun(lower []rune, i int) int {
+	if i+1 >= len(lower) {
+		return i + 1
+	}
+	delta := lower[i+1] - lower[i]
+	if delta != 1 && delta != -1 {
+		return i + 1
+	}
+	j := i + 1
+	for j < len(lower) && lower[j]-lower[j-1] == delta {
+		j++
+	}
+	return j
+}
+
+// keyboardRows 是 QWERTY 鍵盤上常被整排輸入的字元列。
+var keyboardRows = []string{"1234567890-=", "qwertyuiop[]", "asdfghjkl;'", "zxcvbnm,./", "qazwsxedc", "1qaz2wsx3edc"}
+
+// keyboardStarts 是鍵盤排列可能的起點數，用於估計猜中鍵盤排列所需的 bits。
+var keyboardStarts = func() int {
+	n := 0
+	for _, row := range keyboardRows {
+		n += len(row)
+	}
+	return n
+}()
+
+// keyboardRun 回傳從 i 開始、沿某一列鍵盤順向或逆向連續的最長結束位置。
+func keyboardRun(lower []rune, i int) int {
+	best := i + 1
+	for _, row := range keyboardRows {
+		for _, r := range []string{row, reverseString(row)} {
+			start := strings.IndexRune(r, lower[i])
+			if start < 0 {
+				continue
+			}
+			j := i
+			for j < len(lower) && start+(j-i) < len(r) && rune(r[start+(j-i)]) == lower[j] {
+				j++
+			}
+			if j > best {
+				best = j
+			}
+		}
+	}
+	return best
+}
+
+func reverseString(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+// commonPasswordRank 是外洩清單中最常見的密碼與字根（已小寫、已還原 leet），值為排名（由 1 開始）。
+var commonPasswordRank = func() map[string]int {
+	words := []string{
+		"password", "123456", "12345678", "qwerty", "abc123", "football", "monkey", "letmein",
+		"dragon", "111111", "baseball", "iloveyou", "trustno1", "sunshine", "master", "welcome",
+		"shadow", "ashley", "superman", "michael", "ninja", "mustang", "jessica", "charlie",
+		"passw0rd", "login", "admin", "princess", "qwertyuiop", "solo", "starwars", "whatever",
+		"freedom", "hello", "batman", "access", "flower", "lovely", "hottie", "loveme",
+		"zaq1zaq1", "qazwsx", "password1", "secret", "summer", "winter", "spring", "autumn",
+		"hunter", "killer", "soccer", "hockey", "tigger", "pepper", "cheese", "computer",
+		"internet", "service", "changeme", "default", "guest", "root", "test", "user",
+		"love", "angel", "jordan", "harley", "ranger", "buster", "thomas", "robert",
+		"daniel", "andrew", "joshua", "matrix", "cookie", "orange", "banana", "purple",
+		"google", "apple", "samsung", "london", "chicago", "america", "chelsea", "liverpool",
+		"arsenal", "yankees", "dallas", "austin", "taylor", "maggie", "ginger", "silver",
+		"golden", "diamond", "money", "blessed", "family", "forever", "friends", "baby",
+		"qwer", "asdf", "zxcv", "abcd", "pass", "word", "secure", "company", "office",
+	}
+	m := make(map[string]int, len(words))
+	for i, w := range words {
+		w = unleet(w)
+		if _, ok := m[w]; !ok {
+			m[w] = i + 1
+		}
+	}
+	return m
+}()
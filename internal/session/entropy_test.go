@@ -0,0 +1,55 @@
+package session
+
+import (
+	"testing" // 匯入 testing，提供單元測試框架
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// TestEstimatePasswordEntropyWeak 測試常見的弱密碼估計值偏低，並標出對應的弱點。
+func TestEstimatePasswordEntropyWeak(t *testing.T) {
+	cases := map[string]string{
+		"Password1":  WeaknessCommonPassword,   // 常見密碼加首字大寫與數字
+		"P@ssw0rd!":  WeaknessCommonPassword,   // leet 替換仍視為常見密碼
+		"iloveyou":   WeaknessCommonPassword,   // 常見密碼
+		"aaaaaaaaaa": WeaknessRepeated,         // 重複字元
+		"zxcvbnm,./": WeaknessKeyboardPattern,  // 鍵盤排列
+		"alice2024":  WeaknessContainsUsername, // 包含 username
+	}
+	for password, weakness := range cases {
+		strength := EstimatePasswordEntropy(password, "alice")
+		require.Less(t, strength.Bits, 28.0, password)          // 熵估計偏低
+		require.Equal(t, weakness, strength.Weakness, password) // 弱點符合預期
+	}
+}
+
+// TestEstimatePasswordEntropyStrong 測試隨機或夠長的密碼估計值夠高。
+func TestEstimatePasswordEntropyStrong(t *testing.T) {
+	for _, password := range []string{"x7#Qm9!vLp2@", "Tr0ub4dor&3", "correct horse battery staple"} {
+		strength := EstimatePasswordEntropy(password, "alice")
+		require.Greater(t, strength.Bits, 60.0, password) // 熵估計夠高
+	}
+}
+
+// TestCheckPasswordStrength 測試 PasswordMinEntropyBits 未設定時不檢查，設定後擋下弱密碼。
+func TestCheckPasswordStrength(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	require.NoError(t, env.sessSvc.CheckPasswordStrength("Password1", "alice")) // 未設定門檻時不檢查
+
+	env.cfg.PasswordMinEntropyBits = 40                            // 設定門檻
+	err := env.sessSvc.CheckPasswordStrength("Password1", "alice") // 弱密碼
+	require.ErrorIs(t, err, ErrPasswordTooWeak)                    // 應被拒絕
+	var weak *WeakPasswordError
+	require.ErrorAs(t, err, &weak)                                                 // 帶有弱點資訊
+	require.Equal(t, WeaknessCommonPassword, weak.Weakness)                        // 弱點為常見密碼
+	require.NoError(t, env.sessSvc.CheckPasswordStrength("x7#Qm9!vLp2@", "alice")) // 強密碼通過
+
+	hashed, err := bcryptGenerate("password123")                                             // 產生雜湊
+	require.NoError(t, err)                                                                  // 確保成功
+	user := createTestUser(t, env, "alice", hashed)                                          // 建立使用者
+	tok := issueResetToken(t, env, user.ID)                                                  // 發出重設 token
+	err = env.sessSvc.ResetPassword(env.ctx, tok, "Password1", LoginMeta{})                  // 改成弱密碼
+	require.ErrorIs(t, err, ErrPasswordTooWeak)                                              // 應被拒絕
+	require.NoError(t, env.sessSvc.ResetPassword(env.ctx, tok, "x7#Qm9!vLp2@", LoginMeta{})) // token 未被消耗，強密碼可以重設
+}
@@ -0,0 +1,98 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+// legacySessionEpoch 是加入 epoch 前發出、沒有 "v{epoch}." 前綴的 session ID 所屬的 epoch，
+// 與 SESSION_EPOCH 預設值相同，升級部署時既有 session 不會被一次踢光。
+const legacySessionEpoch = 1
+
+// bumpEpochScript 以 max(Redis 值, 設定值) + 1 作為新的 epoch，避免 Redis 值低於設定值時 INCR 沒有效果。
+var bumpEpochScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local floor = tonumber(ARGV[1])
+if current < floor then
+  current = floor
+end
+current = current + 1
+redis.call('SET', KEYS[1], current)
+return current
+`)
+
+// session ID 隨機部分的編碼方式。
+const (
+	SessionIDEncodingUUID   = "uuid"   // 36 字元的 UUID 字串
+	SessionIDEncodingBase62 = "base62" // 同樣 128 bit 的隨機值以 base62 編碼，最多 22 字元
+)
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// newSessionID 產生帶 epoch 前綴的 session ID，例如 "v3.0b6f..."；encoding 為 base62 時改用較短的編碼。
+func newSessionID(epoch int64, encoding string) string {
+	id := uuid.New()
+	if encoding == SessionIDEncodingBase62 {
+		return fmt.Sprintf("v%d.%s", epoch, encodeBase62(id[:]))
+	}
+	return fmt.Sprintf("v%d.%s", epoch, id.String())
+}
+
+// encodeBase62 將 bytes 視為大端序無號整數並以 base62 編碼。
+func encodeBase62(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	if n.Sign() == 0 {
+		return "0"
+	}
+	base := big.NewInt(62)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		out = append(out, base62Alphabet[mod.Int64()])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// sessionIDEpoch 解析 session ID 內嵌的 epoch；沒有前綴的舊格式視為 legacySessionEpoch。
+func sessionIDEpoch(sessionID string) int64 {
+	prefix, _, ok := strings.Cut(sessionID, ".")
+	if !ok || !strings.HasPrefix(prefix, "v") {
+		return legacySessionEpoch
+	}
+	epoch, err := strconv.ParseInt(prefix[1:], 10, 64)
+	if err != nil {
+		return legacySessionEpoch
+	}
+	return epoch
+}
+
+// CurrentSessionEpoch 回傳目前的 session epoch：SESSION_EPOCH 設定值與 Redis session_epoch 取較大者。
+// 設定值適合隨部署調整，Redis 值則讓營運在不重啟服務的情況下立即失效所有 session。
+func (s *SessionService) CurrentSessionEpoch(ctx context.Context) (int64, error) {
+	epoch := s.cfg.SessionEpoch
+	stored, err := s.rdb.Get(ctx, infra.SessionEpochKey()).Int64()
+	if err != nil && err != redis.Nil {
+		return 0, err
+	}
+	if stored > epoch {
+		epoch = stored
+	}
+	return epoch, nil
+}
+
+// BumpSessionEpoch 將 epoch 加一並回傳新值，所有以舊 epoch 發出的 session 之後都會被 IsSessionValid 拒絕。
+func (s *SessionService) BumpSessionEpoch(ctx context.Context) (int64, error) {
+	return bumpEpochScript.Run(ctx, s.rdb, []string{infra.SessionEpochKey()}, s.cfg.SessionEpoch).Int64()
+}
@@ -0,0 +1,118 @@
+package session
+
+import (
+	"database/sql" // 匯入 database/sql，讀取撤銷原因
+	"strings"      // 匯入 strings，檢查 session ID 前綴
+	"testing"      // 匯入 testing，提供單元測試框架
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra，讀取 Redis session key
+)
+
+// TestSessionIDEpoch 測試 session ID 的 epoch 解析，舊格式與無法解析的前綴都視為 legacySessionEpoch。
+func TestSessionIDEpoch(t *testing.T) {
+	require.EqualValues(t, 3, sessionIDEpoch(newSessionID(3, SessionIDEncodingUUID))) // 新格式
+	require.EqualValues(t, legacySessionEpoch, sessionIDEpoch("0b6f-uuid"))           // 沒有前綴的舊 ID
+	require.EqualValues(t, legacySessionEpoch, sessionIDEpoch("vx.0b6f-uuid"))        // 前綴不是數字
+	require.EqualValues(t, legacySessionEpoch, sessionIDEpoch("sid.check"))           // 有點但不是 v 開頭
+}
+
+// TestNewSessionIDBase62 測試 base62 編碼的 session ID 較 UUID 短，且 epoch 仍可解析。
+func TestNewSessionIDBase62(t *testing.T) {
+	sid := newSessionID(4, SessionIDEncodingBase62)                        // base62 格式
+	require.EqualValues(t, 4, sessionIDEpoch(sid))                         // epoch 解析不受編碼影響
+	require.Less(t, len(sid), len(newSessionID(4, SessionIDEncodingUUID))) // 比 UUID 格式短
+	require.NotContains(t, strings.TrimPrefix(sid, "v4."), "-")            // 不含 UUID 的連字號
+	require.NotEqual(t, sid, newSessionID(4, SessionIDEncodingBase62))     // 每次產生的 ID 不同
+	require.Equal(t, "10", encodeBase62([]byte{62}))                       // 62 以 base62 表示為 "10"
+}
+
+// TestBumpSessionEpochInvalidatesOldSessions 測試 epoch 前進後舊 session 被拒絕，新登入的 session 可正常使用。
+func TestBumpSessionEpochInvalidatesOldSessions(t *testing.T) {
+	env := newTestEnv(t)     // 建立測試環境
+	env.cfg.SessionEpoch = 1 // 與正式環境預設相同
+
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, oldSID, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 以 epoch 1 登入
+	require.NoError(t, err)                                                              // 應登入成功
+	require.True(t, strings.HasPrefix(oldSID, "v1."))                                    // ID 帶有 epoch 前綴
+
+	epoch, err := env.sessSvc.BumpSessionEpoch(env.ctx) // 前進 epoch
+	require.NoError(t, err)                             // 不應失敗
+	require.EqualValues(t, 2, epoch)                    // 由設定值 1 前進到 2
+
+	ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, oldSID) // 舊 epoch 的 session
+	require.NoError(t, err)                                         // 檢查不應失敗
+	require.False(t, ok)                                            // 應被拒絕（Redis 資料仍在）
+
+	_, newSID, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 重新登入
+	require.NoError(t, err)                                                              // 應登入成功
+	require.True(t, strings.HasPrefix(newSID, "v2."))                                    // 使用新的 epoch
+	ok, err = env.sessSvc.IsSessionValid(env.ctx, user.ID, newSID)                       // 新 session
+	require.NoError(t, err)                                                              // 檢查不應失敗
+	require.True(t, ok)                                                                  // 應有效
+}
+
+// TestSessionEpochConfigFloor 測試調高 SESSION_EPOCH 設定同樣會讓舊 session 失效，且 bump 以設定值為下限。
+func TestSessionEpochConfigFloor(t *testing.T) {
+	env := newTestEnv(t)     // 建立測試環境
+	env.cfg.SessionEpoch = 1 // 初始 epoch
+
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 以 epoch 1 登入
+	require.NoError(t, err)                                                           // 應登入成功
+
+	env.cfg.SessionEpoch = 5                                     // 部署時調高設定
+	ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid) // 舊 session
+	require.NoError(t, err)                                      // 檢查不應失敗
+	require.False(t, ok)                                         // 應被拒絕
+
+	epoch, err := env.sessSvc.BumpSessionEpoch(env.ctx) // Redis 尚無值，應從設定值往上加
+	require.NoError(t, err)                             // 不應失敗
+	require.EqualValues(t, 6, epoch)                    // 5 + 1
+}
+
+// TestCleanupSessionsBefore 測試 purge 後的背景清理只刪除舊 epoch 的 session，並在 DB 標記 admin:purge。
+func TestCleanupSessionsBefore(t *testing.T) {
+	env := newTestEnv(t)           // 建立測試環境
+	env.cfg.SessionEpoch = 1       // 與正式環境預設相同
+	env.cfg.MaxSessionsPerUser = 5 // 不讓同時登入上限介入
+
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, oldSID, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // purge 前的 session
+	require.NoError(t, err)                                                              // 應登入成功
+
+	epoch, err := env.sessSvc.PurgeAllSessions(env.ctx) // 緊急登出所有人
+	require.NoError(t, err)                             // 不應失敗
+	ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, oldSID)
+	require.NoError(t, err) // 檢查不應失敗
+	require.False(t, ok)    // 清理前就已失效
+
+	_, newSID, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // purge 後重新登入
+	require.NoError(t, err)                                                              // 應登入成功
+
+	n, err := env.sessSvc.CleanupSessionsBefore(env.ctx, epoch) // 執行清理
+	require.NoError(t, err)                                     // 不應失敗
+	require.Equal(t, 1, n)                                      // 只清掉舊 session
+
+	require.False(t, env.mr.Exists(infra.SessKey(oldSID)))                              // 舊 session 的 hash 已刪除
+	require.True(t, env.mr.Exists(infra.SessKey(newSID)))                               // 新 session 不受影響
+	members, err := env.rdb.ZRange(env.ctx, infra.UserSessKey(user.ID), 0, -1).Result() // 使用者的 session 清單
+	require.NoError(t, err)                                                             // 不應失敗
+	require.Equal(t, []string{newSID}, members)                                         // 只剩新 session
+
+	var revokedBy sql.NullString
+	err = env.sqlDB.QueryRowContext(env.ctx, "SELECT revoked_by FROM sessions WHERE id = ?", oldSID).Scan(&revokedBy)
+	require.NoError(t, err)                           // 查詢應成功
+	require.Equal(t, "admin:purge", revokedBy.String) // DB 標記撤銷原因
+}
@@ -0,0 +1,53 @@
+package session
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+// EvictionOrder 的值：達到同時登入上限時先踢哪個 session。
+const (
+	EvictionOrderCreated  = "created"
+	EvictionOrderLastSeen = "last_seen"
+)
+
+// sortByActivity 在 EvictionOrder 為 last_seen 時，將由舊到新排列的 sids 改依最後活動時間由舊到新排列：
+// 以 hash 的 last_seen 為準，從未記錄 last_seen 的 session 以 created_at 代替；時間相同時維持原本的建立順序。
+// 建立很久但仍在使用的 session 因此排在剛建立卻閒置的 session 之後。EvictionOrder 為 created 時原樣回傳。
+func (s *SessionService) sortByActivity(ctx context.Context, sids []string) ([]string, error) {
+	if s.cfg.EvictionOrder != EvictionOrderLastSeen || len(sids) < 2 {
+		return sids, nil
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(sids))
+	for i, sid := range sids {
+		cmds[i] = pipe.HMGet(ctx, infra.SessKey(sid), "last_seen", "created_at")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	activity := make(map[string]int64, len(sids))
+	for i, sid := range sids {
+		for _, v := range cmds[i].Val() {
+			str, _ := v.(string)
+			if unix, err := strconv.ParseInt(str, 10, 64); err == nil {
+				activity[sid] = unix
+				break
+			}
+		}
+	}
+
+	sorted := slices.Clone(sids)
+	slices.SortStableFunc(sorted, func(a, b string) int {
+		return cmp.Compare(activity[a], activity[b])
+	})
+	return sorted, nil
+}
@@ -0,0 +1,45 @@
+package session
+
+import (
+	"strconv" // 匯入 strconv，寫入 UNIX 時間
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，設定建立與活動時間
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra，取得 session key
+)
+
+// TestEvictionOrderLastSeen 測試 EvictionOrder 為 last_seen 時，建立較早但仍在使用的 session 保留，改踢較新但閒置的 session；
+// EvictionOrder 為 created 時照舊踢最早建立的。
+func TestEvictionOrderLastSeen(t *testing.T) {
+	for _, tc := range []struct {
+		order        string
+		oldSurvives  bool
+		idleSurvives bool
+	}{
+		{EvictionOrderLastSeen, true, false}, // 依活動時間：踢閒置的新 session
+		{EvictionOrderCreated, false, true},  // 依建立時間：踢最舊的 session
+	} {
+		t.Run(tc.order, func(t *testing.T) {
+			env, _, login, valid := newPinTestEnv(t) // 建立測試環境（上限 2）
+			env.cfg.EvictionOrder = tc.order         // 設定踢除順序
+			env.cfg.LastSeenInterval = time.Minute   // 記錄 last_seen
+
+			oldActive, newIdle := login(), login() // 兩個 session，已達上限
+			now := time.Now()                      // 目前時間
+			require.NoError(t, env.rdb.HSet(env.ctx, infra.SessKey(oldActive),
+				"created_at", strconv.FormatInt(now.Add(-2*time.Hour).Unix(), 10), // 兩小時前建立
+				"last_seen", strconv.FormatInt(now.Unix(), 10), // 剛剛還在使用
+			).Err())
+			require.NoError(t, env.rdb.HSet(env.ctx, infra.SessKey(newIdle),
+				"created_at", strconv.FormatInt(now.Add(-time.Hour).Unix(), 10), // 一小時前建立，之後沒有活動
+			).Err())
+
+			third := login()                                   // 第三次登入，需要踢掉一個 session
+			require.Equal(t, tc.oldSurvives, valid(oldActive)) // 舊但活躍的 session
+			require.Equal(t, tc.idleSurvives, valid(newIdle))  // 新但閒置的 session
+			require.True(t, valid(third))                      // 新 session 有效
+		})
+	}
+}
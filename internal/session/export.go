@@ -0,0 +1,162 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"sessionservice/internal/db"
+	"sessionservice/internal/infra"
+)
+
+// exportLoginEventsLimit 是 ExportUserData 最多帶出的登入紀錄筆數（由新到舊）。
+const exportLoginEventsLimit = 1000
+
+// UserExport 是資料主體存取請求（GDPR export）的完整內容。
+// 只包含使用者本人相關的資料，密碼雜湊與 rehash / pepper 等內部欄位一律不輸出。
+type UserExport struct {
+	ExportedAt      time.Time              `json:"exported_at"`
+	Profile         ExportProfile          `json:"profile"`
+	ActiveSessions  []ActiveSessionInfo    `json:"active_sessions"`
+	SessionHistory  []ExportSession        `json:"session_history"`
+	LoginEvents     []ExportLoginEvent     `json:"login_events"`
+	UsernameChanges []ExportUsernameChange `json:"username_changes"`
+}
+
+// ExportProfile 是使用者的基本資料與帳號狀態。
+type ExportProfile struct {
+	ID                int64      `json:"id"`
+	Username          string     `json:"username"`
+	Email             string     `json:"email,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastLoginAt       *time.Time `json:"last_login_at"`
+	LastLoginIP       string     `json:"last_login_ip,omitempty"`
+	IsBanned          bool       `json:"is_banned"`
+	MustResetPassword bool       `json:"must_reset_password"`
+	DeletedAt         *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ExportSession 是 sessions 表的一筆紀錄，revoked_by 說明是使用者登出、admin 踢除或系統撤銷。
+type ExportSession struct {
+	ID        string     `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy string     `json:"revoked_by,omitempty"`
+}
+
+// ExportLoginEvent 是 login_events 表的一筆紀錄。
+type ExportLoginEvent struct {
+	CreatedAt time.Time `json:"created_at"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Country   string    `json:"country,omitempty"`
+}
+
+// ExportUsernameChange 是 username_changes 表的一筆紀錄。
+type ExportUsernameChange struct {
+	OldUsername string    `json:"old_username"`
+	NewUsername string    `json:"new_username"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ExportUserData 彙整使用者的個人資料、活躍 session、session 歷史（含撤銷原因）、最近的登入紀錄與 username 變更。
+// 軟刪除但尚未清除的使用者同樣可以匯出；查無使用者時回傳 ErrUserNotFound。
+func (s *SessionService) ExportUserData(ctx context.Context, userID int64) (UserExport, error) {
+	u, err := s.q.GetUserByID(ctx, userID)
+	if err == sql.ErrNoRows {
+		u, err = s.q.GetDeletedUserByID(ctx, userID)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return UserExport{}, ErrUserNotFound
+		}
+		return UserExport{}, err
+	}
+
+	export := UserExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: ExportProfile{
+			ID:                u.ID,
+			Username:          u.Username,
+			Email:             u.Email.String,
+			CreatedAt:         u.CreatedAt,
+			LastLoginAt:       nullTimePtr(u.LastLoginAt),
+			LastLoginIP:       u.LastLoginIp.String,
+			IsBanned:          u.IsBanned,
+			MustResetPassword: u.MustResetPassword,
+			DeletedAt:         nullTimePtr(u.DeletedAt),
+		},
+		ActiveSessions:  []ActiveSessionInfo{},
+		SessionHistory:  []ExportSession{},
+		LoginEvents:     []ExportLoginEvent{},
+		UsernameChanges: []ExportUsernameChange{},
+	}
+
+	// ban 狀態與 Login 相同，DB 與 Redis flag 任一成立即視為被 ban
+	if n, err := s.rdb.Exists(ctx, infra.BannedUserKey(userID)).Result(); err == nil && n > 0 {
+		export.Profile.IsBanned = true
+	}
+
+	active, err := s.ListActiveSessions(ctx, userID, ListSessionsOptions{})
+	if err != nil {
+		return UserExport{}, err
+	}
+	export.ActiveSessions = append(export.ActiveSessions, active...)
+
+	sessions, err := s.q.ListSessionsByUser(ctx, userID)
+	if err != nil {
+		return UserExport{}, err
+	}
+	for _, row := range sessions {
+		export.SessionHistory = append(export.SessionHistory, ExportSession{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			ExpiresAt: row.ExpiresAt,
+			RevokedAt: nullTimePtr(row.RevokedAt),
+			RevokedBy: row.RevokedBy.String,
+		})
+	}
+
+	events, err := s.q.ListLoginEventsByUser(ctx, db.ListLoginEventsByUserParams{
+		UserID: userID,
+		Limit:  exportLoginEventsLimit,
+	})
+	if err != nil {
+		return UserExport{}, err
+	}
+	for _, e := range events {
+		export.LoginEvents = append(export.LoginEvents, ExportLoginEvent{
+			CreatedAt: e.CreatedAt,
+			Success:   e.Success,
+			Reason:    e.Reason.String,
+			IP:        e.Ip.String,
+			UserAgent: e.UserAgent.String,
+			Country:   e.Country.String,
+		})
+	}
+
+	changes, err := s.q.ListUsernameChangesByUser(ctx, userID)
+	if err != nil {
+		return UserExport{}, err
+	}
+	for _, ch := range changes {
+		export.UsernameChanges = append(export.UsernameChanges, ExportUsernameChange{
+			OldUsername: ch.OldUsername,
+			NewUsername: ch.NewUsername,
+			CreatedAt:   ch.CreatedAt,
+		})
+	}
+
+	return export, nil
+}
+
+// nullTimePtr 將 sql.NullTime 轉成 *time.Time，讓 JSON 在沒有值時輸出 null 或省略。
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,29 @@
+package session
+
+import (
+	"context"
+)
+
+// 可在執行期以 POST /admin/flags/:name 切換的 feature flag，Redis 沒有設定時沿用設定檔中對應的值。
+const (
+	FlagSessionDBFallback      = "session_db_fallback"       // SESSION_DB_FALLBACK
+	FlagExtendSessionOnRefresh = "extend_session_on_refresh" // EXTEND_SESSION_ON_REFRESH
+)
+
+// SetFeatureFlag 在執行期切換 feature flag，本 instance 立即生效，其他 instance 在 FeatureFlagsRefresh 內生效；
+// name 不是上列 flag 時回傳 flags.ErrUnknownFlag。
+func (s *SessionService) SetFeatureFlag(ctx context.Context, name string, enabled bool) error {
+	return s.flags.Set(ctx, name, enabled)
+}
+
+// FeatureFlag 回傳 feature flag 目前生效的值（Redis 優先，否則為設定檔的值）。
+func (s *SessionService) FeatureFlag(ctx context.Context, name string) bool {
+	switch name {
+	case FlagSessionDBFallback:
+		return s.flags.Enabled(ctx, name, s.cfg.SessionDBFallback)
+	case FlagExtendSessionOnRefresh:
+		return s.flags.Enabled(ctx, name, s.cfg.ExtendSessionOnRefresh)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,76 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+
+	"sessionservice/internal/db"
+)
+
+// ErrCountryBlocked 表示登入來源國家不在 LOGIN_COUNTRY_ALLOWLIST 內、在 LOGIN_COUNTRY_DENYLIST 內，
+// 或查不出國家且 LOGIN_COUNTRY_UNKNOWN_POLICY=deny。
+var ErrCountryBlocked = errors.New("login from this country is not allowed")
+
+// LoginCountryUnknownPolicy 的值：查不出來源國家（私有 IP、查詢失敗）時放行或拒絕。
+const (
+	LoginCountryUnknownAllow = "allow"
+	LoginCountryUnknownDeny  = "deny"
+)
+
+// GeoResolver 依 IP 查詢國碼（ISO 3166-1 alpha-2），查不到時回傳空字串。查詢在登入流程中同步進行，實作應使用本機資料庫等快速來源。
+type GeoResolver interface {
+	CountryForIP(ctx context.Context, ip string) (string, error)
+}
+
+// WithGeoResolver 設定 GeoResolver；未設定時只依 GEO_COUNTRY_HEADER 標注的國碼判斷。
+func (s *SessionService) WithGeoResolver(r GeoResolver) *SessionService {
+	s.geo = r
+	return s
+}
+
+// loginCountry 回傳這次登入的國碼：優先使用 proxy 標注的 meta.Country，沒有時對公開 IP 查詢 GeoResolver。
+// 私有、loopback 位址、查詢失敗，以及 Cloudflare 代表未知的 XX 一律回傳空字串。
+func (s *SessionService) loginCountry(ctx context.Context, meta LoginMeta) string {
+	country := strings.ToUpper(meta.Country)
+	if country == "" && s.geo != nil {
+		if ip := net.ParseIP(meta.IP); ip != nil && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsUnspecified() {
+			if resolved, err := s.geo.CountryForIP(ctx, ip.String()); err == nil {
+				country = strings.ToUpper(resolved)
+			}
+		}
+	}
+	if country == "XX" {
+		return ""
+	}
+	return country
+}
+
+// checkLoginCountry 在建立 session 前依 LOGIN_COUNTRY_ALLOWLIST / LOGIN_COUNTRY_DENYLIST 檢查來源國家，兩者皆未設定時不檢查。
+// DENYLIST 優先；設定了 ALLOWLIST 時不在清單內的國家一律拒絕。拒絕時記一筆 login:audit 並回傳 ErrCountryBlocked。
+// 回傳的國碼（可能由 GeoResolver 查出）供後續寫入 session 與 audit。
+func (s *SessionService) checkLoginCountry(ctx context.Context, u db.User, meta LoginMeta) (string, error) {
+	if len(s.cfg.LoginCountryAllowlist) == 0 && len(s.cfg.LoginCountryDenylist) == 0 {
+		return meta.Country, nil
+	}
+
+	country := s.loginCountry(ctx, meta)
+	var blocked bool
+	switch {
+	case country == "":
+		blocked = s.cfg.LoginCountryUnknownPolicy == LoginCountryUnknownDeny
+	case slices.Contains(s.cfg.LoginCountryDenylist, country):
+		blocked = true
+	case len(s.cfg.LoginCountryAllowlist) > 0:
+		blocked = !slices.Contains(s.cfg.LoginCountryAllowlist, country)
+	}
+
+	meta.Country = country
+	if blocked {
+		s.auditLoginFailure(ctx, &u.ID, u.Username, LoginReasonCountryBlocked, meta)
+		return "", ErrCountryBlocked
+	}
+	return country, nil
+}
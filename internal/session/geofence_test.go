@@ -0,0 +1,63 @@
+package session
+
+import (
+	"context" // 匯入 context，實作測試用 GeoResolver
+	"errors"  // 匯入 errors，模擬查詢失敗
+	"testing" // 匯入 testing，提供單元測試框架
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// mockGeoResolver 依固定的 IP 對照表回傳國碼，表中沒有的 IP 視為查詢失敗。
+type mockGeoResolver map[string]string
+
+func (m mockGeoResolver) CountryForIP(_ context.Context, ip string) (string, error) {
+	country, ok := m[ip] // 查對照表
+	if !ok {
+		return "", errors.New("lookup failed") // 模擬查詢失敗
+	}
+	return country, nil // 回傳國碼
+}
+
+// TestLoginCountryGeofence 測試 allowlist / denylist 依 header 或 GeoResolver 查出的國碼放行或拒絕登入，
+// 查不出國家（私有 IP、查詢失敗、XX）時依 LoginCountryUnknownPolicy 處理。
+func TestLoginCountryGeofence(t *testing.T) {
+	geo := mockGeoResolver{"203.0.113.1": "tw", "198.51.100.1": "US", "192.0.2.1": "XX"} // 測試用 IP 對照表
+
+	for _, tc := range []struct {
+		name    string
+		allow   []string
+		deny    []string
+		unknown string
+		meta    LoginMeta
+		blocked bool
+	}{
+		{"header denied", nil, []string{"RU"}, "allow", LoginMeta{IP: "203.0.113.1", Country: "ru"}, true},    // header 標注的國家在 denylist
+		{"resolver allowed", []string{"TW"}, nil, "allow", LoginMeta{IP: "203.0.113.1"}, false},               // 查出 TW，在 allowlist
+		{"resolver not allowed", []string{"TW"}, nil, "allow", LoginMeta{IP: "198.51.100.1"}, true},           // 查出 US，不在 allowlist
+		{"deny wins over allow", []string{"TW"}, []string{"TW"}, "allow", LoginMeta{IP: "203.0.113.1"}, true}, // 兩邊都有時以 denylist 為準
+		{"private ip allowed", []string{"TW"}, nil, "allow", LoginMeta{IP: "10.0.0.1"}, false},                // 私有 IP 不查詢，依 allow 放行
+		{"private ip denied", []string{"TW"}, nil, "deny", LoginMeta{IP: "10.0.0.1"}, true},                   // 私有 IP 不查詢，依 deny 拒絕
+		{"lookup failure denied", nil, []string{"RU"}, "deny", LoginMeta{IP: "198.51.100.2"}, true},           // 查詢失敗視為未知
+		{"unknown code allowed", nil, []string{"RU"}, "allow", LoginMeta{IP: "192.0.2.1"}, false},             // XX 視為未知
+		{"no lists", nil, nil, "deny", LoginMeta{IP: "10.0.0.1"}, false},                                      // 未設定清單時不檢查
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t)                           // 建立測試環境
+			env.cfg.LoginCountryAllowlist = tc.allow       // 設定 allowlist
+			env.cfg.LoginCountryDenylist = tc.deny         // 設定 denylist
+			env.cfg.LoginCountryUnknownPolicy = tc.unknown // 設定未知國家的處理方式
+			env.sessSvc.WithGeoResolver(geo)               // 使用測試用 GeoResolver
+			hashed, err := bcryptGenerate("password123")   // 產生密碼雜湊
+			require.NoError(t, err)                        // 應產生成功
+			createTestUser(t, env, "alice", hashed)        // 建立使用者
+
+			_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "password123", tc.meta) // 登入
+			if tc.blocked {
+				require.ErrorIs(t, err, ErrCountryBlocked) // 應回傳 ErrCountryBlocked
+			} else {
+				require.NoError(t, err) // 應登入成功
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package session
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+const (
+	// globalSessionsDefaultLimit 與 globalSessionsMaxLimit 是 ListAllActiveSessions 每頁筆數的預設值與上限。
+	globalSessionsDefaultLimit = 50
+	globalSessionsMaxLimit     = 500
+
+	// globalSessionsScanBatch 是每次 SCAN user_sess:* 取回的 key 數量。
+	globalSessionsScanBatch = 100
+	// globalSessionsMaxScans 限制單次請求最多 SCAN 幾批，過濾條件很嚴時先回傳目前結果與 cursor，避免一次掃完整個 keyspace。
+	globalSessionsMaxScans = 20
+)
+
+// GlobalSessionsOptions 是 ListAllActiveSessions 的分頁與過濾條件。
+type GlobalSessionsOptions struct {
+	Cursor string        // 上一頁回傳的 NextCursor，空字串代表第一頁
+	Limit  int           // 每頁最多筆數，0 代表預設值
+	IP     string        // 只保留 IP 完全相同的 session
+	Device string        // 只保留 user agent 包含此字串的 session（不分大小寫）
+	MinAge time.Duration // 只保留建立至今至少這麼久的 session，0 代表不限制
+	MaxAge time.Duration // 只保留建立至今不超過這麼久的 session，0 代表不限制
+}
+
+// GlobalSessionInfo 是跨使用者列表中的一個 session，附帶擁有者。
+type GlobalSessionInfo struct {
+	UserID int64 `json:"user_id"`
+	ActiveSessionInfo
+}
+
+// GlobalSessionsPage 是 ListAllActiveSessions 的一頁結果，NextCursor 為空代表已經沒有下一頁。
+type GlobalSessionsPage struct {
+	Sessions   []GlobalSessionInfo `json:"sessions"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// globalCursor 記錄下一頁從哪裡繼續：SCAN 的 cursor、該批 key 中已處理的 user 數，以及下一個 user 已回傳的 session 數。
+type globalCursor struct {
+	scan    uint64
+	user    int
+	session int
+}
+
+func (c globalCursor) encode() string {
+	raw := fmt.Sprintf("%d:%d:%d", c.scan, c.user, c.session)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeGlobalCursor(s string) (globalCursor, error) {
+	if s == "" {
+		return globalCursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return globalCursor{}, ErrInvalidListOptions
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return globalCursor{}, ErrInvalidListOptions
+	}
+	scan, err1 := strconv.ParseUint(parts[0], 10, 64)
+	user, err2 := strconv.Atoi(parts[1])
+	sess, err3 := strconv.Atoi(parts[2])
+	if err1 != nil || err2 != nil || err3 != nil || user < 0 || sess < 0 {
+		return globalCursor{}, ErrInvalidListOptions
+	}
+	return globalCursor{scan: scan, user: user, session: sess}, nil
+}
+
+// ListAllActiveSessions 以 SCAN 逐批走訪 user_sess:*，列出所有使用者的活躍 session 並在 server 端過濾。
+// 每批只讀回該批 user 的 session，記憶體用量與總 session 數無關。
+// 分頁以不透明的 cursor 表示；與 SCAN 相同，翻頁期間新增或刪除的 session 可能被略過或重複出現。
+func (s *SessionService) ListAllActiveSessions(ctx context.Context, opts GlobalSessionsOptions) (GlobalSessionsPage, error) {
+	cur, err := decodeGlobalCursor(opts.Cursor)
+	if err != nil {
+		return GlobalSessionsPage{}, err
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = globalSessionsDefaultLimit
+	}
+	limit = min(limit, globalSessionsMaxLimit)
+
+	page := GlobalSessionsPage{Sessions: []GlobalSessionInfo{}}
+	device := strings.ToLower(opts.Device)
+	now := time.Now()
+
+	for scans := 0; scans < globalSessionsMaxScans; scans++ {
+		keys, next, err := s.rdb.Scan(ctx, cur.scan, infra.UserSessKeyPattern(), globalSessionsScanBatch).Result()
+		if err != nil {
+			return GlobalSessionsPage{}, err
+		}
+		if cur.user > len(keys) {
+			cur.user = len(keys)
+		}
+		keys = keys[cur.user:]
+
+		// 先以 pipeline 讀回這批 user 的 session ID，再一次讀回所有 session hash
+		pipe := s.rdb.Pipeline()
+		ranges := make([]*redis.StringSliceCmd, len(keys))
+		for i, key := range keys {
+			ranges[i] = pipe.ZRange(ctx, key, 0, -1)
+		}
+		if len(keys) > 0 {
+			if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+				return GlobalSessionsPage{}, err
+			}
+		}
+		pipe = s.rdb.Pipeline()
+		hashes := make([][]*redis.MapStringStringCmd, len(keys))
+		queued := 0
+		for i := range keys {
+			sids := ranges[i].Val()
+			if i == 0 {
+				sids = sids[min(cur.session, len(sids)):]
+			}
+			hashes[i] = make([]*redis.MapStringStringCmd, len(sids))
+			for j, sid := range sids {
+				hashes[i][j] = pipe.HGetAll(ctx, infra.SessKey(sid))
+				queued++
+			}
+		}
+		if queued > 0 {
+			if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+				return GlobalSessionsPage{}, err
+			}
+		}
+
+		for i, key := range keys {
+			userID, ok := infra.ParseUserSessKey(key)
+			if !ok {
+				continue
+			}
+			skipped := 0
+			if i == 0 {
+				skipped = cur.session
+			}
+			sids := ranges[i].Val()
+			for j, cmd := range hashes[i] {
+				if len(page.Sessions) == limit {
+					page.NextCursor = globalCursor{scan: cur.scan, user: cur.user + i, session: skipped + j}.encode()
+					return page, nil
+				}
+				info, ok := globalSessionMatch(cmd.Val(), sids[skipped+j], opts, device, now)
+				if ok {
+					page.Sessions = append(page.Sessions, GlobalSessionInfo{UserID: userID, ActiveSessionInfo: info})
+				}
+			}
+		}
+
+		if next == 0 {
+			return page, nil
+		}
+		cur = globalCursor{scan: next}
+		if len(page.Sessions) == limit {
+			break
+		}
+	}
+	page.NextCursor = cur.encode()
+	return page, nil
+}
+
+// globalSessionMatch 將 session hash 轉成 ActiveSessionInfo 並套用過濾條件；hash 已過期（zset 還留著）時不列出。
+func globalSessionMatch(data map[string]string, sid string, opts GlobalSessionsOptions, device string, now time.Time) (ActiveSessionInfo, bool) {
+	if len(data) == 0 {
+		return ActiveSessionInfo{}, false
+	}
+	if opts.IP != "" && data["ip"] != opts.IP {
+		return ActiveSessionInfo{}, false
+	}
+	if device != "" && !strings.Contains(strings.ToLower(data["user_agent"]), device) {
+		return ActiveSessionInfo{}, false
+	}
+	createdAt, _ := strconv.ParseInt(data["created_at"], 10, 64)
+	age := now.Sub(time.Unix(createdAt, 0))
+	if opts.MinAge > 0 && age < opts.MinAge {
+		return ActiveSessionInfo{}, false
+	}
+	if opts.MaxAge > 0 && age > opts.MaxAge {
+		return ActiveSessionInfo{}, false
+	}
+	lastSeen, err := strconv.ParseInt(data["last_seen"], 10, 64)
+	if err != nil {
+		lastSeen = createdAt
+	}
+	return ActiveSessionInfo{
+		SessionID: sid,
+		IP:        data["ip"],
+		UserAgent: data["user_agent"],
+		DeviceID:  data["device_id"],
+		CreatedAt: createdAt,
+		LastSeen:  lastSeen,
+		Pinned:    data["pinned"] == "1",
+
+		RequestCount: sessionRequestCount(data),
+	}, true
+}
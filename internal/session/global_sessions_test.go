@@ -0,0 +1,51 @@
+package session
+
+import (
+	"fmt"     // 匯入 fmt，組出測試用 session ID
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，寫入建立時間
+
+	"github.com/redis/go-redis/v9"        // 匯入 go-redis，寫入 zset 成員
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra，組出 Redis key
+)
+
+// TestListAllActiveSessionsAcrossScanBatches 測試 user 數超過一批 SCAN 時，cursor 仍能不重複、不遺漏地走完所有 session。
+func TestListAllActiveSessionsAcrossScanBatches(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	const users = 250        // 超過兩批 SCAN
+	now := time.Now().Unix() // 建立時間
+	for uid := int64(1); uid <= users; uid++ {
+		for n := 0; n < 2; n++ {
+			sid := fmt.Sprintf("sid-%d-%d", uid, n)                                                                          // 每個使用者兩個 session
+			require.NoError(t, env.rdb.HSet(env.ctx, infra.SessKey(sid), "user_id", uid, "created_at", now).Err())           // 寫入 session hash
+			require.NoError(t, env.rdb.ZAdd(env.ctx, infra.UserSessKey(uid), redis.Z{Score: float64(n), Member: sid}).Err()) // 加入使用者的 zset
+		}
+	}
+	require.NoError(t, env.rdb.ZAdd(env.ctx, infra.UserSessKey(999), redis.Z{Score: 1, Member: "sid-gone"}).Err()) // hash 已過期的殘留成員
+
+	seen := map[string]int64{} // 已列出的 session 與擁有者
+	cursor := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 100) // 防止 cursor 出錯時無限迴圈
+		page, err := env.sessSvc.ListAllActiveSessions(env.ctx, GlobalSessionsOptions{Cursor: cursor, Limit: 37})
+		require.NoError(t, err)                        // 應成功
+		require.LessOrEqual(t, len(page.Sessions), 37) // 每頁不超過 limit
+		for _, s := range page.Sessions {
+			_, dup := seen[s.SessionID]
+			require.False(t, dup, s.SessionID)                                                   // 不應重複
+			require.Equal(t, fmt.Sprintf("sid-%d-", s.UserID), s.SessionID[:len(s.SessionID)-1]) // 擁有者正確
+			seen[s.SessionID] = s.UserID
+		}
+		if page.NextCursor == "" {
+			break // 走完所有頁
+		}
+		cursor = page.NextCursor
+	}
+	require.Len(t, seen, users*2) // 所有 session 都列出，殘留成員不列出
+
+	_, err := env.sessSvc.ListAllActiveSessions(env.ctx, GlobalSessionsOptions{Cursor: "%%%"}) // 不合法的 cursor
+	require.ErrorIs(t, err, ErrInvalidListOptions)                                             // 應回傳錯誤
+}
@@ -0,0 +1,36 @@
+package session
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+// touchLastSeenScript 只在 hash 仍存在時寫入 last_seen，避免 session 剛好過期時重新建立一個沒有 TTL 的 hash。
+var touchLastSeenScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+  return redis.call('HSET', KEYS[1], 'last_seen', ARGV[1])
+end
+return 0
+`)
+
+// touchLastSeen 更新 sess:{sid} 的 last_seen，但同一個 session 在 LastSeenInterval 內只寫一次。
+// stored 是呼叫端剛從 hash 讀到的 last_seen，直接與現在時間比較，不需要額外的 Redis 讀取；
+// 頻繁發請求的 client 因此每個區間最多只產生一次寫入。寫入失敗不影響請求。
+func (s *SessionService) touchLastSeen(ctx context.Context, sessKey, stored string) {
+	if s.cfg.LastSeenInterval <= 0 {
+		return
+	}
+
+	now := time.Now()
+	if last, err := strconv.ParseInt(stored, 10, 64); err == nil && now.Sub(time.Unix(last, 0)) < s.cfg.LastSeenInterval {
+		return
+	}
+	if err := touchLastSeenScript.Run(ctx, s.rdb, []string{sessKey}, now.Unix()).Err(); err != nil {
+		infra.LogError("last_seen: update failed: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package session
+
+import (
+	"context"     // 匯入 context，實作 go-redis hook
+	"strconv"     // 匯入 strconv，寫入與解析 last_seen
+	"sync/atomic" // 匯入 sync/atomic，計算寫入次數
+	"testing"     // 匯入 testing，提供單元測試框架
+	"time"        // 匯入 time，設定寫入間隔
+
+	"github.com/redis/go-redis/v9"        // 匯入 go-redis，實作 hook
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra，讀取 session key
+)
+
+// lastSeenWriteCounter 是計算成功執行的 last_seen 寫入腳本次數的 go-redis hook。
+type lastSeenWriteCounter struct{ n atomic.Int64 }
+
+func (h *lastSeenWriteCounter) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *lastSeenWriteCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (h *lastSeenWriteCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if (cmd.Name() == "eval" || cmd.Name() == "evalsha") && err == nil {
+			h.n.Add(1) // EVALSHA 遇到 NOSCRIPT 後改用 EVAL，只計算成功的那一次
+		}
+		return err
+	}
+}
+
+// TestLastSeenWriteThrottled 測試短時間內重複驗證 session 只寫入一次 last_seen，超過間隔後才會再寫。
+func TestLastSeenWriteThrottled(t *testing.T) {
+	env := newTestEnv(t)                        // 建立測試環境
+	env.cfg.LastSeenInterval = 30 * time.Second // 每 30 秒最多寫一次
+
+	hashed, err := bcryptGenerate("password123")                                      // 產生雜湊
+	require.NoError(t, err)                                                           // 確保成功
+	user := createTestUser(t, env, "alice", hashed)                                   // 建立使用者
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 建立 session
+	require.NoError(t, err)                                                           // 應登入成功
+
+	counter := &lastSeenWriteCounter{} // 從這裡開始計算寫入次數
+	env.rdb.AddHook(counter)
+
+	for i := 0; i < 20; i++ { // 模擬頻繁發請求的 client
+		ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid)
+		require.NoError(t, err) // 檢查不應失敗
+		require.True(t, ok)     // session 應有效
+	}
+	require.EqualValues(t, 1, counter.n.Load()) // 間隔內只寫一次
+
+	stored, err := env.rdb.HGet(env.ctx, infra.SessKey(sid), "last_seen").Int64() // 讀取 last_seen
+	require.NoError(t, err)                                                       // 應已寫入
+	require.InDelta(t, time.Now().Unix(), stored, 2)                              // 應為現在時間
+
+	stale := strconv.FormatInt(time.Now().Add(-31*time.Second).Unix(), 10)                  // 超過間隔的舊時間
+	require.NoError(t, env.rdb.HSet(env.ctx, infra.SessKey(sid), "last_seen", stale).Err()) // 模擬上次寫入已過 31 秒
+	ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid)                            // 再驗證一次
+	require.NoError(t, err)                                                                 // 檢查不應失敗
+	require.True(t, ok)                                                                     // session 應有效
+	require.EqualValues(t, 2, counter.n.Load())                                             // 超過間隔後再寫一次
+}
+
+// TestLastSeenDisabled 測試 LastSeenInterval 為 0 時不會寫入 last_seen。
+func TestLastSeenDisabled(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境，LastSeenInterval 預設為 0
+
+	hashed, err := bcryptGenerate("password123")                                      // 產生雜湊
+	require.NoError(t, err)                                                           // 確保成功
+	user := createTestUser(t, env, "alice", hashed)                                   // 建立使用者
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 建立 session
+	require.NoError(t, err)                                                           // 應登入成功
+
+	ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid)   // 驗證 session
+	require.NoError(t, err)                                        // 檢查不應失敗
+	require.True(t, ok)                                            // session 應有效
+	require.Empty(t, env.mr.HGet(infra.SessKey(sid), "last_seen")) // 不應寫入 last_seen
+}
@@ -0,0 +1,42 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"sessionservice/internal/infra"
+)
+
+// login:audit 的 Reason；資安分析依此分類登入嘗試，新增失敗路徑時請在此定義新的值，不要重複使用既有的 reason。
+const (
+	LoginReasonOK                 = "ok"
+	LoginReasonUserNotFound       = "user_not_found"
+	LoginReasonWrongPassword      = "wrong_password"
+	LoginReasonBannedDB           = "banned_db"
+	LoginReasonBannedRedis        = "banned_redis"
+	LoginReasonMustResetPassword  = "must_reset_password"
+	LoginReasonPasswordExpired    = "password_expired"
+	LoginReasonRateLimited        = "login_rate_limited"
+	LoginReasonSessionLimitPinned = "session_limit_pinned"
+	LoginReasonCountryBlocked     = "country_blocked"
+	LoginReasonMultiCountry       = "multi_country_session"
+	LoginReasonInvalidResetToken  = "invalid_reset_token"
+)
+
+// auditLoginFailure 排入一筆失敗的 login:audit。userID 為 nil 代表帳號不存在，此時 username 為正規化後的嘗試值，
+// 讓猜帳號的攻擊也能依 username、IP 分析。
+func (s *SessionService) auditLoginFailure(ctx context.Context, userID *int64, username, reason string, meta LoginMeta) {
+	err := infra.EnqueueLoginAudit(ctx, s.asynqClient, infra.LoginAuditPayload{
+		UserID:    userID,
+		Username:  username,
+		Success:   false,
+		Reason:    reason,
+		IP:        meta.IP,
+		UserAgent: meta.UserAgent,
+		Country:   meta.Country,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		infra.LogError("login audit: enqueue failed: %v", err)
+	}
+}
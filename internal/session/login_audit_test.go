@@ -0,0 +1,110 @@
+package session
+
+import (
+	"encoding/json" // 匯入 encoding/json，解析任務 payload
+	"testing"       // 匯入 testing，提供單元測試框架
+	"time"          // 匯入 time，設定登入次數視窗
+
+	"github.com/hibiken/asynq"            // 匯入 asynq，排入並檢查 login:audit 任務
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra，取得任務型別與 key
+)
+
+// auditedLogins 列出目前排入的 login:audit 任務並清空佇列，讓每個情境只看到自己產生的事件。
+func auditedLogins(t *testing.T, inspector *asynq.Inspector) []infra.LoginAuditPayload {
+	t.Helper() // 標記為測試輔助函式
+
+	tasks, err := inspector.ListPendingTasks("default") // 列出待處理的任務
+	require.NoError(t, err)                             // 查詢應成功
+	var events []infra.LoginAuditPayload
+	for _, task := range tasks {
+		if task.Type != infra.TaskTypeLoginAudit {
+			continue // 只看 login:audit
+		}
+		var p infra.LoginAuditPayload
+		require.NoError(t, json.Unmarshal(task.Payload, &p)) // payload 應為合法 JSON
+		events = append(events, p)
+	}
+	_, err = inspector.DeleteAllPendingTasks("default") // 清空佇列
+	require.NoError(t, err)                             // 應成功
+	return events
+}
+
+// TestLoginFailuresEnqueueAudit 測試每一種登入失敗都排入帶有對應 reason 與嘗試 username 的 login:audit。
+func TestLoginFailuresEnqueueAudit(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	opt := asynq.RedisClientOpt{Addr: env.mr.Addr()}                      // asynq 與 session 共用 miniredis
+	client := asynq.NewClient(opt)                                        // 建立 asynq client
+	defer client.Close()                                                  // 測試結束時關閉
+	inspector := asynq.NewInspector(opt)                                  // 建立 inspector 以檢查排入的任務
+	defer inspector.Close()                                               // 測試結束時關閉
+	env.sessSvc = NewSessionService(env.q, env.rdb, env.cfg, client, nil) // 改用會排任務的 SessionService
+
+	hashed, err := bcryptGenerate("password123")     // 產生雜湊
+	require.NoError(t, err)                          // 確保成功
+	alice := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	meta := LoginMeta{IP: "203.0.113.9", UserAgent: "curl/8.0"} // 攻擊來源
+	expectFailure := func(userID *int64, username, reason string) {
+		t.Helper()                                     // 標記為測試輔助函式
+		events := auditedLogins(t, inspector)          // 取出這次登入排入的事件
+		require.Len(t, events, 1)                      // 每次失敗只有一筆
+		require.False(t, events[0].Success)            // 標記為失敗
+		require.Equal(t, reason, events[0].Reason)     // reason 對應失敗原因
+		require.Equal(t, username, events[0].Username) // 帶著嘗試的 username
+		require.Equal(t, userID, events[0].UserID)     // 帳號不存在時為 nil
+		require.Equal(t, meta.IP, events[0].IP)        // 帶著來源 IP
+		require.False(t, events[0].CreatedAt.IsZero()) // 帶著嘗試當下的時間
+	}
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, " Mallory ", "password123", meta) // 不存在的帳號
+	require.ErrorIs(t, err, ErrInvalidCredentials)                              // 回傳帳密錯誤
+	expectFailure(nil, "mallory", LoginReasonUserNotFound)                      // username 為正規化後的嘗試值
+
+	_, _, _, err = env.sessSvc.LoginTrusted(env.ctx, "mallory", meta) // signed login 同樣會稽核不存在的帳號
+	require.ErrorIs(t, err, ErrInvalidCredentials)                    // 回傳帳密錯誤
+	expectFailure(nil, "mallory", LoginReasonUserNotFound)            // 同樣的 reason
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "wrong-password", meta) // 密碼錯誤
+	require.ErrorIs(t, err, ErrInvalidCredentials)                             // 回傳帳密錯誤
+	expectFailure(&alice.ID, "alice", LoginReasonWrongPassword)                // 帶著 user_id
+
+	require.NoError(t, env.rdb.Set(env.ctx, infra.BannedUserKey(alice.ID), "1", 0).Err()) // 只設定 Redis ban flag
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "password123", meta)               // 嘗試登入
+	require.ErrorIs(t, err, ErrUserBanned)                                                // 被 ban
+	expectFailure(&alice.ID, "alice", LoginReasonBannedRedis)                             // 來自 Redis flag
+
+	require.NoError(t, env.sessSvc.BanUser(env.ctx, alice.ID))              // DB 也標記 ban
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "password123", meta) // 嘗試登入
+	require.ErrorIs(t, err, ErrUserBanned)                                  // 被 ban
+	expectFailure(&alice.ID, "alice", LoginReasonBannedDB)                  // 先檢查 DB
+	require.NoError(t, env.sessSvc.UnbanUser(env.ctx, alice.ID))            // 解除封鎖
+
+	bob := createTestUser(t, env, "bob", hashed)                          // 另一個使用者
+	require.NoError(t, env.sessSvc.ForceResetPassword(env.ctx, bob.ID))   // 強制重設密碼
+	_, _, _, err = env.sessSvc.Login(env.ctx, "bob", "password123", meta) // 嘗試登入
+	require.ErrorIs(t, err, ErrPasswordResetRequired)                     // 必須先重設
+	expectFailure(&bob.ID, "bob", LoginReasonMustResetPassword)           // 對應 reason
+
+	env.cfg.PinnedLimitPolicy = PinnedLimitReject                              // 全部 pin 時拒絕登入
+	env.cfg.MaxSessionsPerUser = 1                                             // 只允許一個 session
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", meta) // 第一個 session
+	require.NoError(t, err)                                                    // 應成功
+	require.NoError(t, env.sessSvc.PinSession(env.ctx, alice.ID, sid, true))   // pin 住
+	auditedLogins(t, inspector)                                                // 略過成功登入的事件
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "password123", meta)    // 第二次登入
+	require.ErrorIs(t, err, ErrSessionLimitReached)                            // 無法踢除
+	expectFailure(&alice.ID, "alice", LoginReasonSessionLimitPinned)           // 對應 reason
+
+	env.cfg.MaxSessionsPerUser = 0                                          // 不限制 session 數
+	env.cfg.LoginRateLimit = 1                                              // 視窗內只允許一次登入
+	env.cfg.LoginRateLimitWindow = time.Minute                              // 一分鐘視窗
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "password123", meta) // 視窗內第一次登入
+	require.NoError(t, err)                                                 // 應成功
+	auditedLogins(t, inspector)                                             // 略過成功登入的事件
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "password123", meta) // 視窗內第二次登入
+	require.ErrorIs(t, err, ErrLoginRateLimited)                            // 超過次數
+	expectFailure(&alice.ID, "alice", LoginReasonRateLimited)               // 對應 reason
+}
@@ -0,0 +1,43 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"sessionservice/internal/db"
+	"sessionservice/internal/infra"
+)
+
+// ErrLoginRateLimited 表示使用者在 LoginRateLimitWindow 內的成功登入次數已達上限；實際回傳的是 *LoginRateLimitError。
+var ErrLoginRateLimited = errors.New("too many logins for user")
+
+// LoginRateLimitError 帶有視窗剩餘的時間，errors.Is(err, ErrLoginRateLimited) 成立。
+type LoginRateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginRateLimitError) Error() string {
+	return ErrLoginRateLimited.Error()
+}
+
+func (e *LoginRateLimitError) Is(target error) bool {
+	return target == ErrLoginRateLimited
+}
+
+// checkLoginRate 在密碼驗證通過、建立 session 前，以固定視窗計算該使用者的登入次數，超過 LoginRateLimit 時回傳 *LoginRateLimitError。
+// 與登入失敗的鎖定分開計算；Redis 故障時不阻擋登入。
+func (s *SessionService) checkLoginRate(ctx context.Context, u db.User, meta LoginMeta) error {
+	if s.cfg.LoginRateLimit <= 0 {
+		return nil
+	}
+
+	key := infra.RateLimitKey("login_user", stringFromInt64(u.ID))
+	allowed, retryAfter, err := infra.AllowRate(ctx, s.rdb, key, s.cfg.LoginRateLimit, s.cfg.LoginRateLimitWindow)
+	if err != nil || allowed {
+		return nil
+	}
+
+	s.auditLoginFailure(ctx, &u.ID, u.Username, LoginReasonRateLimited, meta)
+	return &LoginRateLimitError{RetryAfter: retryAfter}
+}
@@ -0,0 +1,43 @@
+package session
+
+import (
+	"errors"  // 匯入 errors，取出 LoginRateLimitError
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，設定計算視窗
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// TestLoginRateLimitPerUser 測試同一使用者短時間內重複登入會碰到上限，其他使用者不受影響。
+func TestLoginRateLimitPerUser(t *testing.T) {
+	env := newTestEnv(t)                     // 建立測試環境
+	env.cfg.LoginRateLimit = 3               // 每個視窗最多登入 3 次
+	env.cfg.LoginRateLimitWindow = time.Hour // 視窗長度 1 小時
+
+	hashed, err := bcryptGenerate("password123") // 產生雜湊
+	require.NoError(t, err)                      // 確保成功
+	createTestUser(t, env, "alice", hashed)      // 建立 alice
+	createTestUser(t, env, "bob", hashed)        // 建立 bob
+
+	for i := 0; i < 3; i++ {
+		_, _, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 上限內的登入
+		require.NoError(t, err)                                                         // 應登入成功
+	}
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 超過上限
+	require.ErrorIs(t, err, ErrLoginRateLimited)                                   // 應回傳 ErrLoginRateLimited
+	var rateErr *LoginRateLimitError
+	require.True(t, errors.As(err, &rateErr))                                // 帶有剩餘時間
+	require.InDelta(t, time.Hour.Seconds(), rateErr.RetryAfter.Seconds(), 5) // 剩餘時間接近整個視窗
+	require.Equal(t, LoginOutcomeRateLimited, loginOutcome(err))             // metrics outcome 獨立計算
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "wrong-password", LoginMeta{}) // 密碼錯誤不受此上限影響
+	require.ErrorIs(t, err, ErrInvalidCredentials)                                    // 仍回傳帳密錯誤
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, "bob", "password123", LoginMeta{}) // 其他使用者
+	require.NoError(t, err)                                                      // 不受影響
+
+	env.mr.FastForward(time.Hour)                                                  // 視窗結束
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 再次登入
+	require.NoError(t, err)                                                        // 應恢復
+}
@@ -0,0 +1,133 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/db"
+	"sessionservice/internal/infra"
+)
+
+// ErrMagicLinkInvalid 表示 magic link token 不存在、已過期或已被使用。
+var ErrMagicLinkInvalid = errors.New("magic link is invalid or expired")
+
+// ErrInvalidEmail 表示 email 格式不正確。
+var ErrInvalidEmail = errors.New("invalid email")
+
+// NormalizeEmail 去除前後空白並轉成小寫，signup 寫入與 magic link 查詢使用相同規則。
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ValidateEmail 檢查 email 是否為單純的 addr-spec（不接受 "Name <addr>" 形式）。
+func ValidateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return ErrInvalidEmail
+	}
+	return nil
+}
+
+// RequestMagicLink 為 email 對應的使用者產生一次性登入 token，並排入 email:send 寄出連結。
+// 找不到使用者或使用者已被 ban 時不寄信也不回傳錯誤，呼叫端的回應因此無法看出 email 是否存在。
+// Redis 只保存 token 的 SHA-256，效期為 MagicLinkTTL。
+func (s *SessionService) RequestMagicLink(ctx context.Context, email string) error {
+	email = NormalizeEmail(email)
+	if ValidateEmail(email) != nil {
+		return nil
+	}
+
+	u, err := s.q.GetUserByEmail(ctx, sql.NullString{String: email, Valid: true})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	if u.IsBanned {
+		return nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	if err := s.rdb.Set(ctx, infra.MagicLinkKey(magicLinkHash(token)), u.ID, s.cfg.MagicLinkTTL).Err(); err != nil {
+		return err
+	}
+
+	link := s.cfg.MagicLinkURL + "?token=" + url.QueryEscape(token)
+	return infra.EnqueueEmailSend(ctx, s.asynqClient, infra.EmailSendPayload{
+		To:      email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Use the link below to sign in as %s. It expires in %s and can only be used once.\n\n%s\n",
+			u.Username, s.cfg.MagicLinkTTL, link),
+	})
+}
+
+// ConsumeMagicLink 以 GETDEL 取出並刪除 token，確保同一個連結只能登入一次，之後與密碼登入相同地建立 session
+// （檢查 ban、國家、同時登入數並寫入 login audit）。token 不存在或已使用時回傳 ErrMagicLinkInvalid。
+func (s *SessionService) ConsumeMagicLink(
+	ctx context.Context,
+	token string,
+	meta LoginMeta,
+) (user db.User, sessionID string, expiresAt time.Time, err error) {
+	start := time.Now()
+	defer func() {
+		s.metrics.IncrLogin(loginOutcome(err))
+		s.metrics.ObserveLoginLatency(time.Since(start))
+	}()
+
+	if token == "" {
+		return db.User{}, "", time.Time{}, ErrMagicLinkInvalid
+	}
+	val, err := s.rdb.GetDel(ctx, infra.MagicLinkKey(magicLinkHash(token))).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return db.User{}, "", time.Time{}, ErrMagicLinkInvalid
+		}
+		return db.User{}, "", time.Time{}, err
+	}
+	userID, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return db.User{}, "", time.Time{}, ErrMagicLinkInvalid
+	}
+
+	u, err := s.q.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// 寄出連結後使用者已被刪除
+			return db.User{}, "", time.Time{}, ErrMagicLinkInvalid
+		}
+		return db.User{}, "", time.Time{}, err
+	}
+	if err := s.checkNotBanned(ctx, u, meta); err != nil {
+		return db.User{}, "", time.Time{}, err
+	}
+
+	newSID, expiresAt, err := s.startSession(ctx, u, meta)
+	if err != nil {
+		return db.User{}, "", time.Time{}, err
+	}
+	return u, newSID, expiresAt, nil
+}
+
+// magicLinkHash 回傳 magic link token 的 SHA-256（hex），Redis 不保存 token 原文。
+func magicLinkHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,117 @@
+package session
+
+import (
+	"database/sql"  // 匯入 database/sql，設定使用者 email
+	"encoding/json" // 匯入 encoding/json，解析任務 payload
+	"errors"        // 匯入 errors，判斷佇列是否存在
+	"net/url"       // 匯入 net/url，從連結取出 token
+	"strings"       // 匯入 strings，找出信件內的連結
+	"testing"       // 匯入 testing，提供單元測試框架
+	"time"          // 匯入 time，設定連結效期
+
+	"github.com/hibiken/asynq"            // 匯入 asynq，檢查 email:send 任務
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/db"    // 匯入 db，建立帶 email 的使用者
+	"sessionservice/internal/infra" // 匯入 infra，取得任務型別
+)
+
+// sentMagicLinkTokens 取出目前排入的 email:send 任務中的 magic link token 並清空佇列。
+func sentMagicLinkTokens(t *testing.T, inspector *asynq.Inspector) map[string]string {
+	t.Helper() // 標記為測試輔助函式
+
+	tokens := map[string]string{}
+	tasks, err := inspector.ListPendingTasks("default") // 列出待處理的任務
+	if errors.Is(err, asynq.ErrQueueNotFound) {
+		return tokens // 尚未排入任何任務
+	}
+	require.NoError(t, err) // 查詢應成功
+	for _, task := range tasks {
+		if task.Type != infra.TaskTypeEmailSend {
+			continue // 只看 email:send
+		}
+		var p infra.EmailSendPayload
+		require.NoError(t, json.Unmarshal(task.Payload, &p)) // payload 應為合法 JSON
+		start := strings.Index(p.Body, "http")               // 找出信件內的連結
+		require.GreaterOrEqual(t, start, 0)                  // 應帶有連結
+		link, err := url.Parse(strings.Fields(p.Body[start:])[0])
+		require.NoError(t, err)                  // 連結應可解析
+		tokens[p.To] = link.Query().Get("token") // 記下寄給該 email 的 token
+	}
+	_, err = inspector.DeleteAllPendingTasks("default") // 清空佇列
+	require.NoError(t, err)                             // 應成功
+	return tokens
+}
+
+// TestMagicLinkConsumeOnce 測試 magic link 只寄給已註冊的 email，token 可建立 session 且只能使用一次。
+func TestMagicLinkConsumeOnce(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	opt := asynq.RedisClientOpt{Addr: env.mr.Addr()}                      // asynq 與 session 共用 miniredis
+	client := asynq.NewClient(opt)                                        // 建立 asynq client
+	defer client.Close()                                                  // 測試結束時關閉
+	inspector := asynq.NewInspector(opt)                                  // 建立 inspector 以檢查排入的任務
+	defer inspector.Close()                                               // 測試結束時關閉
+	env.cfg.MagicLinkTTL = 15 * time.Minute                               // 連結 15 分鐘內有效
+	env.cfg.MagicLinkURL = "https://example.com/auth/magic-login"         // 信件內的連結位址
+	env.sessSvc = NewSessionService(env.q, env.rdb, env.cfg, client, nil) // 改用會排任務的 SessionService
+
+	user, err := env.q.CreateUser(env.ctx, db.CreateUserParams{ // 建立帶 email 的使用者
+		Username:     "alice",
+		PasswordHash: "x",
+		Email:        sql.NullString{String: "alice@example.com", Valid: true},
+	})
+	require.NoError(t, err) // 應建立成功
+
+	require.NoError(t, env.sessSvc.RequestMagicLink(env.ctx, "nobody@example.com")) // 未註冊的 email 不回傳錯誤
+	require.Empty(t, sentMagicLinkTokens(t, inspector))                             // 也不寄信
+
+	require.NoError(t, env.sessSvc.RequestMagicLink(env.ctx, " Alice@Example.com ")) // 大小寫與空白不影響查詢
+	tokens := sentMagicLinkTokens(t, inspector)                                      // 取出寄出的 token
+	require.Len(t, tokens, 1)                                                        // 只寄出一封
+	tok := tokens["alice@example.com"]                                               // 寄給正規化後的 email
+	require.NotEmpty(t, tok)                                                         // 應帶有 token
+
+	got, sid, exp, err := env.sessSvc.ConsumeMagicLink(env.ctx, tok, LoginMeta{IP: "203.0.113.1"}) // 以連結登入
+	require.NoError(t, err)                                                                        // 應登入成功
+	require.Equal(t, user.ID, got.ID)                                                              // 登入的是 alice
+	require.True(t, exp.After(time.Now()))                                                         // 回傳 session 到期時間
+	ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid)                                   // 檢查建立的 session
+	require.NoError(t, err)                                                                        // 不應失敗
+	require.True(t, ok)                                                                            // session 應有效
+
+	_, _, _, err = env.sessSvc.ConsumeMagicLink(env.ctx, tok, LoginMeta{}) // 再用同一個連結
+	require.ErrorIs(t, err, ErrMagicLinkInvalid)                           // 已使用過應被拒絕
+
+	_, _, _, err = env.sessSvc.ConsumeMagicLink(env.ctx, "not-a-token", LoginMeta{}) // 不存在的 token
+	require.ErrorIs(t, err, ErrMagicLinkInvalid)                                     // 應被拒絕
+}
+
+// TestMagicLinkExpires 測試超過 MagicLinkTTL 後連結失效。
+func TestMagicLinkExpires(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	opt := asynq.RedisClientOpt{Addr: env.mr.Addr()}                      // asynq 與 session 共用 miniredis
+	client := asynq.NewClient(opt)                                        // 建立 asynq client
+	defer client.Close()                                                  // 測試結束時關閉
+	inspector := asynq.NewInspector(opt)                                  // 建立 inspector 以檢查排入的任務
+	defer inspector.Close()                                               // 測試結束時關閉
+	env.cfg.MagicLinkTTL = time.Minute                                    // 連結 1 分鐘內有效
+	env.cfg.MagicLinkURL = "https://example.com/auth/magic-login"         // 信件內的連結位址
+	env.sessSvc = NewSessionService(env.q, env.rdb, env.cfg, client, nil) // 改用會排任務的 SessionService
+
+	_, err := env.q.CreateUser(env.ctx, db.CreateUserParams{ // 建立帶 email 的使用者
+		Username:     "alice",
+		PasswordHash: "x",
+		Email:        sql.NullString{String: "alice@example.com", Valid: true},
+	})
+	require.NoError(t, err) // 應建立成功
+
+	require.NoError(t, env.sessSvc.RequestMagicLink(env.ctx, "alice@example.com")) // 要求寄送連結
+	tok := sentMagicLinkTokens(t, inspector)["alice@example.com"]                  // 取出 token
+	require.NotEmpty(t, tok)                                                       // 應帶有 token
+
+	env.mr.FastForward(2 * time.Minute)                                    // 超過連結效期
+	_, _, _, err = env.sessSvc.ConsumeMagicLink(env.ctx, tok, LoginMeta{}) // 以過期的連結登入
+	require.ErrorIs(t, err, ErrMagicLinkInvalid)                           // 應被拒絕
+}
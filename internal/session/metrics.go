@@ -0,0 +1,72 @@
+package session
+
+import (
+	"errors"
+	"time"
+)
+
+// 登入結果，作為 Metrics.IncrLogin 的 outcome。
+const (
+	LoginOutcomeSuccess         = "success"
+	LoginOutcomeInvalid         = "invalid_credentials"
+	LoginOutcomeBanned          = "banned"
+	LoginOutcomeResetRequired   = "reset_required"
+	LoginOutcomePasswordExpired = "password_expired"
+	LoginOutcomeSessionLimit    = "session_limit"
+	LoginOutcomeRateLimited     = "rate_limited"
+	LoginOutcomeBusy            = "busy"
+	LoginOutcomeCountryBlocked  = "country_blocked"
+	LoginOutcomeMultiCountry    = "multi_country_session"
+	LoginOutcomeError           = "error"
+)
+
+// Metrics 是 SessionService 回報業務指標的介面，讓部署環境自行接上 Prometheus、StatsD 或 Datadog。
+// 實作必須可被多個 goroutine 同時呼叫，且不應阻塞請求。
+type Metrics interface {
+	IncrLogin(outcome string)
+	IncrLogout()
+	IncrSessionCreated()
+	IncrSessionRevoked(reason string)
+	ObserveLoginLatency(d time.Duration)
+	IncrMalformedSession(field string) // session hash 缺少或無法解析的欄位（例如 expires_at）
+}
+
+// NopMetrics 不做任何事，是未指定 Metrics 時的預設值。
+type NopMetrics struct{}
+
+func (NopMetrics) IncrLogin(string)                  {}
+func (NopMetrics) IncrLogout()                       {}
+func (NopMetrics) IncrSessionCreated()               {}
+func (NopMetrics) IncrSessionRevoked(string)         {}
+func (NopMetrics) ObserveLoginLatency(time.Duration) {}
+func (NopMetrics) IncrMalformedSession(string)       {}
+
+// loginOutcome 將 Login 回傳的錯誤對應到 outcome。
+func loginOutcome(err error) string {
+	if errors.Is(err, ErrLoginRateLimited) {
+		return LoginOutcomeRateLimited
+	}
+	if errors.Is(err, ErrPasswordExpired) {
+		return LoginOutcomePasswordExpired
+	}
+	switch err {
+	case nil:
+		return LoginOutcomeSuccess
+	case ErrInvalidCredentials, ErrMagicLinkInvalid:
+		return LoginOutcomeInvalid
+	case ErrUserBanned:
+		return LoginOutcomeBanned
+	case ErrPasswordResetRequired:
+		return LoginOutcomeResetRequired
+	case ErrSessionLimitReached:
+		return LoginOutcomeSessionLimit
+	case ErrPasswordCheckBusy:
+		return LoginOutcomeBusy
+	case ErrCountryBlocked:
+		return LoginOutcomeCountryBlocked
+	case ErrMultiCountrySession:
+		return LoginOutcomeMultiCountry
+	default:
+		return LoginOutcomeError
+	}
+}
@@ -0,0 +1,74 @@
+package session
+
+import (
+	"sync"    // 匯入 sync，保護 recordingMetrics 的紀錄
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，接收登入耗時
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// recordingMetrics 記錄每一次呼叫，用來驗證 SessionService 回報了哪些指標。
+type recordingMetrics struct {
+	mu        sync.Mutex      // 保護 calls 與 latencies
+	calls     []string        // 依序記錄的呼叫
+	latencies []time.Duration // 每次 ObserveLoginLatency 收到的耗時
+}
+
+func (m *recordingMetrics) record(call string) {
+	m.mu.Lock()         // 加鎖
+	defer m.mu.Unlock() // 結束時解鎖
+	m.calls = append(m.calls, call)
+}
+
+func (m *recordingMetrics) IncrLogin(outcome string)      { m.record("login:" + outcome) }
+func (m *recordingMetrics) IncrLogout()                   { m.record("logout") }
+func (m *recordingMetrics) IncrSessionCreated()           { m.record("session_created") }
+func (m *recordingMetrics) IncrSessionRevoked(r string)   { m.record("session_revoked:" + r) }
+func (m *recordingMetrics) IncrMalformedSession(f string) { m.record("malformed:" + f) }
+func (m *recordingMetrics) ObserveLoginLatency(d time.Duration) {
+	m.mu.Lock()         // 加鎖
+	defer m.mu.Unlock() // 結束時解鎖
+	m.latencies = append(m.latencies, d)
+}
+
+// newMetricsTestEnv 建立接上 recordingMetrics 的測試環境。
+func newMetricsTestEnv(t *testing.T) (*testEnv, *recordingMetrics) {
+	t.Helper()                                                         // 標記為測試輔助函式
+	env := newTestEnv(t)                                               // 沿用共用測試環境
+	rec := &recordingMetrics{}                                         // 建立紀錄用 sink
+	env.sessSvc = NewSessionService(env.q, env.rdb, env.cfg, nil, rec) // 以 recordingMetrics 重建 SessionService
+	return env, rec
+}
+
+// TestLoginReportsMetrics 測試登入成功與失敗都會回報對應的 outcome、session 建立與耗時。
+func TestLoginReportsMetrics(t *testing.T) {
+	env, rec := newMetricsTestEnv(t)                // 建立測試環境
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 成功登入
+	require.NoError(t, err)                                                           // 應登入成功
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "wrong", LoginMeta{})          // 密碼錯誤
+	require.ErrorIs(t, err, ErrInvalidCredentials)                                    // 應回傳帳密錯誤
+	require.NoError(t, env.sessSvc.Logout(env.ctx, user.ID, sid))                     // 登出
+
+	require.Equal(t, []string{ // 呼叫順序與內容應符合預期
+		"session_created",
+		"login:" + LoginOutcomeSuccess,
+		"login:" + LoginOutcomeInvalid,
+		"logout",
+		"session_revoked:user",
+	}, rec.calls)
+	require.Len(t, rec.latencies, 2) // 兩次登入各回報一次耗時
+	for _, d := range rec.latencies {
+		require.Greater(t, d, time.Duration(0)) // 耗時應為正值
+	}
+}
+
+// TestNilMetricsDefaultsToNop 測試未指定 Metrics 時使用 NopMetrics，登入流程不受影響。
+func TestNilMetricsDefaultsToNop(t *testing.T) {
+	env := newTestEnv(t)                                // 共用測試環境傳入 nil metrics
+	require.Equal(t, NopMetrics{}, env.sessSvc.metrics) // 應為 NopMetrics
+}
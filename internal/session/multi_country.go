@@ -0,0 +1,47 @@
+package session
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/db"
+	"sessionservice/internal/infra"
+)
+
+// ErrMultiCountrySession 表示 BLOCK_MULTI_COUNTRY_SESSIONS 開啟時，使用者仍有來自其他國家的有效 session，拒絕這次登入。
+var ErrMultiCountrySession = errors.New("user has an active session from another country")
+
+// checkMultiCountry 在 BLOCK_MULTI_COUNTRY_SESSIONS 開啟時檢查使用者既有 session 的國家（session hash 的 country 欄位），
+// 有任一與這次登入不同時記一筆 login:audit 並回傳 ErrMultiCountrySession。這次登入或既有 session 查不出國家時不視為衝突。
+func (s *SessionService) checkMultiCountry(ctx context.Context, u db.User, meta LoginMeta) error {
+	if !s.cfg.BlockMultiCountrySessions || meta.Country == "" {
+		return nil
+	}
+
+	sids, err := s.rdb.ZRange(ctx, infra.UserSessKey(u.ID), 0, -1).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if len(sids) == 0 {
+		return nil
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.StringCmd, len(sids))
+	for i, sid := range sids {
+		cmds[i] = pipe.HGet(ctx, infra.SessKey(sid), "country")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return err
+	}
+
+	for _, cmd := range cmds {
+		if country := cmd.Val(); country != "" && country != meta.Country {
+			s.auditLoginFailure(ctx, &u.ID, u.Username, LoginReasonMultiCountry, meta)
+			return ErrMultiCountrySession
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package session
+
+import (
+	"testing" // 匯入 testing，提供單元測試框架
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra，取得 session key
+)
+
+// TestBlockMultiCountrySessions 測試開啟 BlockMultiCountrySessions 時，同國家可再登入、不同國家被拒絕，
+// 國家未知時不視為衝突；關閉時照常登入。
+func TestBlockMultiCountrySessions(t *testing.T) {
+	env := newTestEnv(t)                         // 建立測試環境
+	env.cfg.MaxSessionsPerUser = 5               // 避免踢除影響結果
+	env.cfg.BlockMultiCountrySessions = true     // 開啟跨國同時登入檢查
+	hashed, err := bcryptGenerate("password123") // 產生密碼雜湊
+	require.NoError(t, err)                      // 應產生成功
+	createTestUser(t, env, "alice", hashed)      // 建立使用者
+
+	login := func(country string) (string, error) {
+		_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{IP: "10.0.0.1", Country: country}) // 從指定國家登入
+		return sid, err
+	}
+
+	twSID, err := login("TW") // 第一個 session 來自 TW
+	require.NoError(t, err)   // 應登入成功
+	country, err := env.rdb.HGet(env.ctx, infra.SessKey(twSID), "country").Result()
+	require.NoError(t, err)         // session hash 有 country 欄位
+	require.Equal(t, "TW", country) // 記錄登入國家
+
+	_, err = login("TW")    // 同國家再登入
+	require.NoError(t, err) // 應登入成功
+	_, err = login("")      // 國家未知
+	require.NoError(t, err) // 不視為衝突
+
+	_, err = login("JP")                            // 不同國家
+	require.ErrorIs(t, err, ErrMultiCountrySession) // 應被拒絕
+
+	env.cfg.BlockMultiCountrySessions = false // 關閉檢查
+	_, err = login("JP")                      // 不同國家
+	require.NoError(t, err)                   // 關閉時照常登入
+}
@@ -0,0 +1,118 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+// TokenMode 的值：access token 的格式。
+const (
+	TokenModeJWT    = "jwt"
+	TokenModeOpaque = "opaque"
+)
+
+// ErrOpaqueTokenInvalid 表示 reference token 不存在（從未簽發、已登出或已過期）。
+var ErrOpaqueTokenInvalid = errors.New("opaque token is invalid or expired")
+
+// OpaqueToken 是 reference token 在 Redis 中對應的內容。
+type OpaqueToken struct {
+	UserID    int64
+	SessionID string
+	ExpiresAt time.Time
+	AMR       []string
+}
+
+// OpaqueTokensEnabled 回傳是否以 reference token 取代 JWT（TOKEN_MODE=opaque）。
+func (s *SessionService) OpaqueTokensEnabled() bool {
+	return s.cfg.TokenMode == TokenModeOpaque
+}
+
+// IssueOpaqueToken 為 session 簽發隨機 reference token，Redis 只保存其 SHA-256 對應的 user / session / exp / amr，
+// key 的 TTL 即 token 效期。token 同時登記在 sess_opaque:{sid}，Logout 時一併刪除而立即失效。
+func (s *SessionService) IssueOpaqueToken(ctx context.Context, userID int64, sessionID string, expiresAt time.Time, amr ...string) (string, error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return "", ErrOpaqueTokenInvalid
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	hash := opaqueTokenHash(token)
+
+	key := infra.OpaqueTokenKey(hash)
+	indexKey := infra.SessOpaqueTokensKey(sessionID)
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, key, map[string]any{
+		"user_id":    userID,
+		"session_id": sessionID,
+		"exp":        expiresAt.Unix(),
+		"amr":        strings.Join(amr, ","),
+	})
+	pipe.Expire(ctx, key, ttl)
+	pipe.SAdd(ctx, indexKey, hash)
+	pipe.Expire(ctx, indexKey, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ResolveOpaqueToken 查出 reference token 對應的 session；不存在或 exp 已過（放寬 JWTLeeway）時回傳 ErrOpaqueTokenInvalid。
+// 只確認 token 本身，session 是否仍有效由呼叫端另外以 IsSessionValid 檢查。
+func (s *SessionService) ResolveOpaqueToken(ctx context.Context, token string) (OpaqueToken, error) {
+	data, err := s.rdb.HGetAll(ctx, infra.OpaqueTokenKey(opaqueTokenHash(token))).Result()
+	if err != nil && err != redis.Nil {
+		return OpaqueToken{}, err
+	}
+	if len(data) == 0 {
+		return OpaqueToken{}, ErrOpaqueTokenInvalid
+	}
+
+	userID, err := strconv.ParseInt(data["user_id"], 10, 64)
+	if err != nil {
+		return OpaqueToken{}, ErrOpaqueTokenInvalid
+	}
+	exp, err := strconv.ParseInt(data["exp"], 10, 64)
+	if err != nil || !time.Now().Before(time.Unix(exp, 0).Add(s.cfg.JWTLeeway)) {
+		return OpaqueToken{}, ErrOpaqueTokenInvalid
+	}
+	tok := OpaqueToken{UserID: userID, SessionID: data["session_id"], ExpiresAt: time.Unix(exp, 0)}
+	if data["amr"] != "" {
+		tok.AMR = strings.Split(data["amr"], ",")
+	}
+	return tok, nil
+}
+
+// revokeOpaqueTokens 刪除 session 簽發過的所有 reference token。
+func (s *SessionService) revokeOpaqueTokens(ctx context.Context, sessionID string) error {
+	indexKey := infra.SessOpaqueTokensKey(sessionID)
+	hashes, err := s.rdb.SMembers(ctx, indexKey).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	keys := []string{indexKey}
+	for _, h := range hashes {
+		keys = append(keys, infra.OpaqueTokenKey(h))
+	}
+	return s.rdb.Del(ctx, keys...).Err()
+}
+
+// opaqueTokenHash 回傳 reference token 的 SHA-256（hex），Redis 不保存 token 原文。
+func opaqueTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
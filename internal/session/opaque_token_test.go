@@ -0,0 +1,72 @@
+package session
+
+import (
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，設定 token 效期
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra，檢查 Redis key
+)
+
+// TestOpaqueTokenIssueAndResolve 測試 reference token 可查回 user / session / amr，Redis 不保存 token 原文，效期到了即失效。
+func TestOpaqueTokenIssueAndResolve(t *testing.T) {
+	env := newTestEnv(t)                               // 建立測試環境
+	env.cfg.TokenMode = TokenModeOpaque                // 使用 reference token
+	require.True(t, env.sessSvc.OpaqueTokensEnabled()) // 應已啟用
+
+	exp := time.Now().Add(time.Hour)                                                       // 一小時後到期
+	tok, err := env.sessSvc.IssueOpaqueToken(env.ctx, 7, "sess-opaque", exp, "pwd", "otp") // 簽發
+	require.NoError(t, err)                                                                // 應簽發成功
+	require.NotContains(t, tok, ".")                                                       // 不是 JWT
+	require.False(t, env.mr.Exists(infra.OpaqueTokenKey(tok)))                             // 不以原文當 key
+
+	got, err := env.sessSvc.ResolveOpaqueToken(env.ctx, tok) // 查詢
+	require.NoError(t, err)                                  // 應查得到
+	require.EqualValues(t, 7, got.UserID)                    // user 正確
+	require.Equal(t, "sess-opaque", got.SessionID)           // session 正確
+	require.Equal(t, exp.Unix(), got.ExpiresAt.Unix())       // exp 正確
+	require.Equal(t, []string{"pwd", "otp"}, got.AMR)        // amr 正確
+
+	_, err = env.sessSvc.ResolveOpaqueToken(env.ctx, tok+"x") // 不存在的 token
+	require.ErrorIs(t, err, ErrOpaqueTokenInvalid)            // 應無效
+
+	env.mr.FastForward(time.Hour + time.Second)           // 超過效期
+	_, err = env.sessSvc.ResolveOpaqueToken(env.ctx, tok) // 再查一次
+	require.ErrorIs(t, err, ErrOpaqueTokenInvalid)        // 應已失效
+
+	_, err = env.sessSvc.IssueOpaqueToken(env.ctx, 7, "sess-opaque", time.Now().Add(-time.Second)) // 已過期的 exp
+	require.ErrorIs(t, err, ErrOpaqueTokenInvalid)                                                 // 不應簽發
+}
+
+// TestOpaqueTokenRevokedOnLogout 測試登出立即刪除該 session 簽發過的所有 reference token，其他 session 的不受影響。
+func TestOpaqueTokenRevokedOnLogout(t *testing.T) {
+	env := newTestEnv(t)                            // 建立測試環境
+	env.cfg.TokenMode = TokenModeOpaque             // 使用 reference token
+	hashed, err := bcryptGenerate("password123")    // 產生密碼雜湊
+	require.NoError(t, err)                         // 應產生成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, sid, exp, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{})        // 第一個 session
+	require.NoError(t, err)                                                                    // 應登入成功
+	_, other, otherExp, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 第二個 session
+	require.NoError(t, err)                                                                    // 應登入成功
+
+	first, err := env.sessSvc.IssueOpaqueToken(env.ctx, user.ID, sid, exp)       // 第一個 session 的 token
+	require.NoError(t, err)                                                      // 應簽發成功
+	refreshed, err := env.sessSvc.IssueOpaqueToken(env.ctx, user.ID, sid, exp)   // 同一 session refresh 後的 token
+	require.NoError(t, err)                                                      // 應簽發成功
+	kept, err := env.sessSvc.IssueOpaqueToken(env.ctx, user.ID, other, otherExp) // 第二個 session 的 token
+	require.NoError(t, err)                                                      // 應簽發成功
+
+	require.NoError(t, env.sessSvc.Logout(env.ctx, user.ID, sid)) // 登出第一個 session
+	for _, tok := range []string{first, refreshed} {
+		_, err = env.sessSvc.ResolveOpaqueToken(env.ctx, tok) // 查詢已登出 session 的 token
+		require.ErrorIs(t, err, ErrOpaqueTokenInvalid)        // 應立即失效
+	}
+	require.False(t, env.mr.Exists(infra.SessOpaqueTokensKey(sid))) // 索引一併刪除
+
+	got, err := env.sessSvc.ResolveOpaqueToken(env.ctx, kept) // 第二個 session 的 token
+	require.NoError(t, err)                                   // 仍有效
+	require.Equal(t, other, got.SessionID)                    // 對應第二個 session
+}
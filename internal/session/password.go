@@ -0,0 +1,254 @@
+package session
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+
+	"sessionservice/internal/db"
+	"sessionservice/internal/infra"
+)
+
+// bcryptCost 回傳目前設定的目標 cost；未設定或不合法時使用 bcrypt.DefaultCost。
+func (s *SessionService) bcryptCost() int {
+	cost := s.cfg.BcryptCost
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+// pepper 回傳 base64(HMAC-SHA256(PasswordPepper, password))。
+// 結果固定 44 bytes，也順便避開 bcrypt 只取前 72 bytes 的限制。
+func (s *SessionService) pepper(password string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.PasswordPepper))
+	mac.Write([]byte(password))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// HashPassword 以設定的 bcrypt cost 產生密碼雜湊，signup 與 rehash 共用。
+// 有設定 PasswordPepper 時先做 HMAC 再交給 bcrypt，peppered 回傳 true，呼叫端需一併寫入 password_peppered。
+func (s *SessionService) HashPassword(password string) (hash string, peppered bool, err error) {
+	input := password
+	if s.cfg.PasswordPepper != "" {
+		input = s.pepper(password)
+		peppered = true
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(input), s.bcryptCost())
+	if err != nil {
+		return "", false, err
+	}
+	return string(hashed), peppered, nil
+}
+
+// comparePassword 依 password_peppered 決定比對方式，讓未 pepper 的舊雜湊在啟用 pepper 後仍可登入。
+// peppered 雜湊在 PasswordPepper 未設定時一律比對失敗。
+// 比對前先取得 BcryptMaxConcurrency 的名額，取不到時回傳 ErrPasswordCheckBusy（或 ctx 的錯誤），呼叫端不應視為密碼錯誤。
+func (s *SessionService) comparePassword(ctx context.Context, u db.User, password string) error {
+	input := password
+	if u.PasswordPeppered {
+		if s.cfg.PasswordPepper == "" {
+			return bcrypt.ErrMismatchedHashAndPassword
+		}
+		input = s.pepper(password)
+	}
+	release, err := s.bcrypt.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(input))
+}
+
+// maybeRehash 在密碼驗證成功後，檢查雜湊 cost 是否低於目標、或尚未套用 pepper，並決定如何升級。
+//
+// 明文密碼只存在於這次請求的記憶體中，不會寫進 DB、Redis 或 Asynq 任務，
+// 因此不能把 rehash 丟給 worker。流程如下：
+//   - 依這次 compare 的耗時推估新 cost 的雜湊時間（cost 每加 1，耗時約加倍）；
+//   - 推估值在 RehashSyncBudget 內，直接同步重新雜湊並寫回；
+//   - 否則只標記 needs_rehash，等使用者下次輸入密碼時一定同步升級。
+func (s *SessionService) maybeRehash(ctx context.Context, u db.User, password string, compareTook time.Duration) {
+	cost, err := bcrypt.Cost([]byte(u.PasswordHash))
+	if err != nil {
+		return
+	}
+	target := s.bcryptCost()
+	needsPepper := s.cfg.PasswordPepper != "" && !u.PasswordPeppered
+	if cost >= target && !needsPepper {
+		return
+	}
+
+	estimate := compareTook
+	if target > cost {
+		estimate <<= uint(target - cost)
+	}
+	if !u.NeedsRehash && estimate > s.cfg.RehashSyncBudget {
+		_ = s.q.MarkNeedsRehash(ctx, u.ID)
+		return
+	}
+
+	hashed, peppered, err := s.HashPassword(password)
+	if err != nil {
+		return
+	}
+	_ = s.q.UpdatePasswordHash(ctx, db.UpdatePasswordHashParams{
+		ID:               u.ID,
+		PasswordHash:     hashed,
+		PasswordPeppered: peppered,
+	})
+}
+
+// ForceResetPassword 標記使用者必須重設密碼並踢掉所有 session 與記住的裝置，
+// 用於密碼出現在外洩清單時；之後 Login 會回傳 ErrPasswordResetRequired 直到重設完成。
+// 有設定 PasswordResetURL 且使用者有 email 時，一併寄出帶有重設 token 的連結；否則由 admin 另行發出 token。
+func (s *SessionService) ForceResetPassword(ctx context.Context, userID int64) error {
+	if err := s.q.SetMustResetPassword(ctx, userID); err != nil {
+		return err
+	}
+	if _, err := s.RevokeTrustedDevices(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.revokeAllSessions(ctx, userID, infra.RevokedByAdminForceReset); err != nil {
+		return err
+	}
+	return s.sendPasswordResetEmail(ctx, userID)
+}
+
+// sendPasswordResetEmail 發出重設 token 並排入 email:send；未設定 PasswordResetURL 或使用者沒有 email 時不做事。
+func (s *SessionService) sendPasswordResetEmail(ctx context.Context, userID int64) error {
+	if s.cfg.PasswordResetURL == "" {
+		return nil
+	}
+	u, err := s.q.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	if !u.Email.Valid {
+		return nil
+	}
+
+	token, err := s.IssuePasswordResetToken(ctx, userID)
+	if err != nil {
+		return err
+	}
+	link := s.cfg.PasswordResetURL + "?token=" + url.QueryEscape(token)
+	return infra.EnqueueEmailSend(ctx, s.asynqClient, infra.EmailSendPayload{
+		To:      u.Email.String,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("The password for %s must be changed before you can sign in again. "+
+			"Use the link below within %s; it can only be used once.\n\n%s\n",
+			u.Username, s.cfg.PasswordResetTokenTTL, link),
+	})
+}
+
+// passwordExpired 回傳使用者的密碼是否已超過 PasswordMaxAge；沒有 password_changed_at 時以帳號建立時間計算。
+func (s *SessionService) passwordExpired(u db.User) bool {
+	if s.cfg.PasswordMaxAge <= 0 {
+		return false
+	}
+	changedAt := u.CreatedAt
+	if u.PasswordChangedAt.Valid {
+		changedAt = u.PasswordChangedAt.Time
+	}
+	return time.Since(changedAt) > s.cfg.PasswordMaxAge
+}
+
+// IssuePasswordResetToken 為使用者產生一次性的密碼重設 token，Redis 只保存 token 的 SHA-256，效期為 PasswordResetTokenTTL。
+// 由 admin、force-reset 的通知信或密碼過期的登入發出；重設只接受這個 token，不接受目前的密碼。
+func (s *SessionService) IssuePasswordResetToken(ctx context.Context, userID int64) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	if err := s.rdb.Set(ctx, infra.PasswordResetKey(resetTokenHash(token)), userID, s.cfg.PasswordResetTokenTTL).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ResetPassword 以密碼重設 token 設定新密碼，並清除 must_reset_password 與 needs_rehash、更新 password_changed_at。
+// token 不存在、已使用或已過期時回傳 ErrResetTokenInvalid，並與登入失敗相同地寫入 login audit。
+// 新密碼不符合規則時 token 仍保留，使用者可在效期內重試；成功時才以 GETDEL 消耗 token，同一個 token 只能重設一次。
+func (s *SessionService) ResetPassword(ctx context.Context, token, newPassword string, meta LoginMeta) error {
+	key := infra.PasswordResetKey(resetTokenHash(token))
+	val, err := s.rdb.Get(ctx, key).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	userID, parseErr := strconv.ParseInt(val, 10, 64)
+	if token == "" || err == redis.Nil || parseErr != nil {
+		s.auditLoginFailure(ctx, nil, "", LoginReasonInvalidResetToken, meta)
+		return ErrResetTokenInvalid
+	}
+
+	u, err := s.q.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// 發出 token 後使用者已被刪除
+			s.auditLoginFailure(ctx, nil, "", LoginReasonInvalidResetToken, meta)
+			return ErrResetTokenInvalid
+		}
+		return err
+	}
+	if u.IsBanned {
+		s.auditLoginFailure(ctx, &u.ID, u.Username, LoginReasonBannedDB, meta)
+		return ErrUserBanned
+	}
+	if err := s.CheckPasswordStrength(newPassword, u.Username); err != nil {
+		return err
+	}
+	if err := s.comparePassword(ctx, u, newPassword); err == nil {
+		return ErrPasswordReused
+	} else if errors.Is(err, ErrPasswordCheckBusy) || ctx.Err() != nil {
+		return err
+	}
+
+	// 同一個 token 同時被兩個請求使用時，只有先 GETDEL 的一方會成功
+	if got, err := s.rdb.GetDel(ctx, key).Result(); err != nil || got != val {
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		s.auditLoginFailure(ctx, &u.ID, u.Username, LoginReasonInvalidResetToken, meta)
+		return ErrResetTokenInvalid
+	}
+
+	hashed, peppered, err := s.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.q.ResetPassword(ctx, db.ResetPasswordParams{
+		ID:               u.ID,
+		PasswordHash:     hashed,
+		PasswordPeppered: peppered,
+	}); err != nil {
+		return err
+	}
+
+	// 舊密碼簽出的 session 與記住的裝置一律失效
+	if _, err := s.RevokeTrustedDevices(ctx, u.ID); err != nil {
+		return err
+	}
+	return s.revokeAllSessions(ctx, u.ID, infra.RevokedByPasswordReset)
+}
+
+// resetTokenHash 回傳密碼重設 token 的 SHA-256（hex），Redis 不保存 token 原文。
+func resetTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,286 @@
+package session
+
+import (
+	"database/sql" // 匯入 database/sql，設定使用者 email
+	"strings"      // 匯入 strings，檢查 Redis 內容是否含有明文
+	"testing"      // 匯入 testing，提供單元測試框架
+	"time"         // 匯入 time，設定同步 rehash 的耗時上限
+
+	"github.com/hibiken/asynq"            // 匯入 asynq，建立真的 client 觀察實際排入的任務
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+	"golang.org/x/crypto/bcrypt"          // 匯入 bcrypt，產生低 cost 雜湊與檢查升級後的 cost
+
+	"sessionservice/internal/db"    // 匯入 db 套件，建立帶 peppered 標記的使用者
+	"sessionservice/internal/infra" // 匯入 infra，取得重設 token 的 Redis key
+)
+
+// newRehashTestEnv 建立 target cost 為 MinCost+1 的測試環境，並接上指向 miniredis 的 Asynq client。
+func newRehashTestEnv(t *testing.T, budget time.Duration) *testEnv {
+	t.Helper()                                                            // 標記為測試輔助函式
+	env := newTestEnv(t)                                                  // 沿用共用測試環境
+	env.cfg.BcryptCost = bcrypt.MinCost + 1                               // 目標 cost 比測試雜湊高一級，讓 rehash 很快
+	env.cfg.RehashSyncBudget = budget                                     // 設定同步 rehash 的耗時上限
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: env.mr.Addr()})  // 讓 login:audit 等任務真的寫進 miniredis
+	t.Cleanup(func() { _ = client.Close() })                              // 測試結束時關閉 client
+	env.sessSvc = NewSessionService(env.q, env.rdb, env.cfg, client, nil) // 以新的設定與 client 重建 SessionService
+	return env
+}
+
+// issueResetToken 為使用者發出密碼重設 token。
+func issueResetToken(t *testing.T, env *testEnv, userID int64) string {
+	t.Helper()                                                       // 標記為測試輔助函式
+	tok, err := env.sessSvc.IssuePasswordResetToken(env.ctx, userID) // 發出 token
+	require.NoError(t, err)                                          // 應成功
+	return tok
+}
+
+// minCostHash 以 bcrypt.MinCost 產生雜湊，模擬舊 cost 的密碼。
+func minCostHash(t *testing.T, password string) string {
+	t.Helper()                                                                   // 標記為測試輔助函式
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost) // 使用最低 cost
+	require.NoError(t, err)                                                      // 確保雜湊成功
+	return string(hashed)
+}
+
+// requireNoPlaintext 確認 Redis（包含 Asynq 任務 payload）與 users 表都沒有出現明文密碼。
+func requireNoPlaintext(t *testing.T, env *testEnv, password string) {
+	t.Helper()                                                          // 標記為測試輔助函式
+	require.NotContains(t, env.mr.Dump(), password)                     // Redis 所有 key 的內容都不應含有明文
+	rows, err := env.sqlDB.QueryContext(env.ctx, "SELECT * FROM users") // 讀出 users 表所有欄位
+	require.NoError(t, err)                                             // 查詢不應失敗
+	defer rows.Close()                                                  // 結束時關閉 rows
+	cols, err := rows.Columns()                                         // 取得欄位數量
+	require.NoError(t, err)                                             // 不應失敗
+	for rows.Next() {                                                   // 逐列檢查
+		vals := make([]any, len(cols)) // 每個欄位都以 any 接收
+		ptrs := make([]any, len(cols)) // Scan 需要指標
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		require.NoError(t, rows.Scan(ptrs...)) // 讀取整列
+		for _, v := range vals {
+			if s, ok := v.(string); ok {
+				require.False(t, strings.Contains(s, password)) // 任何字串欄位都不應含有明文
+			}
+		}
+	}
+}
+
+// TestLoginRehashesSynchronouslyWithinBudget 測試預估耗時在預算內時，登入會同步把雜湊升級到目標 cost。
+func TestLoginRehashesSynchronouslyWithinBudget(t *testing.T) {
+	env := newRehashTestEnv(t, time.Minute)                              // 預算足夠大，必定同步 rehash
+	user := createTestUser(t, env, "alice", minCostHash(t, "s3cret-pw")) // 建立舊 cost 的使用者
+
+	_, _, _, err := env.sessSvc.Login(env.ctx, "alice", "s3cret-pw", LoginMeta{}) // 登入
+	require.NoError(t, err)                                                       // 應登入成功
+
+	got, err := env.q.GetUserByID(env.ctx, user.ID)    // 重新讀取使用者
+	require.NoError(t, err)                            // 查詢不應失敗
+	cost, err := bcrypt.Cost([]byte(got.PasswordHash)) // 取出新雜湊的 cost
+	require.NoError(t, err)                            // 應為合法 bcrypt 雜湊
+	require.Equal(t, bcrypt.MinCost+1, cost)           // 應已升級到目標 cost
+	require.False(t, got.NeedsRehash)                  // 不需要再標記
+	requireNoPlaintext(t, env, "s3cret-pw")            // 明文不應出現在任何儲存處
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "s3cret-pw", LoginMeta{}) // 用新雜湊再次登入
+	require.NoError(t, err)                                                      // 仍應登入成功
+}
+
+// TestLoginMarksNeedsRehashOverBudget 測試超過預算時只標記 needs_rehash，下次登入再同步升級並清除標記。
+func TestLoginMarksNeedsRehashOverBudget(t *testing.T) {
+	env := newRehashTestEnv(t, 0)                    // 預算為 0，第一次登入不會同步 rehash
+	oldHash := minCostHash(t, "s3cret-pw")           // 舊 cost 雜湊
+	user := createTestUser(t, env, "alice", oldHash) // 建立使用者
+
+	_, _, _, err := env.sessSvc.Login(env.ctx, "alice", "s3cret-pw", LoginMeta{}) // 第一次登入
+	require.NoError(t, err)                                                       // 應登入成功
+
+	got, err := env.q.GetUserByID(env.ctx, user.ID) // 重新讀取使用者
+	require.NoError(t, err)                         // 查詢不應失敗
+	require.True(t, got.NeedsRehash)                // 應標記為需要 rehash
+	require.Equal(t, oldHash, got.PasswordHash)     // 雜湊本身尚未變動
+	requireNoPlaintext(t, env, "s3cret-pw")         // 沒有任何任務或欄位帶著明文
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "s3cret-pw", LoginMeta{}) // 使用者下次輸入密碼
+	require.NoError(t, err)                                                      // 應登入成功
+
+	got, err = env.q.GetUserByID(env.ctx, user.ID)     // 再次讀取
+	require.NoError(t, err)                            // 查詢不應失敗
+	cost, err := bcrypt.Cost([]byte(got.PasswordHash)) // 取出新雜湊的 cost
+	require.NoError(t, err)                            // 應為合法 bcrypt 雜湊
+	require.Equal(t, bcrypt.MinCost+1, cost)           // 有標記時無視預算，一定升級
+	require.False(t, got.NeedsRehash)                  // 標記應被清除
+	requireNoPlaintext(t, env, "s3cret-pw")            // 明文依舊不應外流
+}
+
+// TestForceResetPasswordGatesLoginUntilReset 測試 force-reset 後登入會被擋下，重設密碼後標記清除並可用新密碼登入。
+func TestForceResetPasswordGatesLoginUntilReset(t *testing.T) {
+	env := newTestEnv(t)                                                            // 建立測試環境
+	hashed, err := bcryptGenerate("leaked-pw")                                      // 產生雜湊
+	require.NoError(t, err)                                                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed)                                 // 建立使用者
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "leaked-pw", LoginMeta{}) // 先建立一個 session
+	require.NoError(t, err)                                                         // 應登入成功
+
+	require.NoError(t, env.sessSvc.ForceResetPassword(env.ctx, user.ID)) // 標記必須重設密碼
+
+	ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid) // 既有 session 應被踢掉
+	require.NoError(t, err)                                      // 檢查不應失敗
+	require.False(t, ok)                                         // session 已失效
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "leaked-pw", LoginMeta{}) // 密碼正確但需重設
+	require.ErrorIs(t, err, ErrPasswordResetRequired)                            // 應回傳需重設
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "wrong", LoginMeta{})     // 密碼錯誤
+	require.ErrorIs(t, err, ErrInvalidCredentials)                               // 仍回傳帳密錯誤，不洩漏標記狀態
+
+	err = env.sessSvc.ResetPassword(env.ctx, "leaked-pw", "fresh-pw", LoginMeta{}) // 以外洩的舊密碼代替 token
+	require.ErrorIs(t, err, ErrResetTokenInvalid)                                  // 目前的密碼不能用來重設
+
+	tok := issueResetToken(t, env, user.ID)                                              // admin 發出重設 token
+	err = env.sessSvc.ResetPassword(env.ctx, tok, "leaked-pw", LoginMeta{})              // 新舊密碼相同
+	require.ErrorIs(t, err, ErrPasswordReused)                                           // 應被拒絕
+	require.NoError(t, env.sessSvc.ResetPassword(env.ctx, tok, "fresh-pw", LoginMeta{})) // 重設為新密碼
+	err = env.sessSvc.ResetPassword(env.ctx, tok, "another-pw", LoginMeta{})             // 再用同一個 token
+	require.ErrorIs(t, err, ErrResetTokenInvalid)                                        // token 只能使用一次
+
+	got, err := env.q.GetUserByID(env.ctx, user.ID) // 重新讀取使用者
+	require.NoError(t, err)                         // 查詢不應失敗
+	require.False(t, got.MustResetPassword)         // 標記應已清除
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "fresh-pw", LoginMeta{})  // 用新密碼登入
+	require.NoError(t, err)                                                      // 應登入成功
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "leaked-pw", LoginMeta{}) // 舊密碼
+	require.ErrorIs(t, err, ErrInvalidCredentials)                               // 應失敗
+}
+
+// TestPepperedPasswordLogin 測試設定 pepper 後產生的雜湊會標記 peppered，且只能在相同 pepper 下驗證。
+func TestPepperedPasswordLogin(t *testing.T) {
+	env := newTestEnv(t)                     // 建立測試環境
+	env.cfg.PasswordPepper = "server-pepper" // 啟用 pepper
+
+	hashed, peppered, err := env.sessSvc.HashPassword("s3cret-pw")                       // 與 signup 相同的雜湊流程
+	require.NoError(t, err)                                                              // 雜湊不應失敗
+	require.True(t, peppered)                                                            // 應回報已 pepper
+	require.Error(t, bcrypt.CompareHashAndPassword([]byte(hashed), []byte("s3cret-pw"))) // 只拿到 DB 無法直接以明文驗證
+
+	user, err := env.q.CreateUser(env.ctx, db.CreateUserParams{Username: "alice", PasswordHash: hashed, PasswordPeppered: peppered}) // 建立使用者
+	require.NoError(t, err)                                                                                                          // 應建立成功
+	require.True(t, user.PasswordPeppered)                                                                                           // 標記已寫入
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "s3cret-pw", LoginMeta{}) // 正確密碼
+	require.NoError(t, err)                                                      // 應登入成功
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "wrong", LoginMeta{})     // 錯誤密碼
+	require.ErrorIs(t, err, ErrInvalidCredentials)                               // 應失敗
+
+	env.cfg.PasswordPepper = ""                                                  // pepper 遺失
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "s3cret-pw", LoginMeta{}) // 同樣的密碼
+	require.ErrorIs(t, err, ErrInvalidCredentials)                               // peppered 雜湊無法驗證
+}
+
+// TestUnpepperedPasswordStillLogsIn 測試啟用 pepper 前建立的雜湊仍可登入，並在登入時升級為 peppered 雜湊。
+func TestUnpepperedPasswordStillLogsIn(t *testing.T) {
+	env := newRehashTestEnv(t, time.Minute)         // 預算足夠大，登入時同步升級
+	env.cfg.BcryptCost = bcrypt.MinCost             // cost 不需升級，只測 pepper
+	hashed := minCostHash(t, "s3cret-pw")           // 未 pepper 的舊雜湊
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	env.cfg.PasswordPepper = "server-pepper" // 之後才啟用 pepper
+
+	_, _, _, err := env.sessSvc.Login(env.ctx, "alice", "s3cret-pw", LoginMeta{}) // 以舊雜湊登入
+	require.NoError(t, err)                                                       // 應登入成功
+
+	got, err := env.q.GetUserByID(env.ctx, user.ID) // 重新讀取使用者
+	require.NoError(t, err)                         // 查詢不應失敗
+	require.True(t, got.PasswordPeppered)           // 已升級為 peppered
+	require.NotEqual(t, hashed, got.PasswordHash)   // 雜湊已更換
+	requireNoPlaintext(t, env, "s3cret-pw")         // 明文不應外流
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "s3cret-pw", LoginMeta{}) // 以新雜湊登入
+	require.NoError(t, err)                                                      // 仍應登入成功
+}
+
+// TestLoginPasswordMaxAge 測試密碼超過 PasswordMaxAge 時登入回 ErrPasswordExpired，較新的密碼與重設後的密碼則可登入。
+func TestLoginPasswordMaxAge(t *testing.T) {
+	env := newTestEnv(t)                            // 建立測試環境
+	env.cfg.PasswordMaxAge = 90 * 24 * time.Hour    // 密碼最多使用 90 天
+	hashed, err := bcryptGenerate("old-pw")         // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者（password_changed_at 為現在）
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "old-pw", LoginMeta{}) // 剛設定的密碼
+	require.NoError(t, err)                                                   // 應登入成功
+
+	aged := time.Now().UTC().Add(-100 * 24 * time.Hour).Format(time.DateTime)                                       // 100 天前
+	_, err = env.sqlDB.ExecContext(env.ctx, "UPDATE users SET password_changed_at = ? WHERE id = ?", aged, user.ID) // 把密碼變更時間往前調
+	require.NoError(t, err)                                                                                         // 應更新成功
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "old-pw", LoginMeta{}) // 密碼正確但已過期
+	require.ErrorIs(t, err, ErrPasswordExpired)                               // 應要求更換密碼
+	var expired *PasswordExpiredError
+	require.ErrorAs(t, err, &expired)                                        // 帶有重設 token
+	require.NotEmpty(t, expired.ResetToken)                                  // 密碼已驗證通過，直接發出 token
+	require.Equal(t, LoginOutcomePasswordExpired, loginOutcome(err))         // metrics outcome 獨立計算
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "wrong", LoginMeta{}) // 密碼錯誤
+	require.ErrorIs(t, err, ErrInvalidCredentials)                           // 仍回傳帳密錯誤，不洩漏過期狀態
+
+	require.NoError(t, env.sessSvc.ResetPassword(env.ctx, expired.ResetToken, "fresh-pw", LoginMeta{})) // 以登入回應的 token 重設密碼
+	got, err := env.q.GetUserByID(env.ctx, user.ID)                                                     // 重新讀取使用者
+	require.NoError(t, err)                                                                             // 查詢不應失敗
+	require.WithinDuration(t, time.Now(), got.PasswordChangedAt.Time, time.Minute)                      // 變更時間應更新為現在
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "fresh-pw", LoginMeta{}) // 用新密碼登入
+	require.NoError(t, err)                                                     // 應登入成功
+}
+
+// TestResetPasswordInvalidTokenAudited 測試無效的重設 token 與登入失敗一樣寫入 login audit。
+func TestResetPasswordInvalidTokenAudited(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	opt := asynq.RedisClientOpt{Addr: env.mr.Addr()}                      // asynq 與 session 共用 miniredis
+	client := asynq.NewClient(opt)                                        // 建立 asynq client
+	defer client.Close()                                                  // 測試結束時關閉
+	inspector := asynq.NewInspector(opt)                                  // 建立 inspector 以檢查排入的任務
+	defer inspector.Close()                                               // 測試結束時關閉
+	env.sessSvc = NewSessionService(env.q, env.rdb, env.cfg, client, nil) // 改用會排任務的 SessionService
+
+	meta := LoginMeta{IP: "203.0.113.9", UserAgent: "curl/8.0"}            // 攻擊來源
+	err := env.sessSvc.ResetPassword(env.ctx, "guessed", "fresh-pw", meta) // 猜測的 token
+	require.ErrorIs(t, err, ErrResetTokenInvalid)                          // 應被拒絕
+
+	events := auditedLogins(t, inspector)                            // 取出排入的 audit
+	require.Len(t, events, 1)                                        // 一次失敗一筆
+	require.False(t, events[0].Success)                              // 標記為失敗
+	require.Equal(t, LoginReasonInvalidResetToken, events[0].Reason) // reason 為無效的重設 token
+	require.Equal(t, meta.IP, events[0].IP)                          // 帶著來源 IP
+}
+
+// TestForceResetPasswordEmailsResetLink 測試設定 PasswordResetURL 時，force-reset 寄出帶有重設 token 的連結，且該 token 可用於重設。
+func TestForceResetPasswordEmailsResetLink(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	opt := asynq.RedisClientOpt{Addr: env.mr.Addr()}                      // asynq 與 session 共用 miniredis
+	client := asynq.NewClient(opt)                                        // 建立 asynq client
+	defer client.Close()                                                  // 測試結束時關閉
+	inspector := asynq.NewInspector(opt)                                  // 建立 inspector 以檢查排入的任務
+	defer inspector.Close()                                               // 測試結束時關閉
+	env.cfg.PasswordResetTokenTTL = time.Hour                             // token 1 小時內有效
+	env.cfg.PasswordResetURL = "https://example.com/reset"                // 信件內的重設頁面
+	env.sessSvc = NewSessionService(env.q, env.rdb, env.cfg, client, nil) // 改用會排任務的 SessionService
+
+	user, err := env.q.CreateUser(env.ctx, db.CreateUserParams{ // 建立帶 email 的使用者
+		Username:     "alice",
+		PasswordHash: "x",
+		Email:        sql.NullString{String: "alice@example.com", Valid: true},
+	})
+	require.NoError(t, err) // 應建立成功
+
+	require.NoError(t, env.sessSvc.ForceResetPassword(env.ctx, user.ID))         // 強制重設密碼
+	tok := sentMagicLinkTokens(t, inspector)["alice@example.com"]                // 取出信件內連結的 token
+	require.NotEmpty(t, tok)                                                     // 應寄出重設連結
+	require.True(t, env.mr.TTL(infra.PasswordResetKey(resetTokenHash(tok))) > 0) // token 帶有效期
+
+	require.NoError(t, env.sessSvc.ResetPassword(env.ctx, tok, "fresh-password-456", LoginMeta{})) // 以信件內的 token 重設
+	got, err := env.q.GetUserByID(env.ctx, user.ID)                                                // 重新讀取使用者
+	require.NoError(t, err)                                                                        // 查詢不應失敗
+	require.False(t, got.MustResetPassword)                                                        // 標記應已清除
+}
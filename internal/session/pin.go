@@ -0,0 +1,76 @@
+package session
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+// PinnedLimitPolicy 的值：可踢除的 session 全部已 pin 時如何處理新的登入。
+const (
+	PinnedLimitEvictOldest = "evict_oldest"
+	PinnedLimitReject      = "reject"
+)
+
+// ErrSessionLimitReached 表示已達同時登入上限，且可踢除的 session 全部已 pin（PinnedLimitPolicy 為 reject）。
+var ErrSessionLimitReached = errors.New("session limit reached and all sessions are pinned")
+
+// PinSession 設定或取消 session 的 pin；pin 住的 session 在登入數超過上限時最後才會被踢掉。
+// session 不存在或不屬於 userID 時回傳 ErrSessionNotFound。
+func (s *SessionService) PinSession(ctx context.Context, userID int64, sessionID string, pinned bool) error {
+	sessKey := infra.SessKey(sessionID)
+	uid, err := s.rdb.HGet(ctx, sessKey, "user_id").Result()
+	if err != nil {
+		if err == redis.Nil {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	if uid != stringFromInt64(userID) {
+		return ErrSessionNotFound
+	}
+
+	if pinned {
+		return s.rdb.HSet(ctx, sessKey, "pinned", "1").Err()
+	}
+	return s.rdb.HDel(ctx, sessKey, "pinned").Err()
+}
+
+// pinnedFlags 以 pipeline 讀回各 session 是否已 pin；hash 已過期的 session 視為未 pin。
+func (s *SessionService) pinnedFlags(ctx context.Context, sids []string) ([]bool, error) {
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.StringCmd, len(sids))
+	for i, sid := range sids {
+		cmds[i] = pipe.HGet(ctx, infra.SessKey(sid), "pinned")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	pinned := make([]bool, len(sids))
+	for i := range sids {
+		pinned[i] = cmds[i].Val() == "1"
+	}
+	return pinned, nil
+}
+
+// evictionOrder 將由舊到新（或依 sortByActivity 排好）的 sids 重新排成踢除順序：未 pin 的在前、已 pin 的在後，各自維持原本的先後。
+// 需要踢掉 need 個 session 但未 pin 的不夠、且 PinnedLimitPolicy 為 reject 時回傳 ErrSessionLimitReached。
+func (s *SessionService) evictionOrder(sids []string, pinned []bool, need int) ([]string, error) {
+	ordered := make([]string, 0, len(sids))
+	var pinnedSIDs []string
+	for i, sid := range sids {
+		if pinned[i] {
+			pinnedSIDs = append(pinnedSIDs, sid)
+			continue
+		}
+		ordered = append(ordered, sid)
+	}
+	if need > len(ordered) && len(pinnedSIDs) > 0 && s.cfg.PinnedLimitPolicy == PinnedLimitReject {
+		return nil, ErrSessionLimitReached
+	}
+	return append(ordered, pinnedSIDs...), nil
+}
@@ -0,0 +1,82 @@
+package session
+
+import (
+	"testing" // 匯入 testing，提供單元測試框架
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// newPinTestEnv 建立上限為 2 的測試環境與使用者，回傳登入與檢查 session 的輔助函式。
+func newPinTestEnv(t *testing.T) (*testEnv, int64, func() string, func(string) bool) {
+	t.Helper()           // 標記為測試輔助函式
+	env := newTestEnv(t) // 建立測試環境（MaxSessionsPerUser = 2）
+
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	login := func() string {
+		_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 登入
+		require.NoError(t, err)                                                           // 應登入成功
+		return sid
+	}
+	valid := func(sid string) bool {
+		ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid) // 檢查 session
+		require.NoError(t, err)                                      // 檢查不應失敗
+		return ok
+	}
+	return env, user.ID, login, valid
+}
+
+// TestPinnedSessionSurvivesEviction 測試超過上限時跳過已 pin 的 session，改踢最舊的未 pin session。
+func TestPinnedSessionSurvivesEviction(t *testing.T) {
+	env, userID, login, valid := newPinTestEnv(t) // 建立測試環境
+
+	desktop, phone := login(), login()                                         // 兩個 session，已達上限
+	require.NoError(t, env.sessSvc.PinSession(env.ctx, userID, desktop, true)) // pin 住最舊的 desktop
+
+	throwaway := login()              // 第三次登入
+	require.True(t, valid(desktop))   // 已 pin 的最舊 session 保留
+	require.False(t, valid(phone))    // 改踢最舊的未 pin session
+	require.True(t, valid(throwaway)) // 新 session 有效
+
+	sessions, err := env.sessSvc.ListActiveSessions(env.ctx, userID, ListSessionsOptions{}) // 列出 session
+	require.NoError(t, err)                                                                 // 不應失敗
+	require.Len(t, sessions, 2)                                                             // 仍為兩個
+	require.True(t, sessions[0].Pinned)                                                     // desktop 標示為 pinned
+	require.False(t, sessions[1].Pinned)                                                    // 新 session 未 pin
+
+	require.NoError(t, env.sessSvc.PinSession(env.ctx, userID, desktop, false)) // 取消 pin
+	login()                                                                     // 再次登入
+	require.False(t, valid(desktop))                                            // 取消 pin 後照樣依序被踢
+}
+
+// TestAllPinnedFallsBackToPolicy 測試全部 session 都已 pin 時依 PinnedLimitPolicy 處理。
+func TestAllPinnedFallsBackToPolicy(t *testing.T) {
+	env, userID, login, valid := newPinTestEnv(t) // 建立測試環境
+
+	first, second := login(), login()                                         // 兩個 session，已達上限
+	require.NoError(t, env.sessSvc.PinSession(env.ctx, userID, first, true))  // 全部 pin 住
+	require.NoError(t, env.sessSvc.PinSession(env.ctx, userID, second, true)) // 全部 pin 住
+
+	env.cfg.PinnedLimitPolicy = PinnedLimitReject                                   // 拒絕登入
+	_, _, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 第三次登入
+	require.ErrorIs(t, err, ErrSessionLimitReached)                                 // 應被拒絕
+	require.True(t, valid(first))                                                   // 既有 session 不受影響
+	require.True(t, valid(second))
+
+	env.cfg.PinnedLimitPolicy = PinnedLimitEvictOldest // 照樣踢最舊的
+	third := login()                                   // 第三次登入成功
+	require.False(t, valid(first))                     // 踢掉最舊的 pinned session
+	require.True(t, valid(second))
+	require.True(t, valid(third))
+}
+
+// TestPinSessionRequiresOwner 測試不能 pin 別人的或不存在的 session。
+func TestPinSessionRequiresOwner(t *testing.T) {
+	env, userID, login, _ := newPinTestEnv(t) // 建立測試環境
+
+	sid := login()                                                                                   // 建立 session
+	require.ErrorIs(t, env.sessSvc.PinSession(env.ctx, userID+1, sid, true), ErrSessionNotFound)     // 其他使用者
+	require.ErrorIs(t, env.sessSvc.PinSession(env.ctx, userID, "missing", true), ErrSessionNotFound) // 不存在的 session
+}
@@ -0,0 +1,115 @@
+package session
+
+import (
+	"context"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+	"sessionservice/internal/lifecycle"
+)
+
+// purgeScanBatch 是清理時每次 SCAN 取回的 key 數量。
+const purgeScanBatch = 500
+
+// PurgeAllSessions 是緊急登出所有人的入口：前進 session epoch，讓所有既有 session 立即失效，回傳新的 epoch。
+// Redis 與 sessions 表的資料清理較慢，交由 CleanupSessionsBefore 在背景執行。
+func (s *SessionService) PurgeAllSessions(ctx context.Context) (int64, error) {
+	return s.BumpSessionEpoch(ctx)
+}
+
+// WithBackground 設定追蹤背景工作的 lifecycle.Group；未設定時背景清理不受追蹤，關閉服務時可能在途中被中斷。
+func (s *SessionService) WithBackground(g *lifecycle.Group) *SessionService {
+	s.background = g
+	return s
+}
+
+// StartCleanupSessionsBefore 在背景執行 CleanupSessionsBefore 並記錄結果。
+// 有設定 WithBackground 時交由 Group 追蹤，關閉服務時會等清理完成，逾時才取消；
+// 沒清完的 session 已因 epoch 失效，只是 Redis 與 sessions 表的資料要等 TTL 或下一次 purge 才會清掉。
+func (s *SessionService) StartCleanupSessionsBefore(epoch int64) {
+	run := func(ctx context.Context) {
+		n, err := s.CleanupSessionsBefore(ctx, epoch)
+		if err != nil {
+			log.Printf("session purge cleanup stopped after %d sessions: %v", n, err)
+			return
+		}
+		log.Printf("session purge cleanup removed %d sessions", n)
+	}
+	if s.background == nil {
+		go run(context.Background())
+		return
+	}
+	s.background.Go(run)
+}
+
+// CleanupSessionsBefore 以 SCAN 分批刪除 epoch 早於指定值的 sess:{sid}，同步移除 user_sess 內的成員，
+// 並將 sessions 表對應的紀錄標記為 revoked_by="admin:purge"。epoch 之後建立的新 session 不受影響。
+// 回傳清除的 session 數量。
+func (s *SessionService) CleanupSessionsBefore(ctx context.Context, epoch int64) (int, error) {
+	purged := 0
+	var cursor uint64
+	for {
+		keys, next, err := s.rdb.Scan(ctx, cursor, infra.SessKeyPattern(), purgeScanBatch).Result()
+		if err != nil {
+			return purged, err
+		}
+
+		var sids []string
+		for _, key := range keys {
+			sid, ok := infra.SessIDFromKey(key)
+			if ok && sessionIDEpoch(sid) < epoch {
+				sids = append(sids, sid)
+			}
+		}
+		n, err := s.purgeBatch(ctx, sids)
+		purged += n
+		if err != nil {
+			return purged, err
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return purged, nil
+		}
+	}
+}
+
+// purgeBatch 刪除一批 session 的 Redis 資料並在 DB 標記撤銷。
+func (s *SessionService) purgeBatch(ctx context.Context, sids []string) (int, error) {
+	if len(sids) == 0 {
+		return 0, nil
+	}
+
+	pipe := s.rdb.Pipeline()
+	owners := make([]*redis.StringCmd, len(sids))
+	for i, sid := range sids {
+		owners[i] = pipe.HGet(ctx, infra.SessKey(sid), "user_id")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, err
+	}
+
+	pipe = s.rdb.Pipeline()
+	changed := map[int64]bool{}
+	for i, sid := range sids {
+		pipe.Del(ctx, infra.SessKey(sid))
+		if uid, err := owners[i].Int64(); err == nil {
+			pipe.ZRem(ctx, infra.UserSessKey(uid), sid)
+			changed[uid] = true
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	for uid := range changed {
+		s.notifySessionsChanged(ctx, uid)
+	}
+
+	for _, sid := range sids {
+		s.recordRevocation(ctx, sid, infra.RevokedByAdminPurge)
+		s.metrics.IncrSessionRevoked(string(infra.RevokedByAdminPurge))
+	}
+	return len(sids), nil
+}
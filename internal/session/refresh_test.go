@@ -0,0 +1,133 @@
+package session
+
+import (
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，計算到期時間
+
+	"github.com/hibiken/asynq"            // 匯入 asynq，檢查排入的 session:expire 任務
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra，取得 Redis key
+)
+
+// loginAgedSession 登入後把 session 的建立與到期時間往前調 age，模擬已經使用一段時間的 session。
+func loginAgedSession(t *testing.T, env *testEnv, age time.Duration) (int64, string) {
+	t.Helper()                                                                                // 標記為測試輔助函式
+	hashed, err := bcryptGenerate("password123")                                              // 產生雜湊
+	require.NoError(t, err)                                                                   // 確保成功
+	user := createTestUser(t, env, "alice", hashed)                                           // 建立使用者
+	_, sid, expiresAt, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 登入
+	require.NoError(t, err)                                                                   // 應登入成功
+
+	key := infra.SessKey(sid)                                       // session hash key
+	created := time.Now().Add(-age)                                 // 提前的建立時間
+	expires := expiresAt.Add(-age)                                  // 提前的到期時間
+	env.mr.HSet(key, "created_at", stringFromInt64(created.Unix())) // 改寫建立時間
+	env.mr.HSet(key, "expires_at", stringFromInt64(expires.Unix())) // 改寫到期時間
+	env.mr.SetTTL(key, time.Until(expires))                         // 同步 TTL
+	return user.ID, sid
+}
+
+// TestRefreshSessionWithoutExtension 測試未開啟 ExtendSessionOnRefresh 時，refresh 不會動到 session 的到期時間。
+func TestRefreshSessionWithoutExtension(t *testing.T) {
+	env := newTestEnv(t)                                    // 建立測試環境（SessionTTL = 1 小時）
+	userID, sid := loginAgedSession(t, env, 30*time.Minute) // 已使用 30 分鐘的 session
+
+	expiresAt, err := env.sessSvc.RefreshSession(env.ctx, userID, sid)                            // refresh
+	require.NoError(t, err)                                                                       // 應成功
+	require.WithinDuration(t, time.Now().Add(30*time.Minute), expiresAt, 2*time.Second)           // 到期時間不變
+	require.InDelta(t, (30 * time.Minute).Seconds(), env.mr.TTL(infra.SessKey(sid)).Seconds(), 2) // Redis TTL 不變
+}
+
+// TestRefreshSessionExtends 測試開啟 ExtendSessionOnRefresh 時，refresh 會把到期時間滑動到 now + SessionTTL，並受 MaxSessionLifetime 限制。
+func TestRefreshSessionExtends(t *testing.T) {
+	env := newTestEnv(t)                                    // 建立測試環境（SessionTTL = 1 小時）
+	env.cfg.ExtendSessionOnRefresh = true                   // 開啟 refresh 延長 session
+	userID, sid := loginAgedSession(t, env, 30*time.Minute) // 已使用 30 分鐘的 session
+
+	expiresAt, err := env.sessSvc.RefreshSession(env.ctx, userID, sid)                                 // refresh
+	require.NoError(t, err)                                                                            // 應成功
+	require.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, 2*time.Second)                     // 滑動到 now + 1 小時
+	require.InDelta(t, time.Hour.Seconds(), env.mr.TTL(infra.SessKey(sid)).Seconds(), 2)               // Redis TTL 同步延長
+	require.Equal(t, stringFromInt64(expiresAt.Unix()), env.mr.HGet(infra.SessKey(sid), "expires_at")) // hash 的 expires_at 同步更新
+
+	env.cfg.MaxSessionLifetime = 70 * time.Minute                                  // 建立後最多存活 70 分鐘
+	expiresAt, err = env.sessSvc.RefreshSession(env.ctx, userID, sid)              // 再次 refresh
+	require.NoError(t, err)                                                        // 應成功
+	require.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, 2*time.Second) // 已超過上限時不會縮短
+
+	_, err = env.sessSvc.RefreshSession(env.ctx, userID+1, sid) // 其他使用者的 session
+	require.ErrorIs(t, err, ErrSessionNotFound)                 // 應視為不存在
+}
+
+// TestRefreshSessionCappedByLifetime 測試延長後的到期時間不超過 created_at + MaxSessionLifetime。
+func TestRefreshSessionCappedByLifetime(t *testing.T) {
+	env := newTestEnv(t)                                    // 建立測試環境（SessionTTL = 1 小時）
+	env.cfg.ExtendSessionOnRefresh = true                   // 開啟 refresh 延長 session
+	env.cfg.MaxSessionLifetime = 70 * time.Minute           // 建立後最多存活 70 分鐘
+	userID, sid := loginAgedSession(t, env, 30*time.Minute) // 已使用 30 分鐘的 session
+
+	expiresAt, err := env.sessSvc.RefreshSession(env.ctx, userID, sid)                            // refresh
+	require.NoError(t, err)                                                                       // 應成功
+	require.WithinDuration(t, time.Now().Add(40*time.Minute), expiresAt, 2*time.Second)           // 只延長到建立後 70 分鐘
+	require.InDelta(t, (40 * time.Minute).Seconds(), env.mr.TTL(infra.SessKey(sid)).Seconds(), 2) // Redis TTL 同樣受限
+}
+
+// TestRefreshSessionFamilyMaxAge 測試 refresh 家族超過 RefreshFamilyMaxAge 後被拒絕，較新的家族仍可正常 refresh。
+func TestRefreshSessionFamilyMaxAge(t *testing.T) {
+	env := newTestEnv(t)                                        // 建立測試環境（SessionTTL = 1 小時）
+	env.cfg.RefreshFamilyMaxAge = 20 * time.Minute              // 家族最多 20 分鐘
+	oldUser, oldSID := loginAgedSession(t, env, 30*time.Minute) // 已使用 30 分鐘的 session
+
+	_, err := env.sessSvc.RefreshSession(env.ctx, oldUser, oldSID)                                   // refresh 舊家族
+	require.ErrorIs(t, err, ErrRefreshFamilyExpired)                                                 // 應被拒絕
+	require.InDelta(t, (30 * time.Minute).Seconds(), env.mr.TTL(infra.SessKey(oldSID)).Seconds(), 2) // session 本身不受影響
+
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 重新登入開始新家族
+	require.NoError(t, err)                                                           // 應登入成功
+	expiresAt, err := env.sessSvc.RefreshSession(env.ctx, oldUser, sid)               // refresh 新家族
+	require.NoError(t, err)                                                           // 應成功
+	require.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, 2*time.Second)    // 到期時間為登入時的 1 小時
+}
+
+// TestRefreshSessionDoesNotPileUpExpireTasks 測試滑動 refresh 不會每次都排入新的 session:expire，只有到期時間提前時才另外排入。
+func TestRefreshSessionDoesNotPileUpExpireTasks(t *testing.T) {
+	env := newTestEnv(t)                                                  // 建立測試環境（SessionTTL = 1 小時）
+	env.cfg.ExtendSessionOnRefresh = true                                 // 開啟 refresh 延長 session
+	opt := asynq.RedisClientOpt{Addr: env.mr.Addr()}                      // asynq 與 session 共用 miniredis
+	client := asynq.NewClient(opt)                                        // 建立 asynq client
+	defer client.Close()                                                  // 測試結束時關閉
+	inspector := asynq.NewInspector(opt)                                  // 建立 inspector 以檢查排入的任務
+	defer inspector.Close()                                               // 測試結束時關閉
+	env.sessSvc = NewSessionService(env.q, env.rdb, env.cfg, client, nil) // 改用會排任務的 SessionService
+
+	hashed, err := bcryptGenerate("password123")                                      // 產生雜湊
+	require.NoError(t, err)                                                           // 確保成功
+	user := createTestUser(t, env, "alice", hashed)                                   // 建立使用者
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 登入時排入第一個任務
+	require.NoError(t, err)                                                           // 應登入成功
+	expireTasks := func() int {
+		tasks, err := inspector.ListScheduledTasks("default") // 列出排程中的任務
+		require.NoError(t, err)                               // 查詢應成功
+		n := 0
+		for _, task := range tasks {
+			if task.Type == infra.TaskTypeSessionExpire {
+				n++ // 只計算 session:expire
+			}
+		}
+		return n
+	}
+	require.Equal(t, 1, expireTasks()) // 登入排入一個
+
+	for i := 2; i <= 4; i++ { // 連續 refresh，每次都把到期時間往後延
+		env.cfg.SessionTTL = time.Duration(i) * time.Hour           // 讓每次的到期時間不同
+		_, err := env.sessSvc.RefreshSession(env.ctx, user.ID, sid) // 滑動延長
+		require.NoError(t, err)                                     // 應成功
+	}
+	require.Equal(t, 1, expireTasks()) // 仍只有登入時的任務，觸發時由 worker 改排
+
+	shorter := time.Now().Add(10 * time.Minute)                                                                     // 提前的到期時間
+	require.NoError(t, env.sessSvc.setSessionExpiry(env.ctx, user.ID, sid, shorter))                                // 縮短 session
+	require.Equal(t, 2, expireTasks())                                                                              // 提前時另外排入
+	require.Equal(t, stringFromInt64(shorter.Unix()), env.mr.HGet(infra.SessKey(sid), infra.SessExpireTaskAtField)) // 記錄新的排程時間
+}
@@ -0,0 +1,97 @@
+package session
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// incrRequestCountScript 只在 hash 仍存在時累加 request_count，與 touchLastSeenScript 相同，避免重新建立沒有 TTL 的 hash。
+var incrRequestCountScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+  return redis.call('HINCRBY', KEYS[1], 'request_count', ARGV[1])
+end
+return 0
+`)
+
+// requestCounter 在本機累計每個 session 尚未寫入 Redis 的請求數。
+// 多個 API instance 各自累計、各自以 HINCRBY 寫入，Redis 中的值即為總和。
+type requestCounter struct {
+	mu        sync.Mutex
+	pending   map[string]*pendingRequests // key 為 sess:{sid}
+	lastSweep time.Time
+}
+
+// pendingRequests 是某個 session 自 since 起累計、尚未寫入的請求數。
+type pendingRequests struct {
+	n     int64
+	since time.Time
+}
+
+func newRequestCounter() *requestCounter {
+	return &requestCounter{pending: make(map[string]*pendingRequests)}
+}
+
+// countRequest 記錄一次通過驗證的請求。同一個 session 在 RequestCountInterval 內只寫一次 Redis：
+// 區間內第一個請求立即寫入，其餘先在本機累計，區間過後的下一個請求再一次寫入。
+// 之後不再有請求的 session 由 sweep 補寫，因此 Redis 中的值最多落後約兩個區間。寫入失敗不影響請求。
+func (s *SessionService) countRequest(ctx context.Context, sessKey string) {
+	interval := s.cfg.RequestCountInterval
+	if interval <= 0 {
+		return
+	}
+
+	now := time.Now()
+	c := s.requests
+	c.mu.Lock()
+	var n int64
+	p, ok := c.pending[sessKey]
+	switch {
+	case !ok:
+		c.pending[sessKey] = &pendingRequests{since: now}
+		n = 1
+	case now.Sub(p.since) >= interval:
+		n = p.n + 1
+		p.n, p.since = 0, now
+	default:
+		p.n++
+	}
+	stale := c.sweep(now, interval, sessKey)
+	c.mu.Unlock()
+
+	if n > 0 {
+		_ = incrRequestCountScript.Run(ctx, s.rdb, []string{sessKey}, n).Err()
+	}
+	for key, n := range stale {
+		_ = incrRequestCountScript.Run(ctx, s.rdb, []string{key}, n).Err()
+	}
+}
+
+// sweep 每個區間最多執行一次，移除超過區間沒有請求的 session，回傳其中還有未寫入請求數的部分；呼叫端需持有 mu。
+func (c *requestCounter) sweep(now time.Time, interval time.Duration, skip string) map[string]int64 {
+	if now.Sub(c.lastSweep) < interval {
+		return nil
+	}
+	c.lastSweep = now
+
+	stale := make(map[string]int64)
+	for key, p := range c.pending {
+		if key == skip || now.Sub(p.since) < interval {
+			continue
+		}
+		if p.n > 0 {
+			stale[key] = p.n
+		}
+		delete(c.pending, key)
+	}
+	return stale
+}
+
+// sessionRequestCount 讀出 session hash 的 request_count，未記錄時為 0。
+func sessionRequestCount(data map[string]string) int64 {
+	n, _ := strconv.ParseInt(data["request_count"], 10, 64)
+	return n
+}
@@ -0,0 +1,56 @@
+package session
+
+import (
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，設定寫入間隔
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra，讀取 session key
+)
+
+// TestRequestCountThrottled 測試請求數在區間內先於本機累計，區間過後一次寫入，sweep 會補寫閒置 session 的請求數。
+func TestRequestCountThrottled(t *testing.T) {
+	env := newTestEnv(t)                            // 建立測試環境
+	env.cfg.RequestCountInterval = 30 * time.Second // 每 30 秒最多寫一次
+	env.cfg.MaxSessionsPerUser = 5                  // 允許兩個 session 同時存在
+
+	hashed, err := bcryptGenerate("password123")                                       // 產生雜湊
+	require.NoError(t, err)                                                            // 確保成功
+	user := createTestUser(t, env, "alice", hashed)                                    // 建立使用者
+	_, busy, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 頻繁發請求的 session
+	require.NoError(t, err)                                                            // 應登入成功
+	_, idle, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 之後閒置的 session
+	require.NoError(t, err)                                                            // 應登入成功
+
+	validate := func(sid string, times int) {
+		for i := 0; i < times; i++ {
+			ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid) // 模擬一次通過驗證的請求
+			require.NoError(t, err)                                      // 檢查不應失敗
+			require.True(t, ok)                                          // session 應有效
+		}
+	}
+	stored := func(sid string) string {
+		return env.mr.HGet(infra.SessKey(sid), "request_count") // 讀取 Redis 中的請求數
+	}
+
+	validate(busy, 20)                  // 區間內 20 個請求
+	validate(idle, 3)                   // 閒置 session 先有 3 個請求
+	require.Equal(t, "1", stored(busy)) // 只有第一個請求立即寫入
+	require.Equal(t, "1", stored(idle)) // 其餘在本機累計
+
+	past := time.Now().Add(-31 * time.Second)                      // 超過間隔的時間
+	env.sessSvc.requests.pending[infra.SessKey(busy)].since = past // 模擬上次寫入已過 31 秒
+	env.sessSvc.requests.pending[infra.SessKey(idle)].since = past // 閒置 session 同樣已過 31 秒
+	env.sessSvc.requests.lastSweep = past                          // 上次 sweep 也已過 31 秒
+
+	validate(busy, 1)                                                         // 區間過後的下一個請求
+	require.Equal(t, "21", stored(busy))                                      // 一次寫入累計的 19 個加上這次
+	require.Equal(t, "3", stored(idle))                                       // sweep 補寫閒置 session 的 2 個請求
+	require.NotContains(t, env.sessSvc.requests.pending, infra.SessKey(idle)) // 閒置 session 不再佔用記憶體
+
+	sessions, err := env.sessSvc.ListActiveSessions(env.ctx, user.ID, ListSessionsOptions{}) // 列出 session
+	require.NoError(t, err)                                                                  // 應成功
+	require.EqualValues(t, 21, sessions[0].RequestCount)                                     // 列表帶出請求數
+	require.EqualValues(t, 3, sessions[1].RequestCount)                                      // 依建立順序
+}
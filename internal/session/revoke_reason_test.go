@@ -0,0 +1,106 @@
+package session
+
+import (
+	"database/sql" // 匯入 database/sql，讀取 revoked_by 欄位
+	"testing"      // 匯入 testing，提供單元測試框架
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/db"    // 匯入 db 套件，直接寫入 revoked_by
+	"sessionservice/internal/infra" // 匯入 infra 套件，取得撤銷原因常數
+)
+
+// sessionRevokedBy 讀出 sessions 表中 session 的 revoked_by。
+func sessionRevokedBy(t *testing.T, env *testEnv, sid string) string {
+	t.Helper()                                                                                                      // 標記為測試輔助函式
+	var revokedBy sql.NullString                                                                                    // 用來接收 revoked_by 欄位
+	err := env.sqlDB.QueryRowContext(env.ctx, "SELECT revoked_by FROM sessions WHERE id = ?", sid).Scan(&revokedBy) // 查詢 revoked_by
+	require.NoError(t, err)                                                                                         // 查詢應成功
+	return revokedBy.String
+}
+
+// TestRevocationPathsWriteCanonicalReason 測試每個撤銷 session 的路徑都寫入 infra.RevokeReasons 中對應的原因。
+func TestRevocationPathsWriteCanonicalReason(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		revoke func(t *testing.T, env *testEnv, user db.User, sid string)
+		want   infra.RevokeReason
+	}{
+		{"logout", func(t *testing.T, env *testEnv, user db.User, sid string) {
+			require.NoError(t, env.sessSvc.Logout(env.ctx, user.ID, sid)) // 使用者登出
+		}, infra.RevokedByUser},
+		{"kick", func(t *testing.T, env *testEnv, user db.User, sid string) {
+			require.NoError(t, env.sessSvc.KickSession(env.ctx, user.ID, sid)) // admin 踢除單一 session
+		}, infra.RevokedByAdminKick},
+		{"kick all", func(t *testing.T, env *testEnv, user db.User, sid string) {
+			require.NoError(t, env.sessSvc.KickAllSessions(env.ctx, user.ID)) // admin 踢除所有 session
+		}, infra.RevokedByAdminKick},
+		{"kick device", func(t *testing.T, env *testEnv, user db.User, sid string) {
+			_, err := env.sessSvc.KickByDevice(env.ctx, "device-1") // admin 踢除裝置
+			require.NoError(t, err)                                 // 應成功
+		}, infra.RevokedByAdminKickDevice},
+		{"ban", func(t *testing.T, env *testEnv, user db.User, sid string) {
+			require.NoError(t, env.sessSvc.BanUser(env.ctx, user.ID)) // admin 封鎖
+		}, infra.RevokedByAdminBan},
+		{"force reset", func(t *testing.T, env *testEnv, user db.User, sid string) {
+			require.NoError(t, env.sessSvc.ForceResetPassword(env.ctx, user.ID)) // admin 強制重設密碼
+		}, infra.RevokedByAdminForceReset},
+		{"delete", func(t *testing.T, env *testEnv, user db.User, sid string) {
+			require.NoError(t, env.sessSvc.DeleteUser(env.ctx, user.ID)) // admin 刪除使用者
+		}, infra.RevokedByAdminDelete},
+		{"password reset", func(t *testing.T, env *testEnv, user db.User, sid string) {
+			require.NoError(t, env.sessSvc.ResetPassword(env.ctx, issueResetToken(t, env, user.ID), "fresh-password-456", LoginMeta{})) // 使用者重設密碼
+		}, infra.RevokedByPasswordReset},
+		{"username change", func(t *testing.T, env *testEnv, user db.User, sid string) {
+			_, current, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 另一個 session 發起改名
+			require.NoError(t, err)                                                               // 應登入成功
+			_, err = env.sessSvc.ChangeUsername(env.ctx, user.ID, current, "alice2")              // 改名
+			require.NoError(t, err)                                                               // 應成功
+		}, infra.RevokedByUsernameChange},
+		{"session limit", func(t *testing.T, env *testEnv, user db.User, sid string) {
+			for i := 0; i < env.cfg.MaxSessionsPerUser; i++ {
+				_, _, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 持續登入直到超過上限
+				require.NoError(t, err)                                                         // 應登入成功
+			}
+		}, infra.RevokedBySessionLimit},
+		{"purge", func(t *testing.T, env *testEnv, user db.User, sid string) {
+			epoch, err := env.sessSvc.PurgeAllSessions(env.ctx)        // admin 清除所有 session
+			require.NoError(t, err)                                    // 應成功
+			_, err = env.sessSvc.CleanupSessionsBefore(env.ctx, epoch) // 清理舊 epoch 的 session
+			require.NoError(t, err)                                    // 清理應成功
+		}, infra.RevokedByAdminPurge},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t)                            // 建立測試環境
+			hashed, err := bcryptGenerate("password123")    // 產生密碼雜湊
+			require.NoError(t, err)                         // 應產生成功
+			user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+			_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{DeviceID: "device-1"}) // 建立 session
+			require.NoError(t, err)                                                                               // 應登入成功
+
+			tc.revoke(t, env, user, sid)                                     // 走指定的撤銷路徑
+			require.Equal(t, string(tc.want), sessionRevokedBy(t, env, sid)) // 應寫入對應的原因
+			require.Contains(t, infra.RevokeReasons, tc.want)                // 且屬於合法的原因
+		})
+	}
+}
+
+// TestRevokedByRejectsUnknownValue 測試 sessions 表拒絕寫入 infra.RevokeReasons 以外的 revoked_by。
+func TestRevokedByRejectsUnknownValue(t *testing.T) {
+	env := newTestEnv(t)                                                              // 建立測試環境
+	hashed, err := bcryptGenerate("password123")                                      // 產生密碼雜湊
+	require.NoError(t, err)                                                           // 應產生成功
+	createTestUser(t, env, "alice", hashed)                                           // 建立使用者
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 建立 session
+	require.NoError(t, err)                                                           // 應登入成功
+
+	err = env.q.RevokeSession(env.ctx, db.RevokeSessionParams{ID: sid, RevokedBy: infra.RevokeReason("admin:whatever").NullString()}) // 自由格式的原因
+	require.ErrorContains(t, err, "invalid revoked_by")                                                                               // 應被 trigger 拒絕
+	require.Empty(t, sessionRevokedBy(t, env, sid))                                                                                   // session 不受影響
+
+	for _, r := range infra.RevokeReasons {
+		_, err := env.sqlDB.ExecContext(env.ctx, "UPDATE sessions SET revoked_by = ? WHERE id = ?", string(r), sid) // 每個合法的原因
+		require.NoError(t, err, r)                                                                                  // 都應可寫入
+	}
+}
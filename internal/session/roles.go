@@ -0,0 +1,82 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"sessionservice/internal/db"
+)
+
+// ErrInvalidRole 表示角色名稱不符合 rolePattern。
+var ErrInvalidRole = errors.New("invalid role")
+
+// rolePattern 限制角色名稱為小寫英數字、底線與連字號，避免與 users.roles 的逗號分隔衝突。
+var rolePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// UserRoles 拆解 users.roles（逗號分隔）成角色清單，沒有角色時回傳 nil。
+func UserRoles(u db.User) []string {
+	var roles []string
+	for _, r := range strings.Split(u.Roles, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
+
+// SessionTTLFor 回傳使用者登入時採用的 session 存活時間：ROLE_SESSION_TTL 中有對應的角色時取其中最短者，
+// 否則沿用 SessionTTL。
+func (s *SessionService) SessionTTLFor(u db.User) time.Duration {
+	ttl := time.Duration(0)
+	for _, r := range UserRoles(u) {
+		if d, ok := s.cfg.RoleSessionTTL[r]; ok && (ttl == 0 || d < ttl) {
+			ttl = d
+		}
+	}
+	if ttl == 0 {
+		return s.cfg.SessionTTL
+	}
+	return ttl
+}
+
+// maxSessionTTL 回傳 session 可能剩餘的最長存活時間：SessionTTL、所有 RoleSessionTTL 與 MaxSessionLifetime 中最長者。
+func (s *SessionService) maxSessionTTL() time.Duration {
+	ttl := max(s.cfg.SessionTTL, s.cfg.MaxSessionLifetime)
+	for _, d := range s.cfg.RoleSessionTTL {
+		if d > ttl {
+			ttl = d
+		}
+	}
+	return ttl
+}
+
+// SetUserRoles 以 roles 取代使用者的角色（名稱轉成小寫並去除重複）；使用者不存在時回傳 ErrUserNotFound。
+// 已建立的 session 維持原本的到期時間，新的存活時間從下次登入起生效。
+func (s *SessionService) SetUserRoles(ctx context.Context, userID int64, roles []string) ([]string, error) {
+	normalized := make([]string, 0, len(roles))
+	for _, r := range roles {
+		r = strings.ToLower(strings.TrimSpace(r))
+		if !rolePattern.MatchString(r) {
+			return nil, ErrInvalidRole
+		}
+		if !slices.Contains(normalized, r) {
+			normalized = append(normalized, r)
+		}
+	}
+
+	n, err := s.q.SetUserRoles(ctx, db.SetUserRolesParams{
+		ID:    userID,
+		Roles: strings.Join(normalized, ","),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrUserNotFound
+	}
+	return normalized, nil
+}
@@ -0,0 +1,55 @@
+package session
+
+import (
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，設定與比對存活時間
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra，取得 Redis key
+)
+
+// TestRoleSessionTTL 測試登入依使用者角色套用 ROLE_SESSION_TTL：admin 取得較短的存活時間，一般使用者沿用 SessionTTL，多個角色時取最短。
+func TestRoleSessionTTL(t *testing.T) {
+	env := newTestEnv(t)                                                                                      // 建立測試環境（SessionTTL = 1 小時）
+	env.cfg.RoleSessionTTL = map[string]time.Duration{"admin": 15 * time.Minute, "support": 30 * time.Minute} // admin 與 support 較短
+
+	hashed, err := bcryptGenerate("password123")     // 產生雜湊
+	require.NoError(t, err)                          // 確保成功
+	admin := createTestUser(t, env, "alice", hashed) // 之後設為 admin 的使用者
+	createTestUser(t, env, "bob", hashed)            // 沒有角色的使用者
+
+	roles, err := env.sessSvc.SetUserRoles(env.ctx, admin.ID, []string{"Support", "admin", "support"}) // 設定角色
+	require.NoError(t, err)                                                                            // 應成功
+	require.Equal(t, []string{"support", "admin"}, roles)                                              // 轉小寫並去除重複
+
+	_, sid, exp, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{})           // admin 登入
+	require.NoError(t, err)                                                                       // 應登入成功
+	require.WithinDuration(t, time.Now().Add(15*time.Minute), exp, 2*time.Second)                 // 多個角色取最短的 15 分鐘
+	require.InDelta(t, (15 * time.Minute).Seconds(), env.mr.TTL(infra.SessKey(sid)).Seconds(), 2) // Redis TTL 一致
+	require.Equal(t, stringFromInt64(exp.Unix()), env.mr.HGet(infra.SessKey(sid), "expires_at"))  // hash 的 expires_at 一致
+
+	_, sid, exp, err = env.sessSvc.Login(env.ctx, "bob", "password123", LoginMeta{})     // 一般使用者登入
+	require.NoError(t, err)                                                              // 應登入成功
+	require.WithinDuration(t, time.Now().Add(time.Hour), exp, 2*time.Second)             // 沿用 SessionTTL
+	require.InDelta(t, time.Hour.Seconds(), env.mr.TTL(infra.SessKey(sid)).Seconds(), 2) // Redis TTL 一致
+
+	_, err = env.sessSvc.SetUserRoles(env.ctx, admin.ID, []string{"bad,role"})  // 含逗號的角色名稱
+	require.ErrorIs(t, err, ErrInvalidRole)                                     // 應被拒絕
+	_, err = env.sessSvc.SetUserRoles(env.ctx, admin.ID+100, []string{"admin"}) // 不存在的使用者
+	require.ErrorIs(t, err, ErrUserNotFound)                                    // 應回傳 ErrUserNotFound
+}
+
+// TestRoleSessionTTLRefresh 測試開啟 ExtendSessionOnRefresh 時，refresh 依角色的存活時間滑動，不會拉長到 SessionTTL。
+func TestRoleSessionTTLRefresh(t *testing.T) {
+	env := newTestEnv(t)                                                         // 建立測試環境（SessionTTL = 1 小時）
+	env.cfg.ExtendSessionOnRefresh = true                                        // 開啟 refresh 延長 session
+	env.cfg.RoleSessionTTL = map[string]time.Duration{"admin": 15 * time.Minute} // admin 只有 15 分鐘
+	userID, sid := loginAgedSession(t, env, 50*time.Minute)                      // 已使用 50 分鐘、只剩 10 分鐘的 session
+	_, err := env.sessSvc.SetUserRoles(env.ctx, userID, []string{"admin"})       // 登入後才設為 admin
+	require.NoError(t, err)                                                      // 應成功
+
+	expiresAt, err := env.sessSvc.RefreshSession(env.ctx, userID, sid)                  // refresh
+	require.NoError(t, err)                                                             // 應成功
+	require.WithinDuration(t, time.Now().Add(15*time.Minute), expiresAt, 2*time.Second) // 滑動到 now + 15 分鐘，而不是 SessionTTL 的 1 小時
+}
@@ -5,22 +5,28 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"github.com/redis/go-redis/v9"
-	"golang.org/x/crypto/bcrypt"
 
 	"sessionservice/internal/config"
 	"sessionservice/internal/db"
+	"sessionservice/internal/flags"
 	"sessionservice/internal/infra"
+	"sessionservice/internal/lifecycle"
 )
 
 // LoginMeta 描述一個登入請求的額外資訊。
 type LoginMeta struct {
 	IP        string
 	UserAgent string
+	DeviceID  string // client 提供的穩定裝置 ID（例如 App 產生的 UUID），可為空
+	BrowserID string // client 產生、存在瀏覽器內的 ID；同一台裝置上的不同瀏覽器各自不同，可為空
+	Country   string // 前端 proxy / CDN 標注的國碼（ISO 3166-1 alpha-2），可為空
 }
 
 // SessionService 處理與 session 相關的 domain 邏輯。
@@ -29,164 +35,380 @@ type SessionService struct {
 	rdb        *redis.Client
 	cfg        *config.Config
 	asynqClient *asynq.Client
+	metrics    Metrics
+	requests   *requestCounter
+	flags      *flags.FeatureFlags
+	bcrypt     *bcryptLimiter
+	signupHook SignupHook
+	geo        GeoResolver
+	background *lifecycle.Group
 }
 
-func NewSessionService(q *db.Queries, rdb *redis.Client, cfg *config.Config, asynqClient *asynq.Client) *SessionService {
+// NewSessionService 建立 SessionService；metrics 為 nil 時不回報任何指標。
+func NewSessionService(q *db.Queries, rdb *redis.Client, cfg *config.Config, asynqClient *asynq.Client, metrics Metrics) *SessionService {
+	if metrics == nil {
+		metrics = NopMetrics{}
+	}
 	return &SessionService{
 		q:          q,
 		rdb:        rdb,
 		cfg:        cfg,
 		asynqClient: asynqClient,
+		metrics:    metrics,
+		requests:   newRequestCounter(),
+		flags:      flags.New(rdb, cfg.FeatureFlagsRefresh, FlagSessionDBFallback, FlagExtendSessionOnRefresh),
+		bcrypt:     newBcryptLimiter(cfg.BcryptMaxConcurrency, cfg.BcryptQueueDepth, cfg.BcryptQueueTimeout),
+		signupHook: NopSignupHook,
 	}
 }
 
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserBanned         = errors.New("user is banned")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrLifetimeExceeded   = errors.New("session lifetime exceeded")
+
+	ErrRefreshFamilyExpired = errors.New("refresh family exceeded its maximum age")
+
+	ErrPasswordResetRequired = errors.New("password reset required")
+	ErrPasswordExpired       = errors.New("password expired")
+	ErrPasswordReused        = errors.New("new password must differ from the current one")
+	ErrResetTokenInvalid     = errors.New("password reset token is invalid or expired")
 )
 
+// PasswordExpiredError 表示密碼已超過 PasswordMaxAge；密碼本身已驗證通過，因此附上重設 token 讓使用者直接更換。
+// errors.Is(err, ErrPasswordExpired) 成立。
+type PasswordExpiredError struct {
+	ResetToken string
+}
+
+func (e *PasswordExpiredError) Error() string {
+	return ErrPasswordExpired.Error()
+}
+
+func (e *PasswordExpiredError) Is(target error) bool {
+	return target == ErrPasswordExpired
+}
+
 // Login 驗證帳密，建立 Redis session，並寫入 sessions 資料表。
 func (s *SessionService) Login(
 	ctx context.Context,
 	username, password string,
 	meta LoginMeta,
 ) (user db.User, sessionID string, expiresAt time.Time, err error) {
-	// 1. 查詢使用者
+	start := time.Now()
+	defer func() {
+		s.metrics.IncrLogin(loginOutcome(err))
+		s.metrics.ObserveLoginLatency(time.Since(start))
+	}()
+
+	// 1. 查詢使用者（與 signup 使用相同的正規化規則）
+	username = NormalizeUsername(username)
 	u, err := s.q.GetUserByUsername(ctx, username)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// 登入失敗 audit
-			_ = infra.EnqueueLoginAudit(ctx, s.asynqClient, infra.LoginAuditPayload{
-				UserID:    nil,
-				Username:  username,
-				Success:   false,
-				Reason:    "user_not_found",
-				IP:        meta.IP,
-				UserAgent: meta.UserAgent,
-			})
+			s.auditLoginFailure(ctx, nil, username, LoginReasonUserNotFound, meta)
 			return db.User{}, "", time.Time{}, ErrInvalidCredentials
 		}
 		return db.User{}, "", time.Time{}, err
 	}
 
+	if err := s.checkNotBanned(ctx, u, meta); err != nil {
+		return db.User{}, "", time.Time{}, err
+	}
+
+	// 2. 驗證密碼（沿用 Phase 1 的 bcrypt 邏輯）
+	compareStart := time.Now()
+	if err := s.comparePassword(ctx, u, password); err != nil {
+		if errors.Is(err, ErrPasswordCheckBusy) || ctx.Err() != nil {
+			// 沒有真正比對密碼，不算一次密碼錯誤
+			return db.User{}, "", time.Time{}, err
+		}
+		s.auditLoginFailure(ctx, &u.ID, u.Username, LoginReasonWrongPassword, meta)
+		return db.User{}, "", time.Time{}, ErrInvalidCredentials
+	}
+
+	// 密碼已被標記外洩（admin force-reset），重設前不發 session
+	if u.MustResetPassword {
+		s.auditLoginFailure(ctx, &u.ID, u.Username, LoginReasonMustResetPassword, meta)
+		return db.User{}, "", time.Time{}, ErrPasswordResetRequired
+	}
+
+	// 密碼超過 PasswordMaxAge 未更換，同樣要求先重設；密碼未外洩，直接發出重設 token
+	if s.passwordExpired(u) {
+		s.auditLoginFailure(ctx, &u.ID, u.Username, LoginReasonPasswordExpired, meta)
+		resetToken, err := s.IssuePasswordResetToken(ctx, u.ID)
+		if err != nil {
+			return db.User{}, "", time.Time{}, err
+		}
+		return db.User{}, "", time.Time{}, &PasswordExpiredError{ResetToken: resetToken}
+	}
+
+	// 雜湊 cost 低於設定時升級（明文不會離開這次請求）
+	s.maybeRehash(ctx, u, password, time.Since(compareStart))
+
+	newSID, expiresAt, err := s.startSession(ctx, u, meta)
+	if err != nil {
+		return db.User{}, "", time.Time{}, err
+	}
+	return u, newSID, expiresAt, nil
+}
+
+// LoginTrusted 為已由其他方式驗證身分的呼叫端（例如 signed login）建立 session，不檢查密碼。
+// 仍會檢查 ban 狀態、force-reset 與密碼過期並寫入 login audit；密碼過期時不發重設 token，需由帳號擁有者另行重設。
+func (s *SessionService) LoginTrusted(
+	ctx context.Context,
+	username string,
+	meta LoginMeta,
+) (user db.User, sessionID string, expiresAt time.Time, err error) {
+	start := time.Now()
+	defer func() {
+		s.metrics.IncrLogin(loginOutcome(err))
+		s.metrics.ObserveLoginLatency(time.Since(start))
+	}()
+
+	username = NormalizeUsername(username)
+	u, err := s.q.GetUserByUsername(ctx, username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			s.auditLoginFailure(ctx, nil, username, LoginReasonUserNotFound, meta)
+			return db.User{}, "", time.Time{}, ErrInvalidCredentials
+		}
+		return db.User{}, "", time.Time{}, err
+	}
+	if err := s.checkNotBanned(ctx, u, meta); err != nil {
+		return db.User{}, "", time.Time{}, err
+	}
+	if u.MustResetPassword {
+		s.auditLoginFailure(ctx, &u.ID, u.Username, LoginReasonMustResetPassword, meta)
+		return db.User{}, "", time.Time{}, ErrPasswordResetRequired
+	}
+	if s.passwordExpired(u) {
+		s.auditLoginFailure(ctx, &u.ID, u.Username, LoginReasonPasswordExpired, meta)
+		return db.User{}, "", time.Time{}, ErrPasswordExpired
+	}
+
+	newSID, expiresAt, err := s.startSession(ctx, u, meta)
+	if err != nil {
+		return db.User{}, "", time.Time{}, err
+	}
+	return u, newSID, expiresAt, nil
+}
+
+// checkNotBanned 檢查 DB 與 Redis 的 ban 狀態，被 ban 時寫入 audit 並回傳 ErrUserBanned。
+func (s *SessionService) checkNotBanned(ctx context.Context, u db.User, meta LoginMeta) error {
 	// 檢查是否被 ban（DB）
 	if u.IsBanned {
-		_ = infra.EnqueueLoginAudit(ctx, s.asynqClient, infra.LoginAuditPayload{
-			UserID:    &u.ID,
-			Username:  u.Username,
-			Success:   false,
-			Reason:    "banned_db",
-			IP:        meta.IP,
-			UserAgent: meta.UserAgent,
-		})
-		return db.User{}, "", time.Time{}, ErrUserBanned
+		s.auditLoginFailure(ctx, &u.ID, u.Username, LoginReasonBannedDB, meta)
+		return ErrUserBanned
 	}
 
 	// 檢查是否被 ban（Redis flag）
 	if banned, err := s.rdb.Exists(ctx, infra.BannedUserKey(u.ID)).Result(); err == nil && banned > 0 {
-		_ = infra.EnqueueLoginAudit(ctx, s.asynqClient, infra.LoginAuditPayload{
-			UserID:    &u.ID,
-			Username:  u.Username,
-			Success:   false,
-			Reason:    "banned_redis",
-			IP:        meta.IP,
-			UserAgent: meta.UserAgent,
-		})
-		return db.User{}, "", time.Time{}, ErrUserBanned
+		s.auditLoginFailure(ctx, &u.ID, u.Username, LoginReasonBannedRedis, meta)
+		return ErrUserBanned
 	}
+	return nil
+}
 
-	// 2. 驗證密碼（沿用 Phase 1 的 bcrypt 邏輯）
-	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
-		_ = infra.EnqueueLoginAudit(ctx, s.asynqClient, infra.LoginAuditPayload{
-			UserID:    &u.ID,
-			Username:  u.Username,
-			Success:   false,
-			Reason:    "wrong_password",
-			IP:        meta.IP,
-			UserAgent: meta.UserAgent,
-		})
-		return db.User{}, "", time.Time{}, ErrInvalidCredentials
+// startSession 在驗證通過後建立 session：控制同時登入數、寫入 Redis 與 sessions 表，並排入相關任務。
+func (s *SessionService) startSession(ctx context.Context, u db.User, meta LoginMeta) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(s.SessionTTLFor(u))
+
+	// 依來源國家限制登入；GeoResolver 查出的國碼一併寫入 session 與 audit
+	country, err := s.checkLoginCountry(ctx, u, meta)
+	if err != nil {
+		return "", time.Time{}, err
 	}
+	meta.Country = country
 
-	now := time.Now()
-	expiresAt = now.Add(s.cfg.SessionTTL)
+	// 使用者仍有來自其他國家的 session 時拒絕，防止帳號共用
+	if err := s.checkMultiCountry(ctx, u, meta); err != nil {
+		return "", time.Time{}, err
+	}
 
-	// 3. 控制同時登入數：若超過 MaxSessionsPerUser，踢掉最舊的 session
-	if s.cfg.MaxSessionsPerUser > 0 {
-		key := infra.UserSessKey(u.ID)
-		count, err := s.rdb.ZCard(ctx, key).Result()
-		if err != nil && err != redis.Nil {
-			return db.User{}, "", time.Time{}, err
+	// 同一使用者短時間內建立太多 session 時拒絕，避免異常 client 反覆登入、踢除 session
+	if err := s.checkLoginRate(ctx, u, meta); err != nil {
+		return "", time.Time{}, err
+	}
+
+	// 同一個 browser_id 上只保留最新的 session，先踢掉舊的再計算同時登入數
+	if s.cfg.SingleSessionPerBrowser && meta.BrowserID != "" {
+		if err := s.enforceBrowserSession(ctx, u.ID, meta.BrowserID); err != nil {
+			return "", time.Time{}, err
 		}
-		if count >= int64(s.cfg.MaxSessionsPerUser) {
-			// 取得最舊的 session（score 最小者）
-			oldest, err := s.rdb.ZRange(ctx, key, 0, 0).Result()
-			if err != nil && err != redis.Nil {
-				return db.User{}, "", time.Time{}, err
-			}
-			if len(oldest) > 0 {
-				oldSID := oldest[0]
-				// 刪除 Redis 裡舊的 session 資料
-				pipe := s.rdb.TxPipeline()
-				pipe.Del(ctx, infra.SessKey(oldSID))
-				pipe.ZRem(ctx, key, oldSID)
-				_, _ = pipe.Exec(ctx)
-
-				// 資料庫裡的 session 記錄：標記 revoked_at / revoked_by
-				_ = s.q.RevokeSession(ctx, db.RevokeSessionParams{
-					ID:        oldSID,
-					RevokedBy: sql.NullString{String: "system:limit", Valid: true},
-				})
-			}
+	}
+
+	// 3. 控制同時登入數：若超過上限，踢掉最舊的未 pin session（有設定裝置類別上限時只在同類別內踢）
+	var limitErr error
+	if len(s.cfg.MaxSessionsPerDevice) > 0 {
+		limitErr = s.enforceDeviceSessionLimit(ctx, u.ID, DeviceCategory(meta.UserAgent))
+	} else if s.cfg.MaxSessionsPerUser > 0 {
+		limitErr = s.enforceUserSessionLimit(ctx, u.ID)
+	}
+	if limitErr != nil {
+		if limitErr == ErrSessionLimitReached {
+			s.auditLoginFailure(ctx, &u.ID, u.Username, LoginReasonSessionLimitPinned, meta)
 		}
+		return "", time.Time{}, limitErr
 	}
 
-	// 4. 為這次登入產生新的 session ID
-	newSID := uuid.NewString()
+	// 同一個 device_id 上只保留 MaxSessionsPerDeviceID 個 session（只處理同一使用者的 session）
+	if s.cfg.MaxSessionsPerDeviceID > 0 && meta.DeviceID != "" {
+		if err := s.enforceDeviceIDLimit(ctx, u.ID, meta.DeviceID); err != nil {
+			return "", time.Time{}, err
+		}
+	}
 
-	// 5. 寫入 Redis：sess:{sid} hash + user_sess:{uid} zset
+	// 4. 為這次登入產生新的 session ID，前綴帶上目前的 epoch
+	epoch, err := s.CurrentSessionEpoch(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	newSID := newSessionID(epoch, s.cfg.SessionIDEncoding)
+
+	// 5. 先寫入 SQLite sessions 表（作為 audit）；DB 失敗時 Redis 尚未寫入，不會留下沒有紀錄的 session
+	// SESSION_RECORD_MODE=async 時改在 Redis 寫入後排入 session:record，登入請求不碰 DB
+	recordAsync := s.cfg.SessionRecordMode == SessionRecordAsync
+	if !recordAsync {
+		if err := s.q.CreateSession(ctx, db.CreateSessionParams{
+			ID:        newSID,
+			UserID:    u.ID,
+			CreatedAt: now,
+			ExpiresAt: expiresAt,
+		}); err != nil {
+			return "", time.Time{}, err
+		}
+	}
+
+	// 6. 再寫入 Redis：sess:{sid} hash + user_sess:{uid} zset
 	sessKey := infra.SessKey(newSID)
 	userSessKey := infra.UserSessKey(u.ID)
 
-	pipe := s.rdb.TxPipeline()
-	pipe.HSet(ctx, sessKey, map[string]interface{}{
+	fields := map[string]interface{}{
 		"user_id":    u.ID,
 		"created_at": now.Unix(),
 		"expires_at": expiresAt.Unix(),
 		"ip":         meta.IP,
 		"user_agent": meta.UserAgent,
-	})
+	}
+	if meta.DeviceID != "" {
+		fields["device_id"] = meta.DeviceID
+	}
+	if meta.Country != "" {
+		fields["country"] = meta.Country
+	}
+	if meta.BrowserID != "" {
+		fields["browser_id"] = meta.BrowserID
+	}
+
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, sessKey, fields)
 	pipe.ExpireAt(ctx, sessKey, expiresAt)
 	pipe.ZAdd(ctx, userSessKey, redis.Z{
 		Score:  float64(now.UnixNano()), // 使用 UnixNano 當 score，確保每次登入都有嚴格遞增的時間序，避免同一秒內多次登入導致排序不穩定
 		Member: newSID,
 	})
+	if meta.DeviceID != "" {
+		// device_sess 索引跟著最新的 session 一起到期
+		deviceKey := infra.DeviceSessKey(meta.DeviceID)
+		pipe.ZAdd(ctx, deviceKey, redis.Z{Score: float64(now.UnixNano()), Member: newSID})
+		pipe.ExpireAt(ctx, deviceKey, expiresAt)
+	}
+	if meta.BrowserID != "" {
+		pipe.Set(ctx, infra.BrowserSessKey(u.ID, meta.BrowserID), newSID, 0)
+		pipe.ExpireAt(ctx, infra.BrowserSessKey(u.ID, meta.BrowserID), expiresAt)
+	}
 	if _, err := pipe.Exec(ctx); err != nil {
-		return db.User{}, "", time.Time{}, err
+		// Redis 寫入失敗：把剛建立的 DB 紀錄標記為撤銷，避免歷史中出現從未生效的 active session
+		if !recordAsync {
+			_ = s.q.RevokeSession(ctx, db.RevokeSessionParams{
+				ID:        newSID,
+				RevokedBy: infra.RevokedByRedisError.NullString(),
+			})
+		}
+		return "", time.Time{}, err
 	}
-
-	// 6. 寫入 SQLite sessions 表（作為 audit）
-	if err := s.q.CreateSession(ctx, db.CreateSessionParams{
-		ID:        newSID,
-		UserID:    u.ID,
-		CreatedAt: now,
-		ExpiresAt: expiresAt,
-	}); err != nil {
-		return db.User{}, "", time.Time{}, err
+	s.metrics.IncrSessionCreated()
+
+	// 任務遺失時由 worker 的 session:reconcile 依 Redis 補寫紀錄
+	if recordAsync {
+		err := infra.EnqueueSessionRecord(ctx, s.asynqClient, infra.SessionRecordPayload{
+			SessionID: newSID,
+			UserID:    u.ID,
+			CreatedAt: now,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			infra.LogError("session record: enqueue failed: %v", err)
+		}
 	}
 
+	s.notifySessionsChanged(ctx, u.ID)
+
 	// 建立 Asynq 任務：session:expire 與 login:audit
-	_ = infra.EnqueueSessionExpire(ctx, s.asynqClient, newSID, u.ID, expiresAt)
-	_ = infra.EnqueueLoginAudit(ctx, s.asynqClient, infra.LoginAuditPayload{
+	s.scheduleSessionExpire(ctx, u.ID, newSID, expiresAt)
+	err = infra.EnqueueLoginAudit(ctx, s.asynqClient, infra.LoginAuditPayload{
 		UserID:    &u.ID,
 		Username:  u.Username,
 		Success:   true,
-		Reason:    "ok",
+		Reason:    LoginReasonOK,
 		IP:        meta.IP,
 		UserAgent: meta.UserAgent,
+		Country:   meta.Country,
+		CreatedAt: now,
 	})
+	if err != nil {
+		infra.LogError("login audit: enqueue failed: %v", err)
+	}
 
-	return u, newSID, expiresAt, nil
+	return newSID, expiresAt, nil
+}
+
+// enforceUserSessionLimit 在建立新 session 前，若已達 MaxSessionsPerUser 則踢掉一個 session：
+// 優先踢最舊的未 pin session（EvictionOrder 為 last_seen 時改為最久沒有活動的），全部已 pin 時依 PinnedLimitPolicy 踢最舊的或回傳 ErrSessionLimitReached。
+func (s *SessionService) enforceUserSessionLimit(ctx context.Context, userID int64) error {
+	sids, err := s.rdb.ZRange(ctx, infra.UserSessKey(userID), 0, -1).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if len(sids) < s.cfg.MaxSessionsPerUser {
+		return nil
+	}
+	if sids, err = s.sortByActivity(ctx, sids); err != nil {
+		return err
+	}
+
+	pinned, err := s.pinnedFlags(ctx, sids)
+	if err != nil {
+		return err
+	}
+	ordered, err := s.evictionOrder(sids, pinned, len(sids)-s.cfg.MaxSessionsPerUser+1)
+	if err != nil {
+		return err
+	}
+	s.evictForLimit(ctx, userID, ordered[0])
+	return nil
+}
+
+// evictForLimit 因超過同時登入上限踢掉指定 session，並留下踢除原因供被踢的裝置查詢。
+func (s *SessionService) evictForLimit(ctx context.Context, userID int64, oldSID string) {
+	// 刪除 Redis 裡舊的 session 資料
+	pipe := s.rdb.TxPipeline()
+	pipe.Del(ctx, infra.SessKey(oldSID))
+	pipe.ZRem(ctx, infra.UserSessKey(userID), oldSID)
+	if s.cfg.EvictReasonTTL > 0 {
+		// 留下踢除原因，讓被踢的裝置收到 401 時知道是因為在別處登入
+		pipe.Set(ctx, infra.EvictReasonKey(oldSID), EvictReasonMaxSessions, s.cfg.EvictReasonTTL)
+	}
+	_, _ = pipe.Exec(ctx)
+	s.notifySessionsChanged(ctx, userID)
+
+	// 資料庫裡的 session 記錄：標記 revoked_at / revoked_by
+	s.recordRevocation(ctx, oldSID, infra.RevokedBySessionLimit)
+	s.metrics.IncrSessionRevoked(string(infra.RevokedBySessionLimit))
 }
 
 // Logout 刪除 Redis 內的 session，並更新 SQLite sessions 表。
@@ -200,50 +422,140 @@ func (s *SessionService) Logout(ctx context.Context, userID int64, sessionID str
 	if _, err := pipe.Exec(ctx); err != nil {
 		return err
 	}
+	// session 已刪除後 reference token 也會因 IsSessionValid 失敗而被拒；這裡再直接刪掉 token，失敗只記錄
+	if s.OpaqueTokensEnabled() {
+		if err := s.revokeOpaqueTokens(ctx, sessionID); err != nil {
+			infra.LogError("logout: revoke opaque tokens failed: %v", err)
+		}
+	}
+	s.notifySessionsChanged(ctx, userID)
 
 	// 更新資料庫中的 session 狀態（若存在）
-	_ = s.q.RevokeSession(ctx, db.RevokeSessionParams{
-		ID:        sessionID,
-		RevokedBy: sql.NullString{String: "user", Valid: true},
-	})
+	s.recordRevocation(ctx, sessionID, infra.RevokedByUser)
+	s.metrics.IncrLogout()
+	s.metrics.IncrSessionRevoked(string(infra.RevokedByUser))
 
 	return nil
 }
 
-// ListActiveSessions 列出某 user 的活躍 sessions（從 Redis 讀取）。
+// ActiveSessionInfo 是 ListActiveSessions 回傳的單一 session 摘要。
 type ActiveSessionInfo struct {
 	SessionID string `json:"session_id"`
 	IP        string `json:"ip,omitempty"`
 	UserAgent string `json:"user_agent,omitempty"`
+	DeviceID  string `json:"device_id,omitempty"`
+	BrowserID string `json:"browser_id,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	LastSeen  int64  `json:"last_seen"`
+	Pinned    bool   `json:"pinned,omitempty"`
+
+	RequestCount int64 `json:"request_count"` // 未啟用 RequestCountInterval 時固定為 0
 }
 
-func (s *SessionService) ListActiveSessions(ctx context.Context, userID int64) ([]ActiveSessionInfo, error) {
+// ListActiveSessions 的排序欄位與方向。
+const (
+	SessionSortCreatedAt = "created_at"
+	SessionSortLastSeen  = "last_seen"
+
+	SortOrderAsc  = "asc"
+	SortOrderDesc = "desc"
+)
+
+// ErrInvalidListOptions 表示 ListSessionsOptions 的 sort/order 不是支援的值。
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+// ListSessionsOptions 控制 ListActiveSessions 的排序與過濾；零值代表依 created_at 由舊到新、不過濾。
+type ListSessionsOptions struct {
+	Sort   string // created_at（預設）或 last_seen
+	Order  string // asc（預設）或 desc
+	IP     string // 只保留 IP 完全相同的 session
+	Device string // 只保留 user agent 包含此字串的 session（不分大小寫）
+}
+
+// ListActiveSessions 列出某 user 的活躍 sessions（從 Redis 讀取），hash 以 pipeline 一次讀回後再排序與過濾。
+func (s *SessionService) ListActiveSessions(ctx context.Context, userID int64, opts ListSessionsOptions) ([]ActiveSessionInfo, error) {
+	switch opts.Sort {
+	case "", SessionSortCreatedAt, SessionSortLastSeen:
+	default:
+		return nil, ErrInvalidListOptions
+	}
+	switch opts.Order {
+	case "", SortOrderAsc, SortOrderDesc:
+	default:
+		return nil, ErrInvalidListOptions
+	}
+
 	key := infra.UserSessKey(userID)
 	sessionIDs, err := s.rdb.ZRange(ctx, key, 0, -1).Result()
 	if err != nil && err != redis.Nil {
 		return nil, err
 	}
 
-	var result []ActiveSessionInfo
-	for _, sid := range sessionIDs {
-		data, err := s.rdb.HGetAll(ctx, infra.SessKey(sid)).Result()
-		if err != nil && err != redis.Nil {
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(sessionIDs))
+	for i, sid := range sessionIDs {
+		cmds[i] = pipe.HGetAll(ctx, infra.SessKey(sid))
+	}
+	if len(cmds) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
 			return nil, err
 		}
+	}
+
+	device := strings.ToLower(opts.Device)
+	var result []ActiveSessionInfo
+	for i, sid := range sessionIDs {
+		data := cmds[i].Val()
 		if len(data) == 0 {
 			continue
 		}
+		if opts.IP != "" && data["ip"] != opts.IP {
+			continue
+		}
+		if device != "" && !strings.Contains(strings.ToLower(data["user_agent"]), device) {
+			continue
+		}
+		createdAt, _ := strconv.ParseInt(data["created_at"], 10, 64)
+		lastSeen, err := strconv.ParseInt(data["last_seen"], 10, 64)
+		if err != nil {
+			// 尚未有活動紀錄的 session 以建立時間作為最後活動時間
+			lastSeen = createdAt
+		}
 		result = append(result, ActiveSessionInfo{
 			SessionID: sid,
 			IP:        data["ip"],
 			UserAgent: data["user_agent"],
+			DeviceID:  data["device_id"],
+			BrowserID: data["browser_id"],
+			CreatedAt: createdAt,
+			LastSeen:  lastSeen,
+			Pinned:    data["pinned"] == "1",
+
+			RequestCount: sessionRequestCount(data),
 		})
 	}
+
+	// zset 已依建立順序排列，排序時保持穩定以維持同時間 session 的先後
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i].CreatedAt, result[j].CreatedAt
+		if opts.Sort == SessionSortLastSeen {
+			a, b = result[i].LastSeen, result[j].LastSeen
+		}
+		if opts.Order == SortOrderDesc {
+			return a > b
+		}
+		return a < b
+	})
 	return result, nil
 }
 
 // KickSession 強制踢掉指定 session。
 func (s *SessionService) KickSession(ctx context.Context, userID int64, sessionID string) error {
+	return s.revokeSession(ctx, userID, sessionID, infra.RevokedByAdminKick)
+}
+
+// revokeSession 刪除 Redis 內的 session，並在 sessions 表記錄撤銷原因。
+func (s *SessionService) revokeSession(ctx context.Context, userID int64, sessionID string, revokedBy infra.RevokeReason) error {
 	sessKey := infra.SessKey(sessionID)
 	userSessKey := infra.UserSessKey(userID)
 
@@ -253,27 +565,188 @@ func (s *SessionService) KickSession(ctx context.Context, userID int64, sessionI
 	if _, err := pipe.Exec(ctx); err != nil {
 		return err
 	}
+	s.notifySessionsChanged(ctx, userID)
 
-	_ = s.q.RevokeSession(ctx, db.RevokeSessionParams{
+	s.recordRevocation(ctx, sessionID, revokedBy)
+	s.metrics.IncrSessionRevoked(string(revokedBy))
+	return nil
+}
+
+// recordRevocation 在 sessions 表記錄撤銷原因。Redis 的 session 已刪除，寫入 DB 失敗時不回傳錯誤，
+// 改留下 revoked_sess:{sid} tombstone：否則 FlagSessionDBFallback 開啟時 rehydrateSession 會依仍為 active 的 DB 紀錄把 session 回填。
+// tombstone 保留 maxSessionTTL，涵蓋 DB 紀錄可能的剩餘效期。
+func (s *SessionService) recordRevocation(ctx context.Context, sessionID string, revokedBy infra.RevokeReason) {
+	err := s.q.RevokeSession(ctx, db.RevokeSessionParams{
 		ID:        sessionID,
-		RevokedBy: sql.NullString{String: "admin:kick", Valid: true},
+		RevokedBy: revokedBy.NullString(),
 	})
-	return nil
+	if err == nil {
+		return
+	}
+	infra.LogError("revoke session %s: db update failed: %v", sessionID, err)
+	if err := s.rdb.Set(ctx, infra.RevokedSessKey(sessionID), string(revokedBy), s.maxSessionTTL()).Err(); err != nil {
+		infra.LogError("revoke session %s: write tombstone failed: %v", sessionID, err)
+	}
 }
 
 // KickAllSessions 踢掉該 user 所有活躍 session。
 func (s *SessionService) KickAllSessions(ctx context.Context, userID int64) error {
+	return s.revokeAllSessions(ctx, userID, infra.RevokedByAdminKick)
+}
+
+// revokeAllSessions 撤銷該 user 所有活躍 session，revokedBy 會記錄在 sessions 表，供 SessionStatus 說明登出原因。
+func (s *SessionService) revokeAllSessions(ctx context.Context, userID int64, revokedBy infra.RevokeReason) error {
 	key := infra.UserSessKey(userID)
 	sessionIDs, err := s.rdb.ZRange(ctx, key, 0, -1).Result()
 	if err != nil && err != redis.Nil {
 		return err
 	}
 	for _, sid := range sessionIDs {
-		_ = s.KickSession(ctx, userID, sid)
+		_ = s.revokeSession(ctx, userID, sid, revokedBy)
+	}
+	return nil
+}
+
+// ExtendSession 將指定 session 的到期時間往後延長 by，並同步更新 Redis TTL 與 DB。
+// 延長後的到期時間不可超過 created_at + MaxSessionLifetime。
+func (s *SessionService) ExtendSession(ctx context.Context, userID int64, sessionID string, by time.Duration) (time.Time, error) {
+	sessKey := infra.SessKey(sessionID)
+	data, err := s.rdb.HGetAll(ctx, sessKey).Result()
+	if err != nil && err != redis.Nil {
+		return time.Time{}, err
+	}
+	if len(data) == 0 || data["user_id"] != stringFromInt64(userID) {
+		return time.Time{}, ErrSessionNotFound
+	}
+
+	createdAt, err := strconv.ParseInt(data["created_at"], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	expiresUnix, err := strconv.ParseInt(data["expires_at"], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	newExpiresAt := time.Unix(expiresUnix, 0).Add(by)
+	if s.cfg.MaxSessionLifetime > 0 && newExpiresAt.After(time.Unix(createdAt, 0).Add(s.cfg.MaxSessionLifetime)) {
+		return time.Time{}, ErrLifetimeExceeded
+	}
+
+	if err := s.setSessionExpiry(ctx, userID, sessionID, newExpiresAt); err != nil {
+		return time.Time{}, err
+	}
+	return newExpiresAt, nil
+}
+
+// RefreshSession 回傳 session 目前的到期時間，供重新簽發 access token 使用。
+// ExtendSessionOnRefresh（或執行期的 extend_session_on_refresh flag）開啟時先將到期時間滑動到 now + SessionTTLFor（不超過 created_at + MaxSessionLifetime，也不會縮短）。
+// session 的 created_at 即 refresh 家族的起點，距今超過 RefreshFamilyMaxAge 時回傳 ErrRefreshFamilyExpired，不論 session 本身是否還有效。
+func (s *SessionService) RefreshSession(ctx context.Context, userID int64, sessionID string) (time.Time, error) {
+	data, err := s.rdb.HMGet(ctx, infra.SessKey(sessionID), "user_id", "created_at", "expires_at").Result()
+	if err != nil && err != redis.Nil {
+		return time.Time{}, err
+	}
+	if len(data) < 3 || data[0] != stringFromInt64(userID) {
+		return time.Time{}, ErrSessionNotFound
+	}
+	createdStr, _ := data[1].(string)
+	expiresStr, _ := data[2].(string)
+	createdAt, err := strconv.ParseInt(createdStr, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if s.cfg.RefreshFamilyMaxAge > 0 && time.Since(time.Unix(createdAt, 0)) > s.cfg.RefreshFamilyMaxAge {
+		return time.Time{}, ErrRefreshFamilyExpired
+	}
+	expiresUnix, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	expiresAt := time.Unix(expiresUnix, 0)
+	if !s.FeatureFlag(ctx, FlagExtendSessionOnRefresh) {
+		return expiresAt, nil
+	}
+
+	ttl := s.cfg.SessionTTL
+	if len(s.cfg.RoleSessionTTL) > 0 {
+		// 依使用者目前的角色決定滑動的長度，避免較短的角色 TTL 在 refresh 後被拉回 SessionTTL
+		u, err := s.q.GetUserByID(ctx, userID)
+		if err != nil {
+			return time.Time{}, err
+		}
+		ttl = s.SessionTTLFor(u)
+	}
+	newExpiresAt := time.Now().Add(ttl)
+	if s.cfg.MaxSessionLifetime > 0 {
+		if limit := time.Unix(createdAt, 0).Add(s.cfg.MaxSessionLifetime); newExpiresAt.After(limit) {
+			newExpiresAt = limit
+		}
+	}
+	if newExpiresAt.Unix() <= expiresUnix {
+		return expiresAt, nil
+	}
+	if err := s.setSessionExpiry(ctx, userID, sessionID, newExpiresAt); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(newExpiresAt.Unix(), 0), nil
+}
+
+// SessionExpiresAt 只讀 Redis session hash，回傳 session 的到期時間；session 不存在或不屬於 userID 時回傳 ErrSessionNotFound。
+func (s *SessionService) SessionExpiresAt(ctx context.Context, userID int64, sessionID string) (time.Time, error) {
+	data, err := s.rdb.HMGet(ctx, infra.SessKey(sessionID), "user_id", "expires_at").Result()
+	if err != nil && err != redis.Nil {
+		return time.Time{}, err
+	}
+	if len(data) < 2 || data[0] != stringFromInt64(userID) {
+		return time.Time{}, ErrSessionNotFound
+	}
+	expiresStr, _ := data[1].(string)
+	expiresUnix, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(expiresUnix, 0), nil
+}
+
+// setSessionExpiry 將 session 的到期時間改為 newExpiresAt，同步更新 Redis TTL、DB，必要時排入新的 session:expire 任務。
+func (s *SessionService) setSessionExpiry(ctx context.Context, userID int64, sessionID string, newExpiresAt time.Time) error {
+	sessKey := infra.SessKey(sessionID)
+	scheduled, schedErr := s.rdb.HGet(ctx, sessKey, infra.SessExpireTaskAtField).Int64()
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, sessKey, "expires_at", newExpiresAt.Unix())
+	pipe.ExpireAt(ctx, sessKey, newExpiresAt)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	if err := s.q.UpdateSessionExpiry(ctx, db.UpdateSessionExpiryParams{
+		ID:        sessionID,
+		ExpiresAt: newExpiresAt,
+	}); err != nil {
+		return err
+	}
+
+	// 到期時間往後延時不另外排任務：原本的 session:expire 仍在舊時間觸發，worker 看到 session 已延長會改排到新的到期時間，
+	// 滑動 refresh 因此不會在佇列累積一堆空轉的任務。只有到期時間提前，或舊 session 沒有 expire_task_at 紀錄時才排入。
+	if schedErr != nil || newExpiresAt.Unix() < scheduled {
+		s.scheduleSessionExpire(ctx, userID, sessionID, newExpiresAt)
 	}
 	return nil
 }
 
+// scheduleSessionExpire 在 expiresAt 排入 session:expire，成功後把排程時間記在 session hash 的 expire_task_at。
+func (s *SessionService) scheduleSessionExpire(ctx context.Context, userID int64, sessionID string, expiresAt time.Time) {
+	if s.asynqClient == nil {
+		return
+	}
+	if err := infra.EnqueueSessionExpire(ctx, s.asynqClient, sessionID, userID, expiresAt); err != nil {
+		infra.LogError("session expire: enqueue failed: %v", err)
+		return
+	}
+	_ = infra.RecordSessionExpireTask(ctx, s.rdb, sessionID, expiresAt)
+}
+
 // BanUser 封鎖 user，更新 DB 與 Redis，並踢掉所有 sessions。
 func (s *SessionService) BanUser(ctx context.Context, userID int64) error {
 	if err := s.q.BanUser(ctx, userID); err != nil {
@@ -282,7 +755,7 @@ func (s *SessionService) BanUser(ctx context.Context, userID int64) error {
 	if err := s.rdb.Set(ctx, infra.BannedUserKey(userID), "1", 0).Err(); err != nil {
 		return err
 	}
-	return s.KickAllSessions(ctx, userID)
+	return s.revokeAllSessions(ctx, userID, infra.RevokedByAdminBan)
 }
 
 // UnbanUser 解除封鎖 user。
@@ -296,24 +769,173 @@ func (s *SessionService) UnbanUser(ctx context.Context, userID int64) error {
 	return nil
 }
 
-// IsSessionValid 檢查 Redis 中該 session 是否存在且 user_id 符合。
-func (s *SessionService) IsSessionValid(ctx context.Context, userID int64, sessionID string) (bool, error) {
+// SessionInspection 是 sess:{sid} 在 Redis 中的原始內容，供 admin 除錯使用。
+type SessionInspection struct {
+	SessionID    string            `json:"session_id"`
+	Fields       map[string]string `json:"fields"`
+	TTLSeconds   int64             `json:"ttl_seconds"` // -1 代表沒有設定過期時間
+	RequestCount int64             `json:"request_count"`
+	UserBanned   bool              `json:"user_banned"`
+}
+
+// InspectSession 讀出 sess:{sid} 的完整 hash 與 TTL，並附上擁有者目前是否被 ban；key 不存在時回傳 ErrSessionNotFound。
+func (s *SessionService) InspectSession(ctx context.Context, sessionID string) (SessionInspection, error) {
 	sessKey := infra.SessKey(sessionID)
 	data, err := s.rdb.HGetAll(ctx, sessKey).Result()
 	if err != nil && err != redis.Nil {
-		return false, err
+		return SessionInspection{}, err
 	}
 	if len(data) == 0 {
+		return SessionInspection{}, ErrSessionNotFound
+	}
+
+	ttl, err := s.rdb.TTL(ctx, sessKey).Result()
+	if err != nil {
+		return SessionInspection{}, err
+	}
+	ttlSeconds := int64(-1)
+	if ttl >= 0 {
+		ttlSeconds = int64(ttl.Seconds())
+	}
+
+	result := SessionInspection{
+		SessionID:    sessionID,
+		Fields:       data,
+		TTLSeconds:   ttlSeconds,
+		RequestCount: sessionRequestCount(data),
+	}
+
+	// ban 狀態與 Login 相同，DB 與 Redis flag 任一成立即視為被 ban
+	if userID, err := strconv.ParseInt(data["user_id"], 10, 64); err == nil {
+		if u, err := s.q.GetUserByID(ctx, userID); err == nil && u.IsBanned {
+			result.UserBanned = true
+		}
+		if n, err := s.rdb.Exists(ctx, infra.BannedUserKey(userID)).Result(); err == nil && n > 0 {
+			result.UserBanned = true
+		}
+	}
+	return result, nil
+}
+
+// EvictReasonMaxSessions 代表 session 因超過 MaxSessionsPerUser 被踢掉。
+const EvictReasonMaxSessions = "max_sessions"
+
+// EvictReason 回傳 session 被系統踢掉的原因；沒有紀錄時回傳空字串。
+func (s *SessionService) EvictReason(ctx context.Context, sessionID string) (string, error) {
+	reason, err := s.rdb.Get(ctx, infra.EvictReasonKey(sessionID)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return reason, err
+}
+
+// IsSessionValid 檢查 session 的 epoch 未過期，且 Redis 中該 session 存在、user_id 符合。
+func (s *SessionService) IsSessionValid(ctx context.Context, userID int64, sessionID string) (bool, error) {
+	// epoch 已前進時，舊 epoch 的 session 不論 Redis / DB 是否還有資料都視為無效
+	epoch, err := s.CurrentSessionEpoch(ctx)
+	if err != nil {
+		return false, err
+	}
+	if sessionIDEpoch(sessionID) < epoch {
+		return false, nil
+	}
+
+	// 每個請求都會走到這裡，只讀回需要的欄位而不是 HGETALL 整個 hash
+	sessKey := infra.SessKey(sessionID)
+	data, found, err := s.readSessionCheckFields(ctx, sessKey)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		if s.FeatureFlag(ctx, FlagSessionDBFallback) {
+			return s.rehydrateSession(ctx, userID, sessionID)
+		}
 		return false, nil
 	}
 
 	// 簡單比對 user_id 是否一致（以字串形式比對）
-	if uidStr, ok := data["user_id"]; ok {
-		if uidStr != "" && uidStr != stringFromInt64(userID) {
+	if data.UserID != "" && data.UserID != stringFromInt64(userID) {
+		return false, nil
+	}
+
+	// Redis TTL 與 expires_at 理應一致，但 key 被改成永不過期時仍以 expires_at 為準
+	if ok, err := s.checkSessionExpiry(ctx, sessKey, data.ExpiresAt); err != nil || !ok {
+		return false, err
+	}
+
+	s.touchLastSeen(ctx, sessKey, data.LastSeen)
+	s.countRequest(ctx, sessKey)
+	return true, nil
+}
+
+// SessionMissingExpiryPolicy 的值：session hash 沒有 expires_at 時如何判斷是否有效。
+const (
+	SessionMissingExpiryTTL    = "ttl"
+	SessionMissingExpiryReject = "reject"
+)
+
+// checkSessionExpiry 以 hash 的 expires_at 判斷 session 是否已過期；expires_at 由建立 session 的節點寫入，
+// 比對時放寬 JWTLeeway，避免節點間時鐘誤差讓剛到期的 session 在不同節點上時好時壞。
+// expires_at 缺少或無法解析時（舊版程式或手動寫入的 session）回報 malformed 指標，
+// 並依 SessionMissingExpiryPolicy 改看 key 的剩餘 TTL（沒有 TTL 的 key 無法判斷何時過期，視為無效）或一律拒絕。
+func (s *SessionService) checkSessionExpiry(ctx context.Context, sessKey, expiresAt string) (bool, error) {
+	if unix, err := strconv.ParseInt(expiresAt, 10, 64); err == nil {
+		return time.Now().Before(time.Unix(unix, 0).Add(s.cfg.JWTLeeway)), nil
+	}
+
+	s.metrics.IncrMalformedSession("expires_at")
+	if s.cfg.SessionMissingExpiryPolicy == SessionMissingExpiryReject {
+		return false, nil
+	}
+	ttl, err := s.rdb.PTTL(ctx, sessKey).Result()
+	if err != nil {
+		return false, err
+	}
+	return ttl > 0, nil
+}
+
+// rehydrateSession 在 Redis 查無 session 時改查 sessions 表；
+// 仍有效（未撤銷且未過期）時回填 sess:{sid} 與 user_sess:{uid}，之後的請求直接命中 Redis。
+func (s *SessionService) rehydrateSession(ctx context.Context, userID int64, sessionID string) (bool, error) {
+	// 撤銷時 DB 寫入失敗，DB 紀錄仍為 active，但 session 已被撤銷
+	revoked, err := s.rdb.Exists(ctx, infra.RevokedSessKey(sessionID)).Result()
+	if err != nil {
+		return false, err
+	}
+	if revoked > 0 {
+		return false, nil
+	}
+
+	row, err := s.q.GetActiveSession(ctx, db.GetActiveSessionParams{
+		ID:     sessionID,
+		UserID: userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
 			return false, nil
 		}
+		return false, err
+	}
+	if !row.ExpiresAt.After(time.Now()) {
+		return false, nil
 	}
 
+	// DB 沒有記錄 IP / User-Agent，回填後這兩個欄位為空
+	sessKey := infra.SessKey(sessionID)
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, sessKey, map[string]interface{}{
+		"user_id":    row.UserID,
+		"created_at": row.CreatedAt.Unix(),
+		"expires_at": row.ExpiresAt.Unix(),
+	})
+	pipe.ExpireAt(ctx, sessKey, row.ExpiresAt)
+	pipe.ZAdd(ctx, infra.UserSessKey(userID), redis.Z{
+		Score:  float64(row.CreatedAt.UnixNano()),
+		Member: sessionID,
+	})
+	// 回填失敗不影響這次判斷，下次請求會再走一次 DB
+	_, _ = pipe.Exec(ctx)
+
 	return true, nil
 }
 
@@ -56,7 +56,7 @@ func newTestEnv(t *testing.T) *testEnv {
 		MaxSessionsPerUser: 2,         // 設定每個使用者最多同時 2 個 session
 	}
 
-	sessSvc := NewSessionService(q, rdb, cfg, nil) // 建立 SessionService，Asynq client 傳 nil 即可（測試中不排任務）
+	sessSvc := NewSessionService(q, rdb, cfg, nil, nil) // 建立 SessionService，Asynq client 傳 nil 即可（測試中不排任務）
 
 	t.Cleanup(func() {           // 註冊清理邏輯，確保測試結束時釋放資源
 		_ = sqlDB.Close()    // 關閉 SQLite 連線
@@ -83,6 +83,21 @@ func applyMigrations(t *testing.T, sqlDB *sql.DB) {
 		"../../db/migrations/002_add_sessions.up.sql",
 		"../../db/migrations/003_add_login_events.up.sql",
 		"../../db/migrations/004_add_user_ban.up.sql",
+		"../../db/migrations/005_add_user_last_login.up.sql",
+		"../../db/migrations/006_add_user_needs_rehash.up.sql",
+		"../../db/migrations/007_add_user_must_reset_password.up.sql",
+		"../../db/migrations/008_add_username_changes.up.sql",
+		"../../db/migrations/009_add_user_password_peppered.up.sql",
+		"../../db/migrations/010_add_user_deleted_at.up.sql",
+		"../../db/migrations/011_add_login_events_country.up.sql",
+		"../../db/migrations/012_add_user_password_changed_at.up.sql",
+		"../../db/migrations/013_add_sessions_revoked_by_check.up.sql",
+		"../../db/migrations/014_add_user_email.up.sql",
+		"../../db/migrations/015_add_user_last_login_ip.up.sql",
+		"../../db/migrations/016_add_revoked_by_session_gone.up.sql",
+		"../../db/migrations/017_add_user_roles.up.sql",
+		"../../db/migrations/018_normalize_usernames.up.sql",
+		"../../db/migrations/019_add_login_events_event_id.up.sql",
 	} // 注意：測試在 internal/session 目錄下執行時，需回到專案根目錄再進入 db/migrations
 
 	for _, path := range migrationFiles {                       // 逐一處理每個 migration
@@ -355,6 +370,88 @@ func TestIsSessionValid(t *testing.T) {
 	require.False(t, ok)                                 // 因不存在，應回傳 false
 }
 
+// TestIsSessionValidDBFallback 測試啟用 DB fallback 時，Redis 被清空後有效的 session 仍可通過驗證並回填 Redis。
+func TestIsSessionValidDBFallback(t *testing.T) {
+	env := newTestEnv(t)                     // 建立測試環境
+	env.cfg.SessionDBFallback = true         // 啟用 DB fallback
+
+	hashed, err := bcryptGenerate("password123")  // 產生雜湊
+	require.NoError(t, err)                       // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, sid, expiresAt, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 登入建立 session
+	require.NoError(t, err)                       // 應登入成功
+
+	env.mr.FlushAll()                             // 模擬 Redis 資料遺失
+
+	ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid) // Redis 查無資料，改查 DB
+	require.NoError(t, err)                       // 檢查不應失敗
+	require.True(t, ok)                           // DB 中仍有效，應視為有效
+
+	data, err := env.rdb.HGetAll(env.ctx, infra.SessKey(sid)).Result() // 讀取回填的 session hash
+	require.NoError(t, err)                       // 操作不應失敗
+	require.Equal(t, stringFromInt64(user.ID), data["user_id"]) // user_id 應已回填
+	require.Equal(t, stringFromInt64(expiresAt.Unix()), data["expires_at"]) // expires_at 應與 DB 一致
+
+	ttl := env.mr.TTL(infra.SessKey(sid))         // 取得回填後的 TTL
+	require.Greater(t, ttl, time.Duration(0))     // 應設定到期時間
+
+	members, err := env.rdb.ZRange(env.ctx, infra.UserSessKey(user.ID), 0, -1).Result() // 讀取 user_sess
+	require.NoError(t, err)                       // 操作不應失敗
+	require.Equal(t, []string{sid}, members)      // session 應重新加入 user_sess
+
+	ok, err = env.sessSvc.IsSessionValid(env.ctx, user.ID+1, sid) // 其他使用者的 ID
+	require.NoError(t, err)                       // 檢查不應失敗
+	require.False(t, ok)                          // user_id 不符，仍應無效
+}
+
+// TestIsSessionValidDBFallbackRejectsRevoked 測試已登出的 session 不會因 DB fallback 而復活，未啟用時也不會查 DB。
+func TestIsSessionValidDBFallbackRejectsRevoked(t *testing.T) {
+	env := newTestEnv(t)                     // 建立測試環境
+
+	hashed, err := bcryptGenerate("password123")  // 產生雜湊
+	require.NoError(t, err)                       // 確保成功
+	user := createTestUser(t, env, "bob", hashed) // 建立使用者
+
+	_, revokedSID, _, err := env.sessSvc.Login(env.ctx, "bob", "password123", LoginMeta{}) // 第一個 session
+	require.NoError(t, err)                       // 應登入成功
+	require.NoError(t, env.sessSvc.Logout(env.ctx, user.ID, revokedSID)) // 登出，DB 標記 revoked
+	_, liveSID, _, err := env.sessSvc.Login(env.ctx, "bob", "password123", LoginMeta{}) // 第二個 session
+	require.NoError(t, err)                       // 應登入成功
+
+	env.mr.FlushAll()                             // 模擬 Redis 資料遺失
+
+	ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, liveSID) // 未啟用 fallback
+	require.NoError(t, err)                       // 檢查不應失敗
+	require.False(t, ok)                          // 只看 Redis，應無效
+
+	env.cfg.SessionDBFallback = true              // 啟用 DB fallback
+	ok, err = env.sessSvc.IsSessionValid(env.ctx, user.ID, revokedSID) // 已登出的 session
+	require.NoError(t, err)                       // 檢查不應失敗
+	require.False(t, ok)                          // DB 已標記 revoked，不應復活
+}
+
+// TestLoginDBFailureLeavesNoRedisSession 測試 sessions 表寫入失敗時 Login 回傳錯誤，且 Redis 不會留下孤兒 session。
+func TestLoginDBFailureLeavesNoRedisSession(t *testing.T) {
+	env := newTestEnv(t)                     // 建立測試環境
+
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, err = env.sqlDB.ExecContext(env.ctx, `CREATE TRIGGER fail_session_insert BEFORE INSERT ON sessions
+BEGIN SELECT RAISE(ABORT, 'injected failure'); END`) // 讓 sessions 的 INSERT 一律失敗
+	require.NoError(t, err)                       // 建立 trigger 應成功
+
+	_, _, _, err = env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 嘗試登入
+	require.Error(t, err)                         // DB 失敗應回傳錯誤
+
+	require.Empty(t, env.mr.Keys())               // Redis 不應留下任何 session 相關 key
+	n, err := env.rdb.ZCard(env.ctx, infra.UserSessKey(user.ID)).Result() // 使用者的 session 清單
+	require.NoError(t, err)                       // 查詢不應失敗
+	require.Zero(t, n)                            // 應為空
+}
+
 // bcryptGenerate 封裝 bcrypt.GenerateFromPassword，方便在測試中重用，並與正式程式邏輯保持一致。
 func bcryptGenerate(password string) (string, error) {
 	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost) // 使用預設成本參數計算雜湊
@@ -365,3 +462,148 @@ func bcryptGenerate(password string) (string, error) {
 }
 
 
+
+// TestIsSessionValidMissingExpiresAt 測試 session hash 缺少 expires_at 時改以 key 的 TTL 判斷，並回報 malformed 指標。
+func TestIsSessionValidMissingExpiresAt(t *testing.T) {
+	env, rec := newMetricsTestEnv(t)                // 建立測試環境
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 登入
+	require.NoError(t, err)                                                           // 應登入成功
+	key := infra.SessKey(sid)                                                         // session hash key
+	env.mr.HDel(key, "expires_at")                                                    // 模擬舊版程式寫入的 session
+
+	ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid) // TTL 仍在
+	require.NoError(t, err)                                      // 不應出錯
+	require.True(t, ok)                                          // 以 TTL 判斷仍有效
+	require.Contains(t, rec.calls, "malformed:expires_at")       // 應回報 malformed 指標
+
+	env.mr.SetTTL(key, 0)                                       // 沒有 TTL 的 key 無法判斷何時過期
+	ok, err = env.sessSvc.IsSessionValid(env.ctx, user.ID, sid) // 再次檢查
+	require.NoError(t, err)                                     // 不應出錯
+	require.False(t, ok)                                        // 應視為無效
+
+	env.mr.SetTTL(key, time.Minute)                                 // 恢復 TTL
+	env.cfg.SessionMissingExpiryPolicy = SessionMissingExpiryReject // 改為一律拒絕
+	ok, err = env.sessSvc.IsSessionValid(env.ctx, user.ID, sid)     // 再次檢查
+	require.NoError(t, err)                                         // 不應出錯
+	require.False(t, ok)                                            // 應視為無效
+}
+
+// TestIsSessionValidExpiredTTL 測試缺少 expires_at 的 session 在 TTL 到期後失效，以及 expires_at 已過時即使 key 還在也視為無效。
+func TestIsSessionValidExpiredTTL(t *testing.T) {
+	env := newTestEnv(t)                            // 建立測試環境
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 登入
+	require.NoError(t, err)                                                           // 應登入成功
+	key := infra.SessKey(sid)                                                         // session hash key
+	env.mr.HDel(key, "expires_at")                                                    // 缺少 expires_at
+	env.mr.FastForward(2 * time.Hour)                                                 // TTL 到期
+
+	ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid) // 檢查
+	require.NoError(t, err)                                      // 不應出錯
+	require.False(t, ok)                                         // 應視為無效
+
+	_, sid, _, err = env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{})                    // 新的 session
+	require.NoError(t, err)                                                                             // 應登入成功
+	env.mr.HSet(infra.SessKey(sid), "expires_at", stringFromInt64(time.Now().Add(-time.Minute).Unix())) // expires_at 已過，但 key 仍在
+	ok, err = env.sessSvc.IsSessionValid(env.ctx, user.ID, sid)                                         // 檢查
+	require.NoError(t, err)                                                                             // 不應出錯
+	require.False(t, ok)                                                                                // 以 expires_at 為準
+}
+
+// TestIsSessionValidExpiryLeeway 測試 expires_at 剛過但仍在 JWTLeeway 內時視為有效（其他節點時鐘略快），超過 leeway 則無效。
+func TestIsSessionValidExpiryLeeway(t *testing.T) {
+	env := newTestEnv(t)                            // 建立測試環境
+	env.cfg.JWTLeeway = 5 * time.Second             // 容忍 5 秒時鐘誤差
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 登入
+	require.NoError(t, err)                                                           // 應登入成功
+	key := infra.SessKey(sid)                                                         // session hash key
+
+	env.mr.HSet(key, "expires_at", stringFromInt64(time.Now().Add(-2*time.Second).Unix())) // 2 秒前到期，仍在 leeway 內
+	ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid)                           // 檢查
+	require.NoError(t, err)                                                                // 不應出錯
+	require.True(t, ok)                                                                    // 應視為仍有效
+
+	env.mr.HSet(key, "expires_at", stringFromInt64(time.Now().Add(-10*time.Second).Unix())) // 10 秒前到期，超過 leeway
+	ok, err = env.sessSvc.IsSessionValid(env.ctx, user.ID, sid)                             // 再次檢查
+	require.NoError(t, err)                                                                 // 不應出錯
+	require.False(t, ok)                                                                    // 應視為無效
+
+	env.cfg.JWTLeeway = 0                                                                  // 不容忍誤差
+	env.mr.HSet(key, "expires_at", stringFromInt64(time.Now().Add(-2*time.Second).Unix())) // 2 秒前到期
+	ok, err = env.sessSvc.IsSessionValid(env.ctx, user.ID, sid)                            // 再次檢查
+	require.NoError(t, err)                                                                // 不應出錯
+	require.False(t, ok)                                                                   // 應視為無效
+}
+
+// TestLoginMixedCaseLegacyUsername 測試 NormalizeUsername 之前建立的大小寫混合帳號，經 migration 018 正規化後可以登入。
+func TestLoginMixedCaseLegacyUsername(t *testing.T) {
+	env := newTestEnv(t) // 建立完整測試環境
+
+	hashed, err := bcryptGenerate("password123")                                                        // 產生密碼雜湊
+	require.NoError(t, err)                                                                             // 確保加密成功
+	_, err = env.sqlDB.Exec("INSERT INTO users (username, password_hash) VALUES (' Alice', ?)", hashed) // 模擬正規化前直接寫入的舊帳號
+	require.NoError(t, err)                                                                             // 應寫入成功
+
+	data, err := os.ReadFile("../../db/migrations/018_normalize_usernames.up.sql") // 讀取正規化 migration
+	require.NoError(t, err)                                                        // 應讀取成功
+	_, err = env.sqlDB.Exec(string(data))                                          // 對舊資料套用 migration
+	require.NoError(t, err)                                                        // 應套用成功
+
+	u, _, _, err := env.sessSvc.Login(env.ctx, "Alice", "password123", LoginMeta{}) // 以原本的大小寫登入
+	require.NoError(t, err)                                                         // 應登入成功
+	require.Equal(t, "alice", u.Username)                                           // username 已正規化
+}
+
+// TestNormalizeUsernamesMigrationRejectsCollision 測試兩個帳號正規化後相同時 migration 018 直接失敗，不會默默合併或略過。
+func TestNormalizeUsernamesMigrationRejectsCollision(t *testing.T) {
+	env := newTestEnv(t) // 建立完整測試環境
+
+	_, err := env.sqlDB.Exec("INSERT INTO users (username, password_hash) VALUES ('bob', 'x'), ('Bob', 'y')") // 正規化後相同的兩個帳號
+	require.NoError(t, err)                                                                                   // 應寫入成功
+
+	data, err := os.ReadFile("../../db/migrations/018_normalize_usernames.up.sql") // 讀取正規化 migration
+	require.NoError(t, err)                                                        // 應讀取成功
+	_, err = env.sqlDB.Exec(string(data))                                          // 套用 migration
+	require.ErrorContains(t, err, "UNIQUE constraint failed")                      // 應撞上 UNIQUE 而失敗
+}
+
+// TestIsSessionValidDBFallbackRejectsRevokeWithDBError 測試撤銷時 sessions 表寫入失敗，DB fallback 也不會把已登出或被踢的 session 回填。
+func TestIsSessionValidDBFallbackRejectsRevokeWithDBError(t *testing.T) {
+	env := newTestEnv(t)             // 建立測試環境
+	env.cfg.SessionDBFallback = true // 啟用 DB fallback
+
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "carol", hashed) // 建立使用者
+
+	_, loggedOut, _, err := env.sessSvc.Login(env.ctx, "carol", "password123", LoginMeta{}) // 之後登出的 session
+	require.NoError(t, err)                                                                 // 應登入成功
+	_, kicked, _, err := env.sessSvc.Login(env.ctx, "carol", "password123", LoginMeta{})    // 之後被 admin 踢掉的 session
+	require.NoError(t, err)                                                                 // 應登入成功
+
+	_, err = env.sqlDB.Exec("CREATE TRIGGER sessions_fail_update BEFORE UPDATE ON sessions BEGIN SELECT RAISE(ABORT, 'db unavailable'); END") // 模擬撤銷時 DB 寫入失敗
+	require.NoError(t, err)                                                                                                                   // 應建立成功
+
+	require.NoError(t, env.sessSvc.Logout(env.ctx, user.ID, loggedOut))            // 登出仍成功（Redis 已刪除）
+	require.NoError(t, env.sessSvc.KickSession(env.ctx, user.ID, kicked))          // 踢除仍成功
+	require.True(t, env.mr.Exists(infra.RevokedSessKey(loggedOut)))                // 留下 tombstone
+	require.Greater(t, env.mr.TTL(infra.RevokedSessKey(kicked)), time.Duration(0)) // tombstone 會到期
+
+	for _, sid := range []string{loggedOut, kicked} { // DB 紀錄仍為 active
+		ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid)
+		require.NoError(t, err)
+		require.False(t, ok) // 不應被 DB fallback 回填
+	}
+	require.False(t, env.mr.Exists(infra.SessKey(loggedOut))) // Redis 沒有重新出現 session
+}
@@ -0,0 +1,48 @@
+package session
+
+import (
+	"context"
+
+	"sessionservice/internal/infra"
+)
+
+// notifySessionsChanged 通知正在監看此使用者 session 清單的連線；發布失敗只會讓畫面晚一點更新，不影響呼叫端。
+func (s *SessionService) notifySessionsChanged(ctx context.Context, userID int64) {
+	if err := infra.PublishSessionsChanged(ctx, s.rdb, userID); err != nil {
+		infra.LogError("sessions changed: publish failed: %v", err)
+	}
+}
+
+// WatchSessions 訂閱使用者 session 清單的變動（登入、登出、被踢、過期），每次變動送出一個訊號。
+// 訊號不帶內容，呼叫端收到後自行呼叫 ListActiveSessions；連續的變動在呼叫端來不及處理時會合併成一個。
+// ctx 結束時取消訂閱並關閉回傳的 channel。
+func (s *SessionService) WatchSessions(ctx context.Context, userID int64) (<-chan struct{}, error) {
+	sub := s.rdb.Subscribe(ctx, infra.UserSessEventsChannel(userID))
+	// 等到訂閱確認後才回傳，呼叫端之後讀到的清單不會漏掉期間的變動
+	if _, err := sub.Receive(ctx); err != nil {
+		_ = sub.Close()
+		return nil, err
+	}
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+		defer sub.Close()
+		msgs := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
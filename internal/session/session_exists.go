@@ -0,0 +1,49 @@
+package session
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+// SessionExists 以 EXISTS 檢查 sess:{sid} 是否還在 Redis，不讀取 hash 內容，也不檢查 epoch、user_id 或 DB fallback；
+// 只需知道 session 是否已被刪除時使用，完整的驗證規則請用 IsSessionValid。
+func (s *SessionService) SessionExists(ctx context.Context, sessionID string) (bool, error) {
+	n, err := s.rdb.Exists(ctx, infra.SessKey(sessionID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// sessionCheckFields 是 IsSessionValid 判斷時用到的 hash 欄位。
+type sessionCheckFields struct {
+	UserID    string
+	ExpiresAt string
+	LastSeen  string
+}
+
+// readSessionCheckFields 以同一個 pipeline 送出 EXISTS 與 HMGET，只讀回驗證需要的欄位，
+// 不像 HGETALL 會把 user_agent、device 等整個 hash 傳回來。session 不存在時 found 為 false。
+func (s *SessionService) readSessionCheckFields(ctx context.Context, sessKey string) (sessionCheckFields, bool, error) {
+	pipe := s.rdb.Pipeline()
+	exists := pipe.Exists(ctx, sessKey)
+	fields := pipe.HMGet(ctx, sessKey, "user_id", "expires_at", "last_seen")
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return sessionCheckFields{}, false, err
+	}
+	if exists.Val() == 0 {
+		return sessionCheckFields{}, false, nil
+	}
+
+	vals := fields.Val()
+	str := func(i int) string {
+		if v, ok := vals[i].(string); ok {
+			return v
+		}
+		return ""
+	}
+	return sessionCheckFields{UserID: str(0), ExpiresAt: str(1), LastSeen: str(2)}, true, nil
+}
@@ -0,0 +1,147 @@
+package session
+
+import (
+	"context" // 匯入 context，實作 go-redis hook
+	"strconv" // 匯入 strconv，寫入 expires_at
+	"sync"    // 匯入 sync，保護記錄的指令
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，設定過期時間
+
+	"github.com/alicebob/miniredis/v2"    // 匯入 miniredis，benchmark 用的 Redis
+	"github.com/redis/go-redis/v9"        // 匯入 go-redis，實作 hook
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra，讀取 session key
+)
+
+// redisCommandRecorder 是記錄送出過哪些 Redis 指令（含 pipeline 內的指令）的 go-redis hook。
+type redisCommandRecorder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (h *redisCommandRecorder) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *redisCommandRecorder) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.record(cmd) // 記錄單一指令
+		return next(ctx, cmd)
+	}
+}
+
+func (h *redisCommandRecorder) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			h.record(cmd) // 記錄 pipeline 內的每個指令
+		}
+		return next(ctx, cmds)
+	}
+}
+
+func (h *redisCommandRecorder) record(cmd redis.Cmder) {
+	h.mu.Lock()                           // 加鎖
+	defer h.mu.Unlock()                   // 結束時解鎖
+	h.names = append(h.names, cmd.Name()) // 記下指令名稱
+}
+
+// TestSessionExists 測試 SessionExists 在登入後回傳 true、登出後回傳 false。
+func TestSessionExists(t *testing.T) {
+	env := newTestEnv(t)                            // 建立測試環境
+	hashed, err := bcryptGenerate("password123")    // 產生密碼雜湊
+	require.NoError(t, err)                         // 應產生成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 建立 session
+	require.NoError(t, err)                                                           // 應登入成功
+
+	ok, err := env.sessSvc.SessionExists(env.ctx, sid) // 檢查 session
+	require.NoError(t, err)                            // 不應失敗
+	require.True(t, ok)                                // 應存在
+
+	require.NoError(t, env.sessSvc.Logout(env.ctx, user.ID, sid)) // 登出
+	ok, err = env.sessSvc.SessionExists(env.ctx, sid)             // 再檢查一次
+	require.NoError(t, err)                                       // 不應失敗
+	require.False(t, ok)                                          // 應已不存在
+}
+
+// TestIsSessionValidSkipsHGetAll 測試 IsSessionValid 改讀必要欄位後判斷結果不變，且不再送出 HGETALL。
+func TestIsSessionValidSkipsHGetAll(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		mutate func(env *testEnv, sessKey string)
+		userID func(user int64) int64
+		valid  bool
+	}{
+		{"valid", func(*testEnv, string) {}, func(u int64) int64 { return u }, true},                                   // 一般有效 session
+		{"other user", func(*testEnv, string) {}, func(u int64) int64 { return u + 1 }, false},                         // user_id 不符
+		{"deleted", func(env *testEnv, k string) { env.rdb.Del(env.ctx, k) }, func(u int64) int64 { return u }, false}, // hash 已刪除
+		{"expired", func(env *testEnv, k string) { // expires_at 已過但 key 仍在
+			env.rdb.HSet(env.ctx, k, "expires_at", strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10))
+		}, func(u int64) int64 { return u }, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t)                            // 建立測試環境
+			hashed, err := bcryptGenerate("password123")    // 產生密碼雜湊
+			require.NoError(t, err)                         // 應產生成功
+			user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+			_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{IP: "203.0.113.1", UserAgent: "test-agent"}) // 建立 session
+			require.NoError(t, err)                                                                                                     // 應登入成功
+			tc.mutate(env, infra.SessKey(sid))                                                                                          // 依情境調整 session
+
+			recorder := &redisCommandRecorder{} // 從這裡開始記錄指令
+			env.rdb.AddHook(recorder)
+
+			ok, err := env.sessSvc.IsSessionValid(env.ctx, tc.userID(user.ID), sid) // 驗證 session
+			require.NoError(t, err)                                                 // 不應失敗
+			require.Equal(t, tc.valid, ok)                                          // 判斷結果應與預期相同
+			require.NotContains(t, recorder.names, "hgetall")                       // 不應讀取整個 hash
+			require.Contains(t, recorder.names, "hmget")                            // 只讀必要欄位
+		})
+	}
+}
+
+// BenchmarkSessionCheckRead 比較 HGETALL 與 EXISTS + HMGET 讀回的資料量（bytes/op 指標為讀回的欄位內容長度）。
+func BenchmarkSessionCheckRead(b *testing.B) {
+	mr := miniredis.RunT(b)                                 // 啟動 miniredis
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()}) // 建立 Redis client
+	b.Cleanup(func() { _ = rdb.Close() })                   // 結束時關閉
+	ctx := context.Background()                             // 建立背景 context
+	sessKey := infra.SessKey("bench-session")               // 測試用 session key
+	rdb.HSet(ctx, sessKey, map[string]any{                  // 寫入與正式 session 相近的欄位
+		"user_id":    "42",
+		"expires_at": strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10),
+		"last_seen":  strconv.FormatInt(time.Now().Unix(), 10),
+		"created_at": strconv.FormatInt(time.Now().Unix(), 10),
+		"ip":         "203.0.113.1",
+		"user_agent": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36",
+		"device":     "web",
+		"device_id":  "0f8fad5b-d9cb-469f-a165-70867728950e",
+		"country":    "TW",
+	})
+
+	b.Run("hgetall", func(b *testing.B) {
+		var n int
+		for i := 0; i < b.N; i++ {
+			data, err := rdb.HGetAll(ctx, sessKey).Result() // 讀整個 hash
+			require.NoError(b, err)                         // 不應失敗
+			n = 0
+			for k, v := range data {
+				n += len(k) + len(v) // 累計讀回的長度
+			}
+		}
+		b.ReportMetric(float64(n), "bytes/op") // 回報讀回的資料量
+	})
+
+	b.Run("exists+hmget", func(b *testing.B) {
+		s := &SessionService{rdb: rdb} // 只需要 Redis client
+		var n int
+		for i := 0; i < b.N; i++ {
+			data, found, err := s.readSessionCheckFields(ctx, sessKey)      // 只讀必要欄位
+			require.NoError(b, err)                                         // 不應失敗
+			require.True(b, found)                                          // session 應存在
+			n = len(data.UserID) + len(data.ExpiresAt) + len(data.LastSeen) // 累計讀回的長度
+		}
+		b.ReportMetric(float64(n), "bytes/op") // 回報讀回的資料量
+	})
+}
@@ -0,0 +1,7 @@
+package session
+
+// SessionRecordMode 的值：登入時 sessions 表紀錄的寫入方式。
+const (
+	SessionRecordSync  = "sync"  // 寫入 Redis 前同步寫入 DB
+	SessionRecordAsync = "async" // Redis 寫入後排入 session:record，由 worker 寫入
+)
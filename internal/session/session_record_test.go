@@ -0,0 +1,68 @@
+package session
+
+import (
+	"database/sql"  // 匯入 database/sql，判斷查無資料
+	"encoding/json" // 匯入 encoding/json，解析任務 payload
+	"testing"       // 匯入 testing，提供單元測試框架
+
+	"github.com/hibiken/asynq"            // 匯入 asynq，檢查 session:record 任務
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra，取得任務型別
+)
+
+// TestSessionRecordSync 測試預設（sync）模式登入時立即寫入 sessions 表。
+func TestSessionRecordSync(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境（未設定 SessionRecordMode）
+
+	hashed, err := bcryptGenerate("password123") // 產生雜湊
+	require.NoError(t, err)                      // 確保成功
+	createTestUser(t, env, "alice", hashed)      // 建立使用者
+
+	_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 登入
+	require.NoError(t, err)                                                           // 應登入成功
+	row, err := env.q.GetSession(env.ctx, sid)                                        // 查詢 DB 紀錄
+	require.NoError(t, err)                                                           // 應已寫入
+	require.False(t, row.RevokedAt.Valid)                                             // 紀錄為 active
+}
+
+// TestSessionRecordAsync 測試 async 模式登入時不寫 DB，而是排入帶有 session 資訊的 session:record 任務。
+func TestSessionRecordAsync(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	opt := asynq.RedisClientOpt{Addr: env.mr.Addr()}                      // asynq 與 session 共用 miniredis
+	client := asynq.NewClient(opt)                                        // 建立 asynq client
+	defer client.Close()                                                  // 測試結束時關閉
+	inspector := asynq.NewInspector(opt)                                  // 建立 inspector 以檢查排入的任務
+	defer inspector.Close()                                               // 測試結束時關閉
+	env.cfg.SessionRecordMode = SessionRecordAsync                        // 改為 async 模式
+	env.sessSvc = NewSessionService(env.q, env.rdb, env.cfg, client, nil) // 改用會排任務的 SessionService
+
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, sid, exp, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 登入
+	require.NoError(t, err)                                                             // 應登入成功
+	ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, sid)                        // 檢查 Redis 中的 session
+	require.NoError(t, err)                                                             // 不應失敗
+	require.True(t, ok)                                                                 // session 立即有效
+	_, err = env.q.GetSession(env.ctx, sid)                                             // 查詢 DB 紀錄
+	require.ErrorIs(t, err, sql.ErrNoRows)                                              // 尚未寫入 DB
+
+	tasks, err := inspector.ListPendingTasks("default") // 列出待處理的任務
+	require.NoError(t, err)                             // 查詢應成功
+	var records []infra.SessionRecordPayload
+	for _, task := range tasks {
+		if task.Type != infra.TaskTypeSessionRecord {
+			continue // 只看 session:record
+		}
+		var p infra.SessionRecordPayload
+		require.NoError(t, json.Unmarshal(task.Payload, &p)) // payload 應為合法 JSON
+		records = append(records, p)
+	}
+	require.Len(t, records, 1)                                // 只排入一個
+	require.Equal(t, sid, records[0].SessionID)               // 對應剛建立的 session
+	require.Equal(t, user.ID, records[0].UserID)              // 對應登入的使用者
+	require.Equal(t, exp.Unix(), records[0].ExpiresAt.Unix()) // 到期時間與 Redis 一致
+}
@@ -0,0 +1,162 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+// SessionStatus 的 Reason：session 已失效時說明原因，client 可依此顯示在地化的訊息。
+const (
+	SessionReasonLoggedOut       = "logged_out"
+	SessionReasonExpired         = "expired"
+	SessionReasonSessionLimit    = "session_limit"
+	SessionReasonKicked          = "kicked_by_admin"
+	SessionReasonDeviceKicked    = "device_kicked_by_admin"
+	SessionReasonBanned          = "banned"
+	SessionReasonForceReset      = "password_reset_required"
+	SessionReasonPasswordChanged = "password_changed"
+	SessionReasonAccountDeleted  = "account_deleted"
+	SessionReasonUsernameChanged = "username_changed"
+	SessionReasonSuspicious      = "impossible_travel"
+	SessionReasonPurged          = "logged_out_everywhere"
+	SessionReasonSessionsReset   = "sessions_reset"
+	SessionReasonSystemError     = "system_error"
+	SessionReasonUnknown         = "unknown"
+)
+
+// sessionReasonMessages 是每個 Reason 給使用者看的說明。
+var sessionReasonMessages = map[string]string{
+	SessionReasonLoggedOut:       "You signed out of this session.",
+	SessionReasonExpired:         "Your session expired. Please sign in again.",
+	SessionReasonSessionLimit:    "You signed in on too many devices at once, so this older session was signed out.",
+	SessionReasonKicked:          "An administrator ended this session.",
+	SessionReasonDeviceKicked:    "An administrator signed out every session on this device.",
+	SessionReasonBanned:          "Your account has been suspended.",
+	SessionReasonForceReset:      "Your password must be reset before you can sign in again.",
+	SessionReasonPasswordChanged: "Your password was changed, so all sessions were signed out.",
+	SessionReasonAccountDeleted:  "Your account was deleted.",
+	SessionReasonUsernameChanged: "Your username was changed, so other sessions were signed out.",
+	SessionReasonSuspicious:      "We noticed sign-ins from locations too far apart and signed out all sessions to protect your account.",
+	SessionReasonPurged:          "All users were signed out by an administrator.",
+	SessionReasonSessionsReset:   "All sessions were reset. Please sign in again.",
+	SessionReasonSystemError:     "This session could not be started because of a system error.",
+	SessionReasonUnknown:         "This session is no longer active. Please sign in again.",
+}
+
+// revokedByReasons 將 sessions.revoked_by 對應到 Reason。
+var revokedByReasons = map[infra.RevokeReason]string{
+	infra.RevokedByUser:             SessionReasonLoggedOut,
+	infra.RevokedByPasswordReset:    SessionReasonPasswordChanged,
+	infra.RevokedByExpire:           SessionReasonExpired,
+	infra.RevokedBySessionLimit:     SessionReasonSessionLimit,
+	infra.RevokedByRedisError:       SessionReasonSystemError,
+	infra.RevokedByUsernameChange:   SessionReasonUsernameChanged,
+	infra.RevokedByImpossibleTravel: SessionReasonSuspicious,
+	infra.RevokedByAdminKick:        SessionReasonKicked,
+	infra.RevokedByAdminKickDevice:  SessionReasonDeviceKicked,
+	infra.RevokedByAdminBan:         SessionReasonBanned,
+	infra.RevokedByAdminForceReset:  SessionReasonForceReset,
+	infra.RevokedByAdminDelete:      SessionReasonAccountDeleted,
+	infra.RevokedByAdminPurge:       SessionReasonPurged,
+}
+
+// SessionStatus 是 GET /auth/session-status 的結果，只包含 session 本身的狀態，不含 IP、裝置等資料。
+type SessionStatus struct {
+	SessionID string     `json:"session_id"`
+	Active    bool       `json:"active"`
+	Reason    string     `json:"reason,omitempty"`
+	Message   string     `json:"message,omitempty"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+// SessionStatus 回傳 session 是否仍有效；已失效時依 sessions.revoked_by、Redis 的踢除原因與 ban 標記、
+// session epoch 與到期時間判斷原因。userID 是 session 的擁有者，呼叫端必須確認與查詢者相同才能回傳結果。
+// DB 與 Redis 都查不到時回傳 ErrSessionNotFound。
+func (s *SessionService) SessionStatus(ctx context.Context, sessionID string) (status SessionStatus, userID int64, err error) {
+	status = SessionStatus{SessionID: sessionID}
+
+	data, err := s.rdb.HGetAll(ctx, infra.SessKey(sessionID)).Result()
+	if err != nil && err != redis.Nil {
+		return SessionStatus{}, 0, err
+	}
+	row, err := s.q.GetSession(ctx, sessionID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return SessionStatus{}, 0, err
+	}
+	found := err == nil
+	var expiresAt time.Time
+	switch {
+	case found:
+		userID, expiresAt = row.UserID, row.ExpiresAt
+	case len(data) > 0:
+		userID, err = strconv.ParseInt(data["user_id"], 10, 64)
+		if err != nil {
+			return SessionStatus{}, 0, ErrSessionNotFound
+		}
+		if unix, err := strconv.ParseInt(data["expires_at"], 10, 64); err == nil {
+			expiresAt = time.Unix(unix, 0)
+		}
+	default:
+		return SessionStatus{}, 0, ErrSessionNotFound
+	}
+
+	epoch, err := s.CurrentSessionEpoch(ctx)
+	if err != nil {
+		return SessionStatus{}, 0, err
+	}
+	staleEpoch := sessionIDEpoch(sessionID) < epoch
+
+	if len(data) > 0 && !staleEpoch && !(found && row.RevokedAt.Valid) {
+		if ok, err := s.checkSessionExpiry(ctx, infra.SessKey(sessionID), data["expires_at"]); err != nil {
+			return SessionStatus{}, 0, err
+		} else if ok {
+			status.Active = true
+			return status, userID, nil
+		}
+	}
+
+	reason := SessionReasonUnknown
+	if found && row.RevokedAt.Valid {
+		ended := row.RevokedAt.Time
+		status.EndedAt = &ended
+		if r, ok := revokedByReasons[infra.RevokeReason(row.RevokedBy.String)]; ok {
+			reason = r
+		}
+	}
+	if reason == SessionReasonUnknown {
+		reason = s.inferEndReason(ctx, sessionID, userID, staleEpoch, expiresAt)
+		if reason == SessionReasonExpired && status.EndedAt == nil {
+			status.EndedAt = &expiresAt
+		}
+	}
+	status.Reason = reason
+	status.Message = sessionReasonMessages[reason]
+	return status, userID, nil
+}
+
+// inferEndReason 在 sessions 表沒有可辨識的 revoked_by 時（例如 DB 寫入失敗），改由 Redis 的標記推斷原因。
+func (s *SessionService) inferEndReason(ctx context.Context, sessionID string, userID int64, staleEpoch bool, expiresAt time.Time) string {
+	if reason, _ := s.EvictReason(ctx, sessionID); reason == EvictReasonMaxSessions {
+		return SessionReasonSessionLimit
+	}
+	if n, err := s.rdb.Exists(ctx, infra.BannedUserKey(userID)).Result(); err == nil && n > 0 {
+		return SessionReasonBanned
+	}
+	if u, err := s.q.GetUserByID(ctx, userID); err == nil && u.IsBanned {
+		return SessionReasonBanned
+	}
+	if staleEpoch {
+		return SessionReasonSessionsReset
+	}
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		return SessionReasonExpired
+	}
+	return SessionReasonUnknown
+}
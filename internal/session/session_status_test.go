@@ -0,0 +1,129 @@
+package session
+
+import (
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，模擬 session 到期
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/db"    // 匯入 db 套件，直接標記 revoked_by
+	"sessionservice/internal/infra" // 匯入 infra 套件，組出 Redis key 與撤銷原因
+)
+
+// TestSessionStatusReasons 測試各種登出方式都對應到正確的原因與說明。
+func TestSessionStatusReasons(t *testing.T) {
+	cases := []struct {
+		name   string                                       // 情境名稱
+		end    func(env *testEnv, userID int64, sid string) // 結束 session 的方式
+		reason string                                       // 預期的原因
+	}{
+		{"logout", func(env *testEnv, uid int64, sid string) {
+			require.NoError(t, env.sessSvc.Logout(env.ctx, uid, sid)) // 使用者自行登出
+		}, SessionReasonLoggedOut},
+		{"admin kick", func(env *testEnv, uid int64, sid string) {
+			require.NoError(t, env.sessSvc.KickSession(env.ctx, uid, sid)) // admin 踢除
+		}, SessionReasonKicked},
+		{"ban", func(env *testEnv, uid int64, _ string) {
+			require.NoError(t, env.sessSvc.BanUser(env.ctx, uid)) // admin 封鎖
+		}, SessionReasonBanned},
+		{"force reset", func(env *testEnv, uid int64, _ string) {
+			require.NoError(t, env.sessSvc.ForceResetPassword(env.ctx, uid)) // 密碼外洩強制重設
+		}, SessionReasonForceReset},
+		{"password change", func(env *testEnv, uid int64, _ string) {
+			require.NoError(t, env.sessSvc.ResetPassword(env.ctx, issueResetToken(t, env, uid), "x7#Qm9!vLp2@", LoginMeta{})) // 使用者變更密碼
+		}, SessionReasonPasswordChanged},
+		{"delete", func(env *testEnv, uid int64, _ string) {
+			require.NoError(t, env.sessSvc.DeleteUser(env.ctx, uid)) // admin 刪除帳號
+		}, SessionReasonAccountDeleted},
+		{"session limit", func(env *testEnv, _ int64, _ string) {
+			for i := 0; i < 2; i++ {
+				_, _, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 登入超過上限
+				require.NoError(t, err)                                                         // 應成功，最舊的被踢
+			}
+		}, SessionReasonSessionLimit},
+		{"epoch bump", func(env *testEnv, _ int64, _ string) {
+			_, err := env.sessSvc.BumpSessionEpoch(env.ctx) // 讓所有 session 失效
+			require.NoError(t, err)                         // 應成功
+		}, SessionReasonSessionsReset},
+		{"expired", func(env *testEnv, _ int64, _ string) {
+			env.mr.FastForward(2 * time.Hour)                         // Redis 的 session 過期
+			setSessionExpiresAt(t, env, time.Now().Add(-time.Minute)) // DB 的到期時間也已過
+		}, SessionReasonExpired},
+		{"evicted marker only", func(env *testEnv, uid int64, sid string) {
+			env.mr.Del(infra.SessKey(sid))                                                    // session 已從 Redis 移除
+			require.NoError(t, env.mr.Set(infra.EvictReasonKey(sid), EvictReasonMaxSessions)) // 只留下 Redis 的踢除原因
+		}, SessionReasonSessionLimit},
+		{"ban marker only", func(env *testEnv, uid int64, sid string) {
+			env.mr.Del(infra.SessKey(sid))                                // session 已從 Redis 移除
+			require.NoError(t, env.mr.Set(infra.BannedUserKey(uid), "1")) // 只留下 Redis 的 ban 標記
+		}, SessionReasonBanned},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t)           // 建立測試環境
+			env.cfg.MaxSessionsPerUser = 2 // 最多同時 2 個 session
+
+			hashed, err := bcryptGenerate("password123")                                      // 產生雜湊
+			require.NoError(t, err)                                                           // 確保成功
+			alice := createTestUser(t, env, "alice", hashed)                                  // 建立使用者
+			_, sid, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 登入
+			require.NoError(t, err)                                                           // 應成功
+
+			status, owner, err := env.sessSvc.SessionStatus(env.ctx, sid) // 登出前查詢
+			require.NoError(t, err)                                       // 應成功
+			require.True(t, status.Active)                                // session 仍有效
+			require.Empty(t, status.Reason)                               // 沒有失效原因
+			require.Equal(t, alice.ID, owner)                             // 回傳擁有者
+
+			tc.end(env, alice.ID, sid) // 結束 session
+
+			status, owner, err = env.sessSvc.SessionStatus(env.ctx, sid)       // 登出後查詢
+			require.NoError(t, err)                                            // 應成功
+			require.False(t, status.Active)                                    // session 已失效
+			require.Equal(t, tc.reason, status.Reason)                         // 原因正確
+			require.Equal(t, sessionReasonMessages[tc.reason], status.Message) // 附上對應的說明
+			require.Equal(t, alice.ID, owner)                                  // 擁有者不變
+		})
+	}
+}
+
+// TestSessionStatusRevokedByMapping 測試 sessions.revoked_by 的每個值都對應到有說明的原因，未知的值回傳 unknown。
+func TestSessionStatusRevokedByMapping(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	hashed, err := bcryptGenerate("password123")     // 產生雜湊
+	require.NoError(t, err)                          // 確保成功
+	alice := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	revokedBy := map[infra.RevokeReason]string{infra.RevokedByUnknown: SessionReasonUnknown} // migration 無法對應的舊值
+	for k, v := range revokedByReasons {
+		revokedBy[k] = v // 所有已知的 revoked_by
+	}
+	for by, want := range revokedBy {
+		sid, _, err := env.sessSvc.startSession(env.ctx, alice, LoginMeta{}) // 建立 session
+		require.NoError(t, err)                                              // 應成功
+		env.mr.Del(infra.SessKey(sid))                                       // 從 Redis 移除
+		require.NoError(t, env.q.RevokeSession(env.ctx, db.RevokeSessionParams{
+			ID:        sid,             // 目標 session
+			RevokedBy: by.NullString(), // 撤銷原因
+		}))
+		env.mr.Del(infra.UserSessKey(alice.ID)) // 清空 zset，避免達到同時登入上限
+
+		status, _, err := env.sessSvc.SessionStatus(env.ctx, sid) // 查詢狀態
+		require.NoError(t, err)                                   // 應成功
+		require.Equal(t, want, status.Reason, by)                 // 原因正確
+		require.NotEmpty(t, status.Message, by)                   // 每個原因都有說明
+		require.NotNil(t, status.EndedAt, by)                     // 附上撤銷時間
+	}
+
+	_, _, err = env.sessSvc.SessionStatus(env.ctx, "no-such-session") // 不存在的 session
+	require.ErrorIs(t, err, ErrSessionNotFound)                       // 應回傳 ErrSessionNotFound
+}
+
+// setSessionExpiresAt 將 sessions 表中所有 session 的到期時間改為 at。
+func setSessionExpiresAt(t *testing.T, env *testEnv, at time.Time) {
+	t.Helper()                                                                         // 標記為測試輔助函式
+	_, err := env.sqlDB.ExecContext(env.ctx, "UPDATE sessions SET expires_at = ?", at) // 直接改寫到期時間
+	require.NoError(t, err)                                                            // 應成功
+}
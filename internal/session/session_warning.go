@@ -0,0 +1,31 @@
+package session
+
+import (
+	"context"
+
+	"sessionservice/internal/infra"
+)
+
+// SessionUsage 是使用者目前的 session 數與 MaxSessionsPerUser 上限，Warning 表示已達 SessionWarnThreshold。
+type SessionUsage struct {
+	Active  int
+	Max     int
+	Warning bool
+}
+
+// UserSessionUsage 回傳使用者目前的 session 數，供登入回應在接近同時登入上限時提醒 client；只是提示，不影響任何判斷。
+// 未設定 SessionWarnThreshold 或 MaxSessionsPerUser 時回傳零值，不查詢 Redis。
+func (s *SessionService) UserSessionUsage(ctx context.Context, userID int64) (SessionUsage, error) {
+	if s.cfg.SessionWarnThreshold <= 0 || s.cfg.MaxSessionsPerUser <= 0 {
+		return SessionUsage{}, nil
+	}
+	active, err := s.rdb.ZCard(ctx, infra.UserSessKey(userID)).Result()
+	if err != nil {
+		return SessionUsage{}, err
+	}
+	return SessionUsage{
+		Active:  int(active),
+		Max:     s.cfg.MaxSessionsPerUser,
+		Warning: float64(active) >= s.cfg.SessionWarnThreshold*float64(s.cfg.MaxSessionsPerUser),
+	}, nil
+}
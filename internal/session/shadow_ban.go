@@ -0,0 +1,27 @@
+package session
+
+import (
+	"context"
+
+	"sessionservice/internal/infra"
+)
+
+// ShadowBanUser 以 shadow_banned:{uid} 標記使用者。與 BanUser 不同，不會踢掉 session，Login / IsSessionValid 也照常通過，
+// 只由 auth middleware 把 flag 放進 context，讓下游 handler 自行決定要靜默忽略或標記其操作，使用者不易察覺。
+func (s *SessionService) ShadowBanUser(ctx context.Context, userID int64) error {
+	return s.rdb.Set(ctx, infra.ShadowBannedKey(userID), "1", 0).Err()
+}
+
+// UnshadowBanUser 移除使用者的 shadow ban flag。
+func (s *SessionService) UnshadowBanUser(ctx context.Context, userID int64) error {
+	return s.rdb.Del(ctx, infra.ShadowBannedKey(userID)).Err()
+}
+
+// IsShadowBanned 回傳使用者是否被 shadow ban。
+func (s *SessionService) IsShadowBanned(ctx context.Context, userID int64) (bool, error) {
+	n, err := s.rdb.Exists(ctx, infra.ShadowBannedKey(userID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
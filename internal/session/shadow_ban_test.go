@@ -0,0 +1,38 @@
+package session
+
+import (
+	"testing" // 匯入 testing，提供單元測試框架
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// TestShadowBanUser 測試 shadow ban 只設定 flag，既有 session 與新的登入都不受影響，解除後 flag 消失。
+func TestShadowBanUser(t *testing.T) {
+	env := newTestEnv(t)                            // 建立測試環境
+	hashed, err := bcryptGenerate("password123")    // 產生密碼雜湊
+	require.NoError(t, err)                         // 應產生成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, before, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // shadow ban 前的 session
+	require.NoError(t, err)                                                              // 應登入成功
+
+	require.NoError(t, env.sessSvc.ShadowBanUser(env.ctx, user.ID)) // shadow ban
+	banned, err := env.sessSvc.IsShadowBanned(env.ctx, user.ID)     // 查詢 flag
+	require.NoError(t, err)                                         // 不應失敗
+	require.True(t, banned)                                         // 應已標記
+
+	ok, err := env.sessSvc.IsSessionValid(env.ctx, user.ID, before) // 既有 session
+	require.NoError(t, err)                                         // 不應失敗
+	require.True(t, ok)                                             // 仍然有效
+
+	_, after, _, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // shadow ban 後登入
+	require.NoError(t, err)                                                             // 不應被擋下
+	ok, err = env.sessSvc.IsSessionValid(env.ctx, user.ID, after)                       // 新 session
+	require.NoError(t, err)                                                             // 不應失敗
+	require.True(t, ok)                                                                 // 同樣有效
+
+	require.NoError(t, env.sessSvc.UnshadowBanUser(env.ctx, user.ID)) // 解除 shadow ban
+	banned, err = env.sessSvc.IsShadowBanned(env.ctx, user.ID)        // 再次查詢
+	require.NoError(t, err)                                           // 不應失敗
+	require.False(t, banned)                                          // flag 應已移除
+}
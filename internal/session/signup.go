@@ -0,0 +1,43 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"sessionservice/internal/infra"
+)
+
+// ErrSignupCooldown 表示該 IP 仍在註冊冷卻期間內。
+var ErrSignupCooldown = errors.New("signup cooldown active")
+
+// ClaimSignupSlot 以 SETNX 佔用該 IP 的註冊名額，冷卻期間內再次呼叫會回傳 ErrSignupCooldown 與剩餘時間。
+// SignupCooldown 未設定時永遠成功。註冊最後失敗的話，呼叫端應以 ReleaseSignupSlot 歸還名額。
+func (s *SessionService) ClaimSignupSlot(ctx context.Context, ip string) (time.Duration, error) {
+	if s.cfg.SignupCooldown <= 0 {
+		return 0, nil
+	}
+
+	key := infra.SignupCooldownKey(ip)
+	ok, err := s.rdb.SetNX(ctx, key, "1", s.cfg.SignupCooldown).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		return 0, nil
+	}
+
+	ttl, err := s.rdb.PTTL(ctx, key).Result()
+	if err != nil || ttl <= 0 {
+		ttl = s.cfg.SignupCooldown
+	}
+	return ttl, ErrSignupCooldown
+}
+
+// ReleaseSignupSlot 歸還 ClaimSignupSlot 佔用的名額，讓未成功的註冊不會啟動冷卻。
+func (s *SessionService) ReleaseSignupSlot(ctx context.Context, ip string) {
+	if s.cfg.SignupCooldown <= 0 {
+		return
+	}
+	_ = s.rdb.Del(ctx, infra.SignupCooldownKey(ip)).Err()
+}
@@ -0,0 +1,50 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"sessionservice/internal/db"
+)
+
+// ErrSignupHookFailed 表示 SignupHook 回傳錯誤，使用者已 rollback 不會被建立。
+var ErrSignupHookFailed = errors.New("signup hook failed")
+
+// SignupHook 在註冊時、users 寫入之後但 transaction commit 之前呼叫，讓部署在外部系統（計費、CRM）建立對應的資料。
+// 回傳錯誤時整個註冊 rollback；hook 應自行控制逾時，ctx 取消時也應盡快返回。
+type SignupHook func(ctx context.Context, user db.User) error
+
+// NopSignupHook 是預設的 SignupHook，不做任何事。
+func NopSignupHook(context.Context, db.User) error {
+	return nil
+}
+
+// WithSignupHook 設定註冊時呼叫的 SignupHook；hook 為 nil 時改回 NopSignupHook。
+func (s *SessionService) WithSignupHook(hook SignupHook) *SessionService {
+	if hook == nil {
+		hook = NopSignupHook
+	}
+	s.signupHook = hook
+	return s
+}
+
+// CreateUser 在 transaction 中寫入 users 並呼叫 SignupHook，hook 失敗時 rollback 並回傳包住原因的 ErrSignupHookFailed。
+func (s *SessionService) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+	var user db.User
+	err := s.q.ExecTx(ctx, func(q *db.Queries) error {
+		u, err := q.CreateUser(ctx, arg)
+		if err != nil {
+			return err
+		}
+		if err := s.signupHook(ctx, u); err != nil {
+			return fmt.Errorf("%w: %w", ErrSignupHookFailed, err)
+		}
+		user = u
+		return nil
+	})
+	if err != nil {
+		return db.User{}, err
+	}
+	return user, nil
+}
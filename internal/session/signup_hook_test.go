@@ -0,0 +1,43 @@
+package session
+
+import (
+	"context"      // 匯入 context，實作測試用 hook
+	"database/sql" // 匯入 database/sql，比對查無資料的錯誤
+	"errors"       // 匯入 errors，建立 hook 回傳的錯誤
+	"testing"      // 匯入 testing，提供單元測試框架
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/db" // 匯入 db，建立使用者參數
+)
+
+// TestSignupHook 測試 hook 成功時使用者建立並可在 hook 內看到 user ID，hook 失敗時 rollback、username 之後仍可註冊。
+func TestSignupHook(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	var seen db.User
+	env.sessSvc.WithSignupHook(func(_ context.Context, u db.User) error {
+		seen = u // 記錄 hook 收到的使用者
+		return nil
+	})
+	user, err := env.sessSvc.CreateUser(env.ctx, db.CreateUserParams{Username: "alice", PasswordHash: "x"}) // hook 成功
+	require.NoError(t, err)                                                                                 // 應建立成功
+	require.NotZero(t, user.ID)                                                                             // 已取得 user ID
+	require.Equal(t, user.ID, seen.ID)                                                                      // hook 收到同一個使用者
+	_, err = env.q.GetUserByUsername(env.ctx, "alice")                                                      // 查詢使用者
+	require.NoError(t, err)                                                                                 // 已寫入 DB
+
+	provisionErr := errors.New("crm unavailable") // 外部系統失敗
+	env.sessSvc.WithSignupHook(func(context.Context, db.User) error {
+		return provisionErr // hook 回傳錯誤
+	})
+	_, err = env.sessSvc.CreateUser(env.ctx, db.CreateUserParams{Username: "bob", PasswordHash: "x"}) // hook 失敗
+	require.ErrorIs(t, err, ErrSignupHookFailed)                                                      // 回傳 ErrSignupHookFailed
+	require.ErrorIs(t, err, provisionErr)                                                             // 並保留原因
+	_, err = env.q.GetUserByUsername(env.ctx, "bob")                                                  // 查詢使用者
+	require.ErrorIs(t, err, sql.ErrNoRows)                                                            // 已 rollback
+
+	env.sessSvc.WithSignupHook(nil)                                                                   // 改回預設的 no-op hook
+	_, err = env.sessSvc.CreateUser(env.ctx, db.CreateUserParams{Username: "bob", PasswordHash: "x"}) // 再次註冊 bob
+	require.NoError(t, err)                                                                           // rollback 後 username 未被佔用
+}
@@ -0,0 +1,41 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// TokenExpiryPolicy 的值：要簽發的 JWT exp 超過 session 的 expires_at 時如何處理。
+const (
+	TokenExpiryClamp  = "clamp"
+	TokenExpiryReject = "reject"
+)
+
+// ErrTokenOutlivesSession 表示要簽發的 token 會比 session 活得更久（TokenExpiryPolicy 為 reject），通常代表呼叫端有 bug。
+var ErrTokenOutlivesSession = errors.New("token expiry exceeds session expiry")
+
+// SessionTokenExpiry 以 Redis 中 session 的 expires_at 檢查要簽發的 token exp：
+// 未超過時原樣回傳；超過時依 TokenExpiryPolicy 縮短到 session 的到期時間（clamp，預設）或回傳 ErrTokenOutlivesSession（reject）。
+// session 不存在或不屬於 userID 時回傳 ErrSessionNotFound；hash 缺少 expires_at 時無從比對，原樣回傳 exp。
+func (s *SessionService) SessionTokenExpiry(ctx context.Context, userID int64, sessionID string, exp time.Time) (time.Time, error) {
+	sessionExp, err := s.SessionExpiresAt(ctx, userID, sessionID)
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return exp, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	// expires_at 與 JWT 的 exp 都只到秒，同一秒內不算超過
+	if exp.Unix() <= sessionExp.Unix() {
+		return exp, nil
+	}
+	if s.cfg.TokenExpiryPolicy == TokenExpiryReject {
+		return time.Time{}, fmt.Errorf("%w: token exp %s, session expires_at %s",
+			ErrTokenOutlivesSession, exp.UTC().Format(time.RFC3339), sessionExp.UTC().Format(time.RFC3339))
+	}
+	return sessionExp, nil
+}
@@ -0,0 +1,56 @@
+package session
+
+import (
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，計算 token 與 session 的到期時間
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+)
+
+// TestSessionTokenExpiryClampsToSession 測試 token exp 超過 session 到期時間時預設縮短，未超過時原樣保留。
+func TestSessionTokenExpiryClampsToSession(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境（SessionTTL = 1h）
+
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, sid, sessionExp, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 建立 session
+	require.NoError(t, err)                                                                    // 應登入成功
+
+	exp, err := env.sessSvc.SessionTokenExpiry(env.ctx, user.ID, sid, sessionExp) // 與 session 同時到期
+	require.NoError(t, err)                                                       // 不應失敗
+	require.True(t, exp.Equal(sessionExp))                                        // 原樣保留
+
+	early := sessionExp.Add(-10 * time.Minute)                              // 比 session 早到期
+	exp, err = env.sessSvc.SessionTokenExpiry(env.ctx, user.ID, sid, early) // 檢查較短的 exp
+	require.NoError(t, err)                                                 // 不應失敗
+	require.True(t, exp.Equal(early))                                       // 原樣保留
+
+	exp, err = env.sessSvc.SessionTokenExpiry(env.ctx, user.ID, sid, sessionExp.Add(24*time.Hour)) // 呼叫端誤傳過長的 exp
+	require.NoError(t, err)                                                                        // clamp 模式不回傳錯誤
+	require.Equal(t, sessionExp.Unix(), exp.Unix())                                                // 縮短到 session 的到期時間
+
+	_, err = env.sessSvc.SessionTokenExpiry(env.ctx, user.ID+1, sid, sessionExp) // 別人的 session
+	require.ErrorIs(t, err, ErrSessionNotFound)                                  // 應視為不存在
+}
+
+// TestSessionTokenExpiryRejects 測試 TokenExpiryPolicy 為 reject 時，exp 超過 session 到期時間會被拒絕。
+func TestSessionTokenExpiryRejects(t *testing.T) {
+	env := newTestEnv(t)                          // 建立測試環境
+	env.cfg.TokenExpiryPolicy = TokenExpiryReject // 改為拒絕簽發
+
+	hashed, err := bcryptGenerate("password123")    // 產生雜湊
+	require.NoError(t, err)                         // 確保成功
+	user := createTestUser(t, env, "alice", hashed) // 建立使用者
+
+	_, sid, sessionExp, err := env.sessSvc.Login(env.ctx, "alice", "password123", LoginMeta{}) // 建立 session
+	require.NoError(t, err)                                                                    // 應登入成功
+
+	_, err = env.sessSvc.SessionTokenExpiry(env.ctx, user.ID, sid, sessionExp.Add(time.Second)) // 只多一秒也不行
+	require.ErrorIs(t, err, ErrTokenOutlivesSession)                                            // 應回傳錯誤
+
+	exp, err := env.sessSvc.SessionTokenExpiry(env.ctx, user.ID, sid, sessionExp) // 與 session 同時到期
+	require.NoError(t, err)                                                       // 應允許
+	require.True(t, exp.Equal(sessionExp))                                        // 原樣保留
+}
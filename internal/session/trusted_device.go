@@ -0,0 +1,70 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"sessionservice/internal/infra"
+)
+
+// ErrTrustedDeviceDisabled 表示 TrustedDeviceTTL 為 0，不允許記住裝置。
+var ErrTrustedDeviceDisabled = errors.New("trusted devices are disabled")
+
+// TrustDevice 在 MFA 驗證成功且使用者勾選「記住此裝置」後呼叫，回傳交給 client 保存（cookie 或本機儲存）的 trusted-device token。
+// token 只在回傳時出現一次，Redis 只保存其 SHA-256，期間由 TrustedDeviceTTL 決定。
+func (s *SessionService) TrustDevice(ctx context.Context, userID int64) (string, time.Time, error) {
+	if s.cfg.TrustedDeviceTTL <= 0 {
+		return "", time.Time{}, ErrTrustedDeviceDisabled
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	now := time.Now()
+	key := infra.TrustedDeviceKey(userID, trustedDeviceHash(token))
+	if err := s.rdb.Set(ctx, key, strconv.FormatInt(now.Unix(), 10), s.cfg.TrustedDeviceTTL).Err(); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, now.Add(s.cfg.TrustedDeviceTTL), nil
+}
+
+// IsDeviceTrusted 回傳 token 是否為 userID 仍在期間內的 trusted device；登入流程據此略過 MFA。
+// token 綁定在簽發時的使用者，換帳號使用一律不成立；Redis 錯誤時視為不信任，回到要求 MFA。
+func (s *SessionService) IsDeviceTrusted(ctx context.Context, userID int64, token string) bool {
+	if s.cfg.TrustedDeviceTTL <= 0 || token == "" {
+		return false
+	}
+	n, err := s.rdb.Exists(ctx, infra.TrustedDeviceKey(userID, trustedDeviceHash(token))).Result()
+	return err == nil && n > 0
+}
+
+// RevokeTrustedDevices 撤銷使用者所有記住的裝置，回傳撤銷的數量；變更或強制重設密碼時呼叫。
+func (s *SessionService) RevokeTrustedDevices(ctx context.Context, userID int64) (int, error) {
+	pattern := infra.TrustedDeviceKey(userID, "*")
+	revoked := 0
+	iter := s.rdb.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		n, err := s.rdb.Del(ctx, iter.Val()).Result()
+		if err != nil {
+			return revoked, fmt.Errorf("revoke trusted device: %w", err)
+		}
+		revoked += int(n)
+	}
+	return revoked, iter.Err()
+}
+
+// trustedDeviceHash 是 trusted_device key 中的 deviceHash，Redis 外洩時也無法還原出可用的 token。
+func trustedDeviceHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
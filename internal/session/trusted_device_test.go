@@ -0,0 +1,69 @@
+package session
+
+import (
+	"testing" // 匯入 testing，提供單元測試框架
+	"time"    // 匯入 time，設定記住裝置的期間
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra 套件，組出 trusted_device key
+)
+
+// TestTrustedDeviceSkipsWithinWindow 測試記住的裝置在期間內成立、換帳號或過期後不成立。
+func TestTrustedDeviceSkipsWithinWindow(t *testing.T) {
+	env := newTestEnv(t)                           // 建立測試環境
+	env.cfg.TrustedDeviceTTL = 30 * 24 * time.Hour // 記住裝置 30 天
+
+	hashed, err := bcryptGenerate("password123")     // 產生雜湊
+	require.NoError(t, err)                          // 確保成功
+	alice := createTestUser(t, env, "alice", hashed) // 建立使用者
+	bob := createTestUser(t, env, "bob", hashed)     // 另一個使用者
+
+	require.False(t, env.sessSvc.IsDeviceTrusted(env.ctx, alice.ID, "")) // 沒有 token 一律要求 MFA
+
+	token, until, err := env.sessSvc.TrustDevice(env.ctx, alice.ID)                // MFA 成功後記住裝置
+	require.NoError(t, err)                                                        // 應成功
+	require.NotEmpty(t, token)                                                     // 回傳 token
+	require.WithinDuration(t, time.Now().Add(30*24*time.Hour), until, time.Minute) // 到期時間為 30 天後
+
+	require.True(t, env.sessSvc.IsDeviceTrusted(env.ctx, alice.ID, token))                     // 同一裝置可略過 MFA
+	require.False(t, env.sessSvc.IsDeviceTrusted(env.ctx, bob.ID, token))                      // 其他帳號使用同一 token 不成立
+	require.False(t, env.sessSvc.IsDeviceTrusted(env.ctx, alice.ID, token+"x"))                // 竄改過的 token 不成立
+	require.True(t, env.mr.Exists(infra.TrustedDeviceKey(alice.ID, trustedDeviceHash(token)))) // Redis 只保存 token 的雜湊
+
+	env.mr.FastForward(31 * 24 * time.Hour)                                 // 超過記住期間
+	require.False(t, env.sessSvc.IsDeviceTrusted(env.ctx, alice.ID, token)) // 過期後需要重新 MFA
+
+	env.cfg.TrustedDeviceTTL = 0                           // 停用 trusted device
+	_, _, err = env.sessSvc.TrustDevice(env.ctx, alice.ID) // 嘗試記住裝置
+	require.ErrorIs(t, err, ErrTrustedDeviceDisabled)      // 應回傳已停用
+}
+
+// TestResetPasswordRevokesTrustedDevices 測試變更與強制重設密碼都會撤銷所有記住的裝置。
+func TestResetPasswordRevokesTrustedDevices(t *testing.T) {
+	env := newTestEnv(t)                           // 建立測試環境
+	env.cfg.TrustedDeviceTTL = 30 * 24 * time.Hour // 記住裝置 30 天
+
+	hashed, err := bcryptGenerate("password123")     // 產生雜湊
+	require.NoError(t, err)                          // 確保成功
+	alice := createTestUser(t, env, "alice", hashed) // 建立使用者
+	bob := createTestUser(t, env, "bob", hashed)     // 另一個使用者
+
+	laptop, _, err := env.sessSvc.TrustDevice(env.ctx, alice.ID) // 記住第一台裝置
+	require.NoError(t, err)                                      // 應成功
+	phone, _, err := env.sessSvc.TrustDevice(env.ctx, alice.ID)  // 記住第二台裝置
+	require.NoError(t, err)                                      // 應成功
+	other, _, err := env.sessSvc.TrustDevice(env.ctx, bob.ID)    // 其他使用者的裝置
+	require.NoError(t, err)                                      // 應成功
+
+	require.NoError(t, env.sessSvc.ResetPassword(env.ctx, issueResetToken(t, env, alice.ID), "x7#Qm9!vLp2@", LoginMeta{})) // 變更密碼
+
+	require.False(t, env.sessSvc.IsDeviceTrusted(env.ctx, alice.ID, laptop)) // 第一台裝置已撤銷
+	require.False(t, env.sessSvc.IsDeviceTrusted(env.ctx, alice.ID, phone))  // 第二台裝置已撤銷
+	require.True(t, env.sessSvc.IsDeviceTrusted(env.ctx, bob.ID, other))     // 其他使用者不受影響
+
+	tablet, _, err := env.sessSvc.TrustDevice(env.ctx, alice.ID)             // 重新記住裝置
+	require.NoError(t, err)                                                  // 應成功
+	require.NoError(t, env.sessSvc.ForceResetPassword(env.ctx, alice.ID))    // 強制重設密碼
+	require.False(t, env.sessSvc.IsDeviceTrusted(env.ctx, alice.ID, tablet)) // 同樣撤銷
+}
@@ -0,0 +1,110 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"sessionservice/internal/db"
+	"sessionservice/internal/infra"
+)
+
+// MaxUsernameLength 是正規化後 username 允許的最大字元數。
+const MaxUsernameLength = 64
+
+var (
+	ErrInvalidUsername  = errors.New("invalid username")
+	ErrUsernameTaken    = errors.New("username already taken")
+	ErrUsernameReserved = errors.New("username is reserved")
+)
+
+// NormalizeUsername 統一 username 的比較形式：去除前後空白並轉成小寫。
+// signup、login 與 username 可用性查詢都必須走同一套規則，避免 "Alice" 與 "alice" 被視為不同帳號。
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// ValidateUsername 檢查已正規化的 username：不可為空、不可超過 MaxUsernameLength，且不含空白或控制字元。
+func ValidateUsername(username string) error {
+	if username == "" || utf8.RuneCountInString(username) > MaxUsernameLength {
+		return ErrInvalidUsername
+	}
+	for _, r := range username {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return ErrInvalidUsername
+		}
+	}
+	return nil
+}
+
+// IsUsernameReserved 判斷已正規化的 username 是否在 ReservedUsernames 中；設定值同樣先正規化，"Admin" 也會擋下 "admin"。
+func (s *SessionService) IsUsernameReserved(username string) bool {
+	for _, reserved := range s.cfg.ReservedUsernames {
+		if NormalizeUsername(reserved) == username {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeUsername 在同一個 transaction 中更新使用者的 username 並寫入 username_changes 稽核紀錄。
+// 舊 username 可能已被帶進其他 session 的 token claim，因此除了 currentSessionID 以外的 session 一律撤銷。
+func (s *SessionService) ChangeUsername(ctx context.Context, userID int64, currentSessionID, newUsername string) (db.User, error) {
+	newUsername = NormalizeUsername(newUsername)
+	if err := ValidateUsername(newUsername); err != nil {
+		return db.User{}, err
+	}
+	if s.IsUsernameReserved(newUsername) {
+		return db.User{}, ErrUsernameReserved
+	}
+
+	u, err := s.q.GetUserByID(ctx, userID)
+	if err != nil {
+		return db.User{}, err
+	}
+	if u.Username == newUsername {
+		return u, nil
+	}
+
+	// 改名與稽核紀錄在同一個 transaction 內寫入，避免改名成功卻沒有 username_changes 紀錄
+	err = s.q.ExecTx(ctx, func(q *db.Queries) error {
+		if err := q.UpdateUsername(ctx, db.UpdateUsernameParams{
+			ID:       userID,
+			Username: newUsername,
+		}); err != nil {
+			if isUniqueViolation(err) {
+				return ErrUsernameTaken
+			}
+			return err
+		}
+		return q.CreateUsernameChange(ctx, db.CreateUsernameChangeParams{
+			UserID:      userID,
+			OldUsername: u.Username,
+			NewUsername: newUsername,
+		})
+	})
+	if err != nil {
+		return db.User{}, err
+	}
+
+	sessionIDs, err := s.rdb.ZRange(ctx, infra.UserSessKey(userID), 0, -1).Result()
+	if err != nil {
+		return db.User{}, err
+	}
+	for _, sid := range sessionIDs {
+		if sid == currentSessionID {
+			continue
+		}
+		_ = s.revokeSession(ctx, userID, sid, infra.RevokedByUsernameChange)
+	}
+
+	u.Username = newUsername
+	return u, nil
+}
+
+// isUniqueViolation 判斷是否為 SQLite 的 UNIQUE constraint 錯誤。
+func isUniqueViolation(err error) bool {
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
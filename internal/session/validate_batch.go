@@ -0,0 +1,62 @@
+package session
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+// SessionRef 是批次驗證的一筆 session：token 內的 user ID 與 session ID。
+type SessionRef struct {
+	UserID    int64
+	SessionID string
+}
+
+// ValidateSessions 批次檢查多個 session 是否仍有效，規則與 IsSessionValid 相同（epoch、user_id、expires_at、DB fallback），
+// 但所有 session hash 以同一個 pipeline 一次讀回。回傳值與 refs 一一對應。
+// 這是給 gateway 對帳用的查詢，不代表使用者有活動，因此不更新 last_seen 與請求計數。
+func (s *SessionService) ValidateSessions(ctx context.Context, refs []SessionRef) ([]bool, error) {
+	valid := make([]bool, len(refs))
+	if len(refs) == 0 {
+		return valid, nil
+	}
+
+	epoch, err := s.CurrentSessionEpoch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := s.rdb.Pipeline()
+	hashes := make([]*redis.MapStringStringCmd, len(refs))
+	for i, ref := range refs {
+		hashes[i] = pipe.HGetAll(ctx, infra.SessKey(ref.SessionID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	fallback := s.FeatureFlag(ctx, FlagSessionDBFallback)
+	for i, ref := range refs {
+		if sessionIDEpoch(ref.SessionID) < epoch {
+			continue
+		}
+		data := hashes[i].Val()
+		if len(data) == 0 {
+			if fallback {
+				if valid[i], err = s.rehydrateSession(ctx, ref.UserID, ref.SessionID); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if uid := data["user_id"]; uid != "" && uid != stringFromInt64(ref.UserID) {
+			continue
+		}
+		if valid[i], err = s.checkSessionExpiry(ctx, infra.SessKey(ref.SessionID), data["expires_at"]); err != nil {
+			return nil, err
+		}
+	}
+	return valid, nil
+}
@@ -1,7 +1,13 @@
 package token
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -12,9 +18,96 @@ import (
 // - sid: session ID
 // - exp: 過期時間
 // - iat: 發行時間
+// - scope: token exchange 換出的 token 才有，空白分隔的 scope 清單
+// - aud: token exchange 換出的 token 為指定的下游服務；本服務的 token 為 AudienceUser / AudienceAdmin（見 WithAudienceEnforcement）
+// - amr: 使用者這次登入使用的驗證方式（RFC 8176），例如 ["pwd"]、["pwd","otp"]
+// 啟用 compact claims 時 token 內改用較短的 key（見 compactClaims），解析後仍還原成 Claims。
+// sub 預設以 JSON 數字輸出，啟用 string subject 時改為字串；解析時兩種都接受。
 type Claims struct {
-	UserID    int64  `json:"sub"`
-	SessionID string `json:"sid"`
+	UserID    int64    `json:"sub"`
+	SessionID string   `json:"sid"`
+	Scope     string   `json:"scope,omitempty"`
+	AMR       []string `json:"amr,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// amr claim 使用的驗證方式。
+const (
+	AMRPassword  = "pwd"    // 帳號密碼登入
+	AMROTP       = "otp"    // 一次性密碼（TOTP 等第二因素）
+	AMRGoogle    = "google" // Google OAuth 登入
+	AMRKey       = "swk"    // 以共用密鑰簽章的 machine-to-machine signed login
+	AMRMagicLink = "mlk"    // 寄到 email 的一次性登入連結
+)
+
+// 本服務自己使用的 audience，區分一般使用者 API 與 admin 路由。
+const (
+	AudienceUser  = "session-service:user"
+	AudienceAdmin = "session-service:admin"
+)
+
+// subject 是寫進 token 的 sub。RFC 7519 規定 sub 為字串，但早期發出的 token 以數字表示 user ID，
+// 因此解析時同時接受 JSON 數字與數字字串；quoted 為 true 時以字串輸出。
+type subject struct {
+	id     int64
+	quoted bool
+}
+
+func (s subject) MarshalJSON() ([]byte, error) {
+	if s.quoted {
+		return json.Marshal(strconv.FormatInt(s.id, 10))
+	}
+	return json.Marshal(s.id)
+}
+
+func (s *subject) UnmarshalJSON(b []byte) error {
+	raw := string(b)
+	if raw == "null" {
+		return nil
+	}
+	quoted := strings.HasPrefix(raw, `"`)
+	if quoted {
+		var str string
+		if err := json.Unmarshal(b, &str); err != nil {
+			return err
+		}
+		raw = str
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return fmt.Errorf("sub must be a numeric user id: %w", err)
+	}
+	s.id, s.quoted = id, quoted
+	return nil
+}
+
+// quotedSubClaims 在啟用 string subject 時包住 Claims，以字串的 sub 覆蓋 Claims.UserID。
+type quotedSubClaims struct {
+	*Claims
+	Sub subject `json:"sub"`
+}
+
+// compactClaims 是 compact 模式實際寫進 token 的 claims：自訂 claim 改用單字母 key，
+// scope 依 scope 表編成 bitmask，不在表內的 scope 才以字串保留。
+type compactClaims struct {
+	UserID    subject  `json:"sub"`
+	SessionID string   `json:"s,omitempty"`
+	ScopeMask uint64   `json:"sm,omitempty"`
+	Scope     string   `json:"sc,omitempty"`
+	AMR       []string `json:"m,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// wireClaims 解析時同時接受一般與 compact 兩種 key，讓切換設定前後發出的 token 都能驗證。
+type wireClaims struct {
+	UserID           subject  `json:"sub"`
+	SessionID        string   `json:"sid,omitempty"`
+	Scope            string   `json:"scope,omitempty"`
+	AMR              []string `json:"amr,omitempty"`
+	CompactSessionID string   `json:"s,omitempty"`
+	ScopeMask        uint64   `json:"sm,omitempty"`
+	CompactScope     string   `json:"sc,omitempty"`
+	CompactAMR       []string `json:"m,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -22,6 +115,28 @@ type Claims struct {
 type Manager struct {
 	secret []byte
 	ttl    time.Duration
+
+	// compact 為 true 時以 compactClaims 簽發 token，並省略 header 的 typ。
+	compact bool
+	// scopeTable 是 scope bitmask 的對照表，第 i 個 scope 對應第 i 個 bit，只能往後新增。
+	scopeTable []string
+	// stringSubject 為 true 時 sub 以字串輸出，給嚴格遵守 RFC 7519 的下游使用。
+	stringSubject bool
+
+	// maxScopes 是單一 token 最多帶幾個 scope，0 代表不限制；groupScopes 為 true 時超過上限會先嘗試合併成 scope 群組。
+	maxScopes   int
+	groupScopes bool
+	// groupTable 是可合併成 "prefix:*" 的完整 scope 清單，解析時依此展開。
+	groupTable []string
+
+	// maxTTL 是 token 從簽發起算的存活上限，呼叫端要求更晚的 exp 時縮短到 now + maxTTL；0 代表不限制。
+	maxTTL time.Duration
+
+	// leeway 是驗證 exp / nbf / iat 時容忍的時鐘誤差。
+	leeway time.Duration
+
+	// enforceAudience 為 true 時 GenerateWithSession 簽發的 token 帶 aud=AudienceUser，且 AcceptsAudience 不接受沒有 aud 的 token。
+	enforceAudience bool
 }
 
 // NewManager 建立一個新的 JWT Manager。
@@ -33,6 +148,83 @@ func NewManager(secret string, ttl time.Duration) *Manager {
 	}
 }
 
+// WithCompactClaims 讓之後簽發的 token 改用 compact claims，scope 依 WithScopeTable 註冊的對照表編成 bitmask。
+// 解析時不受此設定影響，一般格式的 token 仍可驗證。
+func (m *Manager) WithCompactClaims() *Manager {
+	m.compact = true
+	return m
+}
+
+// WithScopeTable 註冊 scope bitmask 的對照表，最多使用前 64 個。解析 compact token 一律需要這張表，
+// 因此不論是否啟用 compact claims 都應註冊，關閉 compact claims 後先前簽發的 token 仍能還原 scope。
+func (m *Manager) WithScopeTable(scopeTable []string) *Manager {
+	if len(scopeTable) > 64 {
+		scopeTable = scopeTable[:64]
+	}
+	m.scopeTable = scopeTable
+	return m
+}
+
+// WithStringSubject 讓之後簽發的 token 以字串輸出 sub（例如 "42" 而非 42）。
+// 解析時不受此設定影響，數字與字串的 sub 都可驗證。
+func (m *Manager) WithStringSubject() *Manager {
+	m.stringSubject = true
+	return m
+}
+
+// WithScopeLimit 限制之後簽發的 token 最多帶 max 個 scope。group 為 false 時超過上限直接回傳 ErrTooManyScopes；
+// 為 true 時先把 scopeTable 中同一前綴（"reports:" 之類）全部都有的 scope 合併成 "reports:*"，合併後仍超過才回傳錯誤。
+// 解析時不論此設定一律依 scopeTable 展開 "prefix:*"，Claims.Scope 看到的仍是完整清單。
+func (m *Manager) WithScopeLimit(max int, group bool, scopeTable []string) *Manager {
+	m.maxScopes = max
+	m.groupScopes = group
+	m.groupTable = scopeTable
+	return m
+}
+
+// WithMaxTTL 限制之後簽發的 token 從簽發起算最多存活 maxTTL，不論呼叫端傳入的 exp 多晚；0 代表不限制。
+func (m *Manager) WithMaxTTL(maxTTL time.Duration) *Manager {
+	m.maxTTL = maxTTL
+	return m
+}
+
+// WithLeeway 讓 Parse 驗證 exp / nbf / iat 時容忍 leeway 的時鐘誤差，避免簽發與驗證的節點時鐘略有差距時 token 在到期邊緣時好時壞。
+func (m *Manager) WithLeeway(leeway time.Duration) *Manager {
+	m.leeway = leeway
+	return m
+}
+
+// WithAudienceEnforcement 讓之後以 GenerateWithSession 簽發的 token 帶 aud=AudienceUser，
+// 並讓 AcceptsAudience 拒絕沒有 aud 的 token，使每個路由群組只接受自己 audience 的 token。
+func (m *Manager) WithAudienceEnforcement() *Manager {
+	m.enforceAudience = true
+	return m
+}
+
+// AcceptsAudience 回傳 claims 是否可用在要求 audience 的路由：aud 含有 audience 時接受；
+// 沒有 aud 的 token 只在未啟用 WithAudienceEnforcement 時接受。其他 aud（token exchange 換出的 token、其他路由群組的 token）一律拒絕。
+func (m *Manager) AcceptsAudience(claims *Claims, audience string) bool {
+	if len(claims.Audience) == 0 {
+		return !m.enforceAudience
+	}
+	return slices.Contains(claims.Audience, audience)
+}
+
+// CapExpiry 回傳 expiresAt 經 maxTTL 限制後的值：超過 now + maxTTL 時縮短並記錄 log，否則原樣回傳。
+// 簽發時會自動套用；呼叫端需要在回應中告知實際 exp 時，可先以此取得縮短後的時間。
+func (m *Manager) CapExpiry(expiresAt time.Time) time.Time {
+	if m.maxTTL <= 0 {
+		return expiresAt
+	}
+	limit := time.Now().Add(m.maxTTL)
+	if !expiresAt.After(limit) {
+		return expiresAt
+	}
+	log.Printf("jwt: requested exp %s exceeds max token ttl %s, clamped to %s",
+		expiresAt.UTC().Format(time.RFC3339), m.maxTTL, limit.UTC().Format(time.RFC3339))
+	return limit
+}
+
 // Generate 為指定 user 產生一顆 JWT。
 func (m *Manager) Generate(userID int64) (string, error) {
 	now := time.Now()
@@ -41,28 +233,191 @@ func (m *Manager) Generate(userID int64) (string, error) {
 		SessionID: "",
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(m.ttl)),
+			ExpiresAt: jwt.NewNumericDate(m.CapExpiry(now.Add(m.ttl))),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(m.secret)
+	return m.sign(claims)
+}
+
+// GenerateWithSession 為指定 user + session 產生一顆 JWT，並使用指定的 expiresAt（不超過 maxTTL）。
+// amr 為這次登入使用的驗證方式，會原樣寫入 amr claim。啟用 WithAudienceEnforcement 時帶 aud=AudienceUser。
+func (m *Manager) GenerateWithSession(userID int64, sessionID string, expiresAt time.Time, amr ...string) (string, error) {
+	audience := ""
+	if m.enforceAudience {
+		audience = AudienceUser
+	}
+	return m.GenerateForAudience(userID, sessionID, audience, expiresAt, amr...)
 }
 
-// GenerateWithSession 為指定 user + session 產生一顆 JWT，並使用指定的 expiresAt。
-func (m *Manager) GenerateWithSession(userID int64, sessionID string, expiresAt time.Time) (string, error) {
+// GenerateForAudience 與 GenerateWithSession 相同，但 aud 固定為 audience（AudienceUser 或 AudienceAdmin）；audience 為空時不帶 aud。
+func (m *Manager) GenerateForAudience(userID int64, sessionID, audience string, expiresAt time.Time, amr ...string) (string, error) {
 	now := time.Now()
 	claims := &Claims{
 		UserID:    userID,
 		SessionID: sessionID,
+		AMR:       amr,
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ExpiresAt: jwt.NewNumericDate(m.CapExpiry(expiresAt)),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if audience != "" {
+		claims.Audience = jwt.ClaimStrings{audience}
+	}
+	return m.sign(claims)
+}
+
+// GenerateExchanged 為 token exchange 產生一顆綁定同一 session、限定 audience 與 scopes 的 JWT。
+// amr 沿用原 token 的驗證方式，讓下游服務同樣能判斷使用者如何登入。
+func (m *Manager) GenerateExchanged(userID int64, sessionID, audience string, scopes []string, amr []string, expiresAt time.Time) (string, error) {
+	scopes, err := m.limitScopes(scopes)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Scope:     strings.Join(scopes, " "),
+		AMR:       amr,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(m.CapExpiry(expiresAt)),
+		},
+	}
+	return m.sign(claims)
+}
+
+// sign 依設定以一般或 compact 格式簽發 claims。
+func (m *Manager) sign(claims *Claims) (string, error) {
+	sub := subject{id: claims.UserID, quoted: m.stringSubject}
+	if !m.compact {
+		var payload jwt.Claims = claims
+		if m.stringSubject {
+			payload = &quotedSubClaims{Claims: claims, Sub: sub}
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+		return token.SignedString(m.secret)
+	}
+
+	mask, rest := m.encodeScopes(claims.Scope)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &compactClaims{
+		UserID:           sub,
+		SessionID:        claims.SessionID,
+		ScopeMask:        mask,
+		Scope:            rest,
+		AMR:              claims.AMR,
+		RegisteredClaims: claims.RegisteredClaims,
+	})
+	// typ 是選填的 header，驗證時不會用到
+	delete(token.Header, "typ")
 	return token.SignedString(m.secret)
 }
 
+// encodeScopes 將空白分隔的 scope 轉成 bitmask，不在 scope 表內的部分原樣回傳。
+func (m *Manager) encodeScopes(scope string) (uint64, string) {
+	var mask uint64
+	var rest []string
+	for _, sc := range strings.Fields(scope) {
+		if i := slices.Index(m.scopeTable, sc); i >= 0 {
+			mask |= 1 << uint(i)
+			continue
+		}
+		rest = append(rest, sc)
+	}
+	return mask, strings.Join(rest, " ")
+}
+
+// decodeScopes 是 encodeScopes 的反向，依 scope 表順序還原空白分隔的 scope。
+func (m *Manager) decodeScopes(mask uint64, rest string) string {
+	var scopes []string
+	for i, sc := range m.scopeTable {
+		if mask&(1<<uint(i)) != 0 {
+			scopes = append(scopes, sc)
+		}
+	}
+	if rest != "" {
+		scopes = append(scopes, rest)
+	}
+	return strings.Join(scopes, " ")
+}
+
+// scopeGroupSuffix 是 scope 群組的結尾，"reports:*" 代表 scope 表中所有 "reports:" 開頭的 scope。
+const scopeGroupSuffix = ":*"
+
+// limitScopes 依 WithScopeLimit 的設定檢查 scope 數量，必要時合併成群組。
+func (m *Manager) limitScopes(scopes []string) ([]string, error) {
+	if m.maxScopes <= 0 || len(scopes) <= m.maxScopes {
+		return scopes, nil
+	}
+	if m.groupScopes {
+		scopes = m.groupScopeList(scopes)
+	}
+	if len(scopes) > m.maxScopes {
+		return nil, ErrTooManyScopes
+	}
+	return scopes, nil
+}
+
+// groupScopeList 將 scope 表中同一前綴全部都有的 scope 換成一個 "prefix:*"，其他 scope 保持原順序。
+// 只合併完整的前綴，展開後與原本的清單相同，不會多給權限。
+func (m *Manager) groupScopeList(scopes []string) []string {
+	var out []string
+	grouped := map[string]bool{}
+	for _, sc := range scopes {
+		prefix, _, ok := strings.Cut(sc, ":")
+		if !ok || !slices.Contains(m.groupTable, sc) {
+			out = append(out, sc)
+			continue
+		}
+		if grouped[prefix] {
+			continue
+		}
+		family := m.scopeFamily(prefix)
+		complete := len(family) > 1
+		for _, member := range family {
+			complete = complete && slices.Contains(scopes, member)
+		}
+		if !complete {
+			out = append(out, sc)
+			continue
+		}
+		grouped[prefix] = true
+		out = append(out, prefix+scopeGroupSuffix)
+	}
+	return out
+}
+
+// scopeFamily 回傳 scope 表中所有以 prefix: 開頭的 scope。
+func (m *Manager) scopeFamily(prefix string) []string {
+	var family []string
+	for _, sc := range m.groupTable {
+		if strings.HasPrefix(sc, prefix+":") {
+			family = append(family, sc)
+		}
+	}
+	return family
+}
+
+// expandScopes 是 groupScopeList 的反向，將 "prefix:*" 依 scope 表展開；表中沒有的群組原樣保留。
+func (m *Manager) expandScopes(scope string) string {
+	if !strings.Contains(scope, scopeGroupSuffix) {
+		return scope
+	}
+	var out []string
+	for _, sc := range strings.Fields(scope) {
+		prefix, ok := strings.CutSuffix(sc, scopeGroupSuffix)
+		if family := m.scopeFamily(prefix); ok && len(family) > 0 {
+			out = append(out, family...)
+			continue
+		}
+		out = append(out, sc)
+	}
+	return strings.Join(out, " ")
+}
+
 // Parsed 包裝解析後的結果，方便之後擴充。
 type Parsed struct {
 	Token  *jwt.Token
@@ -72,24 +427,55 @@ type Parsed struct {
 var (
 	// ErrInvalidToken 代表 token 無效或簽章錯誤。
 	ErrInvalidToken = errors.New("invalid token")
+	// ErrTooManyScopes 代表 scope 數量超過 WithScopeLimit 的上限（合併成群組後仍超過）。
+	ErrTooManyScopes = errors.New("too many scopes")
 )
 
 // Parse 解析並驗證 JWT。
 func (m *Manager) Parse(tokenStr string) (*Parsed, error) {
-	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	return m.parse(tokenStr, jwt.WithLeeway(m.leeway))
+}
+
+// ParseAllowExpired 與 Parse 相同，但不檢查 exp / nbf / iat，讓已過期的 token 仍可證明自己屬於哪個使用者與 session。
+// 簽章仍必須正確；只能用在不授予任何權限的查詢（例如查詢 session 為何失效），不可取代 Parse。
+func (m *Manager) ParseAllowExpired(tokenStr string) (*Parsed, error) {
+	return m.parse(tokenStr, jwt.WithoutClaimsValidation())
+}
+
+func (m *Manager) parse(tokenStr string, opts ...jwt.ParserOption) (*Parsed, error) {
+	parser := jwt.NewParser(append(opts, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))...)
 
-	tok, err := parser.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+	tok, err := parser.ParseWithClaims(tokenStr, &wireClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return m.secret, nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	claims, ok := tok.Claims.(*Claims)
+	wire, ok := tok.Claims.(*wireClaims)
 	if !ok || !tok.Valid {
 		return nil, ErrInvalidToken
 	}
 
+	// compact 格式的 key 有值時優先採用，否則沿用一般格式
+	claims := &Claims{
+		UserID:           wire.UserID.id,
+		SessionID:        wire.SessionID,
+		Scope:            wire.Scope,
+		AMR:              wire.AMR,
+		RegisteredClaims: wire.RegisteredClaims,
+	}
+	if wire.CompactSessionID != "" {
+		claims.SessionID = wire.CompactSessionID
+	}
+	if wire.ScopeMask != 0 || wire.CompactScope != "" {
+		claims.Scope = m.decodeScopes(wire.ScopeMask, wire.CompactScope)
+	}
+	claims.Scope = m.expandScopes(claims.Scope)
+	if wire.CompactAMR != nil {
+		claims.AMR = wire.CompactAMR
+	}
+
 	return &Parsed{
 		Token:  tok,
 		Claims: claims,
@@ -1,9 +1,12 @@
 package token
 
 import (
-	"testing" // 匯入 testing 套件，提供單元測試基礎工具
-	"time"    // 匯入 time 套件，用來檢查 JWT 時間相關欄位
+	"encoding/base64" // 匯入 base64，解碼 token payload 檢查 claim 格式
+	"strings"         // 匯入 strings，拆解 token 的三個部分
+	"testing"         // 匯入 testing 套件，提供單元測試基礎工具
+	"time"            // 匯入 time 套件，用來檢查 JWT 時間相關欄位
 
+	"github.com/golang-jwt/jwt/v5"        // 匯入 jwt，模擬外部簽發的 token
 	"github.com/stretchr/testify/require" // 匯入 testify/require，方便進行斷言與錯誤檢查
 )
 
@@ -60,4 +63,244 @@ func TestManagerParseInvalidToken(t *testing.T) {
 	require.Nil(t, parsed)                      // 解析結果應為 nil
 }
 
+// TestManagerGenerateWithSessionAMR 測試 GenerateWithSession 會把驗證方式寫入 amr claim，未指定時省略。
+func TestManagerGenerateWithSessionAMR(t *testing.T) {
+	mgr := NewManager("amr-secret", time.Hour) // 建立 Manager
+	expiresAt := time.Now().Add(time.Hour)     // 過期時間
 
+	tokenStr, err := mgr.GenerateWithSession(1, "sess-amr", expiresAt, AMRPassword, AMROTP) // 密碼 + OTP 登入
+	require.NoError(t, err)                                                                 // 產生不應失敗
+	parsed, err := mgr.Parse(tokenStr)                                                      // 解析 token
+	require.NoError(t, err)                                                                 // 解析不應失敗
+	require.Equal(t, []string{"pwd", "otp"}, parsed.Claims.AMR)                              // amr 依序保留
+
+	tokenStr, err = mgr.GenerateWithSession(1, "sess-amr", expiresAt) // 未指定驗證方式
+	require.NoError(t, err)                                           // 產生不應失敗
+	parsed, err = mgr.Parse(tokenStr)                                 // 解析 token
+	require.NoError(t, err)                                           // 解析不應失敗
+	require.Nil(t, parsed.Claims.AMR)                                 // 不應帶 amr claim
+}
+
+// TestManagerCompactClaimsRoundTrip 測試 compact claims 產生的 token 可完整還原 sid、scope 與 amr。
+func TestManagerCompactClaimsRoundTrip(t *testing.T) {
+	scopes := []string{"profile:read", "invoices:read", "invoices:write"}                     // scope bitmask 對照表
+	mgr := NewManager("compact-secret", time.Hour).WithScopeTable(scopes).WithCompactClaims() // 啟用 compact claims
+	expiresAt := time.Now().Add(time.Hour)                                                    // 過期時間
+
+	tokenStr, err := mgr.GenerateExchanged(9, "v1.abc", "billing", []string{"invoices:write", "profile:read", "extra:scope"}, []string{AMRPassword}, expiresAt) // 含一個不在表內的 scope
+	require.NoError(t, err)                                                                                                                                     // 產生不應失敗
+
+	parsed, err := mgr.Parse(tokenStr)                                               // 解析 token
+	require.NoError(t, err)                                                          // 解析不應失敗
+	require.EqualValues(t, 9, parsed.Claims.UserID)                                  // sub 正確
+	require.Equal(t, "v1.abc", parsed.Claims.SessionID)                              // sid 由 "s" 還原
+	require.Equal(t, "profile:read invoices:write extra:scope", parsed.Claims.Scope) // 表內 scope 依表順序還原，表外 scope 接在後面
+	require.Equal(t, []string{AMRPassword}, parsed.Claims.AMR)                       // amr 由 "m" 還原
+	require.Equal(t, []string{"billing"}, []string(parsed.Claims.Audience))          // aud 維持標準 claim
+	_, hasTyp := parsed.Token.Header["typ"]                                          // compact 模式省略 typ header
+	require.False(t, hasTyp)                                                         // 不應帶 typ
+}
+
+// TestManagerCompactClaimsSmaller 測試 compact claims 產生的 token 比一般格式短。
+func TestManagerCompactClaimsSmaller(t *testing.T) {
+	scopes := []string{"profile:read", "invoices:read"}                                        // scope bitmask 對照表
+	plain := NewManager("size-secret", time.Hour)                                              // 一般格式
+	compact := NewManager("size-secret", time.Hour).WithScopeTable(scopes).WithCompactClaims() // compact 格式
+	expiresAt := time.Now().Add(time.Hour)                                                     // 過期時間
+
+	long, err := plain.GenerateExchanged(1, "v1.abc", "billing", scopes, []string{AMRPassword}, expiresAt)    // 一般格式 token
+	require.NoError(t, err)                                                                                   // 產生不應失敗
+	short, err := compact.GenerateExchanged(1, "v1.abc", "billing", scopes, []string{AMRPassword}, expiresAt) // compact token
+	require.NoError(t, err)                                                                                   // 產生不應失敗
+	require.Less(t, len(short), len(long))                                                                    // compact 應較短
+}
+
+// TestManagerCompactParsesLegacyToken 測試啟用 compact claims 後，切換前發出的一般格式 token 仍可解析。
+func TestManagerCompactParsesLegacyToken(t *testing.T) {
+	plain := NewManager("legacy-secret", time.Hour)                                                 // 切換前的 Manager
+	tokenStr, err := plain.GenerateWithSession(3, "sess-legacy", time.Now().Add(time.Hour), AMROTP) // 一般格式 token
+	require.NoError(t, err)                                                                         // 產生不應失敗
+
+	compact := NewManager("legacy-secret", time.Hour).WithCompactClaims() // 切換後的 Manager
+	parsed, err := compact.Parse(tokenStr)                                // 解析舊 token
+	require.NoError(t, err)                                               // 應可解析
+	require.Equal(t, "sess-legacy", parsed.Claims.SessionID)              // sid 正確
+	require.Equal(t, []string{AMROTP}, parsed.Claims.AMR)                 // amr 正確
+}
+
+// TestManagerStringSubject 測試啟用 string subject 時 sub 以字串輸出，且一般與 compact 格式都能解析回 user ID。
+func TestManagerStringSubject(t *testing.T) {
+	for _, compact := range []bool{false, true} {
+		mgr := NewManager("sub-secret", time.Hour).WithStringSubject() // 以字串輸出 sub
+		if compact {
+			mgr.WithCompactClaims() // 同時啟用 compact claims
+		}
+		tokenStr, err := mgr.GenerateWithSession(42, "sess-sub", time.Now().Add(time.Hour)) // 產生 token
+		require.NoError(t, err)                                                             // 產生不應失敗
+
+		parts := strings.Split(tokenStr, ".")                        // 拆出 payload
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1]) // 解碼 payload
+		require.NoError(t, err)                                      // 解碼不應失敗
+		require.Contains(t, string(payload), `"sub":"42"`)           // sub 應為字串
+
+		parsed, err := mgr.Parse(tokenStr)                    // 解析 token
+		require.NoError(t, err)                               // 應可解析
+		require.Equal(t, int64(42), parsed.Claims.UserID)     // user ID 正確
+		require.Equal(t, "sess-sub", parsed.Claims.SessionID) // sid 正確
+
+		plain := NewManager("sub-secret", time.Hour)      // 未啟用 string subject 的 Manager
+		parsed, err = plain.Parse(tokenStr)               // 同樣能解析字串 sub
+		require.NoError(t, err)                           // 應可解析
+		require.Equal(t, int64(42), parsed.Claims.UserID) // user ID 正確
+	}
+}
+
+// TestManagerParsesNumericAndStringSubject 測試外部簽發的 token 以數字或數字字串表示 sub 都能解析，非數字的 sub 則被拒絕。
+func TestManagerParsesNumericAndStringSubject(t *testing.T) {
+	mgr := NewManager("sub-secret", time.Hour)           // 預設以數字輸出 sub
+	exp := jwt.NewNumericDate(time.Now().Add(time.Hour)) // 過期時間
+	sign := func(sub interface{}) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": sub, "sid": "sess-x", "exp": exp}) // 外部簽發的 token
+		s, err := tok.SignedString([]byte("sub-secret"))                                                         // 以相同密鑰簽章
+		require.NoError(t, err)                                                                                  // 簽章不應失敗
+		return s
+	}
+
+	for _, sub := range []interface{}{7, "7"} { // 數字與字串
+		parsed, err := mgr.Parse(sign(sub))              // 解析
+		require.NoError(t, err)                          // 應可解析
+		require.Equal(t, int64(7), parsed.Claims.UserID) // user ID 正確
+	}
+
+	_, err := mgr.Parse(sign("alice")) // 非數字的 sub
+	require.Error(t, err)              // 應解析失敗
+
+	tokenStr, err := mgr.Generate(9)                                                    // 預設格式
+	require.NoError(t, err)                                                             // 產生不應失敗
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(tokenStr, ".")[1]) // 解碼 payload
+	require.NoError(t, err)                                                             // 解碼不應失敗
+	require.Contains(t, string(payload), `"sub":9`)                                     // 預設仍以數字輸出
+}
+
+// TestManagerScopeLimit 測試 scope 數量上限：未超過時不受影響，超過時拒絕簽發，group 模式合併後解析還原完整清單。
+func TestManagerScopeLimit(t *testing.T) {
+	table := []string{"profile:read", "invoices:read", "invoices:write", "invoices:export"} // 可換發的 scope 全集
+	expiresAt := time.Now().Add(time.Hour)                                                  // 過期時間
+	all := []string{"profile:read", "invoices:read", "invoices:write", "invoices:export"}   // 要求全部 scope
+
+	mgr := NewManager("limit-secret", time.Hour).WithScopeLimit(2, false, table) // 最多 2 個，超過直接拒絕
+
+	tokenStr, err := mgr.GenerateExchanged(7, "sid", "billing", []string{"invoices:read", "profile:read"}, nil, expiresAt) // 剛好 2 個
+	require.NoError(t, err)                                                                                                // 未超過上限不受影響
+	parsed, err := mgr.Parse(tokenStr)                                                                                     // 解析 token
+	require.NoError(t, err)                                                                                                // 應成功
+	require.Equal(t, "invoices:read profile:read", parsed.Claims.Scope)                                                    // scope 原樣保留
+
+	_, err = mgr.GenerateExchanged(7, "sid", "billing", all, nil, expiresAt) // 4 個 scope
+	require.ErrorIs(t, err, ErrTooManyScopes)                                // 超過上限拒絕簽發
+
+	for _, compact := range []bool{false, true} {
+		mgr := NewManager("limit-secret", time.Hour).WithScopeLimit(2, true, table) // 超過時合併成群組
+		if compact {
+			mgr.WithScopeTable(table).WithCompactClaims() // compact 格式同樣適用
+		}
+
+		tokenStr, err := mgr.GenerateExchanged(7, "sid", "billing", all, nil, expiresAt)    // invoices:* 三個合併成一個
+		require.NoError(t, err)                                                             // 合併後為 2 個
+		payload, err := base64.RawURLEncoding.DecodeString(strings.Split(tokenStr, ".")[1]) // 解碼 payload
+		require.NoError(t, err)                                                             // payload 應可解碼
+		require.Contains(t, string(payload), `invoices:*`)                                  // token 內是群組
+		require.NotContains(t, string(payload), `invoices:read`)                            // 不再逐一列出
+
+		parsed, err := mgr.Parse(tokenStr)                                 // 解析 token
+		require.NoError(t, err)                                            // 應成功
+		require.ElementsMatch(t, all, strings.Fields(parsed.Claims.Scope)) // 解析後展開成完整清單
+
+		_, err = mgr.GenerateExchanged(7, "sid", "billing", []string{"profile:read", "invoices:read", "invoices:write"}, nil, expiresAt) // invoices 不完整，無法合併
+		require.ErrorIs(t, err, ErrTooManyScopes)                                                                                        // 不會合併成比要求更大的權限
+	}
+}
+
+// TestManagerMaxTTL 測試 exp 超過 MAX_TOKEN_TTL 時縮短到 now + maxTTL，在上限內的 exp 不受影響。
+func TestManagerMaxTTL(t *testing.T) {
+	mgr := NewManager("ttl-secret", time.Hour).WithMaxTTL(24 * time.Hour) // token 最多存活 24 小時
+
+	tooLong := time.Now().Add(30 * 24 * time.Hour)                                                       // 要求 30 天後才過期
+	tokenStr, err := mgr.GenerateWithSession(1, "sess-long", tooLong)                                    // 產生 token
+	require.NoError(t, err)                                                                              // 應產生成功
+	parsed, err := mgr.Parse(tokenStr)                                                                   // 解析 token
+	require.NoError(t, err)                                                                              // 應解析成功
+	require.WithinDuration(t, time.Now().Add(24*time.Hour), parsed.Claims.ExpiresAt.Time, 2*time.Second) // exp 縮短到 now + 24h
+
+	within := time.Now().Add(2 * time.Hour).Truncate(time.Second)                     // 上限內的過期時間
+	tokenStr, err = mgr.GenerateWithSession(1, "sess-short", within)                  // 產生 token
+	require.NoError(t, err)                                                           // 應產生成功
+	parsed, err = mgr.Parse(tokenStr)                                                 // 解析 token
+	require.NoError(t, err)                                                           // 應解析成功
+	require.Equal(t, within.Unix(), parsed.Claims.ExpiresAt.Unix())                   // exp 不變
+	require.Equal(t, within, mgr.CapExpiry(within))                                   // CapExpiry 原樣回傳
+	require.Equal(t, tooLong, NewManager("ttl-secret", time.Hour).CapExpiry(tooLong)) // 未設定上限時不縮短
+}
+
+// TestManagerLeeway 測試設定 leeway 後剛過期的 token 仍可解析，超過 leeway 則照常視為過期。
+func TestManagerLeeway(t *testing.T) {
+	strict := NewManager("leeway-secret", time.Hour)                              // 不容忍時鐘誤差
+	lenient := NewManager("leeway-secret", time.Hour).WithLeeway(5 * time.Second) // 容忍 5 秒
+
+	justExpired, err := strict.GenerateWithSession(1, "sess-skew", time.Now().Add(-2*time.Second)) // 2 秒前過期的 token
+	require.NoError(t, err)                                                                        // 應產生成功
+	_, err = strict.Parse(justExpired)                                                             // 不容忍誤差時解析
+	require.ErrorIs(t, err, jwt.ErrTokenExpired)                                                   // 應視為過期
+	parsed, err := lenient.Parse(justExpired)                                                      // 容忍 5 秒時解析
+	require.NoError(t, err)                                                                        // 應解析成功
+	require.Equal(t, "sess-skew", parsed.Claims.SessionID)                                         // claims 正確
+
+	longExpired, err := strict.GenerateWithSession(1, "sess-old", time.Now().Add(-10*time.Second)) // 10 秒前過期的 token
+	require.NoError(t, err)                                                                        // 應產生成功
+	_, err = lenient.Parse(longExpired)                                                            // 超過 leeway
+	require.ErrorIs(t, err, jwt.ErrTokenExpired)                                                   // 仍應視為過期
+}
+
+// TestManagerAudience 測試 GenerateForAudience 寫入 aud，AcceptsAudience 只接受相同 audience，啟用強制檢查後拒絕沒有 aud 的 token。
+func TestManagerAudience(t *testing.T) {
+	lenient := NewManager("aud-secret", time.Hour)                          // 未強制檢查 aud
+	strict := NewManager("aud-secret", time.Hour).WithAudienceEnforcement() // 強制檢查 aud
+	exp := time.Now().Add(time.Hour)                                        // 共用的到期時間
+
+	adminTok, err := lenient.GenerateForAudience(1, "sess-admin", AudienceAdmin, exp) // admin audience 的 token
+	require.NoError(t, err)                                                           // 應產生成功
+	parsed, err := lenient.Parse(adminTok)                                            // 解析
+	require.NoError(t, err)                                                           // 應解析成功
+	require.Equal(t, []string{AudienceAdmin}, []string(parsed.Claims.Audience))       // aud 正確
+	require.True(t, lenient.AcceptsAudience(parsed.Claims, AudienceAdmin))            // admin 路由接受
+	require.False(t, lenient.AcceptsAudience(parsed.Claims, AudienceUser))            // 一般路由拒絕
+
+	plain, err := lenient.GenerateWithSession(1, "sess-plain", exp)       // 未強制時不帶 aud
+	require.NoError(t, err)                                               // 應產生成功
+	parsed, err = lenient.Parse(plain)                                    // 解析
+	require.NoError(t, err)                                               // 應解析成功
+	require.Empty(t, parsed.Claims.Audience)                              // 沒有 aud
+	require.True(t, lenient.AcceptsAudience(parsed.Claims, AudienceUser)) // 未強制時接受
+	require.False(t, strict.AcceptsAudience(parsed.Claims, AudienceUser)) // 強制時拒絕
+
+	tagged, err := strict.GenerateWithSession(1, "sess-user", exp)             // 強制時自動帶 aud
+	require.NoError(t, err)                                                    // 應產生成功
+	parsed, err = strict.Parse(tagged)                                         // 解析
+	require.NoError(t, err)                                                    // 應解析成功
+	require.Equal(t, []string{AudienceUser}, []string(parsed.Claims.Audience)) // aud 為一般使用者
+	require.True(t, strict.AcceptsAudience(parsed.Claims, AudienceUser))       // 一般路由接受
+	require.False(t, strict.AcceptsAudience(parsed.Claims, AudienceAdmin))     // admin 路由拒絕
+}
+
+// TestManagerParsesCompactTokenAfterDisabling 測試關閉 compact claims 後，只要仍註冊 scope 對照表，先前簽發的 compact token 可還原完整 scope。
+func TestManagerParsesCompactTokenAfterDisabling(t *testing.T) {
+	scopes := []string{"profile:read", "invoices:read"}                                                        // scope bitmask 對照表
+	compact := NewManager("switch-secret", time.Hour).WithScopeTable(scopes).WithCompactClaims()               // 切換前的 Manager
+	tokenStr, err := compact.GenerateExchanged(1, "v1.abc", "billing", scopes, nil, time.Now().Add(time.Hour)) // compact token
+	require.NoError(t, err)                                                                                    // 產生不應失敗
+
+	plain := NewManager("switch-secret", time.Hour).WithScopeTable(scopes) // 關閉 compact claims 後的 Manager
+	parsed, err := plain.Parse(tokenStr)                                   // 解析舊 token
+	require.NoError(t, err)                                                // 應可解析
+	require.Equal(t, "profile:read invoices:read", parsed.Claims.Scope)    // bitmask 仍依對照表還原
+}
@@ -0,0 +1,193 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"sessionservice/internal/db"
+	"sessionservice/internal/infra"
+)
+
+// maxRowsPerInsert 限制單一 INSERT 的列數，避免超過 SQLite 的參數數量上限。
+const maxRowsPerInsert = 500
+
+// ErrAuditBufferFull 代表 DB 長時間寫入失敗導致 buffer 堆積，任務應交回 asynq 稍後重試。
+var ErrAuditBufferFull = errors.New("login audit buffer full")
+
+// AuditBatcher 在記憶體暫存 login:audit 事件，累積 size 筆或經過 interval 時以多列 INSERT 一次寫入。
+// Add 會等到事件所在的批次 commit 後才返回，任務在寫入成功前不會被 asynq 視為完成；
+// flush 失敗時錯誤回傳給該批次所有的 Add，由 asynq 重試（login_events.event_id 避免重複寫入）。
+// 同時等待的事件數受 worker concurrency 限制，size 大於 concurrency 時批次只會由 interval 觸發。
+type AuditBatcher struct {
+	sqlDB    *sql.DB
+	q        *db.Queries
+	size     int
+	interval time.Duration
+
+	mu  sync.Mutex
+	buf []pendingAudit
+
+	// flushMu 讓 flush 依序執行
+	flushMu sync.Mutex
+}
+
+// pendingAudit 是暫存中的事件；done 收到該事件所在批次的寫入結果。
+type pendingAudit struct {
+	p    infra.LoginAuditPayload
+	done chan error
+}
+
+func NewAuditBatcher(sqlDB *sql.DB, q *db.Queries, size int, interval time.Duration) *AuditBatcher {
+	return &AuditBatcher{
+		sqlDB:    sqlDB,
+		q:        q,
+		size:     size,
+		interval: interval,
+	}
+}
+
+// Add 將事件放入 buffer，累積到 size 筆時立即 flush，並等待事件寫入 DB 後回傳寫入結果。
+// ctx 結束時直接回傳 ctx.Err()，事件仍留在 buffer 內，之後照常寫入。
+func (b *AuditBatcher) Add(ctx context.Context, p infra.LoginAuditPayload) error {
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+	e := pendingAudit{p: p, done: make(chan error, 1)}
+
+	b.mu.Lock()
+	if len(b.buf) >= b.size*10 {
+		b.mu.Unlock()
+		return ErrAuditBufferFull
+	}
+	b.buf = append(b.buf, e)
+	full := len(b.buf) >= b.size
+	b.mu.Unlock()
+
+	if full {
+		if err := b.Flush(ctx); err != nil {
+			log.Printf("login:audit: batch flush error: %v", err)
+		}
+	}
+
+	select {
+	case err := <-e.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run 每隔 interval flush 一次，ctx 結束時做最後一次 flush 後返回。
+func (b *AuditBatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.Flush(ctx); err != nil {
+				log.Printf("login:audit: batch flush error: %v", err)
+			}
+		case <-ctx.Done():
+			// 關機時 ctx 已取消，改用新的 context 確保最後一批能寫入
+			if err := b.Flush(context.Background()); err != nil {
+				log.Printf("login:audit: final flush error: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// Flush 將目前 buffer 內的事件寫入 DB，並把寫入結果通知每個等待中的 Add。
+func (b *AuditBatcher) Flush(ctx context.Context) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	pending := b.buf
+	b.buf = nil
+	b.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	events := make([]infra.LoginAuditPayload, len(pending))
+	for i, e := range pending {
+		events[i] = e.p
+	}
+	err := b.write(ctx, events)
+	for _, e := range pending {
+		e.done <- err
+	}
+	return err
+}
+
+// Pending 回傳 buffer 內尚未寫入的事件數。
+func (b *AuditBatcher) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.buf)
+}
+
+// write 在同一個 transaction 內寫入 login_events 並更新 last_login_at。
+func (b *AuditBatcher) write(ctx context.Context, events []infra.LoginAuditPayload) error {
+	tx, err := b.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(events); start += maxRowsPerInsert {
+		end := min(start+maxRowsPerInsert, len(events))
+		chunk := events[start:end]
+
+		rows := make([]string, 0, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*9)
+		for _, p := range chunk {
+			var userID sql.NullInt64
+			if p.UserID != nil {
+				userID = sql.NullInt64{Int64: *p.UserID, Valid: true}
+			}
+			rows = append(rows, "(?, ?, ?, ?, ?, ?, ?, ?, ?)")
+			args = append(args, nullableInt64(userID), p.Username, p.Success, p.Reason, p.IP, p.UserAgent, nullableString(p.Country), nullableString(p.EventID), p.CreatedAt.UTC())
+		}
+		query := `
+INSERT INTO login_events (
+    user_id,
+    username,
+    success,
+    reason,
+    ip,
+    user_agent,
+    country,
+    event_id,
+    created_at
+) VALUES ` + strings.Join(rows, ", ") + `
+ON CONFLICT (event_id) DO NOTHING`
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	qtx := b.q.WithTx(tx)
+	for _, p := range events {
+		if !p.Success || p.UserID == nil {
+			continue
+		}
+		if err := qtx.UpdateLastLogin(ctx, db.UpdateLastLoginParams{
+			ID:          *p.UserID,
+			LastLoginAt: sql.NullTime{Time: p.CreatedAt, Valid: true},
+			LastLoginIp: sql.NullString{String: p.IP, Valid: p.IP != ""},
+		}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
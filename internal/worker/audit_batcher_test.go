@@ -0,0 +1,187 @@
+package worker
+
+import (
+	"context" // 匯入 context，控制 Run 的生命週期
+	"os"      // 匯入 os，重新建立 login_events 表
+	"testing" // 匯入 testing 套件，提供單元測試框架
+	"time"    // 匯入 time，設定批次間隔與登入時間
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/db"    // 匯入 db 套件，建立測試使用者
+	"sessionservice/internal/infra" // 匯入 infra 套件，取得 payload 型別
+)
+
+// countLoginEvents 回傳 login_events 目前的筆數。
+func countLoginEvents(t *testing.T, env *testEnv) int {
+	t.Helper()                                                                                // 標記為測試輔助函式
+	var cnt int                                                                               // 用於接收筆數
+	err := env.sqlDB.QueryRowContext(env.ctx, "SELECT COUNT(*) FROM login_events").Scan(&cnt) // 查詢筆數
+	require.NoError(t, err)                                                                   // 查詢應成功
+	return cnt
+}
+
+// auditEvent 建立指定 username 的失敗登入事件。
+func auditEvent(username string) infra.LoginAuditPayload {
+	return infra.LoginAuditPayload{Username: username, Reason: "wrong_password", CreatedAt: time.Now()}
+}
+
+// addAsync 在背景呼叫 Add（Add 會等到寫入完成才返回），回傳接收結果的 channel。
+func addAsync(ctx context.Context, b *AuditBatcher, p infra.LoginAuditPayload) <-chan error {
+	ch := make(chan error, 1)           // 結果 channel
+	go func() { ch <- b.Add(ctx, p) }() // 背景等待寫入
+	return ch
+}
+
+// waitPending 等待 buffer 內累積到 n 筆事件。
+func waitPending(t *testing.T, b *AuditBatcher, n int) {
+	t.Helper()                                                                                      // 標記為測試輔助函式
+	require.Eventually(t, func() bool { return b.Pending() == n }, time.Second, 5*time.Millisecond) // 等待事件進入 buffer
+}
+
+// TestAuditBatcherFlushesOnSize 測試累積到 size 筆時會立即寫入，未滿時 Add 會等待而不寫入。
+func TestAuditBatcherFlushesOnSize(t *testing.T) {
+	env := newTestEnv(t)                                        // 建立測試環境
+	b := NewAuditBatcher(env.sqlDB, env.q, 3, time.Hour)        // size 為 3，interval 長到不會觸發
+	env.handlers.WithAuditBatcher(b)                            // 讓 handler 改用批次寫入
+	user, err := env.q.CreateUser(env.ctx, db.CreateUserParams{ // 建立使用者
+		Username:     "alice",
+		PasswordHash: "x",
+	})
+	require.NoError(t, err) // 確保建立成功
+
+	loggedInAt := time.Now().Add(-time.Minute).UTC().Truncate(time.Second) // 登入當下的時間
+	results := make(chan error, 2)                                         // 收集任務處理結果
+	for i := 0; i < 2; i++ {                                               // 先送兩筆，尚未達到 size
+		task := newTask(t, infra.TaskTypeLoginAudit, infra.LoginAuditPayload{
+			UserID:    &user.ID,
+			Username:  "alice",
+			Success:   true,
+			Reason:    "ok",
+			CreatedAt: loggedInAt,
+		})
+		go func() { results <- env.handlers.HandleLoginAudit(env.ctx, task) }() // 任務會等待批次寫入
+	}
+	waitPending(t, b, 2)                          // 兩筆仍在 buffer
+	require.Equal(t, 0, countLoginEvents(t, env)) // 尚未寫入 DB
+	require.Len(t, results, 0)                    // 任務尚未完成，不會被 ack
+
+	require.NoError(t, b.Add(env.ctx, auditEvent("alice"))) // 第三筆達到 size
+	require.Equal(t, 3, countLoginEvents(t, env))           // 三筆一次寫入
+	require.Equal(t, 0, b.Pending())                        // buffer 已清空
+	for i := 0; i < 2; i++ {
+		require.NoError(t, <-results) // 先前的任務在寫入後才完成
+	}
+
+	got, err := env.q.GetUserByID(env.ctx, user.ID)         // 重新讀取使用者
+	require.NoError(t, err)                                 // 查詢應成功
+	require.True(t, got.LastLoginAt.Time.Equal(loggedInAt)) // 批次寫入也會更新 last_login_at
+}
+
+// TestAuditBatcherFlushesOnInterval 測試未達 size 時，經過 interval 仍會寫入。
+func TestAuditBatcherFlushesOnInterval(t *testing.T) {
+	env := newTestEnv(t)                                             // 建立測試環境
+	b := NewAuditBatcher(env.sqlDB, env.q, 100, 20*time.Millisecond) // size 很大，只靠時間觸發
+	ctx, cancel := context.WithCancel(env.ctx)                       // 控制 Run 結束
+	done := make(chan struct{})                                      // Run 結束通知
+	go func() {
+		b.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() { cancel(); <-done }) // 測試結束時停止 Run
+
+	require.NoError(t, b.Add(env.ctx, auditEvent("bob"))) // 只送一筆，等到定時 flush 才返回
+	require.Equal(t, 1, countLoginEvents(t, env))         // 返回時已寫入
+}
+
+// TestAuditBatcherFlushesOnShutdown 測試 Run 結束時會寫入 buffer 內剩餘的事件。
+func TestAuditBatcherFlushesOnShutdown(t *testing.T) {
+	env := newTestEnv(t)                                   // 建立測試環境
+	b := NewAuditBatcher(env.sqlDB, env.q, 100, time.Hour) // size 與 interval 都不會觸發
+	ctx, cancel := context.WithCancel(env.ctx)             // 模擬 worker 關機
+	done := make(chan struct{})                            // Run 結束通知
+	go func() {
+		b.Run(ctx)
+		close(done)
+	}()
+
+	first := addAsync(env.ctx, b, auditEvent("carol"))  // 送兩筆
+	second := addAsync(env.ctx, b, auditEvent("carol")) // 仍留在 buffer
+	waitPending(t, b, 2)                                // 等待進入 buffer
+	require.Equal(t, 0, countLoginEvents(t, env))       // 尚未寫入
+
+	cancel() // 觸發關機
+	<-done   // 等待最後一次 flush 完成
+
+	require.NoError(t, <-first)                   // 寫入成功後才返回
+	require.NoError(t, <-second)                  // 寫入成功後才返回
+	require.Equal(t, 2, countLoginEvents(t, env)) // 剩餘事件都已寫入
+	require.Equal(t, 0, b.Pending())              // buffer 已清空
+}
+
+// TestAuditBatcherReturnsFlushError 測試 flush 失敗時錯誤會回傳給等待中的 Add，讓任務交回 asynq 重試。
+func TestAuditBatcherReturnsFlushError(t *testing.T) {
+	env := newTestEnv(t)                                   // 建立測試環境
+	b := NewAuditBatcher(env.sqlDB, env.q, 100, time.Hour) // 手動控制 flush
+
+	first := addAsync(env.ctx, b, auditEvent("first"))   // 第一筆
+	second := addAsync(env.ctx, b, auditEvent("second")) // 第二筆
+	waitPending(t, b, 2)                                 // 等待進入 buffer
+
+	_, err := env.sqlDB.ExecContext(env.ctx, "DROP TABLE login_events") // 讓寫入失敗
+	require.NoError(t, err)                                             // 應成功刪除
+	require.Error(t, b.Flush(env.ctx))                                  // flush 應回傳錯誤
+	require.Error(t, <-first)                                           // 任務收到錯誤，不會被 ack
+	require.Error(t, <-second)                                          // 任務收到錯誤，不會被 ack
+	require.Equal(t, 0, b.Pending())                                    // 失敗的事件交由 asynq 重試，不留在 buffer
+
+	for _, path := range []string{ // 重新建立 login_events（含後續新增的欄位）
+		"../../db/migrations/003_add_login_events.up.sql",
+		"../../db/migrations/011_add_login_events_country.up.sql",
+		"../../db/migrations/019_add_login_events_event_id.up.sql",
+	} {
+		data, err := os.ReadFile(path)                        // 讀取 migration
+		require.NoError(t, err)                               // 讀取應成功
+		_, err = env.sqlDB.ExecContext(env.ctx, string(data)) // 套用 migration
+		require.NoError(t, err)                               // 應成功
+	}
+
+	retry := addAsync(env.ctx, b, auditEvent("first")) // asynq 重試
+	waitPending(t, b, 1)                               // 等待進入 buffer
+	require.NoError(t, b.Flush(env.ctx))               // 重試應成功
+	require.NoError(t, <-retry)                        // 任務寫入成功
+	require.Equal(t, 1, countLoginEvents(t, env))      // 只寫入重試的那一筆
+}
+
+// TestAuditBatcherAddReturnsOnContextDone 測試任務 ctx 結束時 Add 不再等待，事件仍會在之後寫入。
+func TestAuditBatcherAddReturnsOnContextDone(t *testing.T) {
+	env := newTestEnv(t)                                   // 建立測試環境
+	b := NewAuditBatcher(env.sqlDB, env.q, 100, time.Hour) // 手動控制 flush
+
+	ctx, cancel := context.WithCancel(env.ctx)  // 模擬任務逾時
+	res := addAsync(ctx, b, auditEvent("dave")) // 送出事件
+	waitPending(t, b, 1)                        // 等待進入 buffer
+	cancel()                                    // 任務 ctx 結束
+	require.ErrorIs(t, <-res, context.Canceled) // Add 回傳 ctx 錯誤
+
+	require.NoError(t, b.Flush(env.ctx))          // 之後照常 flush
+	require.Equal(t, 1, countLoginEvents(t, env)) // 事件仍寫入
+}
+
+// TestAuditBatcherSkipsDuplicateEventID 測試同一個 event_id 重複加入（任務重試）時只寫入一筆。
+func TestAuditBatcherSkipsDuplicateEventID(t *testing.T) {
+	env := newTestEnv(t)                                 // 建立測試環境
+	b := NewAuditBatcher(env.sqlDB, env.q, 2, time.Hour) // 兩筆即寫入
+
+	ev := auditEvent("alice")                     // 建立事件
+	ev.EventID = "evt-1"                          // 指定 event_id
+	first := addAsync(env.ctx, b, ev)             // 第一次加入
+	second := addAsync(env.ctx, b, ev)            // 同一批次內重複加入
+	require.NoError(t, <-first)                   // 寫入應成功
+	require.NoError(t, <-second)                  // 寫入應成功
+	again := addAsync(env.ctx, b, ev)             // 下一批次再重複加入
+	waitPending(t, b, 1)                          // 等待進入 buffer
+	require.NoError(t, b.Flush(env.ctx))          // 寫入應成功
+	require.NoError(t, <-again)                   // 任務完成
+	require.Equal(t, 1, countLoginEvents(t, env)) // 只寫入一筆
+}
@@ -0,0 +1,211 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/syslog"
+	"os"
+	"sync"
+	"time"
+
+	"sessionservice/internal/db"
+	"sessionservice/internal/infra"
+)
+
+// AUDIT_SINK 可用的 sink 名稱。
+const (
+	AuditSinkSQLite = "sqlite"
+	AuditSinkFile   = "file"
+	AuditSinkSyslog = "syslog"
+)
+
+// AuditSink 是 login:audit 事件的輸出目的地。
+type AuditSink interface {
+	Name() string
+	Write(ctx context.Context, p infra.LoginAuditPayload) error
+}
+
+// AuditSinkOptions 是 OpenAuditSinks 建立各 sink 所需的設定。
+type AuditSinkOptions struct {
+	FilePath  string // file sink 的 JSON lines 檔案路徑
+	SyslogTag string // syslog sink 的 tag
+}
+
+// OpenAuditSinks 依名稱建立 sink；sqlite 由呼叫端傳入（通常是 Handlers.SQLiteAuditSink）。
+// 任一 sink 建立失敗時，已開啟的 sink 會先關閉再回傳錯誤。
+func OpenAuditSinks(names []string, sqlite AuditSink, opts AuditSinkOptions) ([]AuditSink, error) {
+	var sinks []AuditSink
+	for _, name := range names {
+		var sink AuditSink
+		var err error
+		switch name {
+		case AuditSinkSQLite:
+			sink = sqlite
+		case AuditSinkFile:
+			sink, err = NewFileAuditSink(opts.FilePath)
+		case AuditSinkSyslog:
+			sink, err = NewSyslogAuditSink(opts.SyslogTag)
+		default:
+			err = fmt.Errorf("unknown audit sink %q", name)
+		}
+		if err != nil {
+			CloseAuditSinks(sinks)
+			return nil, fmt.Errorf("audit sink %s: %w", name, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// CloseAuditSinks 關閉實作 io.Closer 的 sink（file、syslog）。
+func CloseAuditSinks(sinks []AuditSink) {
+	for _, sink := range sinks {
+		if c, ok := sink.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				log.Printf("login:audit: close %s sink: %v", sink.Name(), err)
+			}
+		}
+	}
+}
+
+// sqliteAuditSink 寫入 login_events 並更新 users.last_login_at；啟用 AuditBatcher 時改由 batcher 批次寫入。
+type sqliteAuditSink struct {
+	sqlDB   *sql.DB
+	q       *db.Queries
+	batcher *AuditBatcher
+}
+
+func (s *sqliteAuditSink) Name() string { return AuditSinkSQLite }
+
+func (s *sqliteAuditSink) Write(ctx context.Context, p infra.LoginAuditPayload) error {
+	if s.batcher != nil {
+		return s.batcher.Add(ctx, p)
+	}
+
+	var userID sql.NullInt64
+	if p.UserID != nil {
+		userID = sql.NullInt64{Int64: *p.UserID, Valid: true}
+	}
+
+	// 直接用 Exec 寫入 login_events，避免再擴充 sqlc schema 太多欄位；
+	// 任務重試時同一個 event_id 已寫入過就略過，last_login_at 仍照常更新（結果相同）
+	_, err := s.sqlDB.ExecContext(ctx, `
+INSERT INTO login_events (
+    user_id,
+    username,
+    success,
+    reason,
+    ip,
+    user_agent,
+    country,
+    event_id,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP
+)
+ON CONFLICT (event_id) DO NOTHING
+`, nullableInt64(userID), p.Username, p.Success, p.Reason, p.IP, p.UserAgent, nullableString(p.Country), nullableString(p.EventID))
+	if err != nil {
+		return err
+	}
+
+	// 登入成功才更新 last_login_at / last_login_ip；使用登入當下的時間，而非任務實際被處理的時間
+	if p.Success && userID.Valid {
+		loggedInAt := p.CreatedAt
+		if loggedInAt.IsZero() {
+			loggedInAt = time.Now()
+		}
+		if err := s.q.UpdateLastLogin(ctx, db.UpdateLastLoginParams{
+			ID:          userID.Int64,
+			LastLoginAt: sql.NullTime{Time: loggedInAt, Valid: true},
+			LastLoginIp: sql.NullString{String: p.IP, Valid: p.IP != ""},
+		}); err != nil {
+			return fmt.Errorf("update last_login_at: %w", err)
+		}
+	}
+	return nil
+}
+
+// FileAuditSink 將每個事件以一行 JSON 附加到檔案（JSON lines），方便交給 log 收集端讀取。
+type FileAuditSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFileAuditSink 以附加模式開啟（必要時建立）path。
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	if path == "" {
+		return nil, errors.New("file path is empty")
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditSink{f: f}, nil
+}
+
+func (s *FileAuditSink) Name() string { return AuditSinkFile }
+
+// Write 先把整行編碼好再一次寫入，避免並行的事件交錯成無效的 JSON。
+func (s *FileAuditSink) Write(ctx context.Context, p infra.LoginAuditPayload) error {
+	line, err := auditJSON(p)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(line)
+	return err
+}
+
+func (s *FileAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// SyslogAuditSink 將事件以 JSON 寫到本機 syslog（facility auth），成功為 info、失敗為 warning。
+type SyslogAuditSink struct {
+	w *syslog.Writer
+}
+
+func NewSyslogAuditSink(tag string) (*SyslogAuditSink, error) {
+	w, err := syslog.New(syslog.LOG_AUTH|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogAuditSink{w: w}, nil
+}
+
+func (s *SyslogAuditSink) Name() string { return AuditSinkSyslog }
+
+func (s *SyslogAuditSink) Write(ctx context.Context, p infra.LoginAuditPayload) error {
+	line, err := auditJSON(p)
+	if err != nil {
+		return err
+	}
+	if p.Success {
+		return s.w.Info(string(line))
+	}
+	return s.w.Warning(string(line))
+}
+
+func (s *SyslogAuditSink) Close() error {
+	return s.w.Close()
+}
+
+// auditJSON 將事件編碼成一行 JSON；未帶 created_at 的舊任務以處理當下的時間補上。
+func auditJSON(p infra.LoginAuditPayload) ([]byte, error) {
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+	p.CreatedAt = p.CreatedAt.UTC()
+	return json.Marshal(p)
+}
@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"bufio"         // 匯入 bufio，逐行讀取 JSON lines 檔案
+	"context"       // 匯入 context，實作測試用 sink
+	"encoding/json" // 匯入 encoding/json，驗證每一行都是有效的 JSON
+	"errors"        // 匯入 errors，模擬 sink 寫入失敗
+	"os"            // 匯入 os，開啟輸出檔案
+	"path/filepath" // 匯入 filepath，組出暫存檔路徑
+	"testing"       // 匯入 testing 套件，提供單元測試框架
+
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra 套件，取得 payload 型別
+)
+
+// failingSink 是每次寫入都失敗的 sink，用來確認其他 sink 不受影響。
+type failingSink struct{ name string }
+
+func (s failingSink) Name() string { return s.name } // 回傳 sink 名稱
+
+func (s failingSink) Write(ctx context.Context, p infra.LoginAuditPayload) error {
+	return errors.New("sink unavailable") // 一律回傳錯誤
+}
+
+// readJSONLines 讀取檔案並將每一行解析成 LoginAuditPayload。
+func readJSONLines(t *testing.T, path string) []infra.LoginAuditPayload {
+	t.Helper() // 標記為測試輔助函式
+
+	f, err := os.Open(path) // 開啟輸出檔案
+	require.NoError(t, err) // 確保開啟成功
+	defer f.Close()         // 讀完後關閉
+
+	var events []infra.LoginAuditPayload
+	scanner := bufio.NewScanner(f) // 逐行掃描
+	for scanner.Scan() {
+		var p infra.LoginAuditPayload
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &p)) // 每一行都必須是有效的 JSON
+		events = append(events, p)                              // 收集解析結果
+	}
+	require.NoError(t, scanner.Err()) // 掃描不應出錯
+	return events
+}
+
+func TestFileAuditSinkWritesJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl") // 暫存檔路徑
+
+	sink, err := NewFileAuditSink(path) // 建立 file sink
+	require.NoError(t, err)             // 確保建立成功
+
+	userID := int64(7) // 測試用 user id
+	require.NoError(t, sink.Write(context.Background(), infra.LoginAuditPayload{
+		UserID:    &userID,
+		Username:  "alice",
+		Success:   true,
+		Reason:    "ok",
+		UserAgent: "agent with \"quotes\"\nand newline", // 特殊字元必須被跳脫，不能拆成多行
+	}))
+	require.NoError(t, sink.Write(context.Background(), auditEvent("bob"))) // 第二筆事件
+	require.NoError(t, sink.Close())                                        // 關閉檔案
+
+	events := readJSONLines(t, path)                                            // 讀回所有行
+	require.Len(t, events, 2)                                                   // 每個事件一行
+	require.Equal(t, "alice", events[0].Username)                               // 保留原本順序
+	require.Equal(t, int64(7), *events[0].UserID)                               // user_id 正確
+	require.Equal(t, "agent with \"quotes\"\nand newline", events[0].UserAgent) // user agent 原樣還原
+	require.False(t, events[0].CreatedAt.IsZero())                              // 沒帶時間的事件會補上 created_at
+	require.Equal(t, "bob", events[1].Username)                                 // 第二筆事件
+
+	// 重新開啟時以附加模式寫入，不會覆蓋舊資料
+	sink, err = NewFileAuditSink(path)                                        // 再次開啟同一個檔案
+	require.NoError(t, err)                                                   // 確保開啟成功
+	require.NoError(t, sink.Write(context.Background(), auditEvent("carol"))) // 寫入第三筆
+	require.NoError(t, sink.Close())                                          // 關閉檔案
+	require.Len(t, readJSONLines(t, path), 3)                                 // 三筆都在
+}
+
+func TestHandleLoginAuditSinkFailureDoesNotBlockOthers(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl") // 暫存檔路徑
+	fileSink, err := NewFileAuditSink(path)           // 建立 file sink
+	require.NoError(t, err)                           // 確保建立成功
+
+	env.handlers.WithAuditSinks([]AuditSink{failingSink{name: AuditSinkSyslog}, env.handlers.SQLiteAuditSink(), fileSink}) // 第一個 sink 會失敗
+
+	err = env.handlers.HandleLoginAudit(env.ctx, newTask(t, infra.TaskTypeLoginAudit, auditEvent("alice")))
+	require.NoError(t, err)                       // 非 sqlite 的 sink 失敗不讓任務重試
+	require.NoError(t, fileSink.Close())          // 關閉檔案
+	require.Equal(t, 1, countLoginEvents(t, env)) // sqlite 仍寫入
+	require.Len(t, readJSONLines(t, path), 1)     // file 仍寫入
+
+	env.handlers.WithAuditSinks([]AuditSink{failingSink{name: AuditSinkSQLite}}) // 模擬 sqlite 寫入失敗
+	err = env.handlers.HandleLoginAudit(env.ctx, newTask(t, infra.TaskTypeLoginAudit, auditEvent("bob")))
+	require.Error(t, err) // sqlite 失敗時回傳錯誤讓 asynq 重試
+}
+
+func TestOpenAuditSinksRejectsUnknown(t *testing.T) {
+	_, err := OpenAuditSinks([]string{"kafka"}, nil, AuditSinkOptions{}) // 不支援的 sink
+	require.Error(t, err)                                                // 應回傳錯誤
+}
+
+// TestHandleLoginAuditSQLiteFailureSkipsOtherOutputs 測試 sqlite 寫入失敗時不呼叫其他 sink 也不做 travel 分析，重試成功後才各輸出一次。
+func TestHandleLoginAuditSQLiteFailureSkipsOtherOutputs(t *testing.T) {
+	env := newTestEnv(t)                           // 建立測試環境
+	env.handlers.WithImpossibleTravel(1000, false) // 啟用 travel 分析
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl") // 暫存檔路徑
+	fileSink, err := NewFileAuditSink(path)           // 建立 file sink
+	require.NoError(t, err)                           // 確保建立成功
+
+	uid := int64(42)                                                                                               // 登入的使用者
+	ev := infra.LoginAuditPayload{UserID: &uid, Username: "alice", Success: true, Country: "GB", EventID: "evt-1"} // 成功登入事件
+	task := newTask(t, infra.TaskTypeLoginAudit, ev)                                                               // 同一個任務會被重試
+	env.handlers.WithAuditSinks([]AuditSink{fileSink, failingSink{name: AuditSinkSQLite}})                         // sqlite 寫入失敗
+	require.Error(t, env.handlers.HandleLoginAudit(env.ctx, task))                                                 // 回傳錯誤讓 asynq 重試
+	exists, err := env.rdb.Exists(env.ctx, infra.LastLoginGeoKey(uid)).Result()                                    // travel 分析會寫入上一次登入的位置
+	require.NoError(t, err)                                                                                        // 查詢應成功
+	require.EqualValues(t, 0, exists)                                                                              // sqlite 失敗時不做 travel 分析
+
+	env.handlers.WithAuditSinks([]AuditSink{fileSink, env.handlers.SQLiteAuditSink()}) // sqlite 恢復
+	require.NoError(t, env.handlers.HandleLoginAudit(env.ctx, task))                   // 重試成功
+	require.NoError(t, fileSink.Close())                                               // 關閉檔案
+
+	require.Len(t, readJSONLines(t, path), 1)                                  // file 只寫入一次
+	require.Equal(t, 1, countLoginEvents(t, env))                              // sqlite 寫入一次
+	exists, err = env.rdb.Exists(env.ctx, infra.LastLoginGeoKey(uid)).Result() // 重試成功後才做 travel 分析
+	require.NoError(t, err)                                                    // 查詢應成功
+	require.EqualValues(t, 1, exists)                                          // 已記錄這次登入的位置
+}
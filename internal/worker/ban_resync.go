@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"context"
+	"log"
+
+	"github.com/hibiken/asynq"
+
+	"sessionservice/internal/infra"
+)
+
+// HandleBanResync 處理 ban:resync：依 users.is_banned 重新建立 Redis 的 banned_user:{uid} 旗標。
+// Redis 被清空或還原時旗標會遺失；Login 仍會檢查 DB 的 is_banned，但其他只看 Redis 旗標的檢查要等這個任務補回。
+// 只補建旗標，不刪除多出來的旗標（解封時由 UnbanUser 刪除）。
+func (h *Handlers) HandleBanResync(ctx context.Context, _ *asynq.Task) error {
+	ids, err := h.q.ListBannedUsers(ctx)
+	if err != nil {
+		log.Printf("%s: list banned users error: %v", infra.TaskTypeBanResync, err)
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	pipe := h.rdb.Pipeline()
+	for _, id := range ids {
+		pipe.Set(ctx, infra.BannedUserKey(id), "1", 0)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("%s: redis error: %v", infra.TaskTypeBanResync, err)
+		return err
+	}
+	log.Printf("%s: restored %d ban flags", infra.TaskTypeBanResync, len(ids))
+	return nil
+}
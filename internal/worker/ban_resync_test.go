@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"testing" // 匯入 testing 套件，提供單元測試框架
+
+	"github.com/hibiken/asynq"            // 匯入 asynq，建立測試用任務
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/db"    // 匯入 db 套件，建立使用者
+	"sessionservice/internal/infra" // 匯入 infra 套件，取得任務類型與 Redis key
+)
+
+// TestHandleBanResync 測試 Redis 被清空後，ban:resync 依 DB 的 is_banned 補回旗標，未被 ban 的使用者不受影響。
+func TestHandleBanResync(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	banned, err := env.q.CreateUser(env.ctx, db.CreateUserParams{Username: "mallory", PasswordHash: "x"}) // 建立要被 ban 的使用者
+	require.NoError(t, err)                                                                               // 應建立成功
+	normal, err := env.q.CreateUser(env.ctx, db.CreateUserParams{Username: "alice", PasswordHash: "x"})   // 建立一般使用者
+	require.NoError(t, err)                                                                               // 應建立成功
+	require.NoError(t, env.q.BanUser(env.ctx, banned.ID))                                                 // DB 標記為 ban
+	require.NoError(t, env.rdb.Set(env.ctx, infra.BannedUserKey(banned.ID), "1", 0).Err())                // Redis 原本有旗標
+
+	env.mr.FlushAll()                                               // 模擬 Redis 被清空
+	require.False(t, env.mr.Exists(infra.BannedUserKey(banned.ID))) // 旗標已遺失
+
+	task := asynq.NewTask(infra.TaskTypeBanResync, nil)             // 建立 ban:resync 任務
+	require.NoError(t, env.handlers.HandleBanResync(env.ctx, task)) // 執行任務
+	require.True(t, env.mr.Exists(infra.BannedUserKey(banned.ID)))  // 被 ban 的使用者旗標已補回
+	require.False(t, env.mr.Exists(infra.BannedUserKey(normal.ID))) // 一般使用者沒有旗標
+}
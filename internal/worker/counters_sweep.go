@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"log"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/infra"
+)
+
+// countersSweepScanBatch 是 counters:sweep 每次 SCAN 的 COUNT。
+const countersSweepScanBatch = 100
+
+// deleteIfNoTTL 在同一個指令內重新確認 key 仍是沒有 TTL 的字串才刪除，
+// 避免 SCAN 之後該 key 已過期並被重新建立（帶 TTL）的計數器被誤刪。
+var deleteIfNoTTL = redis.NewScript(`
+if redis.call("TYPE", KEYS[1]).ok == "string" and redis.call("TTL", KEYS[1]) == -1 then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// HandleCountersSweep 處理 counters:sweep：SCAN infra.CounterKeyPatterns 下的 key，刪除沒有 TTL 的計數器。
+// 只處理字串型別且 TTL 為 -1 的 key，帶 TTL 的計數器一律保留，由 Redis 自行過期。
+// INCR 與 EXPIRE 之間剛好被刪除時，該計數器只會從 0 重新計算一個視窗。
+func (h *Handlers) HandleCountersSweep(ctx context.Context, _ *asynq.Task) error {
+	removed := 0
+	for _, pattern := range infra.CounterKeyPatterns() {
+		iter := h.rdb.Scan(ctx, 0, pattern, countersSweepScanBatch).Iterator()
+		for iter.Next(ctx) {
+			n, err := deleteIfNoTTL.Run(ctx, h.rdb, []string{iter.Val()}).Int()
+			if err != nil {
+				log.Printf("%s: redis error: %v", infra.TaskTypeCountersSweep, err)
+				return err
+			}
+			removed += n
+		}
+		if err := iter.Err(); err != nil {
+			log.Printf("%s: scan %s error: %v", infra.TaskTypeCountersSweep, pattern, err)
+			return err
+		}
+	}
+	if removed > 0 {
+		log.Printf("%s: removed %d counters without TTL", infra.TaskTypeCountersSweep, removed)
+	}
+	return nil
+}
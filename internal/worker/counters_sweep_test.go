@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"testing" // 匯入 testing 套件，提供單元測試框架
+	"time"    // 匯入 time 套件，設定計數器 TTL
+
+	"github.com/hibiken/asynq"            // 匯入 asynq，建立測試用任務
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/infra" // 匯入 infra 套件，取得任務類型與 Redis key
+)
+
+// TestHandleCountersSweep 測試 counters:sweep 只刪除沒有 TTL 的計數器，帶 TTL 的計數器與其他 namespace 的 key 不受影響。
+func TestHandleCountersSweep(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	orphan := infra.RateLimitKey("login_user", "1")                                // 遺失 TTL 的計數器
+	live := infra.RateLimitKey("login_user", "2")                                  // 仍在視窗內的計數器
+	orphanAdmin := infra.AdminAuthFailKey("203.0.113.1")                           // 遺失 TTL 的 admin 驗證失敗計數器
+	require.NoError(t, env.rdb.Set(env.ctx, orphan, "3", 0).Err())                 // 寫入沒有 TTL 的計數器
+	require.NoError(t, env.rdb.Set(env.ctx, live, "3", time.Minute).Err())         // 寫入帶 TTL 的計數器
+	require.NoError(t, env.rdb.Set(env.ctx, orphanAdmin, "5", 0).Err())            // 寫入沒有 TTL 的 admin 計數器
+	require.NoError(t, env.rdb.Set(env.ctx, infra.BannedUserKey(1), "1", 0).Err()) // 其他 namespace 的永久 key
+
+	task := asynq.NewTask(infra.TaskTypeCountersSweep, nil)             // 建立 counters:sweep 任務
+	require.NoError(t, env.handlers.HandleCountersSweep(env.ctx, task)) // 執行任務
+	require.False(t, env.mr.Exists(orphan))                             // 沒有 TTL 的計數器已刪除
+	require.False(t, env.mr.Exists(orphanAdmin))                        // 沒有 TTL 的 admin 計數器已刪除
+	require.True(t, env.mr.Exists(live))                                // 帶 TTL 的計數器保留
+	require.True(t, env.mr.TTL(live) > 0)                               // TTL 未被改動
+	require.True(t, env.mr.Exists(infra.BannedUserKey(1)))              // 其他 namespace 不受影響
+}
@@ -0,0 +1,224 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/db"
+	"sessionservice/internal/infra"
+)
+
+// Handlers 收攏 Asynq worker 的任務處理邏輯，讓 cmd/worker 只負責組裝與啟動。
+type Handlers struct {
+	sqlDB *sql.DB
+	q     *db.Queries
+	rdb   *redis.Client
+
+	// auditBatcher 非 nil 時，login:audit 改為暫存後批次寫入
+	auditBatcher *AuditBatcher
+
+	// auditSinks 為 nil 時 login:audit 只寫入 sqlite
+	auditSinks []AuditSink
+
+	// impossible travel 分析設定，travelMaxKmh <= 0 代表關閉
+	travelMaxKmh int
+	travelKick   bool
+
+	// asynqClient 為 nil 時，session:expire 遇到已延長的 session 不會改排到新的到期時間
+	asynqClient *asynq.Client
+}
+
+func NewHandlers(sqlDB *sql.DB, q *db.Queries, rdb *redis.Client) *Handlers {
+	return &Handlers{
+		sqlDB: sqlDB,
+		q:     q,
+		rdb:   rdb,
+	}
+}
+
+// WithAsynqClient 設定排入後續任務用的 client。
+func (h *Handlers) WithAsynqClient(client *asynq.Client) *Handlers {
+	h.asynqClient = client
+	return h
+}
+
+// WithAuditBatcher 讓 login:audit 改由 AuditBatcher 批次寫入 login_events。
+func (h *Handlers) WithAuditBatcher(b *AuditBatcher) *Handlers {
+	h.auditBatcher = b
+	return h
+}
+
+// WithAuditSinks 設定 login:audit 要輸出的 sink（由 OpenAuditSinks 建立）。
+func (h *Handlers) WithAuditSinks(sinks []AuditSink) *Handlers {
+	h.auditSinks = sinks
+	return h
+}
+
+// Register 將所有任務類型註冊到 mux。
+func (h *Handlers) Register(mux *asynq.ServeMux) {
+	mux.HandleFunc(infra.TaskTypeSessionExpire, h.HandleSessionExpire)
+	mux.HandleFunc(infra.TaskTypeSessionRecord, h.HandleSessionRecord)
+	mux.HandleFunc(infra.TaskTypeSessionReconcile, h.HandleSessionReconcile)
+	mux.HandleFunc(infra.TaskTypeLoginAudit, h.HandleLoginAudit)
+	mux.HandleFunc(infra.TaskTypeAdminAuthFailureNotify, h.HandleAdminAuthFailureNotify)
+	mux.HandleFunc(infra.TaskTypeBanResync, h.HandleBanResync)
+	mux.HandleFunc(infra.TaskTypeCountersSweep, h.HandleCountersSweep)
+	mux.HandleFunc(infra.TaskTypeEmailSend, h.HandleEmailSend)
+}
+
+// HandleSessionExpire 處理 session:expire：清掉 Redis 中仍存在的 session，並在 DB 標記 revoked。
+func (h *Handlers) HandleSessionExpire(ctx context.Context, t *asynq.Task) error {
+	var p infra.SessionExpirePayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		log.Printf("session:expire: invalid payload: %v", err)
+		return err
+	}
+
+	sessKey := infra.SessKey(p.SessionID)
+	userSessKey := infra.UserSessKey(p.UserID)
+
+	// 檢查 Redis 是否仍有該 session
+	data, err := h.rdb.HGetAll(ctx, sessKey).Result()
+	if err != nil && err != redis.Nil {
+		log.Printf("session:expire: redis HGetAll error: %v request_id=%s", err, p.RequestID)
+		return err
+	}
+	if len(data) == 0 {
+		// 已不存在，可能已手動 logout 或被踢，視為完成
+		return nil
+	}
+
+	// session 已被延長（滑動 refresh 或 admin extend）：延長時不會另外排任務，由這裡改排到新的到期時間，
+	// 每個 session 同時只留一個排程中的 session:expire 任務
+	if exp, err := strconv.ParseInt(data["expires_at"], 10, 64); err == nil && exp > time.Now().Unix() {
+		return h.rescheduleSessionExpire(ctx, p, exp)
+	}
+
+	pipe := h.rdb.TxPipeline()
+	pipe.Del(ctx, sessKey)
+	pipe.ZRem(ctx, userSessKey, p.SessionID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("session:expire: redis cleanup error: %v request_id=%s", err, p.RequestID)
+		return err
+	}
+	_ = infra.PublishSessionsChanged(ctx, h.rdb, p.UserID)
+
+	// 更新 DB sessions.revoked_at / revoked_by
+	if err := h.q.RevokeSession(ctx, db.RevokeSessionParams{
+		ID:        p.SessionID,
+		RevokedBy: infra.RevokedByExpire.NullString(),
+	}); err != nil {
+		log.Printf("session:expire: db revoke error: %v request_id=%s", err, p.RequestID)
+		return err
+	}
+
+	return nil
+}
+
+// rescheduleSessionExpire 為已延長的 session 在新的到期時間排入 session:expire，並記錄在 expire_task_at。
+func (h *Handlers) rescheduleSessionExpire(ctx context.Context, p infra.SessionExpirePayload, exp int64) error {
+	if h.asynqClient == nil {
+		return nil
+	}
+	if err := infra.EnqueueSessionExpire(ctx, h.asynqClient, p.SessionID, p.UserID, time.Unix(exp, 0)); err != nil {
+		log.Printf("session:expire: reschedule error: %v request_id=%s", err, p.RequestID)
+		return err
+	}
+	// 寫入失敗只影響下次延長時是否多排一個任務
+	_ = infra.RecordSessionExpireTask(ctx, h.rdb, p.SessionID, time.Unix(exp, 0))
+	return nil
+}
+
+// HandleAdminAuthFailureNotify 處理 notify:admin_auth_failure：目前以告警 log 輸出，交給 log 收集端觸發通知。
+func (h *Handlers) HandleAdminAuthFailureNotify(ctx context.Context, t *asynq.Task) error {
+	var p infra.AdminAuthFailurePayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		log.Printf("notify:admin_auth_failure: invalid payload: %v", err)
+		return err
+	}
+
+	log.Printf("ALERT admin auth failures: ip=%s route=%s failures=%d window=%s at=%s request_id=%s",
+		p.IP, p.Route, p.Failures, p.Window, p.CreatedAt.Format(time.RFC3339), p.RequestID)
+	return nil
+}
+
+// HandleEmailSend 處理 email:send：目前尚未串接寄信服務，只記錄收件者與主旨；接上 SMTP 或寄信 API 時改在這裡送出。
+// Body 含有一次性登入連結，不寫進 log。
+func (h *Handlers) HandleEmailSend(ctx context.Context, t *asynq.Task) error {
+	var p infra.EmailSendPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		log.Printf("email:send: invalid payload: %v", err)
+		return err
+	}
+
+	log.Printf("email:send: to=%s subject=%q request_id=%s", p.To, p.Subject, p.RequestID)
+	return nil
+}
+
+// HandleLoginAudit 處理 login:audit：交給各個 audit sink 輸出（預設只有 sqlite），
+// 並在啟用時與上一次登入的國家比對是否為 impossible travel。
+//
+// 只有 sqlite 失敗會回傳錯誤讓 asynq 重試，因為 login_events 是正式紀錄。為了讓重試不造成重複輸出，
+// sqlite 先寫入，成功後才呼叫 file / syslog sink 與 impossible travel 分析；sqlite 失敗時兩者都不執行，
+// 留給重試（login_events.event_id 讓重試不會重複寫入 sqlite）。file / syslog 失敗只記 log，互不影響。
+func (h *Handlers) HandleLoginAudit(ctx context.Context, t *asynq.Task) error {
+	var p infra.LoginAuditPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		log.Printf("login:audit: invalid payload: %v", err)
+		return err
+	}
+
+	sinks := h.auditSinks
+	if sinks == nil {
+		sinks = []AuditSink{h.SQLiteAuditSink()}
+	}
+
+	for _, sink := range sinks {
+		if sink.Name() != AuditSinkSQLite {
+			continue
+		}
+		if err := sink.Write(ctx, p); err != nil {
+			log.Printf("login:audit: %s sink error: %v request_id=%s", sink.Name(), err, p.RequestID)
+			return err
+		}
+	}
+
+	for _, sink := range sinks {
+		if sink.Name() == AuditSinkSQLite {
+			continue
+		}
+		if err := sink.Write(ctx, p); err != nil {
+			log.Printf("login:audit: %s sink error: %v request_id=%s", sink.Name(), err, p.RequestID)
+		}
+	}
+
+	h.analyzeTravel(ctx, p)
+	return nil
+}
+
+// SQLiteAuditSink 回傳寫入 login_events 的 sink；需在 WithAuditBatcher 之後呼叫才會使用批次寫入。
+func (h *Handlers) SQLiteAuditSink() AuditSink {
+	return &sqliteAuditSink{sqlDB: h.sqlDB, q: h.q, batcher: h.auditBatcher}
+}
+
+func nullableInt64(v sql.NullInt64) interface{} {
+	if v.Valid {
+		return v.Int64
+	}
+	return nil
+}
+
+// nullableString 將空字串寫成 NULL，讓沒有資料的欄位與舊資料一致。
+func nullableString(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
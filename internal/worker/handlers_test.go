@@ -0,0 +1,254 @@
+package worker
+
+import (
+	"context"       // 匯入 context，傳給 handler 與 DB 操作
+	"database/sql"  // 匯入 database/sql，建立測試用 SQLite 連線
+	"encoding/json" // 匯入 encoding/json，組出任務 payload
+	"os"            // 匯入 os，用於讀取 migration 檔案內容
+	"strconv"       // 匯入 strconv，比對 Redis 中的排程時間
+	"testing"       // 匯入 testing 套件，提供單元測試框架
+	"time"          // 匯入 time，設定登入時間
+
+	"github.com/alicebob/miniredis/v2"    // 匯入 miniredis，提供記憶體內 Redis 測試實例
+	"github.com/hibiken/asynq"            // 匯入 asynq，建立測試用任務
+	"github.com/redis/go-redis/v9"        // 匯入 go-redis，用於連線到 miniredis
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/db"    // 匯入 db 套件，建立 sqlc Queries
+	"sessionservice/internal/infra" // 匯入 infra 套件，取得 payload 型別與 Redis key
+
+	_ "modernc.org/sqlite" // 匯入 modernc sqlite driver
+)
+
+// testEnv 封裝 worker handler 測試所需的周邊資源。
+type testEnv struct {
+	ctx      context.Context      // 測試共用的背景 context
+	sqlDB    *sql.DB              // SQLite 連線
+	q        *db.Queries          // sqlc Queries
+	rdb      *redis.Client        // 連線到 miniredis 的 Redis client
+	mr       *miniredis.Miniredis // miniredis 實例
+	handlers *Handlers            // 被測試的 Handlers
+}
+
+// newTestEnv 建立 SQLite（套用 migrations）、miniredis 與 Handlers。
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper() // 標記為測試輔助函式
+
+	sqlDB, err := sql.Open("sqlite", ":memory:") // 建立記憶體內 SQLite DB
+	require.NoError(t, err)                      // 確保開啟成功
+	sqlDB.SetMaxOpenConns(1)                     // :memory: 每條連線各自一份 DB，限制單一連線
+
+	applyMigrations(t, sqlDB) // 套用所有 migration
+
+	mr, err := miniredis.Run() // 啟動記憶體內 Redis
+	require.NoError(t, err)    // 確保啟動成功
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()}) // 連線到 miniredis
+	q := db.New(sqlDB)                                      // 建立 sqlc Queries
+
+	t.Cleanup(func() { // 測試結束時釋放資源
+		_ = sqlDB.Close() // 關閉 SQLite
+		rdb.Close()       // 關閉 Redis client
+		mr.Close()        // 關閉 miniredis
+	})
+
+	return &testEnv{
+		ctx:      context.Background(),
+		sqlDB:    sqlDB,
+		q:        q,
+		rdb:      rdb,
+		mr:       mr,
+		handlers: NewHandlers(sqlDB, q, rdb),
+	}
+}
+
+// applyMigrations 將 db/migrations 目錄下的所有 *.up.sql 依序套用到指定 DB。
+func applyMigrations(t *testing.T, sqlDB *sql.DB) {
+	t.Helper()                  // 標記為測試輔助函式
+	migrationFiles := []string{ // 與正式環境相同順序的 migration 檔案
+		"../../db/migrations/001_init.up.sql",
+		"../../db/migrations/002_add_sessions.up.sql",
+		"../../db/migrations/003_add_login_events.up.sql",
+		"../../db/migrations/004_add_user_ban.up.sql",
+		"../../db/migrations/005_add_user_last_login.up.sql",
+		"../../db/migrations/006_add_user_needs_rehash.up.sql",
+		"../../db/migrations/007_add_user_must_reset_password.up.sql",
+		"../../db/migrations/008_add_username_changes.up.sql",
+		"../../db/migrations/009_add_user_password_peppered.up.sql",
+		"../../db/migrations/010_add_user_deleted_at.up.sql",
+		"../../db/migrations/011_add_login_events_country.up.sql",
+		"../../db/migrations/012_add_user_password_changed_at.up.sql",
+		"../../db/migrations/013_add_sessions_revoked_by_check.up.sql",
+		"../../db/migrations/014_add_user_email.up.sql",
+		"../../db/migrations/015_add_user_last_login_ip.up.sql",
+		"../../db/migrations/016_add_revoked_by_session_gone.up.sql",
+		"../../db/migrations/017_add_user_roles.up.sql",
+		"../../db/migrations/018_normalize_usernames.up.sql",
+		"../../db/migrations/019_add_login_events_event_id.up.sql",
+	}
+
+	for _, path := range migrationFiles { // 逐一套用
+		data, err := os.ReadFile(path)                                 // 讀取 SQL 檔案內容
+		require.NoErrorf(t, err, "failed to read migration %s", path)  // 讀取失敗則中止
+		_, err = sqlDB.Exec(string(data))                              // 執行 SQL
+		require.NoErrorf(t, err, "failed to apply migration %s", path) // 確保套用成功
+	}
+}
+
+// newTask 將 payload 序列化後包成 asynq.Task。
+func newTask(t *testing.T, taskType string, payload interface{}) *asynq.Task {
+	t.Helper()                         // 標記為測試輔助函式
+	data, err := json.Marshal(payload) // 序列化 payload
+	require.NoError(t, err)            // 確保序列化成功
+	return asynq.NewTask(taskType, data)
+}
+
+// TestHandleLoginAuditUpdatesLastLogin 測試登入成功的 audit 任務會寫入 login_events 並推進 last_login_at。
+func TestHandleLoginAuditUpdatesLastLogin(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	user, err := env.q.CreateUser(env.ctx, db.CreateUserParams{Username: "alice", PasswordHash: "x"}) // 建立使用者
+	require.NoError(t, err)                                                                           // 確保建立成功
+	require.False(t, user.LastLoginAt.Valid)                                                          // 新使用者尚未登入過
+
+	first := time.Now().Add(-time.Hour).UTC().Truncate(time.Second) // 第一次登入時間
+	err = env.handlers.HandleLoginAudit(env.ctx, newTask(t, infra.TaskTypeLoginAudit, infra.LoginAuditPayload{
+		UserID:    &user.ID,
+		Username:  "alice",
+		Success:   true,
+		Reason:    "ok",
+		CreatedAt: first,
+	}))
+	require.NoError(t, err) // 任務處理應成功
+
+	got, err := env.q.GetUserByID(env.ctx, user.ID)    // 重新讀取使用者
+	require.NoError(t, err)                            // 查詢應成功
+	require.True(t, got.LastLoginAt.Valid)             // last_login_at 應被設定
+	require.True(t, got.LastLoginAt.Time.Equal(first)) // 應等於登入當下的時間
+
+	second := first.Add(30 * time.Minute) // 第二次登入時間
+	err = env.handlers.HandleLoginAudit(env.ctx, newTask(t, infra.TaskTypeLoginAudit, infra.LoginAuditPayload{
+		UserID:    &user.ID,
+		Username:  "alice",
+		Success:   true,
+		Reason:    "ok",
+		IP:        "203.0.113.7",
+		CreatedAt: second,
+	}))
+	require.NoError(t, err) // 任務處理應成功
+
+	got, err = env.q.GetUserByID(env.ctx, user.ID)          // 再次讀取使用者
+	require.NoError(t, err)                                 // 查詢應成功
+	require.True(t, got.LastLoginAt.Time.After(first))      // last_login_at 應往後推進
+	require.True(t, got.LastLoginAt.Time.Equal(second))     // 並等於第二次登入時間
+	require.Equal(t, "203.0.113.7", got.LastLoginIp.String) // last_login_ip 為第二次登入的來源 IP
+
+	var cnt int64                                                                            // 用於接收 login_events 筆數
+	err = env.sqlDB.QueryRowContext(env.ctx, "SELECT COUNT(*) FROM login_events").Scan(&cnt) // 查詢稽核紀錄數
+	require.NoError(t, err)                                                                  // 查詢應成功
+	require.EqualValues(t, 2, cnt)                                                           // 兩次登入各一筆
+}
+
+// TestHandleLoginAuditFailureKeepsLastLogin 測試登入失敗的 audit 任務不會更新 last_login_at。
+func TestHandleLoginAuditFailureKeepsLastLogin(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	user, err := env.q.CreateUser(env.ctx, db.CreateUserParams{Username: "bob", PasswordHash: "x"}) // 建立使用者
+	require.NoError(t, err)                                                                         // 確保建立成功
+
+	err = env.handlers.HandleLoginAudit(env.ctx, newTask(t, infra.TaskTypeLoginAudit, infra.LoginAuditPayload{
+		UserID:   &user.ID,
+		Username: "bob",
+		Success:  false,
+		Reason:   "wrong_password",
+	}))
+	require.NoError(t, err) // 任務處理應成功
+
+	got, err := env.q.GetUserByID(env.ctx, user.ID) // 重新讀取使用者
+	require.NoError(t, err)                         // 查詢應成功
+	require.False(t, got.LastLoginAt.Valid)         // 失敗登入不應更新 last_login_at
+}
+
+// TestHandleSessionExpire 測試 session:expire 會清掉 Redis 中的 session 並在 DB 標記 system:expire。
+func TestHandleSessionExpire(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	user, err := env.q.CreateUser(env.ctx, db.CreateUserParams{Username: "carol", PasswordHash: "x"}) // 建立使用者
+	require.NoError(t, err)                                                                           // 確保建立成功
+
+	now := time.Now()                                                                                                        // 建立時間
+	err = env.q.CreateSession(env.ctx, db.CreateSessionParams{ID: "sid-1", UserID: user.ID, CreatedAt: now, ExpiresAt: now}) // 建立 DB session
+	require.NoError(t, err)                                                                                                  // 確保建立成功
+	require.NoError(t, env.rdb.HSet(env.ctx, infra.SessKey("sid-1"), "user_id", user.ID).Err())                              // 寫入 Redis session hash
+	require.NoError(t, env.rdb.ZAdd(env.ctx, infra.UserSessKey(user.ID), redis.Z{Score: 1, Member: "sid-1"}).Err())          // 寫入 user_sess zset
+
+	err = env.handlers.HandleSessionExpire(env.ctx, newTask(t, infra.TaskTypeSessionExpire, infra.SessionExpirePayload{SessionID: "sid-1", UserID: user.ID}))
+	require.NoError(t, err) // 任務處理應成功
+
+	exists, err := env.rdb.Exists(env.ctx, infra.SessKey("sid-1")).Result() // 檢查 sess hash
+	require.NoError(t, err)                                                 // 操作應成功
+	require.EqualValues(t, 0, exists)                                       // 應已被刪除
+
+	var revokedBy sql.NullString                                                                                       // 用來接收 revoked_by 欄位
+	err = env.sqlDB.QueryRowContext(env.ctx, "SELECT revoked_by FROM sessions WHERE id = ?", "sid-1").Scan(&revokedBy) // 查詢 revoked_by
+	require.NoError(t, err)                                                                                            // 查詢應成功
+	require.Equal(t, "system:expire", revokedBy.String)                                                                // 應標記為 system:expire
+}
+
+// TestHandleSessionExpireSkipsExtended 測試 session 被延長後，舊的 session:expire 任務不會提早清掉它。
+func TestHandleSessionExpireSkipsExtended(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	later := time.Now().Add(time.Hour).Unix()                                                                  // 延長後的到期時間
+	require.NoError(t, env.rdb.HSet(env.ctx, infra.SessKey("sid-2"), "user_id", 1, "expires_at", later).Err()) // 寫入已延長的 session
+	err := env.handlers.HandleSessionExpire(env.ctx, newTask(t, infra.TaskTypeSessionExpire, infra.SessionExpirePayload{SessionID: "sid-2", UserID: 1}))
+	require.NoError(t, err) // 任務處理應成功
+
+	exists, err := env.rdb.Exists(env.ctx, infra.SessKey("sid-2")).Result() // 檢查 sess hash
+	require.NoError(t, err)                                                 // 操作應成功
+	require.EqualValues(t, 1, exists)                                       // 尚未到期，應保留
+}
+
+// TestHandleLoginAuditRetryWritesOnce 測試同一個 login:audit 任務重試時不會重複寫入 login_events。
+func TestHandleLoginAuditRetryWritesOnce(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	ev := auditEvent("alice")                        // 建立事件
+	ev.EventID = "evt-1"                             // 排入任務時產生的 event_id
+	task := newTask(t, infra.TaskTypeLoginAudit, ev) // 建立任務
+	for i := 0; i < 2; i++ {                         // 模擬 asynq 重試同一個任務
+		require.NoError(t, env.handlers.HandleLoginAudit(env.ctx, task)) // 任務處理應成功
+	}
+	require.Equal(t, 1, countLoginEvents(t, env)) // 只寫入一筆
+
+	require.NoError(t, env.handlers.HandleLoginAudit(env.ctx, newTask(t, infra.TaskTypeLoginAudit, auditEvent("alice")))) // 沒有 event_id 的舊任務
+	require.NoError(t, env.handlers.HandleLoginAudit(env.ctx, newTask(t, infra.TaskTypeLoginAudit, auditEvent("alice")))) // 不受唯一索引限制
+	require.Equal(t, 3, countLoginEvents(t, env))                                                                         // 照常寫入
+}
+
+// TestHandleSessionExpireReschedulesExtended 測試 session 被延長後，舊的 session:expire 任務會在新的到期時間改排一個任務。
+func TestHandleSessionExpireReschedulesExtended(t *testing.T) {
+	env := newTestEnv(t)                             // 建立測試環境
+	opt := asynq.RedisClientOpt{Addr: env.mr.Addr()} // asynq 與測試共用 miniredis
+	client := asynq.NewClient(opt)                   // 建立 asynq client
+	defer client.Close()                             // 測試結束時關閉
+	inspector := asynq.NewInspector(opt)             // 建立 inspector 以檢查排入的任務
+	defer inspector.Close()                          // 測試結束時關閉
+	env.handlers.WithAsynqClient(client)             // 讓 handler 可以改排任務
+
+	later := time.Now().Add(time.Hour).Unix()                                                                  // 延長後的到期時間
+	require.NoError(t, env.rdb.HSet(env.ctx, infra.SessKey("sid-3"), "user_id", 1, "expires_at", later).Err()) // 寫入已延長的 session
+	task := newTask(t, infra.TaskTypeSessionExpire, infra.SessionExpirePayload{SessionID: "sid-3", UserID: 1}) // 舊到期時間的任務
+	require.NoError(t, env.handlers.HandleSessionExpire(env.ctx, task))                                        // 任務處理應成功
+
+	tasks, err := inspector.ListScheduledTasks("default")                                                            // 列出排程中的任務
+	require.NoError(t, err)                                                                                          // 查詢應成功
+	require.Len(t, tasks, 1)                                                                                         // 改排一個任務
+	require.Equal(t, infra.SessionExpireTaskID("sid-3", time.Unix(later, 0)), tasks[0].ID)                           // 排在新的到期時間
+	require.Equal(t, strconv.FormatInt(later, 10), env.mr.HGet(infra.SessKey("sid-3"), infra.SessExpireTaskAtField)) // 記錄排程時間
+
+	require.NoError(t, env.handlers.HandleSessionExpire(env.ctx, task)) // 同一個任務重試
+	tasks, err = inspector.ListScheduledTasks("default")                // 再次列出
+	require.NoError(t, err)                                             // 查詢應成功
+	require.Len(t, tasks, 1)                                            // 不會重複排入
+}
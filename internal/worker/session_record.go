@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"sessionservice/internal/db"
+	"sessionservice/internal/infra"
+)
+
+// sessionReconcileScanBatch 是 session:reconcile 每次 SCAN 的 COUNT。
+const sessionReconcileScanBatch = 100
+
+// HandleSessionRecord 處理 session:record：SESSION_RECORD_MODE=async 時補寫登入當下略過的 sessions 紀錄。
+// 寫入以 INSERT OR IGNORE 進行，重試或 reconcile 已先寫入時不會重複。
+// 任務執行前 session 可能已被登出或過期，此時 Redis 已無 sess:{sid}，直接把剛寫入的紀錄標記為撤銷，
+// 避免留下之後可被 SESSION_DB_FALLBACK 復原的 active 紀錄。
+func (h *Handlers) HandleSessionRecord(ctx context.Context, t *asynq.Task) error {
+	var p infra.SessionRecordPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		log.Printf("session:record: invalid payload: %v", err)
+		return err
+	}
+
+	if err := h.q.RecordSession(ctx, db.RecordSessionParams{
+		ID:        p.SessionID,
+		UserID:    p.UserID,
+		CreatedAt: p.CreatedAt,
+		ExpiresAt: p.ExpiresAt,
+	}); err != nil {
+		log.Printf("session:record: db insert error: %v request_id=%s", err, p.RequestID)
+		return err
+	}
+
+	n, err := h.rdb.Exists(ctx, infra.SessKey(p.SessionID)).Result()
+	if err != nil {
+		log.Printf("session:record: redis error: %v request_id=%s", err, p.RequestID)
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	revokedBy := infra.RevokedBySessionGone
+	if !p.ExpiresAt.After(time.Now()) {
+		revokedBy = infra.RevokedByExpire
+	}
+	if err := h.q.RevokeSession(ctx, db.RevokeSessionParams{
+		ID:        p.SessionID,
+		RevokedBy: revokedBy.NullString(),
+	}); err != nil {
+		log.Printf("session:record: db revoke error: %v request_id=%s", err, p.RequestID)
+		return err
+	}
+	return nil
+}
+
+// HandleSessionReconcile 處理 session:reconcile：SCAN Redis 中所有 sess:{sid}，為 sessions 表沒有紀錄的 session 補寫紀錄，
+// 作為 session:record 任務遺失（排入失敗或超過重試次數）時的備援。欄位不完整的 hash 略過不處理。
+func (h *Handlers) HandleSessionReconcile(ctx context.Context, _ *asynq.Task) error {
+	recorded := 0
+	iter := h.rdb.Scan(ctx, 0, infra.SessKeyPattern(), sessionReconcileScanBatch).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		vals, err := h.rdb.HMGet(ctx, key, "user_id", "created_at", "expires_at").Result()
+		if err != nil {
+			log.Printf("%s: redis error: %v", infra.TaskTypeSessionReconcile, err)
+			return err
+		}
+		userID, ok1 := hashInt64(vals[0])
+		createdAt, ok2 := hashInt64(vals[1])
+		expiresAt, ok3 := hashInt64(vals[2])
+		if !ok1 || !ok2 || !ok3 {
+			continue
+		}
+
+		sid, ok := infra.SessIDFromKey(key)
+		if !ok {
+			continue
+		}
+		if _, err := h.q.GetSession(ctx, sid); err == nil {
+			continue
+		} else if err != sql.ErrNoRows {
+			log.Printf("%s: db error: %v", infra.TaskTypeSessionReconcile, err)
+			return err
+		}
+		if err := h.q.RecordSession(ctx, db.RecordSessionParams{
+			ID:        sid,
+			UserID:    userID,
+			CreatedAt: time.Unix(createdAt, 0),
+			ExpiresAt: time.Unix(expiresAt, 0),
+		}); err != nil {
+			log.Printf("%s: db insert error: %v", infra.TaskTypeSessionReconcile, err)
+			return err
+		}
+		recorded++
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("%s: scan error: %v", infra.TaskTypeSessionReconcile, err)
+		return err
+	}
+	if recorded > 0 {
+		log.Printf("%s: recorded %d sessions missing from db", infra.TaskTypeSessionReconcile, recorded)
+	}
+	return nil
+}
+
+// hashInt64 將 HMGET 取回的欄位轉成 int64，欄位不存在或格式錯誤時回傳 false。
+func hashInt64(v interface{}) (int64, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil
+}
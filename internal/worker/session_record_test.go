@@ -0,0 +1,129 @@
+package worker
+
+import (
+	"database/sql" // 匯入 database/sql，讀取 revoked_by 欄位
+	"testing"      // 匯入 testing 套件，提供單元測試框架
+	"time"         // 匯入 time 套件，設定 session 時間
+
+	"github.com/hibiken/asynq"            // 匯入 asynq，取出登入時排入的任務
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/config"  // 匯入 config，設定 async 模式
+	"sessionservice/internal/db"      // 匯入 db 套件，建立使用者
+	"sessionservice/internal/infra"   // 匯入 infra 套件，取得任務類型與 Redis key
+	"sessionservice/internal/session" // 匯入 session，以 async 模式登入
+)
+
+// TestHandleSessionRecord 測試 session:record 會寫入 active 紀錄，且重複執行不會失敗或覆寫。
+func TestHandleSessionRecord(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	user, err := env.q.CreateUser(env.ctx, db.CreateUserParams{Username: "alice", PasswordHash: "x"}) // 建立使用者
+	require.NoError(t, err)                                                                           // 確保建立成功
+	now := time.Now()                                                                                 // 建立時間
+	require.NoError(t, env.rdb.HSet(env.ctx, infra.SessKey("sid-1"), "user_id", user.ID).Err())       // session 仍在 Redis 中
+
+	payload := infra.SessionRecordPayload{SessionID: "sid-1", UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	task := newTask(t, infra.TaskTypeSessionRecord, payload)            // 建立 session:record 任務
+	require.NoError(t, env.handlers.HandleSessionRecord(env.ctx, task)) // 執行任務
+	require.NoError(t, env.handlers.HandleSessionRecord(env.ctx, task)) // 重試也應成功
+
+	row, err := env.q.GetSession(env.ctx, "sid-1") // 查詢 DB 紀錄
+	require.NoError(t, err)                        // 應已寫入
+	require.Equal(t, user.ID, row.UserID)          // 對應的使用者正確
+	require.False(t, row.RevokedAt.Valid)          // 紀錄為 active
+}
+
+// TestHandleSessionRecordSessionGone 測試任務執行前 session 已從 Redis 消失時，紀錄會直接標記為撤銷。
+func TestHandleSessionRecordSessionGone(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	user, err := env.q.CreateUser(env.ctx, db.CreateUserParams{Username: "alice", PasswordHash: "x"}) // 建立使用者
+	require.NoError(t, err)                                                                           // 確保建立成功
+	now := time.Now()                                                                                 // 建立時間
+
+	cases := []struct {
+		sid       string    // session ID
+		expiresAt time.Time // session 到期時間
+		want      string    // 預期的 revoked_by
+	}{
+		{"sid-logout", now.Add(time.Hour), string(infra.RevokedBySessionGone)}, // 未到期即消失（登出、被踢）
+		{"sid-expired", now.Add(-time.Minute), string(infra.RevokedByExpire)},  // 已過期
+	}
+	for _, tc := range cases {
+		payload := infra.SessionRecordPayload{SessionID: tc.sid, UserID: user.ID, CreatedAt: now.Add(-time.Hour), ExpiresAt: tc.expiresAt}
+		require.NoError(t, env.handlers.HandleSessionRecord(env.ctx, newTask(t, infra.TaskTypeSessionRecord, payload))) // 執行任務
+
+		var revokedBy sql.NullString                                                                                      // 用來接收 revoked_by 欄位
+		err = env.sqlDB.QueryRowContext(env.ctx, "SELECT revoked_by FROM sessions WHERE id = ?", tc.sid).Scan(&revokedBy) // 查詢 revoked_by
+		require.NoError(t, err)                                                                                           // 紀錄應已寫入
+		require.Equal(t, tc.want, revokedBy.String)                                                                       // 依到期與否標記原因
+	}
+}
+
+// TestHandleSessionReconcile 測試 session:reconcile 為 Redis 中沒有 DB 紀錄的 session 補寫紀錄，已有紀錄與欄位不完整的 hash 不受影響。
+func TestHandleSessionReconcile(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	user, err := env.q.CreateUser(env.ctx, db.CreateUserParams{Username: "alice", PasswordHash: "x"}) // 建立使用者
+	require.NoError(t, err)                                                                           // 確保建立成功
+	now := time.Now()                                                                                 // 建立時間
+	exp := now.Add(time.Hour)                                                                         // 到期時間
+
+	for _, sid := range []string{"sid-missing", "sid-recorded"} {
+		require.NoError(t, env.rdb.HSet(env.ctx, infra.SessKey(sid), "user_id", user.ID, "created_at", now.Unix(), "expires_at", exp.Unix()).Err()) // 寫入 Redis session
+	}
+	require.NoError(t, env.q.CreateSession(env.ctx, db.CreateSessionParams{ID: "sid-recorded", UserID: user.ID, CreatedAt: now, ExpiresAt: now})) // 已有紀錄的 session
+	require.NoError(t, env.rdb.HSet(env.ctx, infra.SessKey("sid-broken"), "user_id", user.ID).Err())                                              // 欄位不完整的 hash
+
+	require.NoError(t, env.handlers.HandleSessionReconcile(env.ctx, asynq.NewTask(infra.TaskTypeSessionReconcile, nil))) // 執行任務
+
+	row, err := env.q.GetSession(env.ctx, "sid-missing") // 查詢補寫的紀錄
+	require.NoError(t, err)                              // 應已補寫
+	require.Equal(t, user.ID, row.UserID)                // 使用者正確
+	require.Equal(t, exp.Unix(), row.ExpiresAt.Unix())   // 到期時間取自 Redis
+	require.False(t, row.RevokedAt.Valid)                // 紀錄為 active
+
+	row, err = env.q.GetSession(env.ctx, "sid-recorded") // 查詢原有的紀錄
+	require.NoError(t, err)                              // 應仍存在
+	require.Equal(t, now.Unix(), row.ExpiresAt.Unix())   // 未被覆寫
+
+	_, err = env.q.GetSession(env.ctx, "sid-broken") // 欄位不完整的 session
+	require.ErrorIs(t, err, sql.ErrNoRows)           // 不補寫
+}
+
+// TestSessionRecordAsyncLogin 測試 SESSION_RECORD_MODE=async 時，登入排入的 session:record 交給 worker 處理後 DB 仍有紀錄。
+func TestSessionRecordAsyncLogin(t *testing.T) {
+	env := newTestEnv(t) // 建立測試環境
+
+	opt := asynq.RedisClientOpt{Addr: env.mr.Addr()} // asynq 與 session 共用 miniredis
+	client := asynq.NewClient(opt)                   // 建立 asynq client
+	defer client.Close()                             // 測試結束時關閉
+	inspector := asynq.NewInspector(opt)             // 建立 inspector 以取出排入的任務
+	defer inspector.Close()                          // 測試結束時關閉
+	cfg := &config.Config{SessionTTL: time.Hour, MaxSessionsPerUser: 5, SessionRecordMode: session.SessionRecordAsync}
+	sessSvc := session.NewSessionService(env.q, env.rdb, cfg, client, nil) // 以 async 模式建立 SessionService
+
+	_, err := env.q.CreateUser(env.ctx, db.CreateUserParams{Username: "alice", PasswordHash: "x"}) // 建立使用者
+	require.NoError(t, err)                                                                        // 確保建立成功
+	_, sid, _, err := sessSvc.LoginTrusted(env.ctx, "alice", session.LoginMeta{})                  // 登入
+	require.NoError(t, err)                                                                        // 應登入成功
+	_, err = env.q.GetSession(env.ctx, sid)                                                        // 登入當下
+	require.ErrorIs(t, err, sql.ErrNoRows)                                                         // 尚未寫入 DB
+
+	tasks, err := inspector.ListPendingTasks("default") // 列出待處理的任務
+	require.NoError(t, err)                             // 查詢應成功
+	handled := 0
+	for _, info := range tasks {
+		if info.Type != infra.TaskTypeSessionRecord {
+			continue // 只處理 session:record
+		}
+		require.NoError(t, env.handlers.HandleSessionRecord(env.ctx, asynq.NewTask(info.Type, info.Payload))) // 交給 worker 處理
+		handled++
+	}
+	require.Equal(t, 1, handled) // 登入應排入一個 session:record
+
+	row, err := env.q.GetSession(env.ctx, sid) // 查詢 DB 紀錄
+	require.NoError(t, err)                    // 應已寫入
+	require.False(t, row.RevokedAt.Valid)      // 紀錄為 active
+}
@@ -0,0 +1,222 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"sessionservice/internal/db"
+	"sessionservice/internal/infra"
+)
+
+// AnalysisImpossibleTravel 是 impossible travel 告警在 log 中的事件名稱。
+const AnalysisImpossibleTravel = "security:impossible_travel"
+
+// lastLoginGeoTTL 是 last_login_geo:{uid} 的保留時間；超過這段時間沒登入，下次登入不做比對。
+const lastLoginGeoTTL = 30 * 24 * time.Hour
+
+// minTravelDistanceKm 以下的距離不判定：國家中心點之間的距離對相鄰國家誤差太大。
+const minTravelDistanceKm = 500
+
+// ImpossibleTravelAlert 描述同一使用者兩次成功登入之間不可能達成的移動。
+type ImpossibleTravelAlert struct {
+	UserID      int64
+	FromCountry string
+	ToCountry   string
+	DistanceKm  float64
+	Elapsed     time.Duration
+	SpeedKmh    float64
+}
+
+// WithImpossibleTravel 啟用 login:audit 的 impossible travel 分析：兩次成功登入間的移動速度超過 maxKmh 時告警，
+// kick 為 true 時一併踢掉該使用者所有 session。maxKmh <= 0 代表關閉。
+func (h *Handlers) WithImpossibleTravel(maxKmh int, kick bool) *Handlers {
+	h.travelMaxKmh = maxKmh
+	h.travelKick = kick
+	return h
+}
+
+// analyzeTravel 對帶有國家的成功登入做 impossible travel 分析；分析失敗只記 log，不影響 audit 寫入。
+func (h *Handlers) analyzeTravel(ctx context.Context, p infra.LoginAuditPayload) {
+	if h.travelMaxKmh <= 0 || !p.Success || p.UserID == nil || p.Country == "" {
+		return
+	}
+
+	alert, err := h.checkImpossibleTravel(ctx, *p.UserID, p.Country, p.CreatedAt)
+	if err != nil {
+		log.Printf("%s: check error: %v request_id=%s", AnalysisImpossibleTravel, err, p.RequestID)
+		return
+	}
+	if alert == nil {
+		return
+	}
+
+	log.Printf("ALERT %s: user=%d from=%s to=%s distance_km=%.0f elapsed=%s speed_kmh=%.0f request_id=%s",
+		AnalysisImpossibleTravel, alert.UserID, alert.FromCountry, alert.ToCountry, alert.DistanceKm, alert.Elapsed, alert.SpeedKmh, p.RequestID)
+
+	if h.travelKick {
+		if err := h.kickAllSessions(ctx, alert.UserID, infra.RevokedByImpossibleTravel); err != nil {
+			log.Printf("%s: kick error: %v request_id=%s", AnalysisImpossibleTravel, err, p.RequestID)
+		}
+	}
+}
+
+// checkImpossibleTravel 與 last_login_geo:{uid} 記錄的上一次登入比對，並把這次登入寫回作為下次比對的基準。
+// 任一國家不在對照表內、距離太近或速度未超過門檻時回傳 nil。
+func (h *Handlers) checkImpossibleTravel(ctx context.Context, userID int64, country string, at time.Time) (*ImpossibleTravelAlert, error) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	key := infra.LastLoginGeoKey(userID)
+
+	prev, err := h.rdb.HGetAll(ctx, key).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	pipe := h.rdb.TxPipeline()
+	pipe.HSet(ctx, key, "country", country, "at", at.Unix())
+	pipe.Expire(ctx, key, lastLoginGeoTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	prevAt, err := strconv.ParseInt(prev["at"], 10, 64)
+	if err != nil || prev["country"] == "" {
+		return nil, nil
+	}
+	from, ok := countryCentroids[prev["country"]]
+	if !ok {
+		return nil, nil
+	}
+	to, ok := countryCentroids[country]
+	if !ok {
+		return nil, nil
+	}
+
+	distance := haversineKm(from, to)
+	if distance < minTravelDistanceKm {
+		return nil, nil
+	}
+
+	// 登入事件可能亂序送達，以兩次登入的時間差絕對值計算；時間差過小時以一分鐘計，避免除以零
+	elapsed := at.Sub(time.Unix(prevAt, 0))
+	if elapsed < 0 {
+		elapsed = -elapsed
+	}
+	hours := math.Max(elapsed.Hours(), time.Minute.Hours())
+	speed := distance / hours
+	if speed <= float64(h.travelMaxKmh) {
+		return nil, nil
+	}
+
+	return &ImpossibleTravelAlert{
+		UserID:      userID,
+		FromCountry: prev["country"],
+		ToCountry:   country,
+		DistanceKm:  distance,
+		Elapsed:     elapsed,
+		SpeedKmh:    speed,
+	}, nil
+}
+
+// kickAllSessions 刪除使用者在 Redis 的所有 session，並在 sessions 表記錄撤銷原因。
+func (h *Handlers) kickAllSessions(ctx context.Context, userID int64, revokedBy infra.RevokeReason) error {
+	userSessKey := infra.UserSessKey(userID)
+	sessionIDs, err := h.rdb.ZRange(ctx, userSessKey, 0, -1).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	for _, sid := range sessionIDs {
+		pipe := h.rdb.TxPipeline()
+		pipe.Del(ctx, infra.SessKey(sid))
+		pipe.ZRem(ctx, userSessKey, sid)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+		_ = infra.PublishSessionsChanged(ctx, h.rdb, userID)
+		if err := h.q.RevokeSession(ctx, db.RevokeSessionParams{
+			ID:        sid,
+			RevokedBy: revokedBy.NullString(),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// latLon 是以度為單位的經緯度。
+type latLon struct {
+	lat, lon float64
+}
+
+// haversineKm 回傳兩點間的大圓距離（公里）。
+func haversineKm(a, b latLon) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(b.lat - a.lat)
+	dLon := toRad(b.lon - a.lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.lat))*math.Cos(toRad(b.lat))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// countryCentroids 是常見國家（ISO 3166-1 alpha-2）的概略中心點，只用於估算距離。
+// 不在表內的國家不做 impossible travel 判定。
+var countryCentroids = map[string]latLon{
+	"AE": {24.0, 54.0},
+	"AR": {-34.0, -64.0},
+	"AT": {47.3, 13.3},
+	"AU": {-25.0, 134.0},
+	"BE": {50.8, 4.0},
+	"BR": {-10.0, -55.0},
+	"CA": {56.1, -106.3},
+	"CH": {46.8, 8.2},
+	"CL": {-30.0, -71.0},
+	"CN": {35.0, 105.0},
+	"CO": {4.0, -72.0},
+	"CZ": {49.8, 15.5},
+	"DE": {51.2, 10.4},
+	"DK": {56.0, 10.0},
+	"EG": {27.0, 30.0},
+	"ES": {40.0, -4.0},
+	"FI": {64.0, 26.0},
+	"FR": {46.6, 2.2},
+	"GB": {54.0, -2.0},
+	"GR": {39.0, 22.0},
+	"HK": {22.3, 114.2},
+	"ID": {-5.0, 120.0},
+	"IE": {53.0, -8.0},
+	"IL": {31.5, 34.8},
+	"IN": {21.0, 78.0},
+	"IT": {42.8, 12.8},
+	"JP": {36.0, 138.0},
+	"KE": {1.0, 38.0},
+	"KR": {36.5, 127.9},
+	"MX": {23.0, -102.0},
+	"MY": {4.2, 102.0},
+	"NG": {9.1, 8.7},
+	"NL": {52.1, 5.3},
+	"NO": {62.0, 10.0},
+	"NZ": {-41.0, 174.0},
+	"PH": {13.0, 122.0},
+	"PL": {52.0, 19.0},
+	"PT": {39.5, -8.0},
+	"RU": {60.0, 100.0},
+	"SA": {24.0, 45.0},
+	"SE": {62.0, 15.0},
+	"SG": {1.35, 103.8},
+	"TH": {15.0, 100.0},
+	"TR": {39.0, 35.0},
+	"TW": {23.7, 121.0},
+	"UA": {49.0, 32.0},
+	"US": {39.8, -98.6},
+	"VN": {16.0, 107.8},
+	"ZA": {-29.0, 24.0},
+}
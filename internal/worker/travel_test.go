@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"database/sql" // 匯入 database/sql，讀取撤銷原因
+	"testing"      // 匯入 testing 套件，提供單元測試框架
+	"time"         // 匯入 time，設定兩次登入的時間差
+
+	"github.com/redis/go-redis/v9"        // 匯入 go-redis，寫入 user_sess zset
+	"github.com/stretchr/testify/require" // 匯入 testify/require，簡化斷言撰寫
+
+	"sessionservice/internal/db"    // 匯入 db 套件，建立使用者與 session
+	"sessionservice/internal/infra" // 匯入 infra 套件，取得 payload 型別與 Redis key
+)
+
+// TestCheckImpossibleTravelDistantLogins 測試一小時內從台灣與美國登入會觸發告警。
+func TestCheckImpossibleTravelDistantLogins(t *testing.T) {
+	env := newTestEnv(t)                           // 建立測試環境
+	env.handlers.WithImpossibleTravel(1000, false) // 超過 1000 km/h 即告警
+
+	first := time.Now().Add(-time.Hour) // 第一次登入時間
+	alert, err := env.handlers.checkImpossibleTravel(env.ctx, 1, "TW", first)
+	require.NoError(t, err) // 比對不應失敗
+	require.Nil(t, alert)   // 沒有上一次登入，不告警
+
+	alert, err = env.handlers.checkImpossibleTravel(env.ctx, 1, "US", first.Add(time.Hour))
+	require.NoError(t, err)                           // 比對不應失敗
+	require.NotNil(t, alert)                          // 應告警
+	require.Equal(t, "TW", alert.FromCountry)         // 起點為上一次登入的國家
+	require.Equal(t, "US", alert.ToCountry)           // 終點為這次登入的國家
+	require.Greater(t, alert.SpeedKmh, float64(1000)) // 速度超過門檻
+}
+
+// TestCheckImpossibleTravelCloseLogins 測試鄰近國家、同一國家或時間差足夠時不會告警。
+func TestCheckImpossibleTravelCloseLogins(t *testing.T) {
+	env := newTestEnv(t)                           // 建立測試環境
+	env.handlers.WithImpossibleTravel(1000, false) // 超過 1000 km/h 即告警
+
+	start := time.Now().Add(-48 * time.Hour) // 起始登入時間
+	steps := []struct {
+		country string        // 這次登入的國家
+		after   time.Duration // 與起始時間的差距
+	}{
+		{"DE", 0},                          // 第一次登入
+		{"NL", 10 * time.Minute},           // 鄰近國家，距離低於判定下限
+		{"NL", 20 * time.Minute},           // 同一國家
+		{"JP", 30 * time.Hour},             // 距離遠但經過一天以上
+		{"XX", 30*time.Hour + time.Minute}, // 不在對照表內的國家
+	}
+	for _, step := range steps {
+		alert, err := env.handlers.checkImpossibleTravel(env.ctx, 2, step.country, start.Add(step.after))
+		require.NoError(t, err)              // 比對不應失敗
+		require.Nilf(t, alert, step.country) // 都不應告警
+	}
+}
+
+// TestHandleLoginAuditImpossibleTravelKicks 測試啟用自動踢人時，login:audit 偵測到 impossible travel 會撤銷所有 session。
+func TestHandleLoginAuditImpossibleTravelKicks(t *testing.T) {
+	env := newTestEnv(t)                          // 建立測試環境
+	env.handlers.WithImpossibleTravel(1000, true) // 告警並踢人
+
+	user, err := env.q.CreateUser(env.ctx, db.CreateUserParams{Username: "dave", PasswordHash: "x"}) // 建立使用者
+	require.NoError(t, err)                                                                          // 確保建立成功
+
+	now := time.Now()                                                                                                                      // 建立時間
+	require.NoError(t, env.q.CreateSession(env.ctx, db.CreateSessionParams{ID: "sid-t", UserID: user.ID, CreatedAt: now, ExpiresAt: now})) // 建立 DB session
+	require.NoError(t, env.rdb.HSet(env.ctx, infra.SessKey("sid-t"), "user_id", user.ID).Err())                                            // 寫入 Redis session hash
+	require.NoError(t, env.rdb.ZAdd(env.ctx, infra.UserSessKey(user.ID), redis.Z{Score: 1, Member: "sid-t"}).Err())                        // 寫入 user_sess zset
+
+	for i, country := range []string{"GB", "AU"} { // 相隔十分鐘從英國與澳洲登入
+		err = env.handlers.HandleLoginAudit(env.ctx, newTask(t, infra.TaskTypeLoginAudit, infra.LoginAuditPayload{
+			UserID:    &user.ID,
+			Username:  "dave",
+			Success:   true,
+			Reason:    "ok",
+			Country:   country,
+			CreatedAt: now.Add(time.Duration(i) * 10 * time.Minute),
+		}))
+		require.NoError(t, err) // 任務處理應成功
+	}
+
+	exists, err := env.rdb.Exists(env.ctx, infra.SessKey("sid-t")).Result() // 檢查 sess hash
+	require.NoError(t, err)                                                 // 操作應成功
+	require.EqualValues(t, 0, exists)                                       // 應已被踢掉
+
+	var revokedBy sql.NullString                                                                                       // 用來接收 revoked_by 欄位
+	err = env.sqlDB.QueryRowContext(env.ctx, "SELECT revoked_by FROM sessions WHERE id = ?", "sid-t").Scan(&revokedBy) // 查詢 revoked_by
+	require.NoError(t, err)                                                                                            // 查詢應成功
+	require.Equal(t, "system:impossible_travel", revokedBy.String)                                                     // 應標記為 impossible travel
+
+	var country sql.NullString                                                                                           // 用來接收 country 欄位
+	err = env.sqlDB.QueryRowContext(env.ctx, "SELECT country FROM login_events ORDER BY id DESC LIMIT 1").Scan(&country) // 查詢最後一筆事件
+	require.NoError(t, err)                                                                                              // 查詢應成功
+	require.Equal(t, "AU", country.String)                                                                               // login_events 應記錄國家
+}